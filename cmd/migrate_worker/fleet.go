@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"bizbundl/internal/config"
+	"bizbundl/internal/db/cluster"
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/tenancy"
+	"bizbundl/util"
+)
+
+// Result is the outcome of one tenant operation
+type Result struct {
+	TenantID string
+	From     uint
+	To       uint
+	Dirty    bool
+	// Planned lists the versions that were (or, in dry-run, would be) applied or rolled back
+	Planned []uint
+	Skipped string
+	Err     error
+}
+
+// Fleet runs migrations across tenant schemas with bounded concurrency
+type Fleet struct {
+	cfg         *config.Config
+	clusters    *cluster.Registry
+	queries     platformdb.Querier
+	tenants     *tenancy.Resolver
+	shops       map[string]platformdb.Shop
+	available   []uint
+	concurrency int
+	dryRun      bool
+}
+
+// op migrates a single tenant. It receives the migrate URL of the tenant's
+// schema on its cluster and the live version/dirty state.
+type op func(ctx context.Context, tenantURL string, current uint, dirty bool) Result
+
+// Run applies fn to every tenant, at most f.concurrency at a time.
+// Results are returned in tenant order. Unless readOnly or dry-run, each
+// tenant's resulting version and error are recorded in tenant_migrations.
+func (f *Fleet) Run(ctx context.Context, tenants []string, fn op, readOnly bool) []Result {
+	results := make([]Result, len(tenants))
+	sem := make(chan struct{}, max(f.concurrency, 1))
+	var wg sync.WaitGroup
+
+	for i, tenantID := range tenants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = f.runOne(ctx, tenantID, fn, readOnly || f.dryRun)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (f *Fleet) runOne(ctx context.Context, tenantID string, fn op, readOnly bool) Result {
+	url, err := f.clusters.TenantURL(f.shops[tenantID].DbCluster, tenantID)
+	if err != nil {
+		return Result{TenantID: tenantID, Err: err}
+	}
+	current, dirty, err := util.MigrationVersion(url, f.cfg.TenantMigrationDir())
+	if err != nil {
+		res := Result{TenantID: tenantID, Err: fmt.Errorf("failed to read version: %w", err)}
+		if !readOnly {
+			// Keep the last known version, only the error changes
+			if prev, err := f.queries.GetTenantMigration(ctx, tenantID); err == nil {
+				res.To, res.Dirty = uint(prev.Version), prev.Dirty
+			}
+			f.record(ctx, res)
+		}
+		return res
+	}
+
+	res := fn(ctx, url, current, dirty)
+	res.TenantID = tenantID
+	res.From, res.To, res.Dirty = current, current, dirty
+	if readOnly {
+		return res
+	}
+
+	// Re-read so the record reflects what actually happened (partial runs, dirty)
+	if v, d, err := util.MigrationVersion(url, f.cfg.TenantMigrationDir()); err == nil {
+		res.To, res.Dirty = v, d
+	}
+	f.record(ctx, res)
+
+	// Routing caches the schema version for version pinning
+	if shop, ok := f.shops[tenantID]; ok && f.tenants != nil {
+		f.tenants.InvalidateShop(ctx, shop)
+	}
+	return res
+}
+
+// record persists the tenant's version and last error in the platform schema
+func (f *Fleet) record(ctx context.Context, res Result) {
+	var lastError *string
+	if res.Err != nil {
+		msg := res.Err.Error()
+		lastError = &msg
+	}
+	_, err := f.queries.UpsertTenantMigration(ctx, platformdb.UpsertTenantMigrationParams{
+		TenantID:  res.TenantID,
+		Version:   int64(res.To),
+		Dirty:     res.Dirty,
+		LastError: lastError,
+	})
+	if err != nil {
+		fmt.Printf("⚠️  Failed to record migration state for %s: %v\n", res.TenantID, err)
+	}
+}
+
+// Up migrates each tenant to target
+func (f *Fleet) Up(target uint) op {
+	return func(ctx context.Context, tenantURL string, current uint, dirty bool) Result {
+		if dirty {
+			return Result{Err: fmt.Errorf("dirty at version %d, run clean or force first", current)}
+		}
+		planned, err := planUp(f.available, current, target)
+		if err != nil {
+			return Result{Err: err}
+		}
+		if len(planned) == 0 {
+			return Result{Skipped: "up to date"}
+		}
+		if f.dryRun {
+			return Result{Planned: planned}
+		}
+		err = util.MigrateTo(tenantURL, f.cfg.TenantMigrationDir(), target)
+		return Result{Planned: planned, Err: err}
+	}
+}
+
+// Down rolls each tenant back by steps
+func (f *Fleet) Down(steps int) op {
+	return func(ctx context.Context, tenantURL string, current uint, dirty bool) Result {
+		if dirty {
+			return Result{Err: fmt.Errorf("dirty at version %d, run clean or force first", current)}
+		}
+		planned := planDown(f.available, current, steps)
+		if len(planned) == 0 {
+			return Result{Skipped: "nothing to roll back"}
+		}
+		if f.dryRun {
+			return Result{Planned: planned}
+		}
+		err := util.MigrateDown(tenantURL, f.cfg.TenantMigrationDir(), len(planned))
+		return Result{Planned: planned, Err: err}
+	}
+}
+
+// Force sets the version without running migrations and clears the dirty flag
+func (f *Fleet) Force(version int) op {
+	return func(ctx context.Context, tenantURL string, current uint, dirty bool) Result {
+		if f.dryRun {
+			return Result{}
+		}
+		return Result{Err: util.ForceMigrationVersion(tenantURL, f.cfg.TenantMigrationDir(), version)}
+	}
+}
+
+// Clean resets dirty tenants to the last version that completed, so the
+// failed migration runs again on the next "up". Clean tenants are skipped.
+func (f *Fleet) Clean() op {
+	return func(ctx context.Context, tenantURL string, current uint, dirty bool) Result {
+		if !dirty {
+			return Result{Skipped: "not dirty"}
+		}
+		if f.dryRun {
+			return Result{}
+		}
+		version := cleanVersion(f.available, current)
+		return Result{Err: util.ForceMigrationVersion(tenantURL, f.cfg.TenantMigrationDir(), version)}
+	}
+}
+
+// Status only reads the live version
+func (f *Fleet) Status() op {
+	return func(ctx context.Context, tenantURL string, current uint, dirty bool) Result {
+		planned, _ := planUp(f.available, current, latest(f.available))
+		return Result{Planned: planned}
+	}
+}
+
+// -- Planning --
+
+// planUp lists the versions between current (exclusive) and target (inclusive)
+func planUp(available []uint, current, target uint) ([]uint, error) {
+	if target < current {
+		return nil, fmt.Errorf("at version %d, ahead of target %d (use down)", current, target)
+	}
+	if target != 0 && !slices.Contains(available, target) {
+		return nil, fmt.Errorf("unknown target version %d", target)
+	}
+	planned := []uint{}
+	for _, v := range available {
+		if v > current && v <= target {
+			planned = append(planned, v)
+		}
+	}
+	return planned, nil
+}
+
+// planDown lists the applied versions a rollback of steps would revert, newest first
+func planDown(available []uint, current uint, steps int) []uint {
+	planned := []uint{}
+	for i := len(available) - 1; i >= 0 && len(planned) < steps; i-- {
+		if available[i] <= current {
+			planned = append(planned, available[i])
+		}
+	}
+	return planned
+}
+
+// cleanVersion returns the version preceding a dirty one (-1 if it was the first)
+func cleanVersion(available []uint, dirty uint) int {
+	version := -1
+	for _, v := range available {
+		if v >= dirty {
+			break
+		}
+		version = int(v)
+	}
+	return version
+}
+
+func latest(available []uint) uint {
+	if len(available) == 0 {
+		return 0
+	}
+	return available[len(available)-1]
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var versions = []uint{1, 2, 3, 5}
+
+func TestPlanUp(t *testing.T) {
+	planned, err := planUp(versions, 0, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{1, 2, 3, 5}, planned)
+
+	planned, err = planUp(versions, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{3}, planned)
+
+	planned, err = planUp(versions, 5, 5)
+	require.NoError(t, err)
+	assert.Empty(t, planned)
+
+	_, err = planUp(versions, 3, 2)
+	assert.Error(t, err, "target behind current")
+
+	_, err = planUp(versions, 1, 4)
+	assert.Error(t, err, "unknown target")
+}
+
+func TestPlanDown(t *testing.T) {
+	assert.Equal(t, []uint{5, 3}, planDown(versions, 5, 2))
+	assert.Equal(t, []uint{2, 1}, planDown(versions, 2, 5))
+	assert.Empty(t, planDown(versions, 0, 1))
+}
+
+func TestCleanVersion(t *testing.T) {
+	assert.Equal(t, 3, cleanVersion(versions, 5))
+	assert.Equal(t, 1, cleanVersion(versions, 2))
+	assert.Equal(t, -1, cleanVersion(versions, 1))
+}
@@ -30,7 +30,7 @@ func main() {
 	defer conn.Close()
 
 	store := db.NewStore(conn)
-	catalogSvc := service.NewCatalogService(store)
+	catalogSvc := service.NewCatalogService(store, nil) // seed data is not subject to plan limits
 	ctx := context.Background()
 
 	fmt.Println("🌱 Starting Seeding...")
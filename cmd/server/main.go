@@ -4,6 +4,7 @@ import (
 	"bizbundl/internal/config"
 	db "bizbundl/internal/db/sqlc"
 	"bizbundl/internal/platform"
+	"bizbundl/internal/platform/ops"
 	"bizbundl/internal/platform/root"
 	"bizbundl/internal/platform/shops"
 	"bizbundl/internal/server"
@@ -11,7 +12,7 @@ import (
 	"bizbundl/internal/storefront/cart"
 	"bizbundl/internal/storefront/catalog"
 	"bizbundl/internal/storefront/order"
-	"bizbundl/internal/views/frontend"
+	"bizbundl/internal/views"
 	"bizbundl/util"
 	"context"
 	"os"
@@ -32,10 +33,7 @@ func main() {
 	}
 	// Run Platform Migrations (Public Schema) on Startup
 	// Tenant migrations are handled by the worker or when a shop is created.
-	platformMsgDir := "internal/db/migration/platform"
-	if cfg.InDocker == "true" {
-		platformMsgDir = "/app/internal/db/migration/platform"
-	}
+	platformMsgDir := cfg.PlatformMigrationDir()
 	// Note: We might want to force "search_path=public" here to be safe,
 	// though the default connection usually defaults to public.
 	err = util.RunMigrations(cfg.DBSourceURL(), platformMsgDir)
@@ -53,10 +51,11 @@ func main() {
 	catalogSvc := catalog.Init(app)
 	cartSvc := cart.Init(app)
 	order.Init(app, cartSvc, catalogSvc)
-	shops.Init(app)
+	shopsSvc := shops.Init(app)
+	ops.Init(app, shopsSvc)
 	root.Init(app)
 	platform.Init(app)
 
-	frontend.Init(app)
+	views.Init(app)
 	log.Fatal().Err(app.Start()).Msg("failed to start server")
 }
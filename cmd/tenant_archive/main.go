@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"bizbundl/internal/archive"
+	"bizbundl/internal/config"
+	"bizbundl/internal/db/cluster"
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/infra/redis"
+	"bizbundl/internal/tenancy"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const usage = `Usage: tenant_archive <command> [flags]
+
+Commands:
+  export --tenant T [--dir D]              write an archive of tenant T (default dir: ARCHIVE_DIR)
+  inspect --file F                         print an archive's manifest
+  import --file F --tenant T [--dsn URL]   restore an archive into the new schema T
+  move --tenant T --to C                   move tenant T to database cluster C
+  clusters                                 list the database clusters
+  cluster --name C [--closed] [--max N]    register a cluster (or change its placement)
+
+Flags:
+  --dsn URL        target database (postgresql://...), default: this app's database
+  --remap MODE     auto|yes|no, give restored rows new IDs. auto remaps when the
+                   archived tenant still exists in the target database (a copy)
+  --drain D        move: how long writes are frozen before the snapshot (default 35s)
+  --drop-source    move: drop the old schema once the tenant is switched over
+  --closed         cluster: do not place new shops on it
+  --max N          cluster: place at most N shops on it (0 = unlimited)
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+	cmd := os.Args[1]
+
+	// 1. Flags
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.Usage = func() { fmt.Print(usage) }
+	tenant := fs.String("tenant", "", "tenant ID (schema)")
+	file := fs.String("file", "", "archive path")
+	dir := fs.String("dir", "", "output directory for export")
+	dsn := fs.String("dsn", "", "target database URL for import")
+	remap := fs.String("remap", "auto", "auto|yes|no")
+	to := fs.String("to", "", "target cluster for move")
+	drain := fs.Duration("drain", 35*time.Second, "write freeze before the move snapshot")
+	dropSource := fs.Bool("drop-source", false, "drop the old schema after a move")
+	name := fs.String("name", "", "cluster name")
+	closed := fs.Bool("closed", false, "cluster does not take new shops")
+	maxShops := fs.Int("max", 0, "max shops on the cluster")
+	fs.Parse(os.Args[2:])
+
+	cfg := config.Load()
+	ctx := context.Background()
+
+	// 2. Dispatch
+	switch cmd {
+	case "export":
+		if *tenant == "" {
+			log.Fatal("❌ export requires --tenant")
+		}
+		if *dir == "" {
+			*dir = cfg.ArchiveDir
+		}
+		pool := connect(ctx, cfg.DBSource())
+		defer pool.Close()
+		clusters, err := cluster.Load(pool, cfg.DBSourceURL(), cfg.DBClusters)
+		if err != nil {
+			log.Fatalf("❌ Invalid DB_CLUSTERS: %v", err)
+		}
+		defer clusters.Close()
+
+		// The schema lives on the shop's cluster (main for unregistered schemas)
+		source := pool
+		if shop, err := platformdb.New(pool).GetShopByTenantID(ctx, *tenant); err == nil {
+			if source, err = clusters.Pool(ctx, shop.DbCluster); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		}
+
+		path, manifest, err := archive.ExportToFile(ctx, source, *tenant, *dir)
+		if err != nil {
+			log.Fatalf("❌ Export failed: %v", err)
+		}
+		printManifest(manifest)
+		fmt.Printf("✅ %s\n", path)
+	case "inspect":
+		a := open(*file)
+		defer a.Close()
+		printManifest(a.Manifest)
+	case "import":
+		if *tenant == "" {
+			log.Fatal("❌ import requires --tenant")
+		}
+		a := open(*file)
+		defer a.Close()
+
+		target := *dsn
+		if target == "" {
+			target = cfg.DBSourceURL()
+		}
+		pool := connect(ctx, target)
+		defer pool.Close()
+
+		remapIDs, err := shouldRemap(ctx, pool, *remap, a.Manifest.TenantID)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		tenantURL, err := cluster.WithSearchPath(target, *tenant)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+
+		result, err := archive.Import(ctx, pool, a, archive.ImportOptions{
+			TenantID:      *tenant,
+			DBURL:         tenantURL,
+			MigrationsDir: cfg.TenantMigrationDir(),
+			RemapIDs:      remapIDs,
+		})
+		if err != nil {
+			a.Close()
+			log.Fatalf("❌ Import failed: %v", err)
+		}
+		fmt.Printf("✅ %s -> %s: %d rows, %d new IDs, schema v%d -> v%d\n",
+			a.Manifest.TenantID, *tenant, result.Rows, result.RemappedIDs, a.Manifest.SchemaVersion, result.SchemaVersion)
+		fmt.Println("   The schema is not routed yet: point a shop at it (public.shops.tenant_id) to serve it.")
+	case "move":
+		if *tenant == "" || *to == "" {
+			log.Fatal("❌ move requires --tenant and --to")
+		}
+		pool := connect(ctx, cfg.DBSource())
+		defer pool.Close()
+		clusters, err := cluster.Load(pool, cfg.DBSourceURL(), cfg.DBClusters)
+		if err != nil {
+			log.Fatalf("❌ Invalid DB_CLUSTERS: %v", err)
+		}
+		defer clusters.Close()
+
+		queries := platformdb.New(pool)
+		mover := &Mover{
+			cfg:        cfg,
+			queries:    queries,
+			clusters:   clusters,
+			tenants:    tenancy.NewResolver(queries, redis.NewRedisClient(cfg), cfg.PlatformDomain),
+			drain:      *drain,
+			dropSource: *dropSource,
+		}
+		if err := mover.Move(ctx, *tenant, *to); err != nil {
+			log.Fatalf("❌ Move failed: %v", err)
+		}
+		fmt.Printf("✅ %s now lives on %s\n", *tenant, *to)
+	case "clusters":
+		pool := connect(ctx, cfg.DBSource())
+		defer pool.Close()
+		clusters, err := cluster.Load(pool, cfg.DBSourceURL(), cfg.DBClusters)
+		if err != nil {
+			log.Fatalf("❌ Invalid DB_CLUSTERS: %v", err)
+		}
+		rows, err := platformdb.New(pool).ListDBClusters(ctx)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		printClusters(rows, clusters)
+	case "cluster":
+		if *name == "" {
+			log.Fatal("❌ cluster requires --name")
+		}
+		pool := connect(ctx, cfg.DBSource())
+		defer pool.Close()
+
+		var limit *int32
+		if *maxShops > 0 {
+			n := int32(*maxShops)
+			limit = &n
+		}
+		if _, err := platformdb.New(pool).UpsertDBCluster(ctx, platformdb.UpsertDBClusterParams{
+			Name:            *name,
+			AcceptsNewShops: !*closed,
+			MaxShops:        limit,
+		}); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		fmt.Printf("✅ cluster %s saved. Every node needs it in DB_CLUSTERS before shops are placed on it.\n", *name)
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
+
+func connect(ctx context.Context, dsn string) *pgxpool.Pool {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		log.Fatalf("❌ Cannot connect to db: %v", err)
+	}
+	return pool
+}
+
+func open(path string) *archive.Archive {
+	if path == "" {
+		log.Fatal("❌ --file is required")
+	}
+	a, err := archive.OpenFile(path)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	return a
+}
+
+// shouldRemap resolves --remap. IDs only need to change when the copy lives
+// next to its source: restoring a backup or moving a tenant keeps them.
+func shouldRemap(ctx context.Context, pool *pgxpool.Pool, mode, source string) (bool, error) {
+	switch mode {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	case "auto":
+		var exists bool
+		err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", source).Scan(&exists)
+		return exists, err
+	default:
+		return false, fmt.Errorf("invalid --remap %q, want auto, yes or no", mode)
+	}
+}
+
+func printManifest(m *archive.Manifest) {
+	fmt.Printf("Tenant: %s  Schema: v%d  Format: %d  Created: %s\n", m.TenantID, m.SchemaVersion, m.FormatVersion, m.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TABLE\tROWS\tCOLUMNS\n")
+	for _, t := range m.Tables {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", t.Name, t.Rows, len(t.Columns))
+	}
+	w.Flush()
+}
+
+func printClusters(rows []platformdb.ListDBClustersRow, clusters *cluster.Registry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "CLUSTER\tSHOPS\tMAX\tNEW SHOPS\tCONFIGURED\n")
+	for _, r := range rows {
+		limit := "-"
+		if r.MaxShops != nil {
+			limit = fmt.Sprint(*r.MaxShops)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%t\t%t\n", r.Name, r.ShopCount, limit, r.AcceptsNewShops, clusters.Has(r.Name))
+	}
+	w.Flush()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"bizbundl/internal/archive"
+	"bizbundl/internal/config"
+	"bizbundl/internal/db/cluster"
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Mover moves a tenant's schema to another database cluster
+type Mover struct {
+	cfg      *config.Config
+	queries  *platformdb.Queries
+	clusters *cluster.Registry
+	tenants  *tenancy.Resolver
+	// drain is how long writes are frozen before the snapshot, so nodes
+	// drop their cached routing entry and in-flight requests finish
+	drain      time.Duration
+	dropSource bool
+}
+
+// Move freezes writes, exports the schema from its cluster, imports it on
+// target with the same IDs, then switches routing and lifts the freeze.
+// Reads keep being served from the source the whole time.
+func (m *Mover) Move(ctx context.Context, tenantID, target string) (err error) {
+	// 1. Check
+	shop, err := m.queries.GetShopByTenantID(ctx, tenantID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("unknown tenant %q", tenantID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load shop: %w", err)
+	}
+	if shop.DeletedAt.Valid || shop.Status != "active" {
+		return fmt.Errorf("%s is not an active shop (status %s)", tenantID, shop.Status)
+	}
+	if shop.DbCluster == target {
+		return fmt.Errorf("%s already lives on %s", tenantID, target)
+	}
+	source, err := m.clusters.Pool(ctx, shop.DbCluster)
+	if err != nil {
+		return err
+	}
+	dest, err := m.clusters.Pool(ctx, target)
+	if err != nil {
+		return err
+	}
+	targetURL, err := m.clusters.TenantURL(target, tenantID)
+	if err != nil {
+		return err
+	}
+
+	// 2. Freeze writes
+	frozen, err := m.queries.FreezeShop(ctx, shop.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%s is already frozen, is another move running?", tenantID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to freeze shop: %w", err)
+	}
+	m.tenants.InvalidateShop(ctx, frozen)
+	switched := false
+	defer func() {
+		if switched {
+			return
+		}
+		if unfrozen, unfreezeErr := m.queries.UnfreezeShop(context.Background(), shop.ID); unfreezeErr != nil {
+			err = fmt.Errorf("%w (and the shop is still frozen: %v)", err, unfreezeErr)
+		} else {
+			m.tenants.InvalidateShop(context.Background(), unfrozen)
+		}
+	}()
+
+	fmt.Printf(">> %s: writes frozen, draining for %s...\n", tenantID, m.drain)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(m.drain):
+	}
+
+	// 3. Export from the source (kept as a backup of the move)
+	path, manifest, err := archive.ExportToFile(ctx, source, tenantID, m.cfg.ArchiveDir)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	fmt.Printf(">> %s: exported v%d to %s\n", tenantID, manifest.SchemaVersion, path)
+
+	// 4. Import on the target, same IDs
+	a, err := archive.OpenFile(path)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	result, err := archive.Import(ctx, dest, a, archive.ImportOptions{
+		TenantID:      tenantID,
+		DBURL:         targetURL,
+		MigrationsDir: m.cfg.TenantMigrationDir(),
+	})
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+	fmt.Printf(">> %s: imported %d rows on %s\n", tenantID, result.Rows, target)
+
+	// 5. Switch routing, which also lifts the freeze
+	moved, err := m.queries.SetShopCluster(ctx, platformdb.SetShopClusterParams{ID: shop.ID, DbCluster: target})
+	if err != nil {
+		dropSchema(ctx, dest, tenantID)
+		return fmt.Errorf("failed to switch cluster: %w", err)
+	}
+	switched = true
+	m.tenants.InvalidateShop(ctx, moved)
+
+	if _, err := m.queries.UpsertTenantMigration(ctx, platformdb.UpsertTenantMigrationParams{
+		TenantID: tenantID,
+		Version:  int64(result.SchemaVersion),
+	}); err != nil {
+		fmt.Printf("⚠️  Failed to record migration state for %s: %v\n", tenantID, err)
+	}
+
+	// 6. The source copy is stale from here on
+	if m.dropSource {
+		dropSchema(ctx, source, tenantID)
+	} else {
+		fmt.Printf("   The old schema is still on %s, drop it once the move is verified.\n", shop.DbCluster)
+	}
+	return nil
+}
+
+func dropSchema(ctx context.Context, pool *pgxpool.Pool, tenantID string) {
+	if _, err := pool.Exec(ctx, "DROP SCHEMA IF EXISTS "+pgx.Identifier{tenantID}.Sanitize()+" CASCADE"); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to drop schema %s: %v\n", tenantID, err)
+	}
+}
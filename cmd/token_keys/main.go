@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"bizbundl/token"
+)
+
+const usage = `Usage: token_keys [flags]
+
+Generates a key for signing access tokens and prints its TOKEN_KEYS entries.
+
+Rotating keys:
+  1. Generate the next key with --active-from a time after every verifier
+     has been updated.
+  2. Append its public entry to TOKEN_KEYS of the verifying services, and
+     its secret entry to TOKEN_KEYS of the signing ones (next to the current
+     key, which keeps signing until then).
+  3. Once the old key's tokens have expired (the guest session duration,
+     the longest), remove it everywhere.
+
+Flags:
+  --id ID                key ID in the tokens' footer (default: the current month)
+  --version V            v4 or v2 (default v4)
+  --active-from TIME     RFC 3339 time the key starts signing (default: now)
+`
+
+func main() {
+	flag.Usage = func() { fmt.Print(usage) }
+	id := flag.String("id", time.Now().UTC().Format("2006-01"), "key ID")
+	version := flag.String("version", token.V4, "v4 or v2")
+	activeFrom := flag.String("active-from", "", "RFC 3339 time the key starts signing")
+	flag.Parse()
+
+	key, err := token.GenerateKey(*id, *version)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *activeFrom != "" {
+		if key.ActiveFrom, err = time.Parse(time.RFC3339, *activeFrom); err != nil {
+			log.Fatalf("invalid --active-from: %v", err)
+		}
+	}
+
+	fmt.Println("# Services that sign tokens (keep secret)")
+	fmt.Println(key.Secret())
+	fmt.Println("# Services that only verify tokens")
+	fmt.Println(key.Verifier())
+}
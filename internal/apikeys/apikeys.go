@@ -0,0 +1,168 @@
+// Package apikeys authenticates the headless and integration clients of a
+// shop. Shop admins create named keys holding some permissions (scopes); a
+// client sends one as "Authorization: Bearer bbk_...". Keys are stored as
+// SHA-256 and shown once, when created.
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/permissions"
+	"bizbundl/token"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Prefix starts every key, so they are told apart from session tokens
+	// (and found by secret scanners)
+	Prefix = "bbk_"
+	// Role is the user_role of requests authenticated with a key
+	Role = "api_key"
+	// ContextKey is the Fiber local (and so c.Context() value) holding the
+	// Key of the request, set by middleware.Auth
+	ContextKey = "api_key"
+
+	// prefixLength is how much of a key is kept to tell keys apart
+	prefixLength  = len(Prefix) + 8
+	maxNameLength = 100
+)
+
+var (
+	ErrInvalidKey   = errors.New("invalid, expired or revoked API key")
+	ErrKeyNotFound  = errors.New("API key not found")
+	ErrInvalidName  = errors.New("API keys need a name of at most 100 characters")
+	ErrInvalidScope = errors.New("unknown API key scope")
+	ErrNoScopes     = errors.New("API keys need at least one scope")
+	ErrPastExpiry   = errors.New("the expiry must be in the future")
+)
+
+// Scopes are the permissions keys may hold, in display order. Settings are
+// left out: a key never manages keys or payment gateways.
+var Scopes = scopes()
+
+func scopes() []string {
+	var out []string
+	for _, p := range permissions.All {
+		if p != permissions.SettingsManage {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Key is the API key a request is authenticated with
+type Key struct {
+	ID     string
+	Name   string
+	Scopes []string
+}
+
+// Allows reports whether the key holds permission
+func (k Key) Allows(permission string) bool {
+	return slices.Contains(k.Scopes, permission)
+}
+
+// FromContext returns the Key the request of ctx is authenticated with
+func FromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(ContextKey).(Key)
+	return key, ok
+}
+
+type Service struct {
+	store db.DBStore
+}
+
+func NewService(store db.DBStore) *Service {
+	return &Service{store: store}
+}
+
+// Create creates a key in the shop and returns it with its row. The key is
+// not stored: this is the only time it is shown. expiresAt is optional.
+func (s *Service) Create(ctx context.Context, name string, scopes []string, expiresAt *time.Time, createdBy pgtype.UUID) (string, db.ApiKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxNameLength {
+		return "", db.ApiKey{}, ErrInvalidName
+	}
+	if len(scopes) == 0 {
+		return "", db.ApiKey{}, ErrNoScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", db.ApiKey{}, ErrInvalidScope
+		}
+	}
+	var expires pgtype.Timestamptz
+	if expiresAt != nil {
+		if !expiresAt.After(time.Now()) {
+			return "", db.ApiKey{}, ErrPastExpiry
+		}
+		expires = pgtype.Timestamptz{Time: *expiresAt, Valid: true}
+	}
+
+	random, _, err := token.NewOpaque()
+	if err != nil {
+		return "", db.ApiKey{}, err
+	}
+	// The hash covers the prefix too
+	secret := Prefix + random
+	key, err := s.store.CreateAPIKey(ctx, db.CreateAPIKeyParams{
+		Name:      name,
+		Prefix:    secret[:prefixLength],
+		KeyHash:   token.HashOpaque(secret),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedBy: createdBy,
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		return "", db.ApiKey{}, err
+	}
+	return secret, key, nil
+}
+
+// List returns the shop's keys, revoked and expired ones included
+func (s *Service) List(ctx context.Context) ([]db.ApiKey, error) {
+	return s.store.ListAPIKeys(ctx)
+}
+
+// Revoke stops a key from working
+func (s *Service) Revoke(ctx context.Context, id pgtype.UUID) error {
+	n, err := s.store.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the key secret is, if it is valid in the shop of ctx,
+// and records that it was used
+func (s *Service) Authenticate(ctx context.Context, secret string) (Key, error) {
+	if !strings.HasPrefix(secret, Prefix) {
+		return Key{}, ErrInvalidKey
+	}
+	key, err := s.store.GetAPIKeyByHash(ctx, token.HashOpaque(secret))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	if key.RevokedAt.Valid || (key.ExpiresAt.Valid && !key.ExpiresAt.Time.After(time.Now())) {
+		return Key{}, ErrInvalidKey
+	}
+
+	if err := s.store.TouchAPIKey(ctx, key.ID); err != nil {
+		log.Warn().Err(err).Str("api_key", key.ID.String()).Msg("failed to record API key use")
+	}
+	return Key{ID: key.ID.String(), Name: key.Name, Scopes: key.Scopes}, nil
+}
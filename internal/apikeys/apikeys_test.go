@@ -0,0 +1,78 @@
+package apikeys_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bizbundl/internal/apikeys"
+	"bizbundl/internal/permissions"
+	"bizbundl/internal/testutil"
+	"bizbundl/token"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	keys := apikeys.NewService(srv.GetDB())
+	ctx := context.Background()
+
+	secret, key, err := keys.Create(ctx, "Warehouse sync", []string{permissions.OrdersView, permissions.OrdersFulfill}, nil, pgtype.UUID{})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, key.Prefix))
+	assert.Equal(t, token.HashOpaque(secret), key.KeyHash, "stored hashed")
+
+	got, err := keys.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID.String(), got.ID)
+	assert.True(t, got.Allows(permissions.OrdersFulfill))
+	assert.False(t, got.Allows(permissions.CatalogEdit))
+
+	listed, err := keys.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.True(t, listed[0].LastUsedAt.Valid, "last use is recorded")
+
+	_, err = keys.Authenticate(ctx, secret+"x")
+	assert.ErrorIs(t, err, apikeys.ErrInvalidKey)
+
+	require.NoError(t, keys.Revoke(ctx, key.ID))
+	_, err = keys.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, apikeys.ErrInvalidKey, "revoked")
+	assert.ErrorIs(t, keys.Revoke(ctx, key.ID), apikeys.ErrKeyNotFound)
+}
+
+func TestAPIKeyRules(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	keys := apikeys.NewService(srv.GetDB())
+	ctx := context.Background()
+
+	_, _, err := keys.Create(ctx, "Admin", []string{permissions.SettingsManage}, nil, pgtype.UUID{})
+	assert.ErrorIs(t, err, apikeys.ErrInvalidScope, "keys never manage keys")
+	_, _, err = keys.Create(ctx, "Nothing", nil, nil, pgtype.UUID{})
+	assert.ErrorIs(t, err, apikeys.ErrNoScopes)
+	_, _, err = keys.Create(ctx, " ", []string{permissions.OrdersView}, nil, pgtype.UUID{})
+	assert.ErrorIs(t, err, apikeys.ErrInvalidName)
+	past := time.Now().Add(-time.Minute)
+	_, _, err = keys.Create(ctx, "Old", []string{permissions.OrdersView}, &past, pgtype.UUID{})
+	assert.ErrorIs(t, err, apikeys.ErrPastExpiry)
+
+	soon := time.Now().Add(time.Second)
+	secret, _, err := keys.Create(ctx, "Trial", []string{permissions.OrdersView}, &soon, pgtype.UUID{})
+	require.NoError(t, err)
+	_, err = keys.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	time.Sleep(time.Until(soon) + 10*time.Millisecond)
+	_, err = keys.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, apikeys.ErrInvalidKey, "expired")
+}
@@ -0,0 +1,148 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildArchive(t *testing.T, manifest *Manifest, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		require.NoError(t, writeEntry(tw, name, []byte(body)))
+	}
+	if manifest != nil {
+		body, err := json.Marshal(manifest)
+		require.NoError(t, err)
+		require.NoError(t, writeEntry(tw, manifestName, body))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return &buf
+}
+
+func TestOpen(t *testing.T) {
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		TenantID:      "shop_a",
+		SchemaVersion: 1,
+		Null:          nullMarker,
+		Tables:        []Table{{Name: "products", Columns: []string{"id", "name"}, Rows: 1}},
+	}
+	data := "id,name\n7d2c0e6c-5b7e-4d43-9e5e-0f4f2b8c1a11,Shirt\n"
+
+	a, err := Open(buildArchive(t, manifest, map[string]string{"data/products.csv": data}))
+	require.NoError(t, err)
+	defer a.Close()
+
+	assert.Equal(t, "shop_a", a.Manifest.TenantID)
+	assert.Equal(t, nullMarker, a.null())
+	body, err := os.ReadFile(a.tablePath("products"))
+	require.NoError(t, err)
+	assert.Equal(t, data, string(body))
+
+	require.NoError(t, a.Validate([]uint{1, 2}))
+	assert.ErrorIs(t, a.Validate([]uint{}), ErrUnknownSchemaVersion)
+
+	a.Manifest.SchemaVersion = 3
+	assert.ErrorIs(t, a.Validate([]uint{1, 2}), ErrSchemaVersionNewer)
+}
+
+func TestSelectSQLRedactsCredentials(t *testing.T) {
+	users := Table{Name: "users", Columns: []string{"id", "email", "password_hash", "totp_secret"}}
+	assert.Equal(t, `SELECT "id", "email", "password_hash", "totp_secret" FROM "shop_a"."users"`, selectSQL(`"shop_a"`, users, nil))
+	assert.Equal(t, `SELECT "id", "email", '' AS "password_hash", NULL AS "totp_secret" FROM "shop_a"."users"`, selectSQL(`"shop_a"`, users, Credentials))
+
+	sessions := Table{Name: "sessions", Columns: []string{"id", "token"}}
+	assert.Equal(t, `SELECT "id", "token" FROM "shop_a"."sessions" WHERE false`, selectSQL(`"shop_a"`, sessions, Credentials))
+}
+
+func TestOpenRejectsBadArchives(t *testing.T) {
+	valid := &Manifest{FormatVersion: FormatVersion, SchemaVersion: 1, Tables: []Table{{Name: "products"}}}
+
+	cases := map[string]struct {
+		manifest *Manifest
+		files    map[string]string
+		err      error
+	}{
+		"missing manifest": {nil, map[string]string{"data/products.csv": ""}, ErrInvalidArchive},
+		"path traversal":   {valid, map[string]string{"data/products.csv": "", "../etc/passwd": ""}, ErrInvalidArchive},
+		"missing data":     {valid, map[string]string{}, ErrInvalidArchive},
+		"extra data":       {valid, map[string]string{"data/products.csv": "", "data/users.csv": ""}, ErrInvalidArchive},
+		"future format":    {&Manifest{FormatVersion: FormatVersion + 1}, map[string]string{}, ErrUnsupportedFormat},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Open(buildArchive(t, tc.manifest, tc.files))
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestTransformRemapsIDs(t *testing.T) {
+	const (
+		product  = "7d2c0e6c-5b7e-4d43-9e5e-0f4f2b8c1a11"
+		category = "0b1f3c52-8a47-4f0e-b7a4-5b1d0c9e2f33"
+		external = "c9a0e1f2-3b4c-4d5e-8f60-718293a4b5c6"
+	)
+
+	ids := IDMap{}
+	require.NoError(t, ids.collect(strings.NewReader("id,name\n"+product+",Shirt\n"+category+",Tops\n"), "id", nullMarker))
+	require.Len(t, ids, 2)
+
+	in := "id,category_id,settings,note\n" +
+		product + "," + category + ",\"{\"\"product_ids\"\": [\"\"" + product + "\"\", \"\"" + external + "\"\"]}\"," + product + "\n" +
+		product + `,\N,\N,` + "\n"
+
+	var out bytes.Buffer
+	fns := map[string]func(string) string{"id": ids.replace, "category_id": ids.replace, "settings": ids.replace}
+	require.NoError(t, transform(strings.NewReader(in), &out, nullMarker, fns))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "id,category_id,settings,note", lines[0])
+
+	// IDs of restored rows change everywhere, including inside JSON
+	assert.Contains(t, lines[1], ids[product]+","+ids[category])
+	assert.Contains(t, lines[1], `""`+ids[product]+`""`)
+	// unknown IDs and columns that are not remapped stay as they are
+	assert.Contains(t, lines[1], external)
+	assert.True(t, strings.HasSuffix(lines[1], ","+product))
+	// NULL stays NULL
+	assert.Equal(t, ids[product]+`,\N,\N,`, lines[2])
+}
+
+func TestLoadOrder(t *testing.T) {
+	tables := []string{"order_items", "orders", "products", "categories", "users"}
+	references := map[string][]string{
+		"order_items": {"orders", "products"},
+		"orders":      {"users"},
+		"products":    {"categories"},
+		"categories":  {"categories"},
+	}
+
+	order := loadOrder(tables, references)
+	require.Len(t, order, len(tables))
+
+	pos := map[string]int{}
+	for i, table := range order {
+		pos[table] = i
+	}
+	for table, refs := range references {
+		for _, ref := range refs {
+			assert.LessOrEqual(t, pos[ref], pos[table], "%s before %s", ref, table)
+		}
+	}
+
+	// a cycle still yields every table
+	assert.ElementsMatch(t, []string{"a", "b"}, loadOrder([]string{"a", "b"}, map[string][]string{"a": {"b"}, "b": {"a"}}))
+}
@@ -0,0 +1,294 @@
+// Package archive exports a tenant schema into a portable tar.gz archive and
+// restores it into a fresh schema, on the same or another database.
+//
+// Layout:
+//
+//	manifest.json        format version, tenant, schema version, tables
+//	data/<table>.csv     one CSV (with header) per table, COPY format.
+//	                     NULL is written as an unquoted \N (Manifest.Null).
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FormatVersion is bumped whenever the archive layout changes.
+// 1: NULL written as an empty field. 2: NULL written as Manifest.Null.
+const FormatVersion = 2
+
+const (
+	manifestName = "manifest.json"
+	nullMarker   = `\N`
+)
+
+// Manifest describes an archive
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	TenantID      string    `json:"tenant_id"`
+	SchemaVersion int64     `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Null          string    `json:"null"`
+	Redacted      bool      `json:"redacted,omitempty"`
+	Tables        []Table   `json:"tables"`
+}
+
+// Table is one exported table
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+}
+
+// Redaction leaves data out of an archive: Tables are exported empty, and
+// Columns (table -> column -> SQL expression) are written as the expression
+type Redaction struct {
+	Tables  []string
+	Columns map[string]map[string]string
+}
+
+// Credentials redacts passwords, secrets and tokens, for archives handed
+// to shop owners. Sign-in tables are exported empty, and credential columns
+// hold the value that disables them: an empty password_hash never matches,
+// so imported users reset their password.
+var Credentials = &Redaction{
+	Tables: []string{"sessions", "user_tokens", "recovery_codes", "api_keys"},
+	Columns: map[string]map[string]string{
+		"users": {
+			"password_hash":   "''",
+			"totp_secret":     "NULL",
+			"totp_enabled_at": "NULL",
+			"totp_last_step":  "0",
+		},
+	},
+}
+
+// ExportToFile writes the tenant's archive into dir and returns its path
+func ExportToFile(ctx context.Context, pool *pgxpool.Pool, tenantID, dir string) (string, *Manifest, error) {
+	return ExportRedactedToFile(ctx, pool, tenantID, dir, nil)
+}
+
+// ExportRedactedToFile is ExportToFile leaving out what redact names (nil
+// means nothing)
+func ExportRedactedToFile(ctx context.Context, pool *pgxpool.Pool, tenantID, dir string, redact *Redaction) (string, *Manifest, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", nil, fmt.Errorf("failed to create archive dir: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s.tar.gz", tenantID, time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	manifest, err := export(ctx, pool, tenantID, f, nil, redact)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", nil, err
+	}
+	return path, manifest, nil
+}
+
+// Export streams a consistent snapshot of the tenant schema into w
+func Export(ctx context.Context, pool *pgxpool.Pool, tenantID string, w io.Writer) (*Manifest, error) {
+	return ExportTables(ctx, pool, tenantID, w, nil)
+}
+
+// ExportTables is Export limited to the given tables (nil means all)
+func ExportTables(ctx context.Context, pool *pgxpool.Pool, tenantID string, w io.Writer, only []string) (*Manifest, error) {
+	return export(ctx, pool, tenantID, w, only, nil)
+}
+
+func export(ctx context.Context, pool *pgxpool.Pool, tenantID string, w io.Writer, only []string, redact *Redaction) (*Manifest, error) {
+	// 1. Snapshot: every table is read from the same point in time
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin export tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		TenantID:      tenantID,
+		CreatedAt:     time.Now().UTC(),
+		Null:          nullMarker,
+		Redacted:      redact != nil,
+	}
+	schema := pgx.Identifier{tenantID}.Sanitize()
+	if err := tx.QueryRow(ctx, "SELECT version FROM "+schema+".schema_migrations LIMIT 1").Scan(&manifest.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	tables, err := listTables(ctx, tx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if only != nil {
+		if tables, err = selectTables(tables, only); err != nil {
+			return nil, err
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	// 2. Tables
+	for _, table := range tables {
+		if err := exportTable(ctx, tx, schema, &table, redact, tw); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table.Name, err)
+		}
+		manifest.Tables = append(manifest.Tables, table)
+	}
+
+	// 3. Manifest (last, it carries the row counts)
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, manifestName, body); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// listTables returns the tenant's tables and their columns in ordinal order
+func listTables(ctx context.Context, tx pgx.Tx, tenantID string) ([]Table, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT c.table_name, array_agg(c.column_name::text ORDER BY c.ordinal_position)
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+		  ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = $1
+		  AND t.table_type = 'BASE TABLE'
+		  AND c.table_name <> 'schema_migrations'
+		GROUP BY c.table_name
+		ORDER BY c.table_name`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []Table{}
+	for rows.Next() {
+		var t Table
+		if err := rows.Scan(&t.Name, &t.Columns); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+func selectTables(tables []Table, only []string) ([]Table, error) {
+	selected := make([]Table, 0, len(only))
+	for _, name := range only {
+		i := slices.IndexFunc(tables, func(t Table) bool { return t.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("table %s does not exist", name)
+		}
+		selected = append(selected, tables[i])
+	}
+	return selected, nil
+}
+
+// exportTable COPYs a table into a temp file (tar needs the size up front)
+// and appends it to the archive.
+func exportTable(ctx context.Context, tx pgx.Tx, schema string, table *Table, redact *Redaction, tw *tar.Writer) error {
+	tmp, err := os.CreateTemp("", "tenant-export-*.csv")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	copySQL := fmt.Sprintf("COPY (%s) TO STDOUT WITH (FORMAT csv, HEADER true, NULL %s)",
+		selectSQL(schema, *table, redact), quoteLiteral(nullMarker))
+
+	tag, err := tx.Conn().PgConn().CopyTo(ctx, tmp, copySQL)
+	if err != nil {
+		return err
+	}
+	table.Rows = tag.RowsAffected()
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    dataPath(table.Name),
+		Mode:    0o640,
+		Size:    info.Size(),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, tmp)
+	return err
+}
+
+// selectSQL reads a table's columns, in order, as redact has them
+func selectSQL(schema string, table Table, redact *Redaction) string {
+	cols := make([]string, len(table.Columns))
+	for i, c := range table.Columns {
+		cols[i] = pgx.Identifier{c}.Sanitize()
+	}
+	where := ""
+	if redact != nil {
+		for i, c := range table.Columns {
+			if expr, ok := redact.Columns[table.Name][c]; ok {
+				cols[i] = expr + " AS " + cols[i]
+			}
+		}
+		if slices.Contains(redact.Tables, table.Name) {
+			where = " WHERE false"
+		}
+	}
+	return fmt.Sprintf("SELECT %s FROM %s.%s%s", strings.Join(cols, ", "), schema, pgx.Identifier{table.Name}.Sanitize(), where)
+}
+
+func writeEntry(tw *tar.Writer, name string, body []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o640,
+		Size:    int64(len(body)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(body)
+	return err
+}
+
+func dataPath(table string) string {
+	return "data/" + table + ".csv"
+}
+
+// quoteLiteral quotes s as a SQL string literal (COPY options take no parameters)
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,430 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"bizbundl/util"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrSchemaExists          = errors.New("target schema already exists")
+	ErrSchemaVersionMismatch = errors.New("target schema is not at the archive's version")
+)
+
+// ImportOptions describes where an archive is restored
+type ImportOptions struct {
+	// TenantID is the target schema. It must not exist yet.
+	TenantID string
+	// DBURL is a migrate-compatible URL of the target database, scoped to
+	// the target schema (see cluster.Registry.TenantURL)
+	DBURL         string
+	MigrationsDir string
+	// RemapIDs gives every restored row a new ID (and rewrites references
+	// to it). Needed when the source tenant lives next to the copy;
+	// restoring a backup or moving a tenant keeps the IDs.
+	RemapIDs bool
+}
+
+// LoadOptions tune how rows are written by Load
+type LoadOptions struct {
+	// RemapIDs gives every loaded row a new ID (see ImportOptions.RemapIDs)
+	RemapIDs bool
+	// Rewrite, if set, is applied to every non-NULL text and JSON value,
+	// e.g. to point media references at the new tenant's copies
+	Rewrite func(value string) string
+}
+
+// ImportResult describes a finished restore
+type ImportResult struct {
+	SchemaVersion uint // after migrating the restored data up
+	Rows          int64
+	RemappedIDs   int
+}
+
+// Import restores the archive into a fresh schema on pool's database:
+// the schema is created at the archive's version, loaded in one
+// transaction, then migrated up to the latest version. On failure the new
+// schema is dropped again.
+func Import(ctx context.Context, pool *pgxpool.Pool, a *Archive, opts ImportOptions) (*ImportResult, error) {
+	// 1. Validate
+	available, err := util.MigrationVersions(opts.MigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Validate(available); err != nil {
+		return nil, err
+	}
+	if !tableName.MatchString(opts.TenantID) {
+		return nil, fmt.Errorf("invalid tenant id %q", opts.TenantID)
+	}
+
+	var exists bool
+	if err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)", opts.TenantID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check schema: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaExists, opts.TenantID)
+	}
+
+	// 2. Schema at the archive's version
+	schema := pgx.Identifier{opts.TenantID}.Sanitize()
+	if _, err := pool.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	result, err := restore(ctx, pool, a, opts, available)
+	if err != nil {
+		if _, dropErr := pool.Exec(ctx, "DROP SCHEMA IF EXISTS "+schema+" CASCADE"); dropErr != nil {
+			log.Error().Err(dropErr).Str("tenant_id", opts.TenantID).Msg("failed to drop partially imported schema")
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func restore(ctx context.Context, pool *pgxpool.Pool, a *Archive, opts ImportOptions, available []uint) (*ImportResult, error) {
+	if err := util.MigrateTo(opts.DBURL, opts.MigrationsDir, uint(a.Manifest.SchemaVersion)); err != nil {
+		return nil, err
+	}
+
+	// 3. Data
+	result, err := load(ctx, pool, a, opts.TenantID, LoadOptions{RemapIDs: opts.RemapIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Bring it up to this build's schema
+	if err := util.RunMigrations(opts.DBURL, opts.MigrationsDir); err != nil {
+		return nil, err
+	}
+	result.SchemaVersion = available[len(available)-1]
+	return result, nil
+}
+
+// Load copies the archived tables into an existing tenant schema at the
+// archive's schema version, replacing their rows. It is how template shops
+// are cloned into a freshly provisioned one.
+func Load(ctx context.Context, pool *pgxpool.Pool, a *Archive, tenantID string, opts LoadOptions) (*ImportResult, error) {
+	if !tableName.MatchString(tenantID) {
+		return nil, fmt.Errorf("invalid tenant id %q", tenantID)
+	}
+	var version int64
+	err := pool.QueryRow(ctx, "SELECT version FROM "+pgx.Identifier{tenantID}.Sanitize()+".schema_migrations LIMIT 1").Scan(&version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version of %s: %w", tenantID, err)
+	}
+	if version != a.Manifest.SchemaVersion {
+		return nil, fmt.Errorf("%w: %s is at v%d, archive at v%d", ErrSchemaVersionMismatch, tenantID, version, a.Manifest.SchemaVersion)
+	}
+
+	result, err := load(ctx, pool, a, tenantID, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.SchemaVersion = uint(version)
+	return result, nil
+}
+
+// load copies every archived table in foreign key order inside one transaction
+func load(ctx context.Context, pool *pgxpool.Pool, a *Archive, tenantID string, opts LoadOptions) (*ImportResult, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	schema := pgx.Identifier{tenantID}.Sanitize()
+	target, err := describeSchema(ctx, tx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. The archive must fit the schema it was taken from
+	archived := map[string]Table{}
+	for _, t := range a.Manifest.Tables {
+		info, ok := target.columns[t.Name]
+		if !ok {
+			return nil, fmt.Errorf("table %s does not exist at schema version %d", t.Name, a.Manifest.SchemaVersion)
+		}
+		for _, col := range t.Columns {
+			if _, ok := info[col]; !ok {
+				return nil, fmt.Errorf("column %s.%s does not exist at schema version %d", t.Name, col, a.Manifest.SchemaVersion)
+			}
+		}
+		archived[t.Name] = t
+	}
+
+	// 2. Migrations may seed rows, the archive is the source of truth
+	if len(a.Manifest.Tables) > 0 {
+		names := make([]string, len(a.Manifest.Tables))
+		for i, t := range a.Manifest.Tables {
+			names[i] = schema + "." + pgx.Identifier{t.Name}.Sanitize()
+		}
+		if _, err := tx.Exec(ctx, "TRUNCATE "+strings.Join(names, ", ")+" CASCADE"); err != nil {
+			return nil, fmt.Errorf("failed to clear seeded rows: %w", err)
+		}
+	}
+
+	// 3. New IDs, only when asked
+	ids := IDMap{}
+	if opts.RemapIDs {
+		for table, pk := range target.primaryKeys {
+			if _, ok := archived[table]; !ok || target.columns[table][pk] != "uuid" {
+				continue
+			}
+			if err := collectFile(ids, a.tablePath(table), pk, a.null()); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", table, err)
+			}
+		}
+	}
+
+	// 4. Copy, parents before children
+	result := &ImportResult{RemappedIDs: len(ids)}
+	for _, name := range loadOrder(target.tables, target.references) {
+		t, ok := archived[name]
+		if !ok {
+			continue
+		}
+		rows, err := copyTable(ctx, tx, schema, t, a, rewriters(t, target.columns[name], ids, opts.Rewrite))
+		if err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", name, err)
+		}
+		if rows != t.Rows {
+			return nil, fmt.Errorf("failed to import %s: %d rows, manifest says %d", name, rows, t.Rows)
+		}
+		result.Rows += rows
+	}
+
+	// 5. Sequences continue after the restored rows
+	for _, seq := range target.sequences {
+		sql := fmt.Sprintf("SELECT setval(%s, COALESCE(MAX(%s), 1), MAX(%s) IS NOT NULL) FROM %s.%s",
+			quoteLiteral(seq.name), pgx.Identifier{seq.column}.Sanitize(), pgx.Identifier{seq.column}.Sanitize(),
+			schema, pgx.Identifier{seq.table}.Sanitize())
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return nil, fmt.Errorf("failed to reset sequence %s: %w", seq.name, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return result, nil
+}
+
+// rewriters picks the value rewrites for each column of t by its type
+func rewriters(t Table, types map[string]string, ids IDMap, rewrite func(string) string) map[string]func(string) string {
+	fns := map[string]func(string) string{}
+	for _, col := range t.Columns {
+		var steps []func(string) string
+		if len(ids) > 0 && remapTypes[types[col]] {
+			steps = append(steps, ids.replace)
+		}
+		if rewrite != nil && textTypes[types[col]] {
+			steps = append(steps, rewrite)
+		}
+		switch len(steps) {
+		case 0:
+		case 1:
+			fns[col] = steps[0]
+		default:
+			fns[col] = func(v string) string {
+				for _, step := range steps {
+					v = step(v)
+				}
+				return v
+			}
+		}
+	}
+	return fns
+}
+
+func copyTable(ctx context.Context, tx pgx.Tx, schema string, t Table, a *Archive, fns map[string]func(string) string) (int64, error) {
+	f, err := os.Open(a.tablePath(t.Name))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var src io.Reader = f
+	if len(fns) > 0 {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(transform(f, pw, a.null(), fns))
+		}()
+		defer pr.Close()
+		src = pr
+	}
+
+	cols := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = pgx.Identifier{c}.Sanitize()
+	}
+	copySQL := fmt.Sprintf("COPY %s.%s (%s) FROM STDIN WITH (FORMAT csv, HEADER true, NULL %s)",
+		schema, pgx.Identifier{t.Name}.Sanitize(), strings.Join(cols, ", "), quoteLiteral(a.null()))
+
+	tag, err := tx.Conn().PgConn().CopyFrom(ctx, src, copySQL)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func collectFile(ids IDMap, path, column, null string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ids.collect(f, column, null)
+}
+
+type sequence struct {
+	name, table, column string
+}
+
+// schemaInfo is what the import needs to know about the target schema
+type schemaInfo struct {
+	tables      []string
+	columns     map[string]map[string]string // table -> column -> udt_name
+	primaryKeys map[string]string            // single column primary keys
+	references  map[string][]string          // table -> tables it references
+	sequences   []sequence
+}
+
+func describeSchema(ctx context.Context, tx pgx.Tx, tenantID string) (*schemaInfo, error) {
+	info := &schemaInfo{
+		columns:     map[string]map[string]string{},
+		primaryKeys: map[string]string{},
+		references:  map[string][]string{},
+	}
+
+	// 1. Tables and column types
+	rows, err := tx.Query(ctx, `
+		SELECT c.table_name, c.column_name, c.udt_name,
+		       COALESCE(pg_get_serial_sequence(quote_ident(c.table_schema) || '.' || quote_ident(c.table_name), c.column_name), '')
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+		  ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = $1
+		  AND t.table_type = 'BASE TABLE'
+		  AND c.table_name <> 'schema_migrations'
+		ORDER BY c.table_name, c.ordinal_position`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe schema: %w", err)
+	}
+	for rows.Next() {
+		var table, column, udt, seq string
+		if err := rows.Scan(&table, &column, &udt, &seq); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if info.columns[table] == nil {
+			info.columns[table] = map[string]string{}
+			info.tables = append(info.tables, table)
+		}
+		info.columns[table][column] = udt
+		if seq != "" {
+			info.sequences = append(info.sequences, sequence{name: seq, table: table, column: column})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 2. Single column primary keys
+	rows, err = tx.Query(ctx, `
+		SELECT cl.relname, MIN(a.attname)
+		FROM pg_constraint con
+		JOIN pg_class cl ON cl.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = con.connamespace
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = ANY (con.conkey)
+		WHERE n.nspname = $1 AND con.contype = 'p'
+		GROUP BY cl.relname
+		HAVING COUNT(*) = 1`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read primary keys: %w", err)
+	}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		info.primaryKeys[table] = column
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 3. Foreign keys
+	rows, err = tx.Query(ctx, `
+		SELECT DISTINCT cl.relname, ref.relname
+		FROM pg_constraint con
+		JOIN pg_class cl ON cl.oid = con.conrelid
+		JOIN pg_class ref ON ref.oid = con.confrelid
+		JOIN pg_namespace n ON n.oid = con.connamespace
+		WHERE n.nspname = $1 AND con.contype = 'f'`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, ref string
+		if err := rows.Scan(&table, &ref); err != nil {
+			return nil, err
+		}
+		info.references[table] = append(info.references[table], ref)
+	}
+	return info, rows.Err()
+}
+
+// loadOrder sorts tables so that referenced tables come first. Self
+// references are fine (COPY checks them at the end of the statement);
+// tables in a reference cycle are appended in name order.
+func loadOrder(tables []string, references map[string][]string) []string {
+	sorted := slices.Clone(tables)
+	slices.Sort(sorted)
+
+	done := map[string]bool{}
+	order := make([]string, 0, len(sorted))
+	for len(order) < len(sorted) {
+		progressed := false
+		for _, t := range sorted {
+			if done[t] {
+				continue
+			}
+			ready := true
+			for _, ref := range references[t] {
+				if ref != t && !done[ref] && slices.Contains(sorted, ref) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[t] = true
+				order = append(order, t)
+				progressed = true
+			}
+		}
+		if !progressed {
+			for _, t := range sorted {
+				if !done[t] {
+					done[t] = true
+					order = append(order, t)
+				}
+			}
+		}
+	}
+	return order
+}
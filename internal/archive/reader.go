@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+var (
+	ErrInvalidArchive       = errors.New("not a valid tenant archive")
+	ErrUnsupportedFormat    = errors.New("unsupported archive format version")
+	ErrSchemaVersionNewer   = errors.New("archive was taken from a newer schema than this build knows")
+	ErrUnknownSchemaVersion = errors.New("archive schema version has no matching migration")
+)
+
+var tableName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Archive is an unpacked archive on local disk. Tar is sequential and the
+// manifest comes last, so the entries are extracted before anything is read.
+type Archive struct {
+	Manifest *Manifest
+	dir      string
+}
+
+// OpenFile unpacks the archive at path
+func OpenFile(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+	return Open(f)
+}
+
+// Open unpacks an archive into a temp dir. Call Close to remove it.
+func Open(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	dir, err := os.MkdirTemp("", "tenant-import-*")
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{dir: dir}
+	if err := a.extract(tar.NewReader(gz)); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Archive) extract(tr *tar.Reader) error {
+	var manifest []byte
+	files := map[string]bool{}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		// 1. Only the entries Export writes, nothing that could escape dir
+		if hdr.Name == manifestName {
+			if manifest, err = io.ReadAll(tr); err != nil {
+				return err
+			}
+			continue
+		}
+		table, ok := tableFromPath(hdr.Name)
+		if !ok || hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, hdr.Name)
+		}
+
+		// 2. Data
+		f, err := os.Create(a.tablePath(table))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		files[table] = true
+	}
+
+	// 3. Manifest must describe exactly what was shipped
+	if manifest == nil {
+		return fmt.Errorf("%w: missing %s", ErrInvalidArchive, manifestName)
+	}
+	a.Manifest = &Manifest{}
+	if err := json.Unmarshal(manifest, a.Manifest); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if a.Manifest.FormatVersion < 1 || a.Manifest.FormatVersion > FormatVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedFormat, a.Manifest.FormatVersion)
+	}
+	for _, t := range a.Manifest.Tables {
+		if !files[t.Name] {
+			return fmt.Errorf("%w: missing data for %s", ErrInvalidArchive, t.Name)
+		}
+		delete(files, t.Name)
+	}
+	for table := range files {
+		return fmt.Errorf("%w: %s is not in the manifest", ErrInvalidArchive, table)
+	}
+	return nil
+}
+
+// Validate checks the archive against the tenant migrations this build ships.
+// The archive is restored at its own schema version and migrated up from
+// there, so every version up to the latest one is accepted.
+func (a *Archive) Validate(available []uint) error {
+	v := a.Manifest.SchemaVersion
+	if len(available) == 0 || v < 1 {
+		return fmt.Errorf("%w: %d", ErrUnknownSchemaVersion, v)
+	}
+	if uint(v) > available[len(available)-1] {
+		return fmt.Errorf("%w: archive v%d, latest v%d", ErrSchemaVersionNewer, v, available[len(available)-1])
+	}
+	if !slices.Contains(available, uint(v)) {
+		return fmt.Errorf("%w: %d", ErrUnknownSchemaVersion, v)
+	}
+	return nil
+}
+
+// Close removes the unpacked files
+func (a *Archive) Close() error {
+	return os.RemoveAll(a.dir)
+}
+
+// null is the NULL marker used by the archive's CSV files
+func (a *Archive) null() string {
+	if a.Manifest.FormatVersion < 2 {
+		return ""
+	}
+	return a.Manifest.Null
+}
+
+func (a *Archive) tablePath(table string) string {
+	return filepath.Join(a.dir, table+".csv")
+}
+
+func tableFromPath(name string) (string, bool) {
+	dir, file := filepath.Split(name)
+	if dir != "data/" || filepath.Ext(file) != ".csv" {
+		return "", false
+	}
+	table := file[:len(file)-len(".csv")]
+	return table, tableName.MatchString(table)
+}
@@ -0,0 +1,102 @@
+package archive
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var uuidPattern = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// remapTypes are the column types (udt_name) that can carry a row ID: the
+// key columns themselves, arrays of them, and JSON such as page sections
+// pointing at products.
+var remapTypes = map[string]bool{"uuid": true, "_uuid": true, "json": true, "jsonb": true}
+
+// textTypes are the column types LoadOptions.Rewrite is applied to
+var textTypes = map[string]bool{"text": true, "varchar": true, "_text": true, "_varchar": true, "json": true, "jsonb": true}
+
+// IDMap maps the archive's row IDs to freshly generated ones
+type IDMap map[string]string
+
+// collect adds a new ID for every value of column in the CSV stream
+func (m IDMap) collect(r io.Reader, column, null string) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+	idx := slices.Index(header, column)
+	if idx < 0 {
+		return fmt.Errorf("column %s not in data", column)
+	}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if id := strings.ToLower(record[idx]); id != null && m[id] == "" {
+			m[id] = uuid.NewString()
+		}
+	}
+}
+
+// transform copies the CSV stream to w, passing every non-NULL value of the
+// columns in fns through its function. Other columns are copied as they are.
+func transform(r io.Reader, w io.Writer, null string, fns map[string]func(string) string) error {
+	cr := csv.NewReader(r)
+	cw := csv.NewWriter(w)
+
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	rewritten := map[int]func(string) string{}
+	for i, col := range header {
+		if fn, ok := fns[col]; ok {
+			rewritten[i] = fn
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, fn := range rewritten {
+			if record[i] != null {
+				record[i] = fn(record[i])
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// replace swaps every known ID inside s
+func (m IDMap) replace(s string) string {
+	return uuidPattern.ReplaceAllStringFunc(s, func(id string) string {
+		if mapped, ok := m[strings.ToLower(id)]; ok {
+			return mapped
+		}
+		return id
+	})
+}
@@ -0,0 +1,318 @@
+// Package audit keeps a shop's audit trail: who changed what in its admin,
+// from where, and what the change was. Services record an Entry after each
+// administrative change. Entries are written in the transaction of the
+// context, which TenancyMiddleware opens for each request (from c.Context()
+// and c.UserContext() alike) and db.WithTenant for jobs: a change whose entry
+// fails is rolled back with it. The table refuses updates and deletes.
+//
+// The platform keeps its own trail (platform/audit/service) with the same
+// Diff, Filter and CSV export.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"bizbundl/internal/apikeys"
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/sessions"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// ActorSystem makes the changes of jobs, seeders and provisioning
+	ActorSystem = "system"
+	// ActorAPIKey makes the changes of requests authenticated with a key
+	ActorAPIKey = apikeys.Role
+
+	// RequestIDKey is the Fiber local (and so c.Context() value) holding
+	// the ID of the request, set by middleware.RequestID
+	RequestIDKey = "request_id"
+
+	// Redacted replaces the values of secret fields
+	Redacted = "[redacted]"
+)
+
+// Entry is one change
+type Entry struct {
+	Action     string
+	EntityType string
+	EntityID   string
+	// Before and After are the entity before and after the change, as
+	// values encoding to JSON objects: no Before for a creation, no After
+	// for a deletion. Only the fields that changed are kept.
+	Before any
+	After  any
+	// Secrets are fields whose values are not kept, only that they changed
+	Secrets []string
+}
+
+// Log writes and reads the audit trail of the shop of ctx
+type Log struct {
+	store db.Querier
+}
+
+func NewLog(store db.Querier) *Log {
+	return &Log{store: store}
+}
+
+// Record appends e with the actor, IP and request ID of ctx. Unlike the
+// platform's, the entry shares the change's transaction, the one of ctx:
+// callers fail the change when it cannot be recorded, and the failure rolls
+// it back. Outside a transaction the change is already committed. An update
+// that changed nothing is not recorded.
+func (l *Log) Record(ctx context.Context, e Entry) error {
+	before, after, err := Diff(e.Before, e.After, e.Secrets...)
+	if err != nil {
+		return err
+	}
+	if e.Before != nil && e.After != nil && before == nil && after == nil {
+		return nil
+	}
+
+	actor := ActorFromContext(ctx)
+	_, err = l.store.CreateAuditLog(ctx, db.CreateAuditLogParams{
+		ActorID:    actor.ID,
+		ActorType:  actor.Type,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Before:     before,
+		After:      after,
+		Ip:         nonEmpty(sessions.ClientFromContext(ctx).IP),
+		RequestID:  nonEmpty(RequestIDFromContext(ctx)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// List returns the entries matching f, newest first
+func (l *Log) List(ctx context.Context, f Filter, limit int32) ([]Row, error) {
+	params := db.ListAuditLogParams{
+		Actor:      nonEmpty(f.Actor),
+		Action:     nonEmpty(f.Action),
+		EntityType: nonEmpty(f.EntityType),
+		EntityID:   nonEmpty(f.EntityID),
+		Since:      timestamptz(f.Since),
+		Until:      timestamptz(f.Until),
+		RowLimit:   limit,
+	}
+	if f.Before != nil {
+		params.BeforeAt = timestamptz(f.Before.CreatedAt)
+		params.BeforeID = f.Before.ID
+	}
+	entries, err := l.store.ListAuditLog(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, len(entries))
+	for i, e := range entries {
+		rows[i] = Row{
+			ID:         e.ID,
+			CreatedAt:  e.CreatedAt.Time,
+			ActorID:    e.ActorID,
+			ActorType:  e.ActorType,
+			ActorName:  e.ActorName,
+			Action:     e.Action,
+			EntityType: e.EntityType,
+			EntityID:   e.EntityID,
+			Before:     e.Before,
+			After:      e.After,
+			IP:         deref(e.Ip),
+			RequestID:  deref(e.RequestID),
+		}
+	}
+	return rows, nil
+}
+
+// Actor is who makes the changes of a request
+type Actor struct {
+	// ID is the user's or the API key's, invalid for the system
+	ID pgtype.UUID
+	// Type is the user's role, ActorAPIKey or ActorSystem
+	Type string
+}
+
+// ActorFromContext returns who is signed in to the request of ctx, set by
+// middleware.Auth. Outside requests, it is the system.
+func ActorFromContext(ctx context.Context) Actor {
+	if key, ok := apikeys.FromContext(ctx); ok {
+		var id pgtype.UUID
+		_ = id.Scan(key.ID)
+		return Actor{ID: id, Type: ActorAPIKey}
+	}
+	userID, _ := ctx.Value("user_id").(string)
+	role, _ := ctx.Value("user_role").(string)
+	var id pgtype.UUID
+	if userID == "" || role == "" || id.Scan(userID) != nil {
+		return Actor{Type: ActorSystem}
+	}
+	return Actor{ID: id, Type: role}
+}
+
+// RequestIDFromContext returns the ID of the request of ctx, empty outside
+// requests
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
+
+// Diff returns the fields of before and after that differ, as JSON objects,
+// with the values of secrets redacted. A nil before or after (a creation or
+// a deletion) keeps the other whole. Nothing changed returns two nils.
+func Diff(before, after any, secrets ...string) ([]byte, []byte, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b != nil && a != nil {
+		for k, v := range b {
+			if w, ok := a[k]; ok && reflect.DeepEqual(v, w) {
+				delete(b, k)
+				delete(a, k)
+			}
+		}
+	}
+	for _, secret := range secrets {
+		for _, m := range []map[string]any{b, a} {
+			if _, ok := m[secret]; ok {
+				m[secret] = Redacted
+			}
+		}
+	}
+	beforeJSON, err := encode(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := encode(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+// fields decodes v as a JSON object. Numbers are kept as written, so
+// prices compare exactly.
+func fields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	var m map[string]any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("audit entries need objects, not %s", raw)
+	}
+	return m, nil
+}
+
+func encode(m map[string]any) ([]byte, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// Change is one changed field of a Row
+type Change struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// Row is an entry as listed and exported, for shops and the platform alike
+type Row struct {
+	ID         pgtype.UUID
+	CreatedAt  time.Time
+	ActorID    pgtype.UUID
+	ActorType  string
+	ActorName  string
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     []byte
+	After      []byte
+	// Metadata is only kept by the platform
+	Metadata  []byte
+	IP        string
+	RequestID string
+}
+
+// Actor describes who made the change, for people
+func (r Row) Actor() string {
+	switch {
+	case r.ActorName != "":
+		return r.ActorName
+	case r.ActorID.Valid:
+		return r.ActorType + " " + r.ActorID.String()
+	default:
+		return r.ActorType
+	}
+}
+
+// Changes lists the changed fields by name
+func (r Row) Changes() []Change {
+	var before, after map[string]json.RawMessage
+	_ = json.Unmarshal(r.Before, &before)
+	_ = json.Unmarshal(r.After, &after)
+
+	names := map[string]bool{}
+	for k := range before {
+		names[k] = true
+	}
+	for k := range after {
+		names[k] = true
+	}
+	changes := make([]Change, 0, len(names))
+	for name := range names {
+		changes = append(changes, Change{Field: name, Before: display(before[name]), After: display(after[name])})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// display shows a JSON value, strings without their quotes
+func display(raw json.RawMessage) string {
+	if raw == nil {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func timestamptz(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: !t.IsZero()}
+}
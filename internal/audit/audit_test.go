@@ -0,0 +1,165 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"bizbundl/internal/apikeys"
+	"bizbundl/internal/audit"
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/testutil"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type product struct {
+	Title  string  `json:"title"`
+	Price  float64 `json:"price"`
+	Secret string  `json:"secret"`
+}
+
+func TestDiff(t *testing.T) {
+	before, after, err := audit.Diff(
+		product{Title: "Mug", Price: 10, Secret: "a"},
+		product{Title: "Mug", Price: 12.5, Secret: "b"},
+		"secret",
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"price":10,"secret":"[redacted]"}`, string(before))
+	assert.JSONEq(t, `{"price":12.5,"secret":"[redacted]"}`, string(after))
+
+	before, after, err = audit.Diff(nil, product{Title: "Mug"})
+	require.NoError(t, err)
+	assert.Nil(t, before, "created")
+	assert.JSONEq(t, `{"title":"Mug","price":0,"secret":""}`, string(after))
+
+	before, after, err = audit.Diff(product{Title: "Mug"}, product{Title: "Mug"})
+	require.NoError(t, err)
+	assert.Nil(t, before, "nothing changed")
+	assert.Nil(t, after)
+
+	_, _, err = audit.Diff("Mug", nil)
+	assert.Error(t, err, "not an object")
+}
+
+func TestFilterFromQuery(t *testing.T) {
+	cursor := audit.Cursor{CreatedAt: time.Date(2026, 10, 1, 12, 0, 0, 5, time.UTC)}
+	require.NoError(t, cursor.ID.Scan("00000000-0000-0000-0000-00000000000a"))
+	query := url.Values{
+		"actor":  {"owner@example.com"},
+		"action": {"product."},
+		"since":  {"2026-10-01"},
+		"until":  {"2026-10-02"},
+		"before": {cursor.String()},
+	}
+
+	f, err := audit.FilterFromQuery(func(key string, _ ...string) string { return query.Get(key) })
+	require.NoError(t, err)
+	assert.Equal(t, "owner@example.com", f.Actor)
+	assert.Equal(t, time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC), f.Until, "until is included")
+	assert.Equal(t, cursor, *f.Before)
+	assert.Equal(t, query, f.Values())
+
+	for _, bad := range []url.Values{{"since": {"yesterday"}}, {"before": {"nope"}}} {
+		_, err := audit.FilterFromQuery(func(key string, _ ...string) string { return bad.Get(key) })
+		assert.ErrorIs(t, err, audit.ErrInvalidFilter)
+	}
+}
+
+func TestExport(t *testing.T) {
+	// 1200 entries, listed in batches
+	var all []audit.Row
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1200; i > 0; i-- {
+		var id pgtype.UUID
+		require.NoError(t, id.Scan(fmt.Sprintf("00000000-0000-0000-0000-%012d", i)))
+		all = append(all, audit.Row{ID: id, CreatedAt: start.Add(time.Duration(i) * time.Second), ActorType: "admin", Action: "product.updated", EntityID: "=HYPERLINK()"})
+	}
+	calls := 0
+	list := func(f audit.Filter, limit int32) ([]audit.Row, error) {
+		calls++
+		rows := all
+		if f.Before != nil {
+			for i, r := range all {
+				if r.ID == f.Before.ID {
+					rows = all[i+1:]
+				}
+			}
+		}
+		return rows[:min(int(limit), len(rows))], nil
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, audit.Export(&buf, audit.Filter{}, list))
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 1201, "header and every entry")
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, "'=HYPERLINK()", records[1][6], "formulas are escaped")
+}
+
+func TestLog(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	log := audit.NewLog(srv.GetDB())
+
+	// A staff member, then an API key, from a request
+	ctx := context.WithValue(context.Background(), sessions.ClientKey, sessions.Client{IP: "203.0.113.7"})
+	ctx = context.WithValue(ctx, audit.RequestIDKey, "req-1")
+	staff := context.WithValue(context.WithValue(ctx, "user_id", "00000000-0000-0000-0000-00000000000a"), "user_role", "staff")
+	key := context.WithValue(ctx, apikeys.ContextKey, apikeys.Key{ID: "00000000-0000-0000-0000-00000000000b", Name: "ERP"})
+
+	require.NoError(t, log.Record(staff, audit.Entry{
+		Action: "product.updated", EntityType: "product", EntityID: "p1",
+		Before: product{Title: "Mug", Price: 10}, After: product{Title: "Mug", Price: 12},
+	}))
+	require.NoError(t, log.Record(key, audit.Entry{
+		Action: "order.updated", EntityType: "order", EntityID: "o1",
+		Before: map[string]any{"status": "pending"}, After: map[string]any{"status": "shipped"},
+	}))
+	require.NoError(t, log.Record(context.Background(), audit.Entry{
+		Action: "product.updated", EntityType: "product", EntityID: "p1",
+		Before: product{Title: "Mug"}, After: product{Title: "Mug"},
+	}), "nothing changed, nothing recorded")
+
+	rows, err := log.List(context.Background(), audit.Filter{}, 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, audit.ActorAPIKey, rows[0].ActorType, "newest first")
+
+	rows, err = log.List(context.Background(), audit.Filter{Action: "product."}, 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	r := rows[0]
+	assert.Equal(t, "staff", r.ActorType)
+	assert.Equal(t, "00000000-0000-0000-0000-00000000000a", r.ActorID.String())
+	assert.Equal(t, "203.0.113.7", r.IP)
+	assert.Equal(t, "req-1", r.RequestID)
+	assert.Equal(t, []audit.Change{{Field: "price", Before: "10", After: "12"}}, r.Changes())
+
+	// Entries share the transaction of ctx, as requests' do
+	tx, err := srv.GetDB().GetPool().Begin(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, log.Record(context.WithValue(staff, db.TxKey, tx), audit.Entry{
+		Action: "product.deleted", EntityType: "product", EntityID: "p2", Before: product{Title: "Cup"},
+	}))
+	require.NoError(t, tx.Rollback(context.Background()))
+	rows, err = log.List(context.Background(), audit.Filter{Action: "product.deleted"}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, rows, "rolled back with the change")
+
+	_, err = srv.GetDB().GetPool().Exec(context.Background(), "UPDATE audit_log SET action = 'x'")
+	assert.ErrorContains(t, err, "append-only")
+	_, err = srv.GetDB().GetPool().Exec(context.Background(), "DELETE FROM audit_log")
+	assert.ErrorContains(t, err, "append-only")
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+)
+
+// exportBatch is how many entries an export reads at a time
+const exportBatch = 500
+
+var csvHeader = []string{"time", "actor", "actor_type", "actor_id", "action", "entity_type", "entity_id", "before", "after", "metadata", "ip", "request_id"}
+
+// ListFunc lists the entries matching f, newest first (see Log.List)
+type ListFunc func(f Filter, limit int32) ([]Row, error)
+
+// Export writes every entry matching f to w as CSV, newest first
+func Export(w io.Writer, f Filter, list ListFunc) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	for {
+		rows, err := list(f, exportBatch)
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			actorID := ""
+			if r.ActorID.Valid {
+				actorID = r.ActorID.String()
+			}
+			record := []string{
+				r.CreatedAt.UTC().Format(time.RFC3339),
+				r.ActorName, r.ActorType, actorID,
+				r.Action, r.EntityType, r.EntityID,
+				string(r.Before), string(r.After), string(r.Metadata),
+				r.IP, r.RequestID,
+			}
+			for i := range record {
+				record[i] = escapeFormula(record[i])
+			}
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+		if len(rows) < exportBatch {
+			break
+		}
+		f.Before = CursorOf(rows[len(rows)-1])
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// escapeFormula keeps spreadsheets from running values (product titles,
+// emails) as formulas
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// Export writes the shop's entries matching f to w as CSV
+func (l *Log) Export(ctx context.Context, w io.Writer, f Filter) error {
+	return Export(w, f, func(f Filter, limit int32) ([]Row, error) {
+		return l.List(ctx, f, limit)
+	})
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const dateLayout = "2006-01-02"
+
+var ErrInvalidFilter = errors.New("invalid audit log filter")
+
+// Filter selects entries. Empty fields match every entry.
+type Filter struct {
+	// Actor is an email, an API key name or an actor ID
+	Actor string
+	// Action matches action prefixes: "product." is every product change
+	Action     string
+	EntityType string
+	EntityID   string
+	Since      time.Time
+	Until      time.Time
+	// Before continues a listing after its last entry
+	Before *Cursor
+}
+
+// Cursor is the position of an entry in listings
+type Cursor struct {
+	CreatedAt time.Time
+	ID        pgtype.UUID
+}
+
+// CursorOf is the position of r
+func CursorOf(r Row) *Cursor {
+	return &Cursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
+// String encodes c for query strings
+func (c Cursor) String() string {
+	return c.CreatedAt.UTC().Format(time.RFC3339Nano) + "_" + c.ID.String()
+}
+
+func parseCursor(s string) (*Cursor, error) {
+	at, id, ok := strings.Cut(s, "_")
+	if !ok {
+		return nil, ErrInvalidFilter
+	}
+	var c Cursor
+	var err error
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, at); err != nil {
+		return nil, ErrInvalidFilter
+	}
+	if c.ID.Scan(id) != nil {
+		return nil, ErrInvalidFilter
+	}
+	return &c, nil
+}
+
+// FilterFromQuery reads a Filter from query parameters: actor, action,
+// entity_type, entity_id, since and until (dates, until included) and
+// before (a Cursor)
+func FilterFromQuery(query func(key string, defaultValue ...string) string) (Filter, error) {
+	f := Filter{
+		Actor:      strings.TrimSpace(query("actor")),
+		Action:     strings.TrimSpace(query("action")),
+		EntityType: strings.TrimSpace(query("entity_type")),
+		EntityID:   strings.TrimSpace(query("entity_id")),
+	}
+	var err error
+	if since := query("since"); since != "" {
+		if f.Since, err = time.Parse(dateLayout, since); err != nil {
+			return Filter{}, ErrInvalidFilter
+		}
+	}
+	if until := query("until"); until != "" {
+		if f.Until, err = time.Parse(dateLayout, until); err != nil {
+			return Filter{}, ErrInvalidFilter
+		}
+		f.Until = f.Until.AddDate(0, 0, 1)
+	}
+	if before := query("before"); before != "" {
+		if f.Before, err = parseCursor(before); err != nil {
+			return Filter{}, err
+		}
+	}
+	return f, nil
+}
+
+// Values encodes f for links, the inverse of FilterFromQuery
+func (f Filter) Values() url.Values {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("actor", f.Actor)
+	set("action", f.Action)
+	set("entity_type", f.EntityType)
+	set("entity_id", f.EntityID)
+	if !f.Since.IsZero() {
+		v.Set("since", f.Since.Format(dateLayout))
+	}
+	if !f.Until.IsZero() {
+		v.Set("until", f.Until.AddDate(0, 0, -1).Format(dateLayout))
+	}
+	if f.Before != nil {
+		v.Set("before", f.Before.String())
+	}
+	return v
+}
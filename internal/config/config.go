@@ -12,6 +12,10 @@ import (
 
 const SessionCookieName = "session_id"
 
+// devTokenSymmetricKey is TOKEN_SYMMETRIC_KEY in development only: every
+// other environment must set its own
+const devTokenSymmetricKey = "9y$B&E)H@McQfTjWnZr4u7x!A%D*G-Ka"
+
 type Config struct {
 	AppPort              string        `mapstructure:"APP_PORT"`
 	DBHost               string        `mapstructure:"DB_HOST"`
@@ -25,6 +29,64 @@ type Config struct {
 	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
 	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
 
+	// TokenKeys switches access tokens to PASETO public tokens signed with
+	// these keys (see token.ParseKeys), so other services can verify them
+	// with just the public keys. Empty keeps local tokens encrypted with
+	// TokenSymmetricKey, which also signs links and cookies either way.
+	TokenKeys string `mapstructure:"TOKEN_KEYS"`
+
+	// PlatformDomain is the root domain shops are served under as subdomains
+	// (e.g. "bizbundl.com" -> "neon-vibes.bizbundl.com"). Any other host is
+	// treated as a shop's custom domain.
+	PlatformDomain string `mapstructure:"PLATFORM_DOMAIN"`
+
+	// Blue/Green Version Pinning (see docs/deployment.md)
+	// AppVersion identifies this binary's cohort. Empty disables pinning.
+	AppVersion string `mapstructure:"APP_VERSION"`
+	// TenantSchemaVersion overrides the expected tenant schema version
+	// (0 = latest migration in TenantMigrationDir)
+	TenantSchemaVersion int64 `mapstructure:"TENANT_SCHEMA_VERSION"`
+	// VersionHeader is set on misdirected responses for the upstream proxy
+	VersionHeader string `mapstructure:"VERSION_HEADER"`
+
+	// Offboarding: deleted shops are kept (restorable) for ShopRetentionPeriod,
+	// then their schema is dropped. Exports are written to ArchiveDir.
+	ShopRetentionPeriod time.Duration `mapstructure:"SHOP_RETENTION_PERIOD"`
+	ArchiveDir          string        `mapstructure:"ARCHIVE_DIR"`
+
+	// DBClusters lists the extra Postgres clusters tenant schemas can live on,
+	// "name=postgresql://...;name2=postgresql://...". The platform database
+	// above is always the "main" cluster.
+	DBClusters string `mapstructure:"DB_CLUSTERS"`
+
+	// Billing: renewal invoices are issued BillingInvoiceLead before the period
+	// ends. Unpaid shops get a reminder every BillingReminderInterval and are
+	// suspended once BillingGracePeriod has passed since the due date.
+	// PlatformURL is where the payment gateway sends owners back to.
+	PlatformURL             string        `mapstructure:"PLATFORM_URL"`
+	BillingGatewayKey       string        `mapstructure:"BILLING_GATEWAY_KEY"`
+	BillingInvoiceLead      time.Duration `mapstructure:"BILLING_INVOICE_LEAD"`
+	BillingGracePeriod      time.Duration `mapstructure:"BILLING_GRACE_PERIOD"`
+	BillingReminderInterval time.Duration `mapstructure:"BILLING_REMINDER_INTERVAL"`
+
+	// PlatformOpsToken guards the operator API (/api/ops). Empty disables it.
+	PlatformOpsToken string `mapstructure:"PLATFORM_OPS_TOKEN"`
+
+	// Mail: delivered over SMTP when SMTPHost is set (and not in
+	// development), otherwise appended to MailSinkFile
+	MailFrom     string `mapstructure:"MAIL_FROM"`
+	MailSinkFile string `mapstructure:"MAIL_SINK_FILE"`
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     string `mapstructure:"SMTP_PORT"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+
+	// SMS: one-time login codes. SMSProvider "console" logs messages
+	// instead of sending them. Numbers without a country code get
+	// SMSDefaultCountryCode.
+	SMSProvider           string `mapstructure:"SMS_PROVIDER"`
+	SMSDefaultCountryCode string `mapstructure:"SMS_DEFAULT_COUNTRY_CODE"`
+
 	// Redis Config
 	RedisHost     string `mapstructure:"REDIS_HOST"`
 	RedisPort     string `mapstructure:"REDIS_PORT"`
@@ -47,6 +109,27 @@ func (c *Config) DBSourceURL() string {
 
 }
 
+// TenantDBSourceURL returns a migrate-compatible URL scoped to a tenant schema
+func (c *Config) TenantDBSourceURL(tenantID string) string {
+	return fmt.Sprintf("%s&search_path=%s,public", c.DBSourceURL(), tenantID)
+}
+
+// PlatformMigrationDir is where the public schema migrations live
+func (c *Config) PlatformMigrationDir() string {
+	if c.InDocker == "true" {
+		return "/app/internal/db/migration/platform"
+	}
+	return "internal/db/migration/platform"
+}
+
+// TenantMigrationDir is where the per-shop schema migrations live
+func (c *Config) TenantMigrationDir() string {
+	if c.InDocker == "true" {
+		return "/app/internal/db/migration/tenant"
+	}
+	return "internal/db/migration/tenant"
+}
+
 func Load() *Config {
 	v := viper.New()
 	v.AutomaticEnv()
@@ -60,9 +143,35 @@ func Load() *Config {
 	v.SetDefault("DB_NAME", "bizbundl")
 	v.SetDefault("APP_ENV", "development")
 	v.SetDefault("IN_DOCKER", "false")
-	v.SetDefault("TOKEN_SYMMETRIC_KEY", "9y$B&E)H@McQfTjWnZr4u7x!A%D*G-Ka")
+	v.SetDefault("TOKEN_SYMMETRIC_KEY", "")
+	v.SetDefault("TOKEN_KEYS", "")
 	v.SetDefault("ACCESS_TOKEN_DURATION", time.Minute*5)
 	v.SetDefault("REFRESH_TOKEN_DURATION", time.Hour*24*30)
+	v.SetDefault("PLATFORM_DOMAIN", "localhost")
+	v.SetDefault("APP_VERSION", "")
+	v.SetDefault("TENANT_SCHEMA_VERSION", 0)
+	v.SetDefault("VERSION_HEADER", "X-Tenant-App-Version")
+	v.SetDefault("PLATFORM_OPS_TOKEN", "")
+	v.SetDefault("DB_CLUSTERS", "")
+	v.SetDefault("SHOP_RETENTION_PERIOD", 30*24*time.Hour)
+	v.SetDefault("ARCHIVE_DIR", "storage/archives")
+	v.SetDefault("PLATFORM_URL", "http://localhost:8123")
+	v.SetDefault("BILLING_GATEWAY_KEY", "")
+	v.SetDefault("BILLING_INVOICE_LEAD", 3*24*time.Hour)
+	v.SetDefault("BILLING_GRACE_PERIOD", 7*24*time.Hour)
+	v.SetDefault("BILLING_REMINDER_INTERVAL", 2*24*time.Hour)
+
+	// Mail Defaults
+	v.SetDefault("MAIL_FROM", "BizBundl <no-reply@localhost>")
+	v.SetDefault("MAIL_SINK_FILE", "storage/mail/outbox.log")
+	v.SetDefault("SMTP_HOST", "")
+	v.SetDefault("SMTP_PORT", "587")
+	v.SetDefault("SMTP_USERNAME", "")
+	v.SetDefault("SMTP_PASSWORD", "")
+
+	// SMS Defaults
+	v.SetDefault("SMS_PROVIDER", "console")
+	v.SetDefault("SMS_DEFAULT_COUNTRY_CODE", "880")
 
 	// Redis Defaults
 	v.SetDefault("REDIS_HOST", "localhost")
@@ -84,6 +193,12 @@ func Load() *Config {
 	if err := v.Unmarshal(&cfg); err != nil {
 		panic(fmt.Errorf("failed to unmarshal config: %w", err))
 	}
+	if cfg.TokenSymmetricKey == "" {
+		if cfg.Environment != "development" {
+			panic("TOKEN_SYMMETRIC_KEY must be set outside development")
+		}
+		cfg.TokenSymmetricKey = devTokenSymmetricKey
+	}
 	return &cfg
 }
 func bindEnvs(v *viper.Viper, iface interface{}, parts ...string) {
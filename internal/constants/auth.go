@@ -3,7 +3,18 @@ package constants
 import "time"
 
 const (
-	UserSessionDuration  = 7 * 24 * time.Hour
 	GuestSessionDuration = 2 * 365 * 24 * time.Hour
-	RefreshThreshold     = 30 * time.Minute
+	// GuestRenewThreshold is how old a guest token gets before it is re-issued
+	GuestRenewThreshold = 30 * time.Minute
+	// StepUpWindow is how long re-authenticating unlocks sensitive actions
+	StepUpWindow = 10 * time.Minute
+	// TwoFactorChallengeDuration is how long a user has to enter their
+	// second factor after their password
+	TwoFactorChallengeDuration = 5 * time.Minute
+	// HandoffTwoFactorSuffix ends the subject of shop handoffs from platform
+	// sessions that passed a second factor
+	HandoffTwoFactorSuffix = "|2fa"
+	// HandoffDuration is how long a shop handoff is valid: it only has to
+	// cover the redirect from the dashboard to the shop
+	HandoffDuration = time.Minute
 )
@@ -0,0 +1,157 @@
+// Package cluster keeps one connection pool per database cluster. Tenant
+// schemas live on the cluster recorded in public.shops.db_cluster; the
+// platform schema always lives on Main.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Main is the cluster hosting the platform schema (and every shop created
+// before clusters existed)
+const Main = "main"
+
+var ErrUnknownCluster = errors.New("unknown database cluster")
+
+// Registry maps cluster names to pools. Pools other than Main are opened on
+// first use.
+type Registry struct {
+	mu    sync.Mutex
+	urls  map[string]string
+	pools map[string]*pgxpool.Pool
+}
+
+// NewRegistry creates a Registry around the main pool. urls holds the
+// connection URLs of every cluster, including Main.
+func NewRegistry(main *pgxpool.Pool, urls map[string]string) *Registry {
+	r := &Registry{
+		urls:  make(map[string]string, len(urls)),
+		pools: map[string]*pgxpool.Pool{Main: main},
+	}
+	for name, u := range urls {
+		r.urls[name] = u
+	}
+	return r
+}
+
+// Load builds the Registry from the main pool and its URL plus DB_CLUSTERS
+// (see ParseURLs)
+func Load(main *pgxpool.Pool, mainURL, clusters string) (*Registry, error) {
+	urls, err := ParseURLs(clusters)
+	if err != nil {
+		return nil, err
+	}
+	urls[Main] = mainURL
+	return NewRegistry(main, urls), nil
+}
+
+// Pool returns the pool of the named cluster ("" means Main)
+func (r *Registry) Pool(ctx context.Context, name string) (*pgxpool.Pool, error) {
+	if name == "" {
+		name = Main
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if pool, ok := r.pools[name]; ok {
+		return pool, nil
+	}
+	u, ok := r.urls[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCluster, name)
+	}
+	pool, err := pgxpool.New(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to cluster %s: %w", name, err)
+	}
+	r.pools[name] = pool
+	return pool, nil
+}
+
+// Has reports whether the cluster is configured
+func (r *Registry) Has(name string) bool {
+	_, ok := r.urls[name]
+	return ok || name == Main
+}
+
+// Names lists the configured clusters
+func (r *Registry) Names() []string {
+	names := []string{Main}
+	for name := range r.urls {
+		if name != Main {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names[1:])
+	return names
+}
+
+// TenantURL returns a migrate-compatible URL of the cluster, scoped to the
+// tenant schema
+func (r *Registry) TenantURL(name, tenantID string) (string, error) {
+	if name == "" {
+		name = Main
+	}
+	u, ok := r.urls[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownCluster, name)
+	}
+	return WithSearchPath(u, tenantID)
+}
+
+// Close closes the pools opened by the registry. Main belongs to the caller.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, pool := range r.pools {
+		if name != Main {
+			pool.Close()
+			delete(r.pools, name)
+		}
+	}
+}
+
+// WithSearchPath scopes a database URL to the tenant schema (public stays as fallback)
+func WithSearchPath(dbURL, tenantID string) (string, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid database url: %w", err)
+	}
+	q := u.Query()
+	q.Set("search_path", tenantID+",public")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// ParseURLs parses DB_CLUSTERS: "name=postgresql://...;name2=postgresql://..."
+func ParseURLs(s string) (map[string]string, error) {
+	urls := map[string]string{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, u, ok := strings.Cut(entry, "=")
+		name, u = strings.TrimSpace(name), strings.TrimSpace(u)
+		if !ok || name == "" || u == "" {
+			return nil, fmt.Errorf("invalid cluster entry %q, want name=url", entry)
+		}
+		if name == Main {
+			return nil, fmt.Errorf("cluster %q is the platform database (DB_HOST, DB_NAME, ...)", Main)
+		}
+		if _, dup := urls[name]; dup {
+			return nil, fmt.Errorf("cluster %q is configured twice", name)
+		}
+		urls[name] = u
+	}
+	return urls, nil
+}
@@ -0,0 +1,52 @@
+package cluster
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURLs(t *testing.T) {
+	urls, err := ParseURLs(" eu1=postgresql://u:p@db-eu1:5432/bizbundl?sslmode=disable ; us1=postgresql://u:p@db-us1/bizbundl;")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"eu1": "postgresql://u:p@db-eu1:5432/bizbundl?sslmode=disable",
+		"us1": "postgresql://u:p@db-us1/bizbundl",
+	}, urls)
+
+	empty, err := ParseURLs("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for _, bad := range []string{"eu1", "=postgresql://x", "main=postgresql://x", "a=x;a=y"} {
+		_, err := ParseURLs(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(nil, map[string]string{
+		Main:  "postgresql://u:p@db-main:5432/bizbundl?sslmode=disable",
+		"eu1": "postgresql://u:p@db-eu1:5432/bizbundl",
+	})
+
+	assert.Equal(t, []string{Main, "eu1"}, r.Names())
+	assert.True(t, r.Has("eu1"))
+	assert.False(t, r.Has("us1"))
+
+	raw, err := r.TenantURL("", "shop_neon")
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "db-main:5432", u.Host)
+	assert.Equal(t, "disable", u.Query().Get("sslmode"))
+	assert.Equal(t, "shop_neon,public", u.Query().Get("search_path"))
+
+	_, err = r.TenantURL("us1", "shop_neon")
+	assert.ErrorIs(t, err, ErrUnknownCluster)
+	_, err = r.Pool(context.Background(), "us1")
+	assert.ErrorIs(t, err, ErrUnknownCluster)
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_shops_status;
+
+ALTER TABLE shops
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS provision_error,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,9 @@
+-- Shop provisioning state machine:
+-- pending -> schema_created -> migrated -> seeded -> active (or failed)
+-- Existing shops were provisioned synchronously, so they start as 'active'.
+ALTER TABLE shops
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN provision_error TEXT,
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX idx_shops_status ON shops(status) WHERE status <> 'active';
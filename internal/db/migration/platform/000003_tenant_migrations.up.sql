@@ -0,0 +1,12 @@
+-- Per-tenant schema migration state, maintained by cmd/migrate_worker
+-- and by shop provisioning. Mirrors each schema's schema_migrations row so the
+-- fleet can be inspected from the platform without connecting to every schema.
+CREATE TABLE tenant_migrations (
+    tenant_id VARCHAR(100) PRIMARY KEY REFERENCES shops(tenant_id) ON DELETE CASCADE,
+    version BIGINT NOT NULL DEFAULT 0,
+    dirty BOOLEAN NOT NULL DEFAULT FALSE,
+    last_error TEXT,
+    last_run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_tenant_migrations_failing ON tenant_migrations(tenant_id) WHERE dirty OR last_error IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_shops_app_version;
+ALTER TABLE shops DROP COLUMN IF EXISTS app_version;
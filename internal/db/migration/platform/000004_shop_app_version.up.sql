@@ -0,0 +1,5 @@
+-- Blue/green cohorts: the app version a shop is pinned to.
+-- NULL means unpinned (served by any binary whose schema version matches).
+ALTER TABLE shops ADD COLUMN app_version VARCHAR(50);
+
+CREATE INDEX idx_shops_app_version ON shops(app_version);
@@ -0,0 +1,7 @@
+ALTER TABLE shops
+    DROP COLUMN IF EXISTS suspended_at,
+    DROP COLUMN IF EXISTS suspended_by,
+    DROP COLUMN IF EXISTS suspension_reason,
+    DROP COLUMN IF EXISTS maintenance_mode,
+    DROP COLUMN IF EXISTS maintenance_allowlist,
+    DROP COLUMN IF EXISTS storefront_password_hash;
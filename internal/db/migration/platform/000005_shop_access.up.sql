@@ -0,0 +1,11 @@
+-- Request-time access control for shops (enforced by middleware.ShopGate)
+ALTER TABLE shops
+    -- Suspension: who suspended the shop decides who may lift it ('owner', 'billing', 'platform')
+    ADD COLUMN suspended_at TIMESTAMPTZ,
+    ADD COLUMN suspended_by VARCHAR(20),
+    ADD COLUMN suspension_reason TEXT,
+    -- Maintenance: storefront closed except for staff and allowlisted IPs/CIDRs
+    ADD COLUMN maintenance_mode BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN maintenance_allowlist TEXT[] NOT NULL DEFAULT '{}',
+    -- Pre-launch password gate (bcrypt), NULL = public storefront
+    ADD COLUMN storefront_password_hash VARCHAR(255);
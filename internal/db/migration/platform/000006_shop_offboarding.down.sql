@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS platform_audit_log;
+
+DROP INDEX IF EXISTS idx_shops_purge_after;
+ALTER TABLE shops
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS purge_after,
+    DROP COLUMN IF EXISTS purged_at,
+    DROP COLUMN IF EXISTS export_path;
@@ -0,0 +1,24 @@
+-- Offboarding: soft-delete with a retention window before the schema is dropped
+ALTER TABLE shops
+    ADD COLUMN deleted_at TIMESTAMPTZ,
+    ADD COLUMN deleted_by UUID REFERENCES users(id),
+    ADD COLUMN purge_after TIMESTAMPTZ,
+    ADD COLUMN purged_at TIMESTAMPTZ,
+    ADD COLUMN export_path TEXT;
+
+CREATE INDEX idx_shops_purge_after ON shops(purge_after) WHERE deleted_at IS NOT NULL AND purged_at IS NULL;
+
+-- Platform audit trail (append-only)
+CREATE TABLE platform_audit_log (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    actor_id UUID,                          -- NULL for system jobs
+    actor_type VARCHAR(20) NOT NULL,        -- 'owner', 'platform', 'system'
+    action VARCHAR(100) NOT NULL,           -- e.g. 'shop.deleted'
+    entity_type VARCHAR(50) NOT NULL,       -- e.g. 'shop'
+    entity_id VARCHAR(100) NOT NULL,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    ip VARCHAR(64),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_platform_audit_log_entity ON platform_audit_log(entity_type, entity_id, created_at DESC);
+CREATE INDEX idx_platform_audit_log_created_at ON platform_audit_log(created_at DESC);
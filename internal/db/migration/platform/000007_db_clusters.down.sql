@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_shops_db_cluster;
+
+ALTER TABLE shops
+    DROP COLUMN IF EXISTS frozen_at,
+    DROP COLUMN IF EXISTS db_cluster;
+
+DROP TABLE IF EXISTS db_clusters;
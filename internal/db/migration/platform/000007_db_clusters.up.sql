@@ -0,0 +1,18 @@
+-- Database clusters: tenant schemas can live on more than one Postgres.
+-- Connection strings are configuration (DB_CLUSTERS), not data.
+CREATE TABLE db_clusters (
+    name VARCHAR(50) PRIMARY KEY,
+    accepts_new_shops BOOLEAN NOT NULL DEFAULT true,
+    max_shops INT,                          -- NULL = unlimited
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The database the platform schema lives on
+INSERT INTO db_clusters (name) VALUES ('main');
+
+ALTER TABLE shops
+    ADD COLUMN db_cluster VARCHAR(50) NOT NULL DEFAULT 'main' REFERENCES db_clusters(name),
+    -- Set while the tenant is being moved between clusters: writes are refused
+    ADD COLUMN frozen_at TIMESTAMPTZ;
+
+CREATE INDEX idx_shops_db_cluster ON shops(db_cluster);
@@ -0,0 +1,3 @@
+ALTER TABLE shops DROP COLUMN IF EXISTS template_key;
+
+DROP TABLE IF EXISTS shop_templates;
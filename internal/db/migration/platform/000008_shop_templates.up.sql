@@ -0,0 +1,14 @@
+-- Template shops: platform-managed shops whose content new shops start from
+CREATE TABLE shop_templates (
+    key VARCHAR(50) PRIMARY KEY,            -- e.g. 'fashion'
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    shop_id UUID NOT NULL UNIQUE REFERENCES shops(id),
+    position INT NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The template a shop was cloned from (NULL = blank shop)
+ALTER TABLE shops ADD COLUMN template_key VARCHAR(50) REFERENCES shop_templates(key) ON DELETE SET NULL;
@@ -0,0 +1,18 @@
+-- Plans catalog: what each subscription plan allows (NULL limit = unlimited)
+CREATE TABLE plans (
+    key VARCHAR(50) PRIMARY KEY,            -- matches subscriptions.plan_name
+    name VARCHAR(100) NOT NULL,
+    max_products INT,
+    max_staff INT,                          -- admin and staff accounts
+    max_storage_bytes BIGINT,               -- uploaded media
+    max_monthly_orders INT,
+    custom_domain BOOLEAN NOT NULL DEFAULT false,
+    page_builder_components TEXT[],         -- NULL = every component
+    position INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO plans (key, name, max_products, max_staff, max_storage_bytes, max_monthly_orders, custom_domain, page_builder_components, position) VALUES
+    ('starter', 'Starter', 100, 2, 1073741824, 500, false, ARRAY['hero', 'product_grid', 'checkout_widget'], 1),
+    ('pro', 'Pro', NULL, 15, 21474836480, NULL, true, NULL, 2);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS invoices;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS credit;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS past_due_since;
+ALTER TABLE plans DROP COLUMN IF EXISTS price;
@@ -0,0 +1,35 @@
+-- Billing: plans are priced per monthly period
+ALTER TABLE plans ADD COLUMN price NUMERIC(12, 2) NOT NULL DEFAULT 0;
+UPDATE plans SET price = 990 WHERE key = 'starter';
+UPDATE plans SET price = 2990 WHERE key = 'pro';
+
+-- past_due_since: first missed due date, drives grace period and dunning.
+-- credit: left over from downgrades, taken off the next renewal invoice.
+ALTER TABLE subscriptions ADD COLUMN past_due_since TIMESTAMPTZ;
+ALTER TABLE subscriptions ADD COLUMN credit NUMERIC(12, 2) NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE TABLE invoices (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    shop_id UUID NOT NULL REFERENCES shops(id),
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id),
+    plan_name VARCHAR(50) NOT NULL,
+    kind VARCHAR(20) NOT NULL,              -- renewal, proration
+    amount NUMERIC(12, 2) NOT NULL,
+    credit_applied NUMERIC(12, 2) NOT NULL DEFAULT 0, -- subscription credit taken off amount
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- open, paid, void
+    due_at TIMESTAMPTZ NOT NULL,
+    payment_url TEXT,
+    payment_ref VARCHAR(255) UNIQUE,        -- gateway invoice ID, set once paid
+    reminders_sent INT NOT NULL DEFAULT 0,
+    last_reminder_at TIMESTAMPTZ,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_invoices_shop ON invoices(shop_id, created_at DESC);
+CREATE INDEX idx_invoices_open ON invoices(due_at) WHERE status = 'open';
+-- One renewal invoice per period, so the billing cycle can run on every node
+CREATE UNIQUE INDEX idx_invoices_renewal_period ON invoices(subscription_id, period_start)
+    WHERE kind = 'renewal' AND status <> 'void';
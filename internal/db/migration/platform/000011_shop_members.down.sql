@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS shop_invitations;
+DROP TABLE IF EXISTS shop_members;
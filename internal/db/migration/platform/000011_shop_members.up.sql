@@ -0,0 +1,28 @@
+-- Team members: platform users working in a shop they do not own.
+-- role is one of internal/permissions.Roles.
+CREATE TABLE shop_members (
+    shop_id UUID NOT NULL REFERENCES shops(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    role VARCHAR(50) NOT NULL,
+    invited_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (shop_id, user_id)
+);
+CREATE INDEX idx_shop_members_user ON shop_members(user_id);
+
+-- Invitations are accepted through a signed link; the row makes them
+-- single use and revocable
+CREATE TABLE shop_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    shop_id UUID NOT NULL REFERENCES shops(id),
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL,
+    invited_by UUID NOT NULL REFERENCES users(id),
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    accepted_by UUID REFERENCES users(id),
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_shop_invitations_shop ON shop_invitations(shop_id, created_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS platform_user_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
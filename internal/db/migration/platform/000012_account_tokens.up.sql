@@ -0,0 +1,16 @@
+-- Set once the owner proves they own the address
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- Password reset and email verification tokens. Only the SHA-256 of the
+-- token is stored; used_at makes them single use. Named apart from the
+-- tenants' user_tokens, which a tenant search_path would resolve first.
+CREATE TABLE platform_user_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(50) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_platform_user_tokens_user ON platform_user_tokens(user_id, purpose);
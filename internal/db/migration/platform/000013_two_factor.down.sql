@@ -0,0 +1,5 @@
+ALTER TABLE shops DROP COLUMN IF EXISTS require_two_factor;
+DROP TABLE IF EXISTS platform_recovery_codes;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
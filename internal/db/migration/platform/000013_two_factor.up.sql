@@ -0,0 +1,20 @@
+-- TOTP two-factor authentication. totp_secret is encrypted and set from
+-- setup on; totp_enabled_at once a first code confirmed it. totp_last_step
+-- is the time step of the last accepted code, so codes cannot be replayed.
+ALTER TABLE users ADD COLUMN totp_secret TEXT;
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- Single-use recovery codes, stored as SHA-256. Named apart from the
+-- tenants' recovery_codes, which a tenant search_path would resolve first.
+CREATE TABLE platform_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
+
+-- Owners can require two-factor authentication from their shop's staff
+ALTER TABLE shops ADD COLUMN require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
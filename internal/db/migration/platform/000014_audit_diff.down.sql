@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS platform_audit_log_append_only ON platform_audit_log;
+DROP FUNCTION IF EXISTS platform_audit_log_append_only();
+CREATE INDEX IF NOT EXISTS idx_platform_audit_log_created_at ON platform_audit_log(created_at DESC);
+DROP INDEX IF EXISTS idx_platform_audit_log_created_at_id;
+ALTER TABLE platform_audit_log
+    DROP COLUMN IF EXISTS request_id,
+    DROP COLUMN IF EXISTS after,
+    DROP COLUMN IF EXISTS before;
@@ -0,0 +1,20 @@
+-- Platform audit entries record what changed and the request that changed
+-- it, like the shops' audit_log. before and after hold only the changed
+-- fields.
+ALTER TABLE platform_audit_log
+    ADD COLUMN before JSONB,
+    ADD COLUMN after JSONB,
+    ADD COLUMN request_id VARCHAR(64);
+
+CREATE INDEX idx_platform_audit_log_created_at_id ON platform_audit_log(created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_platform_audit_log_created_at;
+
+CREATE FUNCTION platform_audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'platform_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER platform_audit_log_append_only
+    BEFORE UPDATE OR DELETE ON platform_audit_log
+    FOR EACH ROW EXECUTE FUNCTION platform_audit_log_append_only();
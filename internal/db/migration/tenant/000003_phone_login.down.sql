@@ -0,0 +1,13 @@
+-- Emails can only be required again once every account has one. Phone-only
+-- customers are never deleted here: give them an email, or keep this version.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE email IS NULL) THEN
+        RAISE EXCEPTION 'users without an email exist: phone sign-in cannot be rolled back';
+    END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS idx_users_verified_phone;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_verified_at;
+ALTER TABLE users ALTER COLUMN email SET NOT NULL;
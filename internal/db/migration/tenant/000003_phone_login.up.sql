@@ -0,0 +1,8 @@
+-- Customers who sign in with a phone code may have no email (and have an
+-- empty password_hash, which never matches)
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+
+-- Set once the customer proves they own the number with a code. Only
+-- verified numbers identify an account, so they must be unique.
+ALTER TABLE users ADD COLUMN phone_verified_at TIMESTAMPTZ;
+CREATE UNIQUE INDEX idx_users_verified_phone ON users(phone) WHERE phone_verified_at IS NOT NULL;
@@ -0,0 +1,14 @@
+-- External identities (OAuth 2.0 / OpenID Connect) customers sign in with.
+-- subject is the provider's stable ID for the user; email is the address the
+-- provider gave last, for support.
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, subject)
+);
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
@@ -0,0 +1,29 @@
+-- Audit trail of administrative changes in the shop (append-only). before
+-- and after hold only the fields that changed, secrets redacted. actor_id is
+-- the user or API key that made the change, NULL for system jobs; it is not
+-- a foreign key so entries outlive them.
+CREATE TABLE audit_log (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    actor_id UUID,
+    actor_type VARCHAR(20) NOT NULL,        -- user role, 'api_key' or 'system'
+    action VARCHAR(100) NOT NULL,           -- e.g. 'product.updated'
+    entity_type VARCHAR(50) NOT NULL,       -- e.g. 'product'
+    entity_id VARCHAR(100) NOT NULL,
+    before JSONB,                           -- NULL when created
+    after JSONB,                            -- NULL when deleted
+    ip VARCHAR(64),
+    request_id VARCHAR(64),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at DESC, id DESC);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at DESC);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_product_options_product;
+DROP INDEX IF EXISTS idx_product_variants_product;
+ALTER TABLE product_variants DROP COLUMN IF EXISTS position;
@@ -0,0 +1,4 @@
+-- Variants are shown in the order staff arrange them
+ALTER TABLE product_variants ADD COLUMN position INT NOT NULL DEFAULT 0;
+CREATE INDEX idx_product_variants_product ON product_variants(product_id, position);
+CREATE INDEX idx_product_options_product ON product_options(product_id, position);
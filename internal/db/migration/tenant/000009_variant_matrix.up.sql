@@ -0,0 +1,3 @@
+-- Variants whose option values are gone are archived, not deleted: carts
+-- and orders keep referencing them
+ALTER TABLE product_variants ADD COLUMN archived_at TIMESTAMPTZ;
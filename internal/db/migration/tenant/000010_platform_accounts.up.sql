@@ -0,0 +1,4 @@
+-- Staff accounts opened from the platform dashboard are linked to their
+-- platform user, and found by it: never by email, which anyone can
+-- register in the shop first
+ALTER TABLE users ADD COLUMN platform_user_id UUID UNIQUE;
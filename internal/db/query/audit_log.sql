@@ -0,0 +1,34 @@
+-- name: CreateAuditLog :one
+INSERT INTO audit_log (
+    actor_id,
+    actor_type,
+    action,
+    entity_type,
+    entity_id,
+    before,
+    after,
+    ip,
+    request_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING *;
+
+-- name: ListAuditLog :many
+-- Newest first. Filters are optional; action matches a prefix ("product."),
+-- actor an email, an API key name or an actor ID. Pages continue before
+-- the (created_at, id) of the last entry.
+SELECT a.*, COALESCE(u.email, k.name, '')::text AS actor_name
+FROM audit_log a
+LEFT JOIN users u ON u.id = a.actor_id
+LEFT JOIN api_keys k ON k.id = a.actor_id
+WHERE (sqlc.narg(actor)::text IS NULL
+        OR u.email = sqlc.narg(actor) OR k.name = sqlc.narg(actor) OR a.actor_id::text = sqlc.narg(actor))
+    AND (sqlc.narg(action)::text IS NULL OR a.action LIKE sqlc.narg(action) || '%')
+    AND (sqlc.narg(entity_type)::text IS NULL OR a.entity_type = sqlc.narg(entity_type))
+    AND (sqlc.narg(entity_id)::text IS NULL OR a.entity_id = sqlc.narg(entity_id))
+    AND (sqlc.narg(since)::timestamptz IS NULL OR a.created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamptz IS NULL OR a.created_at < sqlc.narg(until))
+    AND (sqlc.narg(before_at)::timestamptz IS NULL
+        OR (a.created_at, a.id) < (sqlc.narg(before_at)::timestamptz, sqlc.narg(before_id)::uuid))
+ORDER BY a.created_at DESC, a.id DESC
+LIMIT sqlc.arg(row_limit);
@@ -61,3 +61,17 @@ WHERE cart_id = $1;
 -- name: DeleteCart :exec
 DELETE FROM carts
 WHERE id = $1;
+
+-- name: MoveCartItems :exec
+-- Adds the items of one cart to another, summing quantities
+INSERT INTO cart_items (cart_id, product_id, variant_id, quantity)
+SELECT sqlc.arg(to_cart_id), ci.product_id, ci.variant_id, ci.quantity
+FROM cart_items ci
+WHERE ci.cart_id = sqlc.arg(from_cart_id)
+ON CONFLICT (cart_id, product_id, COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'::uuid))
+DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity;
+
+-- name: ReassignUserCarts :exec
+UPDATE carts
+SET user_id = sqlc.arg(to_user_id), updated_at = now()
+WHERE user_id = sqlc.arg(from_user_id);
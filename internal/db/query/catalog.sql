@@ -84,15 +84,52 @@ SET
     is_digital = COALESCE(sqlc.narg('is_digital'), is_digital),
     file_path = COALESCE(sqlc.narg('file_path'), file_path),
     category_id = COALESCE(sqlc.narg('category_id'), category_id),
-    is_active = COALESCE(sqlc.narg('is_active'), is_active)
+    is_active = COALESCE(sqlc.narg('is_active'), is_active),
+    is_featured = COALESCE(sqlc.narg('is_featured'), is_featured)
 WHERE id = $1
 RETURNING *;
 
--- name: DeleteProduct :exec
+-- name: DeleteProduct :execrows
 DELETE FROM products
 WHERE id = $1;
 
 
+-- Options
+
+-- name: CreateProductOption :one
+INSERT INTO product_options (
+    product_id,
+    name,
+    values,
+    position
+) VALUES (
+    $1, $2, $3,
+    (SELECT COALESCE(MAX(position) + 1, 0) FROM product_options WHERE product_id = $1)
+) RETURNING *;
+
+-- name: GetProductOption :one
+SELECT * FROM product_options
+WHERE id = $1 LIMIT 1;
+
+-- name: ListProductOptions :many
+SELECT * FROM product_options
+WHERE product_id = $1
+ORDER BY position, name;
+
+-- name: UpdateProductOption :one
+UPDATE product_options
+SET
+    name = COALESCE(sqlc.narg('name'), name),
+    values = COALESCE(sqlc.narg('values'), values),
+    position = COALESCE(sqlc.narg('position'), position)
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteProductOption :execrows
+DELETE FROM product_options
+WHERE id = $1;
+
+
 -- Variants
 
 -- name: CreateProductVariant :one
@@ -104,19 +141,81 @@ INSERT INTO product_variants (
     compare_at_price,
     sku,
     stock_quantity,
-    is_active
+    is_active,
+    position
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
+    $1, $2, $3, $4, $5, $6, $7, $8,
+    (SELECT COALESCE(MAX(position) + 1, 0) FROM product_variants WHERE product_id = $1)
 ) RETURNING *;
 
 -- name: ListVariantsByProduct :many
 SELECT * FROM product_variants
-WHERE product_id = $1;
+WHERE product_id = $1 AND archived_at IS NULL
+ORDER BY position, title;
 
--- name: DeleteVariant :exec
+-- name: ListArchivedVariantsByProduct :many
+SELECT * FROM product_variants
+WHERE product_id = $1 AND archived_at IS NOT NULL
+ORDER BY archived_at DESC;
+
+-- name: UpdateProductVariant :one
+-- compare_at_price is replaced as given: NULL removes it
+UPDATE product_variants
+SET
+    title = COALESCE(sqlc.narg('title'), title),
+    options = COALESCE(sqlc.narg('options'), options),
+    price = COALESCE(sqlc.narg('price'), price),
+    compare_at_price = sqlc.narg('compare_at_price'),
+    sku = COALESCE(sqlc.narg('sku'), sku),
+    stock_quantity = COALESCE(sqlc.narg('stock_quantity'), stock_quantity),
+    is_active = COALESCE(sqlc.narg('is_active'), is_active)
+WHERE id = $1
+RETURNING *;
+
+-- name: SetVariantOptions :one
+-- Places a variant in the product's matrix, restoring it if archived
+UPDATE product_variants
+SET
+    title = $2,
+    options = $3,
+    position = $4,
+    archived_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: ArchiveVariant :execrows
+UPDATE product_variants
+SET archived_at = NOW()
+WHERE id = $1 AND archived_at IS NULL;
+
+-- name: ReleaseVariantSKU :exec
+-- Frees an archived variant's SKU for the variant taking its place
+UPDATE product_variants
+SET sku = NULL
+WHERE id = $1 AND archived_at IS NOT NULL;
+
+-- name: RenameVariantOption :exec
+UPDATE product_variants
+SET options = (options - sqlc.arg(old_name)::text) || jsonb_build_object(sqlc.arg(new_name)::text, options -> sqlc.arg(old_name)::text)
+WHERE product_id = $1 AND options ? sqlc.arg(old_name)::text;
+
+-- name: RenameVariantOptionValue :exec
+UPDATE product_variants
+SET options = jsonb_set(options, ARRAY[sqlc.arg(name)::text], to_jsonb(sqlc.arg(new_value)::text))
+WHERE product_id = $1 AND options ->> sqlc.arg(name)::text = sqlc.arg(old_value)::text;
+
+-- name: SetVariantPosition :execrows
+UPDATE product_variants
+SET position = $3
+WHERE id = $1 AND product_id = $2;
+
+-- name: DeleteVariant :execrows
 DELETE FROM product_variants
 WHERE id = $1;
 
 -- name: GetProductVariant :one
 SELECT * FROM product_variants
 WHERE id = $1 LIMIT 1;
+
+-- name: CountProducts :one
+SELECT COUNT(*) FROM products;
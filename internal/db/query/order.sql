@@ -39,3 +39,12 @@ UPDATE orders
 SET status = $2, payment_status = $3, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: CountOrdersSince :one
+SELECT COUNT(*) FROM orders
+WHERE created_at >= $1;
+
+-- name: ReassignUserOrders :exec
+UPDATE orders
+SET user_id = sqlc.arg(to_user_id)
+WHERE user_id = sqlc.arg(from_user_id);
@@ -0,0 +1,38 @@
+-- name: CreatePlatformAuditLog :one
+INSERT INTO platform_audit_log (
+    actor_id,
+    actor_type,
+    action,
+    entity_type,
+    entity_id,
+    metadata,
+    ip,
+    before,
+    after,
+    request_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING *;
+
+-- name: ListPlatformAuditLogByEntity :many
+SELECT * FROM platform_audit_log
+WHERE entity_type = $1 AND entity_id = $2
+ORDER BY created_at DESC
+LIMIT $3;
+
+-- name: ListPlatformAuditLog :many
+-- Newest first, filtered like the shops' ListAuditLog: action matches a
+-- prefix, actor an email or an actor ID
+SELECT a.*, COALESCE(u.email, '')::text AS actor_name
+FROM platform_audit_log a
+LEFT JOIN users u ON u.id = a.actor_id
+WHERE (sqlc.narg(actor)::text IS NULL OR u.email = sqlc.narg(actor) OR a.actor_id::text = sqlc.narg(actor))
+    AND (sqlc.narg(action)::text IS NULL OR a.action LIKE sqlc.narg(action) || '%')
+    AND (sqlc.narg(entity_type)::text IS NULL OR a.entity_type = sqlc.narg(entity_type))
+    AND (sqlc.narg(entity_id)::text IS NULL OR a.entity_id = sqlc.narg(entity_id))
+    AND (sqlc.narg(since)::timestamptz IS NULL OR a.created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamptz IS NULL OR a.created_at < sqlc.narg(until))
+    AND (sqlc.narg(before_at)::timestamptz IS NULL
+        OR (a.created_at, a.id) < (sqlc.narg(before_at)::timestamptz, sqlc.narg(before_id)::uuid))
+ORDER BY a.created_at DESC, a.id DESC
+LIMIT sqlc.arg(row_limit);
@@ -0,0 +1,24 @@
+-- name: ListDBClusters :many
+-- Clusters with their live shop count
+SELECT c.name, c.accepts_new_shops, c.max_shops, COUNT(s.id)::bigint AS shop_count
+FROM db_clusters c
+LEFT JOIN shops s ON s.db_cluster = c.name AND s.purged_at IS NULL
+GROUP BY c.name
+ORDER BY c.name;
+
+-- name: ListClustersForPlacement :many
+-- Clusters that can take a new shop, least loaded first
+SELECT c.name
+FROM db_clusters c
+LEFT JOIN shops s ON s.db_cluster = c.name AND s.purged_at IS NULL
+WHERE c.accepts_new_shops
+GROUP BY c.name
+HAVING c.max_shops IS NULL OR COUNT(s.id) < c.max_shops
+ORDER BY COUNT(s.id), c.name;
+
+-- name: UpsertDBCluster :one
+INSERT INTO db_clusters (name, accepts_new_shops, max_shops)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE
+SET accepts_new_shops = EXCLUDED.accepts_new_shops, max_shops = EXCLUDED.max_shops
+RETURNING *;
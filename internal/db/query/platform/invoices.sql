@@ -0,0 +1,62 @@
+-- name: CreateInvoice :one
+INSERT INTO invoices (
+    shop_id,
+    subscription_id,
+    plan_name,
+    kind,
+    amount,
+    credit_applied,
+    period_start,
+    period_end,
+    status,
+    due_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING *;
+
+-- name: GetInvoice :one
+SELECT * FROM invoices
+WHERE id = $1 LIMIT 1;
+
+-- name: ListInvoicesByShop :many
+SELECT * FROM invoices
+WHERE shop_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: SetInvoicePaymentURL :one
+UPDATE invoices
+SET payment_url = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkInvoicePaid :one
+UPDATE invoices
+SET status = 'paid', payment_ref = $2, paid_at = NOW()
+WHERE id = $1 AND status = 'open'
+RETURNING *;
+
+-- name: RecordInvoiceReminder :one
+UPDATE invoices
+SET reminders_sent = reminders_sent + 1, last_reminder_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListOverdueInvoices :many
+SELECT * FROM invoices
+WHERE status = 'open' AND due_at < $1
+ORDER BY due_at;
+
+-- name: CountOverdueInvoicesByShop :one
+SELECT COUNT(*) FROM invoices
+WHERE shop_id = $1 AND status = 'open' AND due_at < $2;
+
+-- name: CountOpenInvoices :one
+SELECT COUNT(*) FROM invoices
+WHERE subscription_id = $1 AND kind = $2 AND status = 'open';
+
+-- name: VoidUpcomingInvoices :exec
+-- Voids open invoices not yet due; overdue ones stay owed
+UPDATE invoices
+SET status = 'void'
+WHERE subscription_id = $1 AND kind = $2 AND status = 'open' AND due_at >= $3;
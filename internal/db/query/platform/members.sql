@@ -0,0 +1,66 @@
+-- name: UpsertShopMember :one
+INSERT INTO shop_members (shop_id, user_id, role, invited_by)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (shop_id, user_id) DO UPDATE SET
+    role = EXCLUDED.role,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetShopMember :one
+SELECT * FROM shop_members
+WHERE shop_id = $1 AND user_id = $2 LIMIT 1;
+
+-- name: UpdateShopMemberRole :one
+UPDATE shop_members
+SET role = $3, updated_at = NOW()
+WHERE shop_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteShopMember :exec
+DELETE FROM shop_members
+WHERE shop_id = $1 AND user_id = $2;
+
+-- name: ListShopMembers :many
+SELECT m.shop_id, m.user_id, m.role, m.created_at, u.email, u.first_name, u.last_name
+FROM shop_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.shop_id = $1
+ORDER BY m.created_at;
+
+-- name: CountShopMembers :one
+SELECT COUNT(*) FROM shop_members
+WHERE shop_id = $1;
+
+-- name: ListMemberShops :many
+-- Shops the user works in as a team member
+SELECT sqlc.embed(shops), m.role
+FROM shop_members m
+JOIN shops ON shops.id = m.shop_id
+WHERE m.user_id = $1 AND shops.deleted_at IS NULL
+ORDER BY shops.name;
+
+-- name: CreateShopInvitation :one
+INSERT INTO shop_invitations (shop_id, email, role, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetShopInvitation :one
+SELECT * FROM shop_invitations
+WHERE id = $1 LIMIT 1;
+
+-- name: ListPendingShopInvitations :many
+SELECT * FROM shop_invitations
+WHERE shop_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY created_at DESC;
+
+-- name: AcceptShopInvitation :one
+UPDATE shop_invitations
+SET accepted_at = NOW(), accepted_by = $2
+WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeShopInvitation :one
+UPDATE shop_invitations
+SET revoked_at = NOW()
+WHERE id = $1 AND shop_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+RETURNING *;
@@ -0,0 +1,7 @@
+-- name: ListPlans :many
+SELECT * FROM plans
+ORDER BY position, key;
+
+-- name: GetPlan :one
+SELECT * FROM plans
+WHERE key = $1 LIMIT 1;
@@ -4,9 +4,12 @@ INSERT INTO shops (
     name,
     subdomain,
     tenant_id,
-    is_active
+    is_active,
+    status,
+    db_cluster,
+    template_key
 ) VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6, $7, $8
 ) RETURNING *;
 
 -- name: GetShopBySubdomain :one
@@ -15,4 +18,196 @@ WHERE subdomain = $1 LIMIT 1;
 
 -- name: ListShopsByOwner :many
 SELECT * FROM shops
-WHERE owner_id = $1;
+WHERE owner_id = $1 AND purged_at IS NULL;
+
+-- name: GetShopByCustomDomain :one
+SELECT * FROM shops
+WHERE custom_domain = $1 LIMIT 1;
+
+-- name: GetShopByID :one
+SELECT * FROM shops
+WHERE id = $1 LIMIT 1;
+
+-- name: UpdateShopCustomDomain :one
+UPDATE shops
+SET custom_domain = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateShopStatus :one
+UPDATE shops
+SET
+    status = $2,
+    provision_error = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ActivateShop :one
+UPDATE shops
+SET
+    status = 'active',
+    is_active = TRUE,
+    provision_error = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListShopsPendingProvision :many
+SELECT * FROM shops
+WHERE status IN ('pending', 'schema_created', 'migrated', 'seeded')
+  AND updated_at < $1
+ORDER BY created_at ASC;
+
+-- name: ListProvisionedShops :many
+-- Shops whose schema exists and has been migrated at least once
+SELECT * FROM shops
+WHERE status NOT IN ('pending', 'schema_created', 'failed')
+  AND purged_at IS NULL
+ORDER BY tenant_id;
+
+-- name: MoveShopsToAppVersion :many
+-- Moves up to batch_size active shops from one cohort to another.
+-- Optionally limited to given tenants and/or to shops whose schema is at schema_version.
+UPDATE shops
+SET
+    app_version = sqlc.narg(to_version),
+    updated_at = NOW()
+WHERE id IN (
+    SELECT s.id FROM shops s
+    LEFT JOIN tenant_migrations tm ON tm.tenant_id = s.tenant_id
+    WHERE s.status = 'active'
+      AND s.app_version IS NOT DISTINCT FROM sqlc.narg(from_version)
+      AND (sqlc.narg(schema_version)::bigint IS NULL OR tm.version = sqlc.narg(schema_version)::bigint)
+      AND (cardinality(sqlc.arg(tenant_ids)::text[]) = 0 OR s.tenant_id = ANY(sqlc.arg(tenant_ids)::text[]))
+    ORDER BY s.created_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE OF s SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CountShopsByAppVersion :many
+SELECT app_version, COUNT(*) AS shops
+FROM shops
+WHERE status = 'active'
+GROUP BY app_version
+ORDER BY app_version;
+
+-- name: SuspendShop :one
+UPDATE shops
+SET
+    suspended_at = NOW(),
+    suspended_by = $2,
+    suspension_reason = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UnsuspendShop :one
+UPDATE shops
+SET
+    suspended_at = NULL,
+    suspended_by = NULL,
+    suspension_reason = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateShopMaintenance :one
+UPDATE shops
+SET
+    maintenance_mode = $2,
+    maintenance_allowlist = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateShopStorefrontPassword :one
+UPDATE shops
+SET
+    storefront_password_hash = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkShopDeleted :one
+UPDATE shops
+SET
+    deleted_at = NOW(),
+    deleted_by = $2,
+    purge_after = $3,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RestoreShop :one
+UPDATE shops
+SET
+    deleted_at = NULL,
+    deleted_by = NULL,
+    purge_after = NULL,
+    export_path = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NOT NULL AND purged_at IS NULL
+RETURNING *;
+
+-- name: SetShopExportPath :one
+UPDATE shops
+SET
+    export_path = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListShopsAwaitingExport :many
+SELECT * FROM shops
+WHERE deleted_at IS NOT NULL
+  AND purged_at IS NULL
+  AND export_path IS NULL
+ORDER BY deleted_at;
+
+-- name: ListShopsDueForPurge :many
+SELECT * FROM shops
+WHERE deleted_at IS NOT NULL
+  AND purged_at IS NULL
+  AND purge_after < NOW()
+ORDER BY purge_after;
+
+-- name: MarkShopPurged :one
+UPDATE shops
+SET
+    purged_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetShopByTenantID :one
+SELECT * FROM shops
+WHERE tenant_id = $1 LIMIT 1;
+
+-- name: FreezeShop :one
+UPDATE shops
+SET frozen_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND frozen_at IS NULL
+RETURNING *;
+
+-- name: UnfreezeShop :one
+UPDATE shops
+SET frozen_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetShopCluster :one
+-- Switches a moved shop to its new cluster and lifts the write freeze
+UPDATE shops
+SET db_cluster = $2, frozen_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateShopRequireTwoFactor :one
+UPDATE shops
+SET
+    require_two_factor = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,55 @@
+-- name: GetLatestSubscriptionByShop :one
+SELECT * FROM subscriptions
+WHERE shop_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE id = $1 LIMIT 1;
+
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (shop_id, plan_name, status, current_period_end)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListSubscriptionsDueForRenewal :many
+-- Subscriptions whose period ends before $1 and have no renewal invoice for
+-- the next period yet
+SELECT s.* FROM subscriptions s
+JOIN shops sh ON sh.id = s.shop_id
+WHERE s.status IN ('active', 'past_due')
+  AND s.current_period_end <= $1
+  AND sh.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM invoices i
+    WHERE i.subscription_id = s.id
+      AND i.kind = 'renewal'
+      AND i.period_start = s.current_period_end
+      AND i.status <> 'void'
+  )
+ORDER BY s.current_period_end;
+
+-- name: ExtendSubscription :one
+UPDATE subscriptions
+SET current_period_end = $2, status = 'active', past_due_since = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', past_due_since = COALESCE(past_due_since, $2), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ChangeSubscriptionPlan :one
+UPDATE subscriptions
+SET plan_name = $2, credit = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetSubscriptionCredit :one
+UPDATE subscriptions
+SET credit = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
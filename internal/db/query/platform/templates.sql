@@ -0,0 +1,24 @@
+-- name: ListShopTemplates :many
+SELECT * FROM shop_templates
+ORDER BY position, key;
+
+-- name: ListActiveShopTemplates :many
+SELECT * FROM shop_templates
+WHERE is_active
+ORDER BY position, key;
+
+-- name: GetShopTemplate :one
+SELECT * FROM shop_templates
+WHERE key = $1 LIMIT 1;
+
+-- name: UpsertShopTemplate :one
+INSERT INTO shop_templates (key, name, description, shop_id, position, is_active)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (key) DO UPDATE
+SET name = EXCLUDED.name,
+    description = EXCLUDED.description,
+    shop_id = EXCLUDED.shop_id,
+    position = EXCLUDED.position,
+    is_active = EXCLUDED.is_active,
+    updated_at = NOW()
+RETURNING *;
@@ -0,0 +1,30 @@
+-- name: UpsertTenantMigration :one
+INSERT INTO tenant_migrations (
+    tenant_id,
+    version,
+    dirty,
+    last_error,
+    last_run_at,
+    updated_at
+) VALUES (
+    $1, $2, $3, $4, NOW(), NOW()
+)
+ON CONFLICT (tenant_id) DO UPDATE SET
+    version = EXCLUDED.version,
+    dirty = EXCLUDED.dirty,
+    last_error = EXCLUDED.last_error,
+    last_run_at = NOW(),
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetTenantMigration :one
+SELECT * FROM tenant_migrations
+WHERE tenant_id = $1 LIMIT 1;
+
+-- name: ListTenantMigrations :many
+SELECT * FROM tenant_migrations
+ORDER BY tenant_id;
+
+-- name: DeleteTenantMigration :exec
+DELETE FROM tenant_migrations
+WHERE tenant_id = $1;
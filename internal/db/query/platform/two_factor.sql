@@ -0,0 +1,42 @@
+-- name: GetUserTwoFactor :one
+SELECT
+    u.totp_secret,
+    u.totp_enabled_at,
+    u.totp_last_step,
+    (SELECT count(*) FROM platform_recovery_codes r WHERE r.user_id = u.id AND r.used_at IS NULL) AS recovery_codes_left
+FROM users u
+WHERE u.id = $1;
+
+-- name: SetTOTPSecret :exec
+-- Starts (or restarts) enrollment; two-factor stays off until EnableTOTP
+UPDATE users
+SET totp_secret = $2, totp_enabled_at = NULL, totp_last_step = 0, updated_at = now()
+WHERE id = $1;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled_at = now(), totp_last_step = $2, updated_at = now()
+WHERE id = $1 AND totp_secret IS NOT NULL;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = now()
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+-- Accepts a code's time step once: later codes only
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND totp_enabled_at IS NOT NULL AND totp_last_step < $2;
+
+-- name: ReplaceRecoveryCodes :exec
+WITH removed AS (
+    DELETE FROM platform_recovery_codes WHERE platform_recovery_codes.user_id = sqlc.arg(user_id)
+)
+INSERT INTO platform_recovery_codes (user_id, code_hash)
+SELECT sqlc.arg(user_id), unnest(sqlc.arg(code_hashes)::text[]);
+
+-- name: UseRecoveryCode :execrows
+UPDATE platform_recovery_codes
+SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
@@ -0,0 +1,21 @@
+-- name: CreateUserToken :one
+INSERT INTO platform_user_tokens (
+    user_id,
+    purpose,
+    token_hash,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ConsumeUserToken :one
+-- Marks the token used and returns it, once, while it has not expired
+UPDATE platform_user_tokens
+SET used_at = now()
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
+
+-- name: RevokeUserTokens :exec
+UPDATE platform_user_tokens
+SET used_at = now()
+WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
@@ -15,3 +15,13 @@ WHERE email = $1 LIMIT 1;
 -- name: GetUserById :one
 SELECT * FROM users
 WHERE id = $1 LIMIT 1;
+
+-- name: UpdatePassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = now()
+WHERE id = $1;
+
+-- name: MarkEmailVerified :exec
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, now()), updated_at = now()
+WHERE id = $1;
@@ -0,0 +1,36 @@
+-- name: GetUserByIdentity :one
+SELECT * FROM users
+WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2);
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (
+    user_id,
+    provider,
+    subject,
+    email
+) VALUES (
+    $1, $2, $3, $4
+);
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET email = $3, last_login_at = now()
+WHERE provider = $1 AND subject = $2;
+
+-- name: CreateSocialUser :one
+-- Passwordless customer signed up with an external identity. The address
+-- counts as verified when the provider verified it.
+INSERT INTO users (
+    email,
+    email_verified_at,
+    password_hash,
+    first_name,
+    last_name,
+    role
+) VALUES (
+    $1, $2, '', $3, $4, 'customer'
+) RETURNING *;
+
+-- name: DeleteUserIdentities :exec
+DELETE FROM user_identities
+WHERE user_id = $1;
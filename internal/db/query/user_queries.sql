@@ -16,7 +16,7 @@ WHERE id = $1 LIMIT 1;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users
-WHERE email = $1 LIMIT 1;
+WHERE email = sqlc.arg(email)::varchar LIMIT 1;
 
 -- name: GetUserByPhone :one
 SELECT * FROM users
@@ -42,3 +42,71 @@ WHERE id = $1;
 -- name: DeleteUser :exec
 DELETE FROM users
 WHERE id = $1;
+
+-- name: CountStaffUsers :one
+SELECT COUNT(*) FROM users
+WHERE role IN ('admin', 'staff');
+
+-- name: SetUserAccess :one
+UPDATE users
+SET role = $2, permissions = $3, updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkEmailVerified :exec
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, now()), updated_at = now()
+WHERE id = $1;
+
+-- name: GetUserByVerifiedPhone :one
+SELECT * FROM users
+WHERE phone = $1 AND phone_verified_at IS NOT NULL LIMIT 1;
+
+-- name: CreatePhoneUser :one
+-- Passwordless customer signed up with a phone code
+INSERT INTO users (
+    phone,
+    phone_verified_at,
+    password_hash,
+    first_name,
+    last_name,
+    role
+) VALUES (
+    $1, now(), '', $2, '', 'customer'
+) RETURNING *;
+
+-- name: SetVerifiedPhone :one
+UPDATE users
+SET phone = $2, phone_verified_at = now(), updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByPlatformID :one
+SELECT * FROM users
+WHERE platform_user_id = $1 LIMIT 1;
+
+-- name: CreatePlatformUser :one
+-- Staff account of a platform user, signed in through handoffs only
+INSERT INTO users (
+    email,
+    password_hash,
+    first_name,
+    last_name,
+    role,
+    platform_user_id
+) VALUES (
+    $1, '!', $2, $3, $4, $5
+) RETURNING *;
+
+-- name: LinkPlatformUser :one
+-- Links an account registered in the shop to the platform user with its
+-- email. Unless the email was verified, the account's password and phone
+-- are dropped: whoever registered it may not be the platform user.
+UPDATE users
+SET platform_user_id = $2,
+    password_hash = CASE WHEN email_verified_at IS NULL THEN '!' ELSE password_hash END,
+    phone = CASE WHEN email_verified_at IS NULL THEN NULL ELSE phone END,
+    phone_verified_at = CASE WHEN email_verified_at IS NULL THEN NULL ELSE phone_verified_at END,
+    updated_at = now()
+WHERE id = $1 AND platform_user_id IS NULL
+RETURNING *;
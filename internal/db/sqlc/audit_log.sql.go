@@ -0,0 +1,160 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: audit_log.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createAuditLog = `-- name: CreateAuditLog :one
+INSERT INTO audit_log (
+    actor_id,
+    actor_type,
+    action,
+    entity_type,
+    entity_id,
+    before,
+    after,
+    ip,
+    request_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING id, actor_id, actor_type, action, entity_type, entity_id, before, after, ip, request_id, created_at
+`
+
+type CreateAuditLogParams struct {
+	ActorID    pgtype.UUID `json:"actor_id"`
+	ActorType  string      `json:"actor_type"`
+	Action     string      `json:"action"`
+	EntityType string      `json:"entity_type"`
+	EntityID   string      `json:"entity_id"`
+	Before     []byte      `json:"before"`
+	After      []byte      `json:"after"`
+	Ip         *string     `json:"ip"`
+	RequestID  *string     `json:"request_id"`
+}
+
+func (q *Queries) CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) (AuditLog, error) {
+	row := q.db.QueryRow(ctx, createAuditLog,
+		arg.ActorID,
+		arg.ActorType,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.Before,
+		arg.After,
+		arg.Ip,
+		arg.RequestID,
+	)
+	var i AuditLog
+	err := row.Scan(
+		&i.ID,
+		&i.ActorID,
+		&i.ActorType,
+		&i.Action,
+		&i.EntityType,
+		&i.EntityID,
+		&i.Before,
+		&i.After,
+		&i.Ip,
+		&i.RequestID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAuditLog = `-- name: ListAuditLog :many
+SELECT a.id, a.actor_id, a.actor_type, a.action, a.entity_type, a.entity_id, a.before, a.after, a.ip, a.request_id, a.created_at, COALESCE(u.email, k.name, '')::text AS actor_name
+FROM audit_log a
+LEFT JOIN users u ON u.id = a.actor_id
+LEFT JOIN api_keys k ON k.id = a.actor_id
+WHERE ($1::text IS NULL
+        OR u.email = $1 OR k.name = $1 OR a.actor_id::text = $1)
+    AND ($2::text IS NULL OR a.action LIKE $2 || '%')
+    AND ($3::text IS NULL OR a.entity_type = $3)
+    AND ($4::text IS NULL OR a.entity_id = $4)
+    AND ($5::timestamptz IS NULL OR a.created_at >= $5)
+    AND ($6::timestamptz IS NULL OR a.created_at < $6)
+    AND ($7::timestamptz IS NULL
+        OR (a.created_at, a.id) < ($7::timestamptz, $8::uuid))
+ORDER BY a.created_at DESC, a.id DESC
+LIMIT $9
+`
+
+type ListAuditLogParams struct {
+	Actor      *string            `json:"actor"`
+	Action     *string            `json:"action"`
+	EntityType *string            `json:"entity_type"`
+	EntityID   *string            `json:"entity_id"`
+	Since      pgtype.Timestamptz `json:"since"`
+	Until      pgtype.Timestamptz `json:"until"`
+	BeforeAt   pgtype.Timestamptz `json:"before_at"`
+	BeforeID   pgtype.UUID        `json:"before_id"`
+	RowLimit   int32              `json:"row_limit"`
+}
+
+type ListAuditLogRow struct {
+	ID         pgtype.UUID        `json:"id"`
+	ActorID    pgtype.UUID        `json:"actor_id"`
+	ActorType  string             `json:"actor_type"`
+	Action     string             `json:"action"`
+	EntityType string             `json:"entity_type"`
+	EntityID   string             `json:"entity_id"`
+	Before     []byte             `json:"before"`
+	After      []byte             `json:"after"`
+	Ip         *string            `json:"ip"`
+	RequestID  *string            `json:"request_id"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	ActorName  string             `json:"actor_name"`
+}
+
+// Newest first. Filters are optional; action matches a prefix ("product."),
+// actor an email, an API key name or an actor ID. Pages continue before
+// the (created_at, id) of the last entry.
+func (q *Queries) ListAuditLog(ctx context.Context, arg ListAuditLogParams) ([]ListAuditLogRow, error) {
+	rows, err := q.db.Query(ctx, listAuditLog,
+		arg.Actor,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.Since,
+		arg.Until,
+		arg.BeforeAt,
+		arg.BeforeID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAuditLogRow{}
+	for rows.Next() {
+		var i ListAuditLogRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorID,
+			&i.ActorType,
+			&i.Action,
+			&i.EntityType,
+			&i.EntityID,
+			&i.Before,
+			&i.After,
+			&i.Ip,
+			&i.RequestID,
+			&i.CreatedAt,
+			&i.ActorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -198,6 +198,42 @@ func (q *Queries) GetCartItems(ctx context.Context, cartID pgtype.UUID) ([]GetCa
 	return items, nil
 }
 
+const moveCartItems = `-- name: MoveCartItems :exec
+INSERT INTO cart_items (cart_id, product_id, variant_id, quantity)
+SELECT $1, ci.product_id, ci.variant_id, ci.quantity
+FROM cart_items ci
+WHERE ci.cart_id = $2
+ON CONFLICT (cart_id, product_id, COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'::uuid))
+DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity
+`
+
+type MoveCartItemsParams struct {
+	ToCartID   pgtype.UUID `json:"to_cart_id"`
+	FromCartID pgtype.UUID `json:"from_cart_id"`
+}
+
+// Adds the items of one cart to another, summing quantities
+func (q *Queries) MoveCartItems(ctx context.Context, arg MoveCartItemsParams) error {
+	_, err := q.db.Exec(ctx, moveCartItems, arg.ToCartID, arg.FromCartID)
+	return err
+}
+
+const reassignUserCarts = `-- name: ReassignUserCarts :exec
+UPDATE carts
+SET user_id = $1, updated_at = now()
+WHERE user_id = $2
+`
+
+type ReassignUserCartsParams struct {
+	ToUserID   pgtype.UUID `json:"to_user_id"`
+	FromUserID pgtype.UUID `json:"from_user_id"`
+}
+
+func (q *Queries) ReassignUserCarts(ctx context.Context, arg ReassignUserCartsParams) error {
+	_, err := q.db.Exec(ctx, reassignUserCarts, arg.ToUserID, arg.FromUserID)
+	return err
+}
+
 const removeCartItem = `-- name: RemoveCartItem :exec
 DELETE FROM cart_items
 WHERE id = $1 AND cart_id = $2
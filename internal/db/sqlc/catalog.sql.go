@@ -11,6 +11,31 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const archiveVariant = `-- name: ArchiveVariant :execrows
+UPDATE product_variants
+SET archived_at = NOW()
+WHERE id = $1 AND archived_at IS NULL
+`
+
+func (q *Queries) ArchiveVariant(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, archiveVariant, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const countProducts = `-- name: CountProducts :one
+SELECT COUNT(*) FROM products
+`
+
+func (q *Queries) CountProducts(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, countProducts)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createCategory = `-- name: CreateCategory :one
 
 INSERT INTO categories (
@@ -108,6 +133,39 @@ func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (P
 	return i, err
 }
 
+const createProductOption = `-- name: CreateProductOption :one
+
+INSERT INTO product_options (
+    product_id,
+    name,
+    values,
+    position
+) VALUES (
+    $1, $2, $3,
+    (SELECT COALESCE(MAX(position) + 1, 0) FROM product_options WHERE product_id = $1)
+) RETURNING id, product_id, name, position, values
+`
+
+type CreateProductOptionParams struct {
+	ProductID pgtype.UUID `json:"product_id"`
+	Name      string      `json:"name"`
+	Values    []string    `json:"values"`
+}
+
+// Options
+func (q *Queries) CreateProductOption(ctx context.Context, arg CreateProductOptionParams) (ProductOption, error) {
+	row := q.db.QueryRow(ctx, createProductOption, arg.ProductID, arg.Name, arg.Values)
+	var i ProductOption
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.Name,
+		&i.Position,
+		&i.Values,
+	)
+	return i, err
+}
+
 const createProductVariant = `-- name: CreateProductVariant :one
 
 INSERT INTO product_variants (
@@ -118,10 +176,12 @@ INSERT INTO product_variants (
     compare_at_price,
     sku,
     stock_quantity,
-    is_active
+    is_active,
+    position
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
-) RETURNING id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active
+    $1, $2, $3, $4, $5, $6, $7, $8,
+    (SELECT COALESCE(MAX(position) + 1, 0) FROM product_variants WHERE product_id = $1)
+) RETURNING id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active, position, archived_at
 `
 
 type CreateProductVariantParams struct {
@@ -158,6 +218,8 @@ func (q *Queries) CreateProductVariant(ctx context.Context, arg CreateProductVar
 		&i.Sku,
 		&i.StockQuantity,
 		&i.IsActive,
+		&i.Position,
+		&i.ArchivedAt,
 	)
 	return i, err
 }
@@ -172,24 +234,43 @@ func (q *Queries) DeleteCategory(ctx context.Context, id pgtype.UUID) error {
 	return err
 }
 
-const deleteProduct = `-- name: DeleteProduct :exec
+const deleteProduct = `-- name: DeleteProduct :execrows
 DELETE FROM products
 WHERE id = $1
 `
 
-func (q *Queries) DeleteProduct(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deleteProduct, id)
-	return err
+func (q *Queries) DeleteProduct(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteProduct, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteProductOption = `-- name: DeleteProductOption :execrows
+DELETE FROM product_options
+WHERE id = $1
+`
+
+func (q *Queries) DeleteProductOption(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteProductOption, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-const deleteVariant = `-- name: DeleteVariant :exec
+const deleteVariant = `-- name: DeleteVariant :execrows
 DELETE FROM product_variants
 WHERE id = $1
 `
 
-func (q *Queries) DeleteVariant(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deleteVariant, id)
-	return err
+func (q *Queries) DeleteVariant(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteVariant, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const getCategory = `-- name: GetCategory :one
@@ -258,8 +339,26 @@ func (q *Queries) GetProductBySlug(ctx context.Context, slug string) (Product, e
 	return i, err
 }
 
+const getProductOption = `-- name: GetProductOption :one
+SELECT id, product_id, name, position, values FROM product_options
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetProductOption(ctx context.Context, id pgtype.UUID) (ProductOption, error) {
+	row := q.db.QueryRow(ctx, getProductOption, id)
+	var i ProductOption
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.Name,
+		&i.Position,
+		&i.Values,
+	)
+	return i, err
+}
+
 const getProductVariant = `-- name: GetProductVariant :one
-SELECT id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active FROM product_variants
+SELECT id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active, position, archived_at FROM product_variants
 WHERE id = $1 LIMIT 1
 `
 
@@ -276,10 +375,50 @@ func (q *Queries) GetProductVariant(ctx context.Context, id pgtype.UUID) (Produc
 		&i.Sku,
 		&i.StockQuantity,
 		&i.IsActive,
+		&i.Position,
+		&i.ArchivedAt,
 	)
 	return i, err
 }
 
+const listArchivedVariantsByProduct = `-- name: ListArchivedVariantsByProduct :many
+SELECT id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active, position, archived_at FROM product_variants
+WHERE product_id = $1 AND archived_at IS NOT NULL
+ORDER BY archived_at DESC
+`
+
+func (q *Queries) ListArchivedVariantsByProduct(ctx context.Context, productID pgtype.UUID) ([]ProductVariant, error) {
+	rows, err := q.db.Query(ctx, listArchivedVariantsByProduct, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProductVariant{}
+	for rows.Next() {
+		var i ProductVariant
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.Title,
+			&i.Options,
+			&i.Price,
+			&i.CompareAtPrice,
+			&i.Sku,
+			&i.StockQuantity,
+			&i.IsActive,
+			&i.Position,
+			&i.ArchivedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listCategories = `-- name: ListCategories :many
 SELECT id, name, slug, parent_id, is_active FROM categories
 ORDER BY name ASC
@@ -389,6 +528,38 @@ func (q *Queries) ListNewArrivals(ctx context.Context, limit int32) ([]Product,
 	return items, nil
 }
 
+const listProductOptions = `-- name: ListProductOptions :many
+SELECT id, product_id, name, position, values FROM product_options
+WHERE product_id = $1
+ORDER BY position, name
+`
+
+func (q *Queries) ListProductOptions(ctx context.Context, productID pgtype.UUID) ([]ProductOption, error) {
+	rows, err := q.db.Query(ctx, listProductOptions, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProductOption{}
+	for rows.Next() {
+		var i ProductOption
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.Name,
+			&i.Position,
+			&i.Values,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listProducts = `-- name: ListProducts :many
 SELECT id, title, slug, description, base_price, is_digital, file_path, is_featured, category_id, is_active, created_at FROM products
 ORDER BY created_at DESC
@@ -427,8 +598,9 @@ func (q *Queries) ListProducts(ctx context.Context) ([]Product, error) {
 }
 
 const listVariantsByProduct = `-- name: ListVariantsByProduct :many
-SELECT id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active FROM product_variants
-WHERE product_id = $1
+SELECT id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active, position, archived_at FROM product_variants
+WHERE product_id = $1 AND archived_at IS NULL
+ORDER BY position, title
 `
 
 func (q *Queries) ListVariantsByProduct(ctx context.Context, productID pgtype.UUID) ([]ProductVariant, error) {
@@ -450,6 +622,8 @@ func (q *Queries) ListVariantsByProduct(ctx context.Context, productID pgtype.UU
 			&i.Sku,
 			&i.StockQuantity,
 			&i.IsActive,
+			&i.Position,
+			&i.ArchivedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -461,6 +635,121 @@ func (q *Queries) ListVariantsByProduct(ctx context.Context, productID pgtype.UU
 	return items, nil
 }
 
+const releaseVariantSKU = `-- name: ReleaseVariantSKU :exec
+UPDATE product_variants
+SET sku = NULL
+WHERE id = $1 AND archived_at IS NOT NULL
+`
+
+// Frees an archived variant's SKU for the variant taking its place
+func (q *Queries) ReleaseVariantSKU(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, releaseVariantSKU, id)
+	return err
+}
+
+const renameVariantOption = `-- name: RenameVariantOption :exec
+UPDATE product_variants
+SET options = (options - $2::text) || jsonb_build_object($3::text, options -> $2::text)
+WHERE product_id = $1 AND options ? $2::text
+`
+
+type RenameVariantOptionParams struct {
+	ProductID pgtype.UUID `json:"product_id"`
+	OldName   string      `json:"old_name"`
+	NewName   string      `json:"new_name"`
+}
+
+func (q *Queries) RenameVariantOption(ctx context.Context, arg RenameVariantOptionParams) error {
+	_, err := q.db.Exec(ctx, renameVariantOption, arg.ProductID, arg.OldName, arg.NewName)
+	return err
+}
+
+const renameVariantOptionValue = `-- name: RenameVariantOptionValue :exec
+UPDATE product_variants
+SET options = jsonb_set(options, ARRAY[$2::text], to_jsonb($3::text))
+WHERE product_id = $1 AND options ->> $2::text = $4::text
+`
+
+type RenameVariantOptionValueParams struct {
+	ProductID pgtype.UUID `json:"product_id"`
+	Name      string      `json:"name"`
+	NewValue  string      `json:"new_value"`
+	OldValue  string      `json:"old_value"`
+}
+
+func (q *Queries) RenameVariantOptionValue(ctx context.Context, arg RenameVariantOptionValueParams) error {
+	_, err := q.db.Exec(ctx, renameVariantOptionValue,
+		arg.ProductID,
+		arg.Name,
+		arg.NewValue,
+		arg.OldValue,
+	)
+	return err
+}
+
+const setVariantOptions = `-- name: SetVariantOptions :one
+UPDATE product_variants
+SET
+    title = $2,
+    options = $3,
+    position = $4,
+    archived_at = NULL
+WHERE id = $1
+RETURNING id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active, position, archived_at
+`
+
+type SetVariantOptionsParams struct {
+	ID       pgtype.UUID `json:"id"`
+	Title    string      `json:"title"`
+	Options  []byte      `json:"options"`
+	Position int32       `json:"position"`
+}
+
+// Places a variant in the product's matrix, restoring it if archived
+func (q *Queries) SetVariantOptions(ctx context.Context, arg SetVariantOptionsParams) (ProductVariant, error) {
+	row := q.db.QueryRow(ctx, setVariantOptions,
+		arg.ID,
+		arg.Title,
+		arg.Options,
+		arg.Position,
+	)
+	var i ProductVariant
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.Title,
+		&i.Options,
+		&i.Price,
+		&i.CompareAtPrice,
+		&i.Sku,
+		&i.StockQuantity,
+		&i.IsActive,
+		&i.Position,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
+const setVariantPosition = `-- name: SetVariantPosition :execrows
+UPDATE product_variants
+SET position = $3
+WHERE id = $1 AND product_id = $2
+`
+
+type SetVariantPositionParams struct {
+	ID        pgtype.UUID `json:"id"`
+	ProductID pgtype.UUID `json:"product_id"`
+	Position  int32       `json:"position"`
+}
+
+func (q *Queries) SetVariantPosition(ctx context.Context, arg SetVariantPositionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setVariantPosition, arg.ID, arg.ProductID, arg.Position)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateCategory = `-- name: UpdateCategory :one
 UPDATE categories
 SET 
@@ -509,7 +798,8 @@ SET
     is_digital = COALESCE($6, is_digital),
     file_path = COALESCE($7, file_path),
     category_id = COALESCE($8, category_id),
-    is_active = COALESCE($9, is_active)
+    is_active = COALESCE($9, is_active),
+    is_featured = COALESCE($10, is_featured)
 WHERE id = $1
 RETURNING id, title, slug, description, base_price, is_digital, file_path, is_featured, category_id, is_active, created_at
 `
@@ -524,6 +814,7 @@ type UpdateProductParams struct {
 	FilePath    *string        `json:"file_path"`
 	CategoryID  pgtype.UUID    `json:"category_id"`
 	IsActive    *bool          `json:"is_active"`
+	IsFeatured  *bool          `json:"is_featured"`
 }
 
 func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (Product, error) {
@@ -537,6 +828,7 @@ func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (P
 		arg.FilePath,
 		arg.CategoryID,
 		arg.IsActive,
+		arg.IsFeatured,
 	)
 	var i Product
 	err := row.Scan(
@@ -554,3 +846,92 @@ func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (P
 	)
 	return i, err
 }
+
+const updateProductOption = `-- name: UpdateProductOption :one
+UPDATE product_options
+SET
+    name = COALESCE($2, name),
+    values = COALESCE($3, values),
+    position = COALESCE($4, position)
+WHERE id = $1
+RETURNING id, product_id, name, position, values
+`
+
+type UpdateProductOptionParams struct {
+	ID       pgtype.UUID `json:"id"`
+	Name     *string     `json:"name"`
+	Values   []string    `json:"values"`
+	Position *int32      `json:"position"`
+}
+
+func (q *Queries) UpdateProductOption(ctx context.Context, arg UpdateProductOptionParams) (ProductOption, error) {
+	row := q.db.QueryRow(ctx, updateProductOption,
+		arg.ID,
+		arg.Name,
+		arg.Values,
+		arg.Position,
+	)
+	var i ProductOption
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.Name,
+		&i.Position,
+		&i.Values,
+	)
+	return i, err
+}
+
+const updateProductVariant = `-- name: UpdateProductVariant :one
+UPDATE product_variants
+SET
+    title = COALESCE($2, title),
+    options = COALESCE($3, options),
+    price = COALESCE($4, price),
+    compare_at_price = $5,
+    sku = COALESCE($6, sku),
+    stock_quantity = COALESCE($7, stock_quantity),
+    is_active = COALESCE($8, is_active)
+WHERE id = $1
+RETURNING id, product_id, title, options, price, compare_at_price, sku, stock_quantity, is_active, position, archived_at
+`
+
+type UpdateProductVariantParams struct {
+	ID             pgtype.UUID    `json:"id"`
+	Title          *string        `json:"title"`
+	Options        []byte         `json:"options"`
+	Price          pgtype.Numeric `json:"price"`
+	CompareAtPrice pgtype.Numeric `json:"compare_at_price"`
+	Sku            *string        `json:"sku"`
+	StockQuantity  *int32         `json:"stock_quantity"`
+	IsActive       *bool          `json:"is_active"`
+}
+
+// compare_at_price is replaced as given: NULL removes it
+func (q *Queries) UpdateProductVariant(ctx context.Context, arg UpdateProductVariantParams) (ProductVariant, error) {
+	row := q.db.QueryRow(ctx, updateProductVariant,
+		arg.ID,
+		arg.Title,
+		arg.Options,
+		arg.Price,
+		arg.CompareAtPrice,
+		arg.Sku,
+		arg.StockQuantity,
+		arg.IsActive,
+	)
+	var i ProductVariant
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.Title,
+		&i.Options,
+		&i.Price,
+		&i.CompareAtPrice,
+		&i.Sku,
+		&i.StockQuantity,
+		&i.IsActive,
+		&i.Position,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
@@ -118,6 +118,33 @@ type AnalyticsEvent struct {
 	CreatedAt     pgtype.Timestamptz `json:"created_at"`
 }
 
+type ApiKey struct {
+	ID         pgtype.UUID        `json:"id"`
+	Name       string             `json:"name"`
+	Prefix     string             `json:"prefix"`
+	KeyHash    string             `json:"key_hash"`
+	Scopes     []string           `json:"scopes"`
+	CreatedBy  pgtype.UUID        `json:"created_by"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	RevokedAt  pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type AuditLog struct {
+	ID         pgtype.UUID        `json:"id"`
+	ActorID    pgtype.UUID        `json:"actor_id"`
+	ActorType  string             `json:"actor_type"`
+	Action     string             `json:"action"`
+	EntityType string             `json:"entity_type"`
+	EntityID   string             `json:"entity_id"`
+	Before     []byte             `json:"before"`
+	After      []byte             `json:"after"`
+	Ip         *string            `json:"ip"`
+	RequestID  *string            `json:"request_id"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
 type Cart struct {
 	ID        pgtype.UUID        `json:"id"`
 	SessionID pgtype.UUID        `json:"session_id"`
@@ -216,15 +243,25 @@ type ProductOption struct {
 }
 
 type ProductVariant struct {
-	ID             pgtype.UUID    `json:"id"`
-	ProductID      pgtype.UUID    `json:"product_id"`
-	Title          string         `json:"title"`
-	Options        []byte         `json:"options"`
-	Price          pgtype.Numeric `json:"price"`
-	CompareAtPrice pgtype.Numeric `json:"compare_at_price"`
-	Sku            *string        `json:"sku"`
-	StockQuantity  *int32         `json:"stock_quantity"`
-	IsActive       *bool          `json:"is_active"`
+	ID             pgtype.UUID        `json:"id"`
+	ProductID      pgtype.UUID        `json:"product_id"`
+	Title          string             `json:"title"`
+	Options        []byte             `json:"options"`
+	Price          pgtype.Numeric     `json:"price"`
+	CompareAtPrice pgtype.Numeric     `json:"compare_at_price"`
+	Sku            *string            `json:"sku"`
+	StockQuantity  *int32             `json:"stock_quantity"`
+	IsActive       *bool              `json:"is_active"`
+	Position       int32              `json:"position"`
+	ArchivedAt     pgtype.Timestamptz `json:"archived_at"`
+}
+
+type RecoveryCode struct {
+	ID        pgtype.UUID        `json:"id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
 type Session struct {
@@ -244,14 +281,40 @@ type StoreConfig struct {
 }
 
 type User struct {
-	ID           pgtype.UUID        `json:"id"`
-	Email        string             `json:"email"`
-	PasswordHash string             `json:"password_hash"`
-	FirstName    string             `json:"first_name"`
-	LastName     string             `json:"last_name"`
-	Role         UserRole           `json:"role"`
-	Permissions  []byte             `json:"permissions"`
-	Phone        *string            `json:"phone"`
-	CreatedAt    pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+	ID              pgtype.UUID        `json:"id"`
+	Email           *string            `json:"email"`
+	PasswordHash    string             `json:"password_hash"`
+	FirstName       string             `json:"first_name"`
+	LastName        string             `json:"last_name"`
+	Role            UserRole           `json:"role"`
+	Permissions     []byte             `json:"permissions"`
+	Phone           *string            `json:"phone"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+	PhoneVerifiedAt pgtype.Timestamptz `json:"phone_verified_at"`
+	TotpSecret      *string            `json:"totp_secret"`
+	TotpEnabledAt   pgtype.Timestamptz `json:"totp_enabled_at"`
+	TotpLastStep    int64              `json:"totp_last_step"`
+	PlatformUserID  pgtype.UUID        `json:"platform_user_id"`
+}
+
+type UserIdentity struct {
+	ID          pgtype.UUID        `json:"id"`
+	UserID      pgtype.UUID        `json:"user_id"`
+	Provider    string             `json:"provider"`
+	Subject     string             `json:"subject"`
+	Email       *string            `json:"email"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	LastLoginAt pgtype.Timestamptz `json:"last_login_at"`
+}
+
+type UserToken struct {
+	ID        pgtype.UUID        `json:"id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	Purpose   string             `json:"purpose"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
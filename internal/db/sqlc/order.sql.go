@@ -11,6 +11,18 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countOrdersSince = `-- name: CountOrdersSince :one
+SELECT COUNT(*) FROM orders
+WHERE created_at >= $1
+`
+
+func (q *Queries) CountOrdersSince(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error) {
+	row := q.db.QueryRow(ctx, countOrdersSince, createdAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createOrder = `-- name: CreateOrder :one
 INSERT INTO orders (
     user_id,
@@ -191,6 +203,22 @@ func (q *Queries) ListOrdersByUser(ctx context.Context, userID pgtype.UUID) ([]O
 	return items, nil
 }
 
+const reassignUserOrders = `-- name: ReassignUserOrders :exec
+UPDATE orders
+SET user_id = $1
+WHERE user_id = $2
+`
+
+type ReassignUserOrdersParams struct {
+	ToUserID   pgtype.UUID `json:"to_user_id"`
+	FromUserID pgtype.UUID `json:"from_user_id"`
+}
+
+func (q *Queries) ReassignUserOrders(ctx context.Context, arg ReassignUserOrdersParams) error {
+	_, err := q.db.Exec(ctx, reassignUserOrders, arg.ToUserID, arg.FromUserID)
+	return err
+}
+
 const updateOrderStatus = `-- name: UpdateOrderStatus :one
 UPDATE orders
 SET status = $2, payment_status = $3, updated_at = NOW()
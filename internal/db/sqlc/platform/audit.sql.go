@@ -0,0 +1,209 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: audit.sql
+
+package platform
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createPlatformAuditLog = `-- name: CreatePlatformAuditLog :one
+INSERT INTO platform_audit_log (
+    actor_id,
+    actor_type,
+    action,
+    entity_type,
+    entity_id,
+    metadata,
+    ip,
+    before,
+    after,
+    request_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING id, actor_id, actor_type, action, entity_type, entity_id, metadata, ip, created_at, before, after, request_id
+`
+
+type CreatePlatformAuditLogParams struct {
+	ActorID    pgtype.UUID `json:"actor_id"`
+	ActorType  string      `json:"actor_type"`
+	Action     string      `json:"action"`
+	EntityType string      `json:"entity_type"`
+	EntityID   string      `json:"entity_id"`
+	Metadata   []byte      `json:"metadata"`
+	Ip         *string     `json:"ip"`
+	Before     []byte      `json:"before"`
+	After      []byte      `json:"after"`
+	RequestID  *string     `json:"request_id"`
+}
+
+func (q *Queries) CreatePlatformAuditLog(ctx context.Context, arg CreatePlatformAuditLogParams) (PlatformAuditLog, error) {
+	row := q.db.QueryRow(ctx, createPlatformAuditLog,
+		arg.ActorID,
+		arg.ActorType,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.Metadata,
+		arg.Ip,
+		arg.Before,
+		arg.After,
+		arg.RequestID,
+	)
+	var i PlatformAuditLog
+	err := row.Scan(
+		&i.ID,
+		&i.ActorID,
+		&i.ActorType,
+		&i.Action,
+		&i.EntityType,
+		&i.EntityID,
+		&i.Metadata,
+		&i.Ip,
+		&i.CreatedAt,
+		&i.Before,
+		&i.After,
+		&i.RequestID,
+	)
+	return i, err
+}
+
+const listPlatformAuditLog = `-- name: ListPlatformAuditLog :many
+SELECT a.id, a.actor_id, a.actor_type, a.action, a.entity_type, a.entity_id, a.metadata, a.ip, a.created_at, a.before, a.after, a.request_id, COALESCE(u.email, '')::text AS actor_name
+FROM platform_audit_log a
+LEFT JOIN users u ON u.id = a.actor_id
+WHERE ($1::text IS NULL OR u.email = $1 OR a.actor_id::text = $1)
+    AND ($2::text IS NULL OR a.action LIKE $2 || '%')
+    AND ($3::text IS NULL OR a.entity_type = $3)
+    AND ($4::text IS NULL OR a.entity_id = $4)
+    AND ($5::timestamptz IS NULL OR a.created_at >= $5)
+    AND ($6::timestamptz IS NULL OR a.created_at < $6)
+    AND ($7::timestamptz IS NULL
+        OR (a.created_at, a.id) < ($7::timestamptz, $8::uuid))
+ORDER BY a.created_at DESC, a.id DESC
+LIMIT $9
+`
+
+type ListPlatformAuditLogParams struct {
+	Actor      *string            `json:"actor"`
+	Action     *string            `json:"action"`
+	EntityType *string            `json:"entity_type"`
+	EntityID   *string            `json:"entity_id"`
+	Since      pgtype.Timestamptz `json:"since"`
+	Until      pgtype.Timestamptz `json:"until"`
+	BeforeAt   pgtype.Timestamptz `json:"before_at"`
+	BeforeID   pgtype.UUID        `json:"before_id"`
+	RowLimit   int32              `json:"row_limit"`
+}
+
+type ListPlatformAuditLogRow struct {
+	ID         pgtype.UUID        `json:"id"`
+	ActorID    pgtype.UUID        `json:"actor_id"`
+	ActorType  string             `json:"actor_type"`
+	Action     string             `json:"action"`
+	EntityType string             `json:"entity_type"`
+	EntityID   string             `json:"entity_id"`
+	Metadata   []byte             `json:"metadata"`
+	Ip         *string            `json:"ip"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	Before     []byte             `json:"before"`
+	After      []byte             `json:"after"`
+	RequestID  *string            `json:"request_id"`
+	ActorName  string             `json:"actor_name"`
+}
+
+// Newest first, filtered like the shops' ListAuditLog: action matches a
+// prefix, actor an email or an actor ID
+func (q *Queries) ListPlatformAuditLog(ctx context.Context, arg ListPlatformAuditLogParams) ([]ListPlatformAuditLogRow, error) {
+	rows, err := q.db.Query(ctx, listPlatformAuditLog,
+		arg.Actor,
+		arg.Action,
+		arg.EntityType,
+		arg.EntityID,
+		arg.Since,
+		arg.Until,
+		arg.BeforeAt,
+		arg.BeforeID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListPlatformAuditLogRow{}
+	for rows.Next() {
+		var i ListPlatformAuditLogRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorID,
+			&i.ActorType,
+			&i.Action,
+			&i.EntityType,
+			&i.EntityID,
+			&i.Metadata,
+			&i.Ip,
+			&i.CreatedAt,
+			&i.Before,
+			&i.After,
+			&i.RequestID,
+			&i.ActorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPlatformAuditLogByEntity = `-- name: ListPlatformAuditLogByEntity :many
+SELECT id, actor_id, actor_type, action, entity_type, entity_id, metadata, ip, created_at, before, after, request_id FROM platform_audit_log
+WHERE entity_type = $1 AND entity_id = $2
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type ListPlatformAuditLogByEntityParams struct {
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	Limit      int32  `json:"limit"`
+}
+
+func (q *Queries) ListPlatformAuditLogByEntity(ctx context.Context, arg ListPlatformAuditLogByEntityParams) ([]PlatformAuditLog, error) {
+	rows, err := q.db.Query(ctx, listPlatformAuditLogByEntity, arg.EntityType, arg.EntityID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []PlatformAuditLog{}
+	for rows.Next() {
+		var i PlatformAuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorID,
+			&i.ActorType,
+			&i.Action,
+			&i.EntityType,
+			&i.EntityID,
+			&i.Metadata,
+			&i.Ip,
+			&i.CreatedAt,
+			&i.Before,
+			&i.After,
+			&i.RequestID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,108 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: clusters.sql
+
+package platform
+
+import (
+	"context"
+)
+
+const listClustersForPlacement = `-- name: ListClustersForPlacement :many
+SELECT c.name
+FROM db_clusters c
+LEFT JOIN shops s ON s.db_cluster = c.name AND s.purged_at IS NULL
+WHERE c.accepts_new_shops
+GROUP BY c.name
+HAVING c.max_shops IS NULL OR COUNT(s.id) < c.max_shops
+ORDER BY COUNT(s.id), c.name
+`
+
+// Clusters that can take a new shop, least loaded first
+func (q *Queries) ListClustersForPlacement(ctx context.Context) ([]string, error) {
+	rows, err := q.db.Query(ctx, listClustersForPlacement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDBClusters = `-- name: ListDBClusters :many
+SELECT c.name, c.accepts_new_shops, c.max_shops, COUNT(s.id)::bigint AS shop_count
+FROM db_clusters c
+LEFT JOIN shops s ON s.db_cluster = c.name AND s.purged_at IS NULL
+GROUP BY c.name
+ORDER BY c.name
+`
+
+type ListDBClustersRow struct {
+	Name            string `json:"name"`
+	AcceptsNewShops bool   `json:"accepts_new_shops"`
+	MaxShops        *int32 `json:"max_shops"`
+	ShopCount       int64  `json:"shop_count"`
+}
+
+// Clusters with their live shop count
+func (q *Queries) ListDBClusters(ctx context.Context) ([]ListDBClustersRow, error) {
+	rows, err := q.db.Query(ctx, listDBClusters)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListDBClustersRow{}
+	for rows.Next() {
+		var i ListDBClustersRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.AcceptsNewShops,
+			&i.MaxShops,
+			&i.ShopCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertDBCluster = `-- name: UpsertDBCluster :one
+INSERT INTO db_clusters (name, accepts_new_shops, max_shops)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE
+SET accepts_new_shops = EXCLUDED.accepts_new_shops, max_shops = EXCLUDED.max_shops
+RETURNING name, accepts_new_shops, max_shops, created_at
+`
+
+type UpsertDBClusterParams struct {
+	Name            string `json:"name"`
+	AcceptsNewShops bool   `json:"accepts_new_shops"`
+	MaxShops        *int32 `json:"max_shops"`
+}
+
+func (q *Queries) UpsertDBCluster(ctx context.Context, arg UpsertDBClusterParams) (DbCluster, error) {
+	row := q.db.QueryRow(ctx, upsertDBCluster, arg.Name, arg.AcceptsNewShops, arg.MaxShops)
+	var i DbCluster
+	err := row.Scan(
+		&i.Name,
+		&i.AcceptsNewShops,
+		&i.MaxShops,
+		&i.CreatedAt,
+	)
+	return i, err
+}
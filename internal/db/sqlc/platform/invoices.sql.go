@@ -0,0 +1,360 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: invoices.sql
+
+package platform
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countOpenInvoices = `-- name: CountOpenInvoices :one
+SELECT COUNT(*) FROM invoices
+WHERE subscription_id = $1 AND kind = $2 AND status = 'open'
+`
+
+type CountOpenInvoicesParams struct {
+	SubscriptionID pgtype.UUID `json:"subscription_id"`
+	Kind           string      `json:"kind"`
+}
+
+func (q *Queries) CountOpenInvoices(ctx context.Context, arg CountOpenInvoicesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countOpenInvoices, arg.SubscriptionID, arg.Kind)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countOverdueInvoicesByShop = `-- name: CountOverdueInvoicesByShop :one
+SELECT COUNT(*) FROM invoices
+WHERE shop_id = $1 AND status = 'open' AND due_at < $2
+`
+
+type CountOverdueInvoicesByShopParams struct {
+	ShopID pgtype.UUID        `json:"shop_id"`
+	DueAt  pgtype.Timestamptz `json:"due_at"`
+}
+
+func (q *Queries) CountOverdueInvoicesByShop(ctx context.Context, arg CountOverdueInvoicesByShopParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countOverdueInvoicesByShop, arg.ShopID, arg.DueAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createInvoice = `-- name: CreateInvoice :one
+INSERT INTO invoices (
+    shop_id,
+    subscription_id,
+    plan_name,
+    kind,
+    amount,
+    credit_applied,
+    period_start,
+    period_end,
+    status,
+    due_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING id, shop_id, subscription_id, plan_name, kind, amount, credit_applied, period_start, period_end, status, due_at, payment_url, payment_ref, reminders_sent, last_reminder_at, paid_at, created_at
+`
+
+type CreateInvoiceParams struct {
+	ShopID         pgtype.UUID        `json:"shop_id"`
+	SubscriptionID pgtype.UUID        `json:"subscription_id"`
+	PlanName       string             `json:"plan_name"`
+	Kind           string             `json:"kind"`
+	Amount         pgtype.Numeric     `json:"amount"`
+	CreditApplied  pgtype.Numeric     `json:"credit_applied"`
+	PeriodStart    pgtype.Timestamptz `json:"period_start"`
+	PeriodEnd      pgtype.Timestamptz `json:"period_end"`
+	Status         string             `json:"status"`
+	DueAt          pgtype.Timestamptz `json:"due_at"`
+}
+
+func (q *Queries) CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (Invoice, error) {
+	row := q.db.QueryRow(ctx, createInvoice,
+		arg.ShopID,
+		arg.SubscriptionID,
+		arg.PlanName,
+		arg.Kind,
+		arg.Amount,
+		arg.CreditApplied,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+		arg.Status,
+		arg.DueAt,
+	)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.SubscriptionID,
+		&i.PlanName,
+		&i.Kind,
+		&i.Amount,
+		&i.CreditApplied,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Status,
+		&i.DueAt,
+		&i.PaymentUrl,
+		&i.PaymentRef,
+		&i.RemindersSent,
+		&i.LastReminderAt,
+		&i.PaidAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getInvoice = `-- name: GetInvoice :one
+SELECT id, shop_id, subscription_id, plan_name, kind, amount, credit_applied, period_start, period_end, status, due_at, payment_url, payment_ref, reminders_sent, last_reminder_at, paid_at, created_at FROM invoices
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetInvoice(ctx context.Context, id pgtype.UUID) (Invoice, error) {
+	row := q.db.QueryRow(ctx, getInvoice, id)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.SubscriptionID,
+		&i.PlanName,
+		&i.Kind,
+		&i.Amount,
+		&i.CreditApplied,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Status,
+		&i.DueAt,
+		&i.PaymentUrl,
+		&i.PaymentRef,
+		&i.RemindersSent,
+		&i.LastReminderAt,
+		&i.PaidAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listInvoicesByShop = `-- name: ListInvoicesByShop :many
+SELECT id, shop_id, subscription_id, plan_name, kind, amount, credit_applied, period_start, period_end, status, due_at, payment_url, payment_ref, reminders_sent, last_reminder_at, paid_at, created_at FROM invoices
+WHERE shop_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListInvoicesByShopParams struct {
+	ShopID pgtype.UUID `json:"shop_id"`
+	Limit  int32       `json:"limit"`
+}
+
+func (q *Queries) ListInvoicesByShop(ctx context.Context, arg ListInvoicesByShopParams) ([]Invoice, error) {
+	rows, err := q.db.Query(ctx, listInvoicesByShop, arg.ShopID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Invoice{}
+	for rows.Next() {
+		var i Invoice
+		if err := rows.Scan(
+			&i.ID,
+			&i.ShopID,
+			&i.SubscriptionID,
+			&i.PlanName,
+			&i.Kind,
+			&i.Amount,
+			&i.CreditApplied,
+			&i.PeriodStart,
+			&i.PeriodEnd,
+			&i.Status,
+			&i.DueAt,
+			&i.PaymentUrl,
+			&i.PaymentRef,
+			&i.RemindersSent,
+			&i.LastReminderAt,
+			&i.PaidAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOverdueInvoices = `-- name: ListOverdueInvoices :many
+SELECT id, shop_id, subscription_id, plan_name, kind, amount, credit_applied, period_start, period_end, status, due_at, payment_url, payment_ref, reminders_sent, last_reminder_at, paid_at, created_at FROM invoices
+WHERE status = 'open' AND due_at < $1
+ORDER BY due_at
+`
+
+func (q *Queries) ListOverdueInvoices(ctx context.Context, dueAt pgtype.Timestamptz) ([]Invoice, error) {
+	rows, err := q.db.Query(ctx, listOverdueInvoices, dueAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Invoice{}
+	for rows.Next() {
+		var i Invoice
+		if err := rows.Scan(
+			&i.ID,
+			&i.ShopID,
+			&i.SubscriptionID,
+			&i.PlanName,
+			&i.Kind,
+			&i.Amount,
+			&i.CreditApplied,
+			&i.PeriodStart,
+			&i.PeriodEnd,
+			&i.Status,
+			&i.DueAt,
+			&i.PaymentUrl,
+			&i.PaymentRef,
+			&i.RemindersSent,
+			&i.LastReminderAt,
+			&i.PaidAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markInvoicePaid = `-- name: MarkInvoicePaid :one
+UPDATE invoices
+SET status = 'paid', payment_ref = $2, paid_at = NOW()
+WHERE id = $1 AND status = 'open'
+RETURNING id, shop_id, subscription_id, plan_name, kind, amount, credit_applied, period_start, period_end, status, due_at, payment_url, payment_ref, reminders_sent, last_reminder_at, paid_at, created_at
+`
+
+type MarkInvoicePaidParams struct {
+	ID         pgtype.UUID `json:"id"`
+	PaymentRef *string     `json:"payment_ref"`
+}
+
+func (q *Queries) MarkInvoicePaid(ctx context.Context, arg MarkInvoicePaidParams) (Invoice, error) {
+	row := q.db.QueryRow(ctx, markInvoicePaid, arg.ID, arg.PaymentRef)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.SubscriptionID,
+		&i.PlanName,
+		&i.Kind,
+		&i.Amount,
+		&i.CreditApplied,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Status,
+		&i.DueAt,
+		&i.PaymentUrl,
+		&i.PaymentRef,
+		&i.RemindersSent,
+		&i.LastReminderAt,
+		&i.PaidAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const recordInvoiceReminder = `-- name: RecordInvoiceReminder :one
+UPDATE invoices
+SET reminders_sent = reminders_sent + 1, last_reminder_at = NOW()
+WHERE id = $1
+RETURNING id, shop_id, subscription_id, plan_name, kind, amount, credit_applied, period_start, period_end, status, due_at, payment_url, payment_ref, reminders_sent, last_reminder_at, paid_at, created_at
+`
+
+func (q *Queries) RecordInvoiceReminder(ctx context.Context, id pgtype.UUID) (Invoice, error) {
+	row := q.db.QueryRow(ctx, recordInvoiceReminder, id)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.SubscriptionID,
+		&i.PlanName,
+		&i.Kind,
+		&i.Amount,
+		&i.CreditApplied,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Status,
+		&i.DueAt,
+		&i.PaymentUrl,
+		&i.PaymentRef,
+		&i.RemindersSent,
+		&i.LastReminderAt,
+		&i.PaidAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const setInvoicePaymentURL = `-- name: SetInvoicePaymentURL :one
+UPDATE invoices
+SET payment_url = $2
+WHERE id = $1
+RETURNING id, shop_id, subscription_id, plan_name, kind, amount, credit_applied, period_start, period_end, status, due_at, payment_url, payment_ref, reminders_sent, last_reminder_at, paid_at, created_at
+`
+
+type SetInvoicePaymentURLParams struct {
+	ID         pgtype.UUID `json:"id"`
+	PaymentUrl *string     `json:"payment_url"`
+}
+
+func (q *Queries) SetInvoicePaymentURL(ctx context.Context, arg SetInvoicePaymentURLParams) (Invoice, error) {
+	row := q.db.QueryRow(ctx, setInvoicePaymentURL, arg.ID, arg.PaymentUrl)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.SubscriptionID,
+		&i.PlanName,
+		&i.Kind,
+		&i.Amount,
+		&i.CreditApplied,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Status,
+		&i.DueAt,
+		&i.PaymentUrl,
+		&i.PaymentRef,
+		&i.RemindersSent,
+		&i.LastReminderAt,
+		&i.PaidAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const voidUpcomingInvoices = `-- name: VoidUpcomingInvoices :exec
+UPDATE invoices
+SET status = 'void'
+WHERE subscription_id = $1 AND kind = $2 AND status = 'open' AND due_at >= $3
+`
+
+type VoidUpcomingInvoicesParams struct {
+	SubscriptionID pgtype.UUID        `json:"subscription_id"`
+	Kind           string             `json:"kind"`
+	DueAt          pgtype.Timestamptz `json:"due_at"`
+}
+
+// Voids open invoices not yet due; overdue ones stay owed
+func (q *Queries) VoidUpcomingInvoices(ctx context.Context, arg VoidUpcomingInvoicesParams) error {
+	_, err := q.db.Exec(ctx, voidUpcomingInvoices, arg.SubscriptionID, arg.Kind, arg.DueAt)
+	return err
+}
@@ -0,0 +1,392 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: members.sql
+
+package platform
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const acceptShopInvitation = `-- name: AcceptShopInvitation :one
+UPDATE shop_invitations
+SET accepted_at = NOW(), accepted_by = $2
+WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING id, shop_id, email, role, invited_by, expires_at, accepted_at, accepted_by, revoked_at, created_at
+`
+
+type AcceptShopInvitationParams struct {
+	ID         pgtype.UUID `json:"id"`
+	AcceptedBy pgtype.UUID `json:"accepted_by"`
+}
+
+func (q *Queries) AcceptShopInvitation(ctx context.Context, arg AcceptShopInvitationParams) (ShopInvitation, error) {
+	row := q.db.QueryRow(ctx, acceptShopInvitation, arg.ID, arg.AcceptedBy)
+	var i ShopInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.Email,
+		&i.Role,
+		&i.InvitedBy,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.AcceptedBy,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const countShopMembers = `-- name: CountShopMembers :one
+SELECT COUNT(*) FROM shop_members
+WHERE shop_id = $1
+`
+
+func (q *Queries) CountShopMembers(ctx context.Context, shopID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countShopMembers, shopID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createShopInvitation = `-- name: CreateShopInvitation :one
+INSERT INTO shop_invitations (shop_id, email, role, invited_by, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, shop_id, email, role, invited_by, expires_at, accepted_at, accepted_by, revoked_at, created_at
+`
+
+type CreateShopInvitationParams struct {
+	ShopID    pgtype.UUID        `json:"shop_id"`
+	Email     string             `json:"email"`
+	Role      string             `json:"role"`
+	InvitedBy pgtype.UUID        `json:"invited_by"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateShopInvitation(ctx context.Context, arg CreateShopInvitationParams) (ShopInvitation, error) {
+	row := q.db.QueryRow(ctx, createShopInvitation,
+		arg.ShopID,
+		arg.Email,
+		arg.Role,
+		arg.InvitedBy,
+		arg.ExpiresAt,
+	)
+	var i ShopInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.Email,
+		&i.Role,
+		&i.InvitedBy,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.AcceptedBy,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteShopMember = `-- name: DeleteShopMember :exec
+DELETE FROM shop_members
+WHERE shop_id = $1 AND user_id = $2
+`
+
+type DeleteShopMemberParams struct {
+	ShopID pgtype.UUID `json:"shop_id"`
+	UserID pgtype.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeleteShopMember(ctx context.Context, arg DeleteShopMemberParams) error {
+	_, err := q.db.Exec(ctx, deleteShopMember, arg.ShopID, arg.UserID)
+	return err
+}
+
+const getShopInvitation = `-- name: GetShopInvitation :one
+SELECT id, shop_id, email, role, invited_by, expires_at, accepted_at, accepted_by, revoked_at, created_at FROM shop_invitations
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetShopInvitation(ctx context.Context, id pgtype.UUID) (ShopInvitation, error) {
+	row := q.db.QueryRow(ctx, getShopInvitation, id)
+	var i ShopInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.Email,
+		&i.Role,
+		&i.InvitedBy,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.AcceptedBy,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getShopMember = `-- name: GetShopMember :one
+SELECT shop_id, user_id, role, invited_by, created_at, updated_at FROM shop_members
+WHERE shop_id = $1 AND user_id = $2 LIMIT 1
+`
+
+type GetShopMemberParams struct {
+	ShopID pgtype.UUID `json:"shop_id"`
+	UserID pgtype.UUID `json:"user_id"`
+}
+
+func (q *Queries) GetShopMember(ctx context.Context, arg GetShopMemberParams) (ShopMember, error) {
+	row := q.db.QueryRow(ctx, getShopMember, arg.ShopID, arg.UserID)
+	var i ShopMember
+	err := row.Scan(
+		&i.ShopID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listMemberShops = `-- name: ListMemberShops :many
+SELECT shops.id, shops.owner_id, shops.name, shops.subdomain, shops.custom_domain, shops.tenant_id, shops.is_active, shops.created_at, shops.status, shops.provision_error, shops.updated_at, shops.app_version, shops.suspended_at, shops.suspended_by, shops.suspension_reason, shops.maintenance_mode, shops.maintenance_allowlist, shops.storefront_password_hash, shops.deleted_at, shops.deleted_by, shops.purge_after, shops.purged_at, shops.export_path, shops.db_cluster, shops.frozen_at, shops.template_key, shops.require_two_factor, m.role
+FROM shop_members m
+JOIN shops ON shops.id = m.shop_id
+WHERE m.user_id = $1 AND shops.deleted_at IS NULL
+ORDER BY shops.name
+`
+
+type ListMemberShopsRow struct {
+	Shop Shop   `json:"shop"`
+	Role string `json:"role"`
+}
+
+// Shops the user works in as a team member
+func (q *Queries) ListMemberShops(ctx context.Context, userID pgtype.UUID) ([]ListMemberShopsRow, error) {
+	rows, err := q.db.Query(ctx, listMemberShops, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListMemberShopsRow{}
+	for rows.Next() {
+		var i ListMemberShopsRow
+		if err := rows.Scan(
+			&i.Shop.ID,
+			&i.Shop.OwnerID,
+			&i.Shop.Name,
+			&i.Shop.Subdomain,
+			&i.Shop.CustomDomain,
+			&i.Shop.TenantID,
+			&i.Shop.IsActive,
+			&i.Shop.CreatedAt,
+			&i.Shop.Status,
+			&i.Shop.ProvisionError,
+			&i.Shop.UpdatedAt,
+			&i.Shop.AppVersion,
+			&i.Shop.SuspendedAt,
+			&i.Shop.SuspendedBy,
+			&i.Shop.SuspensionReason,
+			&i.Shop.MaintenanceMode,
+			&i.Shop.MaintenanceAllowlist,
+			&i.Shop.StorefrontPasswordHash,
+			&i.Shop.DeletedAt,
+			&i.Shop.DeletedBy,
+			&i.Shop.PurgeAfter,
+			&i.Shop.PurgedAt,
+			&i.Shop.ExportPath,
+			&i.Shop.DbCluster,
+			&i.Shop.FrozenAt,
+			&i.Shop.TemplateKey,
+			&i.Shop.RequireTwoFactor,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingShopInvitations = `-- name: ListPendingShopInvitations :many
+SELECT id, shop_id, email, role, invited_by, expires_at, accepted_at, accepted_by, revoked_at, created_at FROM shop_invitations
+WHERE shop_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListPendingShopInvitations(ctx context.Context, shopID pgtype.UUID) ([]ShopInvitation, error) {
+	rows, err := q.db.Query(ctx, listPendingShopInvitations, shopID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ShopInvitation{}
+	for rows.Next() {
+		var i ShopInvitation
+		if err := rows.Scan(
+			&i.ID,
+			&i.ShopID,
+			&i.Email,
+			&i.Role,
+			&i.InvitedBy,
+			&i.ExpiresAt,
+			&i.AcceptedAt,
+			&i.AcceptedBy,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShopMembers = `-- name: ListShopMembers :many
+SELECT m.shop_id, m.user_id, m.role, m.created_at, u.email, u.first_name, u.last_name
+FROM shop_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.shop_id = $1
+ORDER BY m.created_at
+`
+
+type ListShopMembersRow struct {
+	ShopID    pgtype.UUID        `json:"shop_id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	Role      string             `json:"role"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Email     string             `json:"email"`
+	FirstName string             `json:"first_name"`
+	LastName  string             `json:"last_name"`
+}
+
+func (q *Queries) ListShopMembers(ctx context.Context, shopID pgtype.UUID) ([]ListShopMembersRow, error) {
+	rows, err := q.db.Query(ctx, listShopMembers, shopID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListShopMembersRow{}
+	for rows.Next() {
+		var i ListShopMembersRow
+		if err := rows.Scan(
+			&i.ShopID,
+			&i.UserID,
+			&i.Role,
+			&i.CreatedAt,
+			&i.Email,
+			&i.FirstName,
+			&i.LastName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeShopInvitation = `-- name: RevokeShopInvitation :one
+UPDATE shop_invitations
+SET revoked_at = NOW()
+WHERE id = $1 AND shop_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+RETURNING id, shop_id, email, role, invited_by, expires_at, accepted_at, accepted_by, revoked_at, created_at
+`
+
+type RevokeShopInvitationParams struct {
+	ID     pgtype.UUID `json:"id"`
+	ShopID pgtype.UUID `json:"shop_id"`
+}
+
+func (q *Queries) RevokeShopInvitation(ctx context.Context, arg RevokeShopInvitationParams) (ShopInvitation, error) {
+	row := q.db.QueryRow(ctx, revokeShopInvitation, arg.ID, arg.ShopID)
+	var i ShopInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.Email,
+		&i.Role,
+		&i.InvitedBy,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.AcceptedBy,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateShopMemberRole = `-- name: UpdateShopMemberRole :one
+UPDATE shop_members
+SET role = $3, updated_at = NOW()
+WHERE shop_id = $1 AND user_id = $2
+RETURNING shop_id, user_id, role, invited_by, created_at, updated_at
+`
+
+type UpdateShopMemberRoleParams struct {
+	ShopID pgtype.UUID `json:"shop_id"`
+	UserID pgtype.UUID `json:"user_id"`
+	Role   string      `json:"role"`
+}
+
+func (q *Queries) UpdateShopMemberRole(ctx context.Context, arg UpdateShopMemberRoleParams) (ShopMember, error) {
+	row := q.db.QueryRow(ctx, updateShopMemberRole, arg.ShopID, arg.UserID, arg.Role)
+	var i ShopMember
+	err := row.Scan(
+		&i.ShopID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertShopMember = `-- name: UpsertShopMember :one
+INSERT INTO shop_members (shop_id, user_id, role, invited_by)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (shop_id, user_id) DO UPDATE SET
+    role = EXCLUDED.role,
+    updated_at = NOW()
+RETURNING shop_id, user_id, role, invited_by, created_at, updated_at
+`
+
+type UpsertShopMemberParams struct {
+	ShopID    pgtype.UUID `json:"shop_id"`
+	UserID    pgtype.UUID `json:"user_id"`
+	Role      string      `json:"role"`
+	InvitedBy pgtype.UUID `json:"invited_by"`
+}
+
+func (q *Queries) UpsertShopMember(ctx context.Context, arg UpsertShopMemberParams) (ShopMember, error) {
+	row := q.db.QueryRow(ctx, upsertShopMember,
+		arg.ShopID,
+		arg.UserID,
+		arg.Role,
+		arg.InvitedBy,
+	)
+	var i ShopMember
+	err := row.Scan(
+		&i.ShopID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -8,15 +8,142 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type DbCluster struct {
+	Name            string             `json:"name"`
+	AcceptsNewShops bool               `json:"accepts_new_shops"`
+	MaxShops        *int32             `json:"max_shops"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+}
+
+type Invoice struct {
+	ID             pgtype.UUID        `json:"id"`
+	ShopID         pgtype.UUID        `json:"shop_id"`
+	SubscriptionID pgtype.UUID        `json:"subscription_id"`
+	PlanName       string             `json:"plan_name"`
+	Kind           string             `json:"kind"`
+	Amount         pgtype.Numeric     `json:"amount"`
+	CreditApplied  pgtype.Numeric     `json:"credit_applied"`
+	PeriodStart    pgtype.Timestamptz `json:"period_start"`
+	PeriodEnd      pgtype.Timestamptz `json:"period_end"`
+	Status         string             `json:"status"`
+	DueAt          pgtype.Timestamptz `json:"due_at"`
+	PaymentUrl     *string            `json:"payment_url"`
+	PaymentRef     *string            `json:"payment_ref"`
+	RemindersSent  int32              `json:"reminders_sent"`
+	LastReminderAt pgtype.Timestamptz `json:"last_reminder_at"`
+	PaidAt         pgtype.Timestamptz `json:"paid_at"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+type Plan struct {
+	Key                   string             `json:"key"`
+	Name                  string             `json:"name"`
+	MaxProducts           *int32             `json:"max_products"`
+	MaxStaff              *int32             `json:"max_staff"`
+	MaxStorageBytes       *int64             `json:"max_storage_bytes"`
+	MaxMonthlyOrders      *int32             `json:"max_monthly_orders"`
+	CustomDomain          bool               `json:"custom_domain"`
+	PageBuilderComponents []string           `json:"page_builder_components"`
+	Position              int32              `json:"position"`
+	CreatedAt             pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt             pgtype.Timestamptz `json:"updated_at"`
+	Price                 pgtype.Numeric     `json:"price"`
+}
+
+type PlatformAuditLog struct {
+	ID         pgtype.UUID        `json:"id"`
+	ActorID    pgtype.UUID        `json:"actor_id"`
+	ActorType  string             `json:"actor_type"`
+	Action     string             `json:"action"`
+	EntityType string             `json:"entity_type"`
+	EntityID   string             `json:"entity_id"`
+	Metadata   []byte             `json:"metadata"`
+	Ip         *string            `json:"ip"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	Before     []byte             `json:"before"`
+	After      []byte             `json:"after"`
+	RequestID  *string            `json:"request_id"`
+}
+
+type PlatformRecoveryCode struct {
+	ID        pgtype.UUID        `json:"id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type PlatformUserToken struct {
+	ID        pgtype.UUID        `json:"id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	Purpose   string             `json:"purpose"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type Shop struct {
-	ID           pgtype.UUID        `json:"id"`
-	OwnerID      pgtype.UUID        `json:"owner_id"`
-	Name         string             `json:"name"`
-	Subdomain    string             `json:"subdomain"`
-	CustomDomain *string            `json:"custom_domain"`
-	TenantID     string             `json:"tenant_id"`
-	IsActive     *bool              `json:"is_active"`
-	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	ID                     pgtype.UUID        `json:"id"`
+	OwnerID                pgtype.UUID        `json:"owner_id"`
+	Name                   string             `json:"name"`
+	Subdomain              string             `json:"subdomain"`
+	CustomDomain           *string            `json:"custom_domain"`
+	TenantID               string             `json:"tenant_id"`
+	IsActive               *bool              `json:"is_active"`
+	CreatedAt              pgtype.Timestamptz `json:"created_at"`
+	Status                 string             `json:"status"`
+	ProvisionError         *string            `json:"provision_error"`
+	UpdatedAt              pgtype.Timestamptz `json:"updated_at"`
+	AppVersion             *string            `json:"app_version"`
+	SuspendedAt            pgtype.Timestamptz `json:"suspended_at"`
+	SuspendedBy            *string            `json:"suspended_by"`
+	SuspensionReason       *string            `json:"suspension_reason"`
+	MaintenanceMode        bool               `json:"maintenance_mode"`
+	MaintenanceAllowlist   []string           `json:"maintenance_allowlist"`
+	StorefrontPasswordHash *string            `json:"storefront_password_hash"`
+	DeletedAt              pgtype.Timestamptz `json:"deleted_at"`
+	DeletedBy              pgtype.UUID        `json:"deleted_by"`
+	PurgeAfter             pgtype.Timestamptz `json:"purge_after"`
+	PurgedAt               pgtype.Timestamptz `json:"purged_at"`
+	ExportPath             *string            `json:"export_path"`
+	DbCluster              string             `json:"db_cluster"`
+	FrozenAt               pgtype.Timestamptz `json:"frozen_at"`
+	TemplateKey            *string            `json:"template_key"`
+	RequireTwoFactor       bool               `json:"require_two_factor"`
+}
+
+type ShopInvitation struct {
+	ID         pgtype.UUID        `json:"id"`
+	ShopID     pgtype.UUID        `json:"shop_id"`
+	Email      string             `json:"email"`
+	Role       string             `json:"role"`
+	InvitedBy  pgtype.UUID        `json:"invited_by"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	AcceptedAt pgtype.Timestamptz `json:"accepted_at"`
+	AcceptedBy pgtype.UUID        `json:"accepted_by"`
+	RevokedAt  pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type ShopMember struct {
+	ShopID    pgtype.UUID        `json:"shop_id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	Role      string             `json:"role"`
+	InvitedBy pgtype.UUID        `json:"invited_by"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type ShopTemplate struct {
+	Key         string             `json:"key"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	ShopID      pgtype.UUID        `json:"shop_id"`
+	Position    int32              `json:"position"`
+	IsActive    bool               `json:"is_active"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
 }
 
 type Subscription struct {
@@ -26,14 +153,30 @@ type Subscription struct {
 	Status           *string            `json:"status"`
 	CurrentPeriodEnd pgtype.Timestamptz `json:"current_period_end"`
 	CreatedAt        pgtype.Timestamptz `json:"created_at"`
+	PastDueSince     pgtype.Timestamptz `json:"past_due_since"`
+	Credit           pgtype.Numeric     `json:"credit"`
+	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
+}
+
+type TenantMigration struct {
+	TenantID  string             `json:"tenant_id"`
+	Version   int64              `json:"version"`
+	Dirty     bool               `json:"dirty"`
+	LastError *string            `json:"last_error"`
+	LastRunAt pgtype.Timestamptz `json:"last_run_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
 }
 
 type User struct {
-	ID           pgtype.UUID        `json:"id"`
-	Email        string             `json:"email"`
-	PasswordHash string             `json:"password_hash"`
-	FirstName    string             `json:"first_name"`
-	LastName     string             `json:"last_name"`
-	CreatedAt    pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+	ID              pgtype.UUID        `json:"id"`
+	Email           string             `json:"email"`
+	PasswordHash    string             `json:"password_hash"`
+	FirstName       string             `json:"first_name"`
+	LastName        string             `json:"last_name"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+	TotpSecret      *string            `json:"totp_secret"`
+	TotpEnabledAt   pgtype.Timestamptz `json:"totp_enabled_at"`
+	TotpLastStep    int64              `json:"totp_last_step"`
 }
@@ -0,0 +1,73 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: plans.sql
+
+package platform
+
+import (
+	"context"
+)
+
+const getPlan = `-- name: GetPlan :one
+SELECT key, name, max_products, max_staff, max_storage_bytes, max_monthly_orders, custom_domain, page_builder_components, position, created_at, updated_at, price FROM plans
+WHERE key = $1 LIMIT 1
+`
+
+func (q *Queries) GetPlan(ctx context.Context, key string) (Plan, error) {
+	row := q.db.QueryRow(ctx, getPlan, key)
+	var i Plan
+	err := row.Scan(
+		&i.Key,
+		&i.Name,
+		&i.MaxProducts,
+		&i.MaxStaff,
+		&i.MaxStorageBytes,
+		&i.MaxMonthlyOrders,
+		&i.CustomDomain,
+		&i.PageBuilderComponents,
+		&i.Position,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Price,
+	)
+	return i, err
+}
+
+const listPlans = `-- name: ListPlans :many
+SELECT key, name, max_products, max_staff, max_storage_bytes, max_monthly_orders, custom_domain, page_builder_components, position, created_at, updated_at, price FROM plans
+ORDER BY position, key
+`
+
+func (q *Queries) ListPlans(ctx context.Context) ([]Plan, error) {
+	rows, err := q.db.Query(ctx, listPlans)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Plan{}
+	for rows.Next() {
+		var i Plan
+		if err := rows.Scan(
+			&i.Key,
+			&i.Name,
+			&i.MaxProducts,
+			&i.MaxStaff,
+			&i.MaxStorageBytes,
+			&i.MaxMonthlyOrders,
+			&i.CustomDomain,
+			&i.PageBuilderComponents,
+			&i.Position,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Price,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -11,12 +11,109 @@ import (
 )
 
 type Querier interface {
+	AcceptShopInvitation(ctx context.Context, arg AcceptShopInvitationParams) (ShopInvitation, error)
+	ActivateShop(ctx context.Context, id pgtype.UUID) (Shop, error)
+	ChangeSubscriptionPlan(ctx context.Context, arg ChangeSubscriptionPlanParams) (Subscription, error)
+	// Marks the token used and returns it, once, while it has not expired
+	ConsumeUserToken(ctx context.Context, arg ConsumeUserTokenParams) (PlatformUserToken, error)
+	CountOpenInvoices(ctx context.Context, arg CountOpenInvoicesParams) (int64, error)
+	CountOverdueInvoicesByShop(ctx context.Context, arg CountOverdueInvoicesByShopParams) (int64, error)
+	CountShopMembers(ctx context.Context, shopID pgtype.UUID) (int64, error)
+	CountShopsByAppVersion(ctx context.Context) ([]CountShopsByAppVersionRow, error)
+	CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (Invoice, error)
+	CreatePlatformAuditLog(ctx context.Context, arg CreatePlatformAuditLogParams) (PlatformAuditLog, error)
 	CreateShop(ctx context.Context, arg CreateShopParams) (Shop, error)
+	CreateShopInvitation(ctx context.Context, arg CreateShopInvitationParams) (ShopInvitation, error)
+	CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserToken(ctx context.Context, arg CreateUserTokenParams) (PlatformUserToken, error)
+	DeleteShopMember(ctx context.Context, arg DeleteShopMemberParams) error
+	DeleteTenantMigration(ctx context.Context, tenantID string) error
+	DisableTOTP(ctx context.Context, id pgtype.UUID) error
+	EnableTOTP(ctx context.Context, arg EnableTOTPParams) error
+	ExtendSubscription(ctx context.Context, arg ExtendSubscriptionParams) (Subscription, error)
+	FreezeShop(ctx context.Context, id pgtype.UUID) (Shop, error)
+	GetInvoice(ctx context.Context, id pgtype.UUID) (Invoice, error)
+	GetLatestSubscriptionByShop(ctx context.Context, shopID pgtype.UUID) (Subscription, error)
+	GetPlan(ctx context.Context, key string) (Plan, error)
+	GetShopByCustomDomain(ctx context.Context, customDomain *string) (Shop, error)
+	GetShopByID(ctx context.Context, id pgtype.UUID) (Shop, error)
 	GetShopBySubdomain(ctx context.Context, subdomain string) (Shop, error)
+	GetShopByTenantID(ctx context.Context, tenantID string) (Shop, error)
+	GetShopInvitation(ctx context.Context, id pgtype.UUID) (ShopInvitation, error)
+	GetShopMember(ctx context.Context, arg GetShopMemberParams) (ShopMember, error)
+	GetShopTemplate(ctx context.Context, key string) (ShopTemplate, error)
+	GetSubscription(ctx context.Context, id pgtype.UUID) (Subscription, error)
+	GetTenantMigration(ctx context.Context, tenantID string) (TenantMigration, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserById(ctx context.Context, id pgtype.UUID) (User, error)
+	GetUserTwoFactor(ctx context.Context, id pgtype.UUID) (GetUserTwoFactorRow, error)
+	ListActiveShopTemplates(ctx context.Context) ([]ShopTemplate, error)
+	// Clusters that can take a new shop, least loaded first
+	ListClustersForPlacement(ctx context.Context) ([]string, error)
+	// Clusters with their live shop count
+	ListDBClusters(ctx context.Context) ([]ListDBClustersRow, error)
+	ListInvoicesByShop(ctx context.Context, arg ListInvoicesByShopParams) ([]Invoice, error)
+	// Shops the user works in as a team member
+	ListMemberShops(ctx context.Context, userID pgtype.UUID) ([]ListMemberShopsRow, error)
+	ListOverdueInvoices(ctx context.Context, dueAt pgtype.Timestamptz) ([]Invoice, error)
+	ListPendingShopInvitations(ctx context.Context, shopID pgtype.UUID) ([]ShopInvitation, error)
+	ListPlans(ctx context.Context) ([]Plan, error)
+	// Newest first, filtered like the shops' ListAuditLog: action matches a
+	// prefix, actor an email or an actor ID
+	ListPlatformAuditLog(ctx context.Context, arg ListPlatformAuditLogParams) ([]ListPlatformAuditLogRow, error)
+	ListPlatformAuditLogByEntity(ctx context.Context, arg ListPlatformAuditLogByEntityParams) ([]PlatformAuditLog, error)
+	// Shops whose schema exists and has been migrated at least once
+	ListProvisionedShops(ctx context.Context) ([]Shop, error)
+	ListShopMembers(ctx context.Context, shopID pgtype.UUID) ([]ListShopMembersRow, error)
+	ListShopTemplates(ctx context.Context) ([]ShopTemplate, error)
+	ListShopsAwaitingExport(ctx context.Context) ([]Shop, error)
 	ListShopsByOwner(ctx context.Context, ownerID pgtype.UUID) ([]Shop, error)
+	ListShopsDueForPurge(ctx context.Context) ([]Shop, error)
+	ListShopsPendingProvision(ctx context.Context, updatedAt pgtype.Timestamptz) ([]Shop, error)
+	// Subscriptions whose period ends before $1 and have no renewal invoice for
+	// the next period yet
+	ListSubscriptionsDueForRenewal(ctx context.Context, currentPeriodEnd pgtype.Timestamptz) ([]Subscription, error)
+	ListTenantMigrations(ctx context.Context) ([]TenantMigration, error)
+	MarkEmailVerified(ctx context.Context, id pgtype.UUID) error
+	MarkInvoicePaid(ctx context.Context, arg MarkInvoicePaidParams) (Invoice, error)
+	MarkShopDeleted(ctx context.Context, arg MarkShopDeletedParams) (Shop, error)
+	MarkShopPurged(ctx context.Context, id pgtype.UUID) (Shop, error)
+	MarkSubscriptionPastDue(ctx context.Context, arg MarkSubscriptionPastDueParams) (Subscription, error)
+	// Moves up to batch_size active shops from one cohort to another.
+	// Optionally limited to given tenants and/or to shops whose schema is at schema_version.
+	MoveShopsToAppVersion(ctx context.Context, arg MoveShopsToAppVersionParams) ([]Shop, error)
+	RecordInvoiceReminder(ctx context.Context, id pgtype.UUID) (Invoice, error)
+	ReplaceRecoveryCodes(ctx context.Context, arg ReplaceRecoveryCodesParams) error
+	RestoreShop(ctx context.Context, id pgtype.UUID) (Shop, error)
+	RevokeShopInvitation(ctx context.Context, arg RevokeShopInvitationParams) (ShopInvitation, error)
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error
+	SetInvoicePaymentURL(ctx context.Context, arg SetInvoicePaymentURLParams) (Invoice, error)
+	// Switches a moved shop to its new cluster and lifts the write freeze
+	SetShopCluster(ctx context.Context, arg SetShopClusterParams) (Shop, error)
+	SetShopExportPath(ctx context.Context, arg SetShopExportPathParams) (Shop, error)
+	SetSubscriptionCredit(ctx context.Context, arg SetSubscriptionCreditParams) (Subscription, error)
+	// Starts (or restarts) enrollment; two-factor stays off until EnableTOTP
+	SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error
+	SuspendShop(ctx context.Context, arg SuspendShopParams) (Shop, error)
+	UnfreezeShop(ctx context.Context, id pgtype.UUID) (Shop, error)
+	UnsuspendShop(ctx context.Context, id pgtype.UUID) (Shop, error)
+	UpdatePassword(ctx context.Context, arg UpdatePasswordParams) error
+	UpdateShopCustomDomain(ctx context.Context, arg UpdateShopCustomDomainParams) (Shop, error)
+	UpdateShopMaintenance(ctx context.Context, arg UpdateShopMaintenanceParams) (Shop, error)
+	UpdateShopMemberRole(ctx context.Context, arg UpdateShopMemberRoleParams) (ShopMember, error)
+	UpdateShopRequireTwoFactor(ctx context.Context, arg UpdateShopRequireTwoFactorParams) (Shop, error)
+	UpdateShopStatus(ctx context.Context, arg UpdateShopStatusParams) (Shop, error)
+	UpdateShopStorefrontPassword(ctx context.Context, arg UpdateShopStorefrontPasswordParams) (Shop, error)
+	UpsertDBCluster(ctx context.Context, arg UpsertDBClusterParams) (DbCluster, error)
+	UpsertShopMember(ctx context.Context, arg UpsertShopMemberParams) (ShopMember, error)
+	UpsertShopTemplate(ctx context.Context, arg UpsertShopTemplateParams) (ShopTemplate, error)
+	UpsertTenantMigration(ctx context.Context, arg UpsertTenantMigrationParams) (TenantMigration, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
+	// Accepts a code's time step once: later codes only
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
+	// Voids open invoices not yet due; overdue ones stay owed
+	VoidUpcomingInvoices(ctx context.Context, arg VoidUpcomingInvoicesParams) error
 }
 
 var _ Querier = (*Queries)(nil)
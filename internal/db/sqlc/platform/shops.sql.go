@@ -11,24 +11,109 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const activateShop = `-- name: ActivateShop :one
+UPDATE shops
+SET
+    status = 'active',
+    is_active = TRUE,
+    provision_error = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+func (q *Queries) ActivateShop(ctx context.Context, id pgtype.UUID) (Shop, error) {
+	row := q.db.QueryRow(ctx, activateShop, id)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const countShopsByAppVersion = `-- name: CountShopsByAppVersion :many
+SELECT app_version, COUNT(*) AS shops
+FROM shops
+WHERE status = 'active'
+GROUP BY app_version
+ORDER BY app_version
+`
+
+type CountShopsByAppVersionRow struct {
+	AppVersion *string `json:"app_version"`
+	Shops      int64   `json:"shops"`
+}
+
+func (q *Queries) CountShopsByAppVersion(ctx context.Context) ([]CountShopsByAppVersionRow, error) {
+	rows, err := q.db.Query(ctx, countShopsByAppVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountShopsByAppVersionRow{}
+	for rows.Next() {
+		var i CountShopsByAppVersionRow
+		if err := rows.Scan(&i.AppVersion, &i.Shops); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createShop = `-- name: CreateShop :one
 INSERT INTO shops (
     owner_id,
     name,
     subdomain,
     tenant_id,
-    is_active
+    is_active,
+    status,
+    db_cluster,
+    template_key
 ) VALUES (
-    $1, $2, $3, $4, $5
-) RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
 `
 
 type CreateShopParams struct {
-	OwnerID   pgtype.UUID `json:"owner_id"`
-	Name      string      `json:"name"`
-	Subdomain string      `json:"subdomain"`
-	TenantID  string      `json:"tenant_id"`
-	IsActive  *bool       `json:"is_active"`
+	OwnerID     pgtype.UUID `json:"owner_id"`
+	Name        string      `json:"name"`
+	Subdomain   string      `json:"subdomain"`
+	TenantID    string      `json:"tenant_id"`
+	IsActive    *bool       `json:"is_active"`
+	Status      string      `json:"status"`
+	DbCluster   string      `json:"db_cluster"`
+	TemplateKey *string     `json:"template_key"`
 }
 
 func (q *Queries) CreateShop(ctx context.Context, arg CreateShopParams) (Shop, error) {
@@ -38,7 +123,52 @@ func (q *Queries) CreateShop(ctx context.Context, arg CreateShopParams) (Shop, e
 		arg.Subdomain,
 		arg.TenantID,
 		arg.IsActive,
+		arg.Status,
+		arg.DbCluster,
+		arg.TemplateKey,
+	)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
 	)
+	return i, err
+}
+
+const freezeShop = `-- name: FreezeShop :one
+UPDATE shops
+SET frozen_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND frozen_at IS NULL
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+func (q *Queries) FreezeShop(ctx context.Context, id pgtype.UUID) (Shop, error) {
+	row := q.db.QueryRow(ctx, freezeShop, id)
 	var i Shop
 	err := row.Scan(
 		&i.ID,
@@ -49,12 +179,111 @@ func (q *Queries) CreateShop(ctx context.Context, arg CreateShopParams) (Shop, e
 		&i.TenantID,
 		&i.IsActive,
 		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const getShopByCustomDomain = `-- name: GetShopByCustomDomain :one
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
+WHERE custom_domain = $1 LIMIT 1
+`
+
+func (q *Queries) GetShopByCustomDomain(ctx context.Context, customDomain *string) (Shop, error) {
+	row := q.db.QueryRow(ctx, getShopByCustomDomain, customDomain)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const getShopByID = `-- name: GetShopByID :one
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetShopByID(ctx context.Context, id pgtype.UUID) (Shop, error) {
+	row := q.db.QueryRow(ctx, getShopByID, id)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
 	)
 	return i, err
 }
 
 const getShopBySubdomain = `-- name: GetShopBySubdomain :one
-SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at FROM shops
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
 WHERE subdomain = $1 LIMIT 1
 `
 
@@ -70,13 +299,184 @@ func (q *Queries) GetShopBySubdomain(ctx context.Context, subdomain string) (Sho
 		&i.TenantID,
 		&i.IsActive,
 		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
 	)
 	return i, err
 }
 
+const getShopByTenantID = `-- name: GetShopByTenantID :one
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
+WHERE tenant_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetShopByTenantID(ctx context.Context, tenantID string) (Shop, error) {
+	row := q.db.QueryRow(ctx, getShopByTenantID, tenantID)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const listProvisionedShops = `-- name: ListProvisionedShops :many
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
+WHERE status NOT IN ('pending', 'schema_created', 'failed')
+  AND purged_at IS NULL
+ORDER BY tenant_id
+`
+
+// Shops whose schema exists and has been migrated at least once
+func (q *Queries) ListProvisionedShops(ctx context.Context) ([]Shop, error) {
+	rows, err := q.db.Query(ctx, listProvisionedShops)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Shop{}
+	for rows.Next() {
+		var i Shop
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Subdomain,
+			&i.CustomDomain,
+			&i.TenantID,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.Status,
+			&i.ProvisionError,
+			&i.UpdatedAt,
+			&i.AppVersion,
+			&i.SuspendedAt,
+			&i.SuspendedBy,
+			&i.SuspensionReason,
+			&i.MaintenanceMode,
+			&i.MaintenanceAllowlist,
+			&i.StorefrontPasswordHash,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PurgeAfter,
+			&i.PurgedAt,
+			&i.ExportPath,
+			&i.DbCluster,
+			&i.FrozenAt,
+			&i.TemplateKey,
+			&i.RequireTwoFactor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShopsAwaitingExport = `-- name: ListShopsAwaitingExport :many
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
+WHERE deleted_at IS NOT NULL
+  AND purged_at IS NULL
+  AND export_path IS NULL
+ORDER BY deleted_at
+`
+
+func (q *Queries) ListShopsAwaitingExport(ctx context.Context) ([]Shop, error) {
+	rows, err := q.db.Query(ctx, listShopsAwaitingExport)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Shop{}
+	for rows.Next() {
+		var i Shop
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Subdomain,
+			&i.CustomDomain,
+			&i.TenantID,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.Status,
+			&i.ProvisionError,
+			&i.UpdatedAt,
+			&i.AppVersion,
+			&i.SuspendedAt,
+			&i.SuspendedBy,
+			&i.SuspensionReason,
+			&i.MaintenanceMode,
+			&i.MaintenanceAllowlist,
+			&i.StorefrontPasswordHash,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PurgeAfter,
+			&i.PurgedAt,
+			&i.ExportPath,
+			&i.DbCluster,
+			&i.FrozenAt,
+			&i.TemplateKey,
+			&i.RequireTwoFactor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listShopsByOwner = `-- name: ListShopsByOwner :many
-SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at FROM shops
-WHERE owner_id = $1
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
+WHERE owner_id = $1 AND purged_at IS NULL
 `
 
 func (q *Queries) ListShopsByOwner(ctx context.Context, ownerID pgtype.UUID) ([]Shop, error) {
@@ -97,6 +497,315 @@ func (q *Queries) ListShopsByOwner(ctx context.Context, ownerID pgtype.UUID) ([]
 			&i.TenantID,
 			&i.IsActive,
 			&i.CreatedAt,
+			&i.Status,
+			&i.ProvisionError,
+			&i.UpdatedAt,
+			&i.AppVersion,
+			&i.SuspendedAt,
+			&i.SuspendedBy,
+			&i.SuspensionReason,
+			&i.MaintenanceMode,
+			&i.MaintenanceAllowlist,
+			&i.StorefrontPasswordHash,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PurgeAfter,
+			&i.PurgedAt,
+			&i.ExportPath,
+			&i.DbCluster,
+			&i.FrozenAt,
+			&i.TemplateKey,
+			&i.RequireTwoFactor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShopsDueForPurge = `-- name: ListShopsDueForPurge :many
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
+WHERE deleted_at IS NOT NULL
+  AND purged_at IS NULL
+  AND purge_after < NOW()
+ORDER BY purge_after
+`
+
+func (q *Queries) ListShopsDueForPurge(ctx context.Context) ([]Shop, error) {
+	rows, err := q.db.Query(ctx, listShopsDueForPurge)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Shop{}
+	for rows.Next() {
+		var i Shop
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Subdomain,
+			&i.CustomDomain,
+			&i.TenantID,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.Status,
+			&i.ProvisionError,
+			&i.UpdatedAt,
+			&i.AppVersion,
+			&i.SuspendedAt,
+			&i.SuspendedBy,
+			&i.SuspensionReason,
+			&i.MaintenanceMode,
+			&i.MaintenanceAllowlist,
+			&i.StorefrontPasswordHash,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PurgeAfter,
+			&i.PurgedAt,
+			&i.ExportPath,
+			&i.DbCluster,
+			&i.FrozenAt,
+			&i.TemplateKey,
+			&i.RequireTwoFactor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShopsPendingProvision = `-- name: ListShopsPendingProvision :many
+SELECT id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor FROM shops
+WHERE status IN ('pending', 'schema_created', 'migrated', 'seeded')
+  AND updated_at < $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListShopsPendingProvision(ctx context.Context, updatedAt pgtype.Timestamptz) ([]Shop, error) {
+	rows, err := q.db.Query(ctx, listShopsPendingProvision, updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Shop{}
+	for rows.Next() {
+		var i Shop
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Subdomain,
+			&i.CustomDomain,
+			&i.TenantID,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.Status,
+			&i.ProvisionError,
+			&i.UpdatedAt,
+			&i.AppVersion,
+			&i.SuspendedAt,
+			&i.SuspendedBy,
+			&i.SuspensionReason,
+			&i.MaintenanceMode,
+			&i.MaintenanceAllowlist,
+			&i.StorefrontPasswordHash,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PurgeAfter,
+			&i.PurgedAt,
+			&i.ExportPath,
+			&i.DbCluster,
+			&i.FrozenAt,
+			&i.TemplateKey,
+			&i.RequireTwoFactor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markShopDeleted = `-- name: MarkShopDeleted :one
+UPDATE shops
+SET
+    deleted_at = NOW(),
+    deleted_by = $2,
+    purge_after = $3,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type MarkShopDeletedParams struct {
+	ID         pgtype.UUID        `json:"id"`
+	DeletedBy  pgtype.UUID        `json:"deleted_by"`
+	PurgeAfter pgtype.Timestamptz `json:"purge_after"`
+}
+
+func (q *Queries) MarkShopDeleted(ctx context.Context, arg MarkShopDeletedParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, markShopDeleted, arg.ID, arg.DeletedBy, arg.PurgeAfter)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const markShopPurged = `-- name: MarkShopPurged :one
+UPDATE shops
+SET
+    purged_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+func (q *Queries) MarkShopPurged(ctx context.Context, id pgtype.UUID) (Shop, error) {
+	row := q.db.QueryRow(ctx, markShopPurged, id)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const moveShopsToAppVersion = `-- name: MoveShopsToAppVersion :many
+UPDATE shops
+SET
+    app_version = $1,
+    updated_at = NOW()
+WHERE id IN (
+    SELECT s.id FROM shops s
+    LEFT JOIN tenant_migrations tm ON tm.tenant_id = s.tenant_id
+    WHERE s.status = 'active'
+      AND s.app_version IS NOT DISTINCT FROM $2
+      AND ($3::bigint IS NULL OR tm.version = $3::bigint)
+      AND (cardinality($4::text[]) = 0 OR s.tenant_id = ANY($4::text[]))
+    ORDER BY s.created_at
+    LIMIT $5
+    FOR UPDATE OF s SKIP LOCKED
+)
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type MoveShopsToAppVersionParams struct {
+	ToVersion     *string  `json:"to_version"`
+	FromVersion   *string  `json:"from_version"`
+	SchemaVersion *int64   `json:"schema_version"`
+	TenantIds     []string `json:"tenant_ids"`
+	BatchSize     int32    `json:"batch_size"`
+}
+
+// Moves up to batch_size active shops from one cohort to another.
+// Optionally limited to given tenants and/or to shops whose schema is at schema_version.
+func (q *Queries) MoveShopsToAppVersion(ctx context.Context, arg MoveShopsToAppVersionParams) ([]Shop, error) {
+	rows, err := q.db.Query(ctx, moveShopsToAppVersion,
+		arg.ToVersion,
+		arg.FromVersion,
+		arg.SchemaVersion,
+		arg.TenantIds,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Shop{}
+	for rows.Next() {
+		var i Shop
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Subdomain,
+			&i.CustomDomain,
+			&i.TenantID,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.Status,
+			&i.ProvisionError,
+			&i.UpdatedAt,
+			&i.AppVersion,
+			&i.SuspendedAt,
+			&i.SuspendedBy,
+			&i.SuspensionReason,
+			&i.MaintenanceMode,
+			&i.MaintenanceAllowlist,
+			&i.StorefrontPasswordHash,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.PurgeAfter,
+			&i.PurgedAt,
+			&i.ExportPath,
+			&i.DbCluster,
+			&i.FrozenAt,
+			&i.TemplateKey,
+			&i.RequireTwoFactor,
 		); err != nil {
 			return nil, err
 		}
@@ -107,3 +816,534 @@ func (q *Queries) ListShopsByOwner(ctx context.Context, ownerID pgtype.UUID) ([]
 	}
 	return items, nil
 }
+
+const restoreShop = `-- name: RestoreShop :one
+UPDATE shops
+SET
+    deleted_at = NULL,
+    deleted_by = NULL,
+    purge_after = NULL,
+    export_path = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NOT NULL AND purged_at IS NULL
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+func (q *Queries) RestoreShop(ctx context.Context, id pgtype.UUID) (Shop, error) {
+	row := q.db.QueryRow(ctx, restoreShop, id)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const setShopCluster = `-- name: SetShopCluster :one
+UPDATE shops
+SET db_cluster = $2, frozen_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type SetShopClusterParams struct {
+	ID        pgtype.UUID `json:"id"`
+	DbCluster string      `json:"db_cluster"`
+}
+
+// Switches a moved shop to its new cluster and lifts the write freeze
+func (q *Queries) SetShopCluster(ctx context.Context, arg SetShopClusterParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, setShopCluster, arg.ID, arg.DbCluster)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const setShopExportPath = `-- name: SetShopExportPath :one
+UPDATE shops
+SET
+    export_path = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type SetShopExportPathParams struct {
+	ID         pgtype.UUID `json:"id"`
+	ExportPath *string     `json:"export_path"`
+}
+
+func (q *Queries) SetShopExportPath(ctx context.Context, arg SetShopExportPathParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, setShopExportPath, arg.ID, arg.ExportPath)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const suspendShop = `-- name: SuspendShop :one
+UPDATE shops
+SET
+    suspended_at = NOW(),
+    suspended_by = $2,
+    suspension_reason = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type SuspendShopParams struct {
+	ID               pgtype.UUID `json:"id"`
+	SuspendedBy      *string     `json:"suspended_by"`
+	SuspensionReason *string     `json:"suspension_reason"`
+}
+
+func (q *Queries) SuspendShop(ctx context.Context, arg SuspendShopParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, suspendShop, arg.ID, arg.SuspendedBy, arg.SuspensionReason)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const unfreezeShop = `-- name: UnfreezeShop :one
+UPDATE shops
+SET frozen_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+func (q *Queries) UnfreezeShop(ctx context.Context, id pgtype.UUID) (Shop, error) {
+	row := q.db.QueryRow(ctx, unfreezeShop, id)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const unsuspendShop = `-- name: UnsuspendShop :one
+UPDATE shops
+SET
+    suspended_at = NULL,
+    suspended_by = NULL,
+    suspension_reason = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+func (q *Queries) UnsuspendShop(ctx context.Context, id pgtype.UUID) (Shop, error) {
+	row := q.db.QueryRow(ctx, unsuspendShop, id)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const updateShopCustomDomain = `-- name: UpdateShopCustomDomain :one
+UPDATE shops
+SET custom_domain = $2
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type UpdateShopCustomDomainParams struct {
+	ID           pgtype.UUID `json:"id"`
+	CustomDomain *string     `json:"custom_domain"`
+}
+
+func (q *Queries) UpdateShopCustomDomain(ctx context.Context, arg UpdateShopCustomDomainParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, updateShopCustomDomain, arg.ID, arg.CustomDomain)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const updateShopMaintenance = `-- name: UpdateShopMaintenance :one
+UPDATE shops
+SET
+    maintenance_mode = $2,
+    maintenance_allowlist = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type UpdateShopMaintenanceParams struct {
+	ID                   pgtype.UUID `json:"id"`
+	MaintenanceMode      bool        `json:"maintenance_mode"`
+	MaintenanceAllowlist []string    `json:"maintenance_allowlist"`
+}
+
+func (q *Queries) UpdateShopMaintenance(ctx context.Context, arg UpdateShopMaintenanceParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, updateShopMaintenance, arg.ID, arg.MaintenanceMode, arg.MaintenanceAllowlist)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const updateShopRequireTwoFactor = `-- name: UpdateShopRequireTwoFactor :one
+UPDATE shops
+SET
+    require_two_factor = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type UpdateShopRequireTwoFactorParams struct {
+	ID               pgtype.UUID `json:"id"`
+	RequireTwoFactor bool        `json:"require_two_factor"`
+}
+
+func (q *Queries) UpdateShopRequireTwoFactor(ctx context.Context, arg UpdateShopRequireTwoFactorParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, updateShopRequireTwoFactor, arg.ID, arg.RequireTwoFactor)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const updateShopStatus = `-- name: UpdateShopStatus :one
+UPDATE shops
+SET
+    status = $2,
+    provision_error = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type UpdateShopStatusParams struct {
+	ID             pgtype.UUID `json:"id"`
+	Status         string      `json:"status"`
+	ProvisionError *string     `json:"provision_error"`
+}
+
+func (q *Queries) UpdateShopStatus(ctx context.Context, arg UpdateShopStatusParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, updateShopStatus, arg.ID, arg.Status, arg.ProvisionError)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const updateShopStorefrontPassword = `-- name: UpdateShopStorefrontPassword :one
+UPDATE shops
+SET
+    storefront_password_hash = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, owner_id, name, subdomain, custom_domain, tenant_id, is_active, created_at, status, provision_error, updated_at, app_version, suspended_at, suspended_by, suspension_reason, maintenance_mode, maintenance_allowlist, storefront_password_hash, deleted_at, deleted_by, purge_after, purged_at, export_path, db_cluster, frozen_at, template_key, require_two_factor
+`
+
+type UpdateShopStorefrontPasswordParams struct {
+	ID                     pgtype.UUID `json:"id"`
+	StorefrontPasswordHash *string     `json:"storefront_password_hash"`
+}
+
+func (q *Queries) UpdateShopStorefrontPassword(ctx context.Context, arg UpdateShopStorefrontPasswordParams) (Shop, error) {
+	row := q.db.QueryRow(ctx, updateShopStorefrontPassword, arg.ID, arg.StorefrontPasswordHash)
+	var i Shop
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Subdomain,
+		&i.CustomDomain,
+		&i.TenantID,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.Status,
+		&i.ProvisionError,
+		&i.UpdatedAt,
+		&i.AppVersion,
+		&i.SuspendedAt,
+		&i.SuspendedBy,
+		&i.SuspensionReason,
+		&i.MaintenanceMode,
+		&i.MaintenanceAllowlist,
+		&i.StorefrontPasswordHash,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.PurgeAfter,
+		&i.PurgedAt,
+		&i.ExportPath,
+		&i.DbCluster,
+		&i.FrozenAt,
+		&i.TemplateKey,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
@@ -0,0 +1,258 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: subscriptions.sql
+
+package platform
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const changeSubscriptionPlan = `-- name: ChangeSubscriptionPlan :one
+UPDATE subscriptions
+SET plan_name = $2, credit = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, shop_id, plan_name, status, current_period_end, created_at, past_due_since, credit, updated_at
+`
+
+type ChangeSubscriptionPlanParams struct {
+	ID       pgtype.UUID    `json:"id"`
+	PlanName string         `json:"plan_name"`
+	Credit   pgtype.Numeric `json:"credit"`
+}
+
+func (q *Queries) ChangeSubscriptionPlan(ctx context.Context, arg ChangeSubscriptionPlanParams) (Subscription, error) {
+	row := q.db.QueryRow(ctx, changeSubscriptionPlan, arg.ID, arg.PlanName, arg.Credit)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.PlanName,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CreatedAt,
+		&i.PastDueSince,
+		&i.Credit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createSubscription = `-- name: CreateSubscription :one
+INSERT INTO subscriptions (shop_id, plan_name, status, current_period_end)
+VALUES ($1, $2, $3, $4)
+RETURNING id, shop_id, plan_name, status, current_period_end, created_at, past_due_since, credit, updated_at
+`
+
+type CreateSubscriptionParams struct {
+	ShopID           pgtype.UUID        `json:"shop_id"`
+	PlanName         string             `json:"plan_name"`
+	Status           *string            `json:"status"`
+	CurrentPeriodEnd pgtype.Timestamptz `json:"current_period_end"`
+}
+
+func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRow(ctx, createSubscription,
+		arg.ShopID,
+		arg.PlanName,
+		arg.Status,
+		arg.CurrentPeriodEnd,
+	)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.PlanName,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CreatedAt,
+		&i.PastDueSince,
+		&i.Credit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const extendSubscription = `-- name: ExtendSubscription :one
+UPDATE subscriptions
+SET current_period_end = $2, status = 'active', past_due_since = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, shop_id, plan_name, status, current_period_end, created_at, past_due_since, credit, updated_at
+`
+
+type ExtendSubscriptionParams struct {
+	ID               pgtype.UUID        `json:"id"`
+	CurrentPeriodEnd pgtype.Timestamptz `json:"current_period_end"`
+}
+
+func (q *Queries) ExtendSubscription(ctx context.Context, arg ExtendSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRow(ctx, extendSubscription, arg.ID, arg.CurrentPeriodEnd)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.PlanName,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CreatedAt,
+		&i.PastDueSince,
+		&i.Credit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getLatestSubscriptionByShop = `-- name: GetLatestSubscriptionByShop :one
+SELECT id, shop_id, plan_name, status, current_period_end, created_at, past_due_since, credit, updated_at FROM subscriptions
+WHERE shop_id = $1
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestSubscriptionByShop(ctx context.Context, shopID pgtype.UUID) (Subscription, error) {
+	row := q.db.QueryRow(ctx, getLatestSubscriptionByShop, shopID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.PlanName,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CreatedAt,
+		&i.PastDueSince,
+		&i.Credit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getSubscription = `-- name: GetSubscription :one
+SELECT id, shop_id, plan_name, status, current_period_end, created_at, past_due_since, credit, updated_at FROM subscriptions
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetSubscription(ctx context.Context, id pgtype.UUID) (Subscription, error) {
+	row := q.db.QueryRow(ctx, getSubscription, id)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.PlanName,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CreatedAt,
+		&i.PastDueSince,
+		&i.Credit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listSubscriptionsDueForRenewal = `-- name: ListSubscriptionsDueForRenewal :many
+SELECT s.id, s.shop_id, s.plan_name, s.status, s.current_period_end, s.created_at, s.past_due_since, s.credit, s.updated_at FROM subscriptions s
+JOIN shops sh ON sh.id = s.shop_id
+WHERE s.status IN ('active', 'past_due')
+  AND s.current_period_end <= $1
+  AND sh.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM invoices i
+    WHERE i.subscription_id = s.id
+      AND i.kind = 'renewal'
+      AND i.period_start = s.current_period_end
+      AND i.status <> 'void'
+  )
+ORDER BY s.current_period_end
+`
+
+// Subscriptions whose period ends before $1 and have no renewal invoice for
+// the next period yet
+func (q *Queries) ListSubscriptionsDueForRenewal(ctx context.Context, currentPeriodEnd pgtype.Timestamptz) ([]Subscription, error) {
+	rows, err := q.db.Query(ctx, listSubscriptionsDueForRenewal, currentPeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Subscription{}
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.ShopID,
+			&i.PlanName,
+			&i.Status,
+			&i.CurrentPeriodEnd,
+			&i.CreatedAt,
+			&i.PastDueSince,
+			&i.Credit,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markSubscriptionPastDue = `-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', past_due_since = COALESCE(past_due_since, $2), updated_at = NOW()
+WHERE id = $1
+RETURNING id, shop_id, plan_name, status, current_period_end, created_at, past_due_since, credit, updated_at
+`
+
+type MarkSubscriptionPastDueParams struct {
+	ID           pgtype.UUID        `json:"id"`
+	PastDueSince pgtype.Timestamptz `json:"past_due_since"`
+}
+
+func (q *Queries) MarkSubscriptionPastDue(ctx context.Context, arg MarkSubscriptionPastDueParams) (Subscription, error) {
+	row := q.db.QueryRow(ctx, markSubscriptionPastDue, arg.ID, arg.PastDueSince)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.PlanName,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CreatedAt,
+		&i.PastDueSince,
+		&i.Credit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const setSubscriptionCredit = `-- name: SetSubscriptionCredit :one
+UPDATE subscriptions
+SET credit = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, shop_id, plan_name, status, current_period_end, created_at, past_due_since, credit, updated_at
+`
+
+type SetSubscriptionCreditParams struct {
+	ID     pgtype.UUID    `json:"id"`
+	Credit pgtype.Numeric `json:"credit"`
+}
+
+func (q *Queries) SetSubscriptionCredit(ctx context.Context, arg SetSubscriptionCreditParams) (Subscription, error) {
+	row := q.db.QueryRow(ctx, setSubscriptionCredit, arg.ID, arg.Credit)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.ShopID,
+		&i.PlanName,
+		&i.Status,
+		&i.CurrentPeriodEnd,
+		&i.CreatedAt,
+		&i.PastDueSince,
+		&i.Credit,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
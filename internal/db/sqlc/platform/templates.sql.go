@@ -0,0 +1,147 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: templates.sql
+
+package platform
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getShopTemplate = `-- name: GetShopTemplate :one
+SELECT key, name, description, shop_id, position, is_active, created_at, updated_at FROM shop_templates
+WHERE key = $1 LIMIT 1
+`
+
+func (q *Queries) GetShopTemplate(ctx context.Context, key string) (ShopTemplate, error) {
+	row := q.db.QueryRow(ctx, getShopTemplate, key)
+	var i ShopTemplate
+	err := row.Scan(
+		&i.Key,
+		&i.Name,
+		&i.Description,
+		&i.ShopID,
+		&i.Position,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listActiveShopTemplates = `-- name: ListActiveShopTemplates :many
+SELECT key, name, description, shop_id, position, is_active, created_at, updated_at FROM shop_templates
+WHERE is_active
+ORDER BY position, key
+`
+
+func (q *Queries) ListActiveShopTemplates(ctx context.Context) ([]ShopTemplate, error) {
+	rows, err := q.db.Query(ctx, listActiveShopTemplates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ShopTemplate{}
+	for rows.Next() {
+		var i ShopTemplate
+		if err := rows.Scan(
+			&i.Key,
+			&i.Name,
+			&i.Description,
+			&i.ShopID,
+			&i.Position,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShopTemplates = `-- name: ListShopTemplates :many
+SELECT key, name, description, shop_id, position, is_active, created_at, updated_at FROM shop_templates
+ORDER BY position, key
+`
+
+func (q *Queries) ListShopTemplates(ctx context.Context) ([]ShopTemplate, error) {
+	rows, err := q.db.Query(ctx, listShopTemplates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ShopTemplate{}
+	for rows.Next() {
+		var i ShopTemplate
+		if err := rows.Scan(
+			&i.Key,
+			&i.Name,
+			&i.Description,
+			&i.ShopID,
+			&i.Position,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertShopTemplate = `-- name: UpsertShopTemplate :one
+INSERT INTO shop_templates (key, name, description, shop_id, position, is_active)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (key) DO UPDATE
+SET name = EXCLUDED.name,
+    description = EXCLUDED.description,
+    shop_id = EXCLUDED.shop_id,
+    position = EXCLUDED.position,
+    is_active = EXCLUDED.is_active,
+    updated_at = NOW()
+RETURNING key, name, description, shop_id, position, is_active, created_at, updated_at
+`
+
+type UpsertShopTemplateParams struct {
+	Key         string      `json:"key"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	ShopID      pgtype.UUID `json:"shop_id"`
+	Position    int32       `json:"position"`
+	IsActive    bool        `json:"is_active"`
+}
+
+func (q *Queries) UpsertShopTemplate(ctx context.Context, arg UpsertShopTemplateParams) (ShopTemplate, error) {
+	row := q.db.QueryRow(ctx, upsertShopTemplate,
+		arg.Key,
+		arg.Name,
+		arg.Description,
+		arg.ShopID,
+		arg.Position,
+		arg.IsActive,
+	)
+	var i ShopTemplate
+	err := row.Scan(
+		&i.Key,
+		&i.Name,
+		&i.Description,
+		&i.ShopID,
+		&i.Position,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
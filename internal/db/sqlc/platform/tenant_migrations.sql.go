@@ -0,0 +1,117 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: tenant_migrations.sql
+
+package platform
+
+import (
+	"context"
+)
+
+const deleteTenantMigration = `-- name: DeleteTenantMigration :exec
+DELETE FROM tenant_migrations
+WHERE tenant_id = $1
+`
+
+func (q *Queries) DeleteTenantMigration(ctx context.Context, tenantID string) error {
+	_, err := q.db.Exec(ctx, deleteTenantMigration, tenantID)
+	return err
+}
+
+const getTenantMigration = `-- name: GetTenantMigration :one
+SELECT tenant_id, version, dirty, last_error, last_run_at, updated_at FROM tenant_migrations
+WHERE tenant_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetTenantMigration(ctx context.Context, tenantID string) (TenantMigration, error) {
+	row := q.db.QueryRow(ctx, getTenantMigration, tenantID)
+	var i TenantMigration
+	err := row.Scan(
+		&i.TenantID,
+		&i.Version,
+		&i.Dirty,
+		&i.LastError,
+		&i.LastRunAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listTenantMigrations = `-- name: ListTenantMigrations :many
+SELECT tenant_id, version, dirty, last_error, last_run_at, updated_at FROM tenant_migrations
+ORDER BY tenant_id
+`
+
+func (q *Queries) ListTenantMigrations(ctx context.Context) ([]TenantMigration, error) {
+	rows, err := q.db.Query(ctx, listTenantMigrations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TenantMigration{}
+	for rows.Next() {
+		var i TenantMigration
+		if err := rows.Scan(
+			&i.TenantID,
+			&i.Version,
+			&i.Dirty,
+			&i.LastError,
+			&i.LastRunAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertTenantMigration = `-- name: UpsertTenantMigration :one
+INSERT INTO tenant_migrations (
+    tenant_id,
+    version,
+    dirty,
+    last_error,
+    last_run_at,
+    updated_at
+) VALUES (
+    $1, $2, $3, $4, NOW(), NOW()
+)
+ON CONFLICT (tenant_id) DO UPDATE SET
+    version = EXCLUDED.version,
+    dirty = EXCLUDED.dirty,
+    last_error = EXCLUDED.last_error,
+    last_run_at = NOW(),
+    updated_at = NOW()
+RETURNING tenant_id, version, dirty, last_error, last_run_at, updated_at
+`
+
+type UpsertTenantMigrationParams struct {
+	TenantID  string  `json:"tenant_id"`
+	Version   int64   `json:"version"`
+	Dirty     bool    `json:"dirty"`
+	LastError *string `json:"last_error"`
+}
+
+func (q *Queries) UpsertTenantMigration(ctx context.Context, arg UpsertTenantMigrationParams) (TenantMigration, error) {
+	row := q.db.QueryRow(ctx, upsertTenantMigration,
+		arg.TenantID,
+		arg.Version,
+		arg.Dirty,
+		arg.LastError,
+	)
+	var i TenantMigration
+	err := row.Scan(
+		&i.TenantID,
+		&i.Version,
+		&i.Dirty,
+		&i.LastError,
+		&i.LastRunAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
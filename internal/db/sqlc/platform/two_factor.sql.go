@@ -0,0 +1,142 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: two_factor.sql
+
+package platform
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const disableTOTP = `-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) DisableTOTP(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, disableTOTP, id)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled_at = now(), totp_last_step = $2, updated_at = now()
+WHERE id = $1 AND totp_secret IS NOT NULL
+`
+
+type EnableTOTPParams struct {
+	ID           pgtype.UUID `json:"id"`
+	TotpLastStep int64       `json:"totp_last_step"`
+}
+
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) error {
+	_, err := q.db.Exec(ctx, enableTOTP, arg.ID, arg.TotpLastStep)
+	return err
+}
+
+const getUserTwoFactor = `-- name: GetUserTwoFactor :one
+SELECT
+    u.totp_secret,
+    u.totp_enabled_at,
+    u.totp_last_step,
+    (SELECT count(*) FROM platform_recovery_codes r WHERE r.user_id = u.id AND r.used_at IS NULL) AS recovery_codes_left
+FROM users u
+WHERE u.id = $1
+`
+
+type GetUserTwoFactorRow struct {
+	TotpSecret        *string            `json:"totp_secret"`
+	TotpEnabledAt     pgtype.Timestamptz `json:"totp_enabled_at"`
+	TotpLastStep      int64              `json:"totp_last_step"`
+	RecoveryCodesLeft int64              `json:"recovery_codes_left"`
+}
+
+func (q *Queries) GetUserTwoFactor(ctx context.Context, id pgtype.UUID) (GetUserTwoFactorRow, error) {
+	row := q.db.QueryRow(ctx, getUserTwoFactor, id)
+	var i GetUserTwoFactorRow
+	err := row.Scan(
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.RecoveryCodesLeft,
+	)
+	return i, err
+}
+
+const replaceRecoveryCodes = `-- name: ReplaceRecoveryCodes :exec
+WITH removed AS (
+    DELETE FROM platform_recovery_codes WHERE platform_recovery_codes.user_id = $1
+)
+INSERT INTO platform_recovery_codes (user_id, code_hash)
+SELECT $1, unnest($2::text[])
+`
+
+type ReplaceRecoveryCodesParams struct {
+	UserID     pgtype.UUID `json:"user_id"`
+	CodeHashes []string    `json:"code_hashes"`
+}
+
+func (q *Queries) ReplaceRecoveryCodes(ctx context.Context, arg ReplaceRecoveryCodesParams) error {
+	_, err := q.db.Exec(ctx, replaceRecoveryCodes, arg.UserID, arg.CodeHashes)
+	return err
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled_at = NULL, totp_last_step = 0, updated_at = now()
+WHERE id = $1
+`
+
+type SetTOTPSecretParams struct {
+	ID         pgtype.UUID `json:"id"`
+	TotpSecret *string     `json:"totp_secret"`
+}
+
+// Starts (or restarts) enrollment; two-factor stays off until EnableTOTP
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error {
+	_, err := q.db.Exec(ctx, setTOTPSecret, arg.ID, arg.TotpSecret)
+	return err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+UPDATE platform_recovery_codes
+SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	UserID   pgtype.UUID `json:"user_id"`
+	CodeHash string      `json:"code_hash"`
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useRecoveryCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND totp_enabled_at IS NOT NULL AND totp_last_step < $2
+`
+
+type UseTOTPStepParams struct {
+	ID           pgtype.UUID `json:"id"`
+	TotpLastStep int64       `json:"totp_last_step"`
+}
+
+// Accepts a code's time step once: later codes only
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useTOTPStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -12,7 +12,15 @@ import (
 
 type Querier interface {
 	AddCartItem(ctx context.Context, arg AddCartItemParams) (CartItem, error)
+	ArchiveVariant(ctx context.Context, id pgtype.UUID) (int64, error)
 	ClearCart(ctx context.Context, cartID pgtype.UUID) error
+	// Marks the token used and returns it, once, while it has not expired
+	ConsumeUserToken(ctx context.Context, arg ConsumeUserTokenParams) (UserToken, error)
+	CountOrdersSince(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error)
+	CountProducts(ctx context.Context) (int64, error)
+	CountStaffUsers(ctx context.Context) (int64, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
+	CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) (AuditLog, error)
 	CreateCart(ctx context.Context, arg CreateCartParams) (Cart, error)
 	// Categories
 	CreateCategory(ctx context.Context, arg CreateCategoryParams) (Category, error)
@@ -20,22 +28,39 @@ type Querier interface {
 	CreateOrderItem(ctx context.Context, arg CreateOrderItemParams) (OrderItem, error)
 	CreatePage(ctx context.Context, arg CreatePageParams) (Page, error)
 	CreatePaymentGateway(ctx context.Context, arg CreatePaymentGatewayParams) (PaymentGateway, error)
+	// Passwordless customer signed up with a phone code
+	CreatePhoneUser(ctx context.Context, arg CreatePhoneUserParams) (User, error)
+	// Staff account of a platform user, signed in through handoffs only
+	CreatePlatformUser(ctx context.Context, arg CreatePlatformUserParams) (User, error)
 	// Products
 	CreateProduct(ctx context.Context, arg CreateProductParams) (Product, error)
+	// Options
+	CreateProductOption(ctx context.Context, arg CreateProductOptionParams) (ProductOption, error)
 	// Variants
 	CreateProductVariant(ctx context.Context, arg CreateProductVariantParams) (ProductVariant, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	// Passwordless customer signed up with an external identity. The address
+	// counts as verified when the provider verified it.
+	CreateSocialUser(ctx context.Context, arg CreateSocialUserParams) (User, error)
 	CreateStoreConfig(ctx context.Context, arg CreateStoreConfigParams) (StoreConfig, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) error
+	CreateUserToken(ctx context.Context, arg CreateUserTokenParams) (UserToken, error)
 	DeleteCart(ctx context.Context, id pgtype.UUID) error
 	DeleteCategory(ctx context.Context, id pgtype.UUID) error
 	DeleteExpiredSessions(ctx context.Context) error
 	DeletePaymentGateway(ctx context.Context, id string) error
-	DeleteProduct(ctx context.Context, id pgtype.UUID) error
+	DeleteProduct(ctx context.Context, id pgtype.UUID) (int64, error)
+	DeleteProductOption(ctx context.Context, id pgtype.UUID) (int64, error)
 	DeleteSession(ctx context.Context, token string) error
 	DeleteStoreConfig(ctx context.Context, key string) error
 	DeleteUser(ctx context.Context, id pgtype.UUID) error
-	DeleteVariant(ctx context.Context, id pgtype.UUID) error
+	DeleteUserIdentities(ctx context.Context, userID pgtype.UUID) error
+	DeleteUserSessions(ctx context.Context, userID pgtype.UUID) error
+	DeleteVariant(ctx context.Context, id pgtype.UUID) (int64, error)
+	DisableTOTP(ctx context.Context, id pgtype.UUID) error
+	EnableTOTP(ctx context.Context, arg EnableTOTPParams) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (ApiKey, error)
 	GetCartBySession(ctx context.Context, sessionID pgtype.UUID) (Cart, error)
 	GetCartByUser(ctx context.Context, userID pgtype.UUID) (Cart, error)
 	GetCartItems(ctx context.Context, cartID pgtype.UUID) ([]GetCartItemsRow, error)
@@ -46,22 +71,60 @@ type Querier interface {
 	GetPaymentGateway(ctx context.Context, id string) (PaymentGateway, error)
 	GetProduct(ctx context.Context, id pgtype.UUID) (Product, error)
 	GetProductBySlug(ctx context.Context, slug string) (Product, error)
+	GetProductOption(ctx context.Context, id pgtype.UUID) (ProductOption, error)
 	GetProductVariant(ctx context.Context, id pgtype.UUID) (ProductVariant, error)
 	GetSession(ctx context.Context, token string) (Session, error)
 	GetStoreConfig(ctx context.Context, key string) (StoreConfig, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserById(ctx context.Context, id pgtype.UUID) (User, error)
+	GetUserByIdentity(ctx context.Context, arg GetUserByIdentityParams) (User, error)
 	GetUserByPhone(ctx context.Context, phone *string) (User, error)
+	GetUserByPlatformID(ctx context.Context, platformUserID pgtype.UUID) (User, error)
+	GetUserByVerifiedPhone(ctx context.Context, phone *string) (User, error)
+	GetUserTwoFactor(ctx context.Context, id pgtype.UUID) (GetUserTwoFactorRow, error)
+	// Links an account registered in the shop to the platform user with its
+	// email. Unless the email was verified, the account's password and phone
+	// are dropped: whoever registered it may not be the platform user.
+	LinkPlatformUser(ctx context.Context, arg LinkPlatformUserParams) (User, error)
+	ListAPIKeys(ctx context.Context) ([]ApiKey, error)
+	ListArchivedVariantsByProduct(ctx context.Context, productID pgtype.UUID) ([]ProductVariant, error)
+	// Newest first. Filters are optional; action matches a prefix ("product."),
+	// actor an email, an API key name or an actor ID. Pages continue before
+	// the (created_at, id) of the last entry.
+	ListAuditLog(ctx context.Context, arg ListAuditLogParams) ([]ListAuditLogRow, error)
 	ListCategories(ctx context.Context) ([]Category, error)
 	ListFeaturedProducts(ctx context.Context, limit int32) ([]Product, error)
 	ListNewArrivals(ctx context.Context, limit int32) ([]Product, error)
 	ListOrdersByUser(ctx context.Context, userID pgtype.UUID) ([]Order, error)
 	ListPages(ctx context.Context) ([]Page, error)
 	ListPaymentGateways(ctx context.Context) ([]PaymentGateway, error)
+	ListProductOptions(ctx context.Context, productID pgtype.UUID) ([]ProductOption, error)
 	ListProducts(ctx context.Context) ([]Product, error)
 	ListStoreConfigs(ctx context.Context) ([]StoreConfig, error)
 	ListVariantsByProduct(ctx context.Context, productID pgtype.UUID) ([]ProductVariant, error)
+	MarkEmailVerified(ctx context.Context, id pgtype.UUID) error
+	// Adds the items of one cart to another, summing quantities
+	MoveCartItems(ctx context.Context, arg MoveCartItemsParams) error
+	ReassignUserCarts(ctx context.Context, arg ReassignUserCartsParams) error
+	ReassignUserOrders(ctx context.Context, arg ReassignUserOrdersParams) error
+	// Frees an archived variant's SKU for the variant taking its place
+	ReleaseVariantSKU(ctx context.Context, id pgtype.UUID) error
 	RemoveCartItem(ctx context.Context, arg RemoveCartItemParams) error
+	RenameVariantOption(ctx context.Context, arg RenameVariantOptionParams) error
+	RenameVariantOptionValue(ctx context.Context, arg RenameVariantOptionValueParams) error
+	ReplaceRecoveryCodes(ctx context.Context, arg ReplaceRecoveryCodesParams) error
+	RevokeAPIKey(ctx context.Context, id pgtype.UUID) (int64, error)
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error
+	// Starts (or restarts) enrollment; two-factor stays off until EnableTOTP
+	SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error
+	SetUserAccess(ctx context.Context, arg SetUserAccessParams) (User, error)
+	// Places a variant in the product's matrix, restoring it if archived
+	SetVariantOptions(ctx context.Context, arg SetVariantOptionsParams) (ProductVariant, error)
+	SetVariantPosition(ctx context.Context, arg SetVariantPositionParams) (int64, error)
+	SetVerifiedPhone(ctx context.Context, arg SetVerifiedPhoneParams) (User, error)
+	// Recorded at most once a minute, not on every request
+	TouchAPIKey(ctx context.Context, id pgtype.UUID) error
+	TouchUserIdentity(ctx context.Context, arg TouchUserIdentityParams) error
 	UpdateCartItemQuantity(ctx context.Context, arg UpdateCartItemQuantityParams) (CartItem, error)
 	UpdateCartUser(ctx context.Context, arg UpdateCartUserParams) error
 	UpdateCategory(ctx context.Context, arg UpdateCategoryParams) (Category, error)
@@ -70,8 +133,14 @@ type Querier interface {
 	UpdatePassword(ctx context.Context, arg UpdatePasswordParams) error
 	UpdatePaymentGateway(ctx context.Context, arg UpdatePaymentGatewayParams) (PaymentGateway, error)
 	UpdateProduct(ctx context.Context, arg UpdateProductParams) (Product, error)
+	UpdateProductOption(ctx context.Context, arg UpdateProductOptionParams) (ProductOption, error)
+	// compare_at_price is replaced as given: NULL removes it
+	UpdateProductVariant(ctx context.Context, arg UpdateProductVariantParams) (ProductVariant, error)
 	UpdateStoreConfig(ctx context.Context, arg UpdateStoreConfigParams) (StoreConfig, error)
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
+	// Accepts a code's time step once: later codes only
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
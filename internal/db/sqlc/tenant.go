@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"bizbundl/internal/infra/redis"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SetSearchPath scopes the transaction to the tenant schema (public stays as fallback)
+func SetSearchPath(ctx context.Context, tx pgx.Tx, tenantID string) error {
+	_, err := tx.Exec(ctx, "SET LOCAL search_path TO "+pgx.Identifier{tenantID}.Sanitize()+", public")
+	return err
+}
+
+// WithTenant runs fn inside a transaction scoped to the tenant schema.
+// It mirrors what TenancyMiddleware does for HTTP requests, for background jobs
+// and platform code that needs to touch a tenant's data.
+func WithTenant(ctx context.Context, pool *pgxpool.Pool, tenantID string, fn func(ctx context.Context) error) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin tenant tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := SetSearchPath(ctx, tx, tenantID); err != nil {
+		return fmt.Errorf("failed to set search_path for %s: %w", tenantID, err)
+	}
+
+	tenantCtx := context.WithValue(ctx, TxKey, tx)
+	tenantCtx = context.WithValue(tenantCtx, redis.TenantKey, tenantID)
+	if err := fn(tenantCtx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,152 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: user_identities.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createSocialUser = `-- name: CreateSocialUser :one
+INSERT INTO users (
+    email,
+    email_verified_at,
+    password_hash,
+    first_name,
+    last_name,
+    role
+) VALUES (
+    $1, $2, '', $3, $4, 'customer'
+) RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id
+`
+
+type CreateSocialUserParams struct {
+	Email           *string            `json:"email"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+	FirstName       string             `json:"first_name"`
+	LastName        string             `json:"last_name"`
+}
+
+// Passwordless customer signed up with an external identity. The address
+// counts as verified when the provider verified it.
+func (q *Queries) CreateSocialUser(ctx context.Context, arg CreateSocialUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, createSocialUser,
+		arg.Email,
+		arg.EmailVerifiedAt,
+		arg.FirstName,
+		arg.LastName,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
+const createUserIdentity = `-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (
+    user_id,
+    provider,
+    subject,
+    email
+) VALUES (
+    $1, $2, $3, $4
+)
+`
+
+type CreateUserIdentityParams struct {
+	UserID   pgtype.UUID `json:"user_id"`
+	Provider string      `json:"provider"`
+	Subject  string      `json:"subject"`
+	Email    *string     `json:"email"`
+}
+
+func (q *Queries) CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) error {
+	_, err := q.db.Exec(ctx, createUserIdentity,
+		arg.UserID,
+		arg.Provider,
+		arg.Subject,
+		arg.Email,
+	)
+	return err
+}
+
+const deleteUserIdentities = `-- name: DeleteUserIdentities :exec
+DELETE FROM user_identities
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserIdentities(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteUserIdentities, userID)
+	return err
+}
+
+const getUserByIdentity = `-- name: GetUserByIdentity :one
+SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id FROM users
+WHERE id = (SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2)
+`
+
+type GetUserByIdentityParams struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+}
+
+func (q *Queries) GetUserByIdentity(ctx context.Context, arg GetUserByIdentityParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByIdentity, arg.Provider, arg.Subject)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
+const touchUserIdentity = `-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET email = $3, last_login_at = now()
+WHERE provider = $1 AND subject = $2
+`
+
+type TouchUserIdentityParams struct {
+	Provider string  `json:"provider"`
+	Subject  string  `json:"subject"`
+	Email    *string `json:"email"`
+}
+
+func (q *Queries) TouchUserIdentity(ctx context.Context, arg TouchUserIdentityParams) error {
+	_, err := q.db.Exec(ctx, touchUserIdentity, arg.Provider, arg.Subject, arg.Email)
+	return err
+}
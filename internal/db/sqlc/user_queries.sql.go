@@ -11,6 +11,113 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countStaffUsers = `-- name: CountStaffUsers :one
+SELECT COUNT(*) FROM users
+WHERE role IN ('admin', 'staff')
+`
+
+func (q *Queries) CountStaffUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, countStaffUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createPhoneUser = `-- name: CreatePhoneUser :one
+INSERT INTO users (
+    phone,
+    phone_verified_at,
+    password_hash,
+    first_name,
+    last_name,
+    role
+) VALUES (
+    $1, now(), '', $2, '', 'customer'
+) RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id
+`
+
+type CreatePhoneUserParams struct {
+	Phone     *string `json:"phone"`
+	FirstName string  `json:"first_name"`
+}
+
+// Passwordless customer signed up with a phone code
+func (q *Queries) CreatePhoneUser(ctx context.Context, arg CreatePhoneUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, createPhoneUser, arg.Phone, arg.FirstName)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
+const createPlatformUser = `-- name: CreatePlatformUser :one
+INSERT INTO users (
+    email,
+    password_hash,
+    first_name,
+    last_name,
+    role,
+    platform_user_id
+) VALUES (
+    $1, '!', $2, $3, $4, $5
+) RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id
+`
+
+type CreatePlatformUserParams struct {
+	Email          *string     `json:"email"`
+	FirstName      string      `json:"first_name"`
+	LastName       string      `json:"last_name"`
+	Role           UserRole    `json:"role"`
+	PlatformUserID pgtype.UUID `json:"platform_user_id"`
+}
+
+// Staff account of a platform user, signed in through handoffs only
+func (q *Queries) CreatePlatformUser(ctx context.Context, arg CreatePlatformUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, createPlatformUser,
+		arg.Email,
+		arg.FirstName,
+		arg.LastName,
+		arg.Role,
+		arg.PlatformUserID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     email,
@@ -21,11 +128,11 @@ INSERT INTO users (
     role
 ) VALUES (
     $1, $2, $3, $4, $5, $6
-) RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at
+) RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id
 `
 
 type CreateUserParams struct {
-	Email        string   `json:"email"`
+	Email        *string  `json:"email"`
 	PasswordHash string   `json:"password_hash"`
 	FirstName    string   `json:"first_name"`
 	LastName     string   `json:"last_name"`
@@ -54,6 +161,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Phone,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
 	)
 	return i, err
 }
@@ -69,8 +182,8 @@ func (q *Queries) DeleteUser(ctx context.Context, id pgtype.UUID) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at FROM users
-WHERE email = $1 LIMIT 1
+SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id FROM users
+WHERE email = $1::varchar LIMIT 1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -87,12 +200,18 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Phone,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at FROM users
+SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id FROM users
 WHERE id = $1 LIMIT 1
 `
 
@@ -110,12 +229,18 @@ func (q *Queries) GetUserById(ctx context.Context, id pgtype.UUID) (User, error)
 		&i.Phone,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
 	)
 	return i, err
 }
 
 const getUserByPhone = `-- name: GetUserByPhone :one
-SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at FROM users
+SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id FROM users
 WHERE phone = $1 LIMIT 1
 `
 
@@ -133,6 +258,197 @@ func (q *Queries) GetUserByPhone(ctx context.Context, phone *string) (User, erro
 		&i.Phone,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
+const getUserByPlatformID = `-- name: GetUserByPlatformID :one
+SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id FROM users
+WHERE platform_user_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByPlatformID(ctx context.Context, platformUserID pgtype.UUID) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByPlatformID, platformUserID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
+const getUserByVerifiedPhone = `-- name: GetUserByVerifiedPhone :one
+SELECT id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id FROM users
+WHERE phone = $1 AND phone_verified_at IS NOT NULL LIMIT 1
+`
+
+func (q *Queries) GetUserByVerifiedPhone(ctx context.Context, phone *string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByVerifiedPhone, phone)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
+const linkPlatformUser = `-- name: LinkPlatformUser :one
+UPDATE users
+SET platform_user_id = $2,
+    password_hash = CASE WHEN email_verified_at IS NULL THEN '!' ELSE password_hash END,
+    phone = CASE WHEN email_verified_at IS NULL THEN NULL ELSE phone END,
+    phone_verified_at = CASE WHEN email_verified_at IS NULL THEN NULL ELSE phone_verified_at END,
+    updated_at = now()
+WHERE id = $1 AND platform_user_id IS NULL
+RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id
+`
+
+type LinkPlatformUserParams struct {
+	ID             pgtype.UUID `json:"id"`
+	PlatformUserID pgtype.UUID `json:"platform_user_id"`
+}
+
+// Links an account registered in the shop to the platform user with its
+// email. Unless the email was verified, the account's password and phone
+// are dropped: whoever registered it may not be the platform user.
+func (q *Queries) LinkPlatformUser(ctx context.Context, arg LinkPlatformUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, linkPlatformUser, arg.ID, arg.PlatformUserID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :exec
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, now()), updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, markEmailVerified, id)
+	return err
+}
+
+const setUserAccess = `-- name: SetUserAccess :one
+UPDATE users
+SET role = $2, permissions = $3, updated_at = now()
+WHERE id = $1
+RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id
+`
+
+type SetUserAccessParams struct {
+	ID          pgtype.UUID `json:"id"`
+	Role        UserRole    `json:"role"`
+	Permissions []byte      `json:"permissions"`
+}
+
+func (q *Queries) SetUserAccess(ctx context.Context, arg SetUserAccessParams) (User, error) {
+	row := q.db.QueryRow(ctx, setUserAccess, arg.ID, arg.Role, arg.Permissions)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
+	)
+	return i, err
+}
+
+const setVerifiedPhone = `-- name: SetVerifiedPhone :one
+UPDATE users
+SET phone = $2, phone_verified_at = now(), updated_at = now()
+WHERE id = $1
+RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id
+`
+
+type SetVerifiedPhoneParams struct {
+	ID    pgtype.UUID `json:"id"`
+	Phone *string     `json:"phone"`
+}
+
+func (q *Queries) SetVerifiedPhone(ctx context.Context, arg SetVerifiedPhoneParams) (User, error) {
+	row := q.db.QueryRow(ctx, setVerifiedPhone, arg.ID, arg.Phone)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.FirstName,
+		&i.LastName,
+		&i.Role,
+		&i.Permissions,
+		&i.Phone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
 	)
 	return i, err
 }
@@ -163,7 +479,7 @@ SET
     role = COALESCE($6, role),
     updated_at = now()
 WHERE id = $1
-RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at
+RETURNING id, email, password_hash, first_name, last_name, role, permissions, phone, created_at, updated_at, email_verified_at, phone_verified_at, totp_secret, totp_enabled_at, totp_last_step, platform_user_id
 `
 
 type UpdateUserParams struct {
@@ -196,6 +512,12 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Phone,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+		&i.PhoneVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.PlatformUserID,
 	)
 	return i, err
 }
@@ -0,0 +1,362 @@
+// Package entitlements enforces what a shop's subscription plan allows.
+//
+// Plans (public.plans) cap countable resources (products, staff accounts,
+// storage, orders per month) and gate features (custom domains, page builder
+// components). Services ask before they mutate tenant data:
+//
+//	if err := s.entitlements.Check(ctx, entitlements.Products, 1); err != nil {
+//		return db.Product{}, err
+//	}
+//
+// The plan comes from the request's tenant (tenancy.FromContext). Outside a
+// tenant request (seeding, provisioning, workers) nothing is enforced, and a
+// nil *Service enforces nothing either.
+package entitlements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	db "bizbundl/internal/db/sqlc"
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultPlan applies to shops without a subscription
+const DefaultPlan = "starter"
+
+// plans change rarely, every node rereads them after this long
+const planCacheTTL = time.Minute
+
+var ErrUpgradeRequired = errors.New("plan upgrade required")
+
+// Limit is a countable resource capped by the plan
+type Limit string
+
+const (
+	Products      Limit = "products"
+	Staff         Limit = "staff"
+	Storage       Limit = "storage"
+	MonthlyOrders Limit = "monthly_orders"
+)
+
+// Limits in dashboard order
+var Limits = []Limit{Products, Staff, Storage, MonthlyOrders}
+
+var limitLabels = map[Limit]string{
+	Products:      "products",
+	Staff:         "staff accounts",
+	Storage:       "storage",
+	MonthlyOrders: "orders per month",
+}
+
+// Label is the human name of the limit
+func (l Limit) Label() string {
+	return limitLabels[l]
+}
+
+// Format renders an amount of the limit, e.g. "100 products" or "1.0 GB of storage"
+func (l Limit) Format(n int64) string {
+	if l == Storage {
+		return formatBytes(n) + " of storage"
+	}
+	return fmt.Sprintf("%d %s", n, l.Label())
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// UpgradeError explains what the plan does not allow. It matches ErrUpgradeRequired.
+type UpgradeError struct {
+	Plan    string
+	Limit   Limit  // set for limits
+	Max     int64  // the plan's limit
+	Used    int64  // current usage
+	Feature string // set for features
+}
+
+func (e *UpgradeError) Error() string {
+	if e.Feature != "" {
+		return fmt.Sprintf("%s is not included in the %s plan, upgrade to use it", e.Feature, e.Plan)
+	}
+	return fmt.Sprintf("the %s plan allows %s, upgrade to add more", e.Plan, e.Limit.Format(e.Max))
+}
+
+func (e *UpgradeError) Unwrap() error {
+	return ErrUpgradeRequired
+}
+
+// PlanStore is the subset of platform queries the service needs
+type PlanStore interface {
+	GetPlan(ctx context.Context, key string) (platformdb.Plan, error)
+	GetLatestSubscriptionByShop(ctx context.Context, shopID pgtype.UUID) (platformdb.Subscription, error)
+}
+
+type cachedPlan struct {
+	plan    platformdb.Plan
+	expires time.Time
+}
+
+// Service answers what the current shop may do
+type Service struct {
+	plans     PlanStore
+	store     db.DBStore // tenant queries, scoped by the request context
+	uploadDir string
+
+	mu    sync.Mutex
+	cache map[string]cachedPlan
+}
+
+func NewService(plans PlanStore, store db.DBStore, uploadDir string) *Service {
+	return &Service{
+		plans:     plans,
+		store:     store,
+		uploadDir: uploadDir,
+		cache:     map[string]cachedPlan{},
+	}
+}
+
+// Plan returns the plan with the given key ("" = DefaultPlan).
+// Unknown keys fall back to DefaultPlan rather than locking the shop.
+func (s *Service) Plan(ctx context.Context, key string) (platformdb.Plan, error) {
+	if key == "" {
+		key = DefaultPlan
+	}
+
+	s.mu.Lock()
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.plan, nil
+	}
+
+	plan, err := s.plans.GetPlan(ctx, key)
+	if errors.Is(err, pgx.ErrNoRows) && key != DefaultPlan {
+		log.Warn().Str("plan", key).Msg("unknown plan, applying the default plan")
+		return s.Plan(ctx, DefaultPlan)
+	}
+	if err != nil {
+		return platformdb.Plan{}, fmt.Errorf("failed to get plan %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	s.cache[key] = cachedPlan{plan: plan, expires: time.Now().Add(planCacheTTL)}
+	s.mu.Unlock()
+	return plan, nil
+}
+
+// ShopPlan returns the plan of the shop's latest subscription
+func (s *Service) ShopPlan(ctx context.Context, shopID pgtype.UUID) (platformdb.Plan, error) {
+	sub, err := s.plans.GetLatestSubscriptionByShop(ctx, shopID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return platformdb.Plan{}, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return s.Plan(ctx, sub.PlanName)
+}
+
+// current returns the plan of the request's tenant, ok is false outside
+// tenant requests
+func (s *Service) current(ctx context.Context) (plan platformdb.Plan, tenant *tenancy.Tenant, ok bool, err error) {
+	if s == nil {
+		return plan, nil, false, nil
+	}
+	tenant = tenancy.FromContext(ctx)
+	if tenant == nil || tenant.IsPlatform() {
+		return plan, nil, false, nil
+	}
+	plan, err = s.Plan(ctx, tenant.Plan)
+	return plan, tenant, err == nil, err
+}
+
+// Check returns an *UpgradeError if adding n more of limit would exceed the
+// plan. The count and the insert are not atomic, so concurrent requests may
+// overshoot by a few; limits are commercial, not safety, bounds.
+func (s *Service) Check(ctx context.Context, limit Limit, n int64) error {
+	plan, tenant, ok, err := s.current(ctx)
+	if !ok || err != nil {
+		return err
+	}
+	max := Max(plan, limit)
+	if max == nil {
+		return nil
+	}
+	used, err := s.used(ctx, tenant.TenantID, limit)
+	if err != nil {
+		return err
+	}
+	if used+n > *max {
+		return &UpgradeError{Plan: plan.Name, Limit: limit, Max: *max, Used: used}
+	}
+	return nil
+}
+
+// CheckComponents returns an *UpgradeError if the plan does not include one
+// of the page builder component types
+func (s *Service) CheckComponents(ctx context.Context, types []string) error {
+	plan, _, ok, err := s.current(ctx)
+	if !ok || err != nil {
+		return err
+	}
+	for _, t := range types {
+		if !AllowsComponent(plan, t) {
+			return &UpgradeError{Plan: plan.Name, Feature: fmt.Sprintf("The %q section", t)}
+		}
+	}
+	return nil
+}
+
+// CheckCustomDomain returns an *UpgradeError if the plan has no custom domains
+func CheckCustomDomain(plan platformdb.Plan) error {
+	if !plan.CustomDomain {
+		return &UpgradeError{Plan: plan.Name, Feature: "A custom domain"}
+	}
+	return nil
+}
+
+// AllowsComponent reports whether the plan includes the component type
+func AllowsComponent(plan platformdb.Plan, componentType string) bool {
+	return plan.PageBuilderComponents == nil || slices.Contains(plan.PageBuilderComponents, componentType)
+}
+
+// Max returns the plan's cap for limit, nil = unlimited
+func Max(plan platformdb.Plan, limit Limit) *int64 {
+	switch limit {
+	case Products:
+		return widen(plan.MaxProducts)
+	case Staff:
+		return widen(plan.MaxStaff)
+	case Storage:
+		return plan.MaxStorageBytes
+	case MonthlyOrders:
+		return widen(plan.MaxMonthlyOrders)
+	}
+	return nil
+}
+
+func widen(v *int32) *int64 {
+	if v == nil {
+		return nil
+	}
+	n := int64(*v)
+	return &n
+}
+
+// Usage is one resource's consumption against the plan
+type Usage struct {
+	Limit Limit  `json:"limit"`
+	Used  int64  `json:"used"`
+	Max   *int64 `json:"max"` // nil = unlimited
+}
+
+// Summary renders the usage as "12 / 100", "3.5 MB / 1.0 GB" or "12 / unlimited"
+func (u Usage) Summary() string {
+	amount := func(n int64) string {
+		if u.Limit == Storage {
+			return formatBytes(n)
+		}
+		return fmt.Sprint(n)
+	}
+	if u.Max == nil {
+		return amount(u.Used) + " / unlimited"
+	}
+	return amount(u.Used) + " / " + amount(*u.Max)
+}
+
+// Percent is the share of the limit used, 0 for unlimited
+func (u Usage) Percent() int {
+	if u.Max == nil || *u.Max <= 0 {
+		return 0
+	}
+	return int(min(100, u.Used*100 / *u.Max))
+}
+
+// Report is a shop's plan and its usage, for the owner dashboard
+type Report struct {
+	Plan  platformdb.Plan
+	Usage []Usage
+}
+
+// Usage reports the tenant's consumption of every limit. ctx must be scoped
+// to the tenant (see db.WithTenant).
+func (s *Service) Usage(ctx context.Context, plan platformdb.Plan, tenantID string) ([]Usage, error) {
+	usage := make([]Usage, 0, len(Limits))
+	for _, limit := range Limits {
+		used, err := s.used(ctx, tenantID, limit)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, Usage{Limit: limit, Used: used, Max: Max(plan, limit)})
+	}
+	return usage, nil
+}
+
+func (s *Service) used(ctx context.Context, tenantID string, limit Limit) (int64, error) {
+	var (
+		n   int64
+		err error
+	)
+	switch limit {
+	case Products:
+		n, err = s.store.CountProducts(ctx)
+	case Staff:
+		n, err = s.store.CountStaffUsers(ctx)
+	case MonthlyOrders:
+		n, err = s.store.CountOrdersSince(ctx, pgtype.Timestamptz{Time: monthStart(time.Now()), Valid: true})
+	case Storage:
+		n, err = dirSize(filepath.Join(s.uploadDir, tenantID))
+	default:
+		return 0, fmt.Errorf("unknown limit %q", limit)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", limit.Label(), err)
+	}
+	return n, nil
+}
+
+// monthStart is the start of the billing month (calendar month, UTC)
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// dirSize sums the size of the files under dir, 0 if it does not exist
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
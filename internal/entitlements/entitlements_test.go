@@ -0,0 +1,124 @@
+package entitlements
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePlans serves plans from a map and counts lookups
+type fakePlans struct {
+	plans   map[string]platformdb.Plan
+	lookups int
+}
+
+func (f *fakePlans) GetPlan(ctx context.Context, key string) (platformdb.Plan, error) {
+	f.lookups++
+	plan, ok := f.plans[key]
+	if !ok {
+		return platformdb.Plan{}, pgx.ErrNoRows
+	}
+	return plan, nil
+}
+
+func (f *fakePlans) GetLatestSubscriptionByShop(ctx context.Context, shopID pgtype.UUID) (platformdb.Subscription, error) {
+	return platformdb.Subscription{}, pgx.ErrNoRows
+}
+
+func int64Ptr(n int64) *int64 { return &n }
+
+func newTestService(t *testing.T) (*Service, *fakePlans) {
+	plans := &fakePlans{plans: map[string]platformdb.Plan{
+		"starter": {Key: "starter", Name: "Starter", MaxStorageBytes: int64Ptr(1024), PageBuilderComponents: []string{"hero"}},
+		"pro":     {Key: "pro", Name: "Pro", CustomDomain: true},
+	}}
+	return NewService(plans, nil, t.TempDir()), plans
+}
+
+func withTenant(plan string) context.Context {
+	return context.WithValue(context.Background(), tenancy.ContextKey, &tenancy.Tenant{TenantID: "shop_neon", Plan: plan})
+}
+
+func TestPlan(t *testing.T) {
+	s, plans := newTestService(t)
+	ctx := context.Background()
+
+	plan, err := s.Plan(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, "starter", plan.Key)
+
+	// Unknown plans fall back to the default rather than locking the shop
+	plan, err = s.Plan(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "starter", plan.Key)
+
+	// Plans are cached
+	lookups := plans.lookups
+	_, err = s.Plan(ctx, "pro")
+	require.NoError(t, err)
+	_, err = s.Plan(ctx, "pro")
+	require.NoError(t, err)
+	assert.Equal(t, lookups+1, plans.lookups)
+}
+
+func TestCheckStorage(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := withTenant("starter")
+
+	// Nothing uploaded yet
+	require.NoError(t, s.Check(ctx, Storage, 1024))
+	err := s.Check(ctx, Storage, 1025)
+	assert.ErrorIs(t, err, ErrUpgradeRequired)
+	assert.EqualError(t, err, "the Starter plan allows 1.0 KB of storage, upgrade to add more")
+
+	dir := filepath.Join(s.uploadDir, "shop_neon", "products")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.jpg"), make([]byte, 1000), 0o644))
+	assert.ErrorIs(t, s.Check(ctx, Storage, 100), ErrUpgradeRequired)
+	require.NoError(t, s.Check(ctx, Storage, 24))
+
+	// Unlimited on pro
+	require.NoError(t, s.Check(withTenant("pro"), Storage, 1<<40))
+}
+
+func TestNothingEnforcedOutsideTenantRequests(t *testing.T) {
+	s, _ := newTestService(t)
+
+	require.NoError(t, s.Check(context.Background(), Storage, 1<<40))
+	require.NoError(t, s.CheckComponents(context.Background(), []string{"checkout_widget"}))
+
+	var none *Service
+	require.NoError(t, none.Check(withTenant("starter"), Storage, 1<<40))
+}
+
+func TestFeatures(t *testing.T) {
+	s, _ := newTestService(t)
+
+	require.NoError(t, s.CheckComponents(withTenant("starter"), []string{"hero"}))
+	assert.ErrorIs(t, s.CheckComponents(withTenant("starter"), []string{"hero", "checkout_widget"}), ErrUpgradeRequired)
+	require.NoError(t, s.CheckComponents(withTenant("pro"), []string{"checkout_widget"}))
+
+	starter, _ := s.Plan(context.Background(), "starter")
+	pro, _ := s.Plan(context.Background(), "pro")
+	assert.ErrorIs(t, CheckCustomDomain(starter), ErrUpgradeRequired)
+	assert.NoError(t, CheckCustomDomain(pro))
+}
+
+func TestUsageSummary(t *testing.T) {
+	assert.Equal(t, "12 / 100", Usage{Limit: Products, Used: 12, Max: int64Ptr(100)}.Summary())
+	assert.Equal(t, "12 / unlimited", Usage{Limit: Products, Used: 12}.Summary())
+	assert.Equal(t, "512 B / 1.5 MB", Usage{Limit: Storage, Used: 512, Max: int64Ptr(1536 * 1024)}.Summary())
+
+	assert.Equal(t, 12, Usage{Limit: Products, Used: 12, Max: int64Ptr(100)}.Percent())
+	assert.Equal(t, 100, Usage{Limit: Products, Used: 120, Max: int64Ptr(100)}.Percent())
+	assert.Equal(t, 0, Usage{Limit: Products, Used: 12}.Percent())
+}
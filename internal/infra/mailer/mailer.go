@@ -0,0 +1,42 @@
+// Package mailer sends transactional email (password resets, email
+// verification). Development writes messages to a file instead.
+package mailer
+
+import (
+	"context"
+	"strings"
+
+	"bizbundl/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer returns the mailer for the environment: SMTP when SMTP_HOST is
+// set, otherwise the file sink at MAIL_SINK_FILE. Development always uses
+// the file sink so no real mail goes out.
+func NewMailer(cfg *config.Config) Mailer {
+	if cfg.Environment == "development" || cfg.SMTPHost == "" {
+		if cfg.Environment != "development" {
+			log.Warn().Str("file", cfg.MailSinkFile).Msg("mailer: SMTP_HOST not set, writing mail to file")
+		}
+		return NewFileMailer(cfg.MailSinkFile)
+	}
+	return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+}
+
+// headerValue drops line breaks so values cannot inject headers
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,26 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail", "outbox.log")
+	m := NewFileMailer(path)
+
+	require.NoError(t, m.Send(context.Background(), PasswordReset("a@example.com", "https://shop.example.com/reset?token=abc", "1 hour")))
+	require.NoError(t, m.Send(context.Background(), Message{To: "b@example.com\r\nBcc: x@evil.test", Subject: "Hi", Text: "Body"}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	out := string(data)
+	assert.Contains(t, out, "To: a@example.com\n")
+	assert.Contains(t, out, "https://shop.example.com/reset?token=abc")
+	assert.Contains(t, out, "To: b@example.comBcc: x@evil.test\n")
+}
@@ -0,0 +1,88 @@
+package mailer
+
+import "fmt"
+
+// PasswordReset is the email with a password reset link
+func PasswordReset(to, link, valid string) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf(`Someone asked to reset the password for this email address.
+
+To choose a new password, open this link within %s:
+
+%s
+
+If it wasn't you, ignore this email; your password stays the same.`, valid, link),
+	}
+}
+
+// EmailVerification is the email with an address confirmation link
+func EmailVerification(to, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Confirm your email address",
+		Text: fmt.Sprintf(`Please confirm this is your email address by opening this link:
+
+%s
+
+If you did not create an account, you can ignore this email.`, link),
+	}
+}
+
+// AccountLocked is the email sent when failed sign-ins lock an account, with
+// a link that unlocks it
+func AccountLocked(to, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Your account has been locked",
+		Text: fmt.Sprintf(`There were too many failed attempts to sign in to your account, so we've locked it for a while.
+
+If it was you, open this link to unlock it now:
+
+%s
+
+If it wasn't you, someone may be guessing your password. Your account is safe, but consider choosing a stronger password once you're back in.`, link),
+	}
+}
+
+// ShopInvitation is the email inviting someone to join a shop's team
+func ShopInvitation(to, shop, role, link, valid string) Message {
+	return Message{
+		To:      to,
+		Subject: fmt.Sprintf("You're invited to join %s", shop),
+		Text: fmt.Sprintf(`You've been invited to join the team of %s as %s.
+
+To accept, open this link within %s and sign in with this email address:
+
+%s
+
+If you weren't expecting this, you can ignore this email.`, shop, role, valid, link),
+	}
+}
+
+// InvoiceIssued is the email with a new shop invoice and the link to pay it
+func InvoiceIssued(to, shop, plan, amount, due, link string) Message {
+	return Message{
+		To:      to,
+		Subject: fmt.Sprintf("Your invoice for %s", shop),
+		Text: fmt.Sprintf(`A new invoice of %s for the %s plan of %s is due on %s.
+
+To pay it, open this link:
+
+%s`, amount, plan, shop, due, link),
+	}
+}
+
+// PaymentOverdue is the reminder of an unpaid shop invoice
+func PaymentOverdue(to, shop, amount, due, link string) Message {
+	return Message{
+		To:      to,
+		Subject: fmt.Sprintf("Payment overdue for %s", shop),
+		Text: fmt.Sprintf(`The invoice of %s for %s was due on %s and hasn't been paid yet.
+
+Please pay it soon, or %s will be suspended:
+
+%s`, amount, shop, due, shop, link),
+	}
+}
@@ -0,0 +1,109 @@
+// Package sms sends text messages (one-time login codes). Development logs
+// messages to the console instead.
+package sms
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"bizbundl/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+var ErrInvalidNumber = errors.New("invalid phone number")
+
+// Sender delivers a text message to an E.164 number (see Normalize)
+type Sender interface {
+	Send(ctx context.Context, to, text string) error
+}
+
+// NewSender returns the sender for SMS_PROVIDER. Only "console" exists so
+// far; other providers plug in here.
+func NewSender(cfg *config.Config) Sender {
+	if cfg.SMSProvider != "console" {
+		log.Warn().Str("provider", cfg.SMSProvider).Msg("sms: unknown SMS_PROVIDER, logging messages instead")
+	} else if cfg.Environment != "development" {
+		log.Warn().Msg("sms: no SMS provider configured, logging messages instead")
+	}
+	return ConsoleSender{}
+}
+
+// ConsoleSender logs messages, codes included. Local testing only.
+type ConsoleSender struct{}
+
+func (ConsoleSender) Send(_ context.Context, to, text string) error {
+	log.Info().Str("to", to).Str("text", text).Msg("sms")
+	return nil
+}
+
+// Message is a text sent through a Recorder
+type Message struct {
+	To   string
+	Text string
+}
+
+// Recorder keeps messages in memory instead of sending them, for tests
+type Recorder struct {
+	mu       sync.Mutex
+	Messages []Message
+}
+
+func (r *Recorder) Send(_ context.Context, to, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Messages = append(r.Messages, Message{To: to, Text: text})
+	return nil
+}
+
+// Last returns the latest message sent to a number
+func (r *Recorder) Last(to string) (Message, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].To == to {
+			return r.Messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// Normalize turns a number as typed by a shopper into E.164 ("+8801712345678").
+// Numbers without an international prefix are national: a leading trunk 0
+// is dropped and defaultCountryCode ("880") prepended, unless they already
+// start with the country code.
+func Normalize(raw, defaultCountryCode string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	international := strings.HasPrefix(raw, "+")
+
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && international && digits.Len() == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidNumber
+		}
+	}
+	number := digits.String()
+
+	switch {
+	case international:
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case strings.HasPrefix(number, "0"):
+		number = defaultCountryCode + number[1:]
+	case !strings.HasPrefix(number, defaultCountryCode) || len(number) <= 10:
+		number = defaultCountryCode + number
+	}
+
+	// E.164 allows up to 15 digits; nothing real is shorter than 8
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalidNumber
+	}
+	return "+" + number, nil
+}
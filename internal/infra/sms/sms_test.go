@@ -0,0 +1,29 @@
+package sms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	for raw, want := range map[string]string{
+		"01712345678":        "+8801712345678",
+		"017-1234 5678":      "+8801712345678",
+		"1712345678":         "+8801712345678",
+		"8801712345678":      "+8801712345678",
+		"+880 1712-345678":   "+8801712345678",
+		"008801712345678":    "+8801712345678",
+		"+44 (20) 7946 0958": "+442079460958",
+	} {
+		got, err := Normalize(raw, "880")
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got, raw)
+	}
+
+	for _, raw := range []string{"", "1234", "0171234567x", "1+712345678", "+0171234567", "+12345678901234567"} {
+		_, err := Normalize(raw, "880")
+		assert.ErrorIs(t, err, ErrInvalidNumber, raw)
+	}
+}
@@ -0,0 +1,262 @@
+// Package loginguard protects password sign-in from guessing. Failed
+// attempts are counted in Redis per account email and per client IP, and
+// attempts per shop: repeated failures make an account wait longer and
+// longer between tries, then lock it until it is unlocked by email or the
+// lock expires. Failures are recorded in the platform audit log.
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"bizbundl/internal/otp"
+	auditservice "bizbundl/internal/platform/audit/service"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+)
+
+const (
+	keyPrefix = "login:"
+
+	// Failures per email count towards delays and the lock for failureWindow
+	failureWindow = time.Hour
+	// The first freeFailures failures cost nothing; each one after waits
+	// twice as long as the one before, starting at baseDelay
+	freeFailures = 3
+	baseDelay    = time.Second
+	maxDelay     = 30 * time.Second
+	// maxFailures failures lock the account for lockDuration
+	maxFailures  = 10
+	lockDuration = time.Hour
+
+	// maxPerIP failures from one IP per failureWindow block it for ipBlock
+	maxPerIP = 50
+	ipBlock  = 15 * time.Minute
+	// At most maxPerTenant attempts per shop (or the platform) per minute
+	maxPerTenant = 300
+	tenantWindow = time.Minute
+)
+
+// Audit log actions
+const (
+	ActionFailed   = "login.failed"
+	ActionLocked   = "login.locked"
+	ActionUnlocked = "login.unlocked"
+)
+
+var (
+	ErrLocked        = errors.New("too many failed sign-ins, this account is locked; check your email to unlock it")
+	ErrThrottled     = errors.New("too many failed sign-ins, wait a moment before trying again")
+	ErrRateLimited   = errors.New("too many sign-in attempts, try again later")
+	ErrInvalidUnlock = errors.New("this unlock link is invalid or has expired")
+)
+
+// LimitError refuses a sign-in attempt before the password is checked. It
+// matches its Reason: ErrLocked, ErrThrottled or ErrRateLimited.
+type LimitError struct {
+	Reason     error
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return e.Reason.Error()
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Reason
+}
+
+// Auditor records security events (auditservice.AuditService is one)
+type Auditor interface {
+	Record(ctx context.Context, e auditservice.Entry)
+}
+
+type Guard struct {
+	store otp.Store
+	audit Auditor
+	now   func() time.Time
+}
+
+// NewGuard builds the guard. store keeps its counters (otp.RedisStore shares
+// the codes' Redis); audit is told about failures and locks.
+func NewGuard(store otp.Store, audit Auditor) *Guard {
+	return &Guard{store: store, audit: audit, now: time.Now}
+}
+
+// Check is called before the password is verified and fails with a
+// *LimitError while the account is locked or must wait, the client IP is
+// blocked or the shop sees too many attempts. Limits are per shop, from ctx.
+func (g *Guard) Check(ctx context.Context, email string) error {
+	scope, email, ip := scopeOf(ctx), normalize(email), sessions.ClientFromContext(ctx).IP
+
+	type marker struct {
+		key    string
+		reason error
+	}
+	markers := []marker{
+		{key(scope, "lock", email), ErrLocked},
+		{key(scope, "wait", email), ErrThrottled},
+	}
+	if ip != "" {
+		markers = append(markers, marker{key(scope, "ip", ip), ErrRateLimited})
+	}
+	for _, m := range markers {
+		until, err := g.until(ctx, m.key)
+		if err != nil {
+			return err
+		}
+		if wait := until.Sub(g.now()); wait > 0 {
+			return &LimitError{Reason: m.reason, RetryAfter: wait}
+		}
+	}
+
+	n, err := g.store.Incr(ctx, key(scope, "tenant", ""), tenantWindow)
+	if err != nil {
+		return err
+	}
+	if n > maxPerTenant {
+		return &LimitError{Reason: ErrRateLimited, RetryAfter: tenantWindow}
+	}
+	return nil
+}
+
+// Fail counts a failed attempt, for a wrong password or an unknown email
+// alike. When it locks the account, it returns the secret of the link that
+// unlocks it (see Unlock) for the caller to email; "" otherwise.
+func (g *Guard) Fail(ctx context.Context, email string) (string, error) {
+	scope, email, ip := scopeOf(ctx), normalize(email), sessions.ClientFromContext(ctx).IP
+	now := g.now()
+
+	failures, err := g.store.Incr(ctx, key(scope, "failures", email), failureWindow)
+	if err != nil {
+		return "", err
+	}
+	g.record(ctx, ActionFailed, scope, email, map[string]any{"failures": failures})
+
+	if ip != "" {
+		n, err := g.store.Incr(ctx, key(scope, "failures-ip", ip), failureWindow)
+		if err != nil {
+			return "", err
+		}
+		if n >= maxPerIP {
+			if err := g.block(ctx, key(scope, "ip", ip), now.Add(ipBlock), ipBlock); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if failures < maxFailures {
+		if failures > freeFailures {
+			delay := min(baseDelay<<(failures-freeFailures-1), maxDelay)
+			return "", g.block(ctx, key(scope, "wait", email), now.Add(delay), delay)
+		}
+		return "", nil
+	}
+
+	// Lock, with a fresh count for when it ends
+	secret, hash, err := token.NewOpaque()
+	if err != nil {
+		return "", err
+	}
+	if err := g.block(ctx, key(scope, "lock", email), now.Add(lockDuration), lockDuration); err != nil {
+		return "", err
+	}
+	if err := g.store.Set(ctx, key(scope, "unlock", hash), email, lockDuration); err != nil {
+		return "", err
+	}
+	if _, err := g.store.Delete(ctx, key(scope, "failures", email), key(scope, "wait", email)); err != nil {
+		return "", err
+	}
+	g.record(ctx, ActionLocked, scope, email, map[string]any{"until": now.Add(lockDuration)})
+	return secret, nil
+}
+
+// Succeed forgets the account's failures once its password was right
+func (g *Guard) Succeed(ctx context.Context, email string) {
+	scope, email := scopeOf(ctx), normalize(email)
+	_, _ = g.store.Delete(ctx, key(scope, "failures", email), key(scope, "wait", email))
+}
+
+// Unlock lifts the lock an emailed link was sent for and returns the
+// account's email
+func (g *Guard) Unlock(ctx context.Context, secret string) (string, error) {
+	if secret == "" {
+		return "", ErrInvalidUnlock
+	}
+	scope := scopeOf(ctx)
+	unlockKey := key(scope, "unlock", token.HashOpaque(secret))
+
+	email, err := g.store.Get(ctx, unlockKey)
+	if errors.Is(err, otp.ErrNotFound) {
+		return "", ErrInvalidUnlock
+	}
+	if err != nil {
+		return "", err
+	}
+	keys := []string{unlockKey, key(scope, "lock", email), key(scope, "failures", email), key(scope, "wait", email)}
+	if _, err := g.store.Delete(ctx, keys...); err != nil {
+		return "", err
+	}
+	g.record(ctx, ActionUnlocked, scope, email, nil)
+	return email, nil
+}
+
+// block sets a marker that Check honours until the given time
+func (g *Guard) block(ctx context.Context, key string, until time.Time, ttl time.Duration) error {
+	return g.store.Set(ctx, key, strconv.FormatInt(until.UnixMilli(), 10), ttl)
+}
+
+// until reads a marker set by block, the zero time if there is none
+func (g *Guard) until(ctx context.Context, key string) (time.Time, error) {
+	value, err := g.store.Get(ctx, key)
+	if errors.Is(err, otp.ErrNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// record writes an audit entry about the sign-ins to email. The caller is
+// not signed in, so the actor is anonymous and the account is named by its
+// email.
+func (g *Guard) record(ctx context.Context, action, scope, email string, metadata map[string]any) {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata["tenant_id"] = scope
+	g.audit.Record(ctx, auditservice.Entry{
+		ActorType:  auditservice.ActorAnonymous,
+		Action:     action,
+		EntityType: "login",
+		EntityID:   email,
+		Metadata:   metadata,
+		IP:         sessions.ClientFromContext(ctx).IP,
+	})
+}
+
+// normalize makes the email match however it was typed
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func key(scope, kind, id string) string {
+	return keyPrefix + scope + ":" + kind + ":" + id
+}
+
+// scopeOf is the tenant the request is for, or the platform
+func scopeOf(ctx context.Context) string {
+	if tenant := tenancy.FromContext(ctx); tenant != nil {
+		return tenant.TenantID
+	}
+	return tenancy.PublicSchema
+}
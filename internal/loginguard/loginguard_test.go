@@ -0,0 +1,132 @@
+package loginguard
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"bizbundl/internal/otp"
+	auditservice "bizbundl/internal/platform/audit/service"
+	"bizbundl/internal/sessions"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// auditLog keeps the recorded actions
+type auditLog struct {
+	actions []string
+}
+
+func (a *auditLog) Record(_ context.Context, e auditservice.Entry) {
+	a.actions = append(a.actions, e.Action)
+}
+
+func newGuard() (*Guard, *auditLog, *time.Time) {
+	audit := &auditLog{}
+	g := NewGuard(otp.NewMemoryStore(), audit)
+	// Markers keep milliseconds
+	now := time.Now().Truncate(time.Millisecond)
+	g.now = func() time.Time { return now }
+	return g, audit, &now
+}
+
+func clientContext(ip string) context.Context {
+	return context.WithValue(context.Background(), sessions.ClientKey, sessions.Client{IP: ip})
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	g, audit, now := newGuard()
+	ctx := clientContext("10.0.0.1")
+	email := "owner@example.com"
+
+	for i := 0; i < freeFailures; i++ {
+		require.NoError(t, g.Check(ctx, email))
+		_, err := g.Fail(ctx, email)
+		require.NoError(t, err)
+	}
+	require.NoError(t, g.Check(ctx, "Owner@Example.com "), "no delay before freeFailures")
+
+	_, err := g.Fail(ctx, email)
+	require.NoError(t, err)
+	err = g.Check(ctx, " OWNER@example.com")
+	assert.ErrorIs(t, err, ErrThrottled, "emails are matched however typed")
+	var limit *LimitError
+	require.ErrorAs(t, err, &limit)
+	assert.Equal(t, baseDelay, limit.RetryAfter)
+
+	*now = now.Add(baseDelay)
+	require.NoError(t, g.Check(ctx, email))
+	_, err = g.Fail(ctx, email)
+	require.NoError(t, err)
+	require.ErrorAs(t, g.Check(ctx, email), &limit)
+	assert.Equal(t, 2*baseDelay, limit.RetryAfter, "doubles")
+
+	assert.NoError(t, g.Check(ctx, "other@example.com"), "per account")
+
+	g.Succeed(ctx, email)
+	assert.NoError(t, g.Check(ctx, email), "forgotten after a success")
+	assert.Len(t, audit.actions, freeFailures+2)
+	assert.Equal(t, ActionFailed, audit.actions[0])
+}
+
+func TestLockAndUnlock(t *testing.T) {
+	g, audit, now := newGuard()
+	ctx := clientContext("10.0.0.1")
+	email := "owner@example.com"
+
+	var secret string
+	for i := 0; i < maxFailures; i++ {
+		*now = now.Add(maxDelay)
+		require.NoError(t, g.Check(ctx, email))
+		var err error
+		secret, err = g.Fail(ctx, email)
+		require.NoError(t, err)
+		if i < maxFailures-1 {
+			assert.Empty(t, secret)
+		}
+	}
+	require.NotEmpty(t, secret, "the locking failure returns the unlock secret")
+	assert.Equal(t, ActionLocked, audit.actions[len(audit.actions)-1])
+
+	err := g.Check(ctx, email)
+	assert.ErrorIs(t, err, ErrLocked)
+	var limit *LimitError
+	require.ErrorAs(t, err, &limit)
+	assert.Equal(t, lockDuration, limit.RetryAfter)
+
+	_, err = g.Unlock(ctx, "wrong")
+	assert.ErrorIs(t, err, ErrInvalidUnlock)
+
+	unlocked, err := g.Unlock(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, email, unlocked)
+	assert.NoError(t, g.Check(ctx, email))
+	_, err = g.Unlock(ctx, secret)
+	assert.ErrorIs(t, err, ErrInvalidUnlock, "single use")
+	assert.Equal(t, ActionUnlocked, audit.actions[len(audit.actions)-1])
+}
+
+func TestIPBlock(t *testing.T) {
+	g, _, _ := newGuard()
+	ctx := clientContext("10.0.0.1")
+
+	// Spraying one password across many accounts
+	for i := 0; i < maxPerIP; i++ {
+		_, err := g.Fail(ctx, fmt.Sprintf("user%d@example.com", i))
+		require.NoError(t, err)
+	}
+	assert.ErrorIs(t, g.Check(ctx, "fresh@example.com"), ErrRateLimited)
+	assert.NoError(t, g.Check(clientContext("10.0.0.2"), "fresh@example.com"), "other IPs are unaffected")
+}
+
+func TestTenantLimit(t *testing.T) {
+	g, _, _ := newGuard()
+	ctx := context.Background()
+
+	for i := 0; i < maxPerTenant; i++ {
+		require.NoError(t, g.Check(ctx, "owner@example.com"))
+	}
+	assert.ErrorIs(t, g.Check(ctx, "owner@example.com"), ErrRateLimited)
+}
@@ -0,0 +1,299 @@
+// Package mfa adds TOTP two-factor authentication to an account store:
+// enrollment with a QR code, verification with replay protection, and
+// single-use recovery codes stored hashed. It is shared by platform owners
+// and shop staff, each with their own Store.
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bizbundl/internal/constants"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+	"bizbundl/util"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// At most maxFailures wrong codes per user per failureWindow
+	maxFailures   = 5
+	failureWindow = 15 * time.Minute
+	keyPrefix     = "mfa:"
+)
+
+var (
+	ErrNotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrNotSetUp         = errors.New("start two-factor setup first")
+	ErrInvalidCode      = errors.New("invalid authentication code")
+	ErrTooManyAttempts  = errors.New("too many wrong codes, try again later")
+	ErrInvalidChallenge = errors.New("sign-in expired, enter your password again")
+	ErrChallenge        = errors.New("enter the code from your authenticator app")
+)
+
+// ChallengeError is returned by sign-ins that need a second factor. The
+// challenge token is exchanged for a session with CompleteChallenge. It
+// matches ErrChallenge.
+type ChallengeError struct {
+	Challenge string
+}
+
+func (e *ChallengeError) Error() string {
+	return ErrChallenge.Error()
+}
+
+func (e *ChallengeError) Unwrap() error {
+	return ErrChallenge
+}
+
+// Factor is a user's two-factor state. Secret is encrypted; it is set but
+// not Enabled between Setup and Enable.
+type Factor struct {
+	Secret            string
+	Enabled           bool
+	LastStep          int64
+	RecoveryCodesLeft int64
+}
+
+// Store persists two-factor state on the user accounts it belongs to
+type Store interface {
+	GetFactor(ctx context.Context, userID pgtype.UUID) (Factor, error)
+	// SetSecret stores a new, not yet enabled secret
+	SetSecret(ctx context.Context, userID pgtype.UUID, secret string) error
+	Enable(ctx context.Context, userID pgtype.UUID, step int64) error
+	Disable(ctx context.Context, userID pgtype.UUID) error
+	// UseStep records step as the last accepted one, unless a later or
+	// equal step already was
+	UseStep(ctx context.Context, userID pgtype.UUID, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID pgtype.UUID, hashes []string) error
+	// UseRecoveryCode marks an unused code as used, reporting whether it was
+	UseRecoveryCode(ctx context.Context, userID pgtype.UUID, hash string) (bool, error)
+}
+
+// Counter counts failed attempts (otp.RedisStore is one)
+type Counter interface {
+	Incr(ctx context.Context, key string, window time.Duration) (int64, error)
+	Delete(ctx context.Context, keys ...string) (int64, error)
+}
+
+// Enrollment is what the user needs to add the account to an authenticator
+type Enrollment struct {
+	Secret string
+	URI    string
+}
+
+type Service struct {
+	store      Store
+	failures   Counter
+	key        string
+	challenges *token.Signer
+}
+
+// NewService builds the two-factor service. key (32 bytes) encrypts TOTP
+// secrets at rest and signs login challenges.
+func NewService(store Store, failures Counter, key string) *Service {
+	return &Service{
+		store:      store,
+		failures:   failures,
+		key:        key,
+		challenges: token.NewSigner(key, token.PurposeTwoFactorLogin),
+	}
+}
+
+// Challenge is called once a user entered the right password. With
+// two-factor authentication enabled it returns a *ChallengeError: the user
+// is not signed in until they complete the challenge with their code.
+func (s *Service) Challenge(ctx context.Context, userID pgtype.UUID) error {
+	factor, err := s.store.GetFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !factor.Enabled {
+		return nil
+	}
+	return &ChallengeError{
+		Challenge: s.challenges.Sign(scope(ctx)+"|"+userID.String(), constants.TwoFactorChallengeDuration),
+	}
+}
+
+// CompleteChallenge verifies code for the user the challenge was issued to
+// and returns them
+func (s *Service) CompleteChallenge(ctx context.Context, challenge, code string) (pgtype.UUID, error) {
+	var userID pgtype.UUID
+	subject, err := s.challenges.Verify(challenge)
+	if err != nil {
+		return userID, ErrInvalidChallenge
+	}
+	// Challenges are only good in the shop (or platform) they were issued in
+	challengeScope, id, ok := strings.Cut(subject, "|")
+	if !ok || challengeScope != scope(ctx) || userID.Scan(id) != nil {
+		return userID, ErrInvalidChallenge
+	}
+	return userID, s.Verify(ctx, userID, code)
+}
+
+// Status returns the user's two-factor state
+func (s *Service) Status(ctx context.Context, userID pgtype.UUID) (Factor, error) {
+	return s.store.GetFactor(ctx, userID)
+}
+
+// Setup starts enrollment with a new secret, replacing an unfinished one.
+// The account shows in the authenticator as issuer:account.
+func (s *Service) Setup(ctx context.Context, userID pgtype.UUID, issuer, account string) (Enrollment, error) {
+	factor, err := s.store.GetFactor(ctx, userID)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	if factor.Enabled {
+		return Enrollment{}, ErrAlreadyEnabled
+	}
+
+	secret, err := NewSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+	encrypted, err := util.Encrypt(secret, s.key)
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	if err := s.store.SetSecret(ctx, userID, encrypted); err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{Secret: secret, URI: URI(issuer, account, secret)}, nil
+}
+
+// Enable finishes enrollment with a code from the authenticator and returns
+// the recovery codes, which are shown once
+func (s *Service) Enable(ctx context.Context, userID pgtype.UUID, code string) ([]string, error) {
+	factor, err := s.store.GetFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+	if factor.Secret == "" {
+		return nil, ErrNotSetUp
+	}
+	if err := s.attempt(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	step, ok, err := s.validate(factor, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	if err := s.store.Enable(ctx, userID, step); err != nil {
+		return nil, err
+	}
+	s.reset(ctx, userID)
+	return s.RegenerateRecoveryCodes(ctx, userID)
+}
+
+// Verify checks a code from the authenticator, or a recovery code, which is
+// used up
+func (s *Service) Verify(ctx context.Context, userID pgtype.UUID, code string) error {
+	factor, err := s.store.GetFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !factor.Enabled {
+		return ErrNotEnabled
+	}
+	if err := s.attempt(ctx, userID); err != nil {
+		return err
+	}
+
+	var ok bool
+	if len(code) == digits {
+		var step int64
+		step, ok, err = s.validate(factor, code)
+		if err == nil && ok {
+			ok, err = s.store.UseStep(ctx, userID, step)
+		}
+	} else {
+		ok, err = s.store.UseRecoveryCode(ctx, userID, HashRecoveryCode(code))
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	s.reset(ctx, userID)
+	return nil
+}
+
+// Disable turns two-factor authentication off and drops the recovery codes
+func (s *Service) Disable(ctx context.Context, userID pgtype.UUID) error {
+	if err := s.store.Disable(ctx, userID); err != nil {
+		return err
+	}
+	return s.store.ReplaceRecoveryCodes(ctx, userID, nil)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID pgtype.UUID) ([]string, error) {
+	factor, err := s.store.GetFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !factor.Enabled {
+		return nil, ErrNotEnabled
+	}
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *Service) validate(factor Factor, code string) (int64, bool, error) {
+	secret, err := util.Decrypt(factor.Secret, s.key)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	step, ok := Validate(secret, code, time.Now())
+	return step, ok && step > factor.LastStep, nil
+}
+
+// attempt counts a verification and fails once the user has had too many
+func (s *Service) attempt(ctx context.Context, userID pgtype.UUID) error {
+	n, err := s.failures.Incr(ctx, failureKey(ctx, userID), failureWindow)
+	if err != nil {
+		return err
+	}
+	if n > maxFailures {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// reset forgets counted attempts after a correct code
+func (s *Service) reset(ctx context.Context, userID pgtype.UUID) {
+	_, _ = s.failures.Delete(ctx, failureKey(ctx, userID))
+}
+
+// failureKey scopes the counter: user IDs are only unique within a shop
+func failureKey(ctx context.Context, userID pgtype.UUID) string {
+	return keyPrefix + scope(ctx) + ":" + userID.String()
+}
+
+// scope is the tenant the request is for, or the platform
+func scope(ctx context.Context) string {
+	if tenant := tenancy.FromContext(ctx); tenant != nil {
+		return tenant.TenantID
+	}
+	return tenancy.PublicSchema
+}
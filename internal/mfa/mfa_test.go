@@ -0,0 +1,199 @@
+package mfa
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"bizbundl/internal/otp"
+	"bizbundl/internal/tenancy"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "12345678901234567890123456789012"
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B (SHA1), last six digits
+	secret := secretEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := Code(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, unix)
+	}
+
+	now := time.Unix(1234567890, 0)
+	step, ok := Validate(secret, "005924", now)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1234567890/period), step)
+
+	// One period of drift either way
+	_, ok = Validate(secret, "005924", now.Add(period*time.Second))
+	assert.True(t, ok)
+	_, ok = Validate(secret, "005924", now.Add(3*period*time.Second))
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("My Shop", "owner@example.com", "ABC"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/My Shop:owner@example.com", u.Path)
+	assert.Equal(t, "ABC", u.Query().Get("secret"))
+	assert.Equal(t, "My Shop", u.Query().Get("issuer"))
+}
+
+// memoryStore is a Store for one user
+type memoryStore struct {
+	factor Factor
+	codes  map[string]bool
+}
+
+func (m *memoryStore) GetFactor(context.Context, pgtype.UUID) (Factor, error) {
+	f := m.factor
+	for _, used := range m.codes {
+		if !used {
+			f.RecoveryCodesLeft++
+		}
+	}
+	return f, nil
+}
+
+func (m *memoryStore) SetSecret(_ context.Context, _ pgtype.UUID, secret string) error {
+	m.factor = Factor{Secret: secret}
+	return nil
+}
+
+func (m *memoryStore) Enable(_ context.Context, _ pgtype.UUID, step int64) error {
+	m.factor.Enabled, m.factor.LastStep = true, step
+	return nil
+}
+
+func (m *memoryStore) Disable(context.Context, pgtype.UUID) error {
+	m.factor = Factor{}
+	return nil
+}
+
+func (m *memoryStore) UseStep(_ context.Context, _ pgtype.UUID, step int64) (bool, error) {
+	if step <= m.factor.LastStep {
+		return false, nil
+	}
+	m.factor.LastStep = step
+	return true, nil
+}
+
+func (m *memoryStore) ReplaceRecoveryCodes(_ context.Context, _ pgtype.UUID, hashes []string) error {
+	m.codes = map[string]bool{}
+	for _, h := range hashes {
+		m.codes[h] = false
+	}
+	return nil
+}
+
+func (m *memoryStore) UseRecoveryCode(_ context.Context, _ pgtype.UUID, hash string) (bool, error) {
+	used, ok := m.codes[hash]
+	if !ok || used {
+		return false, nil
+	}
+	m.codes[hash] = true
+	return true, nil
+}
+
+func TestService(t *testing.T) {
+	store := &memoryStore{}
+	svc := NewService(store, otp.NewMemoryStore(), testKey)
+	ctx := context.Background()
+	var user pgtype.UUID
+
+	assert.ErrorIs(t, svc.Verify(ctx, user, "123456"), ErrNotEnabled)
+	_, err := svc.Enable(ctx, user, "123456")
+	assert.ErrorIs(t, err, ErrNotSetUp)
+
+	enrollment, err := svc.Setup(ctx, user, "BizBundl", "owner@example.com")
+	require.NoError(t, err)
+	assert.NotContains(t, store.factor.Secret, enrollment.Secret, "stored encrypted")
+
+	code, err := Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	recovery, err := svc.Enable(ctx, user, code)
+	require.NoError(t, err)
+	assert.Len(t, recovery, RecoveryCodeCount)
+	_, err = svc.Setup(ctx, user, "BizBundl", "owner@example.com")
+	assert.ErrorIs(t, err, ErrAlreadyEnabled)
+
+	// The enrollment code cannot be replayed; the next one works once
+	assert.ErrorIs(t, svc.Verify(ctx, user, code), ErrInvalidCode)
+	next, err := Code(enrollment.Secret, time.Now().Add(period*time.Second))
+	require.NoError(t, err)
+	assert.NoError(t, svc.Verify(ctx, user, next))
+	assert.ErrorIs(t, svc.Verify(ctx, user, next), ErrInvalidCode)
+
+	// Recovery codes work once, however they are typed
+	assert.NoError(t, svc.Verify(ctx, user, strings.ToUpper(recovery[0])))
+	assert.ErrorIs(t, svc.Verify(ctx, user, recovery[0]), ErrInvalidCode)
+	status, err := svc.Status(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, int64(RecoveryCodeCount-1), status.RecoveryCodesLeft)
+}
+
+func TestServiceAttempts(t *testing.T) {
+	store := &memoryStore{}
+	svc := NewService(store, otp.NewMemoryStore(), testKey)
+	ctx := context.Background()
+	var user pgtype.UUID
+
+	enrollment, err := svc.Setup(ctx, user, "BizBundl", "owner@example.com")
+	require.NoError(t, err)
+	code, err := Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	recovery, err := svc.Enable(ctx, user, code)
+	require.NoError(t, err)
+
+	for range maxFailures {
+		assert.ErrorIs(t, svc.Verify(ctx, user, "wrong-code"), ErrInvalidCode)
+	}
+	// Locked out, even with a good code
+	assert.ErrorIs(t, svc.Verify(ctx, user, recovery[0]), ErrTooManyAttempts)
+}
+
+func TestChallenge(t *testing.T) {
+	store := &memoryStore{}
+	svc := NewService(store, otp.NewMemoryStore(), testKey)
+	shopA := context.WithValue(context.Background(), tenancy.ContextKey, &tenancy.Tenant{TenantID: "shop_a"})
+	shopB := context.WithValue(context.Background(), tenancy.ContextKey, &tenancy.Tenant{TenantID: "shop_b"})
+	user := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	assert.NoError(t, svc.Challenge(shopA, user), "not enabled")
+
+	enrollment, err := svc.Setup(shopA, user, "shop-a", "staff@example.com")
+	require.NoError(t, err)
+	code, err := Code(enrollment.Secret, time.Now().Add(-period*time.Second))
+	require.NoError(t, err)
+	recovery, err := svc.Enable(shopA, user, code)
+	require.NoError(t, err)
+
+	var challengeErr *ChallengeError
+	require.ErrorAs(t, svc.Challenge(shopA, user), &challengeErr)
+	assert.ErrorIs(t, challengeErr, ErrChallenge)
+	challenge := challengeErr.Challenge
+	_, err = svc.CompleteChallenge(shopA, challenge+"x", recovery[0])
+	assert.ErrorIs(t, err, ErrInvalidChallenge)
+	_, err = svc.CompleteChallenge(shopB, challenge, recovery[0])
+	assert.ErrorIs(t, err, ErrInvalidChallenge, "issued in another shop")
+	_, err = svc.CompleteChallenge(shopA, challenge, "000000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	got, err := svc.CompleteChallenge(shopA, challenge, recovery[0])
+	require.NoError(t, err)
+	assert.Equal(t, user, got)
+}
@@ -0,0 +1,48 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"bizbundl/token"
+)
+
+const (
+	// RecoveryCodeCount codes are issued at a time, each usable once
+	RecoveryCodeCount = 10
+	// recoveryAlphabet leaves out characters that are easy to misread
+	recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryLength   = 10
+)
+
+// NewRecoveryCodes returns fresh recovery codes ("xxxxx-xxxxx") to show the
+// user once, and their hashes to store
+func NewRecoveryCodes() (codes, hashes []string, err error) {
+	max := big.NewInt(int64(len(recoveryAlphabet)))
+	for range RecoveryCodeCount {
+		var b strings.Builder
+		for i := range recoveryLength {
+			if i == recoveryLength/2 {
+				b.WriteByte('-')
+			}
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+			}
+			b.WriteByte(recoveryAlphabet[n.Int64()])
+		}
+		codes = append(codes, b.String())
+		hashes = append(hashes, HashRecoveryCode(b.String()))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code as typed: case, spaces and
+// dashes do not matter
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return token.HashOpaque(code)
+}
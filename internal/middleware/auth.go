@@ -1,8 +1,13 @@
 package middleware
 
 import (
+	"bizbundl/internal/apikeys"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
 	"bizbundl/token"
 	"bizbundl/util"
+	"context"
+	"errors"
 	"strings"
 	"time"
 
@@ -10,19 +15,43 @@ import (
 	"github.com/google/uuid"
 )
 
+// APIKeyLocal is the Fiber local (and so c.Context() value) holding the
+// apikeys.Key a request is authenticated with
+const APIKeyLocal = apikeys.ContextKey
+
+// APIKeys authenticates the API keys of a shop (see apikeys.Service)
+type APIKeys interface {
+	Authenticate(ctx context.Context, secret string) (apikeys.Key, error)
+}
+
 // Auth Middleware (Super Efficient / Stateless)
-// Uses Paseto/JWT to verify user without DB lookup.
-func Auth(tokenMaker token.Maker, userDuration, guestDuration, refreshThreshold time.Duration) fiber.Handler {
+// Uses Paseto/JWT to verify user without DB lookup. Signed-in access tokens
+// are short-lived and also checked against their server-side session (one
+// Redis read), so they stop working as soon as the session is revoked. When
+// one expires, the refresh cookie is rotated for a new pair.
+//
+// Bearer API keys are checked against the shop's keys instead (nil apiKeys
+// refuses them). Their requests get no guest session and no cookies.
+func Auth(tokenMaker token.Maker, sessionManager *sessions.Manager, apiKeys APIKeys, cookies CookiePolicy, guestDuration, guestRenewal time.Duration) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// Both the platform and the storefront auth modules install this globally
+		if _, done := c.Locals("user_id").(string); done {
+			return c.Next()
+		}
+		c.Locals(sessions.ClientKey, sessions.Client{UserAgent: c.Get(fiber.HeaderUserAgent), IP: c.IP()})
+
+		var bearer string
+		if authHeader := c.Get("Authorization"); len(authHeader) > 7 && strings.EqualFold(authHeader[0:7], "Bearer ") {
+			bearer = authHeader[7:]
+		}
+		if strings.HasPrefix(bearer, apikeys.Prefix) {
+			return authenticateAPIKey(c, apiKeys, bearer)
+		}
+
 		// 1. Get Token
-		tokenString := c.Cookies("session_token")
-		// DEBUG LOG
-		// fmt.Printf("Auth Middleware: Cookie Token: %s\n", tokenString)
+		tokenString := c.Cookies(AccessCookie)
 		if tokenString == "" {
-			authHeader := c.Get("Authorization")
-			if len(authHeader) > 7 && strings.EqualFold(authHeader[0:7], "Bearer ") {
-				tokenString = authHeader[7:]
-			}
+			tokenString = bearer
 		}
 
 		var payload *token.Payload
@@ -31,66 +60,80 @@ func Auth(tokenMaker token.Maker, userDuration, guestDuration, refreshThreshold
 		// 2. Verify Token (if exists)
 		if tokenString != "" {
 			payload, err = tokenMaker.VerifyToken(tokenString)
+			// Signed-in tokens are only good while their session is
+			if err == nil && payload.Role != "guest" {
+				err = sessionManager.Check(c.Context(), payload)
+			}
 		}
 
-		// 3. Create Guest Identity if missing or invalid
+		// 3. Missing or expired access token: rotate the refresh token
+		if tokenString == "" || err != nil {
+			if refresh := c.Cookies(RefreshCookie); refresh != "" {
+				tokens, refreshed, refreshErr := sessionManager.Refresh(c.Context(), refresh)
+				if refreshErr == nil {
+					cookies.SetTokens(c, tokens)
+					tokenString, payload, err = tokens.Access, refreshed, nil
+				} else if err == nil {
+					// Revoked, expired or reused: fall through to a guest
+					err = refreshErr
+				}
+			}
+		}
+
+		// 4. Create Guest Identity if missing or invalid
 		// Note: We might want to separate "Strict Auth" (401) vs "Guest Identity" (Auto-create)
 		// For E-commerce, we usually want Guest Identity everywhere by default.
 		if tokenString == "" || err != nil {
-			// If verification failed, clear bad cookie
+			// If verification failed, clear bad cookies
 			if err != nil {
-				c.ClearCookie("session_token")
+				cookies.Clear(c)
 			}
 
 			// Generate NEW Guest Identity
-			guestID := uuid.New() // Using uuid direct
-			// Actually payload needs UUID string
-			newToken, newPayload, err := tokenMaker.CreateToken(guestID.String(), "guest", guestDuration)
+			newToken, newPayload, err := tokenMaker.CreateToken(uuid.NewString(), "guest", guestDuration)
 			if err != nil {
 				return util.APIError(c, fiber.StatusInternalServerError, fiber.NewError(fiber.StatusInternalServerError, "Failed to create guest session"))
 			}
-
-			// Set Cookie
-			cookie := new(fiber.Cookie)
-			cookie.Name = "session_token"
-			cookie.Value = newToken
-			cookie.Expires = time.Now().Add(guestDuration) // Guest duration
-			cookie.HTTPOnly = true
-			// Secure only in prod
-			cookie.Secure = false
-			cookie.SameSite = "Lax" // Lax is better for nav
-			c.Cookie(cookie)
-
-			tokenString = newToken
+			cookies.SetGuest(c, newToken, newPayload.ExpiredAt)
 			payload = newPayload
 		}
 
-		// 4. Set Context
+		// 5. Set Context
 		c.Locals("user_id", payload.UserID)
 		c.Locals("user_role", payload.Role)
+		c.Locals("session_id", payload.SessionID)
 
-		// Auto-Renewal (Sliding Window) for Valid Tokens
-		if time.Since(payload.IssuedAt) > refreshThreshold {
-			// Generate NEW token
-			duration := userDuration
-			if payload.Role == "guest" {
-				duration = guestDuration
-			}
-
-			newToken, _, err := tokenMaker.CreateToken(payload.UserID, payload.Role, duration)
-			if err == nil {
-				// Set Cookie
-				cookie := new(fiber.Cookie)
-				cookie.Name = "session_token"
-				cookie.Value = newToken
-				cookie.Expires = time.Now().Add(duration)
-				cookie.HTTPOnly = true
-				cookie.Secure = true
-				cookie.SameSite = "Strict"
-				c.Cookie(cookie)
+		// Guests keep their identity (and cart) on a sliding window
+		if payload.Role == "guest" && time.Since(payload.IssuedAt) > guestRenewal {
+			if newToken, newPayload, err := tokenMaker.CreateToken(payload.UserID, payload.Role, guestDuration); err == nil {
+				cookies.SetGuest(c, newToken, newPayload.ExpiredAt)
 			}
 		}
 
 		return c.Next()
 	}
 }
+
+// authenticateAPIKey lets through requests with a valid API key. A bad key
+// is refused rather than treated as a guest, so integrations notice.
+func authenticateAPIKey(c *fiber.Ctx, apiKeys APIKeys, secret string) error {
+	// Responses for a key are never shared with other callers
+	c.Locals("cache_skip", true)
+	// Keys belong to shops
+	if tenant, ok := c.Locals("tenant").(*tenancy.Tenant); !ok || tenant.IsPlatform() || apiKeys == nil {
+		return util.APIError(c, fiber.StatusUnauthorized, apikeys.ErrInvalidKey)
+	}
+	key, err := apiKeys.Authenticate(c.Context(), secret)
+	if errors.Is(err, apikeys.ErrInvalidKey) {
+		return util.APIError(c, fiber.StatusUnauthorized, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+
+	// Keys are nobody: handlers for signed-in users refuse them
+	c.Locals("user_id", "")
+	c.Locals("user_role", apikeys.Role)
+	c.Locals(APIKeyLocal, key)
+	return c.Next()
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bizbundl/internal/apikeys"
+	"bizbundl/internal/permissions"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuthApp(t *testing.T, tenant *tenancy.Tenant) (*fiber.App, *sessions.Manager, token.Maker) {
+	maker, err := token.NewPasetoMaker(testKey)
+	require.NoError(t, err)
+	sessionManager := sessions.NewManager(sessions.NewMemoryStore(), maker, time.Minute, time.Hour)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("tenant", tenant)
+		return c.Next()
+	})
+	app.Use(Auth(maker, sessionManager, nil, NewCookiePolicy("production"), time.Hour, time.Hour))
+	app.Get("/", func(c *fiber.Ctx) error {
+		role, _ := c.Locals("user_role").(string)
+		return c.SendString(role)
+	})
+	return app, sessionManager, maker
+}
+
+func roleOf(t *testing.T, app *fiber.App, tok string) string {
+	role, _ := request(t, app, &http.Cookie{Name: AccessCookie, Value: tok})
+	return role
+}
+
+// request returns the role the app saw and the cookies it set
+func request(t *testing.T, app *fiber.App, cookies ...*http.Cookie) (string, map[string]*http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	res, err := app.Test(req)
+	require.NoError(t, err)
+	body := make([]byte, 64)
+	n, _ := res.Body.Read(body)
+
+	set := map[string]*http.Cookie{}
+	for _, cookie := range res.Cookies() {
+		set[cookie.Name] = cookie
+	}
+	return string(body[:n]), set
+}
+
+func TestAuthRevokedSession(t *testing.T) {
+	tenant := &tenancy.Tenant{TenantID: "shop_a", IsActive: true}
+	app, sessionManager, maker := newAuthApp(t, tenant)
+	ctx := context.WithValue(context.Background(), tenancy.ContextKey, tenant)
+
+	tokens, session, err := sessionManager.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+	tok := tokens.Access
+	assert.Equal(t, "customer", roleOf(t, app, tok))
+
+	// Revoked sessions fall back to a guest identity
+	require.NoError(t, sessionManager.Revoke(ctx, "user-1", session.ID))
+	assert.Equal(t, "guest", roleOf(t, app, tok))
+
+	// Signed-in tokens without a session are not accepted
+	legacy, _, err := maker.CreateToken("user-1", "customer", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "guest", roleOf(t, app, legacy))
+}
+
+func TestAuthSessionOfOtherShop(t *testing.T) {
+	app, sessionManager, _ := newAuthApp(t, &tenancy.Tenant{TenantID: "shop_a", IsActive: true})
+	other := context.WithValue(context.Background(), tenancy.ContextKey, &tenancy.Tenant{TenantID: "shop_b", IsActive: true})
+
+	tokens, _, err := sessionManager.Issue(other, "user-1", "admin")
+	require.NoError(t, err)
+	assert.Equal(t, "guest", roleOf(t, app, tokens.Access))
+}
+
+func TestAuthRefresh(t *testing.T) {
+	tenant := &tenancy.Tenant{TenantID: "shop_a", IsActive: true}
+	app, sessionManager, _ := newAuthApp(t, tenant)
+	ctx := context.WithValue(context.Background(), tenancy.ContextKey, tenant)
+
+	issued, _, err := sessionManager.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+
+	// No (or an expired) access token: the refresh token is rotated
+	role, set := request(t, app, &http.Cookie{Name: RefreshCookie, Value: issued.Refresh})
+	assert.Equal(t, "customer", role)
+	require.Contains(t, set, AccessCookie)
+	require.Contains(t, set, RefreshCookie)
+	rotated := set[RefreshCookie]
+	assert.NotEqual(t, issued.Refresh, rotated.Value)
+	assert.True(t, rotated.HttpOnly)
+	assert.True(t, rotated.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, rotated.SameSite)
+
+	// Rotate once more, then replay the original: the session is revoked
+	// and the caller is signed out
+	_, latest := request(t, app, rotated)
+	role, set = request(t, app, &http.Cookie{Name: RefreshCookie, Value: issued.Refresh})
+	assert.Equal(t, "guest", role)
+	assert.Empty(t, set[RefreshCookie].Value)
+
+	// Including whoever holds the latest refresh token
+	role, _ = request(t, app, latest[RefreshCookie])
+	assert.Equal(t, "guest", role)
+}
+
+// fakeAPIKeys knows one key
+type fakeAPIKeys struct {
+	secret string
+	key    apikeys.Key
+}
+
+func (f fakeAPIKeys) Authenticate(_ context.Context, secret string) (apikeys.Key, error) {
+	if secret != f.secret {
+		return apikeys.Key{}, apikeys.ErrInvalidKey
+	}
+	return f.key, nil
+}
+
+func TestAuthAPIKey(t *testing.T) {
+	maker, err := token.NewPasetoMaker(testKey)
+	require.NoError(t, err)
+	sessionManager := sessions.NewManager(sessions.NewMemoryStore(), maker, time.Minute, time.Hour)
+	keys := fakeAPIKeys{secret: apikeys.Prefix + "valid", key: apikeys.Key{ID: "key-1", Scopes: []string{permissions.CatalogEdit}}}
+	resolver := permissions.NewResolver(&fakeUsers{}, nil)
+
+	newApp := func(tenant *tenancy.Tenant) *fiber.App {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("tenant", tenant)
+			return c.Next()
+		})
+		app.Use(Auth(maker, sessionManager, keys, NewCookiePolicy("production"), time.Hour, time.Hour))
+		app.Get("/api/v1/products", RequirePermission(resolver, permissions.CatalogEdit), func(c *fiber.Ctx) error {
+			return c.SendString(c.Locals("user_role").(string))
+		})
+		app.Get("/api/v1/orders", RequirePermission(resolver, permissions.OrdersView), func(c *fiber.Ctx) error { return nil })
+		app.Get("/admin", RequirePermission(resolver, ""), func(c *fiber.Ctx) error { return nil })
+		return app
+	}
+	call := func(app *fiber.App, path, secret string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		return res
+	}
+	app := newApp(&tenancy.Tenant{TenantID: "shop_a", IsActive: true})
+
+	res := call(app, "/api/v1/products", keys.secret)
+	assert.Equal(t, fiber.StatusOK, res.StatusCode)
+	assert.Empty(t, res.Cookies(), "no guest session")
+
+	assert.Equal(t, fiber.StatusForbidden, call(app, "/api/v1/orders", keys.secret).StatusCode, "out of scope")
+	assert.Equal(t, fiber.StatusForbidden, call(app, "/admin", keys.secret).StatusCode, "keys are not staff")
+	assert.Equal(t, fiber.StatusUnauthorized, call(app, "/api/v1/products", apikeys.Prefix+"revoked").StatusCode, "not a guest")
+
+	platform := newApp(&tenancy.Tenant{TenantID: tenancy.PublicSchema, IsActive: true})
+	assert.Equal(t, fiber.StatusUnauthorized, call(platform, "/api/v1/products", keys.secret).StatusCode, "keys belong to shops")
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NoStore keeps responses out of the shared response cache, and out of
+// browsers' caches. Routes with tokens in their URL or per-user data need
+// it, and so do groups behind a guard, which run it first: the cache would
+// otherwise share the guard's refusal with those it lets through.
+func NoStore() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("cache_skip", true)
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.Next()
+	}
+}
+
+// SkipCache is whether a response must not be shared: marked cache_skip
+// (see NoStore), anything but a 200, since the cache keeps neither Location
+// nor Set-Cookie, and responses setting cookies or marked no-store or private
+func SkipCache(c *fiber.Ctx) bool {
+	if skip, _ := c.Locals("cache_skip").(bool); skip {
+		return true
+	}
+	if c.Response().StatusCode() != fiber.StatusOK {
+		return true
+	}
+	setsCookie := false
+	c.Response().Header.VisitAllCookie(func(_, _ []byte) { setsCookie = true })
+	if setsCookie {
+		return true
+	}
+	cacheControl := string(c.Response().Header.Peek(fiber.HeaderCacheControl))
+	return strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private")
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipCache(t *testing.T) {
+	app := fiber.New()
+	app.Use(cache.New(cache.Config{Expiration: time.Minute, Next: SkipCache}))
+
+	calls := map[string]int{}
+	count := func(c *fiber.Ctx) error {
+		calls[c.Route().Path]++
+		return c.Next()
+	}
+	signedIn := func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderAuthorization) == "" {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		return c.Next()
+	}
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+
+	app.Get("/page", count, ok)
+	app.Get("/redirect", count, func(c *fiber.Ctx) error { return c.Redirect("/page") })
+	app.Get("/cookie", count, func(c *fiber.Ctx) error {
+		c.Cookie(&fiber.Cookie{Name: "state", Value: "abc"})
+		return c.SendString("ok")
+	})
+	app.Get("/private", count, NoStore(), ok)
+	app.Get("/admin", count, NoStore(), signedIn, ok)
+
+	get := func(path string, signIn bool) int {
+		req := httptest.NewRequest("GET", path, nil)
+		if signIn {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer x")
+		}
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		return res.StatusCode
+	}
+
+	for _, path := range []string{"/page", "/redirect", "/cookie", "/private"} {
+		get(path, false)
+		get(path, false)
+	}
+	assert.Equal(t, 1, calls["/page"], "shared")
+	assert.Equal(t, 2, calls["/redirect"], "redirects lose their Location in the cache")
+	assert.Equal(t, 2, calls["/cookie"], "cookies are per caller")
+	assert.Equal(t, 2, calls["/private"])
+
+	// A guard's refusal isn't shared with those it lets through
+	assert.Equal(t, fiber.StatusUnauthorized, get("/admin", false))
+	assert.Equal(t, fiber.StatusOK, get("/admin", true))
+	assert.Equal(t, fiber.StatusUnauthorized, get("/admin", false), "nor the page with those it refuses")
+}
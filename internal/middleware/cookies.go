@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"time"
+
+	"bizbundl/internal/sessions"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// AccessCookie holds the short-lived access token, or a guest token
+	AccessCookie = "session_token"
+	// RefreshCookie holds the rotating refresh token of a signed-in session
+	RefreshCookie = "refresh_token"
+)
+
+// CookiePolicy sets every session cookie the same way. Cookies are HTTP-only
+// and SameSite=Lax: Strict would drop them when shoppers come back from a
+// payment gateway or an emailed link, and Lax still keeps them off
+// cross-site POSTs. They are Secure everywhere but local development.
+type CookiePolicy struct {
+	Secure bool
+}
+
+// NewCookiePolicy returns the policy for config.Environment
+func NewCookiePolicy(environment string) CookiePolicy {
+	return CookiePolicy{Secure: environment != "development"}
+}
+
+// SetTokens stores a signed-in session's tokens. A refresh that only minted
+// an access token leaves the refresh cookie alone.
+func (p CookiePolicy) SetTokens(c *fiber.Ctx, tokens sessions.Tokens) {
+	c.Cookie(p.cookie(AccessCookie, tokens.Access, tokens.AccessExpiresAt))
+	if tokens.Refresh != "" {
+		c.Cookie(p.cookie(RefreshCookie, tokens.Refresh, tokens.RefreshExpiresAt))
+	}
+}
+
+// SetGuest stores a guest token, replacing any signed-in session
+func (p CookiePolicy) SetGuest(c *fiber.Ctx, guestToken string, expires time.Time) {
+	c.Cookie(p.cookie(AccessCookie, guestToken, expires))
+	if c.Cookies(RefreshCookie) != "" {
+		c.Cookie(p.cookie(RefreshCookie, "", time.Unix(0, 0)))
+	}
+}
+
+// Clear removes both session cookies
+func (p CookiePolicy) Clear(c *fiber.Ctx) {
+	c.Cookie(p.cookie(AccessCookie, "", time.Unix(0, 0)))
+	c.Cookie(p.cookie(RefreshCookie, "", time.Unix(0, 0)))
+}
+
+func (p CookiePolicy) cookie(name, value string, expires time.Time) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   p.Secure,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+}
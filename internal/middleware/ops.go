@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errOpsUnauthorized = errors.New("invalid ops token")
+
+// OpsToken guards operator endpoints (cohort moves, fleet jobs) with a static
+// bearer token. An empty token disables the endpoints entirely.
+func OpsToken(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return fiber.ErrNotFound
+		}
+
+		authHeader := c.Get(fiber.HeaderAuthorization)
+		given, ok := strings.CutPrefix(authHeader, "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			return util.APIError(c, fiber.StatusUnauthorized, errOpsUnauthorized)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+
+	"bizbundl/internal/apikeys"
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/permissions"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PermissionsKey is the Fiber local holding the caller's permissions.Access
+// once RequirePermission has passed
+const PermissionsKey = "permissions"
+
+var (
+	errNotAuthenticated = errors.New("not authenticated")
+	errForbidden        = errors.New("you do not have permission to do this")
+)
+
+// RequirePermission lets through staff of the current shop who hold
+// permission, or any staff when permission is "". Admins hold every
+// permission. It must run after Auth. Unknown permission names panic at
+// startup so a typo cannot silently lock a route.
+func RequirePermission(resolver *permissions.Resolver, permission string) fiber.Handler {
+	if permission != "" && !permissions.Known(permission) {
+		panic(fmt.Sprintf("middleware: permission %q is not in permissions.Registry", permission))
+	}
+	return func(c *fiber.Ctx) error {
+		// API keys hold the permissions they were created with. They are not
+		// staff: routes open to any staff (permission "") refuse them.
+		if key, ok := c.Locals(APIKeyLocal).(apikeys.Key); ok {
+			if permission == "" || !key.Allows(permission) {
+				return util.APIError(c, fiber.StatusForbidden, errForbidden)
+			}
+			c.Locals(PermissionsKey, permissions.Access{Role: db.UserRoleStaff, Permissions: key.Scopes})
+			return c.Next()
+		}
+
+		// 1. Identity (guests and customers never reach the DB)
+		userID, _ := c.Locals("user_id").(string)
+		role, _ := c.Locals("user_role").(string)
+		if userID == "" || !staffRoles[role] {
+			if isAPI(c) {
+				return util.APIError(c, fiber.StatusUnauthorized, errNotAuthenticated)
+			}
+			return c.Redirect("/login")
+		}
+
+		// 2. Access, from the shop's users table
+		access, err := resolver.Resolve(c.Context(), userID)
+		if err != nil {
+			return util.APIError(c, fiber.StatusInternalServerError, err)
+		}
+		if !access.Staff() || (permission != "" && !access.Has(permission)) {
+			if isAPI(c) {
+				return util.APIError(c, fiber.StatusForbidden, errForbidden)
+			}
+			return c.Status(fiber.StatusForbidden).SendString(errForbidden.Error())
+		}
+
+		c.Locals(PermissionsKey, access)
+		return c.Next()
+	}
+}
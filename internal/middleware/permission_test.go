@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/permissions"
+	"bizbundl/internal/tenancy"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	adminID    = "00000000-0000-0000-0000-00000000000a"
+	fulfillID  = "00000000-0000-0000-0000-00000000000b"
+	customerID = "00000000-0000-0000-0000-00000000000c"
+)
+
+// fakeUsers serves users by ID and counts lookups
+type fakeUsers struct {
+	users   map[string]db.User
+	lookups int
+}
+
+func (f *fakeUsers) GetUserById(_ context.Context, id pgtype.UUID) (db.User, error) {
+	f.lookups++
+	user, ok := f.users[id.String()]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+func newPermissionApp(t *testing.T) (*fiber.App, *fakeUsers, *permissions.Resolver) {
+	users := &fakeUsers{users: map[string]db.User{
+		adminID:    {Role: db.UserRoleAdmin},
+		fulfillID:  {Role: db.UserRoleStaff, Permissions: []byte(`["orders.view","orders.fulfill"]`)},
+		customerID: {Role: db.UserRoleCustomer},
+	}}
+	resolver := permissions.NewResolver(users, nil)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("tenant", &tenancy.Tenant{TenantID: "shop_a", IsActive: true})
+		c.Locals("user_id", c.Get("X-User"))
+		c.Locals("user_role", c.Get("X-Role"))
+		return c.Next()
+	})
+	app.Get("/admin", RequirePermission(resolver, ""), func(c *fiber.Ctx) error { return c.SendString("admin") })
+	app.Post("/api/v1/orders", RequirePermission(resolver, permissions.OrdersFulfill), func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Post("/api/v1/products", RequirePermission(resolver, permissions.CatalogEdit), func(c *fiber.Ctx) error { return c.SendString("ok") })
+	return app, users, resolver
+}
+
+func requestAs(app *fiber.App, method, path, userID, role string) (int, error) {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-User", userID)
+	req.Header.Set("X-Role", role)
+	res, err := app.Test(req)
+	if err != nil {
+		return 0, err
+	}
+	return res.StatusCode, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	app, _, _ := newPermissionApp(t)
+
+	cases := []struct {
+		name, method, path, userID, role string
+		status                           int
+	}{
+		{"guest page", http.MethodGet, "/admin", "guest-1", "guest", fiber.StatusFound},
+		{"guest api", http.MethodPost, "/api/v1/orders", "guest-1", "guest", fiber.StatusUnauthorized},
+		{"admin", http.MethodPost, "/api/v1/products", adminID, "admin", fiber.StatusOK},
+		{"staff with permission", http.MethodPost, "/api/v1/orders", fulfillID, "staff", fiber.StatusOK},
+		{"staff without permission", http.MethodPost, "/api/v1/products", fulfillID, "staff", fiber.StatusForbidden},
+		{"staff any", http.MethodGet, "/admin", fulfillID, "staff", fiber.StatusOK},
+		// The users row wins over the role in the token
+		{"demoted", http.MethodGet, "/admin", customerID, "staff", fiber.StatusForbidden},
+	}
+	for _, tc := range cases {
+		status, err := requestAs(app, tc.method, tc.path, tc.userID, tc.role)
+		require.NoError(t, err)
+		assert.Equal(t, tc.status, status, tc.name)
+	}
+}
+
+func TestRequirePermissionCache(t *testing.T) {
+	app, users, resolver := newPermissionApp(t)
+
+	for range 3 {
+		status, err := requestAs(app, http.MethodPost, "/api/v1/products", fulfillID, "staff")
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusForbidden, status)
+	}
+	assert.Equal(t, 1, users.lookups)
+
+	// Granting the permission takes effect once the user is invalidated
+	user := users.users[fulfillID]
+	user.Permissions = []byte(`["catalog.edit"]`)
+	users.users[fulfillID] = user
+	resolver.Invalidate(context.Background(), "shop_a", fulfillID)
+
+	status, err := requestAs(app, http.MethodPost, "/api/v1/products", fulfillID, "staff")
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, 2, users.lookups)
+}
+
+func TestRequirePermissionUnknown(t *testing.T) {
+	assert.Panics(t, func() { RequirePermission(nil, "manage_everything") })
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"context"
+	"regexp"
+
+	"bizbundl/internal/audit"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// validRequestID accepts the IDs of load balancers and tracing headers, not
+// anything that would garble logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestID names every request with its X-Request-ID header, or a new ID,
+// and sends it back. Audit entries record it (see audit.RequestIDFromContext)
+// from c.Context() and c.UserContext() alike.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Set(fiber.HeaderXRequestID, id)
+		c.Locals(audit.RequestIDKey, id)
+		c.SetUserContext(context.WithValue(c.UserContext(), audit.RequestIDKey, id))
+		return c.Next()
+	}
+}
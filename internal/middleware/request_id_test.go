@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"bizbundl/internal/audit"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	app := fiber.New()
+	app.Use(RequestID())
+	app.Get("/", func(c *fiber.Ctx) error {
+		// Services see it from either context
+		assert.Equal(t, audit.RequestIDFromContext(c.Context()), audit.RequestIDFromContext(c.UserContext()))
+		return c.SendString(audit.RequestIDFromContext(c.Context()))
+	})
+
+	send := func(header string) (string, string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set(fiber.HeaderXRequestID, header)
+		}
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.Header.Get(fiber.HeaderXRequestID), string(body)
+	}
+
+	id, seen := send("")
+	assert.Len(t, id, 36, "generated")
+	assert.Equal(t, id, seen)
+
+	id, seen = send("lb-1234.abc")
+	assert.Equal(t, "lb-1234.abc", id, "taken from the load balancer")
+	assert.Equal(t, id, seen)
+
+	id, _ = send("bad id\" <script>")
+	assert.Len(t, id, 36, "replaced")
+}
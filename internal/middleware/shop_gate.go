@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"bizbundl/internal/otp"
+	root "bizbundl/internal/platform/root/view"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// StorefrontPasswordPath receives the password gate form
+	StorefrontPasswordPath = "/storefront-password"
+	storefrontAccessCookie = "storefront_access"
+	storefrontAccessTTL    = 30 * 24 * time.Hour
+
+	// Password attempts are counted in Redis: at most maxUnlocksPerIP from
+	// one IP per unlockIPWindow, and maxUnlocksPerShop per unlockShopWindow
+	// for the whole shop. Counted before the (slow) bcrypt check.
+	unlockKeyPrefix   = "storefront_password:"
+	maxUnlocksPerIP   = 10
+	unlockIPWindow    = 15 * time.Minute
+	maxUnlocksPerShop = 100
+	unlockShopWindow  = time.Minute
+)
+
+var (
+	errShopUnavailable    = errors.New("shop is unavailable")
+	errShopMaintenance    = errors.New("shop is under maintenance")
+	errStorefrontPassword = errors.New("storefront is password protected")
+)
+
+// Staff roles pass maintenance mode and the password gate
+var staffRoles = map[string]bool{"admin": true, "staff": true}
+
+// Paths that stay reachable in maintenance mode and behind the password gate,
+// so staff can still log in (or reset their password, or unlock their account)
+// and assets still load.
+var gateExemptPrefixes = []string{
+	"/static", "/uploads", "/admin", "/login", "/logout", "/api/v1/auth",
+	"/account/forgot-password", "/account/reset-password", "/account/verify-email", "/account/unlock",
+}
+
+// ShopGate enforces the shop's access state resolved by TenancyMiddleware
+// (cached with the tenant): suspension, maintenance mode and the storefront
+// password gate. It must run after TenancyMiddleware. attempts counts
+// password gate attempts (otp.RedisStore shares the codes' Redis).
+func ShopGate(tokenMaker token.Maker, sessionManager *sessions.Manager, secret string, cookies CookiePolicy, attempts otp.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tenant, ok := c.Locals("tenant").(*tenancy.Tenant)
+		if !ok || tenant.IsPlatform() {
+			return c.Next()
+		}
+
+		// Gated responses and pages of gated shops must never be shared
+		if tenant.Unavailable() || tenant.Maintenance || tenant.PasswordProtected() {
+			c.Locals("cache_skip", true)
+			c.Set(fiber.HeaderCacheControl, "no-store")
+		}
+
+		// 1. Suspended / Deactivated / Lapsed subscription: nothing is served
+		if tenant.Unavailable() {
+			c.Set(fiber.HeaderRetryAfter, "3600")
+			if isAPI(c) {
+				return util.APIError(c, fiber.StatusServiceUnavailable, errShopUnavailable)
+			}
+			c.Status(fiber.StatusServiceUnavailable)
+			return util.Render(c, root.ShopSuspended())
+		}
+
+		exempt := isGateExempt(c.Path())
+		staff := !exempt && (tenant.Maintenance || tenant.PasswordProtected()) && isStaff(c, tokenMaker, sessionManager)
+
+		// 2. Maintenance: staff and allowlisted IPs only
+		if tenant.Maintenance && !exempt && !staff && !tenant.AllowsIP(c.IP()) {
+			c.Set(fiber.HeaderRetryAfter, "600")
+			if isAPI(c) {
+				return util.APIError(c, fiber.StatusServiceUnavailable, errShopMaintenance)
+			}
+			c.Status(fiber.StatusServiceUnavailable)
+			return util.Render(c, root.ShopMaintenance())
+		}
+
+		// 3. Password gate (pre-launch)
+		if tenant.PasswordProtected() && !exempt && !staff {
+			if c.Path() == StorefrontPasswordPath && c.Method() == fiber.MethodPost {
+				return unlockStorefront(c, tenant, secret, cookies, attempts)
+			}
+			if !hmac.Equal([]byte(c.Cookies(storefrontAccessCookie)), []byte(storefrontAccess(tenant, secret))) {
+				if isAPI(c) {
+					return util.APIError(c, fiber.StatusUnauthorized, errStorefrontPassword)
+				}
+				c.Status(fiber.StatusUnauthorized)
+				return util.Render(c, root.StorefrontPassword(c.OriginalURL(), ""))
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// unlockStorefront checks the submitted password and sets the access cookie.
+// Attempts are rate limited per client IP and per shop.
+func unlockStorefront(c *fiber.Ctx, tenant *tenancy.Tenant, secret string, cookies CookiePolicy, attempts otp.Store) error {
+	next := LocalPath(c.FormValue("next"))
+
+	limits := []struct {
+		key    string
+		max    int64
+		window time.Duration
+	}{
+		{unlockKeyPrefix + tenant.TenantID + ":ip:" + c.IP(), maxUnlocksPerIP, unlockIPWindow},
+		{unlockKeyPrefix + tenant.TenantID + ":shop", maxUnlocksPerShop, unlockShopWindow},
+	}
+	for _, limit := range limits {
+		n, err := attempts.Incr(c.Context(), limit.key, limit.window)
+		if err != nil {
+			return err
+		}
+		if n > limit.max {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(limit.window.Seconds())))
+			c.Status(fiber.StatusTooManyRequests)
+			return util.Render(c, root.StorefrontPassword(next, "Too many attempts, try again later."))
+		}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(tenant.PasswordHash), []byte(c.FormValue("password"))) != nil {
+		c.Status(fiber.StatusUnauthorized)
+		return util.Render(c, root.StorefrontPassword(next, "Incorrect password."))
+	}
+
+	c.Cookie(cookies.cookie(storefrontAccessCookie, storefrontAccess(tenant, secret), time.Now().Add(storefrontAccessTTL)))
+	return c.Redirect(next)
+}
+
+// storefrontAccess derives the access cookie value. It is bound to the tenant
+// and the current password hash, so changing the password locks everyone out.
+func storefrontAccess(tenant *tenancy.Tenant, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(tenant.TenantID + "|" + tenant.PasswordHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isStaff verifies the session cookies directly, the gate runs before Auth.
+// An expired access token falls back to the refresh token, which Auth then
+// rotates.
+func isStaff(c *fiber.Ctx, tokenMaker token.Maker, sessionManager *sessions.Manager) bool {
+	if tokenString := c.Cookies(AccessCookie); tokenString != "" {
+		payload, err := tokenMaker.VerifyToken(tokenString)
+		if err == nil {
+			return staffRoles[payload.Role] && sessionManager.Check(c.Context(), payload) == nil
+		}
+	}
+	if refresh := c.Cookies(RefreshCookie); refresh != "" {
+		session, err := sessionManager.Lookup(c.Context(), refresh)
+		return err == nil && staffRoles[session.Role]
+	}
+	return false
+}
+
+func isGateExempt(path string) bool {
+	for _, prefix := range gateExemptPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isAPI(c *fiber.Ctx) bool {
+	return strings.HasPrefix(c.Path(), "/api/")
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"bizbundl/internal/otp"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testKey = "12345678901234567890123456789012"
+
+func newGateApp(t *testing.T, tenant *tenancy.Tenant) (*fiber.App, *sessions.Manager) {
+	maker, err := token.NewPasetoMaker(testKey)
+	require.NoError(t, err)
+	sessionManager := sessions.NewManager(sessions.NewMemoryStore(), maker, time.Minute, time.Hour)
+
+	app := fiber.New(fiber.Config{ProxyHeader: fiber.HeaderXForwardedFor})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("tenant", tenant)
+		return c.Next()
+	})
+	app.Use(ShopGate(maker, sessionManager, testKey, CookiePolicy{Secure: true}, otp.NewMemoryStore()))
+	app.Get("/*", func(c *fiber.Ctx) error { return c.SendString("storefront") })
+	return app, sessionManager
+}
+
+func TestShopGateSuspended(t *testing.T) {
+	app, _ := newGateApp(t, &tenancy.Tenant{TenantID: "shop_a", IsActive: true, Suspended: true})
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, res.StatusCode)
+
+	// Staff and exempt paths do not get through a suspension
+	res, err = app.Test(httptest.NewRequest(http.MethodGet, "/admin", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestShopGateMaintenance(t *testing.T) {
+	tenant := &tenancy.Tenant{TenantID: "shop_a", IsActive: true, Maintenance: true}
+	app, sessionManager := newGateApp(t, tenant)
+	ctx := context.WithValue(context.Background(), tenancy.ContextKey, tenant)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, res.StatusCode)
+
+	// Login stays reachable
+	res, err = app.Test(httptest.NewRequest(http.MethodGet, "/login", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, res.StatusCode)
+
+	// Staff session passes, customer session does not
+	for role, status := range map[string]int{"admin": fiber.StatusOK, "customer": fiber.StatusServiceUnavailable} {
+		tokens, _, err := sessionManager.Issue(ctx, "user-1", role)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: AccessCookie, Value: tokens.Access})
+		res, err = app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, status, res.StatusCode, role)
+
+		// Once the access token has expired, the refresh token still counts
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: RefreshCookie, Value: tokens.Refresh})
+		res, err = app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, status, res.StatusCode, role)
+	}
+}
+
+func TestShopGatePassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("launch-day"), bcrypt.MinCost)
+	require.NoError(t, err)
+	app, _ := newGateApp(t, &tenancy.Tenant{TenantID: "shop_a", IsActive: true, PasswordHash: string(hash)})
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/product/x", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, res.StatusCode)
+
+	unlock := func(password, next string) *http.Response {
+		form := url.Values{"password": {password}, "next": {next}}
+		req := httptest.NewRequest(http.MethodPost, StorefrontPasswordPath, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	assert.Equal(t, fiber.StatusUnauthorized, unlock("wrong", "/").StatusCode)
+
+	// Open redirects fall back to "/"
+	res = unlock("launch-day", "//evil.example.com")
+	assert.Equal(t, fiber.StatusFound, res.StatusCode)
+	assert.Equal(t, "/", res.Header.Get("Location"))
+
+	var access *http.Cookie
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == storefrontAccessCookie {
+			access = cookie
+		}
+	}
+	require.NotNil(t, access)
+	assert.True(t, access.Secure)
+	assert.True(t, access.HttpOnly)
+
+	req := httptest.NewRequest(http.MethodGet, "/product/x", nil)
+	req.AddCookie(access)
+	res, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, res.StatusCode)
+}
+
+func TestShopGatePasswordRateLimit(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("launch-day"), bcrypt.MinCost)
+	require.NoError(t, err)
+	app, _ := newGateApp(t, &tenancy.Tenant{TenantID: "shop_a", IsActive: true, PasswordHash: string(hash)})
+
+	unlock := func(password, ip string) *http.Response {
+		form := url.Values{"password": {password}}
+		req := httptest.NewRequest(http.MethodPost, StorefrontPasswordPath, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fiber.HeaderXForwardedFor, ip)
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	for i := 0; i < maxUnlocksPerIP; i++ {
+		assert.Equal(t, fiber.StatusUnauthorized, unlock("wrong", "10.0.0.1").StatusCode)
+	}
+	res := unlock("launch-day", "10.0.0.1")
+	assert.Equal(t, fiber.StatusTooManyRequests, res.StatusCode, "even with the right password")
+	assert.NotEmpty(t, res.Header.Get("Retry-After"))
+
+	// Other IPs still get through, until the shop sees too many attempts
+	assert.Equal(t, fiber.StatusFound, unlock("launch-day", "10.0.0.2").StatusCode)
+	for i := maxUnlocksPerIP + 1; i < maxUnlocksPerShop; i++ {
+		unlock("wrong", fmt.Sprintf("10.0.1.%d", i))
+	}
+	assert.Equal(t, fiber.StatusTooManyRequests, unlock("launch-day", "10.0.0.3").StatusCode)
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StepUpPath is the page where platform users re-authenticate for
+// RequireStepUp
+const StepUpPath = "/step-up"
+
+var (
+	errStepUpRequired    = errors.New("confirm it's you to continue")
+	errTwoFactorRequired = errors.New("this shop requires two-factor authentication, turn it on for your account and sign in again")
+)
+
+// RequireStepUp guards sensitive actions ("sudo mode"): the signed-in user
+// must have proved who they are within maxAge, with their second factor if
+// they have one. Otherwise APIs get 403 and pages are sent to StepUpPath,
+// which brings the user back. It must run after Auth.
+func RequireStepUp(sessionManager *sessions.Manager, maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		session, err := currentSession(c, sessionManager)
+		if errors.Is(err, errNotAuthenticated) {
+			if isAPI(c) {
+				return util.APIError(c, fiber.StatusUnauthorized, err)
+			}
+			return c.Redirect("/login")
+		}
+		if err != nil {
+			return util.APIError(c, fiber.StatusInternalServerError, err)
+		}
+		if session.AuthenticatedWithin(maxAge) {
+			return c.Next()
+		}
+
+		if isAPI(c) {
+			return util.APIError(c, fiber.StatusForbidden, errStepUpRequired)
+		}
+		// Forms cannot be replayed: come back to the page they were on
+		next := c.OriginalURL()
+		if c.Method() != fiber.MethodGet {
+			next = "/"
+			if referer, err := url.Parse(c.Get(fiber.HeaderReferer)); err == nil && referer.Host == c.Hostname() {
+				next = referer.RequestURI()
+			}
+		}
+		target := StepUpPath + "?next=" + url.QueryEscape(next)
+		if c.Get("HX-Request") == "true" {
+			c.Set("HX-Redirect", target)
+			return c.SendStatus(fiber.StatusForbidden)
+		}
+		return c.Redirect(target)
+	}
+}
+
+// RequireTwoFactor enforces a shop's two-factor requirement: its staff get
+// in only with sessions that passed a second factor. Customers are not
+// affected. It must run after Auth.
+func RequireTwoFactor(sessionManager *sessions.Manager) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tenant, ok := c.Locals("tenant").(*tenancy.Tenant)
+		role, _ := c.Locals("user_role").(string)
+		if !ok || !tenant.RequireTwoFactor || !staffRoles[role] {
+			return c.Next()
+		}
+
+		session, err := currentSession(c, sessionManager)
+		if err == nil && session.TwoFactor {
+			return c.Next()
+		}
+		if err != nil && !errors.Is(err, errNotAuthenticated) {
+			return util.APIError(c, fiber.StatusInternalServerError, err)
+		}
+		if isAPI(c) {
+			return util.APIError(c, fiber.StatusForbidden, errTwoFactorRequired)
+		}
+		return c.Status(fiber.StatusForbidden).SendString(errTwoFactorRequired.Error())
+	}
+}
+
+// currentSession returns the session the request is signed in with
+func currentSession(c *fiber.Ctx, sessionManager *sessions.Manager) (sessions.Session, error) {
+	userID, _ := c.Locals("user_id").(string)
+	sessionID, _ := c.Locals("session_id").(string)
+	if userID == "" || sessionID == "" {
+		return sessions.Session{}, errNotAuthenticated
+	}
+	session, err := sessionManager.Get(c.Context(), userID, sessionID)
+	if errors.Is(err, sessions.ErrNotFound) {
+		return sessions.Session{}, errNotAuthenticated
+	}
+	return session, err
+}
+
+// LocalPath returns next if it is a path on this site, "/" otherwise, so
+// it is safe to redirect to
+func LocalPath(next string) string {
+	if u, err := url.Parse(next); err != nil || next == "" || u.IsAbs() || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStepUpApp(t *testing.T, tenant *tenancy.Tenant) (*fiber.App, *sessions.Manager) {
+	maker, err := token.NewPasetoMaker(testKey)
+	require.NoError(t, err)
+	sessionManager := sessions.NewManager(sessions.NewMemoryStore(), maker, time.Minute, time.Hour)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("tenant", tenant)
+		return c.Next()
+	})
+	app.Use(Auth(maker, sessionManager, nil, NewCookiePolicy("production"), time.Hour, time.Hour))
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	app.Get("/api/secret", RequireStepUp(sessionManager, time.Minute), ok)
+	// A zero window treats every session as signed in too long ago
+	app.Get("/api/stale", RequireStepUp(sessionManager, 0), ok)
+	app.Post("/dashboard/shops/1/delete", RequireStepUp(sessionManager, 0), ok)
+	app.Get("/admin", RequireTwoFactor(sessionManager), ok)
+	return app, sessionManager
+}
+
+func send(t *testing.T, app *fiber.App, method, path, access string, header ...string) *http.Response {
+	req := httptest.NewRequest(method, path, nil)
+	req.Host = "shop.test"
+	if access != "" {
+		req.AddCookie(&http.Cookie{Name: AccessCookie, Value: access})
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := app.Test(req)
+	require.NoError(t, err)
+	return res
+}
+
+func TestRequireStepUp(t *testing.T) {
+	tenant := &tenancy.Tenant{TenantID: "shop_a", IsActive: true}
+	app, sessionManager := newStepUpApp(t, tenant)
+	ctx := context.WithValue(context.Background(), tenancy.ContextKey, tenant)
+
+	assert.Equal(t, fiber.StatusUnauthorized, send(t, app, http.MethodGet, "/api/secret", "").StatusCode)
+
+	// Just signed in: allowed
+	tokens, _, err := sessionManager.Issue(ctx, "user-1", "admin")
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, send(t, app, http.MethodGet, "/api/secret", tokens.Access).StatusCode)
+
+	// Signed in too long ago: re-authenticate first
+	assert.Equal(t, fiber.StatusForbidden, send(t, app, http.MethodGet, "/api/stale", tokens.Access).StatusCode)
+}
+
+func TestRequireStepUpRedirect(t *testing.T) {
+	tenant := &tenancy.Tenant{TenantID: "shop_a", IsActive: true}
+	app, sessionManager := newStepUpApp(t, tenant)
+	ctx := context.WithValue(context.Background(), tenancy.ContextKey, tenant)
+	tokens, _, err := sessionManager.Issue(ctx, "user-1", "owner")
+	require.NoError(t, err)
+
+	// Pages come back to where the form was, never off-site
+	res := send(t, app, http.MethodPost, "/dashboard/shops/1/delete", tokens.Access, "Referer", "http://shop.test/dashboard?tab=shops")
+	assert.Equal(t, fiber.StatusFound, res.StatusCode)
+	assert.Equal(t, StepUpPath+"?next=%2Fdashboard%3Ftab%3Dshops", res.Header.Get("Location"))
+
+	res = send(t, app, http.MethodPost, "/dashboard/shops/1/delete", tokens.Access, "Referer", "http://evil.test/", "HX-Request", "true")
+	assert.Equal(t, fiber.StatusForbidden, res.StatusCode)
+	assert.Equal(t, StepUpPath+"?next=%2F", res.Header.Get("HX-Redirect"))
+}
+
+func TestRequireTwoFactor(t *testing.T) {
+	tenant := &tenancy.Tenant{TenantID: "shop_a", IsActive: true, RequireTwoFactor: true}
+	app, sessionManager := newStepUpApp(t, tenant)
+	ctx := context.WithValue(context.Background(), tenancy.ContextKey, tenant)
+
+	// Customers are not affected
+	customer, _, err := sessionManager.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, send(t, app, http.MethodGet, "/admin", customer.Access).StatusCode)
+
+	staff, session, err := sessionManager.Issue(ctx, "user-2", "staff")
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, send(t, app, http.MethodGet, "/admin", staff.Access).StatusCode)
+
+	// Passing a second factor (enrolling, or a step-up) lets them in
+	require.NoError(t, sessionManager.StepUp(ctx, "user-2", session.ID, true))
+	assert.Equal(t, fiber.StatusOK, send(t, app, http.MethodGet, "/admin", staff.Access).StatusCode)
+
+	twoFactor, _, err := sessionManager.IssueTwoFactor(ctx, "user-3", "admin")
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, send(t, app, http.MethodGet, "/admin", twoFactor.Access).StatusCode)
+}
+
+func TestLocalPath(t *testing.T) {
+	for next, want := range map[string]string{
+		"/dashboard?x=1":       "/dashboard?x=1",
+		"":                     "/",
+		"//evil.example.com":   "/",
+		"/\\evil.example.com":  "/",
+		"https://evil.example": "/",
+		"dashboard":            "/",
+	} {
+		assert.Equal(t, want, LocalPath(next), next)
+	}
+}
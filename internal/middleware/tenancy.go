@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"bizbundl/internal/db/cluster"
 	db "bizbundl/internal/db/sqlc"
 	"bizbundl/internal/infra/redis"
+	root "bizbundl/internal/platform/root/view"
+	"bizbundl/internal/tenancy"
+	"bizbundl/util"
 	"context"
-	"fmt"
+	"errors"
 	"regexp"
 	"strings"
 
@@ -13,36 +17,57 @@ import (
 
 var validTenantID = regexp.MustCompile(`^[a-z0-9_]+$`)
 
-// TenancyMiddleware wraps the request in a transaction with the correct search_path
-func TenancyMiddleware(store db.DBStore) fiber.Handler {
+var (
+	errShopUpgrading = errors.New("shop is being upgraded, retry shortly")
+	errShopFrozen    = errors.New("shop is being moved, changes are paused for a moment")
+)
+
+// TenancyMiddleware wraps the request in a transaction with the correct search_path,
+// on the database cluster holding the tenant's schema.
+// Tenants outside this binary's cohort (see tenancy.VersionPolicy) are turned away
+// before any query runs against their schema.
+func TenancyMiddleware(clusters *cluster.Registry, resolver *tenancy.Resolver, versions tenancy.VersionPolicy) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// 1. Identify Tenant
+		// 1. Identify Tenant (Host -> Shop Registry, cached)
 		host := c.Hostname()
-		// Simple subdomain extraction for MVP: shop1.localhost -> shop1
-		// In production, use a proper mapping or Redis lookup
-		tenantID := extractTenantID(host)
+		tenant, err := resolver.Resolve(c.UserContext(), host)
+		if errors.Is(err, tenancy.ErrShopNotFound) {
+			return shopNotFound(c, host)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("Tenant Lookup Error")
+		}
+		if !versions.Serves(tenant) {
+			return misdirected(c, versions, tenant)
+		}
+		// A tenant being moved between clusters only serves reads (GET routes
+		// which write are marked with Writes)
+		if tenant.Frozen && !isSafeMethod(c.Method()) {
+			return frozen(c)
+		}
+		tenantID := tenant.TenantID
 
 		// 2. Validate TenantID (Prevent SQL Injection)
+		// Registry values are generated by us, but never trust them blindly.
 		if !validTenantID.MatchString(tenantID) {
-			// Fallback to public or error?
-			// For now, default to 'public' for system routes, or error
-			tenantID = "public"
+			return c.Status(fiber.StatusInternalServerError).SendString("Invalid Tenant")
 		}
 
-		// 3. Begin Transaction
-		pool := store.GetPool()
+		// 3. Begin Transaction on the tenant's cluster
 		ctx := c.UserContext()
+		pool, err := clusters.Pool(ctx, tenant.Cluster)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("Database Error")
+		}
 		tx, err := pool.Begin(ctx)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("Database Error")
 		}
 
 		// 4. Set Schema
-		// Note: SET search_path cannot be easily parameterized in simple SQL.
-		// Since we validated tenantID against strict regex, Sprintf is safe-ish.
-		// Ideally use QuoteIdentifier.
-		query := fmt.Sprintf("SET search_path TO %s, public", tenantID)
-		if _, err := tx.Exec(ctx, query); err != nil {
+		// SET LOCAL, so the search_path ends with the transaction instead of
+		// staying on the pooled connection for whoever borrows it next.
+		if err := db.SetSearchPath(ctx, tx, tenantID); err != nil {
 			tx.Rollback(ctx)
 			return c.Status(fiber.StatusInternalServerError).SendString("Schema Error")
 		}
@@ -50,10 +75,15 @@ func TenancyMiddleware(store db.DBStore) fiber.Handler {
 		// 5. Inject Tx into Context
 		ctxWithTx := context.WithValue(ctx, db.TxKey, tx)
 		ctxWithTenant := context.WithValue(ctxWithTx, redis.TenantKey, tenantID)
+		ctxWithTenant = context.WithValue(ctxWithTenant, tenancy.ContextKey, tenant)
 		c.SetUserContext(ctxWithTenant)
 
-		// 6. Inject TenantID for Redis keys (Fiber Locals for Handler access)
+		// 6. Inject TenantID for Redis keys (Fiber Locals for Handler access).
+		// The transaction too: handlers pass c.Context(), which reads Locals,
+		// so their queries and audit entries commit or roll back together.
 		c.Locals("tenant_id", tenantID)
+		c.Locals("tenant", tenant)
+		c.Locals(db.TxKey, tx)
 
 		// 7. Next Handler
 		if err := c.Next(); err != nil {
@@ -75,25 +105,57 @@ func TenancyMiddleware(store db.DBStore) fiber.Handler {
 	}
 }
 
-func extractTenantID(host string) string {
-	// Strip port if present
-	if strings.Contains(host, ":") {
-		host = strings.Split(host, ":")[0]
+// shopNotFound responds for hosts that do not belong to any shop
+func shopNotFound(c *fiber.Ctx, host string) error {
+	if strings.HasPrefix(c.Path(), "/api/") {
+		return util.APIError(c, fiber.StatusNotFound, tenancy.ErrShopNotFound)
+	}
+	c.Status(fiber.StatusNotFound)
+	return util.Render(c, root.ShopNotFound(tenancy.NormalizeHost(host)))
+}
+
+// misdirected responds for tenants pinned to another app or schema version.
+// The routing header tells an upstream proxy where the tenant lives now.
+func misdirected(c *fiber.Ctx, versions tenancy.VersionPolicy, tenant *tenancy.Tenant) error {
+	if versions.Header != "" {
+		c.Set(versions.Header, versions.RouteHint(tenant))
+	}
+	c.Set(fiber.HeaderRetryAfter, "5")
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if strings.HasPrefix(c.Path(), "/api/") {
+		return util.APIError(c, fiber.StatusMisdirectedRequest, errShopUpgrading)
+	}
+	c.Status(fiber.StatusMisdirectedRequest)
+	return util.Render(c, root.ShopUpgrading())
+}
+
+// Writes marks a GET route that changes the tenant's data, such as a link
+// using up a token or an OAuth callback signing someone in, so a frozen
+// tenant refuses it like any other write
+func Writes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if tenant, ok := c.Locals("tenant").(*tenancy.Tenant); ok && tenant.Frozen {
+			return frozen(c)
+		}
+		return c.Next()
 	}
+}
 
-	// Handle Development / Localhost defaults
-	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
-		return "public"
+// frozen responds to writes against a tenant that is being moved
+func frozen(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, "30")
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if strings.HasPrefix(c.Path(), "/api/") {
+		return util.APIError(c, fiber.StatusServiceUnavailable, errShopFrozen)
 	}
+	c.Status(fiber.StatusServiceUnavailable)
+	return util.Render(c, root.ShopUpgrading())
+}
 
-	parts := strings.Split(host, ".")
-	if len(parts) > 0 {
-		// If it's an IP address (numeric), likely we should treat as public or handle differently
-		// For MVP, assuming subdomains are alphabetic.
-		// "192.168.1.1" -> "192" -> valid regex? yes.
-		// Let's explicitly check if it looks like a shop subdomain.
-		// For now, the explicit check above handles local dev.
-		return parts[0]
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return true
 	}
-	return "public"
+	return false
 }
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bizbundl/internal/db/cluster"
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/tenancy"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oneShop is a shop registry holding a single shop
+type oneShop struct {
+	shop platformdb.Shop
+}
+
+func (o oneShop) GetShopBySubdomain(ctx context.Context, subdomain string) (platformdb.Shop, error) {
+	if subdomain != o.shop.Subdomain {
+		return platformdb.Shop{}, pgx.ErrNoRows
+	}
+	return o.shop, nil
+}
+
+func (o oneShop) GetShopByCustomDomain(ctx context.Context, customDomain *string) (platformdb.Shop, error) {
+	return platformdb.Shop{}, pgx.ErrNoRows
+}
+
+func (o oneShop) GetTenantMigration(ctx context.Context, tenantID string) (platformdb.TenantMigration, error) {
+	return platformdb.TenantMigration{}, pgx.ErrNoRows
+}
+
+func (o oneShop) GetLatestSubscriptionByShop(ctx context.Context, shopID pgtype.UUID) (platformdb.Subscription, error) {
+	return platformdb.Subscription{}, pgx.ErrNoRows
+}
+
+func TestTenancyMiddlewareClusters(t *testing.T) {
+	active := true
+	shop := platformdb.Shop{
+		Subdomain: "neon",
+		TenantID:  "shop_neon",
+		IsActive:  &active,
+		DbCluster: "eu1",
+	}
+	newApp := func(shop platformdb.Shop) *fiber.App {
+		resolver := tenancy.NewResolver(oneShop{shop}, nil, "bizbundl.com")
+		// No cluster is configured besides main, which has no pool here
+		clusters := cluster.NewRegistry(nil, map[string]string{cluster.Main: "postgresql://localhost/bizbundl"})
+
+		app := fiber.New()
+		app.Use(TenancyMiddleware(clusters, resolver, tenancy.VersionPolicy{}))
+		app.All("/*", func(c *fiber.Ctx) error { return c.SendString("ok") })
+		return app
+	}
+	request := func(app *fiber.App, method string) *http.Response {
+		req := httptest.NewRequest(method, "http://neon.bizbundl.com/api/v1/cart", nil)
+		res, err := app.Test(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	// A shop on a cluster this node does not know is unavailable, not served from main
+	app := newApp(shop)
+	assert.Equal(t, fiber.StatusServiceUnavailable, request(app, http.MethodGet).StatusCode)
+
+	// Writes to a frozen shop are refused before any database work
+	shop.DbCluster = cluster.Main
+	shop.FrozenAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	app = newApp(shop)
+	res := request(app, http.MethodPost)
+	assert.Equal(t, fiber.StatusServiceUnavailable, res.StatusCode)
+	assert.Equal(t, "30", res.Header.Get(fiber.HeaderRetryAfter))
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), errShopFrozen.Error())
+}
+
+func TestWrites(t *testing.T) {
+	tenant := &tenancy.Tenant{TenantID: "shop_a", IsActive: true}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("tenant", tenant)
+		return c.Next()
+	})
+	app.Get("/account/verify-email", Writes(), func(c *fiber.Ctx) error { return c.SendString("verified") })
+	get := func() int {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/account/verify-email", nil))
+		require.NoError(t, err)
+		return res.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusOK, get())
+
+	// GET routes which write are refused like other writes while frozen
+	tenant.Frozen = true
+	assert.Equal(t, fiber.StatusServiceUnavailable, get())
+}
@@ -8,6 +8,7 @@ type PaymentInfo struct {
 	TransactionID string
 	Status        string // "COMPLETED", "PENDING", "FAILED"
 	Amount        float64
+	OrderID       string // hex ID of the order the payment was initiated for
 }
 
 type Gateway interface {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	db "bizbundl/internal/db/sqlc"
@@ -17,14 +18,25 @@ const (
 )
 
 type UddoktaPay struct {
-	APIKey  string
-	BaseURL string
+	APIKey    string
+	BaseURL   string
+	VerifyURL string
+
+	// Where the customer and the webhook are sent. %s in RedirectURL is
+	// replaced by the order ID (hex).
+	RedirectURL string
+	CancelURL   string
+	WebhookURL  string
 }
 
 func New(apiKey string) *UddoktaPay {
 	return &UddoktaPay{
-		APIKey:  apiKey,
-		BaseURL: BaseURL, // Default
+		APIKey:      apiKey,
+		BaseURL:     BaseURL, // Default
+		VerifyURL:   VerifyURL,
+		RedirectURL: "http://localhost:8080/order/payment/callback?order_id=%s", // Using Callback handler
+		CancelURL:   "http://localhost:8080/cart",
+		WebhookURL:  "http://localhost:8080/api/v1/payment/webhook", // Needs public URL locally (ngrok)
 	}
 }
 
@@ -54,9 +66,9 @@ func (u *UddoktaPay) InitPayment(order *db.Order, customerEmail string) (string,
 		FullName:    "Customer", // TODO: Get from User
 		Email:       customerEmail,
 		Amount:      fmt.Sprintf("%.2f", amount.Float64),
-		RedirectURL: fmt.Sprintf("http://localhost:8080/order/payment/callback?order_id=%x", order.ID.Bytes), // Using Callback handler
-		CancelURL:   "http://localhost:8080/cart",
-		WebhookURL:  "http://localhost:8080/api/v1/payment/webhook", // Needs public URL locally (ngrok)
+		RedirectURL: fmt.Sprintf(u.RedirectURL, fmt.Sprintf("%x", order.ID.Bytes)),
+		CancelURL:   u.CancelURL,
+		WebhookURL:  u.WebhookURL,
 	}
 	reqBody.Metadata.OrderID = fmt.Sprintf("%x", order.ID.Bytes)
 
@@ -86,20 +98,20 @@ func (u *UddoktaPay) InitPayment(order *db.Order, customerEmail string) (string,
 }
 
 type verifyResponse struct {
-	Status   bool `json:"status"` // api status
-	MetaData struct {
-		OrderID string `json:"order_id"`
-	} `json:"metadata"`
+	Status        string `json:"status"` // payment status: COMPLETED, PENDING, ERROR
+	Message       string `json:"message"`
 	TransactionID string `json:"transaction_id"`
 	Amount        string `json:"amount"`
-	StatusStr     string `json:"status"` // payment status: COMPLETED
+	Metadata      struct {
+		OrderID string `json:"order_id"`
+	} `json:"metadata"`
 }
 
 func (u *UddoktaPay) VerifyPayment(invoiceID string) (*payment.PaymentInfo, error) {
 	verifyReq := map[string]string{"invoice_id": invoiceID}
 	jsonBody, _ := json.Marshal(verifyReq)
 
-	req, _ := http.NewRequest("POST", VerifyURL, bytes.NewBuffer(jsonBody))
+	req, _ := http.NewRequest("POST", u.VerifyURL, bytes.NewBuffer(jsonBody))
 	req.Header.Set("RT-UDDOKTAPAY-API-KEY", u.APIKey)
 	req.Header.Set("Content-Type", "application/json")
 
@@ -110,20 +122,19 @@ func (u *UddoktaPay) VerifyPayment(invoiceID string) (*payment.PaymentInfo, erro
 	}
 	defer resp.Body.Close()
 
-	// Note: UddoktaPay verify response structure needs careful checking against docs.
-	// Assuming standard success structure.
-	var res struct {
-		Status        bool   `json:"status"`
-		Message       string `json:"message"`
-		TransactionID string `json:"transaction_id"` // or in payload?
-		PaymentStatus string `json:"status"`         // Field name collision in struct? No, usually distinct.
-		// Let's debug response if fails.
+	var res verifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api error: %s", res.Message)
 	}
-	_ = res
-	// Actually verify endpoint usually returns payment details.
-	// I'll create a generic map decode first to be safe or use simple struct.
 
+	amount, _ := strconv.ParseFloat(res.Amount, 64)
 	return &payment.PaymentInfo{
-		Status: "COMPLETED", // Mock for now if API isn't live
+		TransactionID: res.TransactionID,
+		Status:        res.Status,
+		Amount:        amount,
+		OrderID:       res.Metadata.OrderID,
 	}, nil
 }
@@ -86,3 +86,27 @@ func TestInitPayment_Error(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "Invalid API Key")
 }
+
+func TestVerifyPayment(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-api-key", r.Header.Get("RT-UDDOKTAPAY-API-KEY"))
+
+		var reqBody map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+		assert.Equal(t, "inv_123", reqBody["invoice_id"])
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"COMPLETED","transaction_id":"TX9","amount":"990.00","metadata":{"order_id":"550e8400e29b41d4a716446655440000"}}`))
+	}))
+	defer mockServer.Close()
+
+	provider := New("test-api-key")
+	provider.VerifyURL = mockServer.URL
+
+	info, err := provider.VerifyPayment("inv_123")
+	assert.NoError(t, err)
+	assert.Equal(t, "COMPLETED", info.Status)
+	assert.Equal(t, "TX9", info.TransactionID)
+	assert.Equal(t, 990.0, info.Amount)
+	assert.Equal(t, "550e8400e29b41d4a716446655440000", info.OrderID)
+}
@@ -0,0 +1,347 @@
+// Package oauth signs users in with an external identity provider: the
+// OAuth 2.0 authorization code flow with PKCE, then the provider's userinfo
+// endpoint for who they are. OpenID Connect providers are configured with
+// their issuer and discovered; plain OAuth 2.0 providers (Facebook) with
+// their endpoints.
+package oauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"bizbundl/token"
+)
+
+const (
+	// StateTTL is how long a sign-in can take at the provider
+	StateTTL = 10 * time.Minute
+
+	discoveryTTL = time.Hour
+	// maxResponse bounds what is read from a provider
+	maxResponse = 1 << 20
+)
+
+var (
+	ErrInvalidState = errors.New("sign-in expired or was started in another browser, please try again")
+	ErrDenied       = errors.New("sign-in was cancelled at the provider")
+	ErrNoIdentity   = errors.New("the provider did not say who you are")
+)
+
+// Provider is an identity provider with a shop's client credentials
+type Provider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	// Issuer of an OpenID Connect provider, whose endpoints are discovered
+	Issuer string
+	// Endpoints of a plain OAuth 2.0 provider
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	Scopes      []string
+	// TrustEmails is set for providers that only return addresses they
+	// verified, without an email_verified claim
+	TrustEmails bool
+}
+
+// Presets are the providers shops only add their client credentials to
+var Presets = map[string]Provider{
+	"google": {
+		Name:   "google",
+		Issuer: "https://accounts.google.com",
+		Scopes: []string{"openid", "email", "profile"},
+	},
+	"facebook": {
+		Name:        "facebook",
+		AuthURL:     "https://www.facebook.com/v19.0/dialog/oauth",
+		TokenURL:    "https://graph.facebook.com/v19.0/oauth/access_token",
+		UserInfoURL: "https://graph.facebook.com/me?fields=id,name,email,first_name,last_name",
+		Scopes:      []string{"email", "public_profile"},
+		TrustEmails: true,
+	},
+}
+
+// Identity is who the provider says signed in. Subject is the provider's
+// stable ID for the user; Email may be empty.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// Callback is the query the provider redirects back with
+type Callback struct {
+	State string
+	Code  string
+	Error string
+}
+
+type Client struct {
+	http   *http.Client
+	states *token.Signer
+	key    []byte
+
+	mu         sync.Mutex
+	discovered map[string]discovery
+}
+
+type discovery struct {
+	provider  Provider
+	expiresAt time.Time
+}
+
+// NewClient builds the client. key signs the state kept in the browser
+// between Begin and Finish; httpClient calls the providers (nil for a
+// default with a timeout).
+func NewClient(key string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{
+		http:       httpClient,
+		states:     token.NewSigner(key, token.PurposeOAuthState),
+		key:        []byte(key),
+		discovered: map[string]discovery{},
+	}
+}
+
+// Begin starts a sign-in with p. It returns the provider URL to send the
+// browser to and the state to keep in a cookie for Finish. data comes back
+// from Finish unchanged; it is signed, not secret.
+func (c *Client) Begin(ctx context.Context, p Provider, redirectURI, data string) (authURL, state string, err error) {
+	p, err = c.resolve(ctx, p)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	u, err := url.Parse(p.AuthURL)
+	if err != nil {
+		return "", "", fmt.Errorf("oauth: invalid authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", nonce)
+	q.Set("code_challenge", challenge(c.verifier(nonce)))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), c.states.Sign(p.Name+"|"+nonce+"|"+data, StateTTL), nil
+}
+
+// Finish completes a sign-in at the callback: state is what Begin returned,
+// from the cookie. It returns the identity and Begin's data.
+func (c *Client) Finish(ctx context.Context, p Provider, redirectURI, state string, cb Callback) (Identity, string, error) {
+	subject, err := c.states.Verify(state)
+	if err != nil {
+		return Identity{}, "", ErrInvalidState
+	}
+	parts := strings.SplitN(subject, "|", 3)
+	if len(parts) != 3 || parts[0] != p.Name || !hmac.Equal([]byte(parts[1]), []byte(cb.State)) {
+		return Identity{}, "", ErrInvalidState
+	}
+	nonce, data := parts[1], parts[2]
+	if cb.Error != "" {
+		return Identity{}, "", ErrDenied
+	}
+	if cb.Code == "" {
+		return Identity{}, "", ErrInvalidState
+	}
+
+	p, err = c.resolve(ctx, p)
+	if err != nil {
+		return Identity{}, "", err
+	}
+	accessToken, err := c.exchange(ctx, p, redirectURI, cb.Code, c.verifier(nonce))
+	if err != nil {
+		return Identity{}, "", err
+	}
+	identity, err := c.userInfo(ctx, p, accessToken)
+	if err != nil {
+		return Identity{}, "", err
+	}
+	return identity, data, nil
+}
+
+// exchange trades the authorization code for an access token
+func (c *Client) exchange(ctx context.Context, p Provider, redirectURI, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var res struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := c.do(req, &res); err != nil && res.Error == "" {
+		return "", fmt.Errorf("oauth: token exchange with %s failed: %w", p.Name, err)
+	}
+	if res.Error != "" || res.AccessToken == "" {
+		return "", fmt.Errorf("oauth: token exchange with %s failed: %s %s", p.Name, res.Error, res.ErrorDescription)
+	}
+	return res.AccessToken, nil
+}
+
+// userInfo asks the provider who the access token belongs to
+func (c *Client) userInfo(ctx context.Context, p Provider, accessToken string) (Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.UserInfoURL, nil)
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var claims map[string]any
+	if err := c.do(req, &claims); err != nil {
+		return Identity{}, fmt.Errorf("oauth: userinfo from %s failed: %w", p.Name, err)
+	}
+	identity := Identity{
+		// OpenID Connect says sub, Facebook and most plain OAuth 2.0 APIs id
+		Subject:   firstClaim(claims, "sub", "id"),
+		Email:     firstClaim(claims, "email"),
+		FirstName: firstClaim(claims, "given_name", "first_name"),
+		LastName:  firstClaim(claims, "family_name", "last_name"),
+	}
+	if identity.Subject == "" {
+		return Identity{}, ErrNoIdentity
+	}
+	if identity.FirstName == "" {
+		identity.FirstName = firstClaim(claims, "name")
+	}
+	identity.EmailVerified = identity.Email != "" && (p.TrustEmails || firstClaim(claims, "email_verified") == "true")
+	return identity, nil
+}
+
+// resolve fills in the endpoints of an OpenID Connect provider from its
+// discovery document, which is cached for a while
+func (c *Client) resolve(ctx context.Context, p Provider) (Provider, error) {
+	if p.Issuer == "" {
+		return p, nil
+	}
+	issuer := strings.TrimSuffix(p.Issuer, "/")
+
+	c.mu.Lock()
+	cached, ok := c.discovered[issuer]
+	c.mu.Unlock()
+	if !ok || time.Now().After(cached.expiresAt) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+		if err != nil {
+			return Provider{}, err
+		}
+		var doc struct {
+			Issuer                string `json:"issuer"`
+			AuthorizationEndpoint string `json:"authorization_endpoint"`
+			TokenEndpoint         string `json:"token_endpoint"`
+			UserinfoEndpoint      string `json:"userinfo_endpoint"`
+		}
+		if err := c.do(req, &doc); err != nil {
+			return Provider{}, fmt.Errorf("oauth: discovery of %s failed: %w", issuer, err)
+		}
+		// A document served for another issuer is not to be trusted
+		if strings.TrimSuffix(doc.Issuer, "/") != issuer || doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserinfoEndpoint == "" {
+			return Provider{}, fmt.Errorf("oauth: invalid discovery document for %s", issuer)
+		}
+		cached = discovery{
+			provider:  Provider{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint, UserInfoURL: doc.UserinfoEndpoint},
+			expiresAt: time.Now().Add(discoveryTTL),
+		}
+		c.mu.Lock()
+		c.discovered[issuer] = cached
+		c.mu.Unlock()
+	}
+
+	p.AuthURL, p.TokenURL, p.UserInfoURL = cached.provider.AuthURL, cached.provider.TokenURL, cached.provider.UserInfoURL
+	if len(p.Scopes) == 0 {
+		p.Scopes = []string{"openid", "email", "profile"}
+	}
+	return p, nil
+}
+
+// do sends req and decodes the JSON response into v. Error responses are
+// decoded too (providers explain them in JSON) and reported with the status.
+func (c *Client) do(req *http.Request, v any) error {
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	decoder := json.NewDecoder(io.LimitReader(res.Body, maxResponse))
+	// Numeric IDs can be too large for a float64
+	decoder.UseNumber()
+	decodeErr := decoder.Decode(v)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", res.StatusCode)
+	}
+	return decodeErr
+}
+
+// verifier derives the PKCE code verifier of a sign-in from its state, so
+// nothing has to be stored between Begin and Finish
+func (c *Client) verifier(nonce string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte("pkce|" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// challenge is the S256 PKCE code challenge of verifier
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// firstClaim returns the first of names that is set, as a string
+func firstClaim(claims map[string]any, names ...string) string {
+	for _, name := range names {
+		switch v := claims[name].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case bool:
+			return fmt.Sprint(v)
+		case json.Number:
+			return v.String()
+		}
+	}
+	return ""
+}
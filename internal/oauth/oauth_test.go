@@ -0,0 +1,134 @@
+package oauth_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"bizbundl/internal/oauth"
+	"bizbundl/internal/oauth/oauthtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKey     = "12345678901234567890123456789012"
+	redirectURI = "https://shop.example.com/auth/oauth/test/callback"
+)
+
+func TestSignIn(t *testing.T) {
+	idp := oauthtest.NewServer()
+	defer idp.Close()
+	client := oauth.NewClient(testKey, idp.Client())
+	ctx := context.Background()
+	provider := idp.Provider("test")
+
+	authURL, state, err := client.Begin(ctx, provider, redirectURI, "/checkout")
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, idp.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path, "discovered")
+	assert.Equal(t, "openid email profile", u.Query().Get("scope"))
+
+	callback, err := idp.Authorize(authURL)
+	require.NoError(t, err)
+	identity, data, err := client.Finish(ctx, provider, redirectURI, state, callback)
+	require.NoError(t, err)
+	assert.Equal(t, "/checkout", data)
+	assert.Equal(t, oauth.Identity{
+		Subject:       "user-1",
+		Email:         "customer@example.com",
+		EmailVerified: true,
+		FirstName:     "Casey",
+		LastName:      "Customer",
+	}, identity)
+
+	// The code was used up
+	_, _, err = client.Finish(ctx, provider, redirectURI, state, callback)
+	assert.Error(t, err)
+}
+
+func TestFinishRejectsForeignState(t *testing.T) {
+	idp := oauthtest.NewServer()
+	defer idp.Close()
+	client := oauth.NewClient(testKey, idp.Client())
+	ctx := context.Background()
+	provider := idp.Provider("test")
+
+	authURL, state, err := client.Begin(ctx, provider, redirectURI, "")
+	require.NoError(t, err)
+	callback, err := idp.Authorize(authURL)
+	require.NoError(t, err)
+
+	// A callback for a sign-in this browser did not start (login CSRF)
+	_, otherState, err := client.Begin(ctx, provider, redirectURI, "")
+	require.NoError(t, err)
+	_, _, err = client.Finish(ctx, provider, redirectURI, otherState, callback)
+	assert.ErrorIs(t, err, oauth.ErrInvalidState)
+
+	// Started with another provider
+	_, _, err = client.Finish(ctx, idp.Provider("other"), redirectURI, state, callback)
+	assert.ErrorIs(t, err, oauth.ErrInvalidState)
+
+	// Signed with another key
+	_, _, err = oauth.NewClient("another-key-another-key-another-k", idp.Client()).Finish(ctx, provider, redirectURI, state, callback)
+	assert.ErrorIs(t, err, oauth.ErrInvalidState)
+
+	// Cancelled at the provider
+	_, _, err = client.Finish(ctx, provider, redirectURI, state, oauth.Callback{State: callback.State, Error: "access_denied"})
+	assert.ErrorIs(t, err, oauth.ErrDenied)
+}
+
+func TestPlainOAuth2(t *testing.T) {
+	idp := oauthtest.NewServer()
+	defer idp.Close()
+	// Facebook style: id instead of sub, no email_verified
+	idp.User = map[string]any{"id": "10203040506070809", "email": "fan@example.com", "name": "Fan"}
+	client := oauth.NewClient(testKey, idp.Client())
+	ctx := context.Background()
+
+	provider := oauth.Provider{
+		Name:         "plain",
+		ClientID:     oauthtest.ClientID,
+		ClientSecret: oauthtest.ClientSecret,
+		AuthURL:      idp.URL + "/authorize",
+		TokenURL:     idp.URL + "/token",
+		UserInfoURL:  idp.URL + "/userinfo",
+		Scopes:       []string{"email"},
+	}
+	authURL, state, err := client.Begin(ctx, provider, redirectURI, "")
+	require.NoError(t, err)
+	callback, err := idp.Authorize(authURL)
+	require.NoError(t, err)
+	identity, _, err := client.Finish(ctx, provider, redirectURI, state, callback)
+	require.NoError(t, err)
+	assert.Equal(t, "10203040506070809", identity.Subject)
+	assert.Equal(t, "Fan", identity.FirstName)
+	assert.False(t, identity.EmailVerified, "unverified unless the provider is trusted")
+
+	provider.TrustEmails = true
+	authURL, state, err = client.Begin(ctx, provider, redirectURI, "")
+	require.NoError(t, err)
+	callback, err = idp.Authorize(authURL)
+	require.NoError(t, err)
+	identity, _, err = client.Finish(ctx, provider, redirectURI, state, callback)
+	require.NoError(t, err)
+	assert.True(t, identity.EmailVerified)
+}
+
+func TestWrongClientSecret(t *testing.T) {
+	idp := oauthtest.NewServer()
+	defer idp.Close()
+	client := oauth.NewClient(testKey, idp.Client())
+	ctx := context.Background()
+	provider := idp.Provider("test")
+	provider.ClientSecret = "wrong"
+
+	authURL, state, err := client.Begin(ctx, provider, redirectURI, "")
+	require.NoError(t, err)
+	callback, err := idp.Authorize(authURL)
+	require.NoError(t, err)
+	_, _, err = client.Finish(ctx, provider, redirectURI, state, callback)
+	assert.ErrorContains(t, err, "invalid_client")
+}
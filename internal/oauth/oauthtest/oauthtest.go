@@ -0,0 +1,164 @@
+// Package oauthtest runs a local OpenID Connect provider for tests. It
+// signs in whoever User says, without a login page. It serves HTTPS with a
+// self-signed certificate: call it with Client().
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"bizbundl/internal/oauth"
+)
+
+const (
+	ClientID     = "test-client"
+	ClientSecret = "test-secret"
+)
+
+// Server is the provider. Its URL is the issuer.
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// User holds the userinfo claims of the next sign-in
+	User   map[string]any
+	grants map[string]grant
+	tokens map[string]map[string]any
+}
+
+// grant is an issued authorization code
+type grant struct {
+	challenge   string
+	redirectURI string
+	claims      map[string]any
+}
+
+// NewServer starts a provider signing in a user with a verified email
+func NewServer() *Server {
+	s := &Server{
+		User: map[string]any{
+			"sub":            "user-1",
+			"email":          "customer@example.com",
+			"email_verified": true,
+			"given_name":     "Casey",
+			"family_name":    "Customer",
+		},
+		grants: map[string]grant{},
+		tokens: map[string]map[string]any{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("GET /userinfo", s.userInfo)
+	s.Server = httptest.NewTLSServer(mux)
+	return s
+}
+
+// Provider is the provider as a shop would configure it
+func (s *Server) Provider(name string) oauth.Provider {
+	return oauth.Provider{Name: name, ClientID: ClientID, ClientSecret: ClientSecret, Issuer: s.URL}
+}
+
+// Authorize plays the browser at the provider: it opens authURL and returns
+// the callback the provider redirects back with
+func (s *Server) Authorize(authURL string) (oauth.Callback, error) {
+	client := *s.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	res, err := client.Get(authURL)
+	if err != nil {
+		return oauth.Callback{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		return oauth.Callback{}, fmt.Errorf("authorize: status %d", res.StatusCode)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return oauth.Callback{}, err
+	}
+	q := location.Query()
+	return oauth.Callback{State: q.Get("state"), Code: q.Get("code"), Error: q.Get("error")}, nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code := random()
+	s.mu.Lock()
+	s.grants[code] = grant{challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri"), claims: s.User}
+	s.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("client_id") != ClientID || r.FormValue("client_secret") != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := r.FormValue("code")
+	g, ok := s.grants[code]
+	delete(s.grants, code) // codes work once
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || g.redirectURI != r.FormValue("redirect_uri") || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+	accessToken := random()
+	s.tokens[accessToken] = g.claims
+	writeJSON(w, http.StatusOK, map[string]any{"access_token": accessToken, "token_type": "Bearer"})
+}
+
+func (s *Server) userInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	claims, ok := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func random() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,184 @@
+// Package otp sends one-time codes by SMS and checks them. Codes are stored
+// hashed in Redis, expire after CodeTTL and allow maxAttempts guesses.
+// Requests are rate limited per phone number and per client IP.
+package otp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"bizbundl/internal/infra/sms"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+)
+
+const (
+	// CodeTTL is how long a code can be used
+	CodeTTL     = 5 * time.Minute
+	codeDigits  = 6
+	maxAttempts = 5
+
+	// resendCooldown is the minimum time between two codes to one number
+	resendCooldown = time.Minute
+	// At most maxPerPhone codes to a number and maxPerIP codes requested
+	// from one IP, per rateWindow
+	rateWindow  = time.Hour
+	maxPerPhone = 5
+	maxPerIP    = 20
+)
+
+var (
+	// ErrNotFound is returned by Store.Get for missing or expired keys
+	ErrNotFound        = errors.New("otp: key not found")
+	ErrRateLimited     = errors.New("too many codes requested, try again later")
+	ErrInvalidCode     = errors.New("the code is wrong or has expired")
+	ErrTooManyAttempts = errors.New("too many wrong codes, request a new one")
+)
+
+// Store keeps codes and counters, each with its own expiry
+type Store interface {
+	// Incr counts one event under key and returns the count so far. The
+	// count resets window after the first event.
+	Incr(ctx context.Context, key string, window time.Duration) (int64, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Get(ctx context.Context, key string) (string, error)
+	// Delete removes keys and returns how many existed
+	Delete(ctx context.Context, keys ...string) (int64, error)
+}
+
+type Service struct {
+	store       Store
+	sender      sms.Sender
+	countryCode string
+}
+
+// NewService builds the code service. countryCode is assumed for numbers
+// typed without one (see Normalize).
+func NewService(store Store, sender sms.Sender, countryCode string) *Service {
+	return &Service{store: store, sender: sender, countryCode: countryCode}
+}
+
+// Normalize turns a typed phone number into the E.164 form Send and Verify
+// expect, or fails with sms.ErrInvalidNumber
+func (s *Service) Normalize(phone string) (string, error) {
+	return sms.Normalize(phone, s.countryCode)
+}
+
+// Send texts a new code to phone (E.164), replacing any earlier one. ip is
+// the requesting client. Codes and limits are per shop, from ctx.
+func (s *Service) Send(ctx context.Context, phone, ip string) error {
+	scope := tenantOf(ctx)
+
+	limits := []struct {
+		key    string
+		window time.Duration
+		max    int64
+	}{
+		{key(scope, "cooldown", phone), resendCooldown, 1},
+		{key(scope, "ip", ip), rateWindow, maxPerIP},
+		{key(scope, "phone", phone), rateWindow, maxPerPhone},
+	}
+	for _, limit := range limits {
+		n, err := s.store.Incr(ctx, limit.key, limit.window)
+		if err != nil {
+			return err
+		}
+		if n > limit.max {
+			return ErrRateLimited
+		}
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return err
+	}
+	if err := s.store.Set(ctx, key(scope, "code", phone), hashCode(phone, code), CodeTTL); err != nil {
+		return err
+	}
+	if _, err := s.store.Delete(ctx, key(scope, "attempts", phone)); err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("%s is your verification code. It expires in %d minutes.", code, int(CodeTTL.Minutes()))
+	return s.sender.Send(ctx, phone, text)
+}
+
+// Verify checks code against the last one sent to phone. A code works
+// once; after maxAttempts wrong guesses it is discarded.
+func (s *Service) Verify(ctx context.Context, phone, code string) error {
+	scope := tenantOf(ctx)
+	codeKey, attemptsKey := key(scope, "code", phone), key(scope, "attempts", phone)
+
+	stored, err := s.store.Get(ctx, codeKey)
+	if errors.Is(err, ErrNotFound) {
+		return ErrInvalidCode
+	}
+	if err != nil {
+		return err
+	}
+
+	attempts, err := s.store.Incr(ctx, attemptsKey, CodeTTL)
+	if err != nil {
+		return err
+	}
+	if attempts > maxAttempts {
+		return s.discard(ctx, codeKey, attemptsKey)
+	}
+	if subtle.ConstantTimeCompare([]byte(hashCode(phone, code)), []byte(stored)) != 1 {
+		if attempts == maxAttempts {
+			return s.discard(ctx, codeKey, attemptsKey)
+		}
+		return ErrInvalidCode
+	}
+
+	// Only one of two concurrent requests with the right code gets to
+	// delete it
+	n, err := s.store.Delete(ctx, codeKey)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidCode
+	}
+	_, err = s.store.Delete(ctx, attemptsKey)
+	return err
+}
+
+func (s *Service) discard(ctx context.Context, keys ...string) error {
+	if _, err := s.store.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return ErrTooManyAttempts
+}
+
+func newCode() (string, error) {
+	max := big.NewInt(1)
+	for range codeDigits {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+	return fmt.Sprintf("%0*d", codeDigits, n), nil
+}
+
+func hashCode(phone, code string) string {
+	return token.HashOpaque(phone + ":" + code)
+}
+
+func key(scope, kind, id string) string {
+	return keyPrefix + scope + ":" + kind + ":" + id
+}
+
+func tenantOf(ctx context.Context) string {
+	if tenant := tenancy.FromContext(ctx); tenant != nil {
+		return tenant.TenantID
+	}
+	return tenancy.PublicSchema
+}
@@ -0,0 +1,83 @@
+package otp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"bizbundl/internal/infra/sms"
+	"bizbundl/internal/tenancy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const phone = "+8801712345678"
+
+func newTestService() (*Service, *MemoryStore, *sms.Recorder, context.Context) {
+	store, outbox := NewMemoryStore(), &sms.Recorder{}
+	ctx := context.WithValue(context.Background(), tenancy.ContextKey, &tenancy.Tenant{TenantID: "shop_a"})
+	return NewService(store, outbox, "880"), store, outbox, ctx
+}
+
+func lastCode(t *testing.T, outbox *sms.Recorder, to string) string {
+	msg, ok := outbox.Last(to)
+	require.True(t, ok)
+	return msg.Text[:codeDigits]
+}
+
+func TestVerify(t *testing.T) {
+	svc, _, outbox, ctx := newTestService()
+	require.NoError(t, svc.Send(ctx, phone, "10.0.0.1"))
+	code := lastCode(t, outbox, phone)
+
+	// Codes are per shop
+	other := context.WithValue(context.Background(), tenancy.ContextKey, &tenancy.Tenant{TenantID: "shop_b"})
+	assert.ErrorIs(t, svc.Verify(other, phone, code), ErrInvalidCode)
+
+	assert.ErrorIs(t, svc.Verify(ctx, phone, "not-it"), ErrInvalidCode)
+	assert.NoError(t, svc.Verify(ctx, phone, code))
+
+	// A code works once
+	assert.ErrorIs(t, svc.Verify(ctx, phone, code), ErrInvalidCode)
+}
+
+func TestVerifyAttempts(t *testing.T) {
+	svc, _, outbox, ctx := newTestService()
+	require.NoError(t, svc.Send(ctx, phone, "10.0.0.1"))
+	code := lastCode(t, outbox, phone)
+
+	for i := 1; i < maxAttempts; i++ {
+		assert.ErrorIs(t, svc.Verify(ctx, phone, "000000x"), ErrInvalidCode)
+	}
+	assert.ErrorIs(t, svc.Verify(ctx, phone, "000000x"), ErrTooManyAttempts)
+
+	// The code is gone, even the right one no longer works
+	assert.ErrorIs(t, svc.Verify(ctx, phone, code), ErrInvalidCode)
+}
+
+func TestSendRateLimits(t *testing.T) {
+	svc, store, outbox, ctx := newTestService()
+	endCooldown := func(to string) {
+		_, err := store.Delete(ctx, key("shop_a", "cooldown", to))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, svc.Send(ctx, phone, "10.0.0.1"))
+	assert.ErrorIs(t, svc.Send(ctx, phone, "10.0.0.1"), ErrRateLimited)
+
+	// Per number, whatever the IP
+	for i := 1; i < maxPerPhone; i++ {
+		endCooldown(phone)
+		require.NoError(t, svc.Send(ctx, phone, fmt.Sprintf("10.0.1.%d", i)))
+	}
+	endCooldown(phone)
+	assert.ErrorIs(t, svc.Send(ctx, phone, "10.0.2.1"), ErrRateLimited)
+	assert.Len(t, outbox.Messages, maxPerPhone)
+
+	// Per IP, whatever the number
+	for i := range maxPerIP {
+		require.NoError(t, svc.Send(ctx, fmt.Sprintf("+88017000000%02d", i), "10.0.3.1"))
+	}
+	assert.ErrorIs(t, svc.Send(ctx, "+8801799999999", "10.0.3.1"), ErrRateLimited)
+}
@@ -0,0 +1,116 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	redisLib "github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "otp:"
+
+// RedisStore keeps codes and counters under otp:{tenant}:{kind}:{id}
+type RedisStore struct {
+	client *redisLib.Client
+}
+
+func NewRedisStore(client *redisLib.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (string, error) {
+	value, err := s.client.Get(ctx, key).Result()
+	if errors.Is(err, redisLib.Nil) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) (int64, error) {
+	return s.client.Del(ctx, keys...).Result()
+}
+
+// MemoryStore keeps codes in process. It is for tests and single-node
+// development without Redis.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	value     string
+	count     int64
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]entry{}}
+}
+
+// get returns the live entry under key; the caller holds mu
+func (s *MemoryStore) get(key string) (entry, bool) {
+	e, ok := s.entries[key]
+	if ok && time.Now().After(e.expiresAt) {
+		delete(s.entries, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+func (s *MemoryStore) Incr(_ context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.get(key)
+	if !ok {
+		e = entry{expiresAt: time.Now().Add(window)}
+	}
+	e.count++
+	s.entries[key] = e
+	return e.count, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry{value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.get(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, keys ...string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if _, ok := s.get(key); ok {
+			delete(s.entries, key)
+			n++
+		}
+	}
+	return n, nil
+}
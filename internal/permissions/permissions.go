@@ -0,0 +1,104 @@
+// Package permissions names what shop staff may do in a shop's admin.
+//
+// Team members are invited with a Role; the role's permission strings are
+// written to the member's tenant users.permissions when they open the shop.
+// The owner always has every permission.
+package permissions
+
+import "errors"
+
+// Permissions
+const (
+	OrdersView     = "orders.view"
+	OrdersFulfill  = "orders.fulfill"
+	CatalogEdit    = "catalog.edit"
+	ContentEdit    = "content.edit"
+	SettingsManage = "settings.manage"
+)
+
+// Definition describes a permission for the admin UI
+type Definition struct {
+	Name        string
+	Group       string
+	Description string
+}
+
+// Registry is the central list of permissions, in display order. Routes may
+// only require permissions listed here (see middleware.RequirePermission),
+// and each one here guards a route: no permission is granted for nothing.
+var Registry = []Definition{
+	{Name: OrdersView, Group: "Orders", Description: "View orders and their payments"},
+	{Name: OrdersFulfill, Group: "Orders", Description: "Update order status, ship and cancel orders"},
+	{Name: CatalogEdit, Group: "Catalog", Description: "Create, edit and delete products and categories"},
+	{Name: ContentEdit, Group: "Content", Description: "Edit pages and storefront content"},
+	{Name: SettingsManage, Group: "Settings", Description: "Change shop settings"},
+}
+
+// All lists every permission, in display order
+var All = names(Registry)
+
+// Known reports whether name is in the Registry
+func Known(name string) bool {
+	for _, d := range Registry {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func names(defs []Definition) []string {
+	out := make([]string, len(defs))
+	for i, d := range defs {
+		out[i] = d.Name
+	}
+	return out
+}
+
+// Role is a named set of permissions a member is invited with
+type Role string
+
+const (
+	Manager        Role = "manager"
+	OrderFulfiller Role = "order_fulfiller"
+	ContentEditor  Role = "content_editor"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+// Roles lists the roles owners can assign, in display order
+var Roles = []Role{Manager, OrderFulfiller, ContentEditor}
+
+var rolePermissions = map[Role][]string{
+	Manager:        {OrdersView, OrdersFulfill, CatalogEdit, ContentEdit},
+	OrderFulfiller: {OrdersView, OrdersFulfill},
+	ContentEditor:  {CatalogEdit, ContentEdit},
+}
+
+var roleLabels = map[Role]string{
+	Manager:        "Manager",
+	OrderFulfiller: "Order fulfiller",
+	ContentEditor:  "Content editor",
+}
+
+// ParseRole validates a role name
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, ok := rolePermissions[role]; !ok {
+		return "", ErrUnknownRole
+	}
+	return role, nil
+}
+
+// Permissions returns the permissions granted by the role
+func (r Role) Permissions() []string {
+	return append([]string(nil), rolePermissions[r]...)
+}
+
+// Label is the role's display name
+func (r Role) Label() string {
+	if label, ok := roleLabels[r]; ok {
+		return label
+	}
+	return string(r)
+}
@@ -0,0 +1,30 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRole(t *testing.T) {
+	for _, role := range Roles {
+		parsed, err := ParseRole(string(role))
+		require.NoError(t, err)
+		assert.Equal(t, role, parsed)
+		assert.NotEmpty(t, parsed.Permissions())
+	}
+
+	_, err := ParseRole("owner")
+	assert.ErrorIs(t, err, ErrUnknownRole)
+}
+
+func TestRolePermissions(t *testing.T) {
+	assert.ElementsMatch(t, []string{OrdersView, OrdersFulfill}, OrderFulfiller.Permissions())
+	assert.NotContains(t, Manager.Permissions(), SettingsManage)
+
+	// Callers may not change the role's permissions through the returned slice
+	perms := ContentEditor.Permissions()
+	perms[0] = SettingsManage
+	assert.NotContains(t, ContentEditor.Permissions(), SettingsManage)
+}
@@ -0,0 +1,163 @@
+package permissions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/patrickmn/go-cache"
+	redisLib "github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// accessTTL bounds how long a node acts on an edit it missed the
+	// invalidation message for
+	accessTTL           = time.Minute
+	invalidationChannel = "permissions:invalidate"
+)
+
+// Access is what a user may do in the current shop
+type Access struct {
+	Role        db.UserRole
+	Permissions []string
+}
+
+// Staff reports whether the user may use the shop's admin at all
+func (a Access) Staff() bool {
+	return a.Role == db.UserRoleAdmin || a.Role == db.UserRoleStaff
+}
+
+// Has reports whether the user holds permission. Admins hold every permission.
+func (a Access) Has(permission string) bool {
+	if a.Role == db.UserRoleAdmin {
+		return true
+	}
+	if a.Role != db.UserRoleStaff {
+		return false
+	}
+	for _, p := range a.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// UserStore is the subset of tenant queries the resolver needs
+type UserStore interface {
+	GetUserById(ctx context.Context, id pgtype.UUID) (db.User, error)
+}
+
+// Resolver loads a user's role and users.permissions from the current shop's
+// schema. Results are cached in memory per shop and user; Invalidate drops
+// them on every node when the user is edited.
+type Resolver struct {
+	users UserStore
+	redis *redisLib.Client
+	local *cache.Cache
+}
+
+// NewResolver creates a Resolver. The redis client is optional (nil only
+// invalidates this node).
+func NewResolver(users UserStore, rc *redisLib.Client) *Resolver {
+	return &Resolver{
+		users: users,
+		redis: rc,
+		local: cache.New(accessTTL, 2*accessTTL),
+	}
+}
+
+// Resolve returns the access of userID in the shop of the request context.
+// The users row is authoritative: the role in the session token is not
+// trusted, so demoted staff lose access without logging out.
+func (r *Resolver) Resolve(ctx context.Context, userID string) (Access, error) {
+	tenant := tenancy.FromContext(ctx)
+	if tenant == nil || tenant.IsPlatform() {
+		return Access{}, nil
+	}
+	key := accessKey(tenant.TenantID, userID)
+	if val, ok := r.local.Get(key); ok {
+		return val.(Access), nil
+	}
+
+	var id pgtype.UUID
+	if err := id.Scan(userID); err != nil {
+		return Access{}, nil
+	}
+	user, err := r.users.GetUserById(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Access{}, nil
+	}
+	if err != nil {
+		return Access{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	access := Access{Role: user.Role}
+	if user.Role == db.UserRoleStaff && len(user.Permissions) > 0 {
+		var granted []string
+		if err := json.Unmarshal(user.Permissions, &granted); err != nil {
+			log.Warn().Err(err).Str("tenant", tenant.TenantID).Str("user", userID).Msg("permissions: unreadable users.permissions, granting none")
+		}
+		for _, p := range granted {
+			if Known(p) {
+				access.Permissions = append(access.Permissions, p)
+			}
+		}
+	}
+
+	r.local.Set(key, access, accessTTL)
+	return access, nil
+}
+
+// Invalidate drops the cached access of userID in tenantID on this node and
+// notifies other nodes. Call it after changing the user's role or permissions.
+// It is safe to call on a nil Resolver.
+func (r *Resolver) Invalidate(ctx context.Context, tenantID, userID string) {
+	if r == nil {
+		return
+	}
+	key := accessKey(tenantID, userID)
+	r.local.Delete(key)
+	if r.redis == nil {
+		return
+	}
+	if err := r.redis.Publish(ctx, invalidationChannel, key).Err(); err != nil {
+		log.Warn().Err(err).Msg("permissions: failed to publish invalidation")
+	}
+}
+
+// Listen drops in-memory entries when other nodes publish invalidations.
+// It blocks until ctx is cancelled, so run it in a goroutine.
+func (r *Resolver) Listen(ctx context.Context) {
+	if r.redis == nil {
+		return
+	}
+	sub := r.redis.Subscribe(ctx, invalidationChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			r.local.Delete(msg.Payload)
+		}
+	}
+}
+
+func accessKey(tenantID, userID string) string {
+	return tenantID + ":" + strings.ToLower(userID)
+}
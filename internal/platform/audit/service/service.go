@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"bizbundl/internal/audit"
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/sessions"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+// Actor types
+const (
+	ActorOwner    = "owner"
+	ActorPlatform = "platform"
+	ActorSystem   = "system"
+	// ActorAnonymous is a caller who is not signed in, such as a failed login
+	ActorAnonymous = "anonymous"
+)
+
+// Entry is one platform audit event
+type Entry struct {
+	ActorID    pgtype.UUID
+	ActorType  string
+	Action     string
+	EntityType string
+	EntityID   string
+	Metadata   map[string]any
+	// Before and After are what changed (see audit.Diff), when the entity
+	// changed: nil when only Metadata describes the event
+	Before  any
+	After   any
+	Secrets []string
+	// IP defaults to the client of the request of ctx
+	IP string
+}
+
+// AuditService writes the platform audit trail (public.platform_audit_log)
+type AuditService struct {
+	store db.Querier
+}
+
+func NewAuditService(store db.Querier) *AuditService {
+	return &AuditService{store: store}
+}
+
+// Record appends an entry. Audit failures are logged, never returned:
+// they must not undo the action being audited.
+func (s *AuditService) Record(ctx context.Context, e Entry) {
+	if err := s.record(ctx, e); err != nil {
+		log.Error().Err(err).Str("action", e.Action).Str("entity_id", e.EntityID).Msg("failed to write platform audit log")
+	}
+}
+
+func (s *AuditService) record(ctx context.Context, e Entry) error {
+	if e.Metadata == nil {
+		e.Metadata = map[string]any{}
+	}
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit metadata: %w", err)
+	}
+
+	before, after, err := audit.Diff(e.Before, e.After, e.Secrets...)
+	if err != nil {
+		return err
+	}
+	if e.IP == "" {
+		e.IP = sessions.ClientFromContext(ctx).IP
+	}
+
+	_, err = s.store.CreatePlatformAuditLog(ctx, db.CreatePlatformAuditLogParams{
+		ActorID:    e.ActorID,
+		ActorType:  e.ActorType,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Metadata:   metadata,
+		Ip:         nonEmpty(e.IP),
+		Before:     before,
+		After:      after,
+		RequestID:  nonEmpty(audit.RequestIDFromContext(ctx)),
+	})
+	return err
+}
+
+// ForEntity lists the most recent events for an entity
+func (s *AuditService) ForEntity(ctx context.Context, entityType, entityID string, limit int32) ([]db.PlatformAuditLog, error) {
+	return s.store.ListPlatformAuditLogByEntity(ctx, db.ListPlatformAuditLogByEntityParams{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Limit:      limit,
+	})
+}
+
+// List returns the platform entries matching f, newest first
+func (s *AuditService) List(ctx context.Context, f audit.Filter, limit int32) ([]audit.Row, error) {
+	params := db.ListPlatformAuditLogParams{
+		Actor:      nonEmpty(f.Actor),
+		Action:     nonEmpty(f.Action),
+		EntityType: nonEmpty(f.EntityType),
+		EntityID:   nonEmpty(f.EntityID),
+		Since:      timestamptz(f.Since),
+		Until:      timestamptz(f.Until),
+		RowLimit:   limit,
+	}
+	if f.Before != nil {
+		params.BeforeAt = timestamptz(f.Before.CreatedAt)
+		params.BeforeID = f.Before.ID
+	}
+	entries, err := s.store.ListPlatformAuditLog(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]audit.Row, len(entries))
+	for i, e := range entries {
+		rows[i] = audit.Row{
+			ID:         e.ID,
+			CreatedAt:  e.CreatedAt.Time,
+			ActorID:    e.ActorID,
+			ActorType:  e.ActorType,
+			ActorName:  e.ActorName,
+			Action:     e.Action,
+			EntityType: e.EntityType,
+			EntityID:   e.EntityID,
+			Before:     e.Before,
+			After:      e.After,
+			Metadata:   e.Metadata,
+			IP:         deref(e.Ip),
+			RequestID:  deref(e.RequestID),
+		}
+	}
+	return rows, nil
+}
+
+// Export writes the platform entries matching f to w as CSV
+func (s *AuditService) Export(ctx context.Context, w io.Writer, f audit.Filter) error {
+	return audit.Export(w, f, func(f audit.Filter, limit int32) ([]audit.Row, error) {
+		return s.List(ctx, f, limit)
+	})
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func timestamptz(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: !t.IsZero()}
+}
@@ -1,23 +1,29 @@
 package handler
 
 import (
+	"errors"
+
 	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/loginguard"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/middleware"
 	"bizbundl/internal/platform/auth/service"
 	"bizbundl/internal/platform/auth/view"
 	"bizbundl/util"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
 )
 
 type AuthHandler struct {
 	service *service.AuthService
+	cookies middleware.CookiePolicy
 }
 
 // CartService removed (Platform Users don't shop)
-func NewAuthHandler(service *service.AuthService) *AuthHandler {
-	return &AuthHandler{service: service}
+func NewAuthHandler(service *service.AuthService, cookies middleware.CookiePolicy) *AuthHandler {
+	return &AuthHandler{service: service, cookies: cookies}
 }
 
 // Assuming UserResponse struct is defined elsewhere and has FirstName and LastName fields.
@@ -50,8 +56,12 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return util.APIError(c, fiber.StatusInternalServerError, err)
 	}
 
+	if err := h.service.SendVerificationEmail(c.Context(), user.ID); err != nil {
+		log.Warn().Err(err).Str("user", user.ID.String()).Msg("failed to send verification email")
+	}
+
 	// Auto-Login
-	token, _, err := h.service.Login(c.Context(), req.Email, req.Password)
+	tokens, _, err := h.service.Login(c.Context(), req.Email, req.Password)
 	if err != nil {
 		// Registration successful but login failed (rare/weird)
 		// Return success but no token? Or error?
@@ -59,11 +69,12 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return util.JSON(c, fiber.StatusCreated, user, "Registration successful (Login failed)")
 	}
 
-	h.setSessionCookie(c, token)
+	h.cookies.SetTokens(c, tokens)
 
 	return util.JSON(c, fiber.StatusCreated, fiber.Map{
-		"token": token,
-		"user":  newUserResponse(user),
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+		"user":          newUserResponse(user),
 	}, "Registration successful")
 }
 
@@ -82,7 +93,21 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		}))
 	}
 
-	token, _, err := h.service.Login(c.Context(), req.Email, req.Password)
+	tokens, _, err := h.service.Login(c.Context(), req.Email, req.Password)
+	var challenge *mfa.ChallengeError
+	if errors.As(err, &challenge) {
+		// Password is right: ask for the code
+		data := view.TwoFactorFormData{Challenge: challenge.Challenge}
+		return renderAccount(c, view.TwoFactorChallengeForm(data), view.TwoFactorChallenge(data))
+	}
+	var limit *loginguard.LimitError
+	if errors.As(err, &limit) {
+		// Rendered as a normal response so HTMX swaps it in
+		return util.Render(c, view.Login(view.LoginFormData{
+			Email:   req.Email,
+			Message: err.Error(),
+		}))
+	}
 	if err != nil {
 		// Failure: Re-render form with error message
 		return util.Render(c, view.Login(view.LoginFormData{
@@ -92,7 +117,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		}))
 	}
 
-	h.setSessionCookie(c, token)
+	h.cookies.SetTokens(c, tokens)
 
 	// Success: HTMX Redirect
 	c.Set("HX-Redirect", "/dashboard")
@@ -100,7 +125,12 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 }
 
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	c.ClearCookie("session_token")
+	userID, _ := c.Locals("user_id").(string)
+	sessionID, _ := c.Locals("session_id").(string)
+	if err := h.service.Logout(c.Context(), userID, sessionID); err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	h.cookies.Clear(c)
 	return util.JSON(c, fiber.StatusOK, nil, "Logged out")
 }
 
@@ -128,14 +158,3 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 
 	return util.JSON(c, fiber.StatusOK, newUserResponse(user), "Current user")
 }
-
-func (h *AuthHandler) setSessionCookie(c *fiber.Ctx, token string) {
-	cookie := new(fiber.Cookie)
-	cookie.Name = "session_token"
-	cookie.Value = token
-	cookie.Expires = time.Now().Add(24 * 7 * time.Hour)
-	cookie.HTTPOnly = true
-	cookie.Secure = true
-	cookie.SameSite = "Strict"
-	c.Cookie(cookie)
-}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"errors"
+
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/platform/auth/service"
+	"bizbundl/internal/platform/auth/view"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+// LoginTwoFactor finishes a login with the code asked for after the password
+func (h *AuthHandler) LoginTwoFactor(c *fiber.Ctx) error {
+	data := view.TwoFactorFormData{Challenge: c.FormValue("challenge")}
+	tokens, _, err := h.service.LoginTwoFactor(c.Context(), data.Challenge, c.FormValue("code"))
+	if err != nil {
+		data.Error = twoFactorMessage(err)
+		return renderAccount(c, view.TwoFactorChallengeForm(data), view.TwoFactorChallenge(data))
+	}
+
+	h.cookies.SetTokens(c, tokens)
+	if c.Get("HX-Request") == "true" {
+		c.Set("HX-Redirect", "/dashboard")
+		return c.SendStatus(fiber.StatusOK)
+	}
+	return c.Redirect("/dashboard")
+}
+
+// StepUpPage asks the signed-in owner to confirm it's them, then sends them
+// back to next
+func (h *AuthHandler) StepUpPage(c *fiber.Ctx) error {
+	userID, _, ok := owner(c)
+	if !ok {
+		return nil
+	}
+	data := view.TwoFactorFormData{Next: middleware.LocalPath(c.Query("next"))}
+	factor, err := h.service.TwoFactorStatus(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load account")
+	}
+	data.TwoFactor = factor.Enabled
+	return util.Render(c, view.StepUp(data))
+}
+
+// StepUp checks the password (or code) from StepUpPage
+func (h *AuthHandler) StepUp(c *fiber.Ctx) error {
+	userID, sessionID, ok := owner(c)
+	if !ok {
+		return nil
+	}
+	next := middleware.LocalPath(c.FormValue("next"))
+	code := c.FormValue("code")
+
+	err := h.service.StepUp(c.Context(), userID, sessionID, c.FormValue("password"), code)
+	if err != nil {
+		c.Status(fiber.StatusUnauthorized)
+		return util.Render(c, view.StepUp(view.TwoFactorFormData{
+			Next:      next,
+			TwoFactor: code != "",
+			Error:     twoFactorMessage(err),
+		}))
+	}
+	return c.Redirect(next)
+}
+
+// SecurityPage shows the owner's two-factor settings
+func (h *AuthHandler) SecurityPage(c *fiber.Ctx) error {
+	userID, _, ok := owner(c)
+	if !ok {
+		return nil
+	}
+	return h.renderSecurity(c, userID, view.SecurityData{})
+}
+
+// SetupTwoFactor starts enrollment and shows the QR code
+func (h *AuthHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	userID, _, ok := owner(c)
+	if !ok {
+		return nil
+	}
+	enrollment, err := h.service.SetupTwoFactor(c.Context(), userID)
+	if err != nil {
+		return h.renderSecurity(c, userID, view.SecurityData{Error: twoFactorMessage(err)})
+	}
+	return h.renderSecurity(c, userID, view.SecurityData{Secret: enrollment.Secret, URI: enrollment.URI})
+}
+
+// EnableTwoFactor confirms enrollment with a code and shows the recovery
+// codes
+func (h *AuthHandler) EnableTwoFactor(c *fiber.Ctx) error {
+	userID, sessionID, ok := owner(c)
+	if !ok {
+		return nil
+	}
+	codes, err := h.service.EnableTwoFactor(c.Context(), userID, sessionID, c.FormValue("code"))
+	if err != nil {
+		return h.renderSecurity(c, userID, view.SecurityData{
+			Secret: c.FormValue("secret"),
+			URI:    c.FormValue("uri"),
+			Error:  twoFactorMessage(err),
+		})
+	}
+	return h.renderSecurity(c, userID, view.SecurityData{
+		RecoveryCodes: codes,
+		Message:       "Two-factor authentication is on.",
+	})
+}
+
+// DisableTwoFactor turns two-factor authentication off. It runs behind
+// RequireStepUp.
+func (h *AuthHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	userID, _, ok := owner(c)
+	if !ok {
+		return nil
+	}
+	data := view.SecurityData{Message: "Two-factor authentication is off."}
+	if err := h.service.DisableTwoFactor(c.Context(), userID); err != nil {
+		data = view.SecurityData{Error: twoFactorMessage(err)}
+	}
+	return h.renderSecurity(c, userID, data)
+}
+
+// RegenerateRecoveryCodes replaces the owner's recovery codes. It runs
+// behind RequireStepUp.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID, _, ok := owner(c)
+	if !ok {
+		return nil
+	}
+	codes, err := h.service.RegenerateRecoveryCodes(c.Context(), userID)
+	if err != nil {
+		return h.renderSecurity(c, userID, view.SecurityData{Error: twoFactorMessage(err)})
+	}
+	return h.renderSecurity(c, userID, view.SecurityData{RecoveryCodes: codes})
+}
+
+// renderSecurity renders the security page with the current two-factor state
+func (h *AuthHandler) renderSecurity(c *fiber.Ctx, userID pgtype.UUID, data view.SecurityData) error {
+	factor, err := h.service.TwoFactorStatus(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Failed to load account")
+	}
+	data.Enabled = factor.Enabled
+	data.RecoveryCodesLeft = factor.RecoveryCodesLeft
+	return util.Render(c, view.Security(data))
+}
+
+// owner returns the signed-in platform user and session, redirecting
+// everyone else to the login page
+func owner(c *fiber.Ctx) (pgtype.UUID, string, bool) {
+	var userID pgtype.UUID
+	userIDStr, _ := c.Locals("user_id").(string)
+	sessionID, _ := c.Locals("session_id").(string)
+	role, _ := c.Locals("user_role").(string)
+	if role != "owner" || sessionID == "" || userID.Scan(userIDStr) != nil {
+		c.Redirect("/login")
+		return userID, "", false
+	}
+	return userID, sessionID, true
+}
+
+// twoFactorMessage is what the forms show for err
+func twoFactorMessage(err error) string {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode), errors.Is(err, mfa.ErrTooManyAttempts),
+		errors.Is(err, mfa.ErrInvalidChallenge), errors.Is(err, mfa.ErrNotSetUp),
+		errors.Is(err, mfa.ErrAlreadyEnabled), errors.Is(err, mfa.ErrNotEnabled):
+		return err.Error()
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return "Incorrect password"
+	default:
+		log.Error().Err(err).Msg("two-factor authentication failed")
+		return "Something went wrong. Please try again."
+	}
+}
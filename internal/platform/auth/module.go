@@ -2,7 +2,9 @@ package auth
 
 import (
 	"bizbundl/internal/constants"
+	"bizbundl/internal/mfa"
 	"bizbundl/internal/middleware"
+	"bizbundl/internal/otp"
 
 	// "bizbundl/internal/middleware" // Global?
 	"bizbundl/internal/platform/auth/handler"
@@ -15,16 +17,18 @@ import (
 // Init initializes the Auth module: wires the Service/Handler and registers routes.
 func Init(app *server.Server) {
 	svc := NewAuthService(app)
-	h := handler.NewAuthHandler(svc)
+	h := handler.NewAuthHandler(svc, app.GetCookies())
 
 	// Auth Middleware (Global)
 	// We pass the token maker directly to middleware
 	// We also pass session durations configuration
 	authMiddleware := middleware.Auth(
 		app.GetTokenMaker(),
-		constants.UserSessionDuration,
+		app.GetSessions(),
+		nil, // API keys are for shops
+		app.GetCookies(),
 		constants.GuestSessionDuration,
-		constants.RefreshThreshold,
+		constants.GuestRenewThreshold,
 	)
 
 	// Apply Auth Middleware Globally (to ensure Guest Session on all routes)
@@ -33,6 +37,7 @@ func Init(app *server.Server) {
 	// View Routes (HTMX Pages & Actions)
 	app.GetRouter().Get("/login", h.ShowLoginForm)
 	app.GetRouter().Post("/login", h.Login)
+	app.GetRouter().Post("/login/2fa", h.LoginTwoFactor)
 
 	// Logout Action
 	app.GetRouter().Post("/logout", h.Logout)
@@ -40,6 +45,30 @@ func Init(app *server.Server) {
 	// Register Page (TODO: Create Register View)
 	app.GetRouter().Post("/register", h.Register)
 
+	// Account Recovery (linked from emails). Their links carry tokens, and
+	// verify-email and unlock use them up on GET
+	noStore := middleware.NoStore()
+	app.GetRouter().Get("/forgot-password", h.ForgotPasswordPage)
+	app.GetRouter().Post("/forgot-password", h.ForgotPassword)
+	app.GetRouter().Get("/reset-password", noStore, h.ResetPasswordPage)
+	app.GetRouter().Post("/reset-password", h.ResetPassword)
+	app.GetRouter().Get("/verify-email", noStore, h.VerifyEmailPage)
+	app.GetRouter().Get("/unlock", noStore, h.UnlockAccountPage)
+	app.GetRouter().Post("/verify-email/resend", h.ResendVerification)
+
+	// Re-authentication for sensitive actions (middleware.RequireStepUp)
+	app.GetRouter().Get(middleware.StepUpPath, h.StepUpPage)
+	app.GetRouter().Post(middleware.StepUpPath, h.StepUp)
+
+	// Two-factor authentication
+	stepUp := middleware.RequireStepUp(app.GetSessions(), constants.StepUpWindow)
+	security := app.GetRouter().Group("/dashboard/security")
+	security.Get("/", h.SecurityPage)
+	security.Post("/2fa/setup", h.SetupTwoFactor)
+	security.Post("/2fa/enable", h.EnableTwoFactor)
+	security.Post("/2fa/disable", stepUp, h.DisableTwoFactor)
+	security.Post("/2fa/recovery-codes", stepUp, h.RegenerateRecoveryCodes)
+
 	// Protected
 	// api.Get("/me", authMiddleware, h.Me) // 'Me' is likely used by UI to get state? Or we use template data.
 	// For now, let's keep 'Me' disabled or move to web route if needed.
@@ -52,5 +81,6 @@ func NewAuthService(app *server.Server) *service.AuthService {
 	// We need to construct Platform Queries manually or via helper.
 	pool := app.GetDB().GetPool()
 	queries := service.NewPlatformQueries(pool) // We'll add this helper or inline it in service pkg
-	return service.NewAuthService(queries, app.GetTokenMaker())
+	twoFactor := mfa.NewService(service.NewTwoFactorStore(queries), otp.NewRedisStore(app.GetRedis()), app.GetConfig().TokenSymmetricKey)
+	return service.NewAuthService(queries, app.GetSessions(), app.GetMailer(), twoFactor, app.GetLoginGuard(), app.GetConfig().PlatformURL)
 }
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/infra/mailer"
+	"bizbundl/internal/loginguard"
+	"bizbundl/token"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+
+	// Platform pages the emailed links open (see handler.ResetPasswordPage)
+	ResetPasswordPath = "/reset-password"
+	VerifyEmailPath   = "/verify-email"
+	UnlockAccountPath = "/unlock"
+)
+
+var ErrInvalidLink = errors.New("this link is invalid or has expired")
+
+// RequestPasswordReset emails a single-use reset link to the account with
+// this email. Unknown addresses succeed silently, so the form cannot be used
+// to find out who has an account.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.store.GetUserByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	secret, err := s.issueToken(ctx, user.ID, token.PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	link := s.platformURL + ResetPasswordPath + "?token=" + url.QueryEscape(secret)
+	return s.mailer.Send(ctx, mailer.PasswordReset(user.Email, link, "1 hour"))
+}
+
+// ResetPassword sets a new password with a reset token and signs the user
+// out of every session. Opening the link also proves the address, so the
+// email counts as verified.
+func (s *AuthService) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	if len(newPassword) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	hashed, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	userID, err := s.consumeToken(ctx, resetToken, token.PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	if err := s.store.UpdatePassword(ctx, db.UpdatePasswordParams{ID: userID, PasswordHash: hashed}); err != nil {
+		return err
+	}
+	if err := s.store.MarkEmailVerified(ctx, userID); err != nil {
+		return err
+	}
+	_, err = s.sessions.RevokeAll(ctx, userID.String(), "")
+	return err
+}
+
+// SendVerificationEmail emails the user a link confirming their address.
+// Verified users are not emailed again.
+func (s *AuthService) SendVerificationEmail(ctx context.Context, id pgtype.UUID) error {
+	user, err := s.store.GetUserById(ctx, id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerifiedAt.Valid {
+		return nil
+	}
+
+	secret, err := s.issueToken(ctx, user.ID, token.PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := s.platformURL + VerifyEmailPath + "?token=" + url.QueryEscape(secret)
+	return s.mailer.Send(ctx, mailer.EmailVerification(user.Email, link))
+}
+
+// VerifyEmail marks the address of the token's user as verified
+func (s *AuthService) VerifyEmail(ctx context.Context, verificationToken string) error {
+	userID, err := s.consumeToken(ctx, verificationToken, token.PurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	return s.store.MarkEmailVerified(ctx, userID)
+}
+
+// UnlockAccount lifts the lock that failed logins put on an account, with
+// the link emailed when it was locked
+func (s *AuthService) UnlockAccount(ctx context.Context, unlockToken string) error {
+	_, err := s.logins.Unlock(ctx, unlockToken)
+	if errors.Is(err, loginguard.ErrInvalidUnlock) {
+		return ErrInvalidLink
+	}
+	return err
+}
+
+// issueToken stores a new token for the user, replacing earlier ones of the
+// same purpose, and returns it. Only its hash is kept.
+func (s *AuthService) issueToken(ctx context.Context, userID pgtype.UUID, purpose string, ttl time.Duration) (string, error) {
+	if err := s.store.RevokeUserTokens(ctx, db.RevokeUserTokensParams{UserID: userID, Purpose: purpose}); err != nil {
+		return "", fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+	secret, hash, err := token.NewOpaque()
+	if err != nil {
+		return "", err
+	}
+	_, err = s.store.CreateUserToken(ctx, db.CreateUserTokenParams{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(ttl), Valid: true},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create token: %w", err)
+	}
+	return secret, nil
+}
+
+// consumeToken uses up a token and returns its user
+func (s *AuthService) consumeToken(ctx context.Context, secret, purpose string) (pgtype.UUID, error) {
+	if secret == "" {
+		return pgtype.UUID{}, ErrInvalidLink
+	}
+	row, err := s.store.ConsumeUserToken(ctx, db.ConsumeUserTokenParams{TokenHash: token.HashOpaque(secret), Purpose: purpose})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return pgtype.UUID{}, ErrInvalidLink
+	}
+	if err != nil {
+		return pgtype.UUID{}, err
+	}
+	return row.UserID, nil
+}
@@ -3,30 +3,45 @@ package service
 import (
 	"context"
 	"errors"
+	"net/url"
 
-	"bizbundl/internal/constants"
 	db "bizbundl/internal/db/sqlc/platform"
-	"bizbundl/token"
+	"bizbundl/internal/infra/mailer"
+	"bizbundl/internal/loginguard"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/sessions"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrWeakPassword       = errors.New("password must be at least 6 characters")
 )
 
+// minPasswordLength matches the validate tag on RegisterRequest.Password
+const minPasswordLength = 6
+
 // PlatformStore wrapper to match expected interface if needed, or just use Queries
 // For MVP, directly use the generated Queries struct
 type AuthService struct {
-	store      *db.Queries
-	tokenMaker token.Maker
+	store       *db.Queries
+	sessions    *sessions.Manager
+	mailer      mailer.Mailer
+	twoFactor   *mfa.Service
+	logins      *loginguard.Guard
+	platformURL string
 }
 
-func NewAuthService(store *db.Queries, tokenMaker token.Maker) *AuthService {
-	return &AuthService{store: store, tokenMaker: tokenMaker}
+// NewAuthService builds the platform's auth service. Emailed links point at
+// platformURL (config.PlatformURL); twoFactor checks authenticator codes (see
+// NewTwoFactorStore); logins limits password guessing.
+func NewAuthService(store *db.Queries, sessions *sessions.Manager, mailer mailer.Mailer, twoFactor *mfa.Service, logins *loginguard.Guard, platformURL string) *AuthService {
+	return &AuthService{store: store, sessions: sessions, mailer: mailer, twoFactor: twoFactor, logins: logins, platformURL: platformURL}
 }
 
 // Helper for Module init
@@ -73,34 +88,66 @@ func (s *AuthService) Register(ctx context.Context, email, password, fullName, p
 	return user, nil
 }
 
-// Login verifies credentials and returns a Session Token
-func (s *AuthService) Login(ctx context.Context, email, password string) (string, db.User, error) {
+// Login verifies credentials and returns the new session's tokens. Accounts
+// with two-factor authentication get a *mfa.ChallengeError instead, to
+// finish with LoginTwoFactor. Too many failures get a
+// *loginguard.LimitError before the password is even checked; the one that
+// locks the account emails it an unlock link.
+func (s *AuthService) Login(ctx context.Context, email, password string) (sessions.Tokens, db.User, error) {
+	if err := s.logins.Check(ctx, email); err != nil {
+		return sessions.Tokens{}, db.User{}, err
+	}
+
 	user, err := s.store.GetUserByEmail(ctx, email)
 	if err != nil {
 		// Avoid leaking if user exists or not, but for MVP standard error
-		return "", db.User{}, ErrInvalidCredentials
+		return sessions.Tokens{}, db.User{}, s.loginFailed(ctx, email, nil)
 	}
 
 	if !verifyPassword(password, user.PasswordHash) {
-		return "", db.User{}, ErrInvalidCredentials
+		return sessions.Tokens{}, db.User{}, s.loginFailed(ctx, email, &user)
+	}
+	s.logins.Succeed(ctx, email)
+	if err := s.twoFactor.Challenge(ctx, user.ID); err != nil {
+		return sessions.Tokens{}, db.User{}, err
 	}
 
-	// Create Stateless Token (Paseto)
-	// Use defined constant for User Session
-	duration := constants.UserSessionDuration
-	token, _, err := s.tokenMaker.CreateToken(user.ID.String(), "owner", duration) // Hardcode role 'owner' for now
+	// Stateless access token (Paseto) plus a refresh token, bound to a revocable session
+	tokens, _, err := s.sessions.Issue(ctx, user.ID.String(), "owner") // Hardcode role 'owner' for now
 	if err != nil {
-		return "", db.User{}, err
+		return sessions.Tokens{}, db.User{}, err
 	}
 
-	// We can also creating a DB session for Revocation List (optional but safer)
-	// For "Super Efficiency" requested by user, we might skip or do async.
-	// The previous implementation did DB.
-	// Note: If we use Paseto self-contained, we don't NEED the db session ID in the token necessarily,
-	// unless we want to revoke it by ID.
-	// Let's just return the Paseto.
+	return tokens, user, nil
+}
+
+// loginFailed counts a failed login and returns ErrInvalidCredentials. If
+// that locks the account, user (nil for unknown emails, which are locked
+// all the same) is emailed a link that unlocks it.
+func (s *AuthService) loginFailed(ctx context.Context, email string, user *db.User) error {
+	secret, err := s.logins.Fail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if secret != "" && user != nil {
+		link := s.platformURL + UnlockAccountPath + "?token=" + url.QueryEscape(secret)
+		if err := s.mailer.Send(ctx, mailer.AccountLocked(user.Email, link)); err != nil {
+			log.Error().Err(err).Str("user", user.ID.String()).Msg("failed to send account locked email")
+		}
+	}
+	return ErrInvalidCredentials
+}
 
-	return token, user, nil
+// Logout ends the session the request is signed in with
+func (s *AuthService) Logout(ctx context.Context, userID, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	err := s.sessions.Revoke(ctx, userID, sessionID)
+	if errors.Is(err, sessions.ErrNotFound) {
+		return nil
+	}
+	return err
 }
 
 // GetUser retrieves a user by ID
@@ -3,19 +3,33 @@ package service_test
 import (
 	"context"
 	"testing"
+	"time"
 
+	"bizbundl/internal/loginguard"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/otp"
+	auditservice "bizbundl/internal/platform/audit/service"
 	"bizbundl/internal/platform/auth/service"
+	"bizbundl/internal/server"
 	"bizbundl/internal/testutil"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func newAuthService(srv *server.Server) *service.AuthService {
+	queries := service.NewPlatformQueries(srv.GetDB().GetPool())
+	twoFactor := mfa.NewService(service.NewTwoFactorStore(queries), otp.NewMemoryStore(), srv.GetConfig().TokenSymmetricKey)
+	logins := loginguard.NewGuard(otp.NewMemoryStore(), auditservice.NewAuditService(queries))
+	return service.NewAuthService(queries, srv.GetSessions(), srv.GetMailer(), twoFactor, logins, srv.GetConfig().PlatformURL)
+}
+
 func TestRegister(t *testing.T) {
 	testutil.Cleanup(t)
 	defer testutil.Cleanup(t)
 
 	srv := testutil.SetupTestServer()
-	svc := service.NewAuthService(srv.GetDB(), srv.GetTokenMaker())
+	svc := newAuthService(srv)
 	ctx := context.Background()
 
 	email := testutil.RandomEmail()
@@ -36,7 +50,7 @@ func TestLogin(t *testing.T) {
 	defer testutil.Cleanup(t)
 
 	srv := testutil.SetupTestServer()
-	svc := service.NewAuthService(srv.GetDB(), srv.GetTokenMaker())
+	svc := newAuthService(srv)
 	ctx := context.Background()
 
 	email := testutil.RandomEmail()
@@ -56,3 +70,38 @@ func TestLogin(t *testing.T) {
 	_, _, err = svc.Login(ctx, "ghost@example.com", "any")
 	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
 }
+
+func TestLoginTwoFactor(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	svc := newAuthService(srv)
+	ctx := context.Background()
+
+	email := testutil.RandomEmail()
+	user, err := svc.Register(ctx, email, "securepass", "Owner", "")
+	require.NoError(t, err)
+	_, session, err := srv.GetSessions().Issue(ctx, user.ID.String(), "owner")
+	require.NoError(t, err)
+
+	enrollment, err := svc.SetupTwoFactor(ctx, user.ID)
+	require.NoError(t, err)
+	code, err := mfa.Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	recovery, err := svc.EnableTwoFactor(ctx, user.ID, session.ID, code)
+	require.NoError(t, err)
+	assert.Len(t, recovery, mfa.RecoveryCodeCount)
+
+	// The password alone is not enough any more
+	_, _, err = svc.Login(ctx, email, "securepass")
+	var challenge *mfa.ChallengeError
+	require.ErrorAs(t, err, &challenge)
+
+	_, _, err = svc.LoginTwoFactor(ctx, challenge.Challenge, "not-a-code")
+	assert.ErrorIs(t, err, mfa.ErrInvalidCode)
+	tokens, got, err := svc.LoginTwoFactor(ctx, challenge.Challenge, recovery[0])
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.Access)
+	assert.Equal(t, user.ID, got.ID)
+}
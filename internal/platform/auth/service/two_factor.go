@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/sessions"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// twoFactorIssuer names platform accounts in authenticator apps
+const twoFactorIssuer = "BizBundl"
+
+// twoFactorStore keeps two-factor state on platform users
+type twoFactorStore struct {
+	store *db.Queries
+}
+
+// NewTwoFactorStore returns the mfa.Store of platform accounts
+func NewTwoFactorStore(store *db.Queries) mfa.Store {
+	return &twoFactorStore{store: store}
+}
+
+func (t *twoFactorStore) GetFactor(ctx context.Context, userID pgtype.UUID) (mfa.Factor, error) {
+	row, err := t.store.GetUserTwoFactor(ctx, userID)
+	if err != nil {
+		return mfa.Factor{}, err
+	}
+	factor := mfa.Factor{
+		Enabled:           row.TotpEnabledAt.Valid,
+		LastStep:          row.TotpLastStep,
+		RecoveryCodesLeft: row.RecoveryCodesLeft,
+	}
+	if row.TotpSecret != nil {
+		factor.Secret = *row.TotpSecret
+	}
+	return factor, nil
+}
+
+func (t *twoFactorStore) SetSecret(ctx context.Context, userID pgtype.UUID, secret string) error {
+	return t.store.SetTOTPSecret(ctx, db.SetTOTPSecretParams{ID: userID, TotpSecret: &secret})
+}
+
+func (t *twoFactorStore) Enable(ctx context.Context, userID pgtype.UUID, step int64) error {
+	return t.store.EnableTOTP(ctx, db.EnableTOTPParams{ID: userID, TotpLastStep: step})
+}
+
+func (t *twoFactorStore) Disable(ctx context.Context, userID pgtype.UUID) error {
+	return t.store.DisableTOTP(ctx, userID)
+}
+
+func (t *twoFactorStore) UseStep(ctx context.Context, userID pgtype.UUID, step int64) (bool, error) {
+	n, err := t.store.UseTOTPStep(ctx, db.UseTOTPStepParams{ID: userID, TotpLastStep: step})
+	return n == 1, err
+}
+
+func (t *twoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID pgtype.UUID, hashes []string) error {
+	return t.store.ReplaceRecoveryCodes(ctx, db.ReplaceRecoveryCodesParams{UserID: userID, CodeHashes: hashes})
+}
+
+func (t *twoFactorStore) UseRecoveryCode(ctx context.Context, userID pgtype.UUID, hash string) (bool, error) {
+	n, err := t.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{UserID: userID, CodeHash: hash})
+	return n == 1, err
+}
+
+// LoginTwoFactor completes a sign-in that Login answered with a
+// *mfa.ChallengeError, with a code from the authenticator or a recovery code
+func (s *AuthService) LoginTwoFactor(ctx context.Context, challenge, code string) (sessions.Tokens, db.User, error) {
+	userID, err := s.twoFactor.CompleteChallenge(ctx, challenge, code)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, err
+	}
+	user, err := s.store.GetUserById(ctx, userID)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, ErrInvalidCredentials
+	}
+	tokens, _, err := s.sessions.IssueTwoFactor(ctx, user.ID.String(), "owner")
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, err
+	}
+	return tokens, user, nil
+}
+
+// TwoFactorStatus returns the user's two-factor state
+func (s *AuthService) TwoFactorStatus(ctx context.Context, userID pgtype.UUID) (mfa.Factor, error) {
+	return s.twoFactor.Status(ctx, userID)
+}
+
+// SetupTwoFactor starts enrolling the account; the enrollment is turned on
+// by EnableTwoFactor
+func (s *AuthService) SetupTwoFactor(ctx context.Context, userID pgtype.UUID) (mfa.Enrollment, error) {
+	user, err := s.store.GetUserById(ctx, userID)
+	if err != nil {
+		return mfa.Enrollment{}, ErrUserNotFound
+	}
+	return s.twoFactor.Setup(ctx, userID, twoFactorIssuer, user.Email)
+}
+
+// EnableTwoFactor finishes enrollment and returns the recovery codes. The
+// current session counts as having passed the second factor.
+func (s *AuthService) EnableTwoFactor(ctx context.Context, userID pgtype.UUID, sessionID, code string) ([]string, error) {
+	codes, err := s.twoFactor.Enable(ctx, userID, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.StepUp(ctx, userID.String(), sessionID, true); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID pgtype.UUID) error {
+	return s.twoFactor.Disable(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID pgtype.UUID) ([]string, error) {
+	return s.twoFactor.RegenerateRecoveryCodes(ctx, userID)
+}
+
+// StepUp re-authenticates the signed-in user for sensitive actions (see
+// middleware.RequireStepUp): with a code when they have two-factor
+// authentication enabled, with their password otherwise
+func (s *AuthService) StepUp(ctx context.Context, userID pgtype.UUID, sessionID, password, code string) error {
+	user, err := s.store.GetUserById(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	factor, err := s.twoFactor.Status(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if factor.Enabled {
+		if err := s.twoFactor.Verify(ctx, userID, code); err != nil {
+			return err
+		}
+	} else if !verifyPassword(password, user.PasswordHash) {
+		return ErrInvalidCredentials
+	}
+	return s.sessions.StepUp(ctx, userID.String(), sessionID, factor.Enabled)
+}
@@ -132,7 +132,7 @@ func Login(data LoginFormData) templ.Component {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "</div><button type=\"submit\" class=\"w-full py-2 bg-primary text-white font-bold rounded hover:bg-primary-hover transition loading-spinner\">Login</button><p class=\"text-xs text-center text-gray-400 mt-4\">Don't have an account? <a href=\"/register\" class=\"text-primary hover:underline\">Register</a></p></form></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "</div><button type=\"submit\" class=\"w-full py-2 bg-primary text-white font-bold rounded hover:bg-primary-hover transition loading-spinner\">Login</button><p class=\"text-xs text-center text-gray-400 mt-4\"><a href=\"/forgot-password\" class=\"text-primary hover:underline\">Forgot your password?</a></p><p class=\"text-xs text-center text-gray-400 mt-4\">Don't have an account? <a href=\"/register\" class=\"text-primary hover:underline\">Register</a></p></form></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"time"
+
+	"bizbundl/internal/audit"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// auditPageSize is how many entries ListAudit returns at a time
+const auditPageSize = 100
+
+type AuditEntryResponse struct {
+	ID         string          `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Actor      string          `json:"actor"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    *string         `json:"actor_id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	Metadata   json.RawMessage `json:"metadata"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+}
+
+type AuditPageResponse struct {
+	Entries []AuditEntryResponse `json:"entries"`
+	// Next is the before parameter of the next page, empty on the last one
+	Next string `json:"next"`
+}
+
+func newAuditEntryResponse(r audit.Row) AuditEntryResponse {
+	res := AuditEntryResponse{
+		ID:         r.ID.String(),
+		CreatedAt:  r.CreatedAt,
+		Actor:      r.Actor(),
+		ActorType:  r.ActorType,
+		Action:     r.Action,
+		EntityType: r.EntityType,
+		EntityID:   r.EntityID,
+		Before:     r.Before,
+		After:      r.After,
+		Metadata:   r.Metadata,
+		IP:         r.IP,
+		RequestID:  r.RequestID,
+	}
+	if r.ActorID.Valid {
+		id := r.ActorID.String()
+		res.ActorID = &id
+	}
+	return res
+}
+
+// ListAudit returns a page of the platform audit trail, filtered by the
+// query (see audit.FilterFromQuery)
+func (h *OpsHandler) ListAudit(c *fiber.Ctx) error {
+	f, err := audit.FilterFromQuery(c.Query)
+	if err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	rows, err := h.audit.List(c.UserContext(), f, auditPageSize+1)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+
+	var res AuditPageResponse
+	if len(rows) > auditPageSize {
+		rows = rows[:auditPageSize]
+		res.Next = audit.CursorOf(rows[len(rows)-1]).String()
+	}
+	res.Entries = make([]AuditEntryResponse, len(rows))
+	for i, r := range rows {
+		res.Entries[i] = newAuditEntryResponse(r)
+	}
+	c.Locals("cache_skip", true)
+	return util.JSON(c, fiber.StatusOK, res, "")
+}
+
+// ExportAudit downloads the platform audit entries matching the query as CSV
+func (h *OpsHandler) ExportAudit(c *fiber.Ctx) error {
+	f, err := audit.FilterFromQuery(c.Query)
+	if err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	c.Locals("cache_skip", true)
+	if err := h.audit.Export(c.UserContext(), c.Response().BodyWriter(), f); err != nil {
+		c.Response().ResetBody()
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	c.Attachment("platform-audit-log.csv")
+	return nil
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+
+	auditservice "bizbundl/internal/platform/audit/service"
+	"bizbundl/internal/platform/ops/service"
+	shopsservice "bizbundl/internal/platform/shops/service"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type OpsHandler struct {
+	cohorts *service.CohortService
+	shops   *shopsservice.PlatformService
+	audit   *auditservice.AuditService
+}
+
+func NewOpsHandler(cohorts *service.CohortService, shops *shopsservice.PlatformService, audit *auditservice.AuditService) *OpsHandler {
+	return &OpsHandler{cohorts: cohorts, shops: shops, audit: audit}
+}
+
+// MoveCohortRequest moves shops pinned to From (null = unpinned) to To.
+type MoveCohortRequest struct {
+	From          *string  `json:"from"`
+	To            *string  `json:"to"`
+	SchemaVersion *int64   `json:"schema_version"`
+	TenantIDs     []string `json:"tenant_ids"`
+	BatchSize     int      `json:"batch_size"`
+}
+
+type ShopResponse struct {
+	TenantID   string  `json:"tenant_id"`
+	Subdomain  string  `json:"subdomain"`
+	AppVersion *string `json:"app_version"`
+}
+
+// ListCohorts returns the number of active shops per app version
+func (h *OpsHandler) ListCohorts(c *fiber.Ctx) error {
+	cohorts, err := h.cohorts.Cohorts(c.UserContext())
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, cohorts, "")
+}
+
+// MoveCohort moves one batch of shops to another app version
+func (h *OpsHandler) MoveCohort(c *fiber.Ctx) error {
+	var req MoveCohortRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+
+	moved, err := h.cohorts.Move(c.UserContext(), service.MoveParams{
+		From:          req.From,
+		To:            req.To,
+		SchemaVersion: req.SchemaVersion,
+		TenantIDs:     req.TenantIDs,
+		BatchSize:     req.BatchSize,
+	})
+	if errors.Is(err, service.ErrInvalidBatch) {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+
+	res := make([]ShopResponse, 0, len(moved))
+	for _, shop := range moved {
+		res = append(res, ShopResponse{TenantID: shop.TenantID, Subdomain: shop.Subdomain, AppVersion: shop.AppVersion})
+	}
+	return util.JSON(c, fiber.StatusOK, res, "Shops moved")
+}
+
+type SuspendShopRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// SuspendShop takes a shop offline on behalf of the platform.
+// Owners cannot lift platform suspensions from the dashboard.
+func (h *OpsHandler) SuspendShop(c *fiber.Ctx) error {
+	var shopID pgtype.UUID
+	if err := shopID.Scan(c.Params("id")); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	var req SuspendShopRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	shop, err := h.shops.SuspendShop(c.UserContext(), shopID, shopsservice.SuspendedByPlatform, req.Reason)
+	if errors.Is(err, shopsservice.ErrShopNotFound) {
+		return util.APIError(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, ShopResponse{TenantID: shop.TenantID, Subdomain: shop.Subdomain, AppVersion: shop.AppVersion}, "Shop suspended")
+}
+
+// UnsuspendShop puts a shop back online, whoever suspended it
+func (h *OpsHandler) UnsuspendShop(c *fiber.Ctx) error {
+	var shopID pgtype.UUID
+	if err := shopID.Scan(c.Params("id")); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	_, err := h.shops.LiftSuspension(c.UserContext(), shopID)
+	if errors.Is(err, shopsservice.ErrShopNotFound) {
+		return util.APIError(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Shop unsuspended")
+}
+
+// ListClusters returns the database cluster directory with shop counts
+func (h *OpsHandler) ListClusters(c *fiber.Ctx) error {
+	clusters, err := h.shops.Clusters(c.UserContext())
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, clusters, "")
+}
+
+type SaveTemplateRequest struct {
+	Key         string `json:"key" validate:"required,max=50"`
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description"`
+	TenantID    string `json:"tenant_id" validate:"required"`
+	Position    int32  `json:"position"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// ListTemplates returns every shop template, including inactive ones
+func (h *OpsHandler) ListTemplates(c *fiber.Ctx) error {
+	templates, err := h.shops.AllTemplates(c.UserContext())
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, templates, "")
+}
+
+// SaveTemplate registers a shop as a template new shops can be cloned from
+func (h *OpsHandler) SaveTemplate(c *fiber.Ctx) error {
+	var req SaveTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	isActive := req.IsActive == nil || *req.IsActive
+	template, err := h.shops.SaveTemplate(c.UserContext(), shopsservice.SaveTemplateParams{
+		Key:         req.Key,
+		Name:        req.Name,
+		Description: req.Description,
+		TenantID:    req.TenantID,
+		Position:    req.Position,
+		IsActive:    isActive,
+	})
+	if errors.Is(err, shopsservice.ErrShopNotFound) {
+		return util.APIError(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	return util.JSON(c, fiber.StatusOK, template, "Template saved")
+}
@@ -0,0 +1,36 @@
+package ops
+
+import (
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/middleware"
+	auditservice "bizbundl/internal/platform/audit/service"
+	"bizbundl/internal/platform/ops/handler"
+	"bizbundl/internal/platform/ops/service"
+	shopsservice "bizbundl/internal/platform/shops/service"
+	"bizbundl/internal/server"
+)
+
+// Init registers the operator API (/api/ops), guarded by PLATFORM_OPS_TOKEN
+func Init(app *server.Server, shops *shopsservice.PlatformService) {
+	// 1. Dependency
+	queries := platformdb.New(app.GetDB().GetPool())
+
+	// 2. Services
+	cohorts := service.NewCohortService(queries, app.GetTenantResolver())
+	audit := auditservice.NewAuditService(queries)
+
+	// 3. Handler
+	h := handler.NewOpsHandler(cohorts, shops, audit)
+
+	// 4. Routes
+	ops := app.GetRouter().Group("/api/ops", middleware.OpsToken(app.GetConfig().PlatformOpsToken))
+	ops.Get("/cohorts", h.ListCohorts)
+	ops.Post("/cohorts/move", h.MoveCohort)
+	ops.Post("/shops/:id/suspend", h.SuspendShop)
+	ops.Post("/shops/:id/unsuspend", h.UnsuspendShop)
+	ops.Get("/clusters", h.ListClusters)
+	ops.Get("/templates", h.ListTemplates)
+	ops.Post("/templates", h.SaveTemplate)
+	ops.Get("/audit", h.ListAudit)
+	ops.Get("/audit/export", h.ExportAudit)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/tenancy"
+)
+
+const (
+	DefaultBatchSize = 10
+	MaxBatchSize     = 500
+)
+
+var ErrInvalidBatch = errors.New("batch size must be between 1 and 500")
+
+// CohortService moves shops between app versions for blue/green rollouts
+type CohortService struct {
+	store   db.Querier
+	tenants *tenancy.Resolver
+}
+
+func NewCohortService(store db.Querier, tenants *tenancy.Resolver) *CohortService {
+	return &CohortService{store: store, tenants: tenants}
+}
+
+// MoveParams selects the shops to move. Nil From/To mean "unpinned".
+type MoveParams struct {
+	From *string
+	To   *string
+	// SchemaVersion only moves shops whose schema has been migrated to it
+	SchemaVersion *int64
+	TenantIDs     []string
+	BatchSize     int
+}
+
+// Move re-pins one batch of shops and drops their cached routing entries so
+// the new cohort takes effect immediately. Call repeatedly to move a cohort
+// batch by batch; an empty result means nothing is left to move.
+func (s *CohortService) Move(ctx context.Context, arg MoveParams) ([]db.Shop, error) {
+	if arg.BatchSize == 0 {
+		arg.BatchSize = DefaultBatchSize
+	}
+	if arg.BatchSize < 1 || arg.BatchSize > MaxBatchSize {
+		return nil, ErrInvalidBatch
+	}
+	if arg.TenantIDs == nil {
+		arg.TenantIDs = []string{}
+	}
+
+	moved, err := s.store.MoveShopsToAppVersion(ctx, db.MoveShopsToAppVersionParams{
+		ToVersion:     arg.To,
+		FromVersion:   arg.From,
+		SchemaVersion: arg.SchemaVersion,
+		TenantIds:     arg.TenantIDs,
+		BatchSize:     int32(arg.BatchSize),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to move shops: %w", err)
+	}
+
+	for _, shop := range moved {
+		s.tenants.InvalidateShop(ctx, shop)
+	}
+	return moved, nil
+}
+
+// Cohorts counts active shops per app version
+func (s *CohortService) Cohorts(ctx context.Context) ([]db.CountShopsByAppVersionRow, error) {
+	return s.store.CountShopsByAppVersion(ctx)
+}
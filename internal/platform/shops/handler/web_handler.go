@@ -1,7 +1,11 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+
 	"bizbundl/internal/platform/shops/service"
+	"bizbundl/internal/sessions"
 	"bizbundl/internal/views/platform"
 	"bizbundl/util"
 
@@ -10,11 +14,12 @@ import (
 )
 
 type PlatformWebHandler struct {
-	service *service.PlatformService
+	service  *service.PlatformService
+	sessions *sessions.Manager
 }
 
-func NewPlatformWebHandler(s *service.PlatformService) *PlatformWebHandler {
-	return &PlatformWebHandler{service: s}
+func NewPlatformWebHandler(s *service.PlatformService, sessions *sessions.Manager) *PlatformWebHandler {
+	return &PlatformWebHandler{service: s, sessions: sessions}
 }
 
 // ShowDashboard renders the main list of shops
@@ -35,24 +40,34 @@ func (h *PlatformWebHandler) ShowDashboard(c *fiber.Ctx) error {
 		return c.Status(500).SendString("Failed to load shops")
 	}
 
+	memberShops, err := h.service.MemberShops(c.Context(), ownerID)
+	if err != nil {
+		return c.Status(500).SendString("Failed to load shops")
+	}
+
 	// 3. Render Template (Web Layer)
-	return util.Render(c, platform.Dashboard(shops))
+	return util.Render(c, platform.Dashboard(shops, h.service.Usage(c.Context(), shops), memberShops))
 }
 
 func (h *PlatformWebHandler) ShowCreateShopForm(c *fiber.Ctx) error {
-	return util.Render(c, platform.CreateShopForm())
+	templates, err := h.service.Templates(c.Context())
+	if err != nil {
+		return c.Status(500).SendString("Failed to load templates")
+	}
+	return util.Render(c, platform.CreateShopForm(templates))
 }
 
 func (h *PlatformWebHandler) HandleCreateShop(c *fiber.Ctx) error {
 	// 1. Parse Form
 	name := c.FormValue("name")
+	template := c.FormValue("template")
 
 	userIDStr, _ := c.Locals("user_id").(string)
 	var ownerID pgtype.UUID
 	ownerID.Scan(userIDStr)
 
 	// 2. Call Service
-	_, err := h.service.CreateShop(c.Context(), ownerID, name)
+	_, err := h.service.CreateShop(c.Context(), ownerID, name, template)
 	if err != nil {
 		// Return Form with Error
 		return c.SendString("Error: " + err.Error())
@@ -61,3 +76,341 @@ func (h *PlatformWebHandler) HandleCreateShop(c *fiber.Ctx) error {
 	// 3. Redirect
 	return c.Redirect("/dashboard")
 }
+
+// shopAction parses the owner and shop IDs of a /dashboard/shops/:id/* action.
+// When ok is false the response has already been written.
+func shopAction(c *fiber.Ctx) (ownerID, shopID pgtype.UUID, ok bool) {
+	userIDStr, ok := c.Locals("user_id").(string)
+	if !ok {
+		c.Redirect("/login")
+		return ownerID, shopID, false
+	}
+	ownerID.Scan(userIDStr)
+
+	if err := shopID.Scan(c.Params("id")); err != nil {
+		c.Status(fiber.StatusBadRequest).SendString("Invalid shop")
+		return ownerID, shopID, false
+	}
+	return ownerID, shopID, true
+}
+
+// shopActionDone redirects back to the dashboard, or reports the service error
+func shopActionDone(c *fiber.Ctx, err error) error {
+	if err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect("/dashboard")
+}
+
+func (h *PlatformWebHandler) HandleSetCustomDomain(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	_, err := h.service.SetCustomDomain(c.Context(), ownerID, shopID, c.FormValue("custom_domain"))
+	return shopActionDone(c, err)
+}
+
+func (h *PlatformWebHandler) HandleRetryProvisioning(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	_, err := h.service.RetryProvisioning(c.Context(), ownerID, shopID)
+	return shopActionDone(c, err)
+}
+
+func (h *PlatformWebHandler) HandlePauseShop(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	_, err := h.service.PauseShop(c.Context(), ownerID, shopID)
+	return shopActionDone(c, err)
+}
+
+func (h *PlatformWebHandler) HandleResumeShop(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	_, err := h.service.ResumeShop(c.Context(), ownerID, shopID)
+	return shopActionDone(c, err)
+}
+
+func (h *PlatformWebHandler) HandleSetMaintenance(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	enabled := c.FormValue("maintenance_mode") == "on"
+	_, err := h.service.SetMaintenance(c.Context(), ownerID, shopID, enabled, c.FormValue("allowlist"))
+	return shopActionDone(c, err)
+}
+
+func (h *PlatformWebHandler) HandleSetStorefrontPassword(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	password := c.FormValue("password")
+	if c.FormValue("remove") != "" {
+		password = ""
+	}
+	_, err := h.service.SetStorefrontPassword(c.Context(), ownerID, shopID, password)
+	return shopActionDone(c, err)
+}
+
+func (h *PlatformWebHandler) HandleDeleteShop(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	shop, err := h.service.GetOwnedShop(c.Context(), ownerID, shopID)
+	if err != nil {
+		return shopActionDone(c, err)
+	}
+	// Typing the subdomain confirms the deletion
+	if c.FormValue("confirm") != shop.Subdomain {
+		return c.SendString("Error: type the shop subdomain to confirm deletion")
+	}
+	_, err = h.service.DeleteShop(c.Context(), ownerID, shopID, c.IP())
+	return shopActionDone(c, err)
+}
+
+// HandleSetRequireTwoFactor turns the shop's two-factor requirement for
+// staff on or off. It runs behind RequireStepUp.
+func (h *PlatformWebHandler) HandleSetRequireTwoFactor(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	required := c.FormValue("require_two_factor") == "on"
+	_, err := h.service.SetRequireTwoFactor(c.Context(), ownerID, shopID, required)
+	return shopActionDone(c, err)
+}
+
+func (h *PlatformWebHandler) HandleRestoreShop(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	_, err := h.service.RestoreShop(c.Context(), ownerID, shopID, c.IP())
+	return shopActionDone(c, err)
+}
+
+func (h *PlatformWebHandler) HandleDownloadExport(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	path, err := h.service.ShopExport(c.Context(), ownerID, shopID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Error: " + err.Error())
+	}
+	return c.Download(path)
+}
+
+func (h *PlatformWebHandler) ShowBilling(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	billing, err := h.service.Billing(c.Context(), ownerID, shopID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Error: " + err.Error())
+	}
+	return util.Render(c, platform.Billing(billing.Shop, billing.Subscription, billing.Plans, billing.Invoices))
+}
+
+func (h *PlatformWebHandler) HandleChangePlan(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	if _, err := h.service.ChangePlan(c.Context(), ownerID, shopID, c.FormValue("plan")); err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect(billingURL(shopID))
+}
+
+// HandlePayInvoice sends the owner to the gateway's payment page
+func (h *PlatformWebHandler) HandlePayInvoice(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	var invoiceID pgtype.UUID
+	if err := invoiceID.Scan(c.Params("invoice")); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid invoice")
+	}
+	url, err := h.service.PaymentLink(c.Context(), ownerID, shopID, invoiceID)
+	if err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect(url)
+}
+
+// HandleBillingCallback is where the gateway sends the owner after paying.
+// invoice is ours, invoice_id is the gateway's.
+func (h *PlatformWebHandler) HandleBillingCallback(c *fiber.Ctx) error {
+	var invoiceID pgtype.UUID
+	if err := invoiceID.Scan(c.Query("invoice")); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid invoice")
+	}
+	invoice, err := h.service.ConfirmPayment(c.Context(), invoiceID, c.Query("invoice_id"))
+	if err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect(billingURL(invoice.ShopID))
+}
+
+type billingWebhook struct {
+	InvoiceID string `json:"invoice_id"`
+	Metadata  struct {
+		OrderID string `json:"order_id"`
+	} `json:"metadata"`
+}
+
+// HandleBillingWebhook confirms payments the owner never returned from.
+// The payload only says which payment to check; ConfirmPayment asks the
+// gateway for the result.
+func (h *PlatformWebHandler) HandleBillingWebhook(c *fiber.Ctx) error {
+	var req billingWebhook
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	var invoiceID pgtype.UUID
+	if err := invoiceID.Scan(req.Metadata.OrderID); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, service.ErrInvoiceNotFound)
+	}
+
+	invoice, err := h.service.ConfirmPayment(c.Context(), invoiceID, req.InvoiceID)
+	if errors.Is(err, service.ErrInvoiceNotFound) {
+		return util.APIError(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusUnprocessableEntity, err)
+	}
+	return util.JSON(c, fiber.StatusOK, fiber.Map{"status": invoice.Status}, "Payment confirmed")
+}
+
+func billingURL(shopID pgtype.UUID) string {
+	return fmt.Sprintf("/dashboard/shops/%s/billing", shopID.String())
+}
+
+func (h *PlatformWebHandler) ShowTeam(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	team, err := h.service.Team(c.Context(), ownerID, shopID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Error: " + err.Error())
+	}
+	return util.Render(c, platform.Team(team.Shop, team.Members, team.Invitations, ""))
+}
+
+// HandleInviteMember creates and emails the invitation, and shows its link
+// on the team page so the owner can pass it on too.
+func (h *PlatformWebHandler) HandleInviteMember(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	_, link, err := h.service.InviteMember(c.Context(), ownerID, shopID, c.FormValue("email"), c.FormValue("role"))
+	if err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	team, err := h.service.Team(c.Context(), ownerID, shopID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Error: " + err.Error())
+	}
+	return util.Render(c, platform.Team(team.Shop, team.Members, team.Invitations, link))
+}
+
+func (h *PlatformWebHandler) HandleSetMemberRole(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	var userID pgtype.UUID
+	if err := userID.Scan(c.Params("user")); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid member")
+	}
+	if err := h.service.SetMemberRole(c.Context(), ownerID, shopID, userID, c.FormValue("role")); err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect(teamURL(shopID))
+}
+
+func (h *PlatformWebHandler) HandleRemoveMember(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	var userID pgtype.UUID
+	if err := userID.Scan(c.Params("user")); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid member")
+	}
+	if err := h.service.RemoveMember(c.Context(), ownerID, shopID, userID); err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect(teamURL(shopID))
+}
+
+func (h *PlatformWebHandler) HandleRevokeInvitation(c *fiber.Ctx) error {
+	ownerID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	var invitationID pgtype.UUID
+	if err := invitationID.Scan(c.Params("invitation")); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid invitation")
+	}
+	if err := h.service.RevokeInvitation(c.Context(), ownerID, shopID, invitationID); err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect(teamURL(shopID))
+}
+
+// HandleOpenShop signs the user into the admin of a shop they own or work in
+func (h *PlatformWebHandler) HandleOpenShop(c *fiber.Ctx) error {
+	userID, shopID, ok := shopAction(c)
+	if !ok {
+		return nil
+	}
+	// A platform sign-in with a second factor counts as one in the shop
+	sessionID, _ := c.Locals("session_id").(string)
+	session, err := h.sessions.Get(c.Context(), userID.String(), sessionID)
+	twoFactor := err == nil && session.TwoFactor
+
+	url, err := h.service.OpenShop(c.Context(), userID, shopID, twoFactor)
+	if errors.Is(err, service.ErrShopNotFound) || errors.Is(err, service.ErrNoShopAccess) {
+		return c.Status(fiber.StatusForbidden).SendString("Error: " + err.Error())
+	}
+	if err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect(url)
+}
+
+// HandleAcceptInvitation joins the signed-in platform user to the shop the
+// invitation link is for.
+func (h *PlatformWebHandler) HandleAcceptInvitation(c *fiber.Ctx) error {
+	userIDStr, _ := c.Locals("user_id").(string)
+	if role, _ := c.Locals("user_role").(string); role != "owner" || userIDStr == "" {
+		return c.Status(fiber.StatusUnauthorized).SendString("Sign in or create an account with the invited email address, then open the invitation link again.")
+	}
+	var userID pgtype.UUID
+	userID.Scan(userIDStr)
+
+	if _, err := h.service.AcceptInvitation(c.Context(), userID, c.Query("token")); err != nil {
+		return c.SendString("Error: " + err.Error())
+	}
+	return c.Redirect("/dashboard")
+}
+
+func teamURL(shopID pgtype.UUID) string {
+	return fmt.Sprintf("/dashboard/shops/%s/team", shopID.String())
+}
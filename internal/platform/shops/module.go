@@ -1,24 +1,60 @@
 package shops
 
 import (
+	"context"
+	"time"
+
+	"bizbundl/internal/constants"
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/modules/payment/providers/uddoktapay"
 	"bizbundl/internal/platform/shops/handler"
 	"bizbundl/internal/platform/shops/service"
 	"bizbundl/internal/server"
-	// "bizbundl/internal/middleware" // Auth middleware needed
+	pbservice "bizbundl/pkgs/page_builder/service"
+
+	"github.com/rs/zerolog/log"
 )
 
 // Init orchestrates the Platform Module
-func Init(app *server.Server) {
+func Init(app *server.Server) *service.PlatformService {
 	// 1. Dependency
 	// We need generic Pool to access 'platform' schema queries
 	pool := app.GetDB().GetPool()
 	cfg := app.GetConfig()
 
 	// 2. Service
-	svc := service.NewPlatformService(pool, cfg)
+	// New shops get the default pages seeded into their schema
+	seeder := pbservice.NewPageBuilderService(app.GetDB(), app.GetEntitlements())
+	// Subscription invoices are paid through the gateway, which sends the
+	// owner back to the dashboard
+	gateway := uddoktapay.New(cfg.BillingGatewayKey)
+	gateway.RedirectURL = cfg.PlatformURL + "/dashboard/billing/callback?invoice=%s"
+	gateway.CancelURL = cfg.PlatformURL + "/dashboard"
+	gateway.WebhookURL = cfg.PlatformURL + "/api/billing/webhook"
+	svc := service.NewPlatformService(pool, cfg, app.GetTenantResolver(), app.GetClusters(), app.GetEntitlements(), app.GetPermissions(), seeder, gateway, app.GetMailer(), app.GetSessions())
+	svc.SetBillingNotifier(service.NewMailNotifier(app.GetMailer(), cfg.PlatformURL))
+
+	// Finish shops left half-provisioned by a crash or deploy
+	go func() {
+		n, err := svc.ResumeProvisioning(context.Background(), 5*time.Minute)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to resume shop provisioning")
+			return
+		}
+		if n > 0 {
+			log.Info().Int("count", n).Msg("resumed shop provisioning")
+		}
+	}()
+
+	// Export deleted shops, drop their schemas once their retention window
+	// has passed
+	go svc.RunOffboardingJob(context.Background(), time.Minute)
+
+	// Issue renewal invoices, chase unpaid ones, suspend after the grace period
+	go svc.RunBillingJob(context.Background(), time.Hour)
 
 	// 3. Handler
-	h := handler.NewPlatformWebHandler(svc)
+	h := handler.NewPlatformWebHandler(svc, app.GetSessions())
 
 	// 4. Routes
 	// Group: /dashboard (Protected)
@@ -40,8 +76,36 @@ func Init(app *server.Server) {
 
 	r := app.GetRouter()
 	dash := r.Group("/dashboard")
+	// Sensitive actions: the owner confirms it's them first
+	stepUp := middleware.RequireStepUp(app.GetSessions(), constants.StepUpWindow)
 
 	dash.Get("/", h.ShowDashboard)
 	dash.Get("/shops/new", h.ShowCreateShopForm)
 	dash.Post("/shops", h.HandleCreateShop)
+	dash.Post("/shops/:id/domain", h.HandleSetCustomDomain)
+	dash.Post("/shops/:id/retry", h.HandleRetryProvisioning)
+	dash.Post("/shops/:id/pause", h.HandlePauseShop)
+	dash.Post("/shops/:id/resume", h.HandleResumeShop)
+	dash.Post("/shops/:id/maintenance", h.HandleSetMaintenance)
+	dash.Post("/shops/:id/password", h.HandleSetStorefrontPassword)
+	dash.Post("/shops/:id/two-factor", stepUp, h.HandleSetRequireTwoFactor)
+	dash.Post("/shops/:id/delete", stepUp, h.HandleDeleteShop)
+	dash.Post("/shops/:id/restore", h.HandleRestoreShop)
+	dash.Get("/shops/:id/export", h.HandleDownloadExport)
+	dash.Get("/shops/:id/billing", h.ShowBilling)
+	dash.Post("/shops/:id/plan", h.HandleChangePlan)
+	dash.Get("/shops/:id/invoices/:invoice/pay", h.HandlePayInvoice)
+	dash.Get("/billing/callback", h.HandleBillingCallback)
+	dash.Get("/shops/:id/team", h.ShowTeam)
+	dash.Post("/shops/:id/team/invite", h.HandleInviteMember)
+	dash.Post("/shops/:id/team/:user/role", h.HandleSetMemberRole)
+	dash.Post("/shops/:id/team/:user/remove", h.HandleRemoveMember)
+	dash.Post("/shops/:id/invitations/:invitation/revoke", h.HandleRevokeInvitation)
+	dash.Get("/shops/:id/open", h.HandleOpenShop)
+	dash.Get("/invitations/accept", h.HandleAcceptInvitation)
+
+	// Gateway server-to-server notification (no session)
+	r.Post("/api/billing/webhook", h.HandleBillingWebhook)
+
+	return svc
 }
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	db "bizbundl/internal/db/sqlc/platform"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Who suspended a shop (shops.suspended_by). Only the same party may lift it.
+const (
+	SuspendedByOwner    = "owner"
+	SuspendedByBilling  = "billing"
+	SuspendedByPlatform = "platform"
+)
+
+const minStorefrontPasswordLength = 4
+
+var (
+	ErrSuspensionLocked     = errors.New("shop was suspended by the platform and cannot be resumed from here")
+	ErrInvalidAllowlist     = errors.New("allowlist entries must be IP addresses or CIDR ranges")
+	ErrStorefrontPassword   = errors.New("storefront password must be at least 4 characters")
+	ErrShopAlreadySuspended = errors.New("shop is already suspended")
+	ErrTwoFactorNotEnabled  = errors.New("turn on two-factor authentication for your own account first")
+)
+
+// PauseShop takes the owner's shop offline until ResumeShop
+func (s *PlatformService) PauseShop(ctx context.Context, ownerID, shopID pgtype.UUID) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
+	}
+	if shop.SuspendedAt.Valid {
+		return db.Shop{}, ErrShopAlreadySuspended
+	}
+	return s.SuspendShop(ctx, shop.ID, SuspendedByOwner, "Paused by owner")
+}
+
+// ResumeShop lifts a suspension the owner put in place themselves
+func (s *PlatformService) ResumeShop(ctx context.Context, ownerID, shopID pgtype.UUID) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
+	}
+	if !shop.SuspendedAt.Valid {
+		return shop, nil
+	}
+	if shop.SuspendedBy == nil || *shop.SuspendedBy != SuspendedByOwner {
+		return db.Shop{}, ErrSuspensionLocked
+	}
+	return s.LiftSuspension(ctx, shop.ID)
+}
+
+// SuspendShop stops a shop from serving any traffic (platform/billing use)
+func (s *PlatformService) SuspendShop(ctx context.Context, shopID pgtype.UUID, by, reason string) (db.Shop, error) {
+	shop, err := s.store.SuspendShop(ctx, db.SuspendShopParams{
+		ID:               shopID,
+		SuspendedBy:      &by,
+		SuspensionReason: &reason,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.Shop{}, ErrShopNotFound
+	}
+	if err != nil {
+		return db.Shop{}, fmt.Errorf("failed to suspend shop: %w", err)
+	}
+	s.tenants.InvalidateShop(ctx, shop)
+	return shop, nil
+}
+
+// LiftSuspension puts a suspended shop back online regardless of who suspended it
+func (s *PlatformService) LiftSuspension(ctx context.Context, shopID pgtype.UUID) (db.Shop, error) {
+	shop, err := s.store.UnsuspendShop(ctx, shopID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.Shop{}, ErrShopNotFound
+	}
+	if err != nil {
+		return db.Shop{}, fmt.Errorf("failed to unsuspend shop: %w", err)
+	}
+	s.tenants.InvalidateShop(ctx, shop)
+	return shop, nil
+}
+
+// SetMaintenance toggles maintenance mode. The allowlist is a comma or newline
+// separated list of IPs/CIDRs that may still browse the storefront.
+func (s *PlatformService) SetMaintenance(ctx context.Context, ownerID, shopID pgtype.UUID, enabled bool, allowlist string) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
+	}
+
+	entries, err := parseAllowlist(allowlist)
+	if err != nil {
+		return db.Shop{}, err
+	}
+
+	updated, err := s.store.UpdateShopMaintenance(ctx, db.UpdateShopMaintenanceParams{
+		ID:                   shop.ID,
+		MaintenanceMode:      enabled,
+		MaintenanceAllowlist: entries,
+	})
+	if err != nil {
+		return db.Shop{}, fmt.Errorf("failed to update maintenance mode: %w", err)
+	}
+	s.tenants.InvalidateShop(ctx, updated)
+	return updated, nil
+}
+
+// SetStorefrontPassword enables the pre-launch password gate.
+// An empty password removes the gate.
+func (s *PlatformService) SetStorefrontPassword(ctx context.Context, ownerID, shopID pgtype.UUID, password string) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
+	}
+
+	var hash *string
+	if password != "" {
+		if len(password) < minStorefrontPasswordLength {
+			return db.Shop{}, ErrStorefrontPassword
+		}
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return db.Shop{}, fmt.Errorf("failed to hash password: %w", err)
+		}
+		h := string(bytes)
+		hash = &h
+	}
+
+	updated, err := s.store.UpdateShopStorefrontPassword(ctx, db.UpdateShopStorefrontPasswordParams{
+		ID:                     shop.ID,
+		StorefrontPasswordHash: hash,
+	})
+	if err != nil {
+		return db.Shop{}, fmt.Errorf("failed to update storefront password: %w", err)
+	}
+	s.tenants.InvalidateShop(ctx, updated)
+	return updated, nil
+}
+
+// SetRequireTwoFactor makes a second factor mandatory for the shop's staff:
+// sessions without one are kept out of the admin. The owner must have it
+// on, or they would lock themselves out.
+func (s *PlatformService) SetRequireTwoFactor(ctx context.Context, ownerID, shopID pgtype.UUID, required bool) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
+	}
+	if required {
+		factor, err := s.store.GetUserTwoFactor(ctx, ownerID)
+		if err != nil {
+			return db.Shop{}, fmt.Errorf("failed to get two-factor status: %w", err)
+		}
+		if !factor.TotpEnabledAt.Valid {
+			return db.Shop{}, ErrTwoFactorNotEnabled
+		}
+	}
+
+	updated, err := s.store.UpdateShopRequireTwoFactor(ctx, db.UpdateShopRequireTwoFactorParams{
+		ID:               shop.ID,
+		RequireTwoFactor: required,
+	})
+	if err != nil {
+		return db.Shop{}, fmt.Errorf("failed to update two-factor requirement: %w", err)
+	}
+	s.tenants.InvalidateShop(ctx, updated)
+	return updated, nil
+}
+
+func parseAllowlist(raw string) ([]string, error) {
+	entries := []string{}
+	for _, entry := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' || r == ' ' || r == '\r' }) {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return nil, ErrInvalidAllowlist
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,671 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	tenantdb "bizbundl/internal/db/sqlc"
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/infra/mailer"
+	"bizbundl/internal/modules/payment"
+	auditservice "bizbundl/internal/platform/audit/service"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+// Invoice kinds and statuses (invoices.kind, invoices.status)
+const (
+	InvoiceRenewal   = "renewal"
+	InvoiceProration = "proration"
+
+	InvoiceOpen = "open"
+	InvoicePaid = "paid"
+	InvoiceVoid = "void"
+)
+
+// Subscription statuses set by billing. canceled and unpaid (see
+// tenancy.lapsedSubscriptions) are left to the platform.
+const (
+	SubscriptionActive  = "active"
+	SubscriptionPastDue = "past_due"
+)
+
+var (
+	ErrUnknownPlan         = errors.New("unknown plan")
+	ErrSamePlan            = errors.New("shop is already on this plan")
+	ErrInvoiceOutstanding  = errors.New("pay the open plan change invoice before changing plans again")
+	ErrInvoiceOverdue      = errors.New("pay the overdue invoices before changing plans")
+	ErrInvoiceNotFound     = errors.New("invoice not found")
+	ErrInvoiceNotOpen      = errors.New("invoice is no longer open")
+	ErrPaymentNotCompleted = errors.New("payment has not completed")
+	ErrPaymentMismatch     = errors.New("payment does not match the invoice")
+)
+
+// BillingNotifier tells shop owners about invoices they need to pay
+type BillingNotifier interface {
+	InvoiceIssued(ctx context.Context, email string, shop db.Shop, invoice db.Invoice)
+	PaymentOverdue(ctx context.Context, email string, shop db.Shop, invoice db.Invoice)
+}
+
+// logNotifier is used until a notifier is set with SetBillingNotifier
+type logNotifier struct{}
+
+func (logNotifier) InvoiceIssued(ctx context.Context, email string, shop db.Shop, invoice db.Invoice) {
+	log.Info().Str("tenant_id", shop.TenantID).Str("email", email).Str("invoice_id", invoice.ID.String()).Str("payment_url", paymentURL(invoice)).Msg("invoice issued")
+}
+
+func (logNotifier) PaymentOverdue(ctx context.Context, email string, shop db.Shop, invoice db.Invoice) {
+	log.Info().Str("tenant_id", shop.TenantID).Str("email", email).Str("invoice_id", invoice.ID.String()).Str("payment_url", paymentURL(invoice)).Msg("payment reminder")
+}
+
+// mailNotifier emails owners their invoices and reminders
+type mailNotifier struct {
+	mailer      mailer.Mailer
+	platformURL string
+}
+
+// NewMailNotifier emails owners with m. Invoices without a payment link
+// point to the shop's billing page under platformURL.
+func NewMailNotifier(m mailer.Mailer, platformURL string) BillingNotifier {
+	return mailNotifier{mailer: m, platformURL: platformURL}
+}
+
+func (n mailNotifier) InvoiceIssued(ctx context.Context, email string, shop db.Shop, invoice db.Invoice) {
+	msg := mailer.InvoiceIssued(email, shop.Name, invoice.PlanName, formatCents(toCents(invoice.Amount)), invoice.DueAt.Time.Format("January 2, 2006"), n.link(shop, invoice))
+	if err := n.mailer.Send(ctx, msg); err != nil {
+		log.Error().Err(err).Str("tenant_id", shop.TenantID).Str("invoice_id", invoice.ID.String()).Msg("failed to email invoice")
+	}
+}
+
+func (n mailNotifier) PaymentOverdue(ctx context.Context, email string, shop db.Shop, invoice db.Invoice) {
+	msg := mailer.PaymentOverdue(email, shop.Name, formatCents(toCents(invoice.Amount)), invoice.DueAt.Time.Format("January 2, 2006"), n.link(shop, invoice))
+	if err := n.mailer.Send(ctx, msg); err != nil {
+		log.Error().Err(err).Str("tenant_id", shop.TenantID).Str("invoice_id", invoice.ID.String()).Msg("failed to email payment reminder")
+	}
+}
+
+func (n mailNotifier) link(shop db.Shop, invoice db.Invoice) string {
+	if link := paymentURL(invoice); link != "" {
+		return link
+	}
+	return n.platformURL + "/dashboard/shops/" + shop.ID.String() + "/billing"
+}
+
+func paymentURL(invoice db.Invoice) string {
+	if invoice.PaymentUrl == nil {
+		return ""
+	}
+	return *invoice.PaymentUrl
+}
+
+// SetBillingNotifier replaces the default (log only) billing notifier
+func (s *PlatformService) SetBillingNotifier(n BillingNotifier) {
+	s.notifier = n
+}
+
+// BillingOverview is what the owner's billing page shows
+type BillingOverview struct {
+	Shop         db.Shop
+	Subscription *db.Subscription // nil while the shop is on the free default
+	Plans        []db.Plan
+	Invoices     []db.Invoice
+}
+
+// Billing returns the subscription, available plans and recent invoices of a shop
+func (s *PlatformService) Billing(ctx context.Context, ownerID, shopID pgtype.UUID) (BillingOverview, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return BillingOverview{}, err
+	}
+	overview := BillingOverview{Shop: shop}
+
+	sub, err := s.store.GetLatestSubscriptionByShop(ctx, shop.ID)
+	if err == nil {
+		overview.Subscription = &sub
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return BillingOverview{}, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	if overview.Plans, err = s.store.ListPlans(ctx); err != nil {
+		return BillingOverview{}, fmt.Errorf("failed to list plans: %w", err)
+	}
+	overview.Invoices, err = s.store.ListInvoicesByShop(ctx, db.ListInvoicesByShopParams{ShopID: shop.ID, Limit: 24})
+	if err != nil {
+		return BillingOverview{}, fmt.Errorf("failed to list invoices: %w", err)
+	}
+	return overview, nil
+}
+
+// ChangePlan moves the shop to planKey immediately. The rest of the current
+// period is prorated: an upgrade issues an invoice for the difference, a
+// downgrade leaves a credit that is taken off the next renewal.
+// A shop without a subscription starts one and is billed for a full period.
+func (s *PlatformService) ChangePlan(ctx context.Context, ownerID, shopID pgtype.UUID, planKey string) (db.Subscription, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Subscription{}, err
+	}
+	plan, err := s.store.GetPlan(ctx, planKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.Subscription{}, ErrUnknownPlan
+	}
+	if err != nil {
+		return db.Subscription{}, fmt.Errorf("failed to get plan: %w", err)
+	}
+
+	// 1. First subscription: the period starts now
+	sub, err := s.store.GetLatestSubscriptionByShop(ctx, shop.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.startSubscription(ctx, shop, plan)
+	}
+	if err != nil {
+		return db.Subscription{}, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if sub.PlanName == plan.Key {
+		return db.Subscription{}, ErrSamePlan
+	}
+
+	// An unpaid upgrade must not be turned into credit by downgrading again
+	open, err := s.store.CountOpenInvoices(ctx, db.CountOpenInvoicesParams{SubscriptionID: sub.ID, Kind: InvoiceProration})
+	if err != nil {
+		return db.Subscription{}, fmt.Errorf("failed to check open invoices: %w", err)
+	}
+	if open > 0 {
+		return db.Subscription{}, ErrInvoiceOutstanding
+	}
+	// Nor may an overdue renewal be written off by switching plans
+	now := time.Now()
+	overdue, err := s.store.CountOverdueInvoicesByShop(ctx, db.CountOverdueInvoicesByShopParams{
+		ShopID: shop.ID,
+		DueAt:  pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return db.Subscription{}, fmt.Errorf("failed to count overdue invoices: %w", err)
+	}
+	if overdue > 0 {
+		return db.Subscription{}, ErrInvoiceOverdue
+	}
+
+	// 2. Prorate the rest of the period
+	var oldPrice int64
+	if old, err := s.store.GetPlan(ctx, sub.PlanName); err == nil {
+		oldPrice = toCents(old.Price)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return db.Subscription{}, fmt.Errorf("failed to get plan: %w", err)
+	}
+	periodEnd := sub.CurrentPeriodEnd.Time
+	due := prorate(oldPrice, toCents(plan.Price), periodEnd.AddDate(0, -1, 0), periodEnd, now)
+
+	credit := toCents(sub.Credit)
+	if due < 0 {
+		credit -= due
+	}
+
+	// 3. Switch. A renewal not yet due was priced for the old plan; the
+	// billing cycle issues a new one.
+	updated, err := s.store.ChangeSubscriptionPlan(ctx, db.ChangeSubscriptionPlanParams{
+		ID:       sub.ID,
+		PlanName: plan.Key,
+		Credit:   fromCents(credit),
+	})
+	if err != nil {
+		return db.Subscription{}, fmt.Errorf("failed to change plan: %w", err)
+	}
+	if err := s.store.VoidUpcomingInvoices(ctx, db.VoidUpcomingInvoicesParams{
+		SubscriptionID: sub.ID,
+		Kind:           InvoiceRenewal,
+		DueAt:          pgtype.Timestamptz{Time: now, Valid: true},
+	}); err != nil {
+		log.Error().Err(err).Str("tenant_id", shop.TenantID).Msg("failed to void renewal invoice after plan change")
+	}
+	s.tenants.InvalidateShop(ctx, shop)
+
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorID:    ownerID,
+		ActorType:  auditservice.ActorOwner,
+		Action:     "subscription.plan_changed",
+		EntityType: "shop",
+		EntityID:   shop.ID.String(),
+		Metadata: map[string]any{
+			"from":     sub.PlanName,
+			"to":       plan.Key,
+			"prorated": formatCents(due),
+		},
+	})
+
+	// 4. Bill the upgrade
+	if due > 0 {
+		if _, err := s.issueInvoice(ctx, shop, updated, plan.Key, InvoiceProration, due, 0, now, periodEnd, now); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+func (s *PlatformService) startSubscription(ctx context.Context, shop db.Shop, plan db.Plan) (db.Subscription, error) {
+	status := SubscriptionActive
+	sub, err := s.store.CreateSubscription(ctx, db.CreateSubscriptionParams{
+		ShopID:           shop.ID,
+		PlanName:         plan.Key,
+		Status:           &status,
+		CurrentPeriodEnd: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return db.Subscription{}, fmt.Errorf("failed to create subscription: %w", err)
+	}
+	s.tenants.InvalidateShop(ctx, shop)
+
+	if err := s.renew(ctx, sub); err != nil {
+		return sub, err
+	}
+	return s.store.GetSubscription(ctx, sub.ID)
+}
+
+// BillingCycleResult counts what one RunBillingCycle did
+type BillingCycleResult struct {
+	Issued    int
+	Reminded  int
+	Suspended int
+}
+
+// RunBillingCycle issues renewal invoices ahead of current_period_end, then
+// chases unpaid invoices: reminders during the grace period, suspension once
+// it is over. Safe to run on every node: only one holds the billing lock.
+func (s *PlatformService) RunBillingCycle(ctx context.Context) (BillingCycleResult, error) {
+	var result BillingCycleResult
+
+	unlock, locked, err := s.tryLock(ctx, "billing:cycle")
+	if err != nil || !locked {
+		return result, err
+	}
+	defer unlock()
+
+	now := time.Now()
+
+	// 1. Renewals
+	due, err := s.store.ListSubscriptionsDueForRenewal(ctx, pgtype.Timestamptz{Time: now.Add(s.cfg.BillingInvoiceLead), Valid: true})
+	if err != nil {
+		return result, fmt.Errorf("failed to list subscriptions due for renewal: %w", err)
+	}
+	for _, sub := range due {
+		if err := s.renew(ctx, sub); err != nil {
+			log.Error().Err(err).Str("subscription_id", sub.ID.String()).Msg("failed to renew subscription")
+			continue
+		}
+		result.Issued++
+	}
+
+	// 2. Dunning
+	overdue, err := s.store.ListOverdueInvoices(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil {
+		return result, fmt.Errorf("failed to list overdue invoices: %w", err)
+	}
+	for _, invoice := range overdue {
+		suspended, reminded, err := s.dun(ctx, invoice, now)
+		if err != nil {
+			log.Error().Err(err).Str("invoice_id", invoice.ID.String()).Msg("failed to process overdue invoice")
+			continue
+		}
+		if suspended {
+			result.Suspended++
+		}
+		if reminded {
+			result.Reminded++
+		}
+	}
+	return result, nil
+}
+
+// renew bills the period after sub.current_period_end. Free periods (price
+// covered by credit) are extended right away.
+func (s *PlatformService) renew(ctx context.Context, sub db.Subscription) error {
+	plan, err := s.store.GetPlan(ctx, sub.PlanName)
+	if err != nil {
+		return fmt.Errorf("failed to get plan %s: %w", sub.PlanName, err)
+	}
+	shop, err := s.store.GetShopByID(ctx, sub.ShopID)
+	if err != nil {
+		return fmt.Errorf("failed to get shop: %w", err)
+	}
+
+	price := toCents(plan.Price)
+	applied := min(price, toCents(sub.Credit))
+	start := sub.CurrentPeriodEnd.Time
+	end := start.AddDate(0, 1, 0)
+
+	if price-applied == 0 {
+		if applied > 0 {
+			if _, err := s.store.SetSubscriptionCredit(ctx, db.SetSubscriptionCreditParams{ID: sub.ID, Credit: fromCents(toCents(sub.Credit) - applied)}); err != nil {
+				return fmt.Errorf("failed to apply credit: %w", err)
+			}
+		}
+		_, err := s.store.ExtendSubscription(ctx, db.ExtendSubscriptionParams{ID: sub.ID, CurrentPeriodEnd: pgtype.Timestamptz{Time: end, Valid: true}})
+		if err != nil {
+			return fmt.Errorf("failed to extend subscription: %w", err)
+		}
+		return nil
+	}
+
+	_, err = s.issueInvoice(ctx, shop, sub, plan.Key, InvoiceRenewal, price-applied, applied, start, end, start)
+	return err
+}
+
+// issueInvoice records the invoice and sends the owner a payment link.
+// A failed payment link is not fatal: PaymentLink retries when the owner pays.
+func (s *PlatformService) issueInvoice(ctx context.Context, shop db.Shop, sub db.Subscription, planKey, kind string, amount, credit int64, start, end, dueAt time.Time) (db.Invoice, error) {
+	invoice, err := s.store.CreateInvoice(ctx, db.CreateInvoiceParams{
+		ShopID:         shop.ID,
+		SubscriptionID: sub.ID,
+		PlanName:       planKey,
+		Kind:           kind,
+		Amount:         fromCents(amount),
+		CreditApplied:  fromCents(credit),
+		PeriodStart:    pgtype.Timestamptz{Time: start, Valid: true},
+		PeriodEnd:      pgtype.Timestamptz{Time: end, Valid: true},
+		Status:         InvoiceOpen,
+		DueAt:          pgtype.Timestamptz{Time: dueAt, Valid: true},
+	})
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	if withLink, err := s.createPaymentLink(ctx, shop, invoice); err != nil {
+		log.Error().Err(err).Str("invoice_id", invoice.ID.String()).Msg("failed to create payment link")
+	} else {
+		invoice = withLink
+	}
+
+	if email, err := s.ownerEmail(ctx, shop); err != nil {
+		log.Error().Err(err).Str("tenant_id", shop.TenantID).Msg("failed to notify owner of invoice")
+	} else {
+		s.notifier.InvoiceIssued(ctx, email, shop, invoice)
+	}
+	return invoice, nil
+}
+
+// dun marks the subscription past due, then either reminds the owner or,
+// once the grace period is over, suspends the shop
+func (s *PlatformService) dun(ctx context.Context, invoice db.Invoice, now time.Time) (suspended, reminded bool, err error) {
+	if _, err := s.store.MarkSubscriptionPastDue(ctx, db.MarkSubscriptionPastDueParams{ID: invoice.SubscriptionID, PastDueSince: invoice.DueAt}); err != nil {
+		return false, false, fmt.Errorf("failed to mark subscription past due: %w", err)
+	}
+	shop, err := s.store.GetShopByID(ctx, invoice.ShopID)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get shop: %w", err)
+	}
+
+	// 1. Grace period over. A platform suspension is left alone; an owner
+	// pause is taken over so it cannot be resumed without paying.
+	if now.After(invoice.DueAt.Time.Add(s.cfg.BillingGracePeriod)) {
+		if shop.SuspendedAt.Valid && (shop.SuspendedBy == nil || *shop.SuspendedBy != SuspendedByOwner) {
+			return false, false, nil
+		}
+		if _, err := s.SuspendShop(ctx, shop.ID, SuspendedByBilling, "Unpaid invoice"); err != nil {
+			return false, false, err
+		}
+		s.audit.Record(ctx, auditservice.Entry{
+			ActorType:  auditservice.ActorSystem,
+			Action:     "shop.suspended",
+			EntityType: "shop",
+			EntityID:   shop.ID.String(),
+			Metadata: map[string]any{
+				"tenant_id":  shop.TenantID,
+				"by":         SuspendedByBilling,
+				"invoice_id": invoice.ID.String(),
+			},
+		})
+		return true, false, nil
+	}
+
+	// 2. Reminder
+	if invoice.LastReminderAt.Valid && now.Sub(invoice.LastReminderAt.Time) < s.cfg.BillingReminderInterval {
+		return false, false, nil
+	}
+	email, err := s.ownerEmail(ctx, shop)
+	if err != nil {
+		return false, false, err
+	}
+	s.notifier.PaymentOverdue(ctx, email, shop, invoice)
+	if _, err := s.store.RecordInvoiceReminder(ctx, invoice.ID); err != nil {
+		return false, true, fmt.Errorf("failed to record reminder: %w", err)
+	}
+	return false, true, nil
+}
+
+// PaymentLink returns the gateway URL for an open invoice of the owner's shop
+func (s *PlatformService) PaymentLink(ctx context.Context, ownerID, shopID, invoiceID pgtype.UUID) (string, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return "", err
+	}
+	invoice, err := s.store.GetInvoice(ctx, invoiceID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && invoice.ShopID != shop.ID) {
+		return "", ErrInvoiceNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if invoice.Status != InvoiceOpen {
+		return "", ErrInvoiceNotOpen
+	}
+	if invoice.PaymentUrl != nil {
+		return *invoice.PaymentUrl, nil
+	}
+
+	invoice, err = s.createPaymentLink(ctx, shop, invoice)
+	if err != nil {
+		return "", err
+	}
+	return *invoice.PaymentUrl, nil
+}
+
+func (s *PlatformService) createPaymentLink(ctx context.Context, shop db.Shop, invoice db.Invoice) (db.Invoice, error) {
+	email, err := s.ownerEmail(ctx, shop)
+	if err != nil {
+		return invoice, err
+	}
+
+	// The gateway charges orders; the invoice ID comes back as the order ID
+	url, err := s.gateway.InitPayment(&tenantdb.Order{ID: invoice.ID, TotalAmount: invoice.Amount}, email)
+	if err != nil {
+		return invoice, fmt.Errorf("failed to create payment link: %w", err)
+	}
+	return s.store.SetInvoicePaymentURL(ctx, db.SetInvoicePaymentURLParams{ID: invoice.ID, PaymentUrl: &url})
+}
+
+// ConfirmPayment settles an invoice once the gateway reports paymentRef
+// completed for it. Renewals extend the subscription period, and a shop
+// suspended for non-payment comes back online when nothing is overdue.
+// Confirming an already paid invoice is a no-op, so the redirect and the
+// webhook can both call it.
+func (s *PlatformService) ConfirmPayment(ctx context.Context, invoiceID pgtype.UUID, paymentRef string) (db.Invoice, error) {
+	invoice, err := s.store.GetInvoice(ctx, invoiceID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.Invoice{}, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if invoice.Status == InvoicePaid {
+		return invoice, nil
+	}
+	if invoice.Status != InvoiceOpen {
+		return db.Invoice{}, ErrInvoiceNotOpen
+	}
+
+	// 1. Verify with the gateway, never trust the caller
+	info, err := s.gateway.VerifyPayment(paymentRef)
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("failed to verify payment: %w", err)
+	}
+	if err := matchPayment(invoice, info); err != nil {
+		return db.Invoice{}, err
+	}
+
+	// 2. Settle
+	paid, err := s.store.MarkInvoicePaid(ctx, db.MarkInvoicePaidParams{ID: invoice.ID, PaymentRef: &paymentRef})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Settled concurrently
+		return s.store.GetInvoice(ctx, invoice.ID)
+	}
+	if isUniqueViolation(err) {
+		return db.Invoice{}, ErrPaymentMismatch
+	}
+	if err != nil {
+		return db.Invoice{}, fmt.Errorf("failed to mark invoice paid: %w", err)
+	}
+
+	// 3. Extend the period
+	sub, err := s.store.GetSubscription(ctx, paid.SubscriptionID)
+	if err != nil {
+		return paid, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if paid.Kind == InvoiceRenewal {
+		if applied := toCents(paid.CreditApplied); applied > 0 {
+			credit := max(toCents(sub.Credit)-applied, 0)
+			if _, err := s.store.SetSubscriptionCredit(ctx, db.SetSubscriptionCreditParams{ID: sub.ID, Credit: fromCents(credit)}); err != nil {
+				return paid, fmt.Errorf("failed to apply credit: %w", err)
+			}
+		}
+		end := paid.PeriodEnd
+		if sub.CurrentPeriodEnd.Time.After(end.Time) {
+			end = sub.CurrentPeriodEnd
+		}
+		if _, err := s.store.ExtendSubscription(ctx, db.ExtendSubscriptionParams{ID: sub.ID, CurrentPeriodEnd: end}); err != nil {
+			return paid, fmt.Errorf("failed to extend subscription: %w", err)
+		}
+	}
+
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorType:  auditservice.ActorSystem,
+		Action:     "invoice.paid",
+		EntityType: "invoice",
+		EntityID:   paid.ID.String(),
+		Metadata: map[string]any{
+			"shop_id":        paid.ShopID.String(),
+			"amount":         formatCents(toCents(paid.Amount)),
+			"payment_ref":    paymentRef,
+			"transaction_id": info.TransactionID,
+		},
+	})
+
+	// 4. Back online
+	if err := s.liftBillingSuspension(ctx, paid.ShopID); err != nil {
+		return paid, err
+	}
+	return paid, nil
+}
+
+func (s *PlatformService) liftBillingSuspension(ctx context.Context, shopID pgtype.UUID) error {
+	shop, err := s.store.GetShopByID(ctx, shopID)
+	if err != nil {
+		return fmt.Errorf("failed to get shop: %w", err)
+	}
+	if !shop.SuspendedAt.Valid || shop.SuspendedBy == nil || *shop.SuspendedBy != SuspendedByBilling {
+		s.tenants.InvalidateShop(ctx, shop)
+		return nil
+	}
+
+	overdue, err := s.store.CountOverdueInvoicesByShop(ctx, db.CountOverdueInvoicesByShopParams{
+		ShopID: shop.ID,
+		DueAt:  pgtype.Timestamptz{Time: time.Now().Add(-s.cfg.BillingGracePeriod), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count overdue invoices: %w", err)
+	}
+	if overdue > 0 {
+		return nil
+	}
+	_, err = s.LiftSuspension(ctx, shop.ID)
+	return err
+}
+
+// matchPayment checks the gateway's view of a payment against the invoice.
+// A payment that doesn't name the invoice settles nothing, whatever its amount.
+func matchPayment(invoice db.Invoice, info *payment.PaymentInfo) error {
+	if info.Status != "COMPLETED" {
+		return ErrPaymentNotCompleted
+	}
+	if info.OrderID != fmt.Sprintf("%x", invoice.ID.Bytes) {
+		return ErrPaymentMismatch
+	}
+	if int64(math.Round(info.Amount*100)) < toCents(invoice.Amount) {
+		return ErrPaymentMismatch
+	}
+	return nil
+}
+
+// RunBillingJob runs RunBillingCycle every interval until ctx is cancelled
+func (s *PlatformService) RunBillingJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if result, err := s.RunBillingCycle(ctx); err != nil {
+			log.Error().Err(err).Msg("billing cycle failed")
+		} else if result != (BillingCycleResult{}) {
+			log.Info().Int("issued", result.Issued).Int("reminded", result.Reminded).Int("suspended", result.Suspended).Msg("billing cycle")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PlatformService) ownerEmail(ctx context.Context, shop db.Shop) (string, error) {
+	owner, err := s.store.GetUserById(ctx, shop.OwnerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get shop owner: %w", err)
+	}
+	return owner.Email, nil
+}
+
+// prorate returns what switching from oldPrice to newPrice costs for the rest
+// of the period [start, end) at now. Negative means the owner is owed credit.
+func prorate(oldPrice, newPrice int64, start, end, now time.Time) int64 {
+	if !now.Before(end) || !start.Before(end) {
+		return 0
+	}
+	total := int64(end.Sub(start) / time.Second)
+	remaining := int64(end.Sub(now) / time.Second)
+	if remaining > total {
+		remaining = total
+	}
+	diff := (newPrice - oldPrice) * remaining
+	// Round half away from zero
+	if diff < 0 {
+		return -((-diff + total/2) / total)
+	}
+	return (diff + total/2) / total
+}
+
+// Amounts are NUMERIC(12, 2); billing does its arithmetic in cents
+
+func toCents(n pgtype.Numeric) int64 {
+	f, err := n.Float64Value()
+	if err != nil || !f.Valid {
+		return 0
+	}
+	return int64(math.Round(f.Float64 * 100))
+}
+
+func fromCents(cents int64) pgtype.Numeric {
+	return pgtype.Numeric{Int: big.NewInt(cents), Exp: -2, Valid: true}
+}
+
+func formatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
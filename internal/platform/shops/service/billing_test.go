@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/infra/mailer"
+	"bizbundl/internal/modules/payment"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProrate(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0) // 31 days
+
+	// Upgrade half way through pays half the difference
+	mid := start.Add(end.Sub(start) / 2)
+	assert.Equal(t, int64(1000), prorate(99000, 101000, start, end, mid))
+
+	// Downgrade half way through is owed half the difference
+	assert.Equal(t, int64(-1000), prorate(101000, 99000, start, end, mid))
+
+	// Full period before it starts, nothing once it has ended
+	assert.Equal(t, int64(200000), prorate(99000, 299000, start, end, start.Add(-time.Hour)))
+	assert.Equal(t, int64(0), prorate(99000, 299000, start, end, end))
+	assert.Equal(t, int64(0), prorate(99000, 299000, start, end, end.Add(time.Hour)))
+
+	// One day left of 31 on a 2000.00 upgrade: 64.516... rounds to 64.52
+	assert.Equal(t, int64(6452), prorate(99000, 299000, start, end, end.Add(-24*time.Hour)))
+}
+
+func TestCents(t *testing.T) {
+	assert.Equal(t, int64(99000), toCents(fromCents(99000)))
+	assert.Equal(t, int64(0), toCents(pgtype.Numeric{}))
+	assert.Equal(t, "990.00", formatCents(99000))
+	assert.Equal(t, "-0.05", formatCents(-5))
+}
+
+func TestMatchPayment(t *testing.T) {
+	var id pgtype.UUID
+	id.Scan("550e8400-e29b-41d4-a716-446655440000")
+	invoice := db.Invoice{ID: id, Amount: fromCents(99000)}
+
+	assert.NoError(t, matchPayment(invoice, &payment.PaymentInfo{Status: "COMPLETED", Amount: 990, OrderID: "550e8400e29b41d4a716446655440000"}))
+	assert.ErrorIs(t, matchPayment(invoice, &payment.PaymentInfo{Status: "PENDING", Amount: 990}), ErrPaymentNotCompleted)
+	assert.ErrorIs(t, matchPayment(invoice, &payment.PaymentInfo{Status: "COMPLETED", Amount: 989.99, OrderID: "550e8400e29b41d4a716446655440000"}), ErrPaymentMismatch)
+	assert.ErrorIs(t, matchPayment(invoice, &payment.PaymentInfo{Status: "COMPLETED", Amount: 990, OrderID: "deadbeef"}), ErrPaymentMismatch)
+	assert.ErrorIs(t, matchPayment(invoice, &payment.PaymentInfo{Status: "COMPLETED", Amount: 990}), ErrPaymentMismatch, "no invoice named")
+}
+
+type outbox []mailer.Message
+
+func (o *outbox) Send(_ context.Context, msg mailer.Message) error {
+	*o = append(*o, msg)
+	return nil
+}
+
+func TestMailNotifier(t *testing.T) {
+	var sent outbox
+	notifier := NewMailNotifier(&sent, "https://bizbundl.test")
+	var shopID pgtype.UUID
+	shopID.Scan("550e8400-e29b-41d4-a716-446655440000")
+	shop := db.Shop{ID: shopID, Name: "Acme"}
+	due := pgtype.Timestamptz{Time: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	link := "https://pay.example/inv"
+
+	notifier.InvoiceIssued(context.Background(), "owner@example.com", shop, db.Invoice{PlanName: "Pro", Amount: fromCents(99000), DueAt: due, PaymentUrl: &link})
+	notifier.PaymentOverdue(context.Background(), "owner@example.com", shop, db.Invoice{Amount: fromCents(99000), DueAt: due})
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, "owner@example.com", sent[0].To)
+	assert.Contains(t, sent[0].Text, "990.00")
+	assert.Contains(t, sent[0].Text, "March 1, 2026")
+	assert.Contains(t, sent[0].Text, link)
+	assert.Contains(t, sent[1].Text, "https://bizbundl.test/dashboard/shops/550e8400-e29b-41d4-a716-446655440000/billing", "no payment link yet")
+}
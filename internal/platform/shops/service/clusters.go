@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	db "bizbundl/internal/db/sqlc/platform"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrNoClusterAvailable = errors.New("no database cluster is accepting new shops")
+
+// placeShop picks the cluster a new shop's schema is created on: the least
+// loaded cluster that accepts new shops and is configured on this node.
+func (s *PlatformService) placeShop(ctx context.Context) (string, error) {
+	names, err := s.store.ListClustersForPlacement(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list clusters: %w", err)
+	}
+	for _, name := range names {
+		if s.clusters.Has(name) {
+			return name, nil
+		}
+	}
+	return "", ErrNoClusterAvailable
+}
+
+// tenantPool returns the pool of the cluster holding the shop's schema
+func (s *PlatformService) tenantPool(ctx context.Context, shop db.Shop) (*pgxpool.Pool, error) {
+	return s.clusters.Pool(ctx, shop.DbCluster)
+}
+
+// tenantURL returns the migrate URL of the shop's schema on its cluster
+func (s *PlatformService) tenantURL(shop db.Shop) (string, error) {
+	return s.clusters.TenantURL(shop.DbCluster, shop.TenantID)
+}
+
+// ClusterStatus is a cluster from the directory with its load
+type ClusterStatus struct {
+	Name            string `json:"name"`
+	AcceptsNewShops bool   `json:"accepts_new_shops"`
+	MaxShops        *int32 `json:"max_shops"`
+	Shops           int64  `json:"shops"`
+	// Configured is false when DB_CLUSTERS on this node lacks the cluster
+	Configured bool `json:"configured"`
+}
+
+// Clusters lists the database clusters with their shop counts
+func (s *PlatformService) Clusters(ctx context.Context) ([]ClusterStatus, error) {
+	rows, err := s.store.ListDBClusters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+	clusters := make([]ClusterStatus, 0, len(rows))
+	for _, row := range rows {
+		clusters = append(clusters, ClusterStatus{
+			Name:            row.Name,
+			AcceptsNewShops: row.AcceptsNewShops,
+			MaxShops:        row.MaxShops,
+			Shops:           row.ShopCount,
+			Configured:      s.clusters.Has(row.Name),
+		})
+	}
+	return clusters, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/entitlements"
+)
+
+// mediaRef matches a reference to an uploaded file, e.g. "/uploads/products/a.jpg"
+var mediaRef = regexp.MustCompile(`(/?)uploads/([A-Za-z0-9._\-/]+)`)
+
+// mediaCopier gives a cloned shop its own copy of every uploaded file the
+// template references, so deleting or replacing one never touches the other.
+// Copies go to <root>/<tenant>/..., references are rewritten to match.
+// They count towards the new shop's storage limit.
+type mediaCopier struct {
+	root   string // upload dir, served at /uploads
+	from   string // template tenant ID
+	to     string // new tenant ID
+	plan   db.Plan
+	copied map[string]string
+	bytes  int64 // copied so far
+	err    error
+}
+
+func newMediaCopier(root, from, to string, plan db.Plan) *mediaCopier {
+	return &mediaCopier{root: root, from: from, to: to, plan: plan, copied: map[string]string{}}
+}
+
+// rewrite copies the files referenced in value and returns it pointing at
+// the copies. References to files that do not exist are left alone.
+func (m *mediaCopier) rewrite(value string) string {
+	if !strings.Contains(value, "uploads/") {
+		return value
+	}
+	return mediaRef.ReplaceAllStringFunc(value, func(ref string) string {
+		match := mediaRef.FindStringSubmatch(ref)
+		slash, rel := match[1], match[2]
+
+		// 1. Only plain paths inside the upload dir
+		clean := filepath.Clean(rel)
+		if clean != rel || strings.HasPrefix(clean, "..") {
+			return ref
+		}
+		if copied, ok := m.copied[clean]; ok {
+			return slash + "uploads/" + copied
+		}
+
+		// 2. Within the plan's storage
+		src := filepath.Join(m.root, clean)
+		info, err := os.Stat(src)
+		if err != nil {
+			if !os.IsNotExist(err) && m.err == nil {
+				m.err = fmt.Errorf("failed to copy %s: %w", clean, err)
+			}
+			return ref
+		}
+		if limit := entitlements.Max(m.plan, entitlements.Storage); limit != nil && m.bytes+info.Size() > *limit {
+			if m.err == nil {
+				m.err = &entitlements.UpgradeError{Plan: m.plan.Name, Limit: entitlements.Storage, Max: *limit, Used: m.bytes}
+			}
+			return ref
+		}
+
+		// 3. Copy (a template's own files may already live under its tenant dir)
+		target := filepath.ToSlash(filepath.Join(m.to, strings.TrimPrefix(clean, m.from+"/")))
+		if err := copyFile(src, filepath.Join(m.root, target)); err != nil {
+			if !os.IsNotExist(err) && m.err == nil {
+				m.err = fmt.Errorf("failed to copy %s: %w", clean, err)
+			}
+			return ref
+		}
+		m.copied[clean] = target
+		m.bytes += info.Size()
+		return slash + "uploads/" + target
+	})
+}
+
+// cleanup removes the copies after a failed clone. The new tenant's dir
+// only ever holds copies, and a failed copy may have created it.
+func (m *mediaCopier) cleanup() {
+	os.RemoveAll(filepath.Join(m.root, m.to))
+}
+
+// copyFile writes src to a temp file next to dst and renames it over dst,
+// so a failed copy never leaves a partial file behind
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.CreateTemp(filepath.Dir(dst), ".copy-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(out.Name())
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/entitlements"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMediaCopier(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "products", "broken.jpg"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "products", "a.jpg"), []byte("a"), 0o644))
+
+	// A left-over copy from an earlier attempt is replaced
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "shop", "products"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "shop", "products", "a.jpg"), []byte("stale"), 0o644))
+
+	m := newMediaCopier(root, "template", "shop", db.Plan{})
+	assert.Equal(t, "/uploads/shop/products/a.jpg", m.rewrite("/uploads/products/a.jpg"))
+	require.NoError(t, m.err)
+	data, err := os.ReadFile(filepath.Join(root, "shop", "products", "a.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+
+	// A failed copy leaves nothing behind
+	assert.Equal(t, "/uploads/products/broken.jpg", m.rewrite("/uploads/products/broken.jpg"))
+	assert.Error(t, m.err)
+	entries, err := os.ReadDir(filepath.Join(root, "shop", "products"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	m.cleanup()
+	_, err = os.Stat(filepath.Join(root, "shop"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMediaCopierStorageLimit(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "products"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "products", "a.jpg"), make([]byte, 600), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "products", "b.jpg"), make([]byte, 600), 0o644))
+
+	limit := int64(1000)
+	m := newMediaCopier(root, "template", "shop", db.Plan{Name: "Starter", MaxStorageBytes: &limit})
+	assert.Equal(t, "/uploads/shop/products/a.jpg", m.rewrite("/uploads/products/a.jpg"))
+	require.NoError(t, m.err)
+
+	// The copy which would go over the plan's storage is refused
+	assert.Equal(t, "/uploads/products/b.jpg", m.rewrite("/uploads/products/b.jpg"))
+	assert.ErrorIs(t, m.err, entitlements.ErrUpgradeRequired)
+	_, err := os.Stat(filepath.Join(root, "shop", "products", "b.jpg"))
+	assert.True(t, os.IsNotExist(err))
+}
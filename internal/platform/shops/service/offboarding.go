@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"bizbundl/internal/archive"
+	db "bizbundl/internal/db/sqlc/platform"
+	auditservice "bizbundl/internal/platform/audit/service"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrShopDeleted         = errors.New("shop is already scheduled for deletion")
+	ErrShopNotDeleted      = errors.New("shop is not scheduled for deletion")
+	ErrRestoreWindowClosed = errors.New("the restore window for this shop has closed")
+	ErrExportNotReady      = errors.New("the data export is not ready yet")
+)
+
+// DeleteShop offboards a shop: it is marked deleted and stops routing
+// immediately, ExportDeletedShops writes its export in the background, and
+// the schema is dropped by PurgeDeletedShops once the retention window has
+// passed. Until then the owner can RestoreShop.
+func (s *PlatformService) DeleteShop(ctx context.Context, ownerID, shopID pgtype.UUID, ip string) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
+	}
+	if shop.DeletedAt.Valid {
+		return db.Shop{}, ErrShopDeleted
+	}
+
+	// 1. Soft-delete
+	purgeAfter := pgtype.Timestamptz{Time: time.Now().Add(s.cfg.ShopRetentionPeriod), Valid: true}
+	deleted, err := s.store.MarkShopDeleted(ctx, db.MarkShopDeletedParams{
+		ID:         shop.ID,
+		DeletedBy:  ownerID,
+		PurgeAfter: purgeAfter,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.Shop{}, ErrShopDeleted
+	}
+	if err != nil {
+		return db.Shop{}, fmt.Errorf("failed to delete shop: %w", err)
+	}
+
+	// 2. Stop routing
+	s.tenants.InvalidateShop(ctx, deleted)
+
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorID:    ownerID,
+		ActorType:  auditservice.ActorOwner,
+		Action:     "shop.deleted",
+		EntityType: "shop",
+		EntityID:   deleted.ID.String(),
+		Metadata: map[string]any{
+			"tenant_id":   deleted.TenantID,
+			"subdomain":   deleted.Subdomain,
+			"purge_after": purgeAfter.Time,
+		},
+		IP: ip,
+	})
+
+	return deleted, nil
+}
+
+// RestoreShop undoes DeleteShop while the retention window is open
+func (s *PlatformService) RestoreShop(ctx context.Context, ownerID, shopID pgtype.UUID, ip string) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
+	}
+	if !shop.DeletedAt.Valid {
+		return db.Shop{}, ErrShopNotDeleted
+	}
+	if shop.PurgedAt.Valid || (shop.PurgeAfter.Valid && time.Now().After(shop.PurgeAfter.Time)) {
+		return db.Shop{}, ErrRestoreWindowClosed
+	}
+
+	restored, err := s.store.RestoreShop(ctx, shop.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.Shop{}, ErrRestoreWindowClosed
+	}
+	if err != nil {
+		return db.Shop{}, fmt.Errorf("failed to restore shop: %w", err)
+	}
+
+	s.tenants.InvalidateShop(ctx, restored)
+
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorID:    ownerID,
+		ActorType:  auditservice.ActorOwner,
+		Action:     "shop.restored",
+		EntityType: "shop",
+		EntityID:   restored.ID.String(),
+		Metadata:   map[string]any{"tenant_id": restored.TenantID},
+		IP:         ip,
+	})
+
+	// The export taken at deletion is no longer needed
+	if shop.ExportPath != nil {
+		os.Remove(*shop.ExportPath)
+	}
+
+	return restored, nil
+}
+
+// ExportShop writes an archive of the tenant schema for its owner and
+// records its path. Credentials are left out (see archive.Credentials).
+func (s *PlatformService) ExportShop(ctx context.Context, shop db.Shop) (db.Shop, error) {
+	pool, err := s.tenantPool(ctx, shop)
+	if err != nil {
+		return shop, err
+	}
+	path, manifest, err := archive.ExportRedactedToFile(ctx, pool, shop.TenantID, s.cfg.ArchiveDir, archive.Credentials)
+	if err != nil {
+		return shop, fmt.Errorf("failed to export %s: %w", shop.TenantID, err)
+	}
+
+	updated, err := s.store.SetShopExportPath(ctx, db.SetShopExportPathParams{
+		ID:         shop.ID,
+		ExportPath: &path,
+	})
+	if err != nil {
+		return shop, fmt.Errorf("failed to record export: %w", err)
+	}
+
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorType:  auditservice.ActorSystem,
+		Action:     "shop.exported",
+		EntityType: "shop",
+		EntityID:   shop.ID.String(),
+		Metadata: map[string]any{
+			"tenant_id":      shop.TenantID,
+			"path":           path,
+			"schema_version": manifest.SchemaVersion,
+			"tables":         len(manifest.Tables),
+		},
+	})
+	return updated, nil
+}
+
+// ShopExport returns the path of a deleted shop's export archive
+func (s *PlatformService) ShopExport(ctx context.Context, ownerID, shopID pgtype.UUID) (string, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return "", err
+	}
+	if shop.ExportPath == nil {
+		return "", ErrExportNotReady
+	}
+	return *shop.ExportPath, nil
+}
+
+// ExportDeletedShops writes the exports of deleted shops which have none
+// yet. Safe to run on every node: only one holds the export lock at a time.
+func (s *PlatformService) ExportDeletedShops(ctx context.Context) (int, error) {
+	unlock, locked, err := s.tryLock(ctx, "offboarding:export")
+	if err != nil || !locked {
+		return 0, err
+	}
+	defer unlock()
+
+	shops, err := s.store.ListShopsAwaitingExport(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list shops awaiting export: %w", err)
+	}
+
+	exported := 0
+	for _, shop := range shops {
+		if _, err := s.ExportShop(ctx, shop); err != nil {
+			log.Error().Err(err).Str("tenant_id", shop.TenantID).Msg("offboarding export failed, will retry")
+			continue
+		}
+		exported++
+	}
+	return exported, nil
+}
+
+// PurgeDeletedShops drops the schemas of shops whose retention window has
+// passed. The shop row is kept (marked purged) for the audit trail.
+// Safe to run on every node: only one holds the purge lock at a time.
+func (s *PlatformService) PurgeDeletedShops(ctx context.Context) (int, error) {
+	unlock, locked, err := s.tryLock(ctx, "offboarding:purge")
+	if err != nil || !locked {
+		return 0, err
+	}
+	defer unlock()
+
+	shops, err := s.store.ListShopsDueForPurge(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list shops due for purge: %w", err)
+	}
+
+	purged := 0
+	for _, shop := range shops {
+		if err := s.purgeShop(ctx, shop); err != nil {
+			log.Error().Err(err).Str("tenant_id", shop.TenantID).Msg("failed to purge shop")
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (s *PlatformService) purgeShop(ctx context.Context, shop db.Shop) error {
+	// 1. Never drop data we have not exported
+	if shop.ExportPath == nil {
+		exported, err := s.ExportShop(ctx, shop)
+		if err != nil {
+			return err
+		}
+		shop = exported
+	}
+
+	// 2. Drop schema
+	if err := s.dropSchema(ctx, shop); err != nil {
+		return fmt.Errorf("failed to drop schema: %w", err)
+	}
+	if err := s.store.DeleteTenantMigration(ctx, shop.TenantID); err != nil {
+		log.Warn().Err(err).Str("tenant_id", shop.TenantID).Msg("failed to clear tenant migration record")
+	}
+
+	// 3. Mark purged
+	if _, err := s.store.MarkShopPurged(ctx, shop.ID); err != nil {
+		return fmt.Errorf("failed to mark shop purged: %w", err)
+	}
+
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorType:  auditservice.ActorSystem,
+		Action:     "shop.purged",
+		EntityType: "shop",
+		EntityID:   shop.ID.String(),
+		Metadata: map[string]any{
+			"tenant_id":   shop.TenantID,
+			"export_path": *shop.ExportPath,
+		},
+	})
+	log.Info().Str("tenant_id", shop.TenantID).Msg("shop purged")
+	return nil
+}
+
+// RunOffboardingJob runs ExportDeletedShops and PurgeDeletedShops every
+// interval until ctx is cancelled
+func (s *PlatformService) RunOffboardingJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.ExportDeletedShops(ctx); err != nil {
+			log.Error().Err(err).Msg("shop export job failed")
+		} else if n > 0 {
+			log.Info().Int("count", n).Msg("exported deleted shops")
+		}
+		if n, err := s.PurgeDeletedShops(ctx); err != nil {
+			log.Error().Err(err).Msg("shop purge job failed")
+		} else if n > 0 {
+			log.Info().Int("count", n).Msg("purged deleted shops")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
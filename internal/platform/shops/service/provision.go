@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tenantdb "bizbundl/internal/db/sqlc"
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/util"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+// Provisioning states (shops.status)
+// pending -> schema_created -> migrated -> seeded -> active
+const (
+	StatusPending       = "pending"
+	StatusSchemaCreated = "schema_created"
+	StatusMigrated      = "migrated"
+	StatusSeeded        = "seeded"
+	StatusActive        = "active"
+	StatusFailed        = "failed"
+)
+
+var ErrProvisioningInProgress = errors.New("shop provisioning already in progress")
+
+// TenantSeeder populates a freshly migrated tenant schema with default content.
+// It is called with a context scoped to the tenant (see db.WithTenant).
+type TenantSeeder interface {
+	SeedDefaults(ctx context.Context) error
+}
+
+// Provision drives the shop through the provisioning state machine, starting
+// from its current state. Every step is idempotent so it is safe to call again
+// on a shop that stopped half-way (crash, deploy, timeout).
+func (s *PlatformService) Provision(ctx context.Context, shop db.Shop) (db.Shop, error) {
+	// Only one node may provision a given shop at a time
+	unlock, locked, err := s.tryLock(ctx, "provision:"+shop.ID.String())
+	if err != nil {
+		return shop, err
+	}
+	if !locked {
+		return shop, ErrProvisioningInProgress
+	}
+	defer unlock()
+
+	// A failed shop was compensated (schema dropped), so it starts over
+	if shop.Status == StatusFailed {
+		shop.Status = StatusPending
+	}
+
+	for shop.Status != StatusActive {
+		step := shop.Status
+		if err := s.runProvisionStep(ctx, shop); err != nil {
+			return s.failProvisioning(ctx, shop, step, err)
+		}
+		next, err := s.advance(ctx, shop)
+		if err != nil {
+			return shop, fmt.Errorf("failed to record provisioning state: %w", err)
+		}
+		log.Info().Str("tenant_id", shop.TenantID).Str("from", step).Str("to", next.Status).Msg("shop provisioning step completed")
+		shop = next
+	}
+
+	// Routing may have cached the host while the shop was inactive
+	s.tenants.InvalidateShop(ctx, shop)
+
+	return shop, nil
+}
+
+// runProvisionStep performs the work that moves a shop out of its current state
+func (s *PlatformService) runProvisionStep(ctx context.Context, shop db.Shop) error {
+	pool, err := s.tenantPool(ctx, shop)
+	if err != nil {
+		return err
+	}
+
+	switch shop.Status {
+	case StatusPending:
+		// golang-migrate does not create the schema for us
+		_, err := pool.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{shop.TenantID}.Sanitize())
+		return err
+	case StatusSchemaCreated:
+		url, err := s.tenantURL(shop)
+		if err != nil {
+			return err
+		}
+		if err := util.RunMigrations(url, s.cfg.TenantMigrationDir()); err != nil {
+			return err
+		}
+		return s.recordMigration(ctx, shop)
+	case StatusMigrated:
+		if shop.TemplateKey != nil {
+			return s.cloneTemplate(ctx, shop)
+		}
+		if s.seeder == nil {
+			return nil
+		}
+		return tenantdb.WithTenant(ctx, pool, shop.TenantID, s.seeder.SeedDefaults)
+	case StatusSeeded:
+		// Activation is recorded by advance()
+		return nil
+	default:
+		return fmt.Errorf("unknown provisioning status %q", shop.Status)
+	}
+}
+
+// advance persists the transition to the next state
+func (s *PlatformService) advance(ctx context.Context, shop db.Shop) (db.Shop, error) {
+	var next string
+	switch shop.Status {
+	case StatusPending:
+		next = StatusSchemaCreated
+	case StatusSchemaCreated:
+		next = StatusMigrated
+	case StatusMigrated:
+		next = StatusSeeded
+	case StatusSeeded:
+		return s.store.ActivateShop(ctx, shop.ID)
+	}
+	return s.store.UpdateShopStatus(ctx, db.UpdateShopStatusParams{
+		ID:     shop.ID,
+		Status: next,
+	})
+}
+
+// failProvisioning compensates a failed step and marks the shop failed.
+// Everything inside a not-yet-active schema is disposable, so the schema is
+// dropped and a retry starts from a clean slate.
+func (s *PlatformService) failProvisioning(ctx context.Context, shop db.Shop, step string, cause error) (db.Shop, error) {
+	log.Error().Err(cause).Str("tenant_id", shop.TenantID).Str("step", step).Msg("shop provisioning failed")
+
+	if step != StatusPending {
+		if err := s.dropSchema(ctx, shop); err != nil {
+			log.Error().Err(err).Str("tenant_id", shop.TenantID).Msg("failed to drop schema during compensation")
+		}
+	}
+
+	msg := fmt.Sprintf("%s: %v", step, cause)
+	failed, err := s.store.UpdateShopStatus(ctx, db.UpdateShopStatusParams{
+		ID:             shop.ID,
+		Status:         StatusFailed,
+		ProvisionError: &msg,
+	})
+	if err != nil {
+		return shop, fmt.Errorf("provisioning failed at %s (%v) and could not be recorded: %w", step, cause, err)
+	}
+	return failed, fmt.Errorf("shop provisioning failed at %s: %w", step, cause)
+}
+
+// recordMigration stores the tenant's schema version in tenant_migrations,
+// the same record the fleet migration worker maintains
+func (s *PlatformService) recordMigration(ctx context.Context, shop db.Shop) error {
+	url, err := s.tenantURL(shop)
+	if err != nil {
+		return err
+	}
+	version, dirty, err := util.MigrationVersion(url, s.cfg.TenantMigrationDir())
+	if err != nil {
+		return err
+	}
+	_, err = s.store.UpsertTenantMigration(ctx, db.UpsertTenantMigrationParams{
+		TenantID: shop.TenantID,
+		Version:  int64(version),
+		Dirty:    dirty,
+	})
+	return err
+}
+
+func (s *PlatformService) dropSchema(ctx context.Context, shop db.Shop) error {
+	pool, err := s.tenantPool(ctx, shop)
+	if err != nil {
+		return err
+	}
+	_, err = pool.Exec(ctx, "DROP SCHEMA IF EXISTS "+pgx.Identifier{shop.TenantID}.Sanitize()+" CASCADE")
+	return err
+}
+
+// tryLock takes a session-level advisory lock so only one node works on key.
+// locked is false if another node holds it.
+func (s *PlatformService) tryLock(ctx context.Context, key string) (unlock func(), locked bool, err error) {
+	conn, err := s.store.GetPool().Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", key).Scan(&locked); err != nil {
+		conn.Release()
+		return nil, false, err
+	}
+	if !locked {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	return func() {
+		// Use a fresh context: the request context may already be cancelled
+		_, _ = conn.Exec(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", key)
+		conn.Release()
+	}, true, nil
+}
+
+// RetryProvisioning restarts provisioning for a shop owned by ownerID
+func (s *PlatformService) RetryProvisioning(ctx context.Context, ownerID, shopID pgtype.UUID) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
+	}
+	if shop.Status == StatusActive {
+		return shop, nil
+	}
+	return s.Provision(ctx, shop)
+}
+
+// ResumeProvisioning picks up shops stuck in an intermediate state for longer
+// than stuckFor (e.g. the node crashed mid-provisioning) and finishes them.
+// Failed shops are left for the owner to retry.
+func (s *PlatformService) ResumeProvisioning(ctx context.Context, stuckFor time.Duration) (int, error) {
+	cutoff := pgtype.Timestamptz{Time: time.Now().Add(-stuckFor), Valid: true}
+	shops, err := s.store.ListShopsPendingProvision(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending shops: %w", err)
+	}
+
+	resumed := 0
+	for _, shop := range shops {
+		if _, err := s.Provision(ctx, shop); err != nil {
+			if !errors.Is(err, ErrProvisioningInProgress) {
+				log.Warn().Err(err).Str("tenant_id", shop.TenantID).Msg("resume provisioning failed")
+			}
+			continue
+		}
+		resumed++
+	}
+	return resumed, nil
+}
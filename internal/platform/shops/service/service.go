@@ -2,19 +2,39 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"bizbundl/internal/config"
+	"bizbundl/internal/db/cluster"
 	db "bizbundl/internal/db/sqlc/platform" // platform queries
-
-	// We need a way to run migrations.
-	"bizbundl/util"
-
+	"bizbundl/internal/entitlements"
+	"bizbundl/internal/infra/mailer"
+	"bizbundl/internal/modules/payment"
+	"bizbundl/internal/permissions"
+	auditservice "bizbundl/internal/platform/audit/service"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrShopNotFound    = errors.New("shop not found")
+	ErrInvalidShopName = errors.New("invalid shop name")
+	ErrSubdomainTaken  = errors.New("subdomain is already taken")
+	ErrInvalidDomain   = errors.New("invalid domain")
+	ErrDomainTaken     = errors.New("domain is already connected to another shop")
+)
+
+var validDomain = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
 // PlatformStore defines access to platform DB (shops, users)
 type PlatformStore interface {
 	db.Querier
@@ -33,65 +53,173 @@ func (s *SQLPlatformStore) GetPool() *pgxpool.Pool {
 
 // PlatformService logic
 type PlatformService struct {
-	store PlatformStore
-	cfg   *config.Config
+	store    PlatformStore
+	cfg      *config.Config
+	tenants  *tenancy.Resolver
+	clusters *cluster.Registry
+	plans    *entitlements.Service
+	seeder   TenantSeeder
+	audit    *auditservice.AuditService
+	gateway  payment.Gateway
+	notifier BillingNotifier
+	perms    *permissions.Resolver
+	mailer   mailer.Mailer
+	sessions *sessions.Manager
+
+	invitations *token.Signer
+	handoffs    *token.Signer
 }
 
 // NewPlatformService factory
-func NewPlatformService(pool *pgxpool.Pool, cfg *config.Config) *PlatformService {
+func NewPlatformService(pool *pgxpool.Pool, cfg *config.Config, tenants *tenancy.Resolver, clusters *cluster.Registry, plans *entitlements.Service, perms *permissions.Resolver, seeder TenantSeeder, gateway payment.Gateway, mailer mailer.Mailer, sessions *sessions.Manager) *PlatformService {
 	// Manually construct the store wrapper since it's structurally simple
 	// Note: The main 'Store' in internal/db/sqlc points to 'db' package (Tenants).
 	// We are using 'platform' package here.
 	queries := db.New(pool)
 	return &PlatformService{
-		store: &SQLPlatformStore{Queries: queries, pool: pool},
-		cfg:   cfg,
+		store:    &SQLPlatformStore{Queries: queries, pool: pool},
+		cfg:      cfg,
+		tenants:  tenants,
+		clusters: clusters,
+		plans:    plans,
+		seeder:   seeder,
+		audit:    auditservice.NewAuditService(queries),
+		gateway:  gateway,
+		notifier: logNotifier{},
+		perms:    perms,
+		mailer:   mailer,
+		sessions: sessions,
+
+		invitations: token.NewSigner(cfg.TokenSymmetricKey, token.PurposeShopInvitation),
+		handoffs:    token.NewSigner(cfg.TokenSymmetricKey, token.PurposeShopHandoff),
 	}
 }
 
-// CreateShop orchestrates creating the Shop record and provisioning the Schema
-func (s *PlatformService) CreateShop(ctx context.Context, ownerID pgtype.UUID, name string) (db.Shop, error) {
+// CreateShop registers the shop and provisions its schema, starting from the
+// template with key templateKey ("" for a blank shop).
+// If the owner already has an unfinished shop with the same subdomain (e.g. a
+// previous attempt failed), provisioning is resumed instead of failing on the
+// unique subdomain constraint.
+func (s *PlatformService) CreateShop(ctx context.Context, ownerID pgtype.UUID, name, templateKey string) (db.Shop, error) {
 	// 1. Generate Subdomain (simple slugify)
-	subdomain := strings.ToLower(strings.ReplaceAll(name, " ", "-"))
-	// TODO: Ensure uniqueness or retry with suffix
+	subdomain := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "-"))
+	if subdomain == "" {
+		return db.Shop{}, ErrInvalidShopName
+	}
 
 	// 2. Generate Tenant ID (Schema Name) -> "shop_xyz"
 	// Sanitize subdomain specific characters for SQL schema name safety
 	tenantID := "shop_" + strings.ReplaceAll(subdomain, "-", "_")
 
-	// 3. Create Record
-	isActive := true
+	// 3. Resume an earlier attempt, if any
+	existing, err := s.store.GetShopBySubdomain(ctx, subdomain)
+	if err == nil {
+		if existing.OwnerID != ownerID || existing.Status == StatusActive || existing.DeletedAt.Valid {
+			return db.Shop{}, ErrSubdomainTaken
+		}
+		return s.Provision(ctx, existing)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return db.Shop{}, fmt.Errorf("failed to check subdomain: %w", err)
+	}
+
+	// 4. Starting content
+	var template *string
+	if templateKey != "" {
+		if _, err := s.activeTemplate(ctx, templateKey); err != nil {
+			return db.Shop{}, err
+		}
+		template = &templateKey
+	}
+
+	// 5. Place it on a database cluster
+	dbCluster, err := s.placeShop(ctx)
+	if err != nil {
+		return db.Shop{}, err
+	}
+
+	// 6. Create Record (inactive until provisioning completes)
+	isActive := false
 	shop, err := s.store.CreateShop(ctx, db.CreateShopParams{
-		OwnerID:   ownerID,
-		Name:      name,
-		Subdomain: subdomain,
-		TenantID:  tenantID,
-		IsActive:  &isActive,
+		OwnerID:     ownerID,
+		Name:        name,
+		Subdomain:   subdomain,
+		TenantID:    tenantID,
+		IsActive:    &isActive,
+		Status:      StatusPending,
+		DbCluster:   dbCluster,
+		TemplateKey: template,
 	})
 	if err != nil {
+		if isUniqueViolation(err) {
+			return db.Shop{}, ErrSubdomainTaken
+		}
 		return db.Shop{}, fmt.Errorf("failed to create shop record: %w", err)
 	}
 
-	// 4. Provision Schema (Migration)
-	// We need to run "tenant" migrations on this new schema.
-	// DSN Construction:
-	baseURL := s.cfg.DBSourceURL()
-	tenantURL := fmt.Sprintf("%s&search_path=%s,public", baseURL, tenantID)
+	// 7. Provision Schema (pending -> ... -> active)
+	return s.Provision(ctx, shop)
+}
 
-	migDir := "internal/db/migration/tenant"
-	if s.cfg.InDocker == "true" {
-		migDir = "/app/internal/db/migration/tenant"
+// GetOwnedShop returns the shop only if it belongs to the given owner
+func (s *PlatformService) GetOwnedShop(ctx context.Context, ownerID, shopID pgtype.UUID) (db.Shop, error) {
+	shop, err := s.store.GetShopByID(ctx, shopID)
+	if err != nil || shop.OwnerID != ownerID {
+		return db.Shop{}, ErrShopNotFound
 	}
+	return shop, nil
+}
 
-	if err := util.RunMigrations(tenantURL, migDir); err != nil {
-		// Rollback? If record created but schema failed, we have a broken state.
-		// For MVP, we return error. User retries -> Subdomain constraint fails.
-		// Ideally: Wrap in transaction OR Delete shop on failure.
-		// Let's Log & Return error.
-		return shop, fmt.Errorf("shop created but schema provision failed: %w", err)
+// SetCustomDomain connects (or with an empty domain, disconnects) a custom domain
+func (s *PlatformService) SetCustomDomain(ctx context.Context, ownerID, shopID pgtype.UUID, domain string) (db.Shop, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.Shop{}, err
 	}
 
-	return shop, nil
+	// 1. Normalize & Validate
+	var customDomain *string
+	domain = strings.TrimPrefix(tenancy.NormalizeHost(domain), "www.")
+	if domain != "" {
+		if !validDomain.MatchString(domain) || s.isPlatformDomain(domain) {
+			return db.Shop{}, ErrInvalidDomain
+		}
+		plan, err := s.plans.ShopPlan(ctx, shop.ID)
+		if err != nil {
+			return db.Shop{}, err
+		}
+		if err := entitlements.CheckCustomDomain(plan); err != nil {
+			return db.Shop{}, err
+		}
+		customDomain = &domain
+	}
+
+	// 2. Update Registry
+	updated, err := s.store.UpdateShopCustomDomain(ctx, db.UpdateShopCustomDomainParams{
+		ID:           shop.ID,
+		CustomDomain: customDomain,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return db.Shop{}, ErrDomainTaken
+		}
+		return db.Shop{}, fmt.Errorf("failed to update custom domain: %w", err)
+	}
+
+	// 3. Invalidate both the old and the new hosts
+	s.tenants.InvalidateShop(ctx, shop)
+	s.tenants.InvalidateShop(ctx, updated)
+
+	return updated, nil
+}
+
+func (s *PlatformService) isPlatformDomain(domain string) bool {
+	root := strings.ToLower(s.cfg.PlatformDomain)
+	return domain == root || strings.HasSuffix(domain, "."+root)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
 }
 
 func (s *PlatformService) ListShops(ctx context.Context, ownerID pgtype.UUID) ([]db.Shop, error) {
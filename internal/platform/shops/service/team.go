@@ -0,0 +1,458 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"bizbundl/internal/constants"
+	tenantdb "bizbundl/internal/db/sqlc"
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/entitlements"
+	"bizbundl/internal/infra/mailer"
+	"bizbundl/internal/permissions"
+	auditservice "bizbundl/internal/platform/audit/service"
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidEmail      = errors.New("invalid email address")
+	ErrInvitationInvalid = errors.New("this invitation link is invalid or has expired")
+	ErrInvitationEmail   = errors.New("this invitation was sent to a different email address")
+	ErrAlreadyMember     = errors.New("already a member of this shop")
+	ErrMemberNotFound    = errors.New("team member not found")
+	ErrNoShopAccess      = errors.New("you do not have access to this shop")
+)
+
+// Team is what the owner's team page shows
+type Team struct {
+	Shop        db.Shop
+	Members     []db.ListShopMembersRow
+	Invitations []db.ShopInvitation
+}
+
+// Team lists the members and pending invitations of the owner's shop
+func (s *PlatformService) Team(ctx context.Context, ownerID, shopID pgtype.UUID) (Team, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return Team{}, err
+	}
+	team := Team{Shop: shop}
+	if team.Members, err = s.store.ListShopMembers(ctx, shop.ID); err != nil {
+		return Team{}, fmt.Errorf("failed to list members: %w", err)
+	}
+	if team.Invitations, err = s.store.ListPendingShopInvitations(ctx, shop.ID); err != nil {
+		return Team{}, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return team, nil
+}
+
+// InviteMember invites email to the owner's shop with role, emails them the
+// invitation link and returns it. The link is signed and expires; the
+// invitation row makes it single use and revocable.
+func (s *PlatformService) InviteMember(ctx context.Context, ownerID, shopID pgtype.UUID, email, roleName string) (db.ShopInvitation, string, error) {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return db.ShopInvitation{}, "", err
+	}
+	role, err := permissions.ParseRole(roleName)
+	if err != nil {
+		return db.ShopInvitation{}, "", err
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return db.ShopInvitation{}, "", ErrInvalidEmail
+	}
+
+	// 1. Not the owner or an existing member
+	if user, err := s.store.GetUserByEmail(ctx, email); err == nil {
+		if user.ID == shop.OwnerID {
+			return db.ShopInvitation{}, "", ErrAlreadyMember
+		}
+		if _, err := s.store.GetShopMember(ctx, db.GetShopMemberParams{ShopID: shop.ID, UserID: user.ID}); err == nil {
+			return db.ShopInvitation{}, "", ErrAlreadyMember
+		}
+	}
+
+	// 2. Plan staff limit
+	if err := s.checkStaffLimit(ctx, shop); err != nil {
+		return db.ShopInvitation{}, "", err
+	}
+
+	// 3. Invite
+	invitation, err := s.store.CreateShopInvitation(ctx, db.CreateShopInvitationParams{
+		ShopID:    shop.ID,
+		Email:     email,
+		Role:      string(role),
+		InvitedBy: ownerID,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(invitationTTL), Valid: true},
+	})
+	if err != nil {
+		return db.ShopInvitation{}, "", fmt.Errorf("failed to create invitation: %w", err)
+	}
+	link := s.cfg.PlatformURL + "/dashboard/invitations/accept?token=" + url.QueryEscape(s.invitations.Sign(invitation.ID.String(), invitationTTL))
+
+	// 4. Email it. The owner sees the link too, to pass on if mail fails
+	if err := s.mailer.Send(ctx, mailer.ShopInvitation(email, shop.Name, string(role), link, "7 days")); err != nil {
+		log.Error().Err(err).Str("tenant_id", shop.TenantID).Str("email", email).Msg("failed to send shop invitation email")
+	}
+
+	log.Info().Str("tenant_id", shop.TenantID).Str("email", email).Str("role", string(role)).Msg("shop invitation created")
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorID:    ownerID,
+		ActorType:  auditservice.ActorOwner,
+		Action:     "team.invited",
+		EntityType: "shop",
+		EntityID:   shop.ID.String(),
+		Metadata:   map[string]any{"email": email, "role": string(role)},
+	})
+	return invitation, link, nil
+}
+
+// checkStaffLimit applies the plan's staff limit to one more member.
+// Pending invitations, and members who have not opened the shop yet, count.
+func (s *PlatformService) checkStaffLimit(ctx context.Context, shop db.Shop) error {
+	plan, err := s.plans.ShopPlan(ctx, shop.ID)
+	if err != nil {
+		return err
+	}
+	limit := entitlements.Max(plan, entitlements.Staff)
+	if limit == nil {
+		return nil
+	}
+
+	members, err := s.store.CountShopMembers(ctx, shop.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count members: %w", err)
+	}
+	pending, err := s.store.ListPendingShopInvitations(ctx, shop.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list invitations: %w", err)
+	}
+	staff, err := s.tenantStaffCount(ctx, shop)
+	if err != nil {
+		return err
+	}
+
+	used := max(staff, members) + int64(len(pending))
+	if used+1 > *limit {
+		return &entitlements.UpgradeError{Plan: plan.Name, Limit: entitlements.Staff, Max: *limit, Used: used}
+	}
+	return nil
+}
+
+func (s *PlatformService) tenantStaffCount(ctx context.Context, shop db.Shop) (int64, error) {
+	pool, err := s.tenantPool(ctx, shop)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = tenantdb.WithTenant(ctx, pool, shop.TenantID, func(ctx context.Context) error {
+		n, err := tenantdb.NewStore(pool).CountStaffUsers(ctx)
+		count = n
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count staff of %s: %w", shop.TenantID, err)
+	}
+	return count, nil
+}
+
+// AcceptInvitation adds userID to the shop the invitation token is for.
+// The signed-in user's email must match the invited one.
+func (s *PlatformService) AcceptInvitation(ctx context.Context, userID pgtype.UUID, invitationToken string) (db.Shop, error) {
+	// 1. Token
+	subject, err := s.invitations.Verify(invitationToken)
+	if err != nil {
+		return db.Shop{}, ErrInvitationInvalid
+	}
+	var invitationID pgtype.UUID
+	if err := invitationID.Scan(subject); err != nil {
+		return db.Shop{}, ErrInvitationInvalid
+	}
+	invitation, err := s.store.GetShopInvitation(ctx, invitationID)
+	if err != nil {
+		return db.Shop{}, ErrInvitationInvalid
+	}
+
+	// 2. Invitee
+	user, err := s.store.GetUserById(ctx, userID)
+	if err != nil {
+		return db.Shop{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return db.Shop{}, ErrInvitationEmail
+	}
+	shop, err := s.store.GetShopByID(ctx, invitation.ShopID)
+	if err != nil || shop.DeletedAt.Valid {
+		return db.Shop{}, ErrInvitationInvalid
+	}
+	if shop.OwnerID == user.ID {
+		return db.Shop{}, ErrAlreadyMember
+	}
+
+	// 3. Join
+	if _, err := s.store.AcceptShopInvitation(ctx, db.AcceptShopInvitationParams{ID: invitation.ID, AcceptedBy: user.ID}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Shop{}, ErrInvitationInvalid
+		}
+		return db.Shop{}, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	if _, err := s.store.UpsertShopMember(ctx, db.UpsertShopMemberParams{
+		ShopID:    shop.ID,
+		UserID:    user.ID,
+		Role:      invitation.Role,
+		InvitedBy: invitation.InvitedBy,
+	}); err != nil {
+		return db.Shop{}, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorID:    user.ID,
+		ActorType:  auditservice.ActorOwner,
+		Action:     "team.joined",
+		EntityType: "shop",
+		EntityID:   shop.ID.String(),
+		Metadata:   map[string]any{"email": user.Email, "role": invitation.Role},
+	})
+	return shop, nil
+}
+
+// RevokeInvitation withdraws a pending invitation of the owner's shop
+func (s *PlatformService) RevokeInvitation(ctx context.Context, ownerID, shopID, invitationID pgtype.UUID) error {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.RevokeShopInvitation(ctx, db.RevokeShopInvitationParams{ID: invitationID, ShopID: shop.ID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvitationInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	return nil
+}
+
+// SetMemberRole changes a member's role. A member already working in the
+// shop gets the new permissions right away.
+func (s *PlatformService) SetMemberRole(ctx context.Context, ownerID, shopID, userID pgtype.UUID, roleName string) error {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return err
+	}
+	role, err := permissions.ParseRole(roleName)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.UpdateShopMemberRole(ctx, db.UpdateShopMemberRoleParams{ShopID: shop.ID, UserID: userID, Role: string(role)})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrMemberNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update member: %w", err)
+	}
+
+	if err := s.updateTenantAccess(ctx, shop, userID, tenantdb.UserRoleStaff, role.Permissions(), false); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorID:    ownerID,
+		ActorType:  auditservice.ActorOwner,
+		Action:     "team.role_changed",
+		EntityType: "shop",
+		EntityID:   shop.ID.String(),
+		Metadata:   map[string]any{"user_id": userID.String(), "role": string(role)},
+	})
+	return nil
+}
+
+// RemoveMember takes a member out of the owner's shop and strips their
+// staff access from its admin
+func (s *PlatformService) RemoveMember(ctx context.Context, ownerID, shopID, userID pgtype.UUID) error {
+	shop, err := s.GetOwnedShop(ctx, ownerID, shopID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.GetShopMember(ctx, db.GetShopMemberParams{ShopID: shop.ID, UserID: userID}); err != nil {
+		return ErrMemberNotFound
+	}
+	if err := s.store.DeleteShopMember(ctx, db.DeleteShopMemberParams{ShopID: shop.ID, UserID: userID}); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	if err := s.updateTenantAccess(ctx, shop, userID, tenantdb.UserRoleCustomer, []string{}, false); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, auditservice.Entry{
+		ActorID:    ownerID,
+		ActorType:  auditservice.ActorOwner,
+		Action:     "team.removed",
+		EntityType: "shop",
+		EntityID:   shop.ID.String(),
+		Metadata:   map[string]any{"user_id": userID.String()},
+	})
+	return nil
+}
+
+// MemberShops lists the shops userID works in as a team member
+func (s *PlatformService) MemberShops(ctx context.Context, userID pgtype.UUID) ([]db.ListMemberShopsRow, error) {
+	return s.store.ListMemberShops(ctx, userID)
+}
+
+// OpenShop returns the URL that signs userID into the shop's admin: the
+// owner as admin, members as staff with their role's permissions. It is how
+// users switch between the shops they belong to. twoFactor tells whether the
+// platform session passed a second factor, so the shop session does too.
+func (s *PlatformService) OpenShop(ctx context.Context, userID, shopID pgtype.UUID, twoFactor bool) (string, error) {
+	shop, err := s.store.GetShopByID(ctx, shopID)
+	if err != nil || shop.DeletedAt.Valid {
+		return "", ErrShopNotFound
+	}
+	if shop.Status != StatusActive {
+		return "", ErrNoShopAccess
+	}
+
+	// 1. Access
+	role, perms := tenantdb.UserRoleAdmin, permissions.All
+	if shop.OwnerID != userID {
+		member, err := s.store.GetShopMember(ctx, db.GetShopMemberParams{ShopID: shop.ID, UserID: userID})
+		if err != nil {
+			return "", ErrNoShopAccess
+		}
+		memberRole, err := permissions.ParseRole(member.Role)
+		if err != nil {
+			return "", ErrNoShopAccess
+		}
+		role, perms = tenantdb.UserRoleStaff, memberRole.Permissions()
+	}
+
+	// 2. Tenant account
+	if err := s.updateTenantAccess(ctx, shop, userID, role, perms, true); err != nil {
+		return "", err
+	}
+
+	// 3. Handoff, with an ID the shop uses it up by
+	jti, _, err := token.NewOpaque()
+	if err != nil {
+		return "", err
+	}
+	subject := jti + "|" + shop.TenantID + "|" + userID.String()
+	if twoFactor {
+		subject += constants.HandoffTwoFactorSuffix
+	}
+	handoff := s.handoffs.Sign(subject, constants.HandoffDuration)
+	return s.shopURL(shop) + "/api/v1/auth/handoff?token=" + url.QueryEscape(handoff), nil
+}
+
+// updateTenantAccess writes the platform user's role and permissions to their
+// account in the shop, found by their platform user ID. create opens the
+// account if there is none: an account registered in the shop with their
+// email is taken over, and unless that email was verified, whoever
+// registered it is signed out and loses its password, phone and social
+// logins first.
+func (s *PlatformService) updateTenantAccess(ctx context.Context, shop db.Shop, userID pgtype.UUID, role tenantdb.UserRole, perms []string, create bool) error {
+	user, err := s.store.GetUserById(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	encoded, err := json.Marshal(perms)
+	if err != nil {
+		return fmt.Errorf("failed to encode permissions: %w", err)
+	}
+	pool, err := s.tenantPool(ctx, shop)
+	if err != nil {
+		return err
+	}
+
+	var accountID pgtype.UUID
+	var takenOver bool
+	err = tenantdb.WithTenant(ctx, pool, shop.TenantID, func(ctx context.Context) error {
+		q := tenantdb.NewStore(pool)
+		account, err := q.GetUserByPlatformID(ctx, userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			if !create {
+				return nil
+			}
+			account, takenOver, err = s.openTenantAccount(ctx, q, user, role)
+		}
+		if err != nil {
+			return err
+		}
+		accountID = account.ID
+		_, err = q.SetUserAccess(ctx, tenantdb.SetUserAccessParams{ID: account.ID, Role: role, Permissions: encoded})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update access in %s: %w", shop.TenantID, err)
+	}
+	if !accountID.Valid {
+		return nil
+	}
+	s.perms.Invalidate(ctx, shop.TenantID, accountID.String())
+	if takenOver {
+		tenantCtx := context.WithValue(ctx, tenancy.ContextKey, &tenancy.Tenant{TenantID: shop.TenantID})
+		if _, err := s.sessions.RevokeAll(tenantCtx, accountID.String(), ""); err != nil {
+			return fmt.Errorf("failed to sign out %s: %w", shop.TenantID, err)
+		}
+	}
+	return nil
+}
+
+// openTenantAccount creates the platform user's account in the shop, or
+// links the one registered with their email. takenOver is whether that
+// account's email was unverified, so its sessions must end.
+func (s *PlatformService) openTenantAccount(ctx context.Context, q tenantdb.DBStore, user db.User, role tenantdb.UserRole) (tenantdb.User, bool, error) {
+	existing, err := q.GetUserByEmail(ctx, user.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		account, err := q.CreatePlatformUser(ctx, tenantdb.CreatePlatformUserParams{
+			Email:          &user.Email,
+			FirstName:      user.FirstName,
+			LastName:       user.LastName,
+			Role:           role,
+			PlatformUserID: user.ID,
+		})
+		return account, false, err
+	}
+	if err != nil {
+		return tenantdb.User{}, false, err
+	}
+
+	account, err := q.LinkPlatformUser(ctx, tenantdb.LinkPlatformUserParams{ID: existing.ID, PlatformUserID: user.ID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return tenantdb.User{}, false, ErrNoShopAccess // linked to another platform user
+	}
+	if err != nil {
+		return tenantdb.User{}, false, err
+	}
+	if existing.EmailVerifiedAt.Valid {
+		return account, false, nil
+	}
+	if err := q.DeleteUserIdentities(ctx, account.ID); err != nil {
+		return tenantdb.User{}, false, err
+	}
+	return account, true, nil
+}
+
+// shopURL is the shop's address under the platform domain, e.g.
+// http://neon-vibes.localhost:8123 for PLATFORM_URL http://localhost:8123
+func (s *PlatformService) shopURL(shop db.Shop) string {
+	u, err := url.Parse(s.cfg.PlatformURL)
+	if err != nil || u.Host == "" {
+		return "http://" + shop.Subdomain + "." + s.cfg.PlatformDomain
+	}
+	u.Host = shop.Subdomain + "." + u.Host
+	u.Path = ""
+	return strings.TrimSuffix(u.String(), "/")
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"bizbundl/internal/archive"
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/util"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrUnknownTemplate = errors.New("unknown shop template")
+
+// TemplateTables are the tables a new shop takes from its template: settings
+// and theme, categories, the sample catalog and pages. Customers, orders,
+// sessions and payment credentials stay with the template.
+var TemplateTables = []string{
+	"store_configs",
+	"categories",
+	"products",
+	"product_options",
+	"product_variants",
+	"pages",
+}
+
+// Templates lists the templates owners can start a shop from
+func (s *PlatformService) Templates(ctx context.Context) ([]db.ShopTemplate, error) {
+	return s.store.ListActiveShopTemplates(ctx)
+}
+
+// AllTemplates lists every template, including inactive ones
+func (s *PlatformService) AllTemplates(ctx context.Context) ([]db.ShopTemplate, error) {
+	return s.store.ListShopTemplates(ctx)
+}
+
+// activeTemplate returns the template with the given key if owners may use it
+func (s *PlatformService) activeTemplate(ctx context.Context, key string) (db.ShopTemplate, error) {
+	template, err := s.store.GetShopTemplate(ctx, key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ShopTemplate{}, ErrUnknownTemplate
+		}
+		return db.ShopTemplate{}, fmt.Errorf("failed to get template: %w", err)
+	}
+	if !template.IsActive {
+		return db.ShopTemplate{}, ErrUnknownTemplate
+	}
+	return template, nil
+}
+
+// SaveTemplateParams registers the shop with TenantID as template Key
+type SaveTemplateParams struct {
+	Key         string
+	Name        string
+	Description string
+	TenantID    string
+	Position    int32
+	IsActive    bool
+}
+
+// SaveTemplate registers a shop as a template (or updates one).
+// The shop must be a live, provisioned shop.
+func (s *PlatformService) SaveTemplate(ctx context.Context, params SaveTemplateParams) (db.ShopTemplate, error) {
+	shop, err := s.store.GetShopByTenantID(ctx, params.TenantID)
+	if err != nil {
+		return db.ShopTemplate{}, ErrShopNotFound
+	}
+	if shop.Status != StatusActive || shop.DeletedAt.Valid {
+		return db.ShopTemplate{}, fmt.Errorf("template shop %s is not active", shop.Subdomain)
+	}
+
+	template, err := s.store.UpsertShopTemplate(ctx, db.UpsertShopTemplateParams{
+		Key:         params.Key,
+		Name:        params.Name,
+		Description: params.Description,
+		ShopID:      shop.ID,
+		Position:    params.Position,
+		IsActive:    params.IsActive,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return db.ShopTemplate{}, fmt.Errorf("shop %s is already a template", shop.Subdomain)
+		}
+		return db.ShopTemplate{}, fmt.Errorf("failed to save template: %w", err)
+	}
+	return template, nil
+}
+
+// cloneTemplate copies the template's content into the shop's freshly
+// migrated schema. Rows get new IDs and uploaded media is copied, so the new
+// shop shares nothing with the template.
+func (s *PlatformService) cloneTemplate(ctx context.Context, shop db.Shop) error {
+	// 1. Template and its shop
+	template, err := s.store.GetShopTemplate(ctx, *shop.TemplateKey)
+	if err != nil {
+		return fmt.Errorf("failed to get template %s: %w", *shop.TemplateKey, err)
+	}
+	source, err := s.store.GetShopByID(ctx, template.ShopID)
+	if err != nil {
+		return fmt.Errorf("failed to get template shop: %w", err)
+	}
+	srcPool, err := s.tenantPool(ctx, source)
+	if err != nil {
+		return err
+	}
+	dstPool, err := s.tenantPool(ctx, shop)
+	if err != nil {
+		return err
+	}
+
+	// 2. Stream an archive of the template's content
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := archive.ExportTables(ctx, srcPool, source.TenantID, pw, TemplateTables)
+		pw.CloseWithError(err)
+	}()
+	a, err := archive.Open(pr)
+	pr.CloseWithError(io.ErrClosedPipe) // unblock the exporter if Open stopped early
+	if err != nil {
+		return fmt.Errorf("failed to export template: %w", err)
+	}
+	defer a.Close()
+
+	// 3. Load it with new IDs and copied media, within the shop's storage
+	plan, err := s.plans.ShopPlan(ctx, shop.ID)
+	if err != nil {
+		return err
+	}
+	media := newMediaCopier(util.DefaultFileUploadConfig().UploadDir, source.TenantID, shop.TenantID, plan)
+	result, err := archive.Load(ctx, dstPool, a, shop.TenantID, archive.LoadOptions{
+		RemapIDs: true,
+		Rewrite:  media.rewrite,
+	})
+	if err == nil {
+		err = media.err
+	}
+	if err != nil {
+		media.cleanup()
+		return fmt.Errorf("failed to clone template %s: %w", template.Key, err)
+	}
+
+	// 4. Secrets (API keys etc.) belong to the template's owner
+	_, err = dstPool.Exec(ctx, "DELETE FROM "+pgx.Identifier{shop.TenantID, "store_configs"}.Sanitize()+" WHERE is_encrypted")
+	if err != nil {
+		return fmt.Errorf("failed to clear template secrets: %w", err)
+	}
+
+	log.Info().Str("tenant_id", shop.TenantID).Str("template", template.Key).Int64("rows", result.Rows).
+		Int("files", len(media.copied)).Msg("shop cloned from template")
+	return nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+
+	tenantdb "bizbundl/internal/db/sqlc"
+	db "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/entitlements"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Usage reports plan usage for the live shops among shops, keyed by shop ID.
+// Shops whose usage cannot be read are left out rather than failing the page.
+func (s *PlatformService) Usage(ctx context.Context, shops []db.Shop) map[string]entitlements.Report {
+	reports := make(map[string]entitlements.Report, len(shops))
+	for _, shop := range shops {
+		if shop.Status != StatusActive || shop.DeletedAt.Valid {
+			continue
+		}
+		report, err := s.shopUsage(ctx, shop)
+		if err != nil {
+			log.Warn().Err(err).Str("tenant_id", shop.TenantID).Msg("failed to read plan usage")
+			continue
+		}
+		reports[shop.ID.String()] = report
+	}
+	return reports
+}
+
+func (s *PlatformService) shopUsage(ctx context.Context, shop db.Shop) (entitlements.Report, error) {
+	plan, err := s.plans.ShopPlan(ctx, shop.ID)
+	if err != nil {
+		return entitlements.Report{}, err
+	}
+	pool, err := s.tenantPool(ctx, shop)
+	if err != nil {
+		return entitlements.Report{}, err
+	}
+
+	report := entitlements.Report{Plan: plan}
+	err = tenantdb.WithTenant(ctx, pool, shop.TenantID, func(ctx context.Context) error {
+		usage, err := s.plans.Usage(ctx, plan, shop.TenantID)
+		report.Usage = usage
+		return err
+	})
+	return report, err
+}
@@ -1,13 +1,27 @@
 package server
 
 import (
+	"bizbundl/internal/apikeys"
 	"bizbundl/internal/config"
+	"bizbundl/internal/db/cluster"
 	db "bizbundl/internal/db/sqlc"
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/entitlements"
 	"bizbundl/internal/infra/elastic"
+	"bizbundl/internal/infra/mailer"
 	"bizbundl/internal/infra/redis"
+	"bizbundl/internal/infra/sms"
+	"bizbundl/internal/loginguard"
 	"bizbundl/internal/middleware"
+	"bizbundl/internal/otp"
+	"bizbundl/internal/permissions"
+	auditservice "bizbundl/internal/platform/audit/service"
+	"bizbundl/internal/sessions"
 	cacheStore "bizbundl/internal/store"
+	"bizbundl/internal/tenancy"
 	"bizbundl/token"
+	"bizbundl/util"
+	"context"
 	"fmt"
 	"time"
 
@@ -18,7 +32,9 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/utils"
 	redisClient "github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
 )
 
 // Server serves HTTP requests for our banking service.
@@ -29,10 +45,20 @@ type Server struct {
 	router     *fiber.App
 	redis      *redisClient.Client
 	elastic    *elasticsearch.Client
+	tenants    *tenancy.Resolver
+	clusters   *cluster.Registry
+	plans      *entitlements.Service
+	perms      *permissions.Resolver
+	sessions   *sessions.Manager
+	cookies    middleware.CookiePolicy
+	mailer     mailer.Mailer
+	otp        *otp.Service
+	logins     *loginguard.Guard
+	apiKeys    *apikeys.Service
 }
 
 func NewServer(config *config.Config, store db.DBStore) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
 	}
@@ -47,15 +73,60 @@ func NewServer(config *config.Config, store db.DBStore) (*Server, error) {
 		return nil, fmt.Errorf("failed to init elastic: %w", err)
 	}
 
+	// Host -> Tenant resolution backed by the shop registry (public.shops)
+	tenants := tenancy.NewResolver(platformdb.New(store.GetPool()), rc, config.PlatformDomain)
+	go tenants.Listen(context.Background())
+
+	// Tenant schemas may live on other Postgres clusters (public.shops.db_cluster)
+	clusters, err := cluster.Load(store.GetPool(), config.DBSourceURL(), config.DBClusters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CLUSTERS: %w", err)
+	}
+
+	// Plan limits, consulted by services before they mutate tenant data
+	plans := entitlements.NewService(platformdb.New(store.GetPool()), store, util.DefaultFileUploadConfig().UploadDir)
+
+	// Staff permissions (users.permissions), checked by middleware.RequirePermission
+	perms := permissions.NewResolver(store, rc)
+	go perms.Listen(context.Background())
+
+	// Server-side record of signed-in tokens, checked by middleware.Auth.
+	// Access tokens are short-lived; refresh tokens rotate on every use.
+	sessionManager := sessions.NewManager(sessions.NewRedisStore(rc), tokenMaker, config.AccessTokenDuration, config.RefreshTokenDuration)
+
+	// Rate limits, delays and lockouts of password logins. Failures go to the
+	// platform audit log, for shops and the platform alike.
+	logins := loginguard.NewGuard(otp.NewRedisStore(rc), auditservice.NewAuditService(platformdb.New(store.GetPool())))
+
 	app := fiber.New(fiber.Config{})
+	app.Use(middleware.RequestID())
 	app.Use(etag.New())
 	app.Use(cache.New(cache.Config{
 		Expiration:   1 * time.Minute,
 		CacheControl: true,
 		Storage:      redis.NewFiberStorage(rc),
+		// Shops share paths, so the host is part of the key, and so is the
+		// query: pages differ by it
+		KeyGenerator: func(c *fiber.Ctx) string {
+			key := c.Hostname() + c.Path()
+			if query := c.Request().URI().QueryString(); len(query) > 0 {
+				key += "?" + string(query)
+			}
+			return utils.CopyString(key)
+		},
+		// Evaluated after the handler chain, so only decides what is stored:
+		// guarded routes need middleware.NoStore ahead of their guards
+		Next: middleware.SkipCache,
 	}))
 	app.Use(recover.New())
-	app.Use(middleware.TenancyMiddleware(store))
+	app.Use(middleware.TenancyMiddleware(clusters, tenants, versionPolicy(config)))
+	app.Use(middleware.ShopGate(tokenMaker, sessionManager, config.TokenSymmetricKey, middleware.NewCookiePolicy(config.Environment), otp.NewRedisStore(rc)))
+	// Staff screens and APIs, and the platform dashboard with its handoffs
+	// and invitation links, are never shared: registered ahead of every
+	// route, so ahead of their guards too
+	app.Use("/api/v1/admin", middleware.NoStore())
+	app.Use("/admin", middleware.NoStore())
+	app.Use("/dashboard", middleware.NoStore())
 	if config.Environment != "development" {
 		app.Use(compress.New(compress.Config{
 			Level: compress.LevelBestSpeed,
@@ -101,11 +172,44 @@ func NewServer(config *config.Config, store db.DBStore) (*Server, error) {
 		router:     app,
 		redis:      rc,
 		elastic:    es,
+		tenants:    tenants,
+		clusters:   clusters,
+		plans:      plans,
+		perms:      perms,
+		sessions:   sessionManager,
+		cookies:    middleware.NewCookiePolicy(config.Environment),
+		mailer:     mailer.NewMailer(config),
+		otp:        otp.NewService(otp.NewRedisStore(rc), sms.NewSender(config), config.SMSDefaultCountryCode),
+		logins:     logins,
+		apiKeys:    apikeys.NewService(store),
 	}
 	server.setupStatics()
 	return server, nil
 }
 
+// versionPolicy builds the blue/green pinning policy for this binary.
+// The expected schema version defaults to the latest tenant migration shipped with it.
+func versionPolicy(config *config.Config) tenancy.VersionPolicy {
+	policy := tenancy.VersionPolicy{
+		AppVersion:    config.AppVersion,
+		SchemaVersion: config.TenantSchemaVersion,
+		Header:        config.VersionHeader,
+	}
+	if !policy.Enabled() {
+		return policy
+	}
+	if policy.SchemaVersion == 0 {
+		versions, err := util.MigrationVersions(config.TenantMigrationDir())
+		if err != nil || len(versions) == 0 {
+			log.Warn().Err(err).Msg("version pinning: cannot determine tenant schema version, only app version is checked")
+			return policy
+		}
+		policy.SchemaVersion = int64(versions[len(versions)-1])
+	}
+	log.Info().Str("app_version", policy.AppVersion).Int64("schema_version", policy.SchemaVersion).Msg("version pinning")
+	return policy
+}
+
 func (server *Server) Start() error {
 	return server.router.Listen(":" + "8080")
 }
@@ -115,6 +219,51 @@ func (server *Server) GetRouter() *fiber.App {
 func (server *Server) GetDB() db.DBStore {
 	return server.store
 }
+
+// GetClusters returns the pool-per-cluster registry for tenant schemas
+func (server *Server) GetClusters() *cluster.Registry {
+	return server.clusters
+}
+
+// GetEntitlements returns the plan limits service
+func (server *Server) GetEntitlements() *entitlements.Service {
+	return server.plans
+}
+
+// GetPermissions returns the staff permission resolver
+func (server *Server) GetPermissions() *permissions.Resolver {
+	return server.perms
+}
+
+// GetSessions returns the session manager that issues and revokes sign-ins
+func (server *Server) GetSessions() *sessions.Manager {
+	return server.sessions
+}
+
+// GetMailer returns the transactional email sender
+func (server *Server) GetMailer() mailer.Mailer {
+	return server.mailer
+}
+
+// GetOTP returns the service that texts and checks one-time login codes
+func (server *Server) GetOTP() *otp.Service {
+	return server.otp
+}
+
+// GetLoginGuard returns the brute-force protection of password logins
+func (server *Server) GetLoginGuard() *loginguard.Guard {
+	return server.logins
+}
+
+// GetAPIKeys returns the shops' API keys of headless clients
+func (server *Server) GetAPIKeys() *apikeys.Service {
+	return server.apiKeys
+}
+
+// GetCookies returns how session cookies are set in this environment
+func (server *Server) GetCookies() middleware.CookiePolicy {
+	return server.cookies
+}
 func (server *Server) GetTokenMaker() token.Maker {
 	return server.tokenMaker
 }
@@ -130,6 +279,23 @@ func (server *Server) GetElastic() *elasticsearch.Client {
 	return server.elastic
 }
 
+func (server *Server) GetTenantResolver() *tenancy.Resolver {
+	return server.tenants
+}
+
+// newTokenMaker signs access tokens with TOKEN_KEYS when set, and encrypts
+// them with the symmetric key otherwise
+func newTokenMaker(config *config.Config) (token.Maker, error) {
+	if config.TokenKeys == "" {
+		return token.NewPasetoMaker(config.TokenSymmetricKey)
+	}
+	keys, err := token.ParseKeys(config.TokenKeys)
+	if err != nil {
+		return nil, err
+	}
+	return token.NewPublicMaker(keys)
+}
+
 func (server *Server) setupStatics() {
 	oneYearInSeconds := 31536000
 	server.router.Static("/static", "./static", fiber.Static{
@@ -0,0 +1,409 @@
+// Package sessions keeps a server-side record of every sign-in so sessions
+// can be listed and revoked. A session is a token family: short-lived,
+// stateless PASETO access tokens carrying the session ID, and one rotating
+// refresh token stored hashed. Presenting a refresh token that has already
+// been rotated away revokes the whole family.
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// ClientKey is the Fiber local (and so c.Context() value) holding the
+// request's Client, set by middleware.Auth
+const ClientKey = "session_client"
+
+const (
+	// touchInterval limits how often LastSeenAt is written back
+	touchInterval = time.Minute
+	// rotationGrace lets the refresh token just rotated away still mint
+	// access tokens briefly, for parallel requests that raced the rotation
+	rotationGrace = 30 * time.Second
+)
+
+var (
+	ErrNotFound      = errors.New("session not found")
+	ErrRevoked       = errors.New("session has been revoked")
+	ErrRefreshReused = errors.New("refresh token reused, session revoked")
+	// ErrConflict is returned by Store.CompareAndSave when the refresh token
+	// changed since the session was read
+	ErrConflict = errors.New("session changed concurrently")
+)
+
+// Tokens is what a sign-in or refresh hands to the client. Refresh is empty
+// when only a new access token was minted (see rotationGrace).
+type Tokens struct {
+	Access           string
+	AccessExpiresAt  time.Time
+	Refresh          string
+	RefreshExpiresAt time.Time
+}
+
+// Client describes where a request comes from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// ClientFromContext returns the request's Client, empty outside requests
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(ClientKey).(Client)
+	return client
+}
+
+// Session is one signed-in device of a user in a shop (or the platform)
+type Session struct {
+	ID         string    `json:"id"`
+	TenantID   string    `json:"tenant_id"`
+	UserID     string    `json:"user_id"`
+	Role       string    `json:"role"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// RefreshHash is the SHA-256 of the current refresh token's secret;
+	// PreviousHash and RotatedAt remember the one it replaced
+	RefreshHash  string    `json:"refresh_hash"`
+	PreviousHash string    `json:"previous_hash,omitempty"`
+	RotatedAt    time.Time `json:"rotated_at,omitempty"`
+	// TwoFactor is set once the user passed a second factor in this
+	// session; AuthenticatedAt is when they last proved who they are (at
+	// sign-in, then at each StepUp)
+	TwoFactor       bool      `json:"two_factor,omitempty"`
+	AuthenticatedAt time.Time `json:"authenticated_at"`
+}
+
+// AuthenticatedWithin reports whether the user proved who they are in the
+// last maxAge, as sensitive actions require (see StepUp)
+func (s Session) AuthenticatedWithin(maxAge time.Duration) bool {
+	return time.Since(s.AuthenticatedAt) <= maxAge
+}
+
+// Device is a short description of the session's browser and OS
+func (s Session) Device() string {
+	if s.UserAgent == "" {
+		return "Unknown device"
+	}
+	browser := match(s.UserAgent, [][2]string{{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Chrome/", "Chrome"}, {"Firefox/", "Firefox"}, {"Safari/", "Safari"}}, "Browser")
+	os := match(s.UserAgent, [][2]string{{"Windows", "Windows"}, {"Android", "Android"}, {"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Mac OS X", "macOS"}, {"Linux", "Linux"}}, "")
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
+
+func match(ua string, known [][2]string, fallback string) string {
+	for _, k := range known {
+		if strings.Contains(ua, k[0]) {
+			return k[1]
+		}
+	}
+	return fallback
+}
+
+// Store persists sessions until they expire
+type Store interface {
+	Save(ctx context.Context, s Session) error
+	// CompareAndSave saves s unless the stored session's RefreshHash is no
+	// longer refreshHash (ErrConflict)
+	CompareAndSave(ctx context.Context, s Session, refreshHash string) error
+	// Get returns ErrNotFound for unknown, expired and revoked sessions
+	Get(ctx context.Context, tenantID, id string) (Session, error)
+	Delete(ctx context.Context, tenantID, userID, id string) error
+	List(ctx context.Context, tenantID, userID string) ([]Session, error)
+}
+
+// Manager issues session tokens and answers whether they are still good
+type Manager struct {
+	store           Store
+	tokenMaker      token.Maker
+	accessDuration  time.Duration
+	refreshDuration time.Duration
+}
+
+// NewManager creates a Manager. Access tokens live for accessDuration;
+// a session lasts refreshDuration past its latest refresh.
+func NewManager(store Store, tokenMaker token.Maker, accessDuration, refreshDuration time.Duration) *Manager {
+	return &Manager{store: store, tokenMaker: tokenMaker, accessDuration: accessDuration, refreshDuration: refreshDuration}
+}
+
+// Issue starts a session for the user in the tenant of ctx
+func (m *Manager) Issue(ctx context.Context, userID, role string) (Tokens, Session, error) {
+	return m.issue(ctx, userID, role, false)
+}
+
+// IssueTwoFactor starts a session for a user who passed a second factor
+func (m *Manager) IssueTwoFactor(ctx context.Context, userID, role string) (Tokens, Session, error) {
+	return m.issue(ctx, userID, role, true)
+}
+
+func (m *Manager) issue(ctx context.Context, userID, role string, twoFactor bool) (Tokens, Session, error) {
+	now := time.Now()
+	client := ClientFromContext(ctx)
+	secret, hash, err := newSecret()
+	if err != nil {
+		return Tokens{}, Session{}, err
+	}
+	session := Session{
+		ID:          uuid.NewString(),
+		TenantID:    tenantOf(ctx),
+		UserID:      userID,
+		Role:        role,
+		UserAgent:   client.UserAgent,
+		IP:          client.IP,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(m.refreshDuration),
+		RefreshHash: hash,
+
+		TwoFactor:       twoFactor,
+		AuthenticatedAt: now,
+	}
+	if err := m.store.Save(ctx, session); err != nil {
+		return Tokens{}, Session{}, fmt.Errorf("failed to save session: %w", err)
+	}
+	tokens, _, err := m.tokens(session, secret)
+	if err != nil {
+		return Tokens{}, Session{}, err
+	}
+	return tokens, session, nil
+}
+
+// Check returns ErrRevoked unless the access token's session is alive in
+// the tenant of ctx and belongs to the token's user. It records when and
+// from where the session was last used.
+func (m *Manager) Check(ctx context.Context, payload *token.Payload) error {
+	if payload.SessionID == "" {
+		return ErrRevoked
+	}
+	session, err := m.store.Get(ctx, tenantOf(ctx), payload.SessionID)
+	if errors.Is(err, ErrNotFound) || (err == nil && session.UserID != payload.UserID) {
+		return ErrRevoked
+	}
+	if err != nil {
+		return err
+	}
+
+	client := ClientFromContext(ctx)
+	if time.Since(session.LastSeenAt) > touchInterval || (client.IP != "" && client.IP != session.IP) {
+		touch(&session, client)
+		// Best effort: a failed touch (or one racing a rotation) must not
+		// sign the user out
+		_ = m.store.CompareAndSave(ctx, session, session.RefreshHash)
+	}
+	return nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new
+// refresh token. A refresh token that was already rotated away means it
+// leaked: the session is revoked and ErrRefreshReused returned.
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (Tokens, *token.Payload, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return Tokens{}, nil, ErrRevoked
+	}
+	session, err := m.store.Get(ctx, tenantOf(ctx), sessionID)
+	if errors.Is(err, ErrNotFound) {
+		return Tokens{}, nil, ErrRevoked
+	}
+	if err != nil {
+		return Tokens{}, nil, err
+	}
+	presented := hashSecret(secret)
+
+	// 1. Old token: a race with a rotation a moment ago, or reuse
+	if !sameHash(presented, session.RefreshHash) {
+		if sameHash(presented, session.PreviousHash) && time.Since(session.RotatedAt) < rotationGrace {
+			return m.accessOnly(session)
+		}
+		if err := m.store.Delete(ctx, session.TenantID, session.UserID, session.ID); err != nil {
+			return Tokens{}, nil, err
+		}
+		log.Warn().Str("tenant", session.TenantID).Str("user", session.UserID).Str("session", session.ID).Msg("sessions: refresh token reused, session revoked")
+		return Tokens{}, nil, ErrRefreshReused
+	}
+
+	// 2. Rotate
+	newSecret, newHash, err := newSecret()
+	if err != nil {
+		return Tokens{}, nil, err
+	}
+	rotated := session
+	rotated.PreviousHash = session.RefreshHash
+	rotated.RefreshHash = newHash
+	rotated.RotatedAt = time.Now()
+	rotated.ExpiresAt = time.Now().Add(m.refreshDuration)
+	touch(&rotated, ClientFromContext(ctx))
+
+	err = m.store.CompareAndSave(ctx, rotated, session.RefreshHash)
+	if errors.Is(err, ErrConflict) {
+		// Another request rotated this token first
+		return m.accessOnly(session)
+	}
+	if err != nil {
+		return Tokens{}, nil, fmt.Errorf("failed to save session: %w", err)
+	}
+	return m.tokens(rotated, newSecret)
+}
+
+// Get returns one of the user's sessions
+func (m *Manager) Get(ctx context.Context, userID, id string) (Session, error) {
+	session, err := m.store.Get(ctx, tenantOf(ctx), id)
+	if err != nil {
+		return Session{}, err
+	}
+	if session.UserID != userID {
+		return Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+// StepUp records that the user just proved who they are again (with their
+// password, or with a second factor when twoFactor), unlocking sensitive
+// actions for a while
+func (m *Manager) StepUp(ctx context.Context, userID, id string, twoFactor bool) error {
+	for {
+		session, err := m.Get(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		session.AuthenticatedAt = time.Now()
+		session.TwoFactor = session.TwoFactor || twoFactor
+		err = m.store.CompareAndSave(ctx, session, session.RefreshHash)
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+		// Rotated in the meantime: apply to the rotated session
+	}
+}
+
+// Lookup returns the session of a current refresh token without rotating it
+func (m *Manager) Lookup(ctx context.Context, refreshToken string) (Session, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok {
+		return Session{}, ErrRevoked
+	}
+	session, err := m.store.Get(ctx, tenantOf(ctx), sessionID)
+	if err != nil || !sameHash(hashSecret(secret), session.RefreshHash) {
+		return Session{}, ErrRevoked
+	}
+	return session, nil
+}
+
+func (m *Manager) tokens(session Session, secret string) (Tokens, *token.Payload, error) {
+	access, payload, err := m.tokenMaker.CreateSessionToken(session.UserID, session.Role, session.ID, m.accessDuration)
+	if err != nil {
+		return Tokens{}, nil, err
+	}
+	return Tokens{
+		Access:           access,
+		AccessExpiresAt:  payload.ExpiredAt,
+		Refresh:          session.ID + "." + secret,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, payload, nil
+}
+
+func (m *Manager) accessOnly(session Session) (Tokens, *token.Payload, error) {
+	access, payload, err := m.tokenMaker.CreateSessionToken(session.UserID, session.Role, session.ID, m.accessDuration)
+	if err != nil {
+		return Tokens{}, nil, err
+	}
+	return Tokens{Access: access, AccessExpiresAt: payload.ExpiredAt}, payload, nil
+}
+
+func touch(session *Session, client Client) {
+	session.LastSeenAt = time.Now()
+	if client.IP != "" {
+		session.IP = client.IP
+	}
+	if client.UserAgent != "" {
+		session.UserAgent = client.UserAgent
+	}
+}
+
+func newSecret() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	return secret, hashSecret(secret), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func sameHash(a, b string) bool {
+	return b != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// List returns the user's active sessions, most recently used first
+func (m *Manager) List(ctx context.Context, userID string) ([]Session, error) {
+	list, err := m.store.List(ctx, tenantOf(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastSeenAt.After(list[j].LastSeenAt) })
+	return list, nil
+}
+
+// Revoke ends one of the user's sessions
+func (m *Manager) Revoke(ctx context.Context, userID, id string) error {
+	tenantID := tenantOf(ctx)
+	session, err := m.store.Get(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return ErrNotFound
+	}
+	return m.store.Delete(ctx, tenantID, userID, id)
+}
+
+// RevokeAll ends every session of the user except the one with ID except
+// ("" ends them all) and returns how many were ended
+func (m *Manager) RevokeAll(ctx context.Context, userID, except string) (int, error) {
+	tenantID := tenantOf(ctx)
+	list, err := m.store.List(ctx, tenantID, userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, s := range list {
+		if s.ID == except {
+			continue
+		}
+		if err := m.store.Delete(ctx, tenantID, userID, s.ID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// tenantOf scopes sessions: user IDs are only unique within a shop
+func tenantOf(ctx context.Context) string {
+	if tenant := tenancy.FromContext(ctx); tenant != nil {
+		return tenant.TenantID
+	}
+	return tenancy.PublicSchema
+}
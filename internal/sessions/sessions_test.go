@@ -0,0 +1,172 @@
+package sessions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bizbundl/internal/tenancy"
+	"bizbundl/token"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	maker, err := token.NewPasetoMaker("12345678901234567890123456789012")
+	require.NoError(t, err)
+	return NewManager(NewMemoryStore(), maker, time.Minute, time.Hour)
+}
+
+func shopContext(tenantID string) context.Context {
+	ctx := context.WithValue(context.Background(), tenancy.ContextKey, &tenancy.Tenant{TenantID: tenantID})
+	return context.WithValue(ctx, ClientKey, Client{UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Chrome/120.0 Safari/537.36", IP: "203.0.113.7"})
+}
+
+func TestIssueAndCheck(t *testing.T) {
+	m := newTestManager(t)
+	ctx := shopContext("shop_a")
+
+	tokens, session, err := m.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+	assert.Equal(t, "shop_a", session.TenantID)
+	assert.Equal(t, "Chrome on macOS", session.Device())
+
+	payload, err := m.tokenMaker.VerifyToken(tokens.Access)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, payload.SessionID)
+	assert.NoError(t, m.Check(ctx, payload))
+
+	// Another shop does not know the session
+	assert.ErrorIs(t, m.Check(shopContext("shop_b"), payload), ErrRevoked)
+}
+
+func TestRevokeAll(t *testing.T) {
+	m := newTestManager(t)
+	ctx := shopContext("shop_a")
+
+	_, current, err := m.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+	for range 2 {
+		_, _, err := m.Issue(ctx, "user-1", "customer")
+		require.NoError(t, err)
+	}
+	_, _, err = m.Issue(ctx, "user-2", "customer")
+	require.NoError(t, err)
+
+	n, err := m.RevokeAll(ctx, "user-1", current.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	list, err := m.List(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, current.ID, list[0].ID)
+
+	others, err := m.List(ctx, "user-2")
+	require.NoError(t, err)
+	assert.Len(t, others, 1)
+}
+
+func TestRevokeOtherUsersSession(t *testing.T) {
+	m := newTestManager(t)
+	ctx := shopContext("shop_a")
+
+	_, session, err := m.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+	assert.ErrorIs(t, m.Revoke(ctx, "user-2", session.ID), ErrNotFound)
+	assert.NoError(t, m.Revoke(ctx, "user-1", session.ID))
+}
+
+func TestRefreshRotates(t *testing.T) {
+	m := newTestManager(t)
+	ctx := shopContext("shop_a")
+
+	issued, session, err := m.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+
+	refreshed, payload, err := m.Refresh(ctx, issued.Refresh)
+	require.NoError(t, err)
+	assert.NotEqual(t, issued.Refresh, refreshed.Refresh)
+	assert.Equal(t, session.ID, payload.SessionID)
+	assert.Equal(t, "customer", payload.Role)
+	assert.NoError(t, m.Check(ctx, payload))
+
+	// Refresh tokens are scoped to their shop
+	_, _, err = m.Refresh(shopContext("shop_b"), refreshed.Refresh)
+	assert.ErrorIs(t, err, ErrRevoked)
+	_, _, err = m.Refresh(ctx, "garbage")
+	assert.ErrorIs(t, err, ErrRevoked)
+}
+
+func TestRefreshRace(t *testing.T) {
+	m := newTestManager(t)
+	ctx := shopContext("shop_a")
+
+	issued, _, err := m.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+	_, _, err = m.Refresh(ctx, issued.Refresh)
+	require.NoError(t, err)
+
+	// A parallel request still holding the token just rotated away gets an
+	// access token, but no new refresh token
+	raced, payload, err := m.Refresh(ctx, issued.Refresh)
+	require.NoError(t, err)
+	assert.Empty(t, raced.Refresh)
+	assert.NoError(t, m.Check(ctx, payload))
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	m := newTestManager(t)
+	ctx := shopContext("shop_a")
+
+	issued, _, err := m.Issue(ctx, "user-1", "customer")
+	require.NoError(t, err)
+	first, _, err := m.Refresh(ctx, issued.Refresh)
+	require.NoError(t, err)
+	second, payload, err := m.Refresh(ctx, first.Refresh)
+	require.NoError(t, err)
+
+	// The original token is two rotations old: it leaked
+	_, _, err = m.Refresh(ctx, issued.Refresh)
+	assert.ErrorIs(t, err, ErrRefreshReused)
+
+	// The whole family is gone, including the legitimate holder's tokens
+	assert.ErrorIs(t, m.Check(ctx, payload), ErrRevoked)
+	_, _, err = m.Refresh(ctx, second.Refresh)
+	assert.ErrorIs(t, err, ErrRevoked)
+}
+
+func TestStepUp(t *testing.T) {
+	m := newTestManager(t)
+	ctx := shopContext("shop_a")
+
+	_, session, err := m.Issue(ctx, "user-1", "admin")
+	require.NoError(t, err)
+	assert.False(t, session.TwoFactor)
+	assert.True(t, session.AuthenticatedWithin(time.Minute))
+
+	// Age the sign-in past the window
+	session.AuthenticatedAt = time.Now().Add(-time.Hour)
+	require.NoError(t, m.store.Save(ctx, session))
+	session, err = m.Get(ctx, "user-1", session.ID)
+	require.NoError(t, err)
+	assert.False(t, session.AuthenticatedWithin(time.Minute))
+
+	assert.ErrorIs(t, m.StepUp(ctx, "user-2", session.ID, true), ErrNotFound)
+	require.NoError(t, m.StepUp(ctx, "user-1", session.ID, true))
+	session, err = m.Get(ctx, "user-1", session.ID)
+	require.NoError(t, err)
+	assert.True(t, session.AuthenticatedWithin(time.Minute))
+	assert.True(t, session.TwoFactor)
+
+	// A password step-up does not drop the second factor
+	require.NoError(t, m.StepUp(ctx, "user-1", session.ID, false))
+	session, err = m.Get(ctx, "user-1", session.ID)
+	require.NoError(t, err)
+	assert.True(t, session.TwoFactor)
+
+	_, session, err = m.IssueTwoFactor(ctx, "user-1", "admin")
+	require.NoError(t, err)
+	assert.True(t, session.TwoFactor)
+}
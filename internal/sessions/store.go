@@ -0,0 +1,218 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	redisLib "github.com/redis/go-redis/v9"
+)
+
+const (
+	sessionKeyPrefix = "session:"
+	userKeyPrefix    = "sessions:"
+)
+
+// RedisStore keeps each session under session:{tenant}:{id}, expiring with
+// it, and indexes a user's session IDs in the set sessions:{tenant}:{user}.
+type RedisStore struct {
+	client *redisLib.Client
+}
+
+func NewRedisStore(client *redisLib.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func sessionKey(tenantID, id string) string {
+	return sessionKeyPrefix + tenantID + ":" + id
+}
+
+func userKey(tenantID, userID string) string {
+	return userKeyPrefix + tenantID + ":" + userID
+}
+
+func (s *RedisStore) Save(ctx context.Context, session Session) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		return s.Delete(ctx, session.TenantID, session.UserID, session.ID)
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	index := userKey(session.TenantID, session.UserID)
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, sessionKey(session.TenantID, session.ID), data, ttl)
+	pipe.SAdd(ctx, index, session.ID)
+	// The index lives as long as the user's longest session
+	pipe.ExpireGT(ctx, index, ttl)
+	pipe.ExpireNX(ctx, index, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// CompareAndSave watches the session key so a rotation on another node
+// between the read and the write fails with ErrConflict
+func (s *RedisStore) CompareAndSave(ctx context.Context, session Session, refreshHash string) error {
+	key := sessionKey(session.TenantID, session.ID)
+	err := s.client.Watch(ctx, func(tx *redisLib.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redisLib.Nil) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var current Session
+		if err := json.Unmarshal(data, &current); err != nil {
+			return fmt.Errorf("failed to decode session: %w", err)
+		}
+		if current.RefreshHash != refreshHash {
+			return ErrConflict
+		}
+		ttl := time.Until(session.ExpiresAt)
+		if ttl <= 0 {
+			return ErrNotFound
+		}
+		data, err = json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		index := userKey(session.TenantID, session.UserID)
+		_, err = tx.TxPipelined(ctx, func(pipe redisLib.Pipeliner) error {
+			pipe.Set(ctx, key, data, ttl)
+			pipe.ExpireGT(ctx, index, ttl)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redisLib.TxFailedErr) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *RedisStore) Get(ctx context.Context, tenantID, id string) (Session, error) {
+	data, err := s.client.Get(ctx, sessionKey(tenantID, id)).Bytes()
+	if errors.Is(err, redisLib.Nil) {
+		return Session{}, ErrNotFound
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return Session{}, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return session, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, tenantID, userID, id string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, sessionKey(tenantID, id))
+	pipe.SRem(ctx, userKey(tenantID, userID), id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) List(ctx context.Context, tenantID, userID string) ([]Session, error) {
+	index := userKey(tenantID, userID)
+	ids, err := s.client.SMembers(ctx, index).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(tenantID, id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Session
+	var expired []any
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			return nil, fmt.Errorf("failed to decode session: %w", err)
+		}
+		list = append(list, session)
+	}
+	if len(expired) > 0 {
+		s.client.SRem(ctx, index, expired...)
+	}
+	return list, nil
+}
+
+// MemoryStore keeps sessions in process. It is for tests and single-node
+// development without Redis.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]Session{}}
+}
+
+func (s *MemoryStore) Save(_ context.Context, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionKey(session.TenantID, session.ID)] = session
+	return nil
+}
+
+func (s *MemoryStore) CompareAndSave(_ context.Context, session Session, refreshHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sessionKey(session.TenantID, session.ID)
+	current, ok := s.sessions[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if current.RefreshHash != refreshHash {
+		return ErrConflict
+	}
+	s.sessions[key] = session
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, tenantID, id string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[sessionKey(tenantID, id)]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		return Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, tenantID, _, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionKey(tenantID, id))
+	return nil
+}
+
+func (s *MemoryStore) List(_ context.Context, tenantID, userID string) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Session
+	now := time.Now()
+	for _, session := range s.sessions {
+		if session.TenantID == tenantID && session.UserID == userID && now.Before(session.ExpiresAt) {
+			list = append(list, session)
+		}
+	}
+	return list, nil
+}
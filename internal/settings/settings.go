@@ -2,11 +2,16 @@ package settings
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"bizbundl/internal/audit"
 	db "bizbundl/internal/db/sqlc"
 	"bizbundl/internal/store"
+	"bizbundl/internal/tenancy"
 	"bizbundl/util"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // Constants for Cache Prefixes
@@ -20,16 +25,17 @@ const (
 var AppSecret = "my-secret-key-32-bytes-long-1234"
 
 type Settings struct {
-	q db.Querier
+	q     db.Querier
+	audit *audit.Log
 }
 
 func NewSettings(q db.Querier) *Settings {
-	return &Settings{q: q}
+	return &Settings{q: q, audit: audit.NewLog(q)}
 }
 
 // GetConfig retrieves a config value (Read-Through Pattern)
 func (s *Settings) GetConfig(ctx context.Context, key string) (string, error) {
-	cacheKey := PrefixConfig + key
+	cacheKey := configKey(ctx, key)
 
 	// 1. Check L1 Cache (Memory)
 	if val, ok := store.Get().Get(ctx, cacheKey); ok {
@@ -58,8 +64,32 @@ func (s *Settings) GetConfig(ctx context.Context, key string) (string, error) {
 	return finalValue, nil
 }
 
-// SetConfig updates a config value (Write-Through Pattern)
+// SetConfig updates a config value (Write-Through Pattern). Encrypted
+// values are audited as changed, without the value.
 func (s *Settings) SetConfig(ctx context.Context, key, value, group string, encrypt bool) error {
+	entry := audit.Entry{
+		Action:     "config.updated",
+		EntityType: "config",
+		EntityID:   key,
+		After:      map[string]any{"value": value},
+	}
+	if encrypt {
+		entry.Secrets = []string{"value"}
+	}
+	previous, err := s.q.GetStoreConfig(ctx, key)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		entry.Action = "config.created"
+	case err != nil:
+		return err
+	default:
+		// An encrypted value is ciphertext: it always differs, and stays secret
+		entry.Before = map[string]any{"value": previous.Value}
+		if previous.IsEncrypted != nil && *previous.IsEncrypted {
+			entry.Secrets = []string{"value"}
+		}
+	}
+
 	finalValue := value
 
 	// 1. Encrypt if needed
@@ -72,7 +102,7 @@ func (s *Settings) SetConfig(ctx context.Context, key, value, group string, encr
 	}
 
 	// 2. Update DB
-	_, err := s.q.CreateStoreConfig(ctx, db.CreateStoreConfigParams{
+	_, err = s.q.CreateStoreConfig(ctx, db.CreateStoreConfigParams{
 		Key:         key,
 		Value:       finalValue,
 		IsEncrypted: &encrypt,
@@ -94,15 +124,34 @@ func (s *Settings) SetConfig(ctx context.Context, key, value, group string, encr
 		}
 	}
 
+	if err := s.audit.Record(ctx, entry); err != nil {
+		return err
+	}
+
 	// 3. Update L1 Cache
-	store.Get().SetDefault(ctx, PrefixConfig+key, value) // Store RAW value in cache for speed
+	store.Get().SetDefault(ctx, configKey(ctx, key), value) // Store RAW value in cache for speed
 
 	return nil
 }
 
+// ListConfigKeys returns the keys of the shop's configs in group
+func (s *Settings) ListConfigKeys(ctx context.Context, group string) ([]string, error) {
+	configs, err := s.q.ListStoreConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, cfg := range configs {
+		if cfg.GroupName == group {
+			keys = append(keys, cfg.Key)
+		}
+	}
+	return keys, nil
+}
+
 // GetPaymentGateway retrieves a gateway config
 func (s *Settings) GetPaymentGateway(ctx context.Context, id string) (*db.PaymentGateway, error) {
-	cacheKey := PrefixPayment + id
+	cacheKey := paymentKey(ctx, id)
 
 	// 1. Memory Check
 	if val, ok := store.Get().Get(ctx, cacheKey); ok {
@@ -128,3 +177,63 @@ func (s *Settings) GetPaymentGateway(ctx context.Context, id string) (*db.Paymen
 
 	return &pg, nil
 }
+
+// ListPaymentGateways returns the shop's gateways in checkout order
+func (s *Settings) ListPaymentGateways(ctx context.Context) ([]db.PaymentGateway, error) {
+	return s.q.ListPaymentGateways(ctx)
+}
+
+// UpdatePaymentGateway changes a gateway's settings and credentials (Write-Through Pattern)
+func (s *Settings) UpdatePaymentGateway(ctx context.Context, arg db.UpdatePaymentGatewayParams) (*db.PaymentGateway, error) {
+	before, err := s.q.GetPaymentGateway(ctx, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	pg, err := s.q.UpdatePaymentGateway(ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+	err = s.audit.Record(ctx, audit.Entry{
+		Action:     "payment_gateway.updated",
+		EntityType: "payment_gateway",
+		EntityID:   pg.ID,
+		Before:     gatewaySnapshot(before),
+		After:      gatewaySnapshot(pg),
+		Secrets:    []string{"config"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	store.Get().SetDefault(ctx, paymentKey(ctx, arg.ID), &pg)
+	return &pg, nil
+}
+
+// gatewaySnapshot is what the audit log compares of a gateway. The config
+// holds credentials: only that it changed is logged.
+func gatewaySnapshot(pg db.PaymentGateway) map[string]any {
+	return map[string]any{
+		"name":         pg.Name,
+		"config":       string(pg.Config),
+		"is_test_mode": pg.IsTestMode,
+		"is_active":    pg.IsActive,
+		"position":     pg.Position,
+	}
+}
+
+// configKey is the cache key of a config. Keys are the same in every shop,
+// so it includes the tenant.
+func configKey(ctx context.Context, key string) string {
+	if tenant := tenancy.FromContext(ctx); tenant != nil {
+		return PrefixConfig + tenant.TenantID + ":" + key
+	}
+	return PrefixConfig + key
+}
+
+// paymentKey is the cache key of a gateway. Gateway IDs are the same in
+// every shop, so the key includes the tenant.
+func paymentKey(ctx context.Context, id string) string {
+	if tenant := tenancy.FromContext(ctx); tenant != nil {
+		return PrefixPayment + tenant.TenantID + ":" + id
+	}
+	return PrefixPayment + id
+}
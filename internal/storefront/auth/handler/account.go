@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/internal/views/frontend/pages"
+	"bizbundl/util"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+)
+
+var errPasswordMismatch = errors.New("the passwords do not match")
+
+// The shop's host was resolved to this tenant by TenancyMiddleware, so
+// c.BaseURL() is safe to put in emailed links.
+
+// ForgotPasswordPage renders the reset request form
+func (h *AuthHandler) ForgotPasswordPage(c *fiber.Ctx) error {
+	return util.Render(c, pages.ForgotPassword(pages.AccountForm{}))
+}
+
+// ForgotPassword emails a reset link. The answer is the same whether or not
+// the email has an account.
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	data := pages.AccountForm{Email: c.FormValue("email")}
+	if err := h.service.RequestPasswordReset(c.Context(), data.Email, c.BaseURL()); err != nil {
+		log.Error().Err(err).Msg("failed to send password reset")
+		data.Error = "We couldn't send the email right now. Please try again."
+		return renderAccount(c, pages.ForgotPasswordForm(data), pages.ForgotPassword(data))
+	}
+	data.Done = true
+	data.Message = "If an account exists for " + data.Email + ", we've sent it a link to reset the password."
+	return renderAccount(c, pages.ForgotPasswordForm(data), pages.ForgotPassword(data))
+}
+
+// ResetPasswordPage renders the new password form for an emailed link
+func (h *AuthHandler) ResetPasswordPage(c *fiber.Ctx) error {
+	return util.Render(c, pages.ResetPassword(pages.AccountForm{Token: c.Query("token")}))
+}
+
+// ResetPassword sets the new password and signs the user out everywhere,
+// this browser included
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	data := pages.AccountForm{Token: c.FormValue("token")}
+	password := c.FormValue("password")
+
+	var err error
+	if password != c.FormValue("confirm_password") {
+		err = errPasswordMismatch
+	} else {
+		err = h.service.ResetPassword(c.Context(), data.Token, password)
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidLink), errors.Is(err, service.ErrWeakPassword), errors.Is(err, errPasswordMismatch):
+		data.Error = err.Error()
+	case err != nil:
+		log.Error().Err(err).Msg("failed to reset password")
+		data.Error = "We couldn't reset your password right now. Please try again."
+	default:
+		h.cookies.Clear(c)
+		data.Done = true
+		data.Message = "Your password has been changed. Sign in with your new password."
+	}
+	return renderAccount(c, pages.ResetPasswordForm(data), pages.ResetPassword(data))
+}
+
+// VerifyEmailPage confirms the address of an emailed verification link
+func (h *AuthHandler) VerifyEmailPage(c *fiber.Ctx) error {
+	var data pages.AccountForm
+	err := h.service.VerifyEmail(c.Context(), c.Query("token"))
+	switch {
+	case errors.Is(err, service.ErrInvalidLink):
+		data.Error = err.Error()
+	case err != nil:
+		log.Error().Err(err).Msg("failed to verify email")
+		data.Error = "We couldn't verify your email right now. Please try again."
+	default:
+		data.Message = "Thanks, your email address is confirmed."
+	}
+	return util.Render(c, pages.VerifyEmail(data))
+}
+
+// UnlockAccountPage lifts the lock of an emailed unlock link
+func (h *AuthHandler) UnlockAccountPage(c *fiber.Ctx) error {
+	var data pages.AccountForm
+	err := h.service.UnlockAccount(c.Context(), c.Query("token"))
+	switch {
+	case errors.Is(err, service.ErrInvalidLink):
+		data.Error = err.Error()
+	case err != nil:
+		log.Error().Err(err).Msg("failed to unlock account")
+		data.Error = "We couldn't unlock your account right now. Please try again."
+	default:
+		data.Message = "Your account is unlocked. You can sign in again."
+	}
+	return util.Render(c, pages.UnlockAccount(data))
+}
+
+// ResendVerification emails the signed-in user a new verification link
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	userIDStr, _, ok := signedIn(c)
+	if !ok {
+		return nil
+	}
+	var userID pgtype.UUID
+	if err := userID.Scan(userIDStr); err != nil {
+		return util.APIError(c, fiber.StatusUnauthorized, fiber.NewError(fiber.StatusUnauthorized, "Invalid User ID in token"))
+	}
+	if err := h.service.SendVerificationEmail(c.Context(), userID, c.BaseURL()); err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Verification email sent")
+}
+
+// renderAccount swaps just the form for HTMX and renders the page otherwise
+func renderAccount(c *fiber.Ctx, form, page templ.Component) error {
+	if c.Get("HX-Request") == "true" {
+		return util.Render(c, form)
+	}
+	return util.Render(c, page)
+}
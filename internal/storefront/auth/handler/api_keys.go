@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"bizbundl/internal/apikeys"
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/permissions"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// APIKeyHandler lets shop admins manage the API keys of their integrations
+type APIKeyHandler struct {
+	keys *apikeys.Service
+}
+
+func NewAPIKeyHandler(keys *apikeys.Service) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+func newAPIKeyResponse(key db.ApiKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID.String(),
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt.Time,
+		ExpiresAt:  timeOrNil(key.ExpiresAt),
+		LastUsedAt: timeOrNil(key.LastUsedAt),
+		RevokedAt:  timeOrNil(key.RevokedAt),
+	}
+}
+
+func timeOrNil(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// Scopes lists what keys can be allowed to do
+func (h *APIKeyHandler) Scopes(c *fiber.Ctx) error {
+	var scopes []permissions.Definition
+	for _, d := range permissions.Registry {
+		for _, scope := range apikeys.Scopes {
+			if d.Name == scope {
+				scopes = append(scopes, d)
+			}
+		}
+	}
+	return util.JSON(c, fiber.StatusOK, scopes, "API key scopes")
+}
+
+// List returns the shop's API keys
+func (h *APIKeyHandler) List(c *fiber.Ctx) error {
+	keys, err := h.keys.List(c.Context())
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	res := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		res[i] = newAPIKeyResponse(key)
+	}
+	return util.JSON(c, fiber.StatusOK, res, "API keys")
+}
+
+// Create creates a key and returns it, the only time it is shown. It runs
+// behind RequireStepUp.
+func (h *APIKeyHandler) Create(c *fiber.Ctx) error {
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	var createdBy pgtype.UUID
+	if userID, _ := c.Locals("user_id").(string); userID != "" {
+		_ = createdBy.Scan(userID)
+	}
+
+	secret, key, err := h.keys.Create(c.Context(), req.Name, req.Scopes, req.ExpiresAt, createdBy)
+	switch {
+	case errors.Is(err, apikeys.ErrInvalidName), errors.Is(err, apikeys.ErrInvalidScope),
+		errors.Is(err, apikeys.ErrNoScopes), errors.Is(err, apikeys.ErrPastExpiry):
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	case err != nil:
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusCreated, fiber.Map{
+		"key":     secret,
+		"api_key": newAPIKeyResponse(key),
+	}, "API key created. Copy it now: it will not be shown again.")
+}
+
+// Revoke stops a key from working
+func (h *APIKeyHandler) Revoke(c *fiber.Ctx) error {
+	var id pgtype.UUID
+	if err := id.Scan(c.Params("id")); err != nil {
+		return util.APIError(c, fiber.StatusNotFound, apikeys.ErrKeyNotFound)
+	}
+	err := h.keys.Revoke(c.Context(), id)
+	if errors.Is(err, apikeys.ErrKeyNotFound) {
+		return util.APIError(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "API key revoked")
+}
@@ -2,34 +2,45 @@ package handler
 
 import (
 	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/loginguard"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/sessions"
 	"bizbundl/internal/storefront/auth/service"
 	cartservice "bizbundl/internal/storefront/cart/service"
 	"bizbundl/util"
-	"time"
+	"errors"
+	"math"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
 )
 
 type AuthHandler struct {
 	service     *service.AuthService
 	cartService *cartservice.CartService
+	cookies     middleware.CookiePolicy
 }
 
-func NewAuthHandler(service *service.AuthService, cartService *cartservice.CartService) *AuthHandler {
-	return &AuthHandler{service: service, cartService: cartService}
+func NewAuthHandler(service *service.AuthService, cartService *cartservice.CartService, cookies middleware.CookiePolicy) *AuthHandler {
+	return &AuthHandler{service: service, cartService: cartService, cookies: cookies}
 }
 
 // Assuming UserResponse struct is defined elsewhere and has FirstName and LastName fields.
 // Also assuming db.User struct has FirstName and LastName fields instead of FullName.
 func newUserResponse(user db.User) UserResponse {
-	phone := ""
+	email, phone := "", ""
+	if user.Email != nil {
+		email = *user.Email
+	}
 	if user.Phone != nil {
 		phone = *user.Phone
 	}
 	return UserResponse{
 		ID:          user.ID.String(),
-		Email:       user.Email,
+		Email:       email,
 		FirstName:   user.FirstName, // Changed from FullName
 		LastName:    user.LastName,  // Added
 		Phone:       phone,
@@ -53,8 +64,12 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return util.APIError(c, fiber.StatusInternalServerError, err)
 	}
 
+	if err := h.service.SendVerificationEmail(c.Context(), user.ID, c.BaseURL()); err != nil {
+		log.Warn().Err(err).Str("user", user.ID.String()).Msg("failed to send verification email")
+	}
+
 	// Auto-Login
-	token, _, err := h.service.Login(c.Context(), req.Email, req.Password)
+	tokens, _, err := h.service.Login(c.Context(), req.Email, req.Password, c.BaseURL())
 	if err != nil {
 		// Registration successful but login failed (rare/weird)
 		// Return success but no token? Or error?
@@ -62,11 +77,12 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return util.JSON(c, fiber.StatusCreated, user, "Registration successful (Login failed)")
 	}
 
-	h.setSessionCookie(c, token)
+	h.cookies.SetTokens(c, tokens)
 
 	return util.JSON(c, fiber.StatusCreated, fiber.Map{
-		"token": token,
-		"user":  newUserResponse(user),
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+		"user":          newUserResponse(user),
 	}, "Registration successful")
 }
 
@@ -76,12 +92,36 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return util.APIError(c, fiber.StatusBadRequest, err)
 	}
 
-	token, user, err := h.service.Login(c.Context(), req.Email, req.Password)
+	tokens, user, err := h.service.Login(c.Context(), req.Email, req.Password, c.BaseURL())
+	var challenge *mfa.ChallengeError
+	if errors.As(err, &challenge) {
+		// Finished with TwoFactorLogin
+		return util.JSON(c, fiber.StatusOK, fiber.Map{
+			"two_factor_required": true,
+			"challenge":           challenge.Challenge,
+		}, challenge.Error())
+	}
+	var limit *loginguard.LimitError
+	if errors.As(err, &limit) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(limit.RetryAfter.Seconds()))))
+		return util.APIError(c, fiber.StatusTooManyRequests, err)
+	}
 	if err != nil {
 		return util.APIError(c, fiber.StatusUnauthorized, err)
 	}
 
-	// Merge Guest Cart if exists
+	h.mergeGuestCart(c, user.ID)
+	h.cookies.SetTokens(c, tokens)
+
+	return util.JSON(c, fiber.StatusOK, fiber.Map{
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+		"user":          newUserResponse(user),
+	}, "Login successful")
+}
+
+// mergeGuestCart moves the cart of the guest signing in to their account
+func (h *AuthHandler) mergeGuestCart(c *fiber.Ctx, userID pgtype.UUID) {
 	// Middleware ensures "user_id" and "user_role" are set.
 	// If the current role is 'guest', we use that ID to merge.
 	currentRole, _ := c.Locals("user_role").(string)
@@ -92,24 +132,156 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 			if err := guestUUID.Scan(guestIDStr); err == nil {
 				// Perform Merge (Async or Sync? Sync is safer for immediate cart view)
 				// Ignoring error for now (or log it), shouldn't block login
-				_ = h.cartService.MergeCarts(c.Context(), guestUUID, user.ID)
+				_ = h.cartService.MergeCarts(c.Context(), guestUUID, userID)
 			}
 		}
 	}
+}
 
-	h.setSessionCookie(c, token)
+// Refresh exchanges a refresh token (from the body, for API clients, or the
+// cookie) for a new access and refresh token. Each refresh token works once.
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if req.RefreshToken == "" {
+		req.RefreshToken = c.Cookies(middleware.RefreshCookie)
+	}
+	if req.RefreshToken == "" {
+		return util.APIError(c, fiber.StatusUnauthorized, fiber.NewError(fiber.StatusUnauthorized, "Missing refresh token"))
+	}
 
+	tokens, err := h.service.Refresh(c.Context(), req.RefreshToken)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		h.cookies.Clear(c)
+		return util.APIError(c, fiber.StatusUnauthorized, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+
+	h.cookies.SetTokens(c, tokens)
 	return util.JSON(c, fiber.StatusOK, fiber.Map{
-		"token": token,
-		"user":  newUserResponse(user),
-	}, "Login successful")
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+	}, "Token refreshed")
 }
 
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	c.ClearCookie("session_token")
+	userID, _ := c.Locals("user_id").(string)
+	sessionID, _ := c.Locals("session_id").(string)
+	if err := h.service.Logout(c.Context(), userID, sessionID); err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	h.cookies.Clear(c)
 	return util.JSON(c, fiber.StatusOK, nil, "Logged out")
 }
 
+// signedIn returns the caller's user and session IDs; ok is false (and the
+// response written) for guests
+func signedIn(c *fiber.Ctx) (userID, sessionID string, ok bool) {
+	userID, _ = c.Locals("user_id").(string)
+	sessionID, _ = c.Locals("session_id").(string)
+	if userID == "" || sessionID == "" {
+		util.APIError(c, fiber.StatusUnauthorized, fiber.NewError(fiber.StatusUnauthorized, "Not authenticated"))
+		return "", "", false
+	}
+	return userID, sessionID, true
+}
+
+// Sessions lists the caller's signed-in devices
+func (h *AuthHandler) Sessions(c *fiber.Ctx) error {
+	userID, sessionID, ok := signedIn(c)
+	if !ok {
+		return nil
+	}
+	list, err := h.service.Sessions(c.Context(), userID)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	res := make([]SessionResponse, len(list))
+	for i, s := range list {
+		res[i] = SessionResponse{
+			ID:         s.ID,
+			Device:     s.Device(),
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			Current:    s.ID == sessionID,
+		}
+	}
+	return util.JSON(c, fiber.StatusOK, res, "Active sessions")
+}
+
+// RevokeSession signs one of the caller's devices out
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	userID, _, ok := signedIn(c)
+	if !ok {
+		return nil
+	}
+	err := h.service.RevokeSession(c.Context(), userID, c.Params("id"))
+	if errors.Is(err, sessions.ErrNotFound) {
+		return util.APIError(c, fiber.StatusNotFound, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Session revoked")
+}
+
+// RevokeOtherSessions logs the caller out everywhere else
+func (h *AuthHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	userID, sessionID, ok := signedIn(c)
+	if !ok {
+		return nil
+	}
+	n, err := h.service.RevokeOtherSessions(c.Context(), userID, sessionID)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, fiber.Map{"revoked": n}, "Signed out of other sessions")
+}
+
+// ChangePassword sets a new password and signs out every other session
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	userIDStr, sessionID, ok := signedIn(c)
+	if !ok {
+		return nil
+	}
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	var userID pgtype.UUID
+	if err := userID.Scan(userIDStr); err != nil {
+		return util.APIError(c, fiber.StatusUnauthorized, fiber.NewError(fiber.StatusUnauthorized, "Invalid User ID in token"))
+	}
+
+	err := h.service.ChangePassword(c.Context(), userID, req.CurrentPassword, req.NewPassword, sessionID)
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return util.APIError(c, fiber.StatusUnauthorized, err)
+	case errors.Is(err, service.ErrWeakPassword):
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	case err != nil:
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Password changed")
+}
+
+// Handoff signs in an owner or team member arriving from the platform
+// dashboard and sends them on to the admin.
+func (h *AuthHandler) Handoff(c *fiber.Ctx) error {
+	tokens, _, err := h.service.Handoff(c.Context(), c.Query("token"))
+	if err != nil {
+		return util.APIError(c, fiber.StatusUnauthorized, err)
+	}
+
+	h.cookies.SetTokens(c, tokens)
+	return c.Redirect("/admin")
+}
+
 func (h *AuthHandler) Me(c *fiber.Ctx) error {
 	// Middleware sets user_id from Token
 	userIDStr, ok := c.Locals("user_id").(string)
@@ -134,14 +306,3 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 
 	return util.JSON(c, fiber.StatusOK, newUserResponse(user), "Current user")
 }
-
-func (h *AuthHandler) setSessionCookie(c *fiber.Ctx, token string) {
-	cookie := new(fiber.Cookie)
-	cookie.Name = "session_token"
-	cookie.Value = token
-	cookie.Expires = time.Now().Add(24 * 7 * time.Hour)
-	cookie.HTTPOnly = true
-	cookie.Secure = true
-	cookie.SameSite = "Strict"
-	c.Cookie(cookie)
-}
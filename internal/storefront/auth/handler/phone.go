@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+
+	"bizbundl/internal/infra/sms"
+	"bizbundl/internal/otp"
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RequestPhoneCode texts a one-time sign-in code to the number
+func (h *AuthHandler) RequestPhoneCode(c *fiber.Ctx) error {
+	var req PhoneCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if err := h.service.RequestPhoneCode(c.Context(), req.Phone, c.IP()); err != nil {
+		return phoneError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, fiber.Map{"expires_in": int(otp.CodeTTL.Seconds())}, "Code sent")
+}
+
+// PhoneLogin signs in (or registers) a customer with a code from
+// RequestPhoneCode
+func (h *AuthHandler) PhoneLogin(c *fiber.Ctx) error {
+	var req PhoneLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+
+	tokens, user, created, err := h.service.LoginWithPhone(c.Context(), req.Phone, req.Code, req.FullName)
+	if err != nil {
+		return phoneError(c, err)
+	}
+
+	h.mergeGuestCart(c, user.ID)
+	h.cookies.SetTokens(c, tokens)
+
+	status, message := fiber.StatusOK, "Login successful"
+	if created {
+		status, message = fiber.StatusCreated, "Registration successful"
+	}
+	return util.JSON(c, status, fiber.Map{
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+		"user":          newUserResponse(user),
+	}, message)
+}
+
+// LinkPhone adds a verified phone number to the caller's account, merging
+// in a phone-only account that signed in with it
+func (h *AuthHandler) LinkPhone(c *fiber.Ctx) error {
+	userIDStr, _, ok := signedIn(c)
+	if !ok {
+		return nil
+	}
+	var req LinkPhoneRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	var userID pgtype.UUID
+	if err := userID.Scan(userIDStr); err != nil {
+		return util.APIError(c, fiber.StatusUnauthorized, fiber.NewError(fiber.StatusUnauthorized, "Invalid User ID in token"))
+	}
+
+	user, err := h.service.LinkPhone(c.Context(), userID, req.Phone, req.Code)
+	if err != nil {
+		return phoneError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, newUserResponse(user), "Phone number verified")
+}
+
+func phoneError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, sms.ErrInvalidNumber):
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	case errors.Is(err, otp.ErrRateLimited):
+		c.Set(fiber.HeaderRetryAfter, "60")
+		return util.APIError(c, fiber.StatusTooManyRequests, err)
+	case errors.Is(err, otp.ErrInvalidCode), errors.Is(err, otp.ErrTooManyAttempts), errors.Is(err, service.ErrInvalidCredentials):
+		return util.APIError(c, fiber.StatusUnauthorized, err)
+	case errors.Is(err, service.ErrPhoneTaken):
+		return util.APIError(c, fiber.StatusConflict, err)
+	default:
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+}
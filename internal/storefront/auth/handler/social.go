@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/oauth"
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/internal/views/frontend/pages"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// oauthStateCookie keeps a sign-in's state between SocialLogin and
+// SocialCallback. It is only sent back to the callback.
+const oauthStateCookie = "oauth_state"
+
+// SocialProviders lists the sign-in providers the shop has enabled, for the
+// "Continue with ..." buttons
+func (h *AuthHandler) SocialProviders(c *fiber.Ctx) error {
+	providers, err := h.service.SocialProviders(c.Context())
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	names := []string{}
+	for _, p := range providers {
+		if p.Enabled && p.Configured {
+			names = append(names, p.Name)
+		}
+	}
+	return util.JSON(c, fiber.StatusOK, names, "Sign-in providers")
+}
+
+// SocialLogin sends the customer to the provider to sign in. ?next= is
+// where to go once they are back.
+func (h *AuthHandler) SocialLogin(c *fiber.Ctx) error {
+	next := middleware.LocalPath(c.Query("next"))
+	authURL, state, err := h.service.SocialLoginURL(c.Context(), c.Params("provider"), c.BaseURL(), next)
+	if err != nil {
+		return renderSocialError(c, err)
+	}
+	c.Cookie(h.stateCookie(c, state, time.Now().Add(oauth.StateTTL)))
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// SocialCallback is where the provider sends the customer back. It signs
+// them in and moves their guest cart over, as Login does.
+func (h *AuthHandler) SocialCallback(c *fiber.Ctx) error {
+	state := c.Cookies(oauthStateCookie)
+	c.Cookie(h.stateCookie(c, "", time.Unix(0, 0)))
+
+	tokens, user, next, err := h.service.SocialLogin(c.Context(), c.Params("provider"), c.BaseURL(), state, oauth.Callback{
+		State: c.Query("state"),
+		Code:  c.Query("code"),
+		Error: c.Query("error"),
+	})
+	if err != nil {
+		return renderSocialError(c, err)
+	}
+
+	h.mergeGuestCart(c, user.ID)
+	h.cookies.SetTokens(c, tokens)
+	return c.Redirect(middleware.LocalPath(next), fiber.StatusFound)
+}
+
+func (h *AuthHandler) stateCookie(c *fiber.Ctx, value string, expires time.Time) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    value,
+		Path:     service.SocialLoginPath + c.Params("provider"),
+		Expires:  expires,
+		HTTPOnly: true,
+		Secure:   h.cookies.Secure,
+		// Lax still sends it on the provider's top-level redirect back
+		SameSite: fiber.CookieSameSiteLaxMode,
+	}
+}
+
+// renderSocialError explains why a sign-in did not work
+func renderSocialError(c *fiber.Ctx, err error) error {
+	var data pages.AccountForm
+	switch {
+	case errors.Is(err, service.ErrUnknownProvider), errors.Is(err, service.ErrSocialStaff), errors.Is(err, service.ErrSocialEmailTaken),
+		errors.Is(err, oauth.ErrInvalidState), errors.Is(err, oauth.ErrDenied), errors.Is(err, oauth.ErrNoIdentity):
+		data.Error = err.Error()
+	default:
+		log.Error().Err(err).Str("provider", c.Params("provider")).Msg("social login failed")
+		data.Error = "We couldn't sign you in right now. Please try again."
+	}
+	return util.Render(c, pages.SocialLogin(data))
+}
+
+// ListSocialProviders returns the shop's sign-in provider settings
+func (h *AuthHandler) ListSocialProviders(c *fiber.Ctx) error {
+	providers, err := h.service.SocialProviders(c.Context())
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	res := make([]SocialProviderResponse, len(providers))
+	for i, p := range providers {
+		res[i] = newSocialProviderResponse(p)
+	}
+	return util.JSON(c, fiber.StatusOK, res, "Sign-in providers")
+}
+
+// ConfigureSocialProvider saves a sign-in provider's client credentials.
+// It runs behind RequireStepUp.
+func (h *AuthHandler) ConfigureSocialProvider(c *fiber.Ctx) error {
+	var req SocialProviderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	p, err := h.service.ConfigureSocialProvider(c.Context(), service.SocialProvider{
+		Name:         c.Params("provider"),
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		Issuer:       req.Issuer,
+		Enabled:      req.Enabled,
+	})
+	if errors.Is(err, service.ErrInvalidProvider) {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, newSocialProviderResponse(p), "Sign-in provider updated")
+}
+
+func newSocialProviderResponse(p service.SocialProvider) SocialProviderResponse {
+	return SocialProviderResponse{
+		Name:       p.Name,
+		ClientID:   p.ClientID,
+		Issuer:     p.Issuer,
+		Enabled:    p.Enabled,
+		Configured: p.Configured,
+	}
+}
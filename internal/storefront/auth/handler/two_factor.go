@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/internal/tenancy"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// TwoFactorLogin finishes a login answered with a challenge, with a code
+// from the authenticator app or a recovery code
+func (h *AuthHandler) TwoFactorLogin(c *fiber.Ctx) error {
+	var req TwoFactorLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+
+	tokens, user, err := h.service.LoginTwoFactor(c.Context(), req.Challenge, req.Code)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	h.mergeGuestCart(c, user.ID)
+	h.cookies.SetTokens(c, tokens)
+
+	return util.JSON(c, fiber.StatusOK, fiber.Map{
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+		"user":          newUserResponse(user),
+	}, "Login successful")
+}
+
+// TwoFactorStatus tells whether the caller has two-factor authentication on
+func (h *AuthHandler) TwoFactorStatus(c *fiber.Ctx) error {
+	userID, _, ok := signedInUser(c)
+	if !ok {
+		return nil
+	}
+	factor, err := h.service.TwoFactorStatus(c.Context(), userID)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+	tenant := tenancy.FromContext(c.Context())
+	return util.JSON(c, fiber.StatusOK, TwoFactorStatusResponse{
+		Enabled:           factor.Enabled,
+		RecoveryCodesLeft: factor.RecoveryCodesLeft,
+		Required:          tenant != nil && tenant.RequireTwoFactor,
+	}, "Two-factor status")
+}
+
+// SetupTwoFactor starts enrollment: the secret and otpauth URI the admin
+// shows as a QR code for the authenticator app
+func (h *AuthHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	userID, _, ok := signedInUser(c)
+	if !ok {
+		return nil
+	}
+	enrollment, err := h.service.SetupTwoFactor(c.Context(), userID)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, TwoFactorSetupResponse{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	}, "Scan the QR code, then confirm with a code")
+}
+
+// EnableTwoFactor confirms enrollment with a code and returns the recovery
+// codes, which are only shown this once
+func (h *AuthHandler) EnableTwoFactor(c *fiber.Ctx) error {
+	userID, sessionID, ok := signedInUser(c)
+	if !ok {
+		return nil
+	}
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	codes, err := h.service.EnableTwoFactor(c.Context(), userID, sessionID, req.Code)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, fiber.Map{"recovery_codes": codes}, "Two-factor authentication enabled")
+}
+
+// DisableTwoFactor turns two-factor authentication off. It runs behind
+// RequireStepUp.
+func (h *AuthHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	userID, _, ok := signedInUser(c)
+	if !ok {
+		return nil
+	}
+	if err := h.service.DisableTwoFactor(c.Context(), userID); err != nil {
+		return twoFactorError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Two-factor authentication disabled")
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes. It runs
+// behind RequireStepUp.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID, _, ok := signedInUser(c)
+	if !ok {
+		return nil
+	}
+	codes, err := h.service.RegenerateRecoveryCodes(c.Context(), userID)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, fiber.Map{"recovery_codes": codes}, "New recovery codes")
+}
+
+// StepUp re-authenticates the caller, unlocking sensitive actions for a while
+func (h *AuthHandler) StepUp(c *fiber.Ctx) error {
+	userID, sessionID, ok := signedInUser(c)
+	if !ok {
+		return nil
+	}
+	var req StepUpRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if err := h.service.StepUp(c.Context(), userID, sessionID, req.Password, req.Code); err != nil {
+		return twoFactorError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Confirmed")
+}
+
+// signedInUser is signedIn with the user ID parsed
+func signedInUser(c *fiber.Ctx) (pgtype.UUID, string, bool) {
+	var userID pgtype.UUID
+	userIDStr, sessionID, ok := signedIn(c)
+	if !ok {
+		return userID, "", false
+	}
+	if err := userID.Scan(userIDStr); err != nil {
+		util.APIError(c, fiber.StatusUnauthorized, fiber.NewError(fiber.StatusUnauthorized, "Invalid User ID in token"))
+		return userID, "", false
+	}
+	return userID, sessionID, true
+}
+
+func twoFactorError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, mfa.ErrTooManyAttempts):
+		return util.APIError(c, fiber.StatusTooManyRequests, err)
+	case errors.Is(err, mfa.ErrInvalidCode), errors.Is(err, mfa.ErrInvalidChallenge), errors.Is(err, service.ErrInvalidCredentials):
+		return util.APIError(c, fiber.StatusUnauthorized, err)
+	case errors.Is(err, mfa.ErrNotSetUp), errors.Is(err, mfa.ErrNotEnabled), errors.Is(err, mfa.ErrAlreadyEnabled):
+		return util.APIError(c, fiber.StatusConflict, err)
+	case errors.Is(err, service.ErrTwoFactorStaffOnly), errors.Is(err, service.ErrTwoFactorEnforced):
+		return util.APIError(c, fiber.StatusForbidden, err)
+	case errors.Is(err, service.ErrUserNotFound):
+		return util.APIError(c, fiber.StatusNotFound, err)
+	default:
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+}
@@ -1,5 +1,7 @@
 package handler
 
+import "time"
+
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
@@ -24,6 +26,39 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type PhoneCodeRequest struct {
+	Phone string `json:"phone"`
+}
+
+type PhoneLoginRequest struct {
+	Phone    string `json:"phone"`
+	Code     string `json:"code"`
+	FullName string `json:"full_name"` // Name for new customers
+}
+
+type LinkPhoneRequest struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
 type UserResponse struct {
 	ID          string `json:"id"`
 	Email       string `json:"email"`
@@ -34,3 +69,62 @@ type UserResponse struct {
 	Permissions []byte `json:"permissions"`
 	// Omit PasswordHash!
 }
+
+type TwoFactorLoginRequest struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"` // Authenticator or recovery code
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type StepUpRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"` // Required instead of the password with two-factor enabled
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool  `json:"enabled"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+	Required          bool  `json:"required"` // The shop requires it of staff
+}
+
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth:// URI, for the QR code
+}
+
+type SocialProviderRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"` // Empty keeps the saved one
+	Issuer       string `json:"issuer"`        // Custom OpenID Connect providers only
+	Enabled      bool   `json:"enabled"`
+}
+
+// SocialProviderResponse leaves out the client secret: it is write-only
+type SocialProviderResponse struct {
+	Name       string `json:"name"`
+	ClientID   string `json:"client_id"`
+	Issuer     string `json:"issuer,omitempty"`
+	Enabled    bool   `json:"enabled"`
+	Configured bool   `json:"configured"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`     // Permissions, see GET /api/v1/admin/api-keys/scopes
+	ExpiresAt *time.Time `json:"expires_at"` // Optional
+}
+
+// APIKeyResponse leaves out the key: it is only shown once, when created
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
@@ -2,11 +2,16 @@ package auth
 
 import (
 	"bizbundl/internal/constants"
+	"bizbundl/internal/mfa"
 	"bizbundl/internal/middleware"
+	"bizbundl/internal/oauth"
+	"bizbundl/internal/otp"
+	"bizbundl/internal/permissions"
 	"bizbundl/internal/storefront/auth/handler"
 	"bizbundl/internal/storefront/auth/service"
 	cartservice "bizbundl/internal/storefront/cart/service"
 	"bizbundl/internal/server"
+	"bizbundl/token"
 )
 
 // Init initializes the Auth module: wires the Service/Handler and registers routes.
@@ -14,33 +19,100 @@ func Init(app *server.Server) {
 	svc := NewAuthService(app)
 	// Initialize CartService for linking (Ideally, this should be a singleton in app, but new instance is fine)
 	cartSvc := cartservice.NewCartService(app.GetDB())
-	h := handler.NewAuthHandler(svc, cartSvc)
+	h := handler.NewAuthHandler(svc, cartSvc, app.GetCookies())
 
 	// Auth Middleware (Global)
 	// We pass the token maker directly to middleware
 	// We also pass session durations configuration
 	authMiddleware := middleware.Auth(
 		app.GetTokenMaker(),
-		constants.UserSessionDuration,
+		app.GetSessions(),
+		app.GetAPIKeys(),
+		app.GetCookies(),
 		constants.GuestSessionDuration,
-		constants.RefreshThreshold,
+		constants.GuestRenewThreshold,
 	)
 
 	// Apply Auth Middleware Globally (to ensure Guest Session on all routes)
 	app.GetRouter().Use(authMiddleware)
 
-	// Routes
-	api := app.GetRouter().Group("/api/v1/auth")
+	// Routes. Each answers for the caller: their sessions, their account
+	api := app.GetRouter().Group("/api/v1/auth", middleware.NoStore())
 
 	// Public
 	api.Post("/register", h.Register)
 	api.Post("/login", h.Login)
 	api.Post("/logout", h.Logout)
+	api.Post("/refresh", h.Refresh)
+	api.Get("/handoff", middleware.Writes(), h.Handoff)
+	api.Post("/phone/code", h.RequestPhoneCode)
+	api.Post("/phone/login", h.PhoneLogin)
+	api.Post("/2fa/verify", h.TwoFactorLogin)
+	api.Get("/oauth/providers", h.SocialProviders)
 
 	// Protected
 	api.Get("/me", authMiddleware, h.Me)
+	api.Get("/sessions", h.Sessions)
+	api.Delete("/sessions/:id", h.RevokeSession)
+	api.Post("/sessions/revoke-others", h.RevokeOtherSessions)
+	api.Post("/password", h.ChangePassword)
+	api.Post("/verify-email/resend", h.ResendVerification)
+	api.Post("/phone/link", h.LinkPhone)
+	api.Post("/step-up", h.StepUp)
+
+	// Two-factor authentication (staff)
+	stepUp := middleware.RequireStepUp(app.GetSessions(), constants.StepUpWindow)
+	api.Get("/2fa", h.TwoFactorStatus)
+	api.Post("/2fa/setup", h.SetupTwoFactor)
+	api.Post("/2fa/enable", h.EnableTwoFactor)
+	api.Post("/2fa/disable", stepUp, h.DisableTwoFactor)
+	api.Post("/2fa/recovery-codes", stepUp, h.RegenerateRecoveryCodes)
+
+	// Account recovery pages (linked from emails). Their links carry
+	// tokens, and verify-email and unlock use them up on GET (so they are
+	// writes, refused while the shop is frozen)
+	account := app.GetRouter().Group("/account", middleware.NoStore())
+	account.Get("/forgot-password", h.ForgotPasswordPage)
+	account.Post("/forgot-password", h.ForgotPassword)
+	account.Get("/reset-password", h.ResetPasswordPage)
+	account.Post("/reset-password", h.ResetPassword)
+	account.Get("/verify-email", middleware.Writes(), h.VerifyEmailPage)
+	account.Get("/unlock", middleware.Writes(), h.UnlockAccountPage)
+
+	// Social login (customers), through the providers the shop configures.
+	// Each redirect carries the caller's state cookie or session
+	social := app.GetRouter().Group("/auth/oauth", middleware.NoStore())
+	social.Get("/:provider", h.SocialLogin)
+	social.Get("/:provider/callback", middleware.Writes(), h.SocialCallback)
+
+	// Sign-in provider credentials: admins with two-factor (where the shop
+	// requires it), who confirmed it's them before changing them
+	providers := app.GetRouter().Group("/api/v1/admin/oauth-providers",
+		middleware.RequirePermission(app.GetPermissions(), permissions.SettingsManage),
+		middleware.RequireTwoFactor(app.GetSessions()),
+	)
+	providers.Get("/", h.ListSocialProviders)
+	providers.Put("/:provider", stepUp, h.ConfigureSocialProvider)
+
+	// API keys of headless clients and integrations: admins with two-factor
+	// (where the shop requires it), who confirmed it's them to create one.
+	// Never cached, whether the admin signed in or used a key
+	keys := handler.NewAPIKeyHandler(app.GetAPIKeys())
+	apiKeys := app.GetRouter().Group("/api/v1/admin/api-keys",
+		middleware.NoStore(),
+		middleware.RequirePermission(app.GetPermissions(), permissions.SettingsManage),
+		middleware.RequireTwoFactor(app.GetSessions()),
+	)
+	apiKeys.Get("/", keys.List)
+	apiKeys.Get("/scopes", keys.Scopes)
+	apiKeys.Post("/", stepUp, keys.Create)
+	apiKeys.Delete("/:id", keys.Revoke)
 }
 
 func NewAuthService(app *server.Server) *service.AuthService {
-	return service.NewAuthService(app.GetDB(), app.GetTokenMaker())
+	key := app.GetConfig().TokenSymmetricKey
+	handoffs := service.NewHandoffs(token.NewSigner(key, token.PurposeShopHandoff), otp.NewRedisStore(app.GetRedis()))
+	twoFactor := mfa.NewService(service.NewTwoFactorStore(app.GetDB()), otp.NewRedisStore(app.GetRedis()), key)
+	social := oauth.NewClient(key, nil)
+	return service.NewAuthService(app.GetDB(), app.GetSessions(), app.GetMailer(), app.GetOTP(), twoFactor, app.GetLoginGuard(), social, app.GetEntitlements(), handoffs, app.GetPermissions())
 }
@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"bizbundl/internal/infra/mailer"
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outbox records sent mail
+type outbox struct {
+	sent []mailer.Message
+}
+
+func (o *outbox) Send(_ context.Context, msg mailer.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// lastToken returns the token of the link in the last message
+func (o *outbox) lastToken(t *testing.T) string {
+	require.NotEmpty(t, o.sent)
+	for _, field := range strings.Fields(o.sent[len(o.sent)-1].Text) {
+		if u, err := url.Parse(field); err == nil && u.Query().Get("token") != "" {
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatal("no link in message")
+	return ""
+}
+
+func TestPasswordReset(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	mail := &outbox{}
+	svc := service.NewAuthService(srv.GetDB(), srv.GetSessions(), mail, srv.GetOTP(), twoFactor(srv), loginGuard(srv), nil, srv.GetEntitlements(), nil, srv.GetPermissions())
+	ctx := context.Background()
+
+	email := testutil.RandomEmail()
+	_, err := svc.Register(ctx, email, "oldpassword", "Reset User", "111")
+	require.NoError(t, err)
+
+	// Unknown addresses look the same but send nothing
+	require.NoError(t, svc.RequestPasswordReset(ctx, "ghost@example.com", "http://shop.test"))
+	assert.Empty(t, mail.sent)
+
+	require.NoError(t, svc.RequestPasswordReset(ctx, email, "http://shop.test"))
+	require.Len(t, mail.sent, 1)
+	assert.Equal(t, email, mail.sent[0].To)
+	assert.Contains(t, mail.sent[0].Text, "http://shop.test"+service.ResetPasswordPath+"?token=")
+	first := mail.lastToken(t)
+
+	// A newer link replaces the first
+	require.NoError(t, svc.RequestPasswordReset(ctx, email, "http://shop.test"))
+	second := mail.lastToken(t)
+	assert.ErrorIs(t, svc.ResetPassword(ctx, first, "newpassword"), service.ErrInvalidLink)
+
+	// Weak passwords do not use up the link
+	assert.ErrorIs(t, svc.ResetPassword(ctx, second, "short"), service.ErrWeakPassword)
+	require.NoError(t, svc.ResetPassword(ctx, second, "newpassword"))
+
+	_, _, err = svc.Login(ctx, email, "newpassword", "")
+	assert.NoError(t, err)
+	_, _, err = svc.Login(ctx, email, "oldpassword", "")
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+
+	// Single use
+	assert.ErrorIs(t, svc.ResetPassword(ctx, second, "anotherpassword"), service.ErrInvalidLink)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	mail := &outbox{}
+	svc := service.NewAuthService(srv.GetDB(), srv.GetSessions(), mail, srv.GetOTP(), twoFactor(srv), loginGuard(srv), nil, srv.GetEntitlements(), nil, srv.GetPermissions())
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, testutil.RandomEmail(), "password123", "Verify User", "111")
+	require.NoError(t, err)
+	assert.False(t, user.EmailVerifiedAt.Valid)
+
+	require.NoError(t, svc.SendVerificationEmail(ctx, user.ID, "http://shop.test"))
+	tok := mail.lastToken(t)
+
+	assert.ErrorIs(t, svc.VerifyEmail(ctx, "not-a-token"), service.ErrInvalidLink)
+	require.NoError(t, svc.VerifyEmail(ctx, tok))
+	assert.ErrorIs(t, svc.VerifyEmail(ctx, tok), service.ErrInvalidLink)
+
+	user, err = svc.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, user.EmailVerifiedAt.Valid)
+
+	// Verified users are not emailed again
+	require.NoError(t, svc.SendVerificationEmail(ctx, user.ID, "http://shop.test"))
+	assert.Len(t, mail.sent, 1)
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"bizbundl/internal/constants"
+	"bizbundl/internal/otp"
+	"bizbundl/token"
+)
+
+const handoffKeyPrefix = "handoff:used:"
+
+// Handoffs verifies the tokens the platform dashboard signs to send owners
+// and team members into their shop. Each is good for one sign-in: a token
+// lifted from a log or browser history within its minute is refused.
+type Handoffs struct {
+	signer *token.Signer
+	used   otp.Store
+}
+
+// NewHandoffs verifies tokens signed by signer, remembering those used in used
+func NewHandoffs(signer *token.Signer, used otp.Store) *Handoffs {
+	return &Handoffs{signer: signer, used: used}
+}
+
+// Consume verifies a handoff token and uses it up, returning its subject.
+// Subjects start with a random ID, which is what is remembered.
+func (h *Handoffs) Consume(ctx context.Context, handoffToken string) (string, error) {
+	subject, err := h.signer.Verify(handoffToken)
+	if err != nil {
+		return "", err
+	}
+	jti, subject, ok := strings.Cut(subject, "|")
+	if !ok || jti == "" {
+		return "", token.ErrInvalidToken
+	}
+	// Remembered for as long as the token is valid
+	n, err := h.used.Incr(ctx, handoffKeyPrefix+jti, constants.HandoffDuration)
+	if err != nil {
+		return "", err
+	}
+	if n > 1 {
+		return "", token.ErrInvalidToken
+	}
+	return subject, nil
+}
@@ -0,0 +1,36 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bizbundl/internal/otp"
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/token"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandoffsConsume(t *testing.T) {
+	signer := token.NewSigner("12345678901234567890123456789012", token.PurposeShopHandoff)
+	handoffs := service.NewHandoffs(signer, otp.NewMemoryStore())
+	ctx := context.Background()
+
+	handoff := signer.Sign("jti-1|shop_1|owner@example.com", time.Minute)
+	subject, err := handoffs.Consume(ctx, handoff)
+	require.NoError(t, err)
+	assert.Equal(t, "shop_1|owner@example.com", subject)
+
+	_, err = handoffs.Consume(ctx, handoff)
+	assert.ErrorIs(t, err, token.ErrInvalidToken, "replayed")
+	_, err = handoffs.Consume(ctx, signer.Sign("jti-2|shop_1|owner@example.com", time.Minute))
+	assert.NoError(t, err, "another handoff")
+
+	_, err = handoffs.Consume(ctx, signer.Sign("jti-3|shop_1|owner@example.com", -time.Minute))
+	assert.ErrorIs(t, err, token.ErrExpiredToken)
+	other := token.NewSigner("12345678901234567890123456789012", token.PurposeShopInvitation)
+	_, err = handoffs.Consume(ctx, other.Sign("jti-4|shop_1|owner@example.com", time.Minute))
+	assert.ErrorIs(t, err, token.ErrInvalidToken)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/sessions"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrPhoneTaken = errors.New("phone number belongs to another account")
+
+// RequestPhoneCode texts a sign-in code to phone. ip is the client's, codes
+// are rate limited per number and per IP.
+func (s *AuthService) RequestPhoneCode(ctx context.Context, phone, ip string) error {
+	number, err := s.otp.Normalize(phone)
+	if err != nil {
+		return err
+	}
+	return s.otp.Send(ctx, number, ip)
+}
+
+// LoginWithPhone signs a customer in with a code from RequestPhoneCode. A
+// number no account has verified yet registers a new passwordless customer
+// named fullName; created reports that. Staff sign in with their password.
+func (s *AuthService) LoginWithPhone(ctx context.Context, phone, code, fullName string) (tokens sessions.Tokens, user db.User, created bool, err error) {
+	number, err := s.otp.Normalize(phone)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, false, err
+	}
+	if err := s.otp.Verify(ctx, number, code); err != nil {
+		return sessions.Tokens{}, db.User{}, false, err
+	}
+
+	// Only verified numbers identify an account: anyone can type someone
+	// else's number into a registration form
+	user, err = s.store.GetUserByVerifiedPhone(ctx, &number)
+	if errors.Is(err, pgx.ErrNoRows) {
+		user, err = s.store.CreatePhoneUser(ctx, db.CreatePhoneUserParams{
+			Phone:     &number,
+			FirstName: strings.TrimSpace(fullName),
+		})
+		created = true
+	}
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, false, err
+	}
+	if isStaffRole(user.Role) {
+		return sessions.Tokens{}, db.User{}, false, ErrInvalidCredentials
+	}
+
+	tokens, _, err = s.sessions.Issue(ctx, user.ID.String(), string(user.Role))
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, false, err
+	}
+	return tokens, user, created, nil
+}
+
+// LinkPhone verifies phone for a signed-in user, so they can also sign in
+// with it. A passwordless account that already signed in with the number is
+// merged into the user: its carts and orders move over and it is deleted.
+// The request's transaction makes the merge all or nothing.
+func (s *AuthService) LinkPhone(ctx context.Context, userID pgtype.UUID, phone, code string) (db.User, error) {
+	number, err := s.otp.Normalize(phone)
+	if err != nil {
+		return db.User{}, err
+	}
+	if err := s.otp.Verify(ctx, number, code); err != nil {
+		return db.User{}, err
+	}
+
+	existing, err := s.store.GetUserByVerifiedPhone(ctx, &number)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return db.User{}, err
+	case existing.ID == userID:
+		return existing, nil
+	case existing.Email != nil || isStaffRole(existing.Role):
+		return db.User{}, ErrPhoneTaken
+	default:
+		if err := s.mergeAccount(ctx, existing.ID, userID); err != nil {
+			return db.User{}, err
+		}
+	}
+
+	user, err := s.store.SetVerifiedPhone(ctx, db.SetVerifiedPhoneParams{ID: userID, Phone: &number})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.User{}, ErrUserNotFound
+	}
+	return user, err
+}
+
+// mergeAccount moves everything of user from to user into, then deletes from
+func (s *AuthService) mergeAccount(ctx context.Context, from, into pgtype.UUID) error {
+	// Both active carts: one cart with the items of both
+	fromCart, err := s.store.GetCartByUser(ctx, from)
+	if err == nil {
+		intoCart, err := s.store.GetCartByUser(ctx, into)
+		if err == nil {
+			if err := s.store.MoveCartItems(ctx, db.MoveCartItemsParams{ToCartID: intoCart.ID, FromCartID: fromCart.ID}); err != nil {
+				return err
+			}
+			if err := s.store.DeleteCart(ctx, fromCart.ID); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	if err := s.store.ReassignUserCarts(ctx, db.ReassignUserCartsParams{ToUserID: into, FromUserID: from}); err != nil {
+		return err
+	}
+	if err := s.store.ReassignUserOrders(ctx, db.ReassignUserOrdersParams{ToUserID: into, FromUserID: from}); err != nil {
+		return err
+	}
+	if err := s.store.DeleteUserSessions(ctx, from); err != nil {
+		return err
+	}
+	if err := s.store.DeleteUser(ctx, from); err != nil {
+		return err
+	}
+	_, err = s.sessions.RevokeAll(ctx, from.String(), "")
+	return err
+}
@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bizbundl/internal/infra/sms"
+	"bizbundl/internal/otp"
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noCooldown lets a test ask for codes to one number back to back
+type noCooldown struct {
+	*otp.MemoryStore
+}
+
+func (s noCooldown) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	if strings.Contains(key, ":cooldown:") {
+		return 1, nil
+	}
+	return s.MemoryStore.Incr(ctx, key, window)
+}
+
+func newPhoneService(t *testing.T) (*service.AuthService, func(phone string) string) {
+	srv := testutil.SetupTestServer()
+	texts := &sms.Recorder{}
+	codes := otp.NewService(noCooldown{otp.NewMemoryStore()}, texts, "880")
+	svc := service.NewAuthService(srv.GetDB(), srv.GetSessions(), srv.GetMailer(), codes, twoFactor(srv), loginGuard(srv), nil, srv.GetEntitlements(), nil, srv.GetPermissions())
+
+	// code requests a code for phone and returns it
+	code := func(phone string) string {
+		require.NoError(t, svc.RequestPhoneCode(context.Background(), phone, "10.0.0.1"))
+		number, err := codes.Normalize(phone)
+		require.NoError(t, err)
+		msg, ok := texts.Last(number)
+		require.True(t, ok)
+		return msg.Text[:6]
+	}
+	return svc, code
+}
+
+func TestLoginWithPhone(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	svc, code := newPhoneService(t)
+	ctx := context.Background()
+
+	_, _, _, err := svc.LoginWithPhone(ctx, "01712345678", "000000x", "Rahim")
+	assert.ErrorIs(t, err, otp.ErrInvalidCode)
+
+	// First sign-in registers a passwordless customer
+	tokens, user, created, err := svc.LoginWithPhone(ctx, "01712345678", code("01712345678"), "Rahim")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEmpty(t, tokens.Access)
+	assert.Nil(t, user.Email)
+	assert.Equal(t, "+8801712345678", *user.Phone)
+	assert.True(t, user.PhoneVerifiedAt.Valid)
+
+	// The same number, however it is typed, is the same customer
+	_, again, created, err := svc.LoginWithPhone(ctx, "+880 1712-345678", code("+880 1712-345678"), "")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, user.ID, again.ID)
+
+	// A number typed into a registration form is not verified, and does
+	// not sign anyone into that account
+	victim, err := svc.Register(ctx, testutil.RandomEmail(), "password123", "Victim", "+8801811111111")
+	require.NoError(t, err)
+	_, other, created, err := svc.LoginWithPhone(ctx, "01811111111", code("01811111111"), "")
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, victim.ID, other.ID)
+}
+
+func TestLinkPhone(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	svc, code := newPhoneService(t)
+	ctx := context.Background()
+
+	_, phoneUser, _, err := svc.LoginWithPhone(ctx, "01712345678", code("01712345678"), "Rahim")
+	require.NoError(t, err)
+	emailUser, err := svc.Register(ctx, testutil.RandomEmail(), "password123", "Rahim", "")
+	require.NoError(t, err)
+
+	// Linking the number merges the phone-only account into this one
+	linked, err := svc.LinkPhone(ctx, emailUser.ID, "01712345678", code("01712345678"))
+	require.NoError(t, err)
+	assert.Equal(t, "+8801712345678", *linked.Phone)
+	_, err = svc.GetUser(ctx, phoneUser.ID)
+	assert.Error(t, err)
+
+	_, user, _, err := svc.LoginWithPhone(ctx, "01712345678", code("01712345678"), "")
+	require.NoError(t, err)
+	assert.Equal(t, emailUser.ID, user.ID)
+
+	// Accounts with an email are never merged away
+	another, err := svc.Register(ctx, testutil.RandomEmail(), "password123", "Karim", "")
+	require.NoError(t, err)
+	_, err = svc.LinkPhone(ctx, another.ID, "01712345678", code("01712345678"))
+	assert.ErrorIs(t, err, service.ErrPhoneTaken)
+}
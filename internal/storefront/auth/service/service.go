@@ -3,13 +3,24 @@ package service
 import (
 	"context"
 	"errors"
-	"time"
+	"net/url"
+	"strings"
 
 	"bizbundl/internal/constants"
 	db "bizbundl/internal/db/sqlc"
-	"bizbundl/token"
+	"bizbundl/internal/entitlements"
+	"bizbundl/internal/infra/mailer"
+	"bizbundl/internal/loginguard"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/oauth"
+	"bizbundl/internal/otp"
+	"bizbundl/internal/permissions"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/settings"
+	"bizbundl/internal/tenancy"
 
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,15 +28,36 @@ var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrEmailTaken         = errors.New("email already taken")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrWeakPassword       = errors.New("password must be at least 6 characters")
 )
 
+// minPasswordLength matches the validate tag on RegisterRequest.Password
+const minPasswordLength = 6
+
 type AuthService struct {
-	store      db.DBStore
-	tokenMaker token.Maker
+	store        db.DBStore
+	sessions     *sessions.Manager
+	mailer       mailer.Mailer
+	otp          *otp.Service
+	twoFactor    *mfa.Service
+	logins       *loginguard.Guard
+	oauth        *oauth.Client
+	settings     *settings.Settings
+	entitlements *entitlements.Service
+	handoffs     *Handoffs
+	permissions  *permissions.Resolver
 }
 
-func NewAuthService(store db.DBStore, tokenMaker token.Maker) *AuthService {
-	return &AuthService{store: store, tokenMaker: tokenMaker}
+// NewAuthService builds the shop's auth service. mailer delivers password
+// reset and verification links; otp texts phone sign-in codes; twoFactor
+// checks staff authenticator codes (see NewTwoFactorStore); logins limits
+// password guessing; oauth signs customers in with the providers the shop
+// configures (see SocialLogin); handoffs verifies the tokens the platform
+// dashboard signs to send owners and team members into the shop (see
+// Handoff); permissions is told when a user's role changes.
+func NewAuthService(store db.DBStore, sessions *sessions.Manager, mailer mailer.Mailer, otp *otp.Service, twoFactor *mfa.Service, logins *loginguard.Guard, oauth *oauth.Client, entitlements *entitlements.Service, handoffs *Handoffs, permissions *permissions.Resolver) *AuthService {
+	return &AuthService{store: store, sessions: sessions, mailer: mailer, otp: otp, twoFactor: twoFactor, logins: logins, oauth: oauth, settings: settings.NewSettings(store), entitlements: entitlements, handoffs: handoffs, permissions: permissions}
 }
 
 // hashPassword generates a bcrypt hash of the password
@@ -78,7 +110,7 @@ func (s *AuthService) Register(ctx context.Context, email, password, fullName, p
 	// (TODO: Better splitting logic or API update)
 
 	user, err := s.store.CreateUser(ctx, db.CreateUserParams{
-		Email:        email,
+		Email:        &email,
 		PasswordHash: hashed,
 		FirstName:    firstName,
 		LastName:     lastName,
@@ -93,34 +125,148 @@ func (s *AuthService) Register(ctx context.Context, email, password, fullName, p
 	return user, nil
 }
 
-// Login verifies credentials and returns a Session Token
-func (s *AuthService) Login(ctx context.Context, email, password string) (string, db.User, error) {
+// CreateStaff creates an admin or staff account, within the plan's staff limit
+func (s *AuthService) CreateStaff(ctx context.Context, email, password, firstName, lastName string, role db.UserRole) (db.User, error) {
+	if !isStaffRole(role) {
+		return db.User{}, ErrInvalidRole
+	}
+	if err := s.entitlements.Check(ctx, entitlements.Staff, 1); err != nil {
+		return db.User{}, err
+	}
+
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return db.User{}, err
+	}
+	return s.store.CreateUser(ctx, db.CreateUserParams{
+		Email:        &email,
+		PasswordHash: hashed,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Role:         role,
+	})
+}
+
+// SetRole changes a user's role. Promoting a customer counts against the
+// plan's staff limit.
+func (s *AuthService) SetRole(ctx context.Context, id pgtype.UUID, role db.UserRole) (db.User, error) {
+	if !isStaffRole(role) && role != db.UserRoleCustomer {
+		return db.User{}, ErrInvalidRole
+	}
+	user, err := s.store.GetUserById(ctx, id)
+	if err != nil {
+		return db.User{}, ErrUserNotFound
+	}
+	if isStaffRole(role) && !isStaffRole(user.Role) {
+		if err := s.entitlements.Check(ctx, entitlements.Staff, 1); err != nil {
+			return db.User{}, err
+		}
+	}
+	updated, err := s.store.UpdateUser(ctx, db.UpdateUserParams{
+		ID:   id,
+		Role: db.NullUserRole{UserRole: role, Valid: true},
+	})
+	if err != nil {
+		return db.User{}, err
+	}
+	if tenant := tenancy.FromContext(ctx); tenant != nil {
+		s.permissions.Invalidate(ctx, tenant.TenantID, updated.ID.String())
+	}
+	return updated, nil
+}
+
+func isStaffRole(role db.UserRole) bool {
+	return role == db.UserRoleAdmin || role == db.UserRoleStaff
+}
+
+// Login verifies credentials and returns the new session's tokens. Accounts
+// with two-factor authentication get a *mfa.ChallengeError instead, to
+// finish with LoginTwoFactor. Too many failures get a
+// *loginguard.LimitError before the password is even checked; the one that
+// locks the account emails it an unlock link on baseURL, the shop's origin.
+func (s *AuthService) Login(ctx context.Context, email, password, baseURL string) (sessions.Tokens, db.User, error) {
+	if err := s.logins.Check(ctx, email); err != nil {
+		return sessions.Tokens{}, db.User{}, err
+	}
+
 	user, err := s.store.GetUserByEmail(ctx, email)
 	if err != nil {
 		// Avoid leaking if user exists or not, but for MVP standard error
-		return "", db.User{}, ErrInvalidCredentials
+		return sessions.Tokens{}, db.User{}, s.loginFailed(ctx, email, nil, baseURL)
 	}
 
 	if !verifyPassword(password, user.PasswordHash) {
-		return "", db.User{}, ErrInvalidCredentials
+		return sessions.Tokens{}, db.User{}, s.loginFailed(ctx, email, &user, baseURL)
+	}
+	s.logins.Succeed(ctx, email)
+	if err := s.twoFactor.Challenge(ctx, user.ID); err != nil {
+		return sessions.Tokens{}, db.User{}, err
+	}
+
+	// Stateless access token (Paseto) plus a refresh token, bound to a revocable session
+	tokens, _, err := s.sessions.Issue(ctx, user.ID.String(), string(user.Role))
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, err
 	}
 
-	// Create Stateless Token (Paseto)
-	// Use defined constant for User Session
-	duration := constants.UserSessionDuration
-	token, _, err := s.tokenMaker.CreateToken(user.ID.String(), string(user.Role), duration)
+	return tokens, user, nil
+}
+
+// loginFailed counts a failed login and returns ErrInvalidCredentials. If
+// that locks the account, user (nil for unknown emails, which are locked
+// all the same) is emailed a link that unlocks it.
+func (s *AuthService) loginFailed(ctx context.Context, email string, user *db.User, baseURL string) error {
+	secret, err := s.logins.Fail(ctx, email)
 	if err != nil {
-		return "", db.User{}, err
+		return err
+	}
+	if secret != "" && user != nil && user.Email != nil {
+		link := baseURL + UnlockAccountPath + "?token=" + url.QueryEscape(secret)
+		if err := s.mailer.Send(ctx, mailer.AccountLocked(*user.Email, link)); err != nil {
+			log.Error().Err(err).Str("user", user.ID.String()).Msg("failed to send account locked email")
+		}
 	}
+	return ErrInvalidCredentials
+}
 
-	// We can also creating a DB session for Revocation List (optional but safer)
-	// For "Super Efficiency" requested by user, we might skip or do async.
-	// The previous implementation did DB.
-	// Note: If we use Paseto self-contained, we don't NEED the db session ID in the token necessarily,
-	// unless we want to revoke it by ID.
-	// Let's just return the Paseto.
+// Handoff signs in the owner or a team member sent over from the platform
+// dashboard. The token names the shop and the platform user, and whether
+// the platform sign-in passed a second factor; only admin and staff accounts
+// can be signed into this way, and each token only once.
+func (s *AuthService) Handoff(ctx context.Context, handoffToken string) (sessions.Tokens, db.User, error) {
+	// 1. Token, for this shop, unused
+	subject, err := s.handoffs.Consume(ctx, handoffToken)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, ErrInvalidCredentials
+	}
+	tenantID, platformUserID, ok := strings.Cut(subject, "|")
+	platformUserID, twoFactor := strings.CutSuffix(platformUserID, constants.HandoffTwoFactorSuffix)
+	tenant := tenancy.FromContext(ctx)
+	if !ok || tenant == nil || tenant.TenantID != tenantID {
+		return sessions.Tokens{}, db.User{}, ErrInvalidCredentials
+	}
 
-	return token, user, nil
+	// 2. Account, opened for the platform user (never found by email,
+	// which anyone may have registered in the shop)
+	var id pgtype.UUID
+	if err := id.Scan(platformUserID); err != nil {
+		return sessions.Tokens{}, db.User{}, ErrInvalidCredentials
+	}
+	user, err := s.store.GetUserByPlatformID(ctx, id)
+	if err != nil || !isStaffRole(user.Role) {
+		return sessions.Tokens{}, db.User{}, ErrInvalidCredentials
+	}
+
+	// 3. Session
+	issue := s.sessions.Issue
+	if twoFactor {
+		issue = s.sessions.IssueTwoFactor
+	}
+	tokens, _, err := issue(ctx, user.ID.String(), string(user.Role))
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, err
+	}
+	return tokens, user, nil
 }
 
 // GetUser retrieves a user by ID
@@ -132,34 +278,64 @@ func (s *AuthService) GetUser(ctx context.Context, id pgtype.UUID) (db.User, err
 	return user, nil
 }
 
-// VerifySession checks if value exists and is valid
-func (s *AuthService) VerifySession(ctx context.Context, token string) (db.User, error) {
-	session, err := s.store.GetSession(ctx, token)
-	if err != nil {
-		return db.User{}, errors.New("invalid session")
+// Refresh rotates a refresh token for a new token pair. A reused refresh
+// token revokes its session and fails like any other invalid one.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (sessions.Tokens, error) {
+	tokens, _, err := s.sessions.Refresh(ctx, refreshToken)
+	if errors.Is(err, sessions.ErrRevoked) || errors.Is(err, sessions.ErrRefreshReused) {
+		return sessions.Tokens{}, ErrInvalidCredentials
 	}
+	return tokens, err
+}
 
-	if session.ExpiresAt.Time.Before(time.Now()) {
-		// Cleanup expired
-		_ = s.store.DeleteSession(ctx, token)
-		return db.User{}, errors.New("expired session")
+// Logout ends the session the request is signed in with
+func (s *AuthService) Logout(ctx context.Context, userID, sessionID string) error {
+	if sessionID == "" {
+		return nil
 	}
-
-	// UserID is a pgtype.UUID, which might not be valid if it was a guest session
-	// But Login always sets UserID.
-	// If we support Guest Sessions later, we need to handle that.
-	// The previous schema says UserID in Session is nullable (linked to user OR guest).
-	// Wait, the schema says: `user_id UUID REFERENCES users(id)`. It is nullable.
-	// Our Login function sets it.
-	// If it's nil, we can't return a User.
-	if !session.UserID.Valid {
-		return db.User{}, errors.New("guest session")
+	err := s.sessions.Revoke(ctx, userID, sessionID)
+	if errors.Is(err, sessions.ErrNotFound) {
+		return nil
 	}
+	return err
+}
+
+// Sessions lists the user's signed-in devices
+func (s *AuthService) Sessions(ctx context.Context, userID string) ([]sessions.Session, error) {
+	return s.sessions.List(ctx, userID)
+}
+
+// RevokeSession signs one of the user's devices out
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	return s.sessions.Revoke(ctx, userID, sessionID)
+}
+
+// RevokeOtherSessions signs the user out everywhere but the current session
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID, currentSessionID string) (int, error) {
+	return s.sessions.RevokeAll(ctx, userID, currentSessionID)
+}
 
-	user, err := s.store.GetUserById(ctx, session.UserID)
+// ChangePassword replaces the user's password and signs out every other
+// session, so a stolen token stops working once the password is changed.
+func (s *AuthService) ChangePassword(ctx context.Context, id pgtype.UUID, currentPassword, newPassword, currentSessionID string) error {
+	user, err := s.store.GetUserById(ctx, id)
 	if err != nil {
-		return db.User{}, err
+		return ErrUserNotFound
+	}
+	if !verifyPassword(currentPassword, user.PasswordHash) {
+		return ErrInvalidCredentials
+	}
+	if len(newPassword) < minPasswordLength {
+		return ErrWeakPassword
 	}
 
-	return user, nil
+	hashed, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.store.UpdatePassword(ctx, db.UpdatePasswordParams{ID: id, PasswordHash: hashed}); err != nil {
+		return err
+	}
+	_, err = s.sessions.RevokeAll(ctx, user.ID.String(), currentSessionID)
+	return err
 }
@@ -4,18 +4,34 @@ import (
 	"context"
 	"testing"
 
+	platformdb "bizbundl/internal/db/sqlc/platform"
+	"bizbundl/internal/loginguard"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/otp"
+	auditservice "bizbundl/internal/platform/audit/service"
+	"bizbundl/internal/server"
 	"bizbundl/internal/storefront/auth/service"
 	"bizbundl/internal/testutil"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// twoFactor builds the two-factor service the auth service is wired with
+func twoFactor(srv *server.Server) *mfa.Service {
+	return mfa.NewService(service.NewTwoFactorStore(srv.GetDB()), otp.NewMemoryStore(), srv.GetConfig().TokenSymmetricKey)
+}
+
+// loginGuard builds a login guard that forgets failures between tests
+func loginGuard(srv *server.Server) *loginguard.Guard {
+	return loginguard.NewGuard(otp.NewMemoryStore(), auditservice.NewAuditService(platformdb.New(srv.GetDB().GetPool())))
+}
+
 func TestRegister(t *testing.T) {
 	testutil.Cleanup(t)
 	defer testutil.Cleanup(t)
 
 	srv := testutil.SetupTestServer()
-	svc := service.NewAuthService(srv.GetDB(), srv.GetTokenMaker())
+	svc := service.NewAuthService(srv.GetDB(), srv.GetSessions(), srv.GetMailer(), srv.GetOTP(), twoFactor(srv), loginGuard(srv), nil, srv.GetEntitlements(), nil, srv.GetPermissions())
 	ctx := context.Background()
 
 	email := testutil.RandomEmail()
@@ -23,7 +39,7 @@ func TestRegister(t *testing.T) {
 	// Good Case
 	user, err := svc.Register(ctx, email, "password123", "Test User", "1234567890")
 	assert.NoError(t, err)
-	assert.Equal(t, email, user.Email)
+	assert.Equal(t, &email, user.Email)
 	assert.NotEmpty(t, user.PasswordHash)
 
 	// Duplicate Email
@@ -36,23 +52,49 @@ func TestLogin(t *testing.T) {
 	defer testutil.Cleanup(t)
 
 	srv := testutil.SetupTestServer()
-	svc := service.NewAuthService(srv.GetDB(), srv.GetTokenMaker())
+	svc := service.NewAuthService(srv.GetDB(), srv.GetSessions(), srv.GetMailer(), srv.GetOTP(), twoFactor(srv), loginGuard(srv), nil, srv.GetEntitlements(), nil, srv.GetPermissions())
 	ctx := context.Background()
 
 	email := testutil.RandomEmail()
 	_, _ = svc.Register(ctx, email, "securepass", "Login User", "111")
 
 	// correct login
-	token, user, err := svc.Login(ctx, email, "securepass")
+	token, user, err := svc.Login(ctx, email, "securepass", "")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
-	assert.Equal(t, email, user.Email)
+	assert.Equal(t, &email, user.Email)
 
 	// wrong password
-	_, _, err = svc.Login(ctx, email, "wrongpass")
+	_, _, err = svc.Login(ctx, email, "wrongpass", "")
 	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
 
 	// non-existent user
-	_, _, err = svc.Login(ctx, "ghost@example.com", "any")
+	_, _, err = svc.Login(ctx, "ghost@example.com", "any", "")
 	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
 }
+
+func TestLoginThrottled(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	svc := service.NewAuthService(srv.GetDB(), srv.GetSessions(), srv.GetMailer(), srv.GetOTP(), twoFactor(srv), loginGuard(srv), nil, srv.GetEntitlements(), nil, srv.GetPermissions())
+	ctx := context.Background()
+
+	email := testutil.RandomEmail()
+	_, err := svc.Register(ctx, email, "securepass", "Test User", "123")
+	assert.NoError(t, err)
+
+	// A few free failures, then a wait
+	for i := 0; i < 4; i++ {
+		_, _, err = svc.Login(ctx, email, "wrongpass", "")
+		assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	}
+
+	// Even the right password waits, it is not checked
+	_, _, err = svc.Login(ctx, email, "securepass", "")
+	assert.ErrorIs(t, err, loginguard.ErrThrottled)
+	var limit *loginguard.LimitError
+	assert.ErrorAs(t, err, &limit)
+	assert.Positive(t, limit.RetryAfter)
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/oauth"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// A shop turns a provider on by saving its client credentials in
+	// store_configs, encrypted, under oauth.{provider}
+	socialConfigGroup  = "oauth"
+	socialConfigPrefix = "oauth."
+
+	// SocialLoginPath starts a sign-in at {SocialLoginPath}{provider}; the
+	// provider sends the customer back to its SocialCallbackPath, which
+	// shops register with the provider
+	SocialLoginPath    = "/auth/oauth/"
+	SocialCallbackPath = "/callback"
+
+	// Column sizes of users.first_name and last_name
+	maxNameLength = 50
+)
+
+var (
+	ErrUnknownProvider    = errors.New("this sign-in method is not available")
+	ErrInvalidProvider    = errors.New("provider names are lowercase letters, digits and dashes; custom providers need an https issuer")
+	ErrSocialStaff        = errors.New("staff accounts sign in with their password")
+	ErrSocialEmailTaken   = errors.New("an account already uses this email; sign in with your password")
+	providerName          = regexp.MustCompile(`^[a-z0-9-]{1,30}$`)
+	errSocialStateTenants = errors.New("sign-in was started in another shop")
+)
+
+// SocialProvider is a provider's configuration in the shop. ClientSecret is
+// write-only: it is never returned by SocialProviders.
+type SocialProvider struct {
+	Name         string `json:"-"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// Issuer of a custom OpenID Connect provider; empty for oauth.Presets
+	Issuer  string `json:"issuer,omitempty"`
+	Enabled bool   `json:"enabled"`
+	// Configured says a client secret is saved
+	Configured bool `json:"-"`
+}
+
+// SocialProviders lists the providers the shop has configured, without
+// their secrets
+func (s *AuthService) SocialProviders(ctx context.Context) ([]SocialProvider, error) {
+	keys, err := s.settings.ListConfigKeys(ctx, socialConfigGroup)
+	if err != nil {
+		return nil, err
+	}
+	providers := make([]SocialProvider, 0, len(keys))
+	for _, key := range keys {
+		p, err := s.socialProvider(ctx, strings.TrimPrefix(key, socialConfigPrefix))
+		if err != nil {
+			return nil, err
+		}
+		p.Configured, p.ClientSecret = p.ClientSecret != "", ""
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// ConfigureSocialProvider saves a provider's settings and returns them
+// without the secret. An empty client secret keeps the saved one.
+func (s *AuthService) ConfigureSocialProvider(ctx context.Context, p SocialProvider) (SocialProvider, error) {
+	_, preset := oauth.Presets[p.Name]
+	if !providerName.MatchString(p.Name) || (!preset && !strings.HasPrefix(p.Issuer, "https://")) {
+		return SocialProvider{}, ErrInvalidProvider
+	}
+	if preset {
+		p.Issuer = ""
+	} else if _, err := url.Parse(p.Issuer); err != nil {
+		return SocialProvider{}, ErrInvalidProvider
+	}
+	if p.ClientSecret == "" {
+		saved, err := s.socialProvider(ctx, p.Name)
+		if err != nil && !errors.Is(err, ErrUnknownProvider) {
+			return SocialProvider{}, err
+		}
+		p.ClientSecret = saved.ClientSecret
+	}
+
+	value, err := json.Marshal(p)
+	if err != nil {
+		return SocialProvider{}, err
+	}
+	if err := s.settings.SetConfig(ctx, socialConfigPrefix+p.Name, string(value), socialConfigGroup, true); err != nil {
+		return SocialProvider{}, err
+	}
+	p.Configured, p.ClientSecret = p.ClientSecret != "", ""
+	return p, nil
+}
+
+// SocialLoginURL starts a sign-in with provider. It returns the provider
+// URL to send the customer to and the state to keep in a cookie for
+// SocialLogin. baseURL is the shop's origin; next is where to go after.
+func (s *AuthService) SocialLoginURL(ctx context.Context, provider, baseURL, next string) (authURL, state string, err error) {
+	p, err := s.enabledProvider(ctx, provider)
+	if err != nil {
+		return "", "", err
+	}
+	return s.oauth.Begin(ctx, p, socialRedirectURI(baseURL, provider), tenantID(ctx)+"|"+next)
+}
+
+// SocialLogin finishes a sign-in started by SocialLoginURL and returns the
+// new session's tokens and where to go next. The identity signs in the
+// customer it was linked to before; otherwise a customer account with the
+// same, provider-verified email; otherwise a new passwordless customer.
+func (s *AuthService) SocialLogin(ctx context.Context, provider, baseURL, state string, cb oauth.Callback) (sessions.Tokens, db.User, string, error) {
+	p, err := s.enabledProvider(ctx, provider)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, "", err
+	}
+	identity, data, err := s.oauth.Finish(ctx, p, socialRedirectURI(baseURL, provider), state, cb)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, "", err
+	}
+	scope, next, _ := strings.Cut(data, "|")
+	if scope != tenantID(ctx) {
+		return sessions.Tokens{}, db.User{}, "", errSocialStateTenants
+	}
+
+	user, err := s.socialUser(ctx, provider, identity)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, "", err
+	}
+	tokens, _, err := s.sessions.Issue(ctx, user.ID.String(), string(user.Role))
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, "", err
+	}
+	return tokens, user, next, nil
+}
+
+// socialUser finds or creates the customer identity signs in
+func (s *AuthService) socialUser(ctx context.Context, provider string, identity oauth.Identity) (db.User, error) {
+	var email *string
+	if identity.EmailVerified {
+		email = &identity.Email
+	}
+
+	// 1. Linked before
+	user, err := s.store.GetUserByIdentity(ctx, db.GetUserByIdentityParams{Provider: provider, Subject: identity.Subject})
+	if err == nil {
+		if isStaffRole(user.Role) {
+			return db.User{}, ErrSocialStaff
+		}
+		return user, s.store.TouchUserIdentity(ctx, db.TouchUserIdentityParams{Provider: provider, Subject: identity.Subject, Email: email})
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return db.User{}, err
+	}
+
+	// 2. An account with the address. Only an address verified on both
+	// sides proves it is theirs: whoever registered an unverified one with
+	// a password would keep signing in to it. Staff keep their password and
+	// second factor.
+	if identity.Email != "" {
+		user, err = s.store.GetUserByEmail(ctx, identity.Email)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+		case err != nil:
+			return db.User{}, err
+		case !identity.EmailVerified, !user.EmailVerifiedAt.Valid:
+			return db.User{}, ErrSocialEmailTaken
+		case isStaffRole(user.Role):
+			return db.User{}, ErrSocialStaff
+		default:
+			return user, s.linkIdentity(ctx, user.ID, provider, identity.Subject, email)
+		}
+	}
+
+	// 3. New customer
+	params := db.CreateSocialUserParams{
+		Email:     email,
+		FirstName: truncate(identity.FirstName, maxNameLength),
+		LastName:  truncate(identity.LastName, maxNameLength),
+	}
+	if email != nil {
+		params.EmailVerifiedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	}
+	user, err = s.store.CreateSocialUser(ctx, params)
+	if err != nil {
+		return db.User{}, err
+	}
+	return user, s.linkIdentity(ctx, user.ID, provider, identity.Subject, email)
+}
+
+func (s *AuthService) linkIdentity(ctx context.Context, userID pgtype.UUID, provider, subject string, email *string) error {
+	return s.store.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+}
+
+// socialProvider reads a provider's settings
+func (s *AuthService) socialProvider(ctx context.Context, name string) (SocialProvider, error) {
+	value, err := s.settings.GetConfig(ctx, socialConfigPrefix+name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SocialProvider{}, ErrUnknownProvider
+	}
+	if err != nil {
+		return SocialProvider{}, err
+	}
+	p := SocialProvider{Name: name}
+	if err := json.Unmarshal([]byte(value), &p); err != nil {
+		return SocialProvider{}, err
+	}
+	return p, nil
+}
+
+// enabledProvider is the oauth.Provider of an enabled provider
+func (s *AuthService) enabledProvider(ctx context.Context, name string) (oauth.Provider, error) {
+	cfg, err := s.socialProvider(ctx, name)
+	if err != nil {
+		return oauth.Provider{}, err
+	}
+	if !cfg.Enabled {
+		return oauth.Provider{}, ErrUnknownProvider
+	}
+	p, ok := oauth.Presets[name]
+	if !ok {
+		p = oauth.Provider{Name: name, Issuer: cfg.Issuer}
+	}
+	p.ClientID, p.ClientSecret = cfg.ClientID, cfg.ClientSecret
+	return p, nil
+}
+
+// socialRedirectURI is where provider sends the customer back to
+func socialRedirectURI(baseURL, provider string) string {
+	return baseURL + SocialLoginPath + provider + SocialCallbackPath
+}
+
+// tenantID is the shop the request is for
+func tenantID(ctx context.Context) string {
+	if tenant := tenancy.FromContext(ctx); tenant != nil {
+		return tenant.TenantID
+	}
+	return ""
+}
+
+// truncate cuts s to at most n characters
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/oauth"
+	"bizbundl/internal/oauth/oauthtest"
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const shopURL = "https://shop.example.com"
+
+func newSocialService(t *testing.T, idp *oauthtest.Server) *service.AuthService {
+	srv := testutil.SetupTestServer()
+	social := oauth.NewClient(srv.GetConfig().TokenSymmetricKey, idp.Client())
+	svc := service.NewAuthService(srv.GetDB(), srv.GetSessions(), srv.GetMailer(), srv.GetOTP(), twoFactor(srv), loginGuard(srv), social, srv.GetEntitlements(), nil, srv.GetPermissions())
+
+	_, err := svc.ConfigureSocialProvider(context.Background(), service.SocialProvider{
+		Name:         "idp",
+		ClientID:     oauthtest.ClientID,
+		ClientSecret: oauthtest.ClientSecret,
+		Issuer:       idp.URL,
+		Enabled:      true,
+	})
+	require.NoError(t, err)
+	return svc
+}
+
+// signIn goes through the provider as idp.User
+func signIn(t *testing.T, svc *service.AuthService, idp *oauthtest.Server, next string) (db.User, string, error) {
+	ctx := context.Background()
+	authURL, state, err := svc.SocialLoginURL(ctx, "idp", shopURL, next)
+	require.NoError(t, err)
+	callback, err := idp.Authorize(authURL)
+	require.NoError(t, err)
+	tokens, user, next, err := svc.SocialLogin(ctx, "idp", shopURL, state, callback)
+	if err == nil {
+		assert.NotEmpty(t, tokens.Access)
+	}
+	return user, next, err
+}
+
+func TestSocialLogin(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	idp := oauthtest.NewServer()
+	defer idp.Close()
+	svc := newSocialService(t, idp)
+	email := testutil.RandomEmail()
+	idp.User["sub"], idp.User["email"] = testutil.RandomString(12), email
+
+	// First sign-in creates a customer
+	user, next, err := signIn(t, svc, idp, "/checkout")
+	require.NoError(t, err)
+	assert.Equal(t, "/checkout", next)
+	assert.Equal(t, &email, user.Email)
+	assert.Equal(t, db.UserRoleCustomer, user.Role)
+	assert.Equal(t, "Casey", user.FirstName)
+	assert.True(t, user.EmailVerifiedAt.Valid, "the provider verified it")
+
+	// The identity signs in the same customer, whatever the address now is
+	idp.User["email"] = testutil.RandomEmail()
+	again, _, err := signIn(t, svc, idp, "")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+}
+
+func TestSocialLoginLinksByEmail(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	idp := oauthtest.NewServer()
+	defer idp.Close()
+	svc := newSocialService(t, idp)
+	store := testutil.SetupTestServer().GetDB()
+	ctx := context.Background()
+
+	email := testutil.RandomEmail()
+	registered, err := svc.Register(ctx, email, "securepass", "Casey", "")
+	require.NoError(t, err)
+
+	// An unverified address proves nothing
+	idp.User["sub"], idp.User["email"], idp.User["email_verified"] = testutil.RandomString(12), email, false
+	_, _, err = signIn(t, svc, idp, "")
+	assert.ErrorIs(t, err, service.ErrSocialEmailTaken)
+
+	// Nor does a verified one, while the account's address is unverified:
+	// whoever registered it would keep its password
+	idp.User["email_verified"] = true
+	_, _, err = signIn(t, svc, idp, "")
+	assert.ErrorIs(t, err, service.ErrSocialEmailTaken)
+
+	// Verified on both sides, it links the account
+	require.NoError(t, store.MarkEmailVerified(ctx, registered.ID))
+	user, _, err := signIn(t, svc, idp, "")
+	require.NoError(t, err)
+	assert.Equal(t, registered.ID, user.ID)
+
+	// Not staff accounts
+	staff := testutil.RandomEmail()
+	_, err = svc.CreateStaff(ctx, staff, "securepass", "Sam", "Staff", db.UserRoleStaff)
+	require.NoError(t, err)
+	idp.User["sub"], idp.User["email"] = testutil.RandomString(12), staff
+	_, _, err = signIn(t, svc, idp, "")
+	assert.ErrorIs(t, err, service.ErrSocialStaff)
+}
+
+func TestSocialProviders(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	idp := oauthtest.NewServer()
+	defer idp.Close()
+	svc := newSocialService(t, idp)
+	ctx := context.Background()
+
+	// The secret is kept when left out, and never listed
+	p, err := svc.ConfigureSocialProvider(ctx, service.SocialProvider{Name: "idp", ClientID: oauthtest.ClientID, Issuer: idp.URL})
+	require.NoError(t, err)
+	assert.True(t, p.Configured)
+	providers, err := svc.SocialProviders(ctx)
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Empty(t, providers[0].ClientSecret)
+	assert.False(t, providers[0].Enabled)
+
+	_, _, err = svc.SocialLoginURL(ctx, "idp", shopURL, "")
+	assert.ErrorIs(t, err, service.ErrUnknownProvider, "disabled")
+
+	_, err = svc.ConfigureSocialProvider(ctx, service.SocialProvider{Name: "custom", Issuer: "http://idp.example.com"})
+	assert.ErrorIs(t, err, service.ErrInvalidProvider, "issuers must be https")
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/sessions"
+	"bizbundl/internal/tenancy"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// defaultIssuer names the account in authenticator apps outside a shop
+const defaultIssuer = "BizBundl"
+
+var (
+	ErrTwoFactorStaffOnly = errors.New("two-factor authentication is for staff accounts")
+	ErrTwoFactorEnforced  = errors.New("this shop requires two-factor authentication")
+)
+
+// twoFactorStore keeps two-factor state on the shop's users
+type twoFactorStore struct {
+	store db.DBStore
+}
+
+// NewTwoFactorStore returns the mfa.Store of the shop's staff accounts
+func NewTwoFactorStore(store db.DBStore) mfa.Store {
+	return &twoFactorStore{store: store}
+}
+
+func (t *twoFactorStore) GetFactor(ctx context.Context, userID pgtype.UUID) (mfa.Factor, error) {
+	row, err := t.store.GetUserTwoFactor(ctx, userID)
+	if err != nil {
+		return mfa.Factor{}, err
+	}
+	factor := mfa.Factor{
+		Enabled:           row.TotpEnabledAt.Valid,
+		LastStep:          row.TotpLastStep,
+		RecoveryCodesLeft: row.RecoveryCodesLeft,
+	}
+	if row.TotpSecret != nil {
+		factor.Secret = *row.TotpSecret
+	}
+	return factor, nil
+}
+
+func (t *twoFactorStore) SetSecret(ctx context.Context, userID pgtype.UUID, secret string) error {
+	return t.store.SetTOTPSecret(ctx, db.SetTOTPSecretParams{ID: userID, TotpSecret: &secret})
+}
+
+func (t *twoFactorStore) Enable(ctx context.Context, userID pgtype.UUID, step int64) error {
+	return t.store.EnableTOTP(ctx, db.EnableTOTPParams{ID: userID, TotpLastStep: step})
+}
+
+func (t *twoFactorStore) Disable(ctx context.Context, userID pgtype.UUID) error {
+	return t.store.DisableTOTP(ctx, userID)
+}
+
+func (t *twoFactorStore) UseStep(ctx context.Context, userID pgtype.UUID, step int64) (bool, error) {
+	n, err := t.store.UseTOTPStep(ctx, db.UseTOTPStepParams{ID: userID, TotpLastStep: step})
+	return n == 1, err
+}
+
+func (t *twoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID pgtype.UUID, hashes []string) error {
+	return t.store.ReplaceRecoveryCodes(ctx, db.ReplaceRecoveryCodesParams{UserID: userID, CodeHashes: hashes})
+}
+
+func (t *twoFactorStore) UseRecoveryCode(ctx context.Context, userID pgtype.UUID, hash string) (bool, error) {
+	n, err := t.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{UserID: userID, CodeHash: hash})
+	return n == 1, err
+}
+
+// LoginTwoFactor completes a sign-in that Login answered with a
+// *mfa.ChallengeError, with a code from the authenticator or a recovery code
+func (s *AuthService) LoginTwoFactor(ctx context.Context, challenge, code string) (sessions.Tokens, db.User, error) {
+	userID, err := s.twoFactor.CompleteChallenge(ctx, challenge, code)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, err
+	}
+	user, err := s.store.GetUserById(ctx, userID)
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, ErrInvalidCredentials
+	}
+	tokens, _, err := s.sessions.IssueTwoFactor(ctx, user.ID.String(), string(user.Role))
+	if err != nil {
+		return sessions.Tokens{}, db.User{}, err
+	}
+	return tokens, user, nil
+}
+
+// TwoFactorStatus returns the user's two-factor state
+func (s *AuthService) TwoFactorStatus(ctx context.Context, userID pgtype.UUID) (mfa.Factor, error) {
+	return s.twoFactor.Status(ctx, userID)
+}
+
+// SetupTwoFactor starts enrolling a staff account; the enrollment is turned
+// on by EnableTwoFactor
+func (s *AuthService) SetupTwoFactor(ctx context.Context, userID pgtype.UUID) (mfa.Enrollment, error) {
+	user, err := s.store.GetUserById(ctx, userID)
+	if err != nil {
+		return mfa.Enrollment{}, ErrUserNotFound
+	}
+	// Customers sign in with phone codes too, which would skip the factor
+	if !isStaffRole(user.Role) {
+		return mfa.Enrollment{}, ErrTwoFactorStaffOnly
+	}
+
+	issuer := defaultIssuer
+	if tenant := tenancy.FromContext(ctx); tenant != nil && tenant.Subdomain != "" {
+		issuer = tenant.Subdomain
+	}
+	var account string
+	if user.Email != nil {
+		account = *user.Email
+	}
+	return s.twoFactor.Setup(ctx, userID, issuer, account)
+}
+
+// EnableTwoFactor finishes enrollment and returns the recovery codes. The
+// current session counts as having passed the second factor.
+func (s *AuthService) EnableTwoFactor(ctx context.Context, userID pgtype.UUID, sessionID, code string) ([]string, error) {
+	codes, err := s.twoFactor.Enable(ctx, userID, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.StepUp(ctx, userID.String(), sessionID, true); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off, unless the shop
+// requires it
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID pgtype.UUID) error {
+	if tenant := tenancy.FromContext(ctx); tenant != nil && tenant.RequireTwoFactor {
+		return ErrTwoFactorEnforced
+	}
+	return s.twoFactor.Disable(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID pgtype.UUID) ([]string, error) {
+	return s.twoFactor.RegenerateRecoveryCodes(ctx, userID)
+}
+
+// StepUp re-authenticates the signed-in user for sensitive actions (see
+// middleware.RequireStepUp): with a code when they have two-factor
+// authentication enabled, with their password otherwise
+func (s *AuthService) StepUp(ctx context.Context, userID pgtype.UUID, sessionID, password, code string) error {
+	user, err := s.store.GetUserById(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	factor, err := s.twoFactor.Status(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if factor.Enabled {
+		if err := s.twoFactor.Verify(ctx, userID, code); err != nil {
+			return err
+		}
+	} else if !verifyPassword(password, user.PasswordHash) {
+		return ErrInvalidCredentials
+	}
+	return s.sessions.StepUp(ctx, userID.String(), sessionID, factor.Enabled)
+}
@@ -0,0 +1,69 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/storefront/auth/service"
+	"bizbundl/internal/tenancy"
+	"bizbundl/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactor(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	svc := service.NewAuthService(srv.GetDB(), srv.GetSessions(), srv.GetMailer(), srv.GetOTP(), twoFactor(srv), loginGuard(srv), nil, srv.GetEntitlements(), nil, srv.GetPermissions())
+	ctx := context.Background()
+
+	email := testutil.RandomEmail()
+	user, err := svc.Register(ctx, email, "securepass", "Staff User", "")
+	require.NoError(t, err)
+
+	// Customers cannot enroll
+	_, err = svc.SetupTwoFactor(ctx, user.ID)
+	assert.ErrorIs(t, err, service.ErrTwoFactorStaffOnly)
+
+	_, err = srv.GetDB().UpdateUser(ctx, db.UpdateUserParams{ID: user.ID, Role: db.NullUserRole{UserRole: db.UserRoleStaff, Valid: true}})
+	require.NoError(t, err)
+	_, session, err := srv.GetSessions().Issue(ctx, user.ID.String(), "staff")
+	require.NoError(t, err)
+
+	enrollment, err := svc.SetupTwoFactor(ctx, user.ID)
+	require.NoError(t, err)
+	code, err := mfa.Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	recovery, err := svc.EnableTwoFactor(ctx, user.ID, session.ID, code)
+	require.NoError(t, err)
+
+	// Enabling counts as passing the second factor in this session
+	current, err := srv.GetSessions().Get(ctx, user.ID.String(), session.ID)
+	require.NoError(t, err)
+	assert.True(t, current.TwoFactor)
+
+	// Login asks for the code
+	_, _, err = svc.Login(ctx, email, "securepass", "")
+	var challenge *mfa.ChallengeError
+	require.ErrorAs(t, err, &challenge)
+	_, got, err := svc.LoginTwoFactor(ctx, challenge.Challenge, recovery[0])
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+
+	// Step-up wants the code, not the password
+	assert.ErrorIs(t, svc.StepUp(ctx, user.ID, session.ID, "securepass", ""), mfa.ErrInvalidCode)
+	assert.NoError(t, svc.StepUp(ctx, user.ID, session.ID, "", recovery[1]))
+
+	// Shops that require it do not let staff turn it off
+	required := context.WithValue(ctx, tenancy.ContextKey, &tenancy.Tenant{TenantID: tenancy.PublicSchema, RequireTwoFactor: true})
+	assert.ErrorIs(t, svc.DisableTwoFactor(required, user.ID), service.ErrTwoFactorEnforced)
+	require.NoError(t, svc.DisableTwoFactor(ctx, user.ID))
+	_, _, err = svc.Login(ctx, email, "securepass", "")
+	assert.NoError(t, err)
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/mfa"
+	"bizbundl/internal/otp"
 	authservice "bizbundl/internal/storefront/auth/service"
 	"bizbundl/internal/storefront/cart/service"
 	catalogservice "bizbundl/internal/storefront/catalog/service"
@@ -24,7 +26,7 @@ func TestAddToCart(t *testing.T) {
 	srv := testutil.SetupTestServer()
 	store := srv.GetDB()
 	cartSvc := service.NewCartService(store)
-	catalogSvc := catalogservice.NewCatalogService(store)
+	catalogSvc := catalogservice.NewCatalogService(store, srv.GetEntitlements())
 	ctx := context.Background()
 
 	// Setup Product
@@ -67,6 +69,43 @@ func TestAddToCart(t *testing.T) {
 	assert.Equal(t, sessionID, c.SessionID)
 }
 
+func TestAddVariantToCart(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	store := srv.GetDB()
+	cartSvc := service.NewCartService(store)
+	catalogSvc := catalogservice.NewCatalogService(store, srv.GetEntitlements())
+	ctx := context.Background()
+
+	p, err := catalogSvc.CreateProduct(ctx, catalogservice.CreateProductParams{Title: "Item 1", BasePrice: 100})
+	require.NoError(t, err)
+	shirt, err := catalogSvc.CreateProduct(ctx, catalogservice.CreateProductParams{Title: "Shirt", BasePrice: 20})
+	require.NoError(t, err)
+	_, err = catalogSvc.CreateOption(ctx, shirt.ID, "Size", []string{"S", "M"})
+	require.NoError(t, err)
+
+	s, err := store.CreateSession(ctx, db.CreateSessionParams{
+		Token:     uuid.New().String(),
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	require.NoError(t, err)
+	sessionID := s.ID
+
+	// Products with options are added as one of their variants
+	_, err = cartSvc.AddToCart(ctx, sessionID, pgtype.UUID{}, shirt.ID, pgtype.UUID{}, 1)
+	assert.ErrorIs(t, err, service.ErrVariantRequired)
+
+	variant, err := catalogSvc.ResolveVariant(ctx, shirt.ID, map[string]string{"Size": "M"})
+	require.NoError(t, err)
+	_, err = cartSvc.AddToCart(ctx, sessionID, pgtype.UUID{}, p.ID, variant.ID, 1)
+	assert.ErrorIs(t, err, service.ErrVariantUnavailable, "another product's")
+	item, err := cartSvc.AddToCart(ctx, sessionID, pgtype.UUID{}, shirt.ID, variant.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, variant.ID, item.VariantID)
+}
+
 func TestMergeCarts(t *testing.T) {
 	testutil.Cleanup(t)
 	defer testutil.Cleanup(t)
@@ -74,8 +113,8 @@ func TestMergeCarts(t *testing.T) {
 	srv := testutil.SetupTestServer()
 	store := srv.GetDB()
 	cartSvc := service.NewCartService(store)
-	catalogSvc := catalogservice.NewCatalogService(store)
-	authSvc := authservice.NewAuthService(store, srv.GetTokenMaker())
+	catalogSvc := catalogservice.NewCatalogService(store, srv.GetEntitlements())
+	authSvc := authservice.NewAuthService(store, srv.GetSessions(), srv.GetMailer(), srv.GetOTP(), mfa.NewService(authservice.NewTwoFactorStore(store), otp.NewMemoryStore(), srv.GetConfig().TokenSymmetricKey), srv.GetLoginGuard(), nil, srv.GetEntitlements(), nil, srv.GetPermissions())
 	ctx := context.Background()
 
 	// Product
@@ -99,7 +138,7 @@ func TestMergeCarts(t *testing.T) {
 	// User Login
 	email := testutil.RandomEmail()
 	_, _ = authSvc.Register(ctx, email, "pass", "User", "123")
-	_, user, _ := authSvc.Login(ctx, email, "pass")
+	_, user, _ := authSvc.Login(ctx, email, "pass", "")
 
 	// Merge
 	err = cartSvc.MergeCarts(ctx, sess.ID, user.ID)
@@ -4,6 +4,7 @@ import (
 	"bizbundl/internal/storefront/cart/service"
 	"bizbundl/internal/views/components/ui"
 	"bizbundl/util"
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -81,6 +82,7 @@ func (h *CartHandler) GetCart(c *fiber.Ctx) error {
 
 type AddItemRequest struct {
 	ProductID string `json:"product_id" form:"product_id"`
+	VariantID string `json:"variant_id" form:"variant_id"` // Optional, see the product page's variant picker
 	Quantity  int    `json:"quantity" form:"quantity"`
 }
 
@@ -99,12 +101,22 @@ func (h *CartHandler) AddItem(c *fiber.Ctx) error {
 		return util.APIError(c, fiber.StatusBadRequest, fmt.Errorf("invalid product id"))
 	}
 
+	var vID pgtype.UUID
+	if req.VariantID != "" {
+		if err := vID.Scan(req.VariantID); err != nil {
+			return util.APIError(c, fiber.StatusBadRequest, fmt.Errorf("invalid variant id"))
+		}
+	}
+
 	// Default quantity
 	if req.Quantity <= 0 {
 		req.Quantity = 1
 	}
 
-	item, err := h.service.AddToCart(c.Context(), sessID, userID, pID, pgtype.UUID{}, int32(req.Quantity))
+	item, err := h.service.AddToCart(c.Context(), sessID, userID, pID, vID, int32(req.Quantity))
+	if errors.Is(err, service.ErrVariantUnavailable) || errors.Is(err, service.ErrVariantRequired) {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
 	if err != nil {
 		return util.APIError(c, fiber.StatusInternalServerError, err)
 	}
@@ -11,6 +11,13 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// ErrVariantUnavailable is for variants of another product, archived or
+// inactive ones
+var ErrVariantUnavailable = errors.New("this variant is not available")
+
+// ErrVariantRequired is for products with options added without a variant
+var ErrVariantRequired = errors.New("choose the product's options")
+
 type CartService struct {
 	store db.DBStore
 }
@@ -51,6 +58,27 @@ func (s *CartService) GetOrCreateCart(ctx context.Context, sessionID pgtype.UUID
 }
 
 func (s *CartService) AddToCart(ctx context.Context, sessionID pgtype.UUID, userID pgtype.UUID, productID pgtype.UUID, variantID pgtype.UUID, qty int32) (db.CartItem, error) {
+	if variantID.Valid {
+		v, err := s.store.GetProductVariant(ctx, variantID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.CartItem{}, ErrVariantUnavailable
+		}
+		if err != nil {
+			return db.CartItem{}, fmt.Errorf("failed to get variant: %w", err)
+		}
+		if v.ProductID != productID || v.ArchivedAt.Valid || v.IsActive == nil || !*v.IsActive {
+			return db.CartItem{}, ErrVariantUnavailable
+		}
+	} else {
+		options, err := s.store.ListProductOptions(ctx, productID)
+		if err != nil {
+			return db.CartItem{}, fmt.Errorf("failed to list options: %w", err)
+		}
+		if len(options) > 0 {
+			return db.CartItem{}, ErrVariantRequired
+		}
+	}
+
 	cart, err := s.GetOrCreateCart(ctx, sessionID, userID)
 	if err != nil {
 		return db.CartItem{}, fmt.Errorf("failed to get cart: %w", err)
@@ -2,10 +2,13 @@ package catalog_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
+	db "bizbundl/internal/db/sqlc"
 	"bizbundl/internal/storefront/catalog/service"
 	"bizbundl/internal/testutil"
+	"bizbundl/util"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
@@ -18,7 +21,7 @@ func TestCreateCategory(t *testing.T) {
 
 	srv := testutil.SetupTestServer()
 	store := srv.GetDB()
-	svc := service.NewCatalogService(store)
+	svc := service.NewCatalogService(store, srv.GetEntitlements())
 	ctx := context.Background()
 
 	cat, err := svc.CreateCategory(ctx, "Electronics", pgtype.UUID{})
@@ -34,7 +37,7 @@ func TestCreateProduct(t *testing.T) {
 
 	srv := testutil.SetupTestServer()
 	store := srv.GetDB()
-	svc := service.NewCatalogService(store)
+	svc := service.NewCatalogService(store, srv.GetEntitlements())
 	ctx := context.Background()
 
 	// Dependency: Category
@@ -59,7 +62,7 @@ func TestGetProduct(t *testing.T) {
 
 	srv := testutil.SetupTestServer()
 	store := srv.GetDB()
-	svc := service.NewCatalogService(store)
+	svc := service.NewCatalogService(store, srv.GetEntitlements())
 	ctx := context.Background()
 
 	cat, _ := svc.CreateCategory(ctx, "Phones", pgtype.UUID{})
@@ -85,7 +88,7 @@ func TestListCategories(t *testing.T) {
 
 	srv := testutil.SetupTestServer()
 	store := srv.GetDB()
-	svc := service.NewCatalogService(store)
+	svc := service.NewCatalogService(store, srv.GetEntitlements())
 	ctx := context.Background()
 
 	_, _ = svc.CreateCategory(ctx, "A", pgtype.UUID{})
@@ -102,7 +105,7 @@ func TestListProducts(t *testing.T) {
 
 	srv := testutil.SetupTestServer()
 	store := srv.GetDB()
-	svc := service.NewCatalogService(store)
+	svc := service.NewCatalogService(store, srv.GetEntitlements())
 	ctx := context.Background()
 
 	cat, _ := svc.CreateCategory(ctx, "C", pgtype.UUID{})
@@ -112,3 +115,333 @@ func TestListProducts(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, items, 1)
 }
+
+func TestUpdateAndDeleteProduct(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	svc := service.NewCatalogService(srv.GetDB(), srv.GetEntitlements())
+	ctx := context.Background()
+
+	mug, err := svc.CreateProduct(ctx, service.CreateProductParams{Title: "Mug", BasePrice: 10})
+	require.NoError(t, err)
+	cup, err := svc.CreateProduct(ctx, service.CreateProductParams{Title: "Cup", BasePrice: 8})
+	require.NoError(t, err)
+
+	title, price, featured := "Big Mug", 12.5, true
+	p, err := svc.UpdateProduct(ctx, mug.ID, service.UpdateProductParams{Title: &title, BasePrice: &price, IsFeatured: &featured})
+	require.NoError(t, err)
+	assert.Equal(t, "Big Mug", p.Title)
+	assert.Equal(t, "mug", p.Slug, "kept unless given")
+	assert.True(t, *p.IsFeatured)
+
+	slug := "Cup"
+	_, err = svc.UpdateProduct(ctx, mug.ID, service.UpdateProductParams{Slug: &slug})
+	assert.ErrorIs(t, err, service.ErrDuplicateSlug)
+
+	require.NoError(t, svc.DeleteProduct(ctx, cup.ID))
+	assert.ErrorIs(t, svc.DeleteProduct(ctx, cup.ID), service.ErrProductNotFound)
+}
+
+func TestOptionsAndVariants(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	svc := service.NewCatalogService(srv.GetDB(), srv.GetEntitlements())
+	ctx := context.Background()
+
+	shirt, err := svc.CreateProduct(ctx, service.CreateProductParams{Title: "Shirt", BasePrice: 20})
+	require.NoError(t, err)
+
+	// Options
+	size, err := svc.CreateOption(ctx, shirt.ID, " Size ", []string{"S", " M ", "L"})
+	require.NoError(t, err)
+	assert.Equal(t, "Size", size.Name)
+	assert.Equal(t, []string{"S", "M", "L"}, size.Values)
+	color, err := svc.CreateOption(ctx, shirt.ID, "Color", []string{"Red", "Blue"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), *color.Position, "after the others")
+
+	_, err = svc.CreateOption(ctx, shirt.ID, "size", []string{"XL"})
+	assert.ErrorIs(t, err, service.ErrDuplicateOption)
+	_, err = svc.CreateOption(ctx, shirt.ID, "Fit", []string{"Slim", "slim"})
+	assert.ErrorIs(t, err, service.ErrInvalidOption)
+
+	// Options make the variants, a value of each
+	variants, err := svc.ListVariants(ctx, shirt.ID)
+	require.NoError(t, err)
+	require.Len(t, variants, 6)
+	small, medium := variants[0], variants[2]
+	assert.Equal(t, "S / Red", small.Title)
+	assert.Equal(t, "M / Red", medium.Title, "named after its options")
+
+	_, err = svc.CreateVariant(ctx, shirt.ID, service.VariantParams{Options: map[string]string{"Size": "M", "Color": "Red"}, Price: 20})
+	assert.ErrorIs(t, err, service.ErrDuplicateVariant)
+	_, err = svc.CreateVariant(ctx, shirt.ID, service.VariantParams{Options: map[string]string{"Size": "XL", "Color": "Red"}, Price: 20})
+	assert.ErrorIs(t, err, service.ErrInvalidVariant)
+
+	compareAt := 25.0
+	medium, err = svc.UpdateVariant(ctx, medium.ID, service.VariantParams{
+		Options:        map[string]string{"Size": "M", "Color": "Red"},
+		Price:          20,
+		CompareAtPrice: &compareAt,
+		SKU:            "SHIRT-M-RED",
+		StockQuantity:  5,
+		IsActive:       true,
+	})
+	require.NoError(t, err)
+	assert.True(t, medium.CompareAtPrice.Valid)
+
+	_, err = svc.UpdateVariant(ctx, small.ID, service.VariantParams{Options: map[string]string{"Size": "S", "Color": "Red"}, Price: 20, CompareAtPrice: &[]float64{15}[0]})
+	assert.ErrorIs(t, err, service.ErrInvalidPrice)
+	_, err = svc.UpdateVariant(ctx, small.ID, service.VariantParams{Options: map[string]string{"Size": "S", "Color": "Red"}, Price: 20, SKU: "SHIRT-M-RED"})
+	assert.ErrorIs(t, err, service.ErrDuplicateSKU)
+	_, err = svc.UpdateVariant(ctx, small.ID, service.VariantParams{Options: map[string]string{"Size": "M", "Color": "Red"}, Price: 20})
+	assert.ErrorIs(t, err, service.ErrDuplicateVariant)
+
+	small, err = svc.UpdateVariant(ctx, small.ID, service.VariantParams{Title: "Small red", Options: map[string]string{"Size": "S", "Color": "Red"}, Price: 18, IsActive: true})
+	require.NoError(t, err)
+	assert.Equal(t, "Small red", small.Title)
+
+	// Editing replaces the fields, the compare-at price too
+	medium, err = svc.UpdateVariant(ctx, medium.ID, service.VariantParams{
+		Options:  map[string]string{"Size": "M", "Color": "Red"},
+		Price:    22,
+		SKU:      "SHIRT-M-RED",
+		IsActive: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SHIRT-M-RED", *medium.Sku)
+	assert.False(t, medium.CompareAtPrice.Valid)
+
+	// Reordering
+	_, err = svc.ReorderVariants(ctx, shirt.ID, []pgtype.UUID{small.ID})
+	assert.ErrorIs(t, err, service.ErrInvalidOrder)
+	ids := []pgtype.UUID{medium.ID}
+	for _, v := range variants {
+		if v.ID != medium.ID {
+			ids = append(ids, v.ID)
+		}
+	}
+	variants, err = svc.ReorderVariants(ctx, shirt.ID, ids)
+	require.NoError(t, err)
+	require.Len(t, variants, 6)
+	assert.Equal(t, medium.ID, variants[0].ID)
+
+	require.NoError(t, svc.DeleteOption(ctx, color.ID))
+	options, err := svc.ListOptions(ctx, shirt.ID)
+	require.NoError(t, err)
+	assert.Len(t, options, 1)
+	variants, err = svc.ListVariants(ctx, shirt.ID)
+	require.NoError(t, err)
+	require.Len(t, variants, 3, "one a size")
+	for _, v := range variants {
+		assert.Equal(t, 1, len(service.Choices(v)))
+	}
+	assert.Equal(t, "SHIRT-M-RED", *variants[1].Sku, "taken over from the variant it replaces")
+}
+
+func TestMatrix(t *testing.T) {
+	combos := service.Matrix([]db.ProductOption{
+		{Name: "Size", Values: []string{"S", "M"}},
+		{Name: "Color", Values: []string{"Red", "Blue", "Green"}},
+	})
+	require.Len(t, combos, 6)
+	assert.Equal(t, map[string]string{"Size": "S", "Color": "Red"}, combos[0])
+	assert.Equal(t, map[string]string{"Size": "S", "Color": "Blue"}, combos[1], "the first option varies slowest")
+	assert.Equal(t, map[string]string{"Size": "M", "Color": "Green"}, combos[5])
+
+	assert.Equal(t, []map[string]string{{}}, service.Matrix(nil))
+}
+
+func TestGenerateVariants(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	svc := service.NewCatalogService(srv.GetDB(), srv.GetEntitlements())
+	ctx := context.Background()
+
+	mug, err := svc.CreateProduct(ctx, service.CreateProductParams{Title: "Mug", BasePrice: 12})
+	require.NoError(t, err)
+
+	// Without options there is a single variant
+	variants, changes, err := svc.GenerateVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	require.Len(t, variants, 1)
+	assert.Equal(t, "Default", variants[0].Title)
+	assert.Equal(t, service.MatrixChanges{Created: 1}, changes)
+	def := variants[0]
+	def, err = svc.UpdateVariant(ctx, def.ID, service.VariantParams{Price: 14, SKU: "MUG", StockQuantity: 9, IsActive: true})
+	require.NoError(t, err)
+
+	// The first option's values replace the variant, the first taking its
+	// SKU and stock, as carts and orders still point at the old one
+	size, err := svc.CreateOption(ctx, mug.ID, "Size", []string{"Small", "Large"})
+	require.NoError(t, err)
+	variants, err = svc.ListVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	require.Len(t, variants, 2)
+	assert.NotEqual(t, def.ID, variants[0].ID)
+	assert.Equal(t, "Small", variants[0].Title)
+	assert.Equal(t, map[string]string{"Size": "Small"}, service.Choices(variants[0]))
+	assert.Equal(t, "MUG", *variants[0].Sku)
+	assert.Equal(t, int32(9), *variants[0].StockQuantity)
+	assert.Equal(t, "14.00", util.FormatPrice(variants[1].Price), "priced like the variant it shares values with")
+	assert.Equal(t, int32(0), *variants[1].StockQuantity)
+	small, large := variants[0], variants[1]
+	archived, err := svc.ListArchivedVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, def.ID, archived[0].ID)
+	assert.Empty(t, service.Choices(archived[0]), "still the combination it was sold as")
+	assert.Nil(t, archived[0].Sku)
+
+	// Renaming a value keeps its variant
+	_, err = svc.UpdateOption(ctx, size.ID, service.UpdateOptionParams{Values: []string{"Small", "Big"}})
+	require.NoError(t, err)
+	variants, err = svc.ListVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	require.Len(t, variants, 2)
+	assert.Equal(t, large.ID, variants[1].ID)
+	assert.Equal(t, "Big", variants[1].Title)
+
+	// Removed values archive their variants, and bring them back when restored
+	_, err = svc.UpdateOption(ctx, size.ID, service.UpdateOptionParams{Values: []string{"Small"}})
+	require.NoError(t, err)
+	archived, err = svc.ListArchivedVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	require.Len(t, archived, 2)
+	assert.Contains(t, []pgtype.UUID{archived[0].ID, archived[1].ID}, large.ID)
+
+	_, err = svc.UpdateOption(ctx, size.ID, service.UpdateOptionParams{Values: []string{"Small", "Big"}})
+	require.NoError(t, err)
+	variants, err = svc.ListVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	require.Len(t, variants, 2)
+	assert.Equal(t, large.ID, variants[1].ID)
+	archived, err = svc.ListArchivedVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, def.ID, archived[0].ID)
+
+	// Staff titles survive regenerating
+	_, err = svc.UpdateVariant(ctx, large.ID, service.VariantParams{Title: "The big one", Options: map[string]string{"Size": "Big"}, Price: 14, IsActive: true})
+	require.NoError(t, err)
+	variants, changes, err = svc.GenerateVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	assert.Equal(t, service.MatrixChanges{}, changes)
+	assert.Equal(t, "The big one", variants[1].Title)
+
+	// A second option splits each variant, archiving it
+	_, err = svc.CreateOption(ctx, mug.ID, "Color", []string{"White", "Black"})
+	require.NoError(t, err)
+	variants, err = svc.ListVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	require.Len(t, variants, 4)
+	for _, v := range variants {
+		assert.NotContains(t, []pgtype.UUID{small.ID, large.ID}, v.ID)
+	}
+	assert.Equal(t, "Small / White", variants[0].Title)
+	assert.Equal(t, "MUG", *variants[0].Sku)
+	assert.Equal(t, int32(9), *variants[0].StockQuantity)
+	archived, err = svc.ListArchivedVariants(ctx, mug.ID)
+	require.NoError(t, err)
+	assert.Len(t, archived, 3)
+
+	_, err = svc.CreateOption(ctx, mug.ID, "Pattern", nil)
+	assert.ErrorIs(t, err, service.ErrInvalidOption)
+	many := make([]string, service.MaxVariants)
+	for i := range many {
+		many[i] = fmt.Sprintf("Pattern %d", i)
+	}
+	_, err = svc.CreateOption(ctx, mug.ID, "Pattern", many)
+	assert.ErrorIs(t, err, service.ErrTooManyVariants)
+}
+
+func TestBulkEditVariants(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	svc := service.NewCatalogService(srv.GetDB(), srv.GetEntitlements())
+	ctx := context.Background()
+
+	shirt, err := svc.CreateProduct(ctx, service.CreateProductParams{Title: "Shirt", BasePrice: 20})
+	require.NoError(t, err)
+	_, err = svc.CreateOption(ctx, shirt.ID, "Size", []string{"S", "M"})
+	require.NoError(t, err)
+	_, err = svc.CreateOption(ctx, shirt.ID, "Color", []string{"Red", "Blue"})
+	require.NoError(t, err)
+
+	price, stock := 25.0, int32(10)
+	changed, err := svc.BulkEditVariants(ctx, shirt.ID, service.BulkVariantEdit{
+		Match:         map[string]string{"Color": "Red"},
+		Price:         &price,
+		StockQuantity: &stock,
+	})
+	require.NoError(t, err)
+	require.Len(t, changed, 2)
+	for _, v := range changed {
+		assert.Equal(t, "25.00", util.FormatPrice(v.Price))
+		assert.Equal(t, int32(10), *v.StockQuantity)
+	}
+
+	// Nothing changes when a variant can't take the edit
+	adjustment := int32(-5)
+	_, err = svc.BulkEditVariants(ctx, shirt.ID, service.BulkVariantEdit{StockAdjustment: &adjustment})
+	assert.ErrorIs(t, err, service.ErrInvalidStock)
+	compareAt := 22.0
+	_, err = svc.BulkEditVariants(ctx, shirt.ID, service.BulkVariantEdit{CompareAtPrice: &compareAt})
+	assert.ErrorIs(t, err, service.ErrInvalidPrice)
+
+	adjustment = -3
+	changed, err = svc.BulkEditVariants(ctx, shirt.ID, service.BulkVariantEdit{
+		Match:           map[string]string{"Color": "Red"},
+		StockAdjustment: &adjustment,
+	})
+	require.NoError(t, err)
+	require.Len(t, changed, 2)
+	assert.Equal(t, int32(7), *changed[0].StockQuantity)
+
+	variants, err := svc.ListVariants(ctx, shirt.ID)
+	require.NoError(t, err)
+	for _, v := range variants {
+		if service.Choices(v)["Color"] == "Blue" {
+			assert.Equal(t, "20.00", util.FormatPrice(v.Price), "not matched")
+			assert.Equal(t, int32(0), *v.StockQuantity)
+		}
+	}
+}
+
+func TestResolveVariant(t *testing.T) {
+	testutil.Cleanup(t)
+	defer testutil.Cleanup(t)
+
+	srv := testutil.SetupTestServer()
+	svc := service.NewCatalogService(srv.GetDB(), srv.GetEntitlements())
+	ctx := context.Background()
+
+	shirt, err := svc.CreateProduct(ctx, service.CreateProductParams{Title: "Shirt", BasePrice: 20})
+	require.NoError(t, err)
+	_, err = svc.CreateOption(ctx, shirt.ID, "Size", []string{"S", "M"})
+	require.NoError(t, err)
+	_, err = svc.CreateOption(ctx, shirt.ID, "Color", []string{"Red", "Blue"})
+	require.NoError(t, err)
+
+	v, err := svc.ResolveVariant(ctx, shirt.ID, map[string]string{"Size": "M", "Color": "Blue"})
+	require.NoError(t, err)
+	assert.Equal(t, "M / Blue", v.Title)
+
+	_, err = svc.ResolveVariant(ctx, shirt.ID, map[string]string{"Size": "M"})
+	assert.ErrorIs(t, err, service.ErrVariantUnavailable, "every option is chosen")
+	_, err = svc.ResolveVariant(ctx, shirt.ID, map[string]string{"Size": "XL", "Color": "Blue"})
+	assert.ErrorIs(t, err, service.ErrVariantUnavailable)
+
+	_, err = svc.UpdateVariant(ctx, v.ID, service.VariantParams{Options: map[string]string{"Size": "M", "Color": "Blue"}, Price: 20, IsActive: false})
+	require.NoError(t, err)
+	_, err = svc.ResolveVariant(ctx, shirt.ID, map[string]string{"Size": "M", "Color": "Blue"})
+	assert.ErrorIs(t, err, service.ErrVariantUnavailable, "inactive")
+}
@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"errors"
+
+	"bizbundl/internal/entitlements"
+	"bizbundl/internal/storefront/catalog/service"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RegisterAdminRoutes sets up the API staff manage the catalog with, on a
+// router that checks they may
+func (h *CatalogHandler) RegisterAdminRoutes(router fiber.Router) {
+	router.Post("/products", h.CreateProduct)
+	router.Get("/products/:id", h.GetAdminProduct)
+	router.Put("/products/:id", h.UpdateProduct)
+	router.Delete("/products/:id", h.DeleteProduct)
+
+	router.Get("/products/:id/options", h.ListOptions)
+	router.Post("/products/:id/options", h.CreateOption)
+	router.Put("/options/:id", h.UpdateOption)
+	router.Delete("/options/:id", h.DeleteOption)
+
+	router.Get("/products/:id/variants", h.ListVariants)
+	router.Post("/products/:id/variants", h.CreateVariant)
+	router.Patch("/products/:id/variants", h.BulkEditVariants)
+	router.Post("/products/:id/variants/generate", h.GenerateVariants)
+	router.Put("/products/:id/variants/order", h.ReorderVariants)
+	router.Put("/variants/:id", h.UpdateVariant)
+	router.Delete("/variants/:id", h.DeleteVariant)
+}
+
+func (h *CatalogHandler) CreateProduct(c *fiber.Ctx) error {
+	var req CreateProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	product, err := h.service.CreateProduct(c.Context(), createProductParams(req))
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusCreated, product, "Product created")
+}
+
+// GetAdminProduct returns a product, active or not, with its options and
+// variants
+func (h *CatalogHandler) GetAdminProduct(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	product, err := h.service.GetProduct(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	options, err := h.service.ListOptions(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	variants, err := h.service.ListVariants(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	archived, err := h.service.ListArchivedVariants(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, AdminProductResponse{
+		Product:  product,
+		Options:  options,
+		Variants: newVariantResponses(variants),
+		Archived: newVariantResponses(archived),
+	}, "Product retrieved")
+}
+
+func (h *CatalogHandler) UpdateProduct(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	var req UpdateProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	p := service.UpdateProductParams{
+		Title:       req.Title,
+		Slug:        req.Slug,
+		Description: req.Description,
+		BasePrice:   req.BasePrice,
+		IsDigital:   req.IsDigital,
+		FilePath:    req.FilePath,
+		IsActive:    req.IsActive,
+		IsFeatured:  req.IsFeatured,
+	}
+	if req.CategoryID != nil {
+		_ = p.CategoryID.Scan(*req.CategoryID)
+	}
+	product, err := h.service.UpdateProduct(c.Context(), id, p)
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, product, "Product updated")
+}
+
+func (h *CatalogHandler) DeleteProduct(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	if err := h.service.DeleteProduct(c.Context(), id); err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Product deleted")
+}
+
+func (h *CatalogHandler) ListOptions(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	options, err := h.service.ListOptions(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, options, "Options retrieved")
+}
+
+func (h *CatalogHandler) CreateOption(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	var req CreateOptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	option, err := h.service.CreateOption(c.Context(), productID, req.Name, req.Values)
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusCreated, option, "Option created")
+}
+
+func (h *CatalogHandler) UpdateOption(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrOptionNotFound)
+	}
+	var req UpdateOptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	option, err := h.service.UpdateOption(c.Context(), id, service.UpdateOptionParams{
+		Name:     req.Name,
+		Values:   req.Values,
+		Position: req.Position,
+	})
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, option, "Option updated")
+}
+
+func (h *CatalogHandler) DeleteOption(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrOptionNotFound)
+	}
+	if err := h.service.DeleteOption(c.Context(), id); err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Option deleted")
+}
+
+func (h *CatalogHandler) ListVariants(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	variants, err := h.service.ListVariants(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, newVariantResponses(variants), "Variants retrieved")
+}
+
+func (h *CatalogHandler) CreateVariant(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	var req VariantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	variant, err := h.service.CreateVariant(c.Context(), productID, variantParams(req))
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusCreated, newVariantResponse(variant), "Variant created")
+}
+
+// UpdateVariant replaces the fields of a variant
+func (h *CatalogHandler) UpdateVariant(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrVariantNotFound)
+	}
+	var req VariantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	variant, err := h.service.UpdateVariant(c.Context(), id, variantParams(req))
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, newVariantResponse(variant), "Variant updated")
+}
+
+func (h *CatalogHandler) DeleteVariant(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrVariantNotFound)
+	}
+	if err := h.service.DeleteVariant(c.Context(), id); err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, nil, "Variant deleted")
+}
+
+// GenerateVariants makes a product's variants the combinations of its
+// options. Option changes already do, this catches up variants made
+// before them.
+func (h *CatalogHandler) GenerateVariants(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	variants, changes, err := h.service.GenerateVariants(c.Context(), productID)
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, GenerateVariantsResponse{
+		Variants: newVariantResponses(variants),
+		Changes:  changes,
+	}, "Variants generated")
+}
+
+// BulkEditVariants changes the price or stock of many variants at once,
+// returning those changed
+func (h *CatalogHandler) BulkEditVariants(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	var req BulkEditVariantsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	variants, err := h.service.BulkEditVariants(c.Context(), productID, bulkVariantEdit(req))
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, newVariantResponses(variants), "Variants updated")
+}
+
+// ReorderVariants puts a product's variants in the order given
+func (h *CatalogHandler) ReorderVariants(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	var req ReorderVariantsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	ids := make([]pgtype.UUID, len(req.VariantIDs))
+	for i, id := range req.VariantIDs {
+		_ = ids[i].Scan(id)
+	}
+	variants, err := h.service.ReorderVariants(c.Context(), productID, ids)
+	if err != nil {
+		return catalogError(c, err)
+	}
+	return util.JSON(c, fiber.StatusOK, newVariantResponses(variants), "Variants reordered")
+}
+
+func createProductParams(req CreateProductRequest) service.CreateProductParams {
+	p := service.CreateProductParams{
+		Title:       req.Title,
+		Description: req.Description,
+		BasePrice:   req.BasePrice,
+		IsDigital:   req.IsDigital,
+		FilePath:    req.FilePath,
+		IsFeatured:  req.IsFeatured,
+	}
+	_ = p.CategoryID.Scan(req.CategoryID)
+	return p
+}
+
+func bulkVariantEdit(req BulkEditVariantsRequest) service.BulkVariantEdit {
+	return service.BulkVariantEdit{
+		Match:               req.Match,
+		Price:               req.Price,
+		CompareAtPrice:      req.CompareAtPrice,
+		ClearCompareAtPrice: req.ClearCompareAtPrice,
+		StockQuantity:       req.StockQuantity,
+		StockAdjustment:     req.StockAdjustment,
+	}
+}
+
+func variantParams(req VariantRequest) service.VariantParams {
+	active := req.IsActive == nil || *req.IsActive
+	return service.VariantParams{
+		Title:          req.Title,
+		Options:        req.Options,
+		Price:          req.Price,
+		CompareAtPrice: req.CompareAtPrice,
+		SKU:            req.SKU,
+		StockQuantity:  req.StockQuantity,
+		IsActive:       active,
+	}
+}
+
+// uuidParam reads the :id of the route, which is not found when it is not
+// a UUID
+func uuidParam(c *fiber.Ctx) (pgtype.UUID, bool) {
+	var id pgtype.UUID
+	return id, id.Scan(c.Params("id")) == nil
+}
+
+// catalogError answers with the status of a catalog service error
+func catalogError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrProductNotFound), errors.Is(err, service.ErrOptionNotFound),
+		errors.Is(err, service.ErrVariantNotFound):
+		return util.APIError(c, fiber.StatusNotFound, err)
+	case errors.Is(err, service.ErrDuplicateSlug), errors.Is(err, service.ErrDuplicateSKU),
+		errors.Is(err, service.ErrDuplicateOption), errors.Is(err, service.ErrDuplicateVariant),
+		errors.Is(err, service.ErrInUse):
+		return util.APIError(c, fiber.StatusConflict, err)
+	case errors.Is(err, service.ErrInvalidPrice), errors.Is(err, service.ErrInvalidOption),
+		errors.Is(err, service.ErrInvalidVariant), errors.Is(err, service.ErrInvalidOrder),
+		errors.Is(err, service.ErrInvalidStock), errors.Is(err, service.ErrTooManyVariants):
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	case errors.Is(err, entitlements.ErrUpgradeRequired):
+		return util.APIError(c, fiber.StatusForbidden, err)
+	default:
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+}
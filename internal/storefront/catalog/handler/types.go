@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"time"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/storefront/catalog/service"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type CreateProductRequest struct {
+	Title       string  `json:"title" form:"title" validate:"required,max=255"`
+	Description string  `json:"description" form:"description"`
+	BasePrice   float64 `json:"base_price" form:"base_price" validate:"gte=0"`
+	IsDigital   bool    `json:"is_digital" form:"is_digital"`
+	FilePath    string  `json:"file_path" form:"file_path" validate:"max=255"`
+	CategoryID  string  `json:"category_id" form:"category_id" validate:"omitempty,uuid"`
+	IsFeatured  bool    `json:"is_featured" form:"is_featured"`
+}
+
+// UpdateProductRequest changes the fields given
+type UpdateProductRequest struct {
+	Title       *string  `json:"title" validate:"omitempty,min=1,max=255"`
+	Slug        *string  `json:"slug" validate:"omitempty,min=1,max=255"`
+	Description *string  `json:"description"`
+	BasePrice   *float64 `json:"base_price" validate:"omitempty,gte=0"`
+	IsDigital   *bool    `json:"is_digital"`
+	FilePath    *string  `json:"file_path" validate:"omitempty,max=255"`
+	CategoryID  *string  `json:"category_id" validate:"omitempty,uuid"`
+	IsActive    *bool    `json:"is_active"`
+	IsFeatured  *bool    `json:"is_featured"`
+}
+
+type CreateOptionRequest struct {
+	Name   string   `json:"name" validate:"required,max=50"`
+	Values []string `json:"values" validate:"required,min=1,dive,required,max=100"`
+}
+
+// UpdateOptionRequest changes the fields given
+type UpdateOptionRequest struct {
+	Name     *string  `json:"name" validate:"omitempty,min=1,max=50"`
+	Values   []string `json:"values" validate:"omitempty,min=1,dive,required,max=100"`
+	Position *int32   `json:"position" validate:"omitempty,gte=0"`
+}
+
+// VariantRequest creates a variant or replaces one's fields
+type VariantRequest struct {
+	Title          string            `json:"title" validate:"max=255"` // Empty names it after its options
+	Options        map[string]string `json:"options"`                  // A value of each of the product's options, by name
+	Price          float64           `json:"price" validate:"gte=0"`
+	CompareAtPrice *float64          `json:"compare_at_price" validate:"omitempty,gte=0"` // Above the price, or null
+	SKU            string            `json:"sku" validate:"max=100"`
+	StockQuantity  int32             `json:"stock_quantity" validate:"gte=0"`
+	IsActive       *bool             `json:"is_active"` // Default true
+}
+
+type ReorderVariantsRequest struct {
+	VariantIDs []string `json:"variant_ids" validate:"required,dive,uuid"`
+}
+
+// BulkEditVariantsRequest changes the variants having the option values of
+// match (all of them when empty)
+type BulkEditVariantsRequest struct {
+	Match               map[string]string `json:"match"`
+	Price               *float64          `json:"price" validate:"omitempty,gte=0"`
+	CompareAtPrice      *float64          `json:"compare_at_price" validate:"omitempty,gte=0"`
+	ClearCompareAtPrice bool              `json:"clear_compare_at_price"`
+	StockQuantity       *int32            `json:"stock_quantity" validate:"omitempty,gte=0"`
+	StockAdjustment     *int32            `json:"stock_adjustment"` // Added to the stock, e.g. -2
+}
+
+// GenerateVariantsResponse is a product's variants, generated from its
+// options, and what changed
+type GenerateVariantsResponse struct {
+	Variants []VariantResponse     `json:"variants"`
+	Changes  service.MatrixChanges `json:"changes"`
+}
+
+// VariantResponse has the variant's options decoded
+type VariantResponse struct {
+	ID             pgtype.UUID       `json:"id"`
+	ProductID      pgtype.UUID       `json:"product_id"`
+	Title          string            `json:"title"`
+	Options        map[string]string `json:"options"`
+	Price          pgtype.Numeric    `json:"price"`
+	CompareAtPrice pgtype.Numeric    `json:"compare_at_price"`
+	SKU            *string           `json:"sku"`
+	StockQuantity  *int32            `json:"stock_quantity"`
+	IsActive       *bool             `json:"is_active"`
+	Position       int32             `json:"position"`
+	ArchivedAt     *time.Time        `json:"archived_at"`
+}
+
+func newVariantResponse(v db.ProductVariant) VariantResponse {
+	options := map[string]string{}
+	_ = json.Unmarshal(v.Options, &options)
+	return VariantResponse{
+		ID:             v.ID,
+		ProductID:      v.ProductID,
+		Title:          v.Title,
+		Options:        options,
+		Price:          v.Price,
+		CompareAtPrice: v.CompareAtPrice,
+		SKU:            v.Sku,
+		StockQuantity:  v.StockQuantity,
+		IsActive:       v.IsActive,
+		Position:       v.Position,
+		ArchivedAt:     timeOrNil(v.ArchivedAt),
+	}
+}
+
+func newVariantResponses(variants []db.ProductVariant) []VariantResponse {
+	res := make([]VariantResponse, len(variants))
+	for i, v := range variants {
+		res[i] = newVariantResponse(v)
+	}
+	return res
+}
+
+// AdminProductResponse is a product with everything staff edit on it
+type AdminProductResponse struct {
+	Product  db.Product         `json:"product"`
+	Options  []db.ProductOption `json:"options"`
+	Variants []VariantResponse  `json:"variants"`
+	// Archived are the variants whose option values are gone
+	Archived []VariantResponse `json:"archived"`
+}
+
+func timeOrNil(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,470 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bizbundl/internal/storefront/catalog/service"
+	catalogview "bizbundl/internal/views/admin/catalog"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RegisterAdminViews sets up the screens staff manage the catalog with, on
+// a router that checks they may. Forms are answered with the HTML to swap
+// in, errors included.
+func (h *CatalogHandler) RegisterAdminViews(router fiber.Router) {
+	router.Get("/", h.ProductsPage)
+	router.Post("/products", h.CreateProductForm)
+	router.Get("/products/:id", h.ProductPage)
+	router.Post("/products/:id", h.UpdateProductForm)
+	router.Post("/products/:id/delete", h.DeleteProductForm)
+	router.Post("/products/:id/options", h.CreateOptionForm)
+	router.Post("/products/:id/variants", h.CreateVariantForm)
+	router.Post("/products/:id/variants/generate", h.GenerateVariantsForm)
+	router.Post("/products/:id/variants/bulk", h.BulkEditVariantsForm)
+	router.Post("/options/:id", h.UpdateOptionForm)
+	router.Post("/options/:id/delete", h.DeleteOptionForm)
+	router.Post("/variants/:id", h.UpdateVariantForm)
+	router.Post("/variants/:id/delete", h.DeleteVariantForm)
+	router.Post("/variants/:id/move", h.MoveVariant)
+}
+
+func (h *CatalogHandler) ProductsPage(c *fiber.Ctx) error {
+	data, err := h.productsData(c)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.Render(c, catalogview.Products(data))
+}
+
+func (h *CatalogHandler) CreateProductForm(c *fiber.Ctx) error {
+	var req CreateProductRequest
+	err := c.BodyParser(&req)
+	if err == nil {
+		err = validate(req)
+	}
+	var productID pgtype.UUID
+	if err == nil {
+		product, createErr := h.service.CreateProduct(c.Context(), createProductParams(req))
+		productID, err = product.ID, createErr
+	}
+	if err != nil {
+		data, listErr := h.productsData(c)
+		if listErr != nil {
+			return util.APIError(c, fiber.StatusInternalServerError, listErr)
+		}
+		data.Error = err.Error()
+		return util.Render(c, catalogview.NewProductForm(data))
+	}
+	c.Set("HX-Redirect", "/admin/catalog/products/"+util.UUIDToString(productID))
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+func (h *CatalogHandler) ProductPage(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	data, err := h.productData(c, id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	return util.Render(c, catalogview.Product(data))
+}
+
+// UpdateProductForm saves the product form, which has every field
+func (h *CatalogHandler) UpdateProductForm(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	price, priceErr := strconv.ParseFloat(c.FormValue("base_price"), 64)
+	req := UpdateProductRequest{
+		Title:       formString(c, "title"),
+		Slug:        formString(c, "slug"),
+		Description: formString(c, "description"),
+		BasePrice:   &price,
+		IsDigital:   formBool(c, "is_digital"),
+		FilePath:    formString(c, "file_path"),
+		CategoryID:  formString(c, "category_id"),
+		IsActive:    formBool(c, "is_active"),
+		IsFeatured:  formBool(c, "is_featured"),
+	}
+	if *req.CategoryID == "" {
+		req.CategoryID = nil // Kept as is
+	}
+	err := validate(req)
+	if priceErr != nil {
+		err = fmt.Errorf("base_price: Invalid value: number")
+	}
+	if err == nil {
+		p := service.UpdateProductParams{
+			Title:       req.Title,
+			Slug:        req.Slug,
+			Description: req.Description,
+			BasePrice:   req.BasePrice,
+			IsDigital:   req.IsDigital,
+			FilePath:    req.FilePath,
+			IsActive:    req.IsActive,
+			IsFeatured:  req.IsFeatured,
+		}
+		if req.CategoryID != nil {
+			_ = p.CategoryID.Scan(*req.CategoryID)
+		}
+		_, err = h.service.UpdateProduct(c.Context(), id, p)
+	}
+
+	data, dataErr := h.productData(c, id)
+	if dataErr != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	if err != nil {
+		data.Error = err.Error()
+	} else {
+		data.Message = "Product saved"
+	}
+	return util.Render(c, catalogview.ProductForm(data))
+}
+
+func (h *CatalogHandler) DeleteProductForm(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	if err := h.service.DeleteProduct(c.Context(), id); err != nil {
+		data, dataErr := h.productData(c, id)
+		if dataErr != nil {
+			return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+		}
+		data.Error = err.Error()
+		return util.Render(c, catalogview.ProductForm(data))
+	}
+	c.Set("HX-Redirect", "/admin/catalog")
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (h *CatalogHandler) CreateOptionForm(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	req := CreateOptionRequest{Name: c.FormValue("name"), Values: splitValues(c.FormValue("values"))}
+	err := validate(req)
+	if err == nil {
+		_, err = h.service.CreateOption(c.Context(), productID, req.Name, req.Values)
+	}
+	return h.renderEditor(c, productID, err, "Option added")
+}
+
+func (h *CatalogHandler) UpdateOptionForm(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrOptionNotFound)
+	}
+	option, err := h.service.GetOption(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrOptionNotFound)
+	}
+	// The form has both fields
+	req := CreateOptionRequest{Name: c.FormValue("name"), Values: splitValues(c.FormValue("values"))}
+	err = validate(req)
+	if err == nil {
+		_, err = h.service.UpdateOption(c.Context(), id, service.UpdateOptionParams{Name: &req.Name, Values: req.Values})
+	}
+	return h.renderEditor(c, option.ProductID, err, "Option saved")
+}
+
+func (h *CatalogHandler) DeleteOptionForm(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrOptionNotFound)
+	}
+	option, err := h.service.GetOption(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrOptionNotFound)
+	}
+	err = h.service.DeleteOption(c.Context(), id)
+	return h.renderEditor(c, option.ProductID, err, "Option deleted")
+}
+
+func (h *CatalogHandler) CreateVariantForm(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	req, err := variantForm(c)
+	if err == nil {
+		_, err = h.service.CreateVariant(c.Context(), productID, variantParams(req))
+	}
+	return h.renderEditor(c, productID, err, "Variant added")
+}
+
+func (h *CatalogHandler) UpdateVariantForm(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrVariantNotFound)
+	}
+	variant, err := h.service.GetProductVariant(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrVariantNotFound)
+	}
+	req, err := variantForm(c)
+	if err == nil {
+		_, err = h.service.UpdateVariant(c.Context(), id, variantParams(req))
+	}
+	return h.renderEditor(c, variant.ProductID, err, "Variant saved")
+}
+
+func (h *CatalogHandler) DeleteVariantForm(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrVariantNotFound)
+	}
+	variant, err := h.service.GetProductVariant(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrVariantNotFound)
+	}
+	err = h.service.DeleteVariant(c.Context(), id)
+	return h.renderEditor(c, variant.ProductID, err, "Variant deleted")
+}
+
+func (h *CatalogHandler) GenerateVariantsForm(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	_, changes, err := h.service.GenerateVariants(c.Context(), productID)
+	message := fmt.Sprintf("%d variants created, %d updated, %d archived", changes.Created, changes.Updated, changes.Archived)
+	return h.renderEditor(c, productID, err, message)
+}
+
+// BulkEditVariantsForm reads the bulk edit form, whose option selects are
+// named "match.<name>" and empty for any value
+func (h *CatalogHandler) BulkEditVariantsForm(c *fiber.Ctx) error {
+	productID, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	req := BulkEditVariantsRequest{
+		Match:               map[string]string{},
+		ClearCompareAtPrice: c.FormValue("clear_compare_at_price") == "true",
+	}
+	var err error
+	if req.Price, err = formFloat(c, "price"); err == nil {
+		req.CompareAtPrice, err = formFloat(c, "compare_at_price")
+	}
+	if err == nil {
+		req.StockQuantity, err = formInt(c, "stock_quantity")
+	}
+	if err == nil {
+		req.StockAdjustment, err = formInt(c, "stock_adjustment")
+	}
+	c.Request().PostArgs().VisitAll(func(key, value []byte) {
+		if name, ok := strings.CutPrefix(string(key), "match."); ok && len(value) > 0 {
+			req.Match[name] = string(value)
+		}
+	})
+	if err == nil {
+		err = validate(req)
+	}
+	var variants int
+	if err == nil {
+		changed, editErr := h.service.BulkEditVariants(c.Context(), productID, bulkVariantEdit(req))
+		variants, err = len(changed), editErr
+	}
+	return h.renderEditor(c, productID, err, fmt.Sprintf("%d variants updated", variants))
+}
+
+// MoveVariant swaps a variant with the one before (?direction=up) or after
+// it
+func (h *CatalogHandler) MoveVariant(c *fiber.Ctx) error {
+	id, ok := uuidParam(c)
+	if !ok {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrVariantNotFound)
+	}
+	variant, err := h.service.GetProductVariant(c.Context(), id)
+	if err != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrVariantNotFound)
+	}
+	variants, err := h.service.ListVariants(c.Context(), variant.ProductID)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+
+	ids := make([]pgtype.UUID, len(variants))
+	for i, v := range variants {
+		ids[i] = v.ID
+	}
+	for i := range ids {
+		if ids[i] != id {
+			continue
+		}
+		j := i + 1
+		if c.Query("direction") == "up" {
+			j = i - 1
+		}
+		if j >= 0 && j < len(ids) {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+		break
+	}
+	_, err = h.service.ReorderVariants(c.Context(), variant.ProductID, ids)
+	return h.renderEditor(c, variant.ProductID, err, "")
+}
+
+// renderEditor answers a change to the options or variants of a product
+// with its editor, showing err or else message
+func (h *CatalogHandler) renderEditor(c *fiber.Ctx, productID pgtype.UUID, err error, message string) error {
+	data, dataErr := h.productData(c, productID)
+	if dataErr != nil {
+		return util.APIError(c, fiber.StatusNotFound, service.ErrProductNotFound)
+	}
+	if err != nil {
+		data.Error = err.Error()
+	} else {
+		data.Message = message
+	}
+	return util.Render(c, catalogview.Editor(data))
+}
+
+func (h *CatalogHandler) productsData(c *fiber.Ctx) (catalogview.ProductsData, error) {
+	products, err := h.service.ListProducts(c.Context())
+	if err != nil {
+		return catalogview.ProductsData{}, err
+	}
+	categories, err := h.service.ListCategories(c.Context())
+	if err != nil {
+		return catalogview.ProductsData{}, err
+	}
+	return catalogview.ProductsData{Products: products, Categories: categories}, nil
+}
+
+func (h *CatalogHandler) productData(c *fiber.Ctx, id pgtype.UUID) (catalogview.ProductData, error) {
+	product, err := h.service.GetProduct(c.Context(), id)
+	if err != nil {
+		return catalogview.ProductData{}, err
+	}
+	categories, err := h.service.ListCategories(c.Context())
+	if err != nil {
+		return catalogview.ProductData{}, err
+	}
+	options, err := h.service.ListOptions(c.Context(), id)
+	if err != nil {
+		return catalogview.ProductData{}, err
+	}
+	variants, err := h.service.ListVariants(c.Context(), id)
+	if err != nil {
+		return catalogview.ProductData{}, err
+	}
+	archived, err := h.service.ListArchivedVariants(c.Context(), id)
+	if err != nil {
+		return catalogview.ProductData{}, err
+	}
+	return catalogview.ProductData{
+		Product:    product,
+		Categories: categories,
+		Options:    options,
+		Variants:   variants,
+		Archived:   archived,
+	}, nil
+}
+
+// variantForm reads a variant form, whose option selects are named
+// "option.<name>"
+func variantForm(c *fiber.Ctx) (VariantRequest, error) {
+	price, err := strconv.ParseFloat(c.FormValue("price"), 64)
+	if err != nil {
+		return VariantRequest{}, fmt.Errorf("price: Invalid value: number")
+	}
+	req := VariantRequest{
+		Title:    c.FormValue("title"),
+		Options:  map[string]string{},
+		Price:    price,
+		SKU:      c.FormValue("sku"),
+		IsActive: formBool(c, "is_active"),
+	}
+	if req.CompareAtPrice, err = formFloat(c, "compare_at_price"); err != nil {
+		return VariantRequest{}, err
+	}
+	stock, err := formInt(c, "stock_quantity")
+	if err != nil {
+		return VariantRequest{}, err
+	}
+	if stock != nil {
+		req.StockQuantity = *stock
+	}
+	c.Request().PostArgs().VisitAll(func(key, value []byte) {
+		if name, ok := strings.CutPrefix(string(key), "option."); ok {
+			req.Options[name] = string(value)
+		}
+	})
+	return req, validate(req)
+}
+
+// validate checks req with the validator, as one error for forms
+func validate(req any) error {
+	errs, err := util.ValidateStruct(req)
+	if err == nil {
+		return nil
+	}
+	fields := make([]string, 0, len(errs))
+	for field, msg := range errs {
+		fields = append(fields, field+": "+msg)
+	}
+	sort.Strings(fields)
+	return fmt.Errorf("%s", strings.Join(fields, "; "))
+}
+
+// splitValues reads comma separated option values
+func splitValues(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// formFloat reads an optional number field
+func formFloat(c *fiber.Ctx, key string) (*float64, error) {
+	v := c.FormValue(key)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Invalid value: number", key)
+	}
+	return &f, nil
+}
+
+// formInt reads an optional whole number field
+func formInt(c *fiber.Ctx, key string) (*int32, error) {
+	v := c.FormValue(key)
+	if v == "" {
+		return nil, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Invalid value: number", key)
+	}
+	n := int32(i)
+	return &n, nil
+}
+
+func formString(c *fiber.Ctx, key string) *string {
+	v := c.FormValue(key)
+	return &v
+}
+
+// formBool reads a checkbox, sent only when ticked
+func formBool(c *fiber.Ctx, key string) *bool {
+	v := c.FormValue(key) == "true"
+	return &v
+}
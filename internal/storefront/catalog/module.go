@@ -1,17 +1,30 @@
 package catalog
 
 import (
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/permissions"
 	"bizbundl/internal/storefront/catalog/handler"
 	"bizbundl/internal/storefront/catalog/service"
 	"bizbundl/internal/server"
+
+	"github.com/gofiber/fiber/v2"
 )
 
 // Init initializes the Catalog module
 func Init(app *server.Server) *service.CatalogService {
-	svc := service.NewCatalogService(app.GetDB())
+	svc := service.NewCatalogService(app.GetDB(), app.GetEntitlements())
 	handler := handler.NewCatalogHandler(svc)
 
 	api := app.GetRouter().Group("/api/v1")
 	handler.RegisterRoutes(api)
+
+	// Catalog management, by staff allowed to (and API keys holding the
+	// scope) with two-factor, where the shop requires it
+	canEdit := []fiber.Handler{
+		middleware.RequirePermission(app.GetPermissions(), permissions.CatalogEdit),
+		middleware.RequireTwoFactor(app.GetSessions()),
+	}
+	handler.RegisterAdminRoutes(app.GetRouter().Group("/api/v1/admin/catalog", canEdit...))
+	handler.RegisterAdminViews(app.GetRouter().Group("/admin/catalog", canEdit...))
 	return svc
 }
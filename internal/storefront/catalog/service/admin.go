@@ -0,0 +1,658 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"bizbundl/internal/audit"
+	db "bizbundl/internal/db/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrOptionNotFound  = errors.New("option not found")
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrDuplicateSlug   = errors.New("another product already uses this slug")
+	ErrDuplicateSKU    = errors.New("another variant already uses this SKU")
+	ErrDuplicateOption = errors.New("the product already has an option with this name")
+	ErrInvalidPrice    = errors.New("prices cannot be negative, and the compare-at price must be above the price")
+	ErrInvalidOption   = errors.New("an option needs a name and distinct, non-empty values")
+	ErrInvalidVariant  = errors.New("variant options must be the product's options and values")
+	ErrInvalidOrder    = errors.New("the order must list each of the product's variants once")
+	ErrInUse           = errors.New("carts or orders include it: deactivate it instead")
+)
+
+// -- Products --
+
+// UpdateProductParams are the fields to change: nil leaves a field as is
+type UpdateProductParams struct {
+	Title       *string
+	Slug        *string
+	Description *string
+	BasePrice   *float64
+	IsDigital   *bool
+	FilePath    *string
+	CategoryID  pgtype.UUID
+	IsActive    *bool
+	IsFeatured  *bool
+}
+
+func (s *CatalogService) UpdateProduct(ctx context.Context, id pgtype.UUID, p UpdateProductParams) (db.Product, error) {
+	before, err := s.getProduct(ctx, id)
+	if err != nil {
+		return db.Product{}, err
+	}
+	params := db.UpdateProductParams{
+		ID:          id,
+		Title:       p.Title,
+		Description: p.Description,
+		IsDigital:   p.IsDigital,
+		FilePath:    p.FilePath,
+		CategoryID:  p.CategoryID,
+		IsActive:    p.IsActive,
+		IsFeatured:  p.IsFeatured,
+	}
+	if p.Slug != nil {
+		slug := makeSlug(*p.Slug)
+		params.Slug = &slug
+	}
+	if p.BasePrice != nil {
+		if params.BasePrice, err = price(*p.BasePrice); err != nil {
+			return db.Product{}, err
+		}
+	}
+
+	product, err := s.store.UpdateProduct(ctx, params)
+	if isUniqueViolation(err) {
+		return db.Product{}, ErrDuplicateSlug
+	}
+	if err != nil {
+		return db.Product{}, fmt.Errorf("failed to update product: %w", err)
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product.updated",
+		EntityType: "product",
+		EntityID:   product.ID.String(),
+		Before:     before,
+		After:      product,
+	})
+	if err != nil {
+		return db.Product{}, err
+	}
+	return product, nil
+}
+
+// DeleteProduct deletes a product with its options and variants, unless
+// carts or orders include it
+func (s *CatalogService) DeleteProduct(ctx context.Context, id pgtype.UUID) error {
+	before, err := s.getProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.DeleteProduct(ctx, id)
+	if isForeignKeyViolation(err) {
+		return ErrInUse
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete product: %w", err)
+	}
+	return s.changed(ctx, audit.Entry{
+		Action:     "product.deleted",
+		EntityType: "product",
+		EntityID:   before.ID.String(),
+		Before:     before,
+	})
+}
+
+func (s *CatalogService) getProduct(ctx context.Context, id pgtype.UUID) (db.Product, error) {
+	product, err := s.store.GetProduct(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.Product{}, ErrProductNotFound
+	}
+	return product, err
+}
+
+// -- Options --
+
+// ListOptions returns the options of a product, in their order
+func (s *CatalogService) ListOptions(ctx context.Context, productID pgtype.UUID) ([]db.ProductOption, error) {
+	return s.store.ListProductOptions(ctx, productID)
+}
+
+// CreateOption adds an option, like Size with S, M and L, after the
+// product's others, and generates the variants it makes
+func (s *CatalogService) CreateOption(ctx context.Context, productID pgtype.UUID, name string, values []string) (db.ProductOption, error) {
+	if _, err := s.getProduct(ctx, productID); err != nil {
+		return db.ProductOption{}, err
+	}
+	name, values, err := cleanOption(name, values)
+	if err != nil {
+		return db.ProductOption{}, err
+	}
+	if err := s.checkOptionName(ctx, productID, pgtype.UUID{}, name); err != nil {
+		return db.ProductOption{}, err
+	}
+	options, err := s.store.ListProductOptions(ctx, productID)
+	if err != nil {
+		return db.ProductOption{}, err
+	}
+	if err := checkMatrixSize(append(options, db.ProductOption{Values: values})); err != nil {
+		return db.ProductOption{}, err
+	}
+	auto, err := s.autoTitled(ctx, productID)
+	if err != nil {
+		return db.ProductOption{}, err
+	}
+
+	option, err := s.store.CreateProductOption(ctx, db.CreateProductOptionParams{
+		ProductID: productID,
+		Name:      name,
+		Values:    values,
+	})
+	if err != nil {
+		return db.ProductOption{}, fmt.Errorf("failed to create option: %w", err)
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product_option.created",
+		EntityType: "product_option",
+		EntityID:   option.ID.String(),
+		After:      option,
+	})
+	if err != nil {
+		return db.ProductOption{}, err
+	}
+	if _, _, err := s.generateVariants(ctx, productID, auto); err != nil {
+		return db.ProductOption{}, err
+	}
+	return option, nil
+}
+
+// UpdateOptionParams are the fields to change: nil leaves a field as is
+type UpdateOptionParams struct {
+	Name     *string
+	Values   []string
+	Position *int32
+}
+
+// UpdateOption changes an option and generates the product's variants
+// again. Variants follow a renamed option, and values replaced in place.
+func (s *CatalogService) UpdateOption(ctx context.Context, id pgtype.UUID, p UpdateOptionParams) (db.ProductOption, error) {
+	before, err := s.GetOption(ctx, id)
+	if err != nil {
+		return db.ProductOption{}, err
+	}
+	name, values := before.Name, before.Values
+	if p.Name != nil {
+		name = *p.Name
+	}
+	if p.Values != nil {
+		values = p.Values
+	}
+	if name, values, err = cleanOption(name, values); err != nil {
+		return db.ProductOption{}, err
+	}
+	if err := s.checkOptionName(ctx, before.ProductID, id, name); err != nil {
+		return db.ProductOption{}, err
+	}
+	options, err := s.store.ListProductOptions(ctx, before.ProductID)
+	if err != nil {
+		return db.ProductOption{}, err
+	}
+	for i := range options {
+		if options[i].ID == id {
+			options[i].Values = values
+		}
+	}
+	if err := checkMatrixSize(options); err != nil {
+		return db.ProductOption{}, err
+	}
+	auto, err := s.autoTitled(ctx, before.ProductID)
+	if err != nil {
+		return db.ProductOption{}, err
+	}
+
+	option, err := s.store.UpdateProductOption(ctx, db.UpdateProductOptionParams{
+		ID:       id,
+		Name:     &name,
+		Values:   values,
+		Position: p.Position,
+	})
+	if err != nil {
+		return db.ProductOption{}, fmt.Errorf("failed to update option: %w", err)
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product_option.updated",
+		EntityType: "product_option",
+		EntityID:   option.ID.String(),
+		Before:     before,
+		After:      option,
+	})
+	if err != nil {
+		return db.ProductOption{}, err
+	}
+	if err := s.renameVariantOptions(ctx, before, option); err != nil {
+		return db.ProductOption{}, err
+	}
+	if _, _, err := s.generateVariants(ctx, option.ProductID, auto); err != nil {
+		return db.ProductOption{}, err
+	}
+	return option, nil
+}
+
+// DeleteOption removes an option and generates the product's variants
+// again
+func (s *CatalogService) DeleteOption(ctx context.Context, id pgtype.UUID) error {
+	before, err := s.GetOption(ctx, id)
+	if err != nil {
+		return err
+	}
+	auto, err := s.autoTitled(ctx, before.ProductID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.DeleteProductOption(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete option: %w", err)
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product_option.deleted",
+		EntityType: "product_option",
+		EntityID:   before.ID.String(),
+		Before:     before,
+	})
+	if err != nil {
+		return err
+	}
+	_, _, err = s.generateVariants(ctx, before.ProductID, auto)
+	return err
+}
+
+// renameVariantOptions carries a renamed option, and values replaced in
+// place, over to the product's variants so they keep their combinations
+func (s *CatalogService) renameVariantOptions(ctx context.Context, before, after db.ProductOption) error {
+	if before.Name != after.Name {
+		err := s.store.RenameVariantOption(ctx, db.RenameVariantOptionParams{
+			ProductID: after.ProductID,
+			OldName:   before.Name,
+			NewName:   after.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to rename variant options: %w", err)
+		}
+	}
+	if len(before.Values) != len(after.Values) {
+		return nil
+	}
+	for i, old := range before.Values {
+		// Reordered values are not renamed ones
+		if value := after.Values[i]; value != old && !contains(after.Values, old) && !contains(before.Values, value) {
+			err := s.store.RenameVariantOptionValue(ctx, db.RenameVariantOptionValueParams{
+				ProductID: after.ProductID,
+				Name:      after.Name,
+				OldValue:  old,
+				NewValue:  value,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to rename variant options: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *CatalogService) GetOption(ctx context.Context, id pgtype.UUID) (db.ProductOption, error) {
+	option, err := s.store.GetProductOption(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.ProductOption{}, ErrOptionNotFound
+	}
+	return option, err
+}
+
+// checkOptionName fails when another option of the product (than id) has
+// the name
+func (s *CatalogService) checkOptionName(ctx context.Context, productID, id pgtype.UUID, name string) error {
+	options, err := s.store.ListProductOptions(ctx, productID)
+	if err != nil {
+		return err
+	}
+	for _, o := range options {
+		if o.ID != id && strings.EqualFold(o.Name, name) {
+			return ErrDuplicateOption
+		}
+	}
+	return nil
+}
+
+// cleanOption trims the name and values of an option and checks them
+func cleanOption(name string, values []string) (string, []string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(values) == 0 {
+		return "", nil, ErrInvalidOption
+	}
+	seen := map[string]bool{}
+	cleaned := make([]string, len(values))
+	for i, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[strings.ToLower(v)] {
+			return "", nil, ErrInvalidOption
+		}
+		seen[strings.ToLower(v)] = true
+		cleaned[i] = v
+	}
+	return name, cleaned, nil
+}
+
+// -- Variants --
+
+// ListVariants returns the variants of a product, in their order
+func (s *CatalogService) ListVariants(ctx context.Context, productID pgtype.UUID) ([]db.ProductVariant, error) {
+	return s.store.ListVariantsByProduct(ctx, productID)
+}
+
+// VariantParams describe a variant. Options pick a value of each of the
+// product's options, by name; a variant without a title is named after
+// them.
+type VariantParams struct {
+	Title          string
+	Options        map[string]string
+	Price          float64
+	CompareAtPrice *float64
+	SKU            string
+	StockQuantity  int32
+	IsActive       bool
+}
+
+// CreateVariant adds a variant after the product's others
+func (s *CatalogService) CreateVariant(ctx context.Context, productID pgtype.UUID, p VariantParams) (db.ProductVariant, error) {
+	if _, err := s.getProduct(ctx, productID); err != nil {
+		return db.ProductVariant{}, err
+	}
+	options, encoded, err := s.variantOptions(ctx, productID, p.Options)
+	if err != nil {
+		return db.ProductVariant{}, err
+	}
+	if err := s.checkDuplicateVariant(ctx, productID, pgtype.UUID{}, encoded); err != nil {
+		return db.ProductVariant{}, err
+	}
+	priceNumeric, compareAt, err := prices(p.Price, p.CompareAtPrice)
+	if err != nil {
+		return db.ProductVariant{}, err
+	}
+
+	variant, err := s.store.CreateProductVariant(ctx, db.CreateProductVariantParams{
+		ProductID:      productID,
+		Title:          variantTitle(p.Title, p.Options, options),
+		Options:        encoded,
+		Price:          priceNumeric,
+		CompareAtPrice: compareAt,
+		Sku:            skuPtr(p.SKU),
+		StockQuantity:  &p.StockQuantity,
+		IsActive:       &p.IsActive,
+	})
+	if isUniqueViolation(err) {
+		return db.ProductVariant{}, ErrDuplicateSKU
+	}
+	if err != nil {
+		return db.ProductVariant{}, fmt.Errorf("failed to create variant: %w", err)
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product_variant.created",
+		EntityType: "product_variant",
+		EntityID:   variant.ID.String(),
+		After:      variantSnapshot(variant),
+	})
+	if err != nil {
+		return db.ProductVariant{}, err
+	}
+	return variant, nil
+}
+
+// UpdateVariant replaces a variant's fields with p
+func (s *CatalogService) UpdateVariant(ctx context.Context, id pgtype.UUID, p VariantParams) (db.ProductVariant, error) {
+	before, err := s.getVariant(ctx, id)
+	if err != nil {
+		return db.ProductVariant{}, err
+	}
+	options, encoded, err := s.variantOptions(ctx, before.ProductID, p.Options)
+	if err != nil {
+		return db.ProductVariant{}, err
+	}
+	if err := s.checkDuplicateVariant(ctx, before.ProductID, id, encoded); err != nil {
+		return db.ProductVariant{}, err
+	}
+	priceNumeric, compareAt, err := prices(p.Price, p.CompareAtPrice)
+	if err != nil {
+		return db.ProductVariant{}, err
+	}
+
+	title := variantTitle(p.Title, p.Options, options)
+	variant, err := s.store.UpdateProductVariant(ctx, db.UpdateProductVariantParams{
+		ID:             id,
+		Title:          &title,
+		Options:        encoded,
+		Price:          priceNumeric,
+		CompareAtPrice: compareAt,
+		Sku:            skuPtr(p.SKU),
+		StockQuantity:  &p.StockQuantity,
+		IsActive:       &p.IsActive,
+	})
+	if isUniqueViolation(err) {
+		return db.ProductVariant{}, ErrDuplicateSKU
+	}
+	if err != nil {
+		return db.ProductVariant{}, fmt.Errorf("failed to update variant: %w", err)
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product_variant.updated",
+		EntityType: "product_variant",
+		EntityID:   variant.ID.String(),
+		Before:     variantSnapshot(before),
+		After:      variantSnapshot(variant),
+	})
+	if err != nil {
+		return db.ProductVariant{}, err
+	}
+	return variant, nil
+}
+
+// DeleteVariant deletes a variant, unless carts or orders include it
+func (s *CatalogService) DeleteVariant(ctx context.Context, id pgtype.UUID) error {
+	before, err := s.getVariant(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.DeleteVariant(ctx, id)
+	if isForeignKeyViolation(err) {
+		return ErrInUse
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete variant: %w", err)
+	}
+	return s.changed(ctx, audit.Entry{
+		Action:     "product_variant.deleted",
+		EntityType: "product_variant",
+		EntityID:   before.ID.String(),
+		Before:     variantSnapshot(before),
+	})
+}
+
+// ReorderVariants puts the variants of a product in the order of ids, which
+// lists each of them once
+func (s *CatalogService) ReorderVariants(ctx context.Context, productID pgtype.UUID, ids []pgtype.UUID) ([]db.ProductVariant, error) {
+	if _, err := s.getProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+	variants, err := s.store.ListVariantsByProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) != len(variants) {
+		return nil, ErrInvalidOrder
+	}
+	listed := map[pgtype.UUID]bool{}
+	for _, id := range ids {
+		listed[id] = true
+	}
+	before := make([]string, len(variants))
+	for i, v := range variants {
+		if !listed[v.ID] {
+			return nil, ErrInvalidOrder
+		}
+		before[i] = v.ID.String()
+	}
+
+	after := make([]string, len(ids))
+	for i, id := range ids {
+		_, err := s.store.SetVariantPosition(ctx, db.SetVariantPositionParams{
+			ID:        id,
+			ProductID: productID,
+			Position:  int32(i),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to reorder variants: %w", err)
+		}
+		after[i] = id.String()
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product.variants_reordered",
+		EntityType: "product",
+		EntityID:   productID.String(),
+		Before:     map[string]any{"variants": before},
+		After:      map[string]any{"variants": after},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.store.ListVariantsByProduct(ctx, productID)
+}
+
+func (s *CatalogService) getVariant(ctx context.Context, id pgtype.UUID) (db.ProductVariant, error) {
+	variant, err := s.store.GetProductVariant(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.ProductVariant{}, ErrVariantNotFound
+	}
+	return variant, err
+}
+
+// variantOptions checks that chosen picks a value of each of the product's
+// options, and returns the options with chosen encoded for the variant
+func (s *CatalogService) variantOptions(ctx context.Context, productID pgtype.UUID, chosen map[string]string) ([]db.ProductOption, []byte, error) {
+	options, err := s.store.ListProductOptions(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chosen) != len(options) {
+		return nil, nil, ErrInvalidVariant
+	}
+	for _, o := range options {
+		value, ok := chosen[o.Name]
+		if !ok || !contains(o.Values, value) {
+			return nil, nil, ErrInvalidVariant
+		}
+	}
+	if chosen == nil {
+		chosen = map[string]string{}
+	}
+	encoded, err := json.Marshal(chosen)
+	return options, encoded, err
+}
+
+// variantTitle is title, or else the variant's option values in the order
+// of the options, like "M / Blue"
+func variantTitle(title string, chosen map[string]string, options []db.ProductOption) string {
+	if title = strings.TrimSpace(title); title != "" {
+		return title
+	}
+	if len(options) == 0 {
+		return "Default"
+	}
+	values := make([]string, len(options))
+	for i, o := range options {
+		values[i] = chosen[o.Name]
+	}
+	return strings.Join(values, " / ")
+}
+
+// variantSnapshot is what the audit log compares of a variant
+func variantSnapshot(v db.ProductVariant) map[string]any {
+	return map[string]any{
+		"title":            v.Title,
+		"options":          json.RawMessage(v.Options),
+		"price":            v.Price,
+		"compare_at_price": v.CompareAtPrice,
+		"sku":              v.Sku,
+		"stock_quantity":   v.StockQuantity,
+		"is_active":        v.IsActive,
+	}
+}
+
+// -- Utilities --
+
+// changed records a change to the catalog and drops the cached pages that
+// may show it
+func (s *CatalogService) changed(ctx context.Context, e audit.Entry) error {
+	if err := s.audit.Record(ctx, e); err != nil {
+		return err
+	}
+	return s.pages.InvalidatePages(ctx)
+}
+
+func price(f float64) (pgtype.Numeric, error) {
+	var n pgtype.Numeric
+	if f < 0 {
+		return n, ErrInvalidPrice
+	}
+	if err := n.Scan(fmt.Sprintf("%f", f)); err != nil {
+		return n, fmt.Errorf("invalid price: %v", err)
+	}
+	return n, nil
+}
+
+// prices encodes a variant's price and compare-at price, which is optional
+// and must be above the price
+func prices(p float64, compareAt *float64) (pgtype.Numeric, pgtype.Numeric, error) {
+	priceNumeric, err := price(p)
+	if err != nil {
+		return pgtype.Numeric{}, pgtype.Numeric{}, err
+	}
+	if compareAt == nil {
+		return priceNumeric, pgtype.Numeric{}, nil
+	}
+	if *compareAt <= p {
+		return pgtype.Numeric{}, pgtype.Numeric{}, ErrInvalidPrice
+	}
+	compareAtNumeric, err := price(*compareAt)
+	return priceNumeric, compareAtNumeric, err
+}
+
+func skuPtr(sku string) *string {
+	if sku = strings.TrimSpace(sku); sku == "" {
+		return nil
+	}
+	return &sku
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
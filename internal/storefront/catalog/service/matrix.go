@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"bizbundl/internal/audit"
+	db "bizbundl/internal/db/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// MaxVariants is how many combinations a product's options may make
+const MaxVariants = 100
+
+var (
+	ErrTooManyVariants    = fmt.Errorf("options cannot make more than %d variants", MaxVariants)
+	ErrDuplicateVariant   = errors.New("another variant already has these options")
+	ErrVariantUnavailable = errors.New("this combination is not available")
+	ErrInvalidStock       = errors.New("stock cannot be negative")
+)
+
+// Matrix returns every combination of the values of options, the first
+// option varying slowest. No options make one empty combination.
+func Matrix(options []db.ProductOption) []map[string]string {
+	combos := []map[string]string{{}}
+	for _, o := range options {
+		next := make([]map[string]string, 0, len(combos)*len(o.Values))
+		for _, combo := range combos {
+			for _, value := range o.Values {
+				c := make(map[string]string, len(combo)+1)
+				for k, v := range combo {
+					c[k] = v
+				}
+				c[o.Name] = value
+				next = append(next, c)
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// MatrixChanges counts what generating a product's variants did
+type MatrixChanges struct {
+	Created  int `json:"created"`
+	Updated  int `json:"updated"`
+	Archived int `json:"archived"`
+}
+
+// GenerateVariants makes the product's variants the combinations of its
+// options. A variant only ever stands for one combination, since carts and
+// orders point at it: a combination keeps the variant which has it, and
+// archived variants come back when their combination does. When options are
+// added or removed, a variant whose combination is gone is archived and
+// passes its price, SKU and stock to a combination sharing its values.
+// Other new combinations take the price of a variant they share values
+// with, or the product's. Renamed options and values keep their variants
+// (see UpdateOption).
+func (s *CatalogService) GenerateVariants(ctx context.Context, productID pgtype.UUID) ([]db.ProductVariant, MatrixChanges, error) {
+	auto, err := s.autoTitled(ctx, productID)
+	if err != nil {
+		return nil, MatrixChanges{}, err
+	}
+	return s.generateVariants(ctx, productID, auto)
+}
+
+// generateVariants is GenerateVariants, retitling the variants of auto
+// after their options, as they were named before the options changed
+func (s *CatalogService) generateVariants(ctx context.Context, productID pgtype.UUID, auto map[pgtype.UUID]bool) ([]db.ProductVariant, MatrixChanges, error) {
+	product, err := s.getProduct(ctx, productID)
+	if err != nil {
+		return nil, MatrixChanges{}, err
+	}
+	options, err := s.store.ListProductOptions(ctx, productID)
+	if err != nil {
+		return nil, MatrixChanges{}, err
+	}
+	combos := Matrix(options)
+	if len(combos) > MaxVariants {
+		return nil, MatrixChanges{}, ErrTooManyVariants
+	}
+	current, err := s.store.ListVariantsByProduct(ctx, productID)
+	if err != nil {
+		return nil, MatrixChanges{}, err
+	}
+	archived, err := s.store.ListArchivedVariantsByProduct(ctx, productID)
+	if err != nil {
+		return nil, MatrixChanges{}, err
+	}
+
+	// 1. Combinations a current variant has keep it
+	existing := append(append([]db.ProductVariant{}, current...), archived...)
+	chosen := make([]map[string]string, len(existing))
+	for i, v := range existing {
+		chosen[i] = Choices(v)
+	}
+	kept := make([]int, len(combos))
+	keeps := make([]bool, len(current))
+	for i, combo := range combos {
+		kept[i] = -1
+		for j := range current {
+			if !keeps[j] && sameChoices(chosen[j], combo) {
+				kept[i], keeps[j] = j, true
+				break
+			}
+		}
+	}
+
+	// 2. The others take over from a current variant sharing their values,
+	// in an archived variant having the combination or a new one
+	predecessor := make([]int, len(combos))
+	restored := make([]int, len(combos))
+	replaced := make([]bool, len(current))
+	restores := make([]bool, len(existing))
+	for i, combo := range combos {
+		predecessor[i], restored[i] = -1, -1
+		if kept[i] != -1 {
+			continue
+		}
+		for j := range current {
+			if !keeps[j] && !replaced[j] && overlaps(chosen[j], combo) {
+				predecessor[i], replaced[j] = j, true
+				break
+			}
+		}
+		for j := len(current); j < len(existing); j++ {
+			if !restores[j] && sameChoices(chosen[j], combo) {
+				restored[i], restores[j] = j, true
+				break
+			}
+		}
+	}
+
+	// 3. Variants left without their combination are archived, first, so
+	// their SKUs are free for the variants taking their place
+	var changes MatrixChanges
+	for j, v := range current {
+		if keeps[j] {
+			continue
+		}
+		if _, err := s.store.ArchiveVariant(ctx, v.ID); err != nil {
+			return nil, MatrixChanges{}, fmt.Errorf("failed to archive variant: %w", err)
+		}
+		if replaced[j] && v.Sku != nil {
+			if err := s.store.ReleaseVariantSKU(ctx, v.ID); err != nil {
+				return nil, MatrixChanges{}, fmt.Errorf("failed to archive variant: %w", err)
+			}
+		}
+		changes.Archived++
+	}
+
+	variants := make([]db.ProductVariant, len(combos))
+	for i, combo := range combos {
+		encoded, err := json.Marshal(combo)
+		if err != nil {
+			return nil, MatrixChanges{}, err
+		}
+		title := variantTitle("", combo, options)
+
+		// Kept, or restored, in place
+		if j := max(kept[i], restored[i]); j != -1 {
+			v := existing[j]
+			if !auto[v.ID] {
+				title = v.Title
+			}
+			if title != v.Title || v.Position != int32(i) || v.ArchivedAt.Valid {
+				if v, err = s.store.SetVariantOptions(ctx, db.SetVariantOptionsParams{
+					ID:       v.ID,
+					Title:    title,
+					Options:  encoded,
+					Position: int32(i),
+				}); err != nil {
+					return nil, MatrixChanges{}, fmt.Errorf("failed to update variant: %w", err)
+				}
+				changes.Updated++
+			}
+			if p := predecessor[i]; p != -1 {
+				from := current[p]
+				if v, err = s.store.UpdateProductVariant(ctx, db.UpdateProductVariantParams{
+					ID:             v.ID,
+					Price:          from.Price,
+					CompareAtPrice: from.CompareAtPrice,
+					Sku:            from.Sku,
+					StockQuantity:  from.StockQuantity,
+					IsActive:       from.IsActive,
+				}); err != nil {
+					return nil, MatrixChanges{}, fmt.Errorf("failed to update variant: %w", err)
+				}
+			}
+			variants[i] = v
+			continue
+		}
+
+		// A new combination, taking over from its predecessor, or priced
+		// like a variant sharing its values
+		stock, active := int32(0), true
+		params := db.CreateProductVariantParams{
+			ProductID:     productID,
+			Title:         title,
+			Options:       encoded,
+			Price:         product.BasePrice,
+			StockQuantity: &stock,
+			IsActive:      &active,
+		}
+		if p := predecessor[i]; p != -1 {
+			from := current[p]
+			params.Price, params.CompareAtPrice = from.Price, from.CompareAtPrice
+			params.Sku, params.StockQuantity, params.IsActive = from.Sku, from.StockQuantity, from.IsActive
+		} else {
+			for j := range existing {
+				if overlaps(chosen[j], combo) {
+					params.Price, params.CompareAtPrice = existing[j].Price, existing[j].CompareAtPrice
+					break
+				}
+			}
+		}
+		v, err := s.store.CreateProductVariant(ctx, params)
+		if err != nil {
+			return nil, MatrixChanges{}, fmt.Errorf("failed to create variant: %w", err)
+		}
+		if v.Position != int32(i) {
+			if _, err := s.store.SetVariantPosition(ctx, db.SetVariantPositionParams{ID: v.ID, ProductID: productID, Position: int32(i)}); err != nil {
+				return nil, MatrixChanges{}, fmt.Errorf("failed to create variant: %w", err)
+			}
+			v.Position = int32(i)
+		}
+		variants[i] = v
+		changes.Created++
+	}
+
+	if changes == (MatrixChanges{}) {
+		return variants, changes, nil
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product.variants_generated",
+		EntityType: "product",
+		EntityID:   productID.String(),
+		Before:     map[string]any{"variants": variantTitles(current)},
+		After:      map[string]any{"variants": variantTitles(variants)},
+	})
+	if err != nil {
+		return nil, MatrixChanges{}, err
+	}
+	return variants, changes, nil
+}
+
+// BulkVariantEdit changes the variants of a product having the option
+// values of Match (all of them when empty). Nil fields are left as is.
+type BulkVariantEdit struct {
+	Match          map[string]string
+	Price          *float64
+	CompareAtPrice *float64
+	// ClearCompareAtPrice removes the compare-at price
+	ClearCompareAtPrice bool
+	StockQuantity       *int32
+	// StockAdjustment is added to the stock, after StockQuantity
+	StockAdjustment *int32
+}
+
+// BulkEditVariants applies e to the matching variants, all or none of
+// them, and returns those changed
+func (s *CatalogService) BulkEditVariants(ctx context.Context, productID pgtype.UUID, e BulkVariantEdit) ([]db.ProductVariant, error) {
+	if _, err := s.getProduct(ctx, productID); err != nil {
+		return nil, err
+	}
+	current, err := s.store.ListVariantsByProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything is checked before anything is written
+	var edits []db.UpdateProductVariantParams
+	var matched []db.ProductVariant
+	for _, v := range current {
+		if !matches(Choices(v), e.Match) {
+			continue
+		}
+		p := db.UpdateProductVariantParams{
+			ID:             v.ID,
+			Price:          v.Price,
+			CompareAtPrice: v.CompareAtPrice,
+			StockQuantity:  v.StockQuantity,
+		}
+		if e.Price != nil {
+			if p.Price, err = price(*e.Price); err != nil {
+				return nil, err
+			}
+		}
+		if e.CompareAtPrice != nil {
+			if p.CompareAtPrice, err = price(*e.CompareAtPrice); err != nil {
+				return nil, err
+			}
+		}
+		if e.ClearCompareAtPrice {
+			p.CompareAtPrice = pgtype.Numeric{}
+		}
+		if p.CompareAtPrice.Valid && !above(p.CompareAtPrice, p.Price) {
+			return nil, ErrInvalidPrice
+		}
+		stock := derefInt(v.StockQuantity)
+		if e.StockQuantity != nil {
+			stock = *e.StockQuantity
+		}
+		if e.StockAdjustment != nil {
+			stock += *e.StockAdjustment
+		}
+		if stock < 0 {
+			return nil, ErrInvalidStock
+		}
+		p.StockQuantity = &stock
+		edits = append(edits, p)
+		matched = append(matched, v)
+	}
+
+	variants := make([]db.ProductVariant, len(edits))
+	for i, p := range edits {
+		variant, err := s.store.UpdateProductVariant(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update variant: %w", err)
+		}
+		err = s.audit.Record(ctx, audit.Entry{
+			Action:     "product_variant.updated",
+			EntityType: "product_variant",
+			EntityID:   variant.ID.String(),
+			Before:     variantSnapshot(matched[i]),
+			After:      variantSnapshot(variant),
+		})
+		if err != nil {
+			return nil, err
+		}
+		variants[i] = variant
+	}
+	if len(variants) > 0 {
+		if err := s.pages.InvalidatePages(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return variants, nil
+}
+
+// ListArchivedVariants returns the variants of a product whose options
+// are gone, most recently archived first
+func (s *CatalogService) ListArchivedVariants(ctx context.Context, productID pgtype.UUID) ([]db.ProductVariant, error) {
+	return s.store.ListArchivedVariantsByProduct(ctx, productID)
+}
+
+// ResolveVariant returns the variant of a product with the option values
+// of selection, for customers: archived and inactive variants are not
+// available
+func (s *CatalogService) ResolveVariant(ctx context.Context, productID pgtype.UUID, selection map[string]string) (db.ProductVariant, error) {
+	variants, err := s.store.ListVariantsByProduct(ctx, productID)
+	if err != nil {
+		return db.ProductVariant{}, err
+	}
+	for _, v := range variants {
+		if sameChoices(Choices(v), selection) {
+			if v.IsActive == nil || !*v.IsActive {
+				return db.ProductVariant{}, ErrVariantUnavailable
+			}
+			return v, nil
+		}
+	}
+	return db.ProductVariant{}, ErrVariantUnavailable
+}
+
+// autoTitled returns the variants of a product, archived too, named after
+// their options rather than by staff
+func (s *CatalogService) autoTitled(ctx context.Context, productID pgtype.UUID) (map[pgtype.UUID]bool, error) {
+	options, err := s.store.ListProductOptions(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.store.ListVariantsByProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.store.ListArchivedVariantsByProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	auto := map[pgtype.UUID]bool{}
+	for _, v := range append(current, archived...) {
+		if v.Title == variantTitle("", Choices(v), options) {
+			auto[v.ID] = true
+		}
+	}
+	return auto, nil
+}
+
+// checkDuplicateVariant fails when a current variant of the product other
+// than id has the options of encoded
+func (s *CatalogService) checkDuplicateVariant(ctx context.Context, productID, id pgtype.UUID, encoded []byte) error {
+	var choices map[string]string
+	if err := json.Unmarshal(encoded, &choices); err != nil {
+		return err
+	}
+	variants, err := s.store.ListVariantsByProduct(ctx, productID)
+	if err != nil {
+		return err
+	}
+	for _, v := range variants {
+		if v.ID != id && sameChoices(Choices(v), choices) {
+			return ErrDuplicateVariant
+		}
+	}
+	return nil
+}
+
+// checkMatrixSize fails when options would make too many variants
+func checkMatrixSize(options []db.ProductOption) error {
+	n := 1
+	for _, o := range options {
+		n *= len(o.Values)
+		if n > MaxVariants {
+			return ErrTooManyVariants
+		}
+	}
+	return nil
+}
+
+// Choices returns the option values of a variant, by option name
+func Choices(v db.ProductVariant) map[string]string {
+	choices := map[string]string{}
+	_ = json.Unmarshal(v.Options, &choices)
+	return choices
+}
+
+func sameChoices(a, b map[string]string) bool {
+	return len(a) == len(b) && matches(a, b)
+}
+
+// matches is whether choices has every value of match
+func matches(choices, match map[string]string) bool {
+	for name, value := range match {
+		if choices[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps is whether a variant with choices can take combo after options
+// were added or removed: the options they share have the same values, and
+// they share one, unless either has no options at all
+func overlaps(choices, combo map[string]string) bool {
+	shared := 0
+	for name, value := range choices {
+		if v, ok := combo[name]; ok {
+			if v != value {
+				return false
+			}
+			shared++
+		}
+	}
+	return shared > 0 || len(choices) == 0 || len(combo) == 0
+}
+
+// above is whether a is more than b
+func above(a, b pgtype.Numeric) bool {
+	af, aErr := a.Float64Value()
+	bf, bErr := b.Float64Value()
+	return aErr == nil && bErr == nil && af.Float64 > bf.Float64
+}
+
+func variantTitles(variants []db.ProductVariant) []string {
+	titles := make([]string, len(variants))
+	for i, v := range variants {
+		titles[i] = v.Title
+	}
+	return titles
+}
+
+func derefInt(i *int32) int32 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
@@ -6,29 +6,54 @@ import (
 
 	"strings"
 
+	"bizbundl/internal/audit"
 	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/entitlements"
+	pb "bizbundl/pkgs/page_builder/service"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type CatalogService struct {
-	store db.DBStore
-}
-
-func NewCatalogService(store db.DBStore) *CatalogService {
-	return &CatalogService{store: store}
+	store        db.DBStore
+	entitlements *entitlements.Service
+	audit        *audit.Log
+	// pages show products, so their caches go on every catalog change
+	pages *pb.PageBuilderService
+}
+
+func NewCatalogService(store db.DBStore, entitlements *entitlements.Service) *CatalogService {
+	return &CatalogService{
+		store:        store,
+		entitlements: entitlements,
+		audit:        audit.NewLog(store),
+		pages:        pb.NewPageBuilderService(store, entitlements),
+	}
 }
 
 // -- Categories --
 
 func (s *CatalogService) CreateCategory(ctx context.Context, name string, parentID pgtype.UUID) (db.Category, error) {
 	slug := makeSlug(name)
-	return s.store.CreateCategory(ctx, db.CreateCategoryParams{
+	category, err := s.store.CreateCategory(ctx, db.CreateCategoryParams{
 		Name:     name,
 		Slug:     slug,
 		ParentID: parentID,
 		IsActive: boolPtr(true),
 	})
+	if err != nil {
+		return db.Category{}, err
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "category.created",
+		EntityType: "category",
+		EntityID:   category.ID.String(),
+		After:      category,
+	})
+	if err != nil {
+		return db.Category{}, err
+	}
+	return category, nil
 }
 
 func (s *CatalogService) GetCategory(ctx context.Context, id pgtype.UUID) (db.Category, error) {
@@ -52,6 +77,10 @@ type CreateProductParams struct {
 }
 
 func (s *CatalogService) CreateProduct(ctx context.Context, p CreateProductParams) (db.Product, error) {
+	if err := s.entitlements.Check(ctx, entitlements.Products, 1); err != nil {
+		return db.Product{}, err
+	}
+
 	slug := makeSlug(p.Title)
 
 	priceNumeric := pgtype.Numeric{}
@@ -60,7 +89,7 @@ func (s *CatalogService) CreateProduct(ctx context.Context, p CreateProductParam
 		return db.Product{}, fmt.Errorf("invalid price: %v", err)
 	}
 
-	return s.store.CreateProduct(ctx, db.CreateProductParams{
+	product, err := s.store.CreateProduct(ctx, db.CreateProductParams{
 		Title:       p.Title,
 		Slug:        slug,
 		Description: strPtr(p.Description),
@@ -71,6 +100,19 @@ func (s *CatalogService) CreateProduct(ctx context.Context, p CreateProductParam
 		IsActive:    boolPtr(true),
 		IsFeatured:  boolPtr(p.IsFeatured),
 	})
+	if err != nil {
+		return db.Product{}, err
+	}
+	err = s.changed(ctx, audit.Entry{
+		Action:     "product.created",
+		EntityType: "product",
+		EntityID:   product.ID.String(),
+		After:      product,
+	})
+	if err != nil {
+		return db.Product{}, err
+	}
+	return product, nil
 }
 
 func (s *CatalogService) GetProduct(ctx context.Context, id pgtype.UUID) (db.Product, error) {
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"errors"
+
+	db "bizbundl/internal/db/sqlc"
+	orderService "bizbundl/internal/storefront/order/service"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type UpdateOrderStatusRequest struct {
+	Status        string  `json:"status" validate:"required"`
+	PaymentStatus *string `json:"payment_status"`
+}
+
+// GetOrder shows staff an order and its items
+func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
+	var id pgtype.UUID
+	if err := id.Scan(c.Params("id")); err != nil {
+		return util.APIError(c, fiber.StatusNotFound, orderService.ErrOrderNotFound)
+	}
+	order, items, err := h.orderSvc.GetOrder(c.Context(), id)
+	switch {
+	case errors.Is(err, orderService.ErrOrderNotFound):
+		return util.APIError(c, fiber.StatusNotFound, err)
+	case err != nil:
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, fiber.Map{"order": order, "items": items}, "Order retrieved")
+}
+
+// UpdateStatus lets staff fulfil, complete or cancel an order
+func (h *OrderHandler) UpdateStatus(c *fiber.Ctx) error {
+	var id pgtype.UUID
+	if err := id.Scan(c.Params("id")); err != nil {
+		return util.APIError(c, fiber.StatusNotFound, orderService.ErrOrderNotFound)
+	}
+	var req UpdateOrderStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+
+	order, err := h.orderSvc.UpdateStatus(c.Context(), id, db.OrderStatus(req.Status), req.PaymentStatus)
+	switch {
+	case errors.Is(err, orderService.ErrInvalidStatus):
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	case errors.Is(err, orderService.ErrOrderNotFound):
+		return util.APIError(c, fiber.StatusNotFound, err)
+	case err != nil:
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, order, "Order updated")
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+
+	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/settings"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// GatewayHandler lets shop admins configure their payment gateways
+type GatewayHandler struct {
+	settings *settings.Settings
+}
+
+func NewGatewayHandler(settings *settings.Settings) *GatewayHandler {
+	return &GatewayHandler{settings: settings}
+}
+
+type UpdateGatewayRequest struct {
+	Name       *string         `json:"name"`
+	Config     json.RawMessage `json:"config"` // Credentials, replaced as a whole
+	IsTestMode *bool           `json:"is_test_mode"`
+	IsActive   *bool           `json:"is_active"`
+	Position   *int32          `json:"position"`
+}
+
+// GatewayResponse leaves out the credentials: they are write-only
+type GatewayResponse struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Configured bool   `json:"configured"`
+	IsTestMode bool   `json:"is_test_mode"`
+	IsActive   bool   `json:"is_active"`
+	Position   int32  `json:"position"`
+}
+
+func newGatewayResponse(pg db.PaymentGateway) GatewayResponse {
+	res := GatewayResponse{
+		ID:         pg.ID,
+		Name:       pg.Name,
+		Configured: len(pg.Config) > 0 && string(pg.Config) != "{}",
+	}
+	if pg.IsTestMode != nil {
+		res.IsTestMode = *pg.IsTestMode
+	}
+	if pg.IsActive != nil {
+		res.IsActive = *pg.IsActive
+	}
+	if pg.Position != nil {
+		res.Position = *pg.Position
+	}
+	return res
+}
+
+// List returns the shop's payment gateways
+func (h *GatewayHandler) List(c *fiber.Ctx) error {
+	gateways, err := h.settings.ListPaymentGateways(c.Context())
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	res := make([]GatewayResponse, len(gateways))
+	for i, pg := range gateways {
+		res[i] = newGatewayResponse(pg)
+	}
+	return util.JSON(c, fiber.StatusOK, res, "Payment gateways")
+}
+
+// Update changes a gateway's credentials and settings. It runs behind
+// RequireStepUp.
+func (h *GatewayHandler) Update(c *fiber.Ctx) error {
+	var req UpdateGatewayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	var config map[string]any
+	if req.Config != nil && json.Unmarshal(req.Config, &config) != nil {
+		return util.APIError(c, fiber.StatusBadRequest, fiber.NewError(fiber.StatusBadRequest, "config must be a JSON object"))
+	}
+
+	pg, err := h.settings.UpdatePaymentGateway(c.Context(), db.UpdatePaymentGatewayParams{
+		ID:         c.Params("id"),
+		Name:       req.Name,
+		Config:     req.Config,
+		IsTestMode: req.IsTestMode,
+		IsActive:   req.IsActive,
+		Position:   req.Position,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return util.APIError(c, fiber.StatusNotFound, fiber.NewError(fiber.StatusNotFound, "Payment gateway not found"))
+	}
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, newGatewayResponse(*pg), "Payment gateway updated")
+}
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 
 	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/entitlements"
 	"bizbundl/internal/storefront/cart/service"
 	catalogService "bizbundl/internal/storefront/catalog/service"
 	orderService "bizbundl/internal/storefront/order/service"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/a-h/templ"
 	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
 )
 
 type OrderHandler struct {
@@ -123,6 +126,11 @@ func (h *OrderHandler) Checkout(c *fiber.Ctx) error {
 		order, err = h.orderSvc.CreateOrderFromCart(c.Context(), userID, cart.ID)
 	}
 
+	if errors.Is(err, entitlements.ErrUpgradeRequired) {
+		// The upgrade hint is for the owner, not the customer
+		log.Warn().Err(err).Msg("order rejected by plan limit")
+		return h.renderHTMXError(c, "This shop cannot take orders right now. Please try again later.")
+	}
 	if err != nil {
 		return h.renderHTMXError(c, fmt.Sprintf("Checkout failed: %v", err))
 	}
@@ -1,6 +1,10 @@
 package order
 
 import (
+	"bizbundl/internal/constants"
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/permissions"
+	"bizbundl/internal/settings"
 	cartService "bizbundl/internal/storefront/cart/service"
 	catalogService "bizbundl/internal/storefront/catalog/service"
 	"bizbundl/internal/storefront/order/handler"
@@ -15,7 +19,7 @@ type Module struct {
 }
 
 func Init(app *server.Server, cartSvc *cartService.CartService, catalogSvc *catalogService.CatalogService) *Module {
-	svc := service.NewOrderService(app.GetDB())
+	svc := service.NewOrderService(app.GetDB(), app.GetEntitlements())
 
 	// Payment GW
 	pgw := uddoktapay.New("") // Uses default Sandbox Key internaly
@@ -29,6 +33,23 @@ func Init(app *server.Server, cartSvc *cartService.CartService, catalogSvc *cata
 	g.Get("/payment/callback", h.PaymentCallback)
 	g.Get("/success/:id", h.SuccessPage)
 
+	// Orders, for staff allowed to see them, and fulfilment, for those
+	// allowed to fulfil them (and API keys holding the scopes)
+	twoFactor := middleware.RequireTwoFactor(app.GetSessions())
+	orders := app.GetRouter().Group("/api/v1/admin/orders")
+	orders.Get("/:id", middleware.RequirePermission(app.GetPermissions(), permissions.OrdersView), twoFactor, h.GetOrder)
+	orders.Put("/:id/status", middleware.RequirePermission(app.GetPermissions(), permissions.OrdersFulfill), twoFactor, h.UpdateStatus)
+
+	// Gateway credentials: admins with two-factor (where the shop requires
+	// it), who confirmed it's them before changing them
+	gw := handler.NewGatewayHandler(settings.NewSettings(app.GetDB()))
+	gateways := app.GetRouter().Group("/api/v1/admin/payment-gateways",
+		middleware.RequirePermission(app.GetPermissions(), permissions.SettingsManage),
+		middleware.RequireTwoFactor(app.GetSessions()),
+	)
+	gateways.Get("/", gw.List)
+	gateways.Put("/:id", middleware.RequireStepUp(app.GetSessions(), constants.StepUpWindow), gw.Update)
+
 	return &Module{
 		handler: h,
 		service: svc,
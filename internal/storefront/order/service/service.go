@@ -2,19 +2,30 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"bizbundl/internal/audit"
 	db "bizbundl/internal/db/sqlc"
+	"bizbundl/internal/entitlements"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrInvalidStatus = errors.New("unknown order status")
+)
+
 type OrderService struct {
-	store db.DBStore
+	store        db.DBStore
+	entitlements *entitlements.Service
+	audit        *audit.Log
 }
 
-func NewOrderService(store db.DBStore) *OrderService {
-	return &OrderService{store: store}
+func NewOrderService(store db.DBStore, entitlements *entitlements.Service) *OrderService {
+	return &OrderService{store: store, entitlements: entitlements, audit: audit.NewLog(store)}
 }
 
 // OrderItemDTO helper for internal use
@@ -28,6 +39,10 @@ type OrderItemDTO struct {
 
 // createOrderCore handles the actual DB insertion
 func (s *OrderService) createOrderCore(ctx context.Context, userID pgtype.UUID, items []OrderItemDTO, total float64) (*db.Order, error) {
+	if err := s.entitlements.Check(ctx, entitlements.MonthlyOrders, 1); err != nil {
+		return nil, err
+	}
+
 	totalNumeric := pgtype.Numeric{}
 	totalNumeric.Scan(fmt.Sprintf("%.2f", total))
 
@@ -159,6 +174,9 @@ func (s *OrderService) CreateOrderDirect(ctx context.Context, userID pgtype.UUID
 
 func (s *OrderService) GetOrder(ctx context.Context, id pgtype.UUID) (*db.Order, []db.OrderItem, error) {
 	o, err := s.store.GetOrder(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrOrderNotFound
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -168,3 +186,52 @@ func (s *OrderService) GetOrder(ctx context.Context, id pgtype.UUID) (*db.Order,
 	}
 	return &o, items, nil
 }
+
+// UpdateStatus moves an order along (processing, shipped, ...) and, when
+// paymentStatus is set, records its payment status. Staff changes are
+// audited.
+func (s *OrderService) UpdateStatus(ctx context.Context, id pgtype.UUID, status db.OrderStatus, paymentStatus *string) (*db.Order, error) {
+	switch status {
+	case db.OrderStatusPending, db.OrderStatusProcessing, db.OrderStatusShipped, db.OrderStatusCompleted, db.OrderStatusCancelled:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	before, err := s.store.GetOrder(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if paymentStatus == nil {
+		paymentStatus = before.PaymentStatus
+	}
+
+	o, err := s.store.UpdateOrderStatus(ctx, db.UpdateOrderStatusParams{
+		ID:            id,
+		Status:        db.NullOrderStatus{OrderStatus: status, Valid: true},
+		PaymentStatus: paymentStatus,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+	err = s.audit.Record(ctx, audit.Entry{
+		Action:     "order.updated",
+		EntityType: "order",
+		EntityID:   o.ID.String(),
+		Before:     orderSnapshot(before),
+		After:      orderSnapshot(o),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// orderSnapshot is what the audit log compares of an order
+func orderSnapshot(o db.Order) map[string]any {
+	return map[string]any{
+		"status":         string(o.Status.OrderStatus),
+		"payment_status": o.PaymentStatus,
+	}
+}
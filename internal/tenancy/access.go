@@ -0,0 +1,47 @@
+package tenancy
+
+import (
+	"net"
+	"strings"
+)
+
+// Subscription statuses that take a shop offline
+var lapsedSubscriptions = map[string]bool{
+	"canceled": true,
+	"unpaid":   true,
+}
+
+// Unavailable reports whether the shop must not serve any traffic:
+// suspended, deactivated, or its subscription lapsed.
+func (t *Tenant) Unavailable() bool {
+	if t.IsPlatform() {
+		return false
+	}
+	return t.Suspended || !t.IsActive || lapsedSubscriptions[t.SubscriptionStatus]
+}
+
+// PasswordProtected reports whether the storefront sits behind a password gate
+func (t *Tenant) PasswordProtected() bool {
+	return t.PasswordHash != ""
+}
+
+// AllowsIP reports whether ip is on the maintenance allowlist.
+// Entries are plain IPs or CIDR ranges.
+func (t *Tenant) AllowsIP(ip string) bool {
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return false
+	}
+	for _, entry := range t.MaintenanceAllowlist {
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package tenancy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantUnavailable(t *testing.T) {
+	assert.False(t, (&Tenant{TenantID: "shop_a", IsActive: true}).Unavailable())
+	assert.False(t, (&Tenant{TenantID: "shop_a", IsActive: true, SubscriptionStatus: "active"}).Unavailable())
+
+	assert.True(t, (&Tenant{TenantID: "shop_a", IsActive: true, Suspended: true}).Unavailable())
+	assert.True(t, (&Tenant{TenantID: "shop_a", IsActive: false}).Unavailable())
+	assert.True(t, (&Tenant{TenantID: "shop_a", IsActive: true, SubscriptionStatus: "unpaid"}).Unavailable())
+
+	assert.False(t, platformTenant.Unavailable())
+}
+
+func TestTenantAllowsIP(t *testing.T) {
+	tenant := &Tenant{MaintenanceAllowlist: []string{"203.0.113.7", "10.0.0.0/8", "not-an-ip"}}
+
+	assert.True(t, tenant.AllowsIP("203.0.113.7"))
+	assert.True(t, tenant.AllowsIP("10.20.30.40"))
+	assert.False(t, tenant.AllowsIP("203.0.113.8"))
+	assert.False(t, tenant.AllowsIP(""))
+}
@@ -0,0 +1,368 @@
+package tenancy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	platformdb "bizbundl/internal/db/sqlc/platform"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/patrickmn/go-cache"
+	redisLib "github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// PublicSchema is the schema used for platform (non-shop) hosts.
+const PublicSchema = "public"
+
+const (
+	// Redis (L2) cache lifetime for a resolved host
+	redisTTL = 10 * time.Minute
+	// In-memory (L1) cache lifetime. Kept short so nodes that miss an
+	// invalidation message still converge quickly.
+	localTTL = 30 * time.Second
+	// Unknown hosts are cached briefly to protect the DB from random Host headers
+	notFoundTTL = 30 * time.Second
+
+	redisKeyPrefix      = "tenancy:host:"
+	invalidationChannel = "tenancy:invalidate"
+)
+
+var ErrShopNotFound = errors.New("shop not found")
+
+// Tenant is the routing record resolved from a request host.
+type Tenant struct {
+	ShopID       string `json:"shop_id"`
+	TenantID     string `json:"tenant_id"`
+	Subdomain    string `json:"subdomain"`
+	CustomDomain string `json:"custom_domain,omitempty"`
+	IsActive     bool   `json:"is_active"`
+	// Cluster is the database cluster holding the schema (see internal/db/cluster)
+	Cluster string `json:"cluster,omitempty"`
+	// Frozen is set while the tenant moves between clusters: reads only
+	Frozen bool `json:"frozen,omitempty"`
+	// AppVersion is the cohort the shop is pinned to ("" = unpinned)
+	AppVersion string `json:"app_version,omitempty"`
+	// SchemaVersion is the tenant schema's migration version (0 = unknown)
+	SchemaVersion int64 `json:"schema_version,omitempty"`
+	// Access state, enforced by middleware.ShopGate (see access.go)
+	Suspended          bool   `json:"suspended,omitempty"`
+	SuspensionReason   string `json:"suspension_reason,omitempty"`
+	SubscriptionStatus string `json:"subscription_status,omitempty"`
+	// Plan is the subscription plan key ("" = no subscription, see internal/entitlements)
+	Plan                 string   `json:"plan,omitempty"`
+	Maintenance          bool     `json:"maintenance,omitempty"`
+	MaintenanceAllowlist []string `json:"maintenance_allowlist,omitempty"`
+	PasswordHash         string   `json:"password_hash,omitempty"`
+	// RequireTwoFactor keeps staff without a second factor out of the admin
+	RequireTwoFactor bool `json:"require_two_factor,omitempty"`
+	// NotFound marks a negative cache entry
+	NotFound bool `json:"not_found,omitempty"`
+}
+
+// ContextKey carries the request's *Tenant in the request context. It is
+// also the Fiber local, so c.Context() resolves it too.
+var ContextKey = "tenant"
+
+// FromContext returns the tenant of the request, nil outside tenant requests
+// (workers, provisioning)
+func FromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(ContextKey).(*Tenant)
+	return t
+}
+
+// IsPlatform reports whether the tenant is the platform itself (public schema)
+func (t *Tenant) IsPlatform() bool {
+	return t.TenantID == PublicSchema
+}
+
+var platformTenant = &Tenant{TenantID: PublicSchema, IsActive: true}
+
+// ShopLookup is the subset of platform queries the resolver needs
+type ShopLookup interface {
+	GetShopBySubdomain(ctx context.Context, subdomain string) (platformdb.Shop, error)
+	GetShopByCustomDomain(ctx context.Context, customDomain *string) (platformdb.Shop, error)
+	GetTenantMigration(ctx context.Context, tenantID string) (platformdb.TenantMigration, error)
+	GetLatestSubscriptionByShop(ctx context.Context, shopID pgtype.UUID) (platformdb.Subscription, error)
+}
+
+// Resolver maps a request host to a shop's tenant (schema) using the shop registry
+// in the platform schema. Results are cached in memory (L1) and Redis (L2).
+type Resolver struct {
+	shops          ShopLookup
+	redis          *redisLib.Client
+	local          *cache.Cache
+	platformDomain string
+}
+
+// NewResolver creates a Resolver. The redis client is optional (nil disables L2).
+func NewResolver(shops ShopLookup, rc *redisLib.Client, platformDomain string) *Resolver {
+	return &Resolver{
+		shops:          shops,
+		redis:          rc,
+		local:          cache.New(localTTL, 2*localTTL),
+		platformDomain: strings.ToLower(strings.TrimSpace(platformDomain)),
+	}
+}
+
+// Resolve returns the tenant serving the given host.
+// Platform hosts resolve to the public schema; unknown hosts return ErrShopNotFound.
+func (r *Resolver) Resolve(ctx context.Context, host string) (*Tenant, error) {
+	host = NormalizeHost(host)
+	if r.isPlatformHost(host) {
+		return platformTenant, nil
+	}
+
+	// 1. L1 (Memory)
+	if val, ok := r.local.Get(host); ok {
+		return found(val.(*Tenant))
+	}
+
+	// 2. L2 (Redis)
+	if t, ok := r.getRedis(ctx, host); ok {
+		r.local.Set(host, t, localTTL)
+		return found(t)
+	}
+
+	// 3. Shop Registry (DB)
+	t, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := redisTTL
+	if t.NotFound {
+		ttl = notFoundTTL
+	}
+	r.local.Set(host, t, min(localTTL, ttl))
+	r.setRedis(ctx, host, t, ttl)
+
+	return found(t)
+}
+
+func found(t *Tenant) (*Tenant, error) {
+	if t.NotFound {
+		return nil, ErrShopNotFound
+	}
+	return t, nil
+}
+
+// lookup queries the shop registry. Hosts under the platform domain are matched
+// by subdomain, anything else by custom domain.
+func (r *Resolver) lookup(ctx context.Context, host string) (*Tenant, error) {
+	var shop platformdb.Shop
+	var err error
+
+	if sub, ok := r.subdomainOf(host); ok {
+		shop, err = r.shops.GetShopBySubdomain(ctx, sub)
+	} else {
+		shop, err = r.shops.GetShopByCustomDomain(ctx, &host)
+		// "www.neonvibes.com" should reach the shop registered as "neonvibes.com"
+		if errors.Is(err, pgx.ErrNoRows) && strings.HasPrefix(host, "www.") {
+			bare := strings.TrimPrefix(host, "www.")
+			shop, err = r.shops.GetShopByCustomDomain(ctx, &bare)
+		}
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Tenant{NotFound: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup shop for host %s: %w", host, err)
+	}
+	// Offboarded shops stop routing as soon as they are deleted
+	if shop.DeletedAt.Valid {
+		return &Tenant{NotFound: true}, nil
+	}
+
+	t := tenantFromShop(shop)
+
+	// Schema version, for version pinning (see VersionPolicy)
+	m, err := r.shops.GetTenantMigration(ctx, shop.TenantID)
+	if err == nil {
+		t.SchemaVersion = m.Version
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to lookup schema version for %s: %w", shop.TenantID, err)
+	}
+
+	// Subscription state, for suspension of lapsed shops
+	sub, err := r.shops.GetLatestSubscriptionByShop(ctx, shop.ID)
+	if err == nil {
+		if sub.Status != nil {
+			t.SubscriptionStatus = *sub.Status
+		}
+		t.Plan = sub.PlanName
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to lookup subscription for %s: %w", shop.TenantID, err)
+	}
+
+	return t, nil
+}
+
+func tenantFromShop(shop platformdb.Shop) *Tenant {
+	t := &Tenant{
+		ShopID:    shop.ID.String(),
+		TenantID:  shop.TenantID,
+		Subdomain: shop.Subdomain,
+		IsActive:  shop.IsActive != nil && *shop.IsActive,
+		Cluster:   shop.DbCluster,
+		Frozen:    shop.FrozenAt.Valid,
+	}
+	if shop.CustomDomain != nil {
+		t.CustomDomain = *shop.CustomDomain
+	}
+	if shop.AppVersion != nil {
+		t.AppVersion = *shop.AppVersion
+	}
+	if shop.SuspendedAt.Valid {
+		t.Suspended = true
+		if shop.SuspensionReason != nil {
+			t.SuspensionReason = *shop.SuspensionReason
+		}
+	}
+	t.Maintenance = shop.MaintenanceMode
+	t.RequireTwoFactor = shop.RequireTwoFactor
+	t.MaintenanceAllowlist = shop.MaintenanceAllowlist
+	if shop.StorefrontPasswordHash != nil {
+		t.PasswordHash = *shop.StorefrontPasswordHash
+	}
+	return t
+}
+
+// -- Host Parsing --
+
+// NormalizeHost lowercases the host and strips the port and any trailing dot
+func NormalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	return strings.TrimSuffix(host, ".")
+}
+
+func (r *Resolver) isPlatformHost(host string) bool {
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1":
+		return true
+	case r.platformDomain, "www." + r.platformDomain:
+		return true
+	}
+	// Bare IPs never identify a shop
+	return net.ParseIP(host) != nil
+}
+
+// subdomainOf extracts the shop label from "<label>.<platformDomain>".
+// Nested labels (a.b.<platformDomain>) are not shop subdomains.
+func (r *Resolver) subdomainOf(host string) (string, bool) {
+	if r.platformDomain == "" {
+		return "", false
+	}
+	label, ok := strings.CutSuffix(host, "."+r.platformDomain)
+	if !ok || label == "" || strings.Contains(label, ".") {
+		return "", false
+	}
+	return label, true
+}
+
+// -- Invalidation --
+
+// HostsForShop lists every host a shop can be reached on
+func (r *Resolver) HostsForShop(subdomain string, customDomain *string) []string {
+	hosts := []string{}
+	if subdomain != "" && r.platformDomain != "" {
+		hosts = append(hosts, subdomain+"."+r.platformDomain)
+	}
+	if customDomain != nil && *customDomain != "" {
+		d := NormalizeHost(*customDomain)
+		hosts = append(hosts, d, "www."+d)
+	}
+	return hosts
+}
+
+// InvalidateShop drops cached entries for all hosts of the given shop
+func (r *Resolver) InvalidateShop(ctx context.Context, shop platformdb.Shop) {
+	r.Invalidate(ctx, r.HostsForShop(shop.Subdomain, shop.CustomDomain)...)
+}
+
+// Invalidate drops cached entries for the given hosts on this node and in Redis,
+// and notifies other nodes to drop their in-memory copies.
+func (r *Resolver) Invalidate(ctx context.Context, hosts ...string) {
+	if len(hosts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = NormalizeHost(h)
+		r.local.Delete(h)
+		keys = append(keys, redisKeyPrefix+h)
+	}
+	if r.redis == nil {
+		return
+	}
+	if err := r.redis.Del(ctx, keys...).Err(); err != nil {
+		log.Warn().Err(err).Strs("hosts", hosts).Msg("tenancy: failed to delete cached hosts")
+	}
+	if err := r.redis.Publish(ctx, invalidationChannel, strings.Join(hosts, ",")).Err(); err != nil {
+		log.Warn().Err(err).Msg("tenancy: failed to publish invalidation")
+	}
+}
+
+// Listen drops in-memory entries when other nodes publish invalidations.
+// It blocks until ctx is cancelled, so run it in a goroutine.
+func (r *Resolver) Listen(ctx context.Context) {
+	if r.redis == nil {
+		return
+	}
+	sub := r.redis.Subscribe(ctx, invalidationChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			for _, h := range strings.Split(msg.Payload, ",") {
+				r.local.Delete(NormalizeHost(h))
+			}
+		}
+	}
+}
+
+// -- Redis (L2) --
+
+func (r *Resolver) getRedis(ctx context.Context, host string) (*Tenant, bool) {
+	if r.redis == nil {
+		return nil, false
+	}
+	val, err := r.redis.Get(ctx, redisKeyPrefix+host).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	var t Tenant
+	if err := json.Unmarshal(val, &t); err != nil {
+		return nil, false
+	}
+	return &t, true
+}
+
+func (r *Resolver) setRedis(ctx context.Context, host string, t *Tenant, ttl time.Duration) {
+	if r.redis == nil {
+		return
+	}
+	bytes, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	r.redis.Set(ctx, redisKeyPrefix+host, bytes, ttl)
+}
@@ -0,0 +1,136 @@
+package tenancy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	platformdb "bizbundl/internal/db/sqlc/platform"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeShops struct {
+	bySubdomain map[string]platformdb.Shop
+	byDomain    map[string]platformdb.Shop
+	calls       int
+}
+
+func (f *fakeShops) GetShopBySubdomain(ctx context.Context, subdomain string) (platformdb.Shop, error) {
+	f.calls++
+	if shop, ok := f.bySubdomain[subdomain]; ok {
+		return shop, nil
+	}
+	return platformdb.Shop{}, pgx.ErrNoRows
+}
+
+func (f *fakeShops) GetShopByCustomDomain(ctx context.Context, customDomain *string) (platformdb.Shop, error) {
+	f.calls++
+	if shop, ok := f.byDomain[*customDomain]; ok {
+		return shop, nil
+	}
+	return platformdb.Shop{}, pgx.ErrNoRows
+}
+
+func (f *fakeShops) GetTenantMigration(ctx context.Context, tenantID string) (platformdb.TenantMigration, error) {
+	return platformdb.TenantMigration{TenantID: tenantID, Version: 7}, nil
+}
+
+func (f *fakeShops) GetLatestSubscriptionByShop(ctx context.Context, shopID pgtype.UUID) (platformdb.Subscription, error) {
+	return platformdb.Subscription{}, pgx.ErrNoRows
+}
+
+func newTestResolver() (*Resolver, *fakeShops) {
+	domain := "neonvibes.com"
+	active := true
+	shop := platformdb.Shop{
+		Subdomain:    "neon-vibes",
+		TenantID:     "shop_neon_vibes",
+		CustomDomain: &domain,
+		IsActive:     &active,
+	}
+	shops := &fakeShops{
+		bySubdomain: map[string]platformdb.Shop{"neon-vibes": shop},
+		byDomain:    map[string]platformdb.Shop{"neonvibes.com": shop},
+	}
+	return NewResolver(shops, nil, "bizbundl.com"), shops
+}
+
+func TestResolvePlatformHosts(t *testing.T) {
+	r, shops := newTestResolver()
+	ctx := context.Background()
+
+	for _, host := range []string{"bizbundl.com", "www.bizbundl.com", "localhost:8080", "127.0.0.1", "[::1]:8080", "10.0.0.4"} {
+		tenant, err := r.Resolve(ctx, host)
+		require.NoError(t, err, host)
+		assert.True(t, tenant.IsPlatform(), host)
+	}
+	assert.Equal(t, 0, shops.calls)
+}
+
+func TestResolveShopHosts(t *testing.T) {
+	ctx := context.Background()
+
+	for _, host := range []string{"neon-vibes.bizbundl.com", "NEON-VIBES.bizbundl.com:443", "neonvibes.com", "www.neonvibes.com"} {
+		r, _ := newTestResolver()
+		tenant, err := r.Resolve(ctx, host)
+		require.NoError(t, err, host)
+		assert.Equal(t, "shop_neon_vibes", tenant.TenantID, host)
+		assert.True(t, tenant.IsActive)
+		assert.Equal(t, int64(7), tenant.SchemaVersion)
+	}
+}
+
+func TestResolveUnknownHost(t *testing.T) {
+	r, shops := newTestResolver()
+	ctx := context.Background()
+
+	// Previously "www.shop.example.com" resolved to schema "www"
+	for _, host := range []string{"www.shop.example.com", "ghost.bizbundl.com", "a.b.bizbundl.com"} {
+		_, err := r.Resolve(ctx, host)
+		assert.ErrorIs(t, err, ErrShopNotFound, host)
+	}
+
+	// Negative results are cached
+	calls := shops.calls
+	_, err := r.Resolve(ctx, "ghost.bizbundl.com")
+	assert.ErrorIs(t, err, ErrShopNotFound)
+	assert.Equal(t, calls, shops.calls)
+}
+
+func TestResolveCachesAndInvalidates(t *testing.T) {
+	r, shops := newTestResolver()
+	ctx := context.Background()
+
+	_, err := r.Resolve(ctx, "neon-vibes.bizbundl.com")
+	require.NoError(t, err)
+	_, err = r.Resolve(ctx, "neon-vibes.bizbundl.com")
+	require.NoError(t, err)
+	assert.Equal(t, 1, shops.calls)
+
+	// Shop renamed its schema; invalidation must force a fresh lookup
+	shop := shops.bySubdomain["neon-vibes"]
+	shop.TenantID = "shop_neon_vibes_v2"
+	shops.bySubdomain["neon-vibes"] = shop
+	r.InvalidateShop(ctx, shop)
+
+	tenant, err := r.Resolve(ctx, "neon-vibes.bizbundl.com")
+	require.NoError(t, err)
+	assert.Equal(t, "shop_neon_vibes_v2", tenant.TenantID)
+	assert.Equal(t, 2, shops.calls)
+}
+
+func TestResolveDeletedShop(t *testing.T) {
+	r, shops := newTestResolver()
+	ctx := context.Background()
+
+	shop := shops.bySubdomain["neon-vibes"]
+	shop.DeletedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	shops.bySubdomain["neon-vibes"] = shop
+
+	_, err := r.Resolve(ctx, "neon-vibes.bizbundl.com")
+	assert.ErrorIs(t, err, ErrShopNotFound)
+}
@@ -0,0 +1,47 @@
+package tenancy
+
+import "strconv"
+
+// VersionPolicy describes which tenants this binary may serve during a
+// blue/green rollout (see docs/deployment.md).
+//
+// A tenant is served when it is pinned to AppVersion (or unpinned) and its
+// schema is at SchemaVersion. Anything else belongs to another cohort.
+type VersionPolicy struct {
+	// AppVersion of this binary. Empty disables pinning.
+	AppVersion string
+	// SchemaVersion this binary was built against (latest tenant migration)
+	SchemaVersion int64
+	// Header set on misdirected responses with the tenant's app version,
+	// so an upstream proxy can route the request to the right cohort.
+	Header string
+}
+
+// Enabled reports whether version pinning is active
+func (p VersionPolicy) Enabled() bool {
+	return p.AppVersion != ""
+}
+
+// Serves reports whether this binary may serve the tenant.
+// Platform hosts and tenants without a recorded schema version are always served.
+func (p VersionPolicy) Serves(t *Tenant) bool {
+	if !p.Enabled() || t.IsPlatform() {
+		return true
+	}
+	if t.AppVersion != "" && t.AppVersion != p.AppVersion {
+		return false
+	}
+	if t.SchemaVersion != 0 && p.SchemaVersion != 0 && t.SchemaVersion != p.SchemaVersion {
+		return false
+	}
+	return true
+}
+
+// RouteHint is the value of the routing header for a tenant this binary
+// does not serve: its pinned app version, or its schema version when unpinned.
+func (p VersionPolicy) RouteHint(t *Tenant) string {
+	if t.AppVersion != "" {
+		return t.AppVersion
+	}
+	return "schema-" + strconv.FormatInt(t.SchemaVersion, 10)
+}
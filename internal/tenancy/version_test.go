@@ -0,0 +1,28 @@
+package tenancy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionPolicyServes(t *testing.T) {
+	policy := VersionPolicy{AppVersion: "v1.1", SchemaVersion: 12, Header: "X-App-Version"}
+
+	assert.True(t, policy.Serves(platformTenant))
+	assert.True(t, policy.Serves(&Tenant{TenantID: "shop_a", SchemaVersion: 12}), "unpinned, schema matches")
+	assert.True(t, policy.Serves(&Tenant{TenantID: "shop_a", AppVersion: "v1.1", SchemaVersion: 12}))
+	assert.True(t, policy.Serves(&Tenant{TenantID: "shop_a"}), "unknown schema version")
+
+	assert.False(t, policy.Serves(&Tenant{TenantID: "shop_a", AppVersion: "v1.0", SchemaVersion: 12}), "other cohort")
+	assert.False(t, policy.Serves(&Tenant{TenantID: "shop_a", SchemaVersion: 11}), "schema not migrated yet")
+
+	disabled := VersionPolicy{}
+	assert.True(t, disabled.Serves(&Tenant{TenantID: "shop_a", AppVersion: "v1.0", SchemaVersion: 11}))
+}
+
+func TestVersionPolicyRouteHint(t *testing.T) {
+	policy := VersionPolicy{AppVersion: "v1.1"}
+	assert.Equal(t, "v1.0", policy.RouteHint(&Tenant{AppVersion: "v1.0", SchemaVersion: 11}))
+	assert.Equal(t, "schema-11", policy.RouteHint(&Tenant{SchemaVersion: 11}))
+}
@@ -85,6 +85,7 @@ func Cleanup(t *testing.T) {
 		"cart_items", "carts",
 		"order_items", "orders",
 		"sessions",
+		"api_keys", "audit_log",
 		"product_variants", "products", "categories",
 		"users",
 	}
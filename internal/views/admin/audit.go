@@ -0,0 +1,53 @@
+package admin
+
+import (
+	"bizbundl/internal/audit"
+	auditview "bizbundl/internal/views/admin/audit"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// auditPageSize is how many entries the audit log shows at a time
+const auditPageSize = 50
+
+// auditLog serves the shop's audit trail and its CSV export
+type auditLog struct {
+	log *audit.Log
+}
+
+func (h auditLog) page(c *fiber.Ctx) error {
+	f, err := audit.FilterFromQuery(c.Query)
+	if err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	rows, err := h.log.List(c.Context(), f, auditPageSize+1)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	var next *audit.Cursor
+	if len(rows) > auditPageSize {
+		rows = rows[:auditPageSize]
+		next = audit.CursorOf(rows[len(rows)-1])
+	}
+
+	c.Locals("cache_skip", true)
+	if c.Get("HX-Request") == "true" {
+		return util.Render(c, auditview.Entries(f, rows, next))
+	}
+	return util.Render(c, auditview.Page(f, rows, next))
+}
+
+func (h auditLog) export(c *fiber.Ctx) error {
+	f, err := audit.FilterFromQuery(c.Query)
+	if err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	c.Locals("cache_skip", true)
+	if err := h.log.Export(c.Context(), c.Response().BodyWriter(), f); err != nil {
+		c.Response().ResetBody()
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	c.Attachment("audit-log.csv")
+	return nil
+}
@@ -1,14 +1,34 @@
 package admin
 
 import (
+	"bizbundl/internal/audit"
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/permissions"
 	"bizbundl/internal/server"
+
+	"github.com/gofiber/fiber/v2"
 )
 
 func Init(server *server.Server) {
 	router := server.GetRouter()
-	adminRouter := router.Group("/admin")
+	// Staff only (with two-factor, where the shop requires it); routes may
+	// require a specific permission on top
+	adminRouter := router.Group("/admin",
+		middleware.RequirePermission(server.GetPermissions(), ""),
+		middleware.RequireTwoFactor(server.GetSessions()),
+	)
 
 	for _, route := range Routes {
-		adminRouter.Add(route.Method, route.Path, route.Handler)
+		handlers := []fiber.Handler{route.Handler}
+		if route.Permission != "" {
+			handlers = append([]fiber.Handler{middleware.RequirePermission(server.GetPermissions(), route.Permission)}, handlers...)
+		}
+		adminRouter.Add(route.Method, route.Path, handlers...)
 	}
+
+	// Who changed what, for those who manage the shop
+	trail := auditLog{log: audit.NewLog(server.GetDB())}
+	settingsManage := middleware.RequirePermission(server.GetPermissions(), permissions.SettingsManage)
+	adminRouter.Get("/audit", settingsManage, trail.page)
+	adminRouter.Get("/audit/export", settingsManage, trail.export)
 }
@@ -1,6 +1,9 @@
 package admin
 
 import (
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/permissions"
+	"bizbundl/internal/views/admin/access"
 	dashboard "bizbundl/internal/views/admin/dashboard"
 	"bizbundl/util"
 
@@ -11,6 +14,8 @@ type ViewRoute struct {
 	Path    string
 	Method  string
 	Handler fiber.Handler
+	// Permission is required on top of being staff ("" = any staff)
+	Permission string
 }
 
 var Routes = []ViewRoute{
@@ -21,4 +26,12 @@ var Routes = []ViewRoute{
 			return util.Render(c, dashboard.Dashboard())
 		},
 	},
+	{
+		Path:   "/permissions",
+		Method: "GET",
+		Handler: func(c *fiber.Ctx) error {
+			viewer, _ := c.Locals(middleware.PermissionsKey).(permissions.Access)
+			return util.Render(c, access.Permissions(viewer))
+		},
+	},
 }
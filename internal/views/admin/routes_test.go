@@ -2,12 +2,15 @@ package admin
 
 import (
 	"testing"
+
+	"bizbundl/internal/permissions"
 )
 
 // go test ./internal/views/frontend/...
 func TestRequiredRoutesExist(t *testing.T) {
 	requiredPaths := []string{
 		"/",
+		"/permissions",
 	}
 
 	for _, required := range requiredPaths {
@@ -23,3 +26,11 @@ func TestRequiredRoutesExist(t *testing.T) {
 		}
 	}
 }
+
+func TestRoutePermissionsRegistered(t *testing.T) {
+	for _, route := range Routes {
+		if route.Permission != "" && !permissions.Known(route.Permission) {
+			t.Errorf("Route %s requires unregistered permission %q", route.Path, route.Permission)
+		}
+	}
+}
@@ -9,7 +9,9 @@ import (
 	pb "bizbundl/pkgs/page_builder/service"
 	"bizbundl/util"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -102,8 +104,78 @@ func (h *FrontendHandler) ProductPage(c *fiber.Ctx) error {
 		return util.APIError(c, fiber.StatusNotFound, err)
 	}
 
+	picker, err := h.variantPicker(c.Context(), product, nil)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+
 	c.Set(fiber.HeaderContentType, fiber.MIMETextHTML)
-	return pages.Product(product).Render(c.Context(), c.Response().BodyWriter())
+	return pages.Product(product, picker).Render(c.Context(), c.Response().BodyWriter())
+}
+
+// ResolveVariant answers the product page's option selects with the variant
+// their values pick
+func (h *FrontendHandler) ResolveVariant(c *fiber.Ctx) error {
+	c.Locals("cache_skip", true)
+
+	product, err := h.catalogService.GetProductBySlug(c.Context(), c.Params("slug"))
+	if err != nil {
+		return util.APIError(c, fiber.StatusNotFound, err)
+	}
+
+	selection := map[string]string{}
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		if name, ok := strings.CutPrefix(string(key), "option."); ok {
+			selection[name] = string(value)
+		}
+	})
+
+	picker, err := h.variantPicker(c.Context(), product, selection)
+	if err != nil {
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTML)
+	return pages.VariantSelection(product, picker, true).Render(c.Context(), c.Response().BodyWriter())
+}
+
+// variantPicker resolves selection to a variant. A nil selection picks the
+// first variant in stock, or else the first one on sale
+func (h *FrontendHandler) variantPicker(ctx context.Context, product db.Product, selection map[string]string) (pages.VariantPicker, error) {
+	options, err := h.catalogService.ListOptions(ctx, product.ID)
+	if err != nil || len(options) == 0 {
+		return pages.VariantPicker{}, err
+	}
+	picker := pages.VariantPicker{Options: options, Selection: selection}
+
+	if selection != nil {
+		v, err := h.catalogService.ResolveVariant(ctx, product.ID, selection)
+		if errors.Is(err, service.ErrVariantUnavailable) {
+			return picker, nil
+		}
+		if err != nil {
+			return picker, err
+		}
+		picker.Variant = &v
+		return picker, nil
+	}
+
+	variants, err := h.catalogService.ListVariants(ctx, product.ID)
+	if err != nil {
+		return picker, err
+	}
+	for i, v := range variants {
+		if v.IsActive != nil && !*v.IsActive {
+			continue
+		}
+		if picker.Variant == nil || (!pages.InStock(product, *picker.Variant) && pages.InStock(product, v)) {
+			picker.Variant = &variants[i]
+		}
+	}
+	if picker.Variant != nil {
+		picker.Selection = service.Choices(*picker.Variant)
+	}
+	return picker, nil
 }
 
 // -- Cart --
@@ -149,6 +221,7 @@ func (h *FrontendHandler) getIdentities(c *fiber.Ctx) (pgtype.UUID, pgtype.UUID)
 
 type AddToCartRequest struct {
 	ProductID string `json:"product_id" form:"product_id"`
+	VariantID string `json:"variant_id" form:"variant_id"` // Required when the product has options
 	Quantity  int    `json:"quantity" form:"quantity"`
 }
 
@@ -164,13 +237,23 @@ func (h *FrontendHandler) AddToCart(c *fiber.Ctx) error {
 		return util.APIError(c, fiber.StatusBadRequest, fiber.NewError(fiber.StatusBadRequest, "invalid product id"))
 	}
 
+	var vID pgtype.UUID
+	if req.VariantID != "" {
+		if err := vID.Scan(req.VariantID); err != nil {
+			return util.APIError(c, fiber.StatusBadRequest, fiber.NewError(fiber.StatusBadRequest, "invalid variant id"))
+		}
+	}
+
 	// Default quantity 1 if missing
 	qty := req.Quantity
 	if qty <= 0 {
 		qty = 1
 	}
 
-	_, err := h.cartService.AddToCart(c.Context(), sessionID, userID, pID, pgtype.UUID{}, int32(qty))
+	_, err := h.cartService.AddToCart(c.Context(), sessionID, userID, pID, vID, int32(qty))
+	if errors.Is(err, cartservice.ErrVariantUnavailable) || errors.Is(err, cartservice.ErrVariantRequired) {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
 	if err != nil {
 		return util.APIError(c, fiber.StatusInternalServerError, err)
 	}
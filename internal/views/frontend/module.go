@@ -9,7 +9,7 @@ import (
 )
 
 func Init(app *server.Server) {
-	catalogSvc := service.NewCatalogService(app.GetDB())
+	catalogSvc := service.NewCatalogService(app.GetDB(), app.GetEntitlements())
 	cartSvc := cartservice.NewCartService(app.GetDB())
 	pbModule := page_builder.Init(app, catalogSvc, cartSvc)
 	h := handler.NewFrontendHandler(catalogSvc, cartSvc, pbModule.Service, pbModule.Resolver)
@@ -22,6 +22,7 @@ func Init(app *server.Server) {
 	routes := app.GetRouter().Group("/")
 	routes.Get("/", h.HomePage)
 	routes.Get("/product/:slug", h.ProductPage)
+	routes.Get("/product/:slug/variant", h.ResolveVariant)
 	// Dynamic Landing Pages (Catch-All) - Must be last!
 	routes.Get("/*", h.RenderLandingPage)
 
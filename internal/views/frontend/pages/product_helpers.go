@@ -0,0 +1,12 @@
+package pages
+
+import db "bizbundl/internal/db/sqlc"
+
+// InStock reports whether a variant can be bought: digital products never
+// run out
+func InStock(p db.Product, v db.ProductVariant) bool {
+	if p.IsDigital != nil && *p.IsDigital {
+		return true
+	}
+	return v.StockQuantity == nil || *v.StockQuantity > 0
+}
@@ -12,6 +12,7 @@ import (
 // We Just Replace the Frontend views/ Customer Facing Views for Each Site If Need
 // While Maintaining the Same Structure
 func Init(server *server.Server) {
-	frontend.Init(server)
+	// Admin first: the frontend ends with a catch-all for landing pages
 	admin.Init(server)
+	frontend.Init(server)
 }
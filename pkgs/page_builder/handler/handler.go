@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"bizbundl/internal/entitlements"
+	"bizbundl/pkgs/components/registry"
+	"bizbundl/pkgs/page_builder/service"
+	"bizbundl/util"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PageHandler struct {
+	service *service.PageBuilderService
+}
+
+func NewPageHandler(service *service.PageBuilderService) *PageHandler {
+	return &PageHandler{service: service}
+}
+
+// RegisterAdminRoutes sets up the API staff edit storefront pages with, on a
+// router that checks they may
+func (h *PageHandler) RegisterAdminRoutes(router fiber.Router) {
+	router.Put("/", h.SavePage)
+}
+
+type SavePageRequest struct {
+	Route     string             `json:"route" validate:"required"`
+	Name      string             `json:"name" validate:"required"`
+	Sections  []registry.Section `json:"sections"`
+	Published bool               `json:"published"`
+}
+
+// SavePage creates or replaces the page at the request's route
+func (h *PageHandler) SavePage(c *fiber.Ctx) error {
+	var req SavePageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	}
+	if errs, err := util.ValidateStruct(req); err != nil {
+		return util.JSON(c, fiber.StatusBadRequest, errs, "Validation failed")
+	}
+	if !strings.HasPrefix(req.Route, "/") {
+		return util.APIError(c, fiber.StatusBadRequest, errors.New("route must start with /"))
+	}
+
+	page, err := h.service.SavePage(c.Context(), req.Route, req.Name, req.Sections, req.Published)
+	switch {
+	case errors.Is(err, service.ErrInvalidPage):
+		return util.APIError(c, fiber.StatusBadRequest, err)
+	case errors.Is(err, entitlements.ErrUpgradeRequired):
+		return util.APIError(c, fiber.StatusForbidden, err)
+	case err != nil:
+		return util.APIError(c, fiber.StatusInternalServerError, err)
+	}
+	return util.JSON(c, fiber.StatusOK, page, "Page saved")
+}
@@ -1,9 +1,12 @@
 package page_builder
 
 import (
+	"bizbundl/internal/middleware"
+	"bizbundl/internal/permissions"
 	cart_service "bizbundl/internal/storefront/cart/service"
 	catalog_service "bizbundl/internal/storefront/catalog/service"
 	"bizbundl/internal/server"
+	"bizbundl/pkgs/page_builder/handler"
 	"bizbundl/pkgs/page_builder/resolver"
 	"bizbundl/pkgs/page_builder/service"
 
@@ -19,7 +22,7 @@ type PageBuilderModule struct {
 }
 
 func Init(app *server.Server, catalogSvc *catalog_service.CatalogService, cartSvc *cart_service.CartService) *PageBuilderModule {
-	svc := service.NewPageBuilderService(app.GetDB())
+	svc := service.NewPageBuilderService(app.GetDB(), app.GetEntitlements())
 
 	// -- Atomic Component Registration --
 	// Hero is registered via init() in pkg/components/hero
@@ -30,6 +33,13 @@ func Init(app *server.Server, catalogSvc *catalog_service.CatalogService, cartSv
 	// Core Resolver
 	res := resolver.NewPageResolver()
 
+	// Page editing, by staff allowed to (and API keys holding the scope)
+	// with two-factor, where the shop requires it
+	handler.NewPageHandler(svc).RegisterAdminRoutes(app.GetRouter().Group("/api/v1/admin/pages",
+		middleware.RequirePermission(app.GetPermissions(), permissions.ContentEdit),
+		middleware.RequireTwoFactor(app.GetSessions()),
+	))
+
 	// SeedDefaults removed from Global Init.
 	// It should be called per-tenant when a shop is provisioned or accessed.
 
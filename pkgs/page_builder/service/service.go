@@ -1,10 +1,13 @@
 package service
 
 import (
+	"bizbundl/internal/audit"
+	"bizbundl/internal/entitlements"
 	"bizbundl/internal/store"
 	"bizbundl/pkgs/components/registry"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,6 +16,9 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
+// ErrInvalidPage wraps the reason ValidatePage refused a page
+var ErrInvalidPage = errors.New("invalid page")
+
 type PageConfig struct {
 	ID       string             `json:"id"`
 	Route    string             `json:"route"`
@@ -21,16 +27,18 @@ type PageConfig struct {
 }
 
 type PageBuilderService struct {
-	store db.DBStore
+	store        db.DBStore
+	entitlements *entitlements.Service
+	audit        *audit.Log
 }
 
-func NewPageBuilderService(store db.DBStore) *PageBuilderService {
-	return &PageBuilderService{store: store}
+func NewPageBuilderService(store db.DBStore, entitlements *entitlements.Service) *PageBuilderService {
+	return &PageBuilderService{store: store, entitlements: entitlements, audit: audit.NewLog(store)}
 }
 
 func (s *PageBuilderService) GetPage(ctx context.Context, route string) (*PageConfig, error) {
 	// 1. Cache Check
-	cacheKey := "pb:page:" + route
+	cacheKey := pageCacheKey(route)
 	if val, ok := store.Get().Get(ctx, cacheKey); ok {
 		return val.(*PageConfig), nil
 	}
@@ -108,6 +116,101 @@ func (s *PageBuilderService) ValidatePage(sections []registry.Section) error {
 	return nil
 }
 
+// SavePage validates the sections, checks the plan includes every component
+// used, and creates or replaces the page at route
+func (s *PageBuilderService) SavePage(ctx context.Context, route, name string, sections []registry.Section, publish bool) (db.Page, error) {
+	// 1. Structure & Plan
+	if err := s.ValidatePage(sections); err != nil {
+		return db.Page{}, fmt.Errorf("%w: %v", ErrInvalidPage, err)
+	}
+	if err := s.entitlements.CheckComponents(ctx, sectionTypes(sections)); err != nil {
+		return db.Page{}, err
+	}
+
+	sectionsJSON, err := json.Marshal(sections)
+	if err != nil {
+		return db.Page{}, fmt.Errorf("failed to encode sections: %w", err)
+	}
+
+	// 2. Upsert
+	entry := audit.Entry{Action: "page.updated", EntityType: "page", EntityID: route}
+	before, err := s.store.GetPageByRoute(ctx, route)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		entry.Action = "page.created"
+	case err != nil:
+		return db.Page{}, fmt.Errorf("failed to get page: %w", err)
+	default:
+		entry.Before = pageSnapshot(before)
+	}
+	page, err := s.store.UpdatePage(ctx, db.UpdatePageParams{
+		Route:       route,
+		Sections:    sectionsJSON,
+		IsPublished: &publish,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		page, err = s.store.CreatePage(ctx, db.CreatePageParams{
+			Route:       route,
+			Name:        name,
+			Sections:    sectionsJSON,
+			IsPublished: &publish,
+		})
+	}
+	if err != nil {
+		return db.Page{}, fmt.Errorf("failed to save page: %w", err)
+	}
+	entry.After = pageSnapshot(page)
+	if err := s.audit.Record(ctx, entry); err != nil {
+		return db.Page{}, err
+	}
+
+	// 3. Cache Invalidate
+	store.Get().Delete(ctx, pageCacheKey(route))
+
+	return page, nil
+}
+
+// InvalidatePages drops every cached page of the shop, for changes their
+// sections show, like the catalog's
+func (s *PageBuilderService) InvalidatePages(ctx context.Context) error {
+	pages, err := s.store.ListPages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list pages: %w", err)
+	}
+	for _, page := range pages {
+		store.Get().Delete(ctx, pageCacheKey(page.Route))
+	}
+	return nil
+}
+
+func pageCacheKey(route string) string {
+	return "pb:page:" + route
+}
+
+// pageSnapshot is what the audit log compares of a page
+func pageSnapshot(page db.Page) map[string]any {
+	return map[string]any{
+		"name":         page.Name,
+		"sections":     json.RawMessage(page.Sections),
+		"is_published": page.IsPublished,
+	}
+}
+
+// sectionTypes lists the component types used by sections and their children
+func sectionTypes(sections []registry.Section) []string {
+	var types []string
+	for _, section := range sections {
+		types = append(types, section.Type)
+		if childrenRaw, ok := section.Props["children"]; ok {
+			var children []registry.Section
+			bytes, _ := json.Marshal(childrenRaw)
+			json.Unmarshal(bytes, &children)
+			types = append(types, sectionTypes(children)...)
+		}
+	}
+	return types
+}
+
 func (s *PageBuilderService) SeedDefaults(ctx context.Context) error {
 	// Check if Home exists
 	_, err := s.store.GetPageByRoute(ctx, "/")
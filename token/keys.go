@@ -0,0 +1,101 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParseKeys reads the keys of a PublicMaker from config:
+//
+//	id=k4.secret.<key>[@2006-01-02T15:04:05Z];id2=k4.public.<key>
+//
+// Keys are PASERK strings (k2 or k4, secret or public, see GenerateKey).
+// The time after @ is the key's ActiveFrom.
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid token key %q: want id=key", entry)
+		}
+		key, err := parseKey(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("token key %s: %w", id, err)
+		}
+		key.ID = strings.TrimSpace(id)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func parseKey(value string) (Key, error) {
+	value, activeFrom, scheduled := strings.Cut(value, "@")
+	var key Key
+	if scheduled {
+		t, err := time.Parse(time.RFC3339, activeFrom)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid activation time: %w", err)
+		}
+		key.ActiveFrom = t
+	}
+
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || (parts[0] != "k2" && parts[0] != "k4") {
+		return Key{}, fmt.Errorf("not a k2 or k4 PASERK")
+	}
+	key.Version = "v" + parts[0][1:]
+	raw, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid key encoding: %w", err)
+	}
+	switch {
+	case parts[1] == "secret" && len(raw) == ed25519.PrivateKeySize:
+		key.Private = ed25519.PrivateKey(raw)
+		key.Public = key.Private.Public().(ed25519.PublicKey)
+	case parts[1] == "public" && len(raw) == ed25519.PublicKeySize:
+		key.Public = ed25519.PublicKey(raw)
+	default:
+		return Key{}, fmt.Errorf("not a secret or public key")
+	}
+	return key, nil
+}
+
+// GenerateKey creates a new signing key of version V2 or V4
+func GenerateKey(id, version string) (Key, error) {
+	if version != V2 && version != V4 {
+		return Key{}, fmt.Errorf("unsupported token version %q", version)
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return Key{ID: id, Version: version, Public: public, Private: private}, nil
+}
+
+// Secret is the key's config entry for the services that sign tokens
+func (k Key) Secret() string {
+	return k.entry("secret", k.Private)
+}
+
+// Verifier is the key's config entry for the services that only verify
+// tokens
+func (k Key) Verifier() string {
+	k.ActiveFrom = time.Time{} // verifiers accept a key's tokens at any time
+	return k.entry("public", k.Public)
+}
+
+func (k Key) entry(kind string, raw []byte) string {
+	entry := k.ID + "=k" + strings.TrimPrefix(k.Version, "v") + "." + kind + "." + base64.RawURLEncoding.EncodeToString(raw)
+	if !k.ActiveFrom.IsZero() {
+		entry += "@" + k.ActiveFrom.UTC().Format(time.RFC3339)
+	}
+	return entry
+}
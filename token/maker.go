@@ -6,5 +6,7 @@ import (
 
 type Maker interface {
 	CreateToken(username string, role string, duration time.Duration) (string, *Payload, error)
+	// CreateSessionToken creates a token bound to a server-side session
+	CreateSessionToken(username string, role string, sessionID string, duration time.Duration) (string, *Payload, error)
 	VerifyToken(token string) (*Payload, error)
 }
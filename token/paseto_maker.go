@@ -39,6 +39,18 @@ func (maker *PasetoMaker) CreateToken(username string, role string, duration tim
 	return token, payload, err
 }
 
+// CreateSessionToken creates a new token bound to the session with the given ID
+func (maker *PasetoMaker) CreateSessionToken(username string, role string, sessionID string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	payload.SessionID = sessionID
+
+	token, err := maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+	return token, payload, err
+}
+
 // VerifyToken checks if the token is valid or not
 func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
 	payload := &Payload{}
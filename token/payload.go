@@ -14,9 +14,12 @@ var (
 
 // Payload contains the payload data of the token
 type Payload struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    string    `json:"user_id"`
-	Role      string    `json:"role"`
+	ID     uuid.UUID `json:"id"`
+	UserID string    `json:"user_id"`
+	Role   string    `json:"role"`
+	// SessionID ties a signed-in token to its server-side session (see
+	// internal/sessions); guest tokens have none
+	SessionID string    `json:"session_id,omitempty"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
 }
@@ -0,0 +1,240 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/o1egl/paseto"
+)
+
+// PASETO versions of public (signed) tokens. Both sign with Ed25519; v4
+// is the current one, v2 is for verifiers that only speak v2.
+const (
+	V2 = "v2"
+	V4 = "v4"
+)
+
+var ErrNoSigningKey = errors.New("no active token signing key")
+
+// Key is an Ed25519 key of a PublicMaker. Services that only verify tokens
+// hold the public half: Private is nil.
+type Key struct {
+	ID      string
+	Version string
+	Public  ed25519.PublicKey
+	Private ed25519.PrivateKey
+	// ActiveFrom schedules the key's rotation in: it signs from then on.
+	// Publish it to the verifiers first. Zero is always active.
+	ActiveFrom time.Time
+}
+
+// footer is the unencrypted footer of a public token
+type footer struct {
+	KeyID string `json:"kid"`
+}
+
+// PublicMaker is a PASETO public token maker. Tokens are signed with the
+// newest active private key and carry its ID in the footer; any of the keys
+// verifies them, so tokens signed before a rotation stay valid for as long
+// as the old key is kept.
+type PublicMaker struct {
+	keys    map[string]Key
+	signing []Key // newest first
+	now     func() time.Time
+}
+
+// NewPublicMaker creates a PublicMaker. With public keys only, it verifies
+// tokens but cannot create them.
+func NewPublicMaker(keys []Key) (Maker, error) {
+	return newPublicMaker(keys)
+}
+
+func newPublicMaker(keys []Key) (*PublicMaker, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no token keys")
+	}
+	maker := &PublicMaker{keys: map[string]Key{}, now: time.Now}
+	for _, key := range keys {
+		if key.ID == "" || strings.ContainsAny(key.ID, `"\`) {
+			return nil, fmt.Errorf("invalid token key ID %q", key.ID)
+		}
+		if _, ok := maker.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate token key ID %q", key.ID)
+		}
+		if key.Version != V2 && key.Version != V4 {
+			return nil, fmt.Errorf("token key %s: unsupported version %q", key.ID, key.Version)
+		}
+		if len(key.Public) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("token key %s: invalid public key", key.ID)
+		}
+		if key.Private != nil {
+			if len(key.Private) != ed25519.PrivateKeySize || !key.Public.Equal(key.Private.Public()) {
+				return nil, fmt.Errorf("token key %s: private key does not match the public key", key.ID)
+			}
+			maker.signing = append(maker.signing, key)
+		}
+		maker.keys[key.ID] = key
+	}
+	sort.SliceStable(maker.signing, func(i, j int) bool {
+		return maker.signing[i].ActiveFrom.After(maker.signing[j].ActiveFrom)
+	})
+	return maker, nil
+}
+
+// CreateToken creates a new token for a specific username and duration
+func (maker *PublicMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return "", payload, err
+	}
+
+	token, err := maker.sign(payload)
+	return token, payload, err
+}
+
+// CreateSessionToken creates a new token bound to the session with the given ID
+func (maker *PublicMaker) CreateSessionToken(username string, role string, sessionID string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return "", payload, err
+	}
+	payload.SessionID = sessionID
+
+	token, err := maker.sign(payload)
+	return token, payload, err
+}
+
+// VerifyToken checks if the token is valid or not
+func (maker *PublicMaker) VerifyToken(token string) (*Payload, error) {
+	key, ok := maker.keyOf(token)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	var err error
+	switch key.Version {
+	case V2:
+		err = paseto.NewV2().Verify(token, key.Public, payload, nil)
+	case V4:
+		var message []byte
+		if message, err = verifyV4(token, key.Public); err == nil {
+			err = json.Unmarshal(message, payload)
+		}
+	}
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	err = payload.Valid()
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// sign signs payload with the signing key active now
+func (maker *PublicMaker) sign(payload *Payload) (string, error) {
+	key, ok := maker.signingKey()
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+	footer, err := json.Marshal(footer{KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	if key.Version == V2 {
+		return paseto.NewV2().Sign(key.Private, payload, footer)
+	}
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return signV4(key.Private, message, footer), nil
+}
+
+// signingKey is the newest private key whose rotation is due
+func (maker *PublicMaker) signingKey() (Key, bool) {
+	now := maker.now()
+	for _, key := range maker.signing {
+		if !key.ActiveFrom.After(now) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// keyOf finds the key a token says it was signed with. The footer is read
+// before the signature is checked, but it is signed too: a token naming
+// another key does not verify with it. The key must be of the token's
+// version, so a v4 key never checks v2 tokens.
+func (maker *PublicMaker) keyOf(token string) (Key, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || parts[1] != "public" {
+		return Key{}, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return Key{}, false
+	}
+	var f footer
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return Key{}, false
+	}
+	key, ok := maker.keys[f.KeyID]
+	return key, ok && key.Version == parts[0]
+}
+
+const headerV4Public = "v4.public."
+
+// signV4 returns the v4.public token of message (with no implicit assertion)
+func signV4(private ed25519.PrivateKey, message, footer []byte) string {
+	sig := ed25519.Sign(private, preAuthEncode([]byte(headerV4Public), message, footer, nil))
+	token := headerV4Public + base64.RawURLEncoding.EncodeToString(append(message, sig...))
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+	return token
+}
+
+// verifyV4 checks a v4.public token and returns its message
+func verifyV4(token string, public ed25519.PublicKey) ([]byte, error) {
+	if !strings.HasPrefix(token, headerV4Public) {
+		return nil, ErrInvalidToken
+	}
+	body, encodedFooter, _ := strings.Cut(strings.TrimPrefix(token, headerV4Public), ".")
+	data, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil || len(data) < ed25519.SignatureSize {
+		return nil, ErrInvalidToken
+	}
+	footer, err := base64.RawURLEncoding.DecodeString(encodedFooter)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	message, sig := data[:len(data)-ed25519.SignatureSize], data[len(data)-ed25519.SignatureSize:]
+	if !ed25519.Verify(public, preAuthEncode([]byte(headerV4Public), message, footer, nil), sig) {
+		return nil, ErrInvalidToken
+	}
+	return message, nil
+}
+
+// preAuthEncode is PASETO's PAE: the count of pieces, then each piece
+// prefixed with its length, as 64-bit little-endian integers
+func preAuthEncode(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(pieces)))
+	for _, p := range pieces {
+		binary.Write(&buf, binary.LittleEndian, uint64(len(p)))
+		buf.Write(p)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,171 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateKey(t *testing.T, id, version string) Key {
+	key, err := GenerateKey(id, version)
+	require.NoError(t, err)
+	return key
+}
+
+// verifier holds only the public halves of keys
+func verifier(t *testing.T, keys ...Key) Maker {
+	var public []Key
+	for _, key := range keys {
+		key.Private = nil
+		public = append(public, key)
+	}
+	maker, err := NewPublicMaker(public)
+	require.NoError(t, err)
+	return maker
+}
+
+func TestPublicMaker(t *testing.T) {
+	for _, version := range []string{V2, V4} {
+		t.Run(version, func(t *testing.T) {
+			key := generateKey(t, "k1", version)
+			maker := makerOf(t, key)
+
+			tok, payload, err := maker.CreateSessionToken("user-1", "customer", "session-1", time.Minute)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(tok, version+".public."))
+
+			// Verified without the private key
+			got, err := verifier(t, key).VerifyToken(tok)
+			require.NoError(t, err)
+			assert.Equal(t, payload.ID, got.ID)
+			assert.Equal(t, "session-1", got.SessionID)
+
+			_, err = verifier(t, generateKey(t, "k1", version)).VerifyToken(tok)
+			assert.ErrorIs(t, err, ErrInvalidToken, "same ID, other key")
+			_, err = maker.VerifyToken(tok[:len(tok)-60] + strings.Repeat("A", 60))
+			assert.ErrorIs(t, err, ErrInvalidToken, "tampered")
+
+			expired, _, err := maker.CreateToken("user-1", "guest", -time.Second)
+			require.NoError(t, err)
+			_, err = maker.VerifyToken(expired)
+			assert.ErrorIs(t, err, ErrExpiredToken)
+
+			_, _, err = verifier(t, key).CreateToken("user-1", "guest", time.Minute)
+			assert.ErrorIs(t, err, ErrNoSigningKey)
+		})
+	}
+}
+
+// TestV4Vector checks v4.public against the PASETO test vector 4-S-1
+func TestV4Vector(t *testing.T) {
+	secret, err := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+	require.NoError(t, err)
+	message := `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`
+	const vector = "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA"
+
+	private := ed25519.PrivateKey(secret)
+	assert.Equal(t, vector, signV4(private, []byte(message), nil))
+	got, err := verifyV4(vector, private.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	assert.Equal(t, message, string(got))
+}
+
+func TestPublicMakerRejectsOtherVersions(t *testing.T) {
+	v2 := generateKey(t, "k1", V2)
+	v4 := v2
+	v4.Version = V4
+
+	tok, _, err := makerOf(t, v2).CreateToken("user-1", "customer", time.Minute)
+	require.NoError(t, err)
+	_, err = verifier(t, v4).VerifyToken(tok)
+	assert.ErrorIs(t, err, ErrInvalidToken, "keys only verify tokens of their version")
+
+	local, err := NewPasetoMaker("12345678901234567890123456789012")
+	require.NoError(t, err)
+	tok, _, err = local.CreateToken("user-1", "customer", time.Minute)
+	require.NoError(t, err)
+	_, err = verifier(t, v4).VerifyToken(tok)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Now()
+	old := generateKey(t, "2026-10", V4)
+	next := generateKey(t, "2026-11", V4)
+	next.ActiveFrom = now.Add(time.Hour)
+
+	maker, err := newPublicMaker([]Key{next, old})
+	require.NoError(t, err)
+	maker.now = func() time.Time { return now }
+	before, _, err := maker.CreateToken("user-1", "customer", 2*time.Hour)
+	require.NoError(t, err)
+
+	// Verifiers learned the next key before it signs
+	verify := verifier(t, old, next)
+
+	maker.now = func() time.Time { return now.Add(time.Hour) }
+	after, _, err := maker.CreateToken("user-1", "customer", time.Minute)
+	require.NoError(t, err)
+	assert.NotEqual(t, keyID(t, before), keyID(t, after))
+	assert.Equal(t, "2026-11", keyID(t, after))
+
+	for _, tok := range []string{before, after} {
+		_, err := verify.VerifyToken(tok)
+		assert.NoError(t, err)
+	}
+
+	// Once the old key is dropped, its tokens are no longer accepted
+	_, err = verifier(t, next).VerifyToken(before)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestParseKeys(t *testing.T) {
+	key := generateKey(t, "2026-10", V4)
+	next := generateKey(t, "2026-11", V2)
+	next.ActiveFrom = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	keys, err := ParseKeys(key.Secret() + "; " + next.Secret())
+	require.NoError(t, err)
+	assert.Equal(t, []Key{key, next}, keys)
+
+	keys, err = ParseKeys(key.Secret() + ";" + key.Verifier())
+	require.NoError(t, err)
+	_, err = NewPublicMaker(keys)
+	assert.ErrorContains(t, err, "duplicate")
+
+	keys, err = ParseKeys(next.Verifier())
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Nil(t, keys[0].Private)
+	assert.Equal(t, next.Public, keys[0].Public)
+
+	for _, spec := range []string{"k1", "k1=k3.secret.AAAA", "k1=k4.local.AAAA", "k1=k4.public.AAAA", key.Secret() + "@soon"} {
+		_, err := ParseKeys(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// makerOf is a maker signing with key
+func makerOf(t *testing.T, key Key) Maker {
+	maker, err := NewPublicMaker([]Key{key})
+	require.NoError(t, err)
+	return maker
+}
+
+// keyID is the key ID in a token's footer
+func keyID(t *testing.T, tok string) string {
+	parts := strings.Split(tok, ".")
+	require.Len(t, parts, 4)
+	raw, err := base64.RawURLEncoding.DecodeString(parts[3])
+	require.NoError(t, err)
+	var f footer
+	require.NoError(t, json.Unmarshal(raw, &f))
+	return f.KeyID
+}
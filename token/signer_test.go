@@ -0,0 +1,38 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	invites := NewSigner("secret", "invite")
+
+	tok := invites.Sign("shop_neon|a@b.c", time.Minute)
+	subject, err := invites.Verify(tok)
+	require.NoError(t, err)
+	require.Equal(t, "shop_neon|a@b.c", subject)
+
+	// Other purpose, other key, tampering, expiry
+	_, err = NewSigner("secret", "handoff").Verify(tok)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = NewSigner("other", "invite").Verify(tok)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = invites.Verify("x" + tok)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = invites.Verify(invites.Sign("a", -time.Second))
+	require.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestOpaque(t *testing.T) {
+	tok, hash, err := NewOpaque()
+	require.NoError(t, err)
+	require.Len(t, hash, 64)
+	require.Equal(t, hash, HashOpaque(tok))
+
+	other, _, err := NewOpaque()
+	require.NoError(t, err)
+	require.NotEqual(t, tok, other)
+}
@@ -123,7 +123,9 @@ func isSuspiciousImage(buffer []byte) bool {
 	return false
 }
 
-// SaveUploadedFile saves an uploaded file with security checks
+// SaveUploadedFile saves an uploaded file with security checks. Shop
+// uploads check the plan's storage first:
+// entitlements.Check(ctx, entitlements.Storage, file.Size).
 func SaveUploadedFile(file *multipart.FileHeader, subdir string, config *FileUploadConfig) (string, error) {
 	// Use default config if nil
 	if config == nil {
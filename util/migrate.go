@@ -1,8 +1,13 @@
 package util
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -53,3 +58,97 @@ func RunMigrationReset(dbURL string, migrationsDir string) error {
 	}
 	return nil
 }
+
+// newMigrate opens a migrate instance for the given database and migrations directory
+func newMigrate(dbURL string, migrationsDir string) (*migrate.Migrate, error) {
+	absPath, err := filepath.Abs(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	m, err := migrate.New("file://"+absPath, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	return m, nil
+}
+
+// MigrationVersion returns the applied version and dirty flag.
+// A database without any applied migration reports version 0.
+func MigrationVersion(dbURL string, migrationsDir string) (uint, bool, error) {
+	m, err := newMigrate(dbURL, migrationsDir)
+	if err != nil {
+		return 0, false, err
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// MigrateTo migrates up or down to the given version
+func MigrateTo(dbURL string, migrationsDir string, version uint) error {
+	m, err := newMigrate(dbURL, migrationsDir)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration to %d failed: %w", version, err)
+	}
+	return nil
+}
+
+// MigrateDown rolls back the given number of migrations
+func MigrateDown(dbURL string, migrationsDir string, steps int) error {
+	m, err := newMigrate(dbURL, migrationsDir)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("rollback of %d steps failed: %w", steps, err)
+	}
+	return nil
+}
+
+// ForceMigrationVersion sets the version and clears the dirty flag without
+// running any migration. A version of -1 means "no migration applied".
+func ForceMigrationVersion(dbURL string, migrationsDir string, version int) error {
+	m, err := newMigrate(dbURL, migrationsDir)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Force(version)
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_.+\.up\.sql$`)
+
+// MigrationVersions lists the versions available in the migrations directory, ascending
+func MigrationVersions(migrationsDir string) ([]uint, error) {
+	entries, err := os.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	versions := []uint{}
+	for _, e := range entries {
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+		v, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file %s: %w", e.Name(), err)
+		}
+		versions = append(versions, uint(v))
+	}
+	slices.Sort(versions)
+	return versions, nil
+}